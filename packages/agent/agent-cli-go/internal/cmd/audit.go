@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// auditRecord is one line of the audit log: what a run was, what sandbox it
+// got and how it ended. It names the provider but never holds a credential or
+// an environment value. ExitCode is absent when the sandbox never reported
+// one; Error says why. Status sums the outcome up in the operator's run phases.
+// GitIntegrity lists the changes to the repository's hooks and git config that
+// were reverted after the run, Protected those to the --protect paths.
+type auditRecord struct {
+	ID              string         `json:"id"`
+	StartedAt       time.Time      `json:"startedAt"`
+	EndedAt         time.Time      `json:"endedAt"`
+	Agent           string         `json:"agent"`
+	Provider        string         `json:"provider,omitempty"`
+	Isolation       string         `json:"isolation"`
+	Provision       string         `json:"provision"`
+	Network         string         `json:"network"`
+	Runtime         string         `json:"runtime,omitempty"`
+	Image           string         `json:"image,omitempty"`
+	ImageDigest     string         `json:"imageDigest,omitempty"`
+	NixRev          string         `json:"nixRev,omitempty"`
+	ClosureHash     string         `json:"closureHash,omitempty"`
+	Policy          planGuarantees `json:"policy"`
+	Capabilities    planGuarantees `json:"capabilities"`
+	WorkDir         string         `json:"workDir"`
+	WorktreeBranch  string         `json:"worktreeBranch,omitempty"`
+	Terminal        string         `json:"terminal,omitempty"`
+	TerminalSession string         `json:"terminalSession,omitempty"`
+	GitIntegrity    []string       `json:"gitIntegrity,omitempty"`
+	Protected       []string       `json:"protected,omitempty"`
+	Status          string         `json:"status,omitempty"`
+	ExitCode        *int           `json:"exitCode,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// The statuses of an audit record: the operator's Succeeded, Failed and
+// TimedOut phases.
+const (
+	runSucceeded = "Succeeded"
+	runFailed    = "Failed"
+	runTimedOut  = "TimedOut"
+)
+
+// inspectImage asks a container engine for an image's ID.
+var inspectImage = func(engine, image string) (string, error) {
+	out, err := exec.Command(engine, "image", "inspect", "--format", "{{.Id}}", image).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// stateDir returns where agent-cli keeps its state, $XDG_STATE_HOME/agent-cli.
+func stateDir() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolve user home directory: %w", err)
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "agent-cli"), nil
+}
+
+// defaultAuditLogPath returns the history every run is appended to,
+// $XDG_STATE_HOME/agent-cli/runs.jsonl.
+func defaultAuditLogPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "runs.jsonl"), nil
+}
+
+// newAuditRecord starts the record of a run about to begin.
+func newAuditRecord(axes resolvedAxes, agent *types.AgentConfig, provider *types.ModelProvider, options runOptions, workDir string, contribution provision.Contribution) auditRecord {
+	record := auditRecord{
+		ID:             newRunID(),
+		StartedAt:      time.Now().UTC(),
+		Agent:          string(agent.Type),
+		Isolation:      string(axes.IsolationName),
+		Provision:      string(axes.ProvisionName),
+		Network:        string(axes.Network),
+		Runtime:        axes.Runtime,
+		Policy:         policyGuarantees(axes.Policy),
+		Capabilities:   capabilityGuarantees(axes.Capabilities),
+		WorkDir:        workDir,
+		WorktreeBranch: options.worktreeBranch,
+		Terminal:       options.terminal,
+	}
+	if provider != nil {
+		record.Provider = provider.Name
+	}
+	if axes.IsolationName == isolation.IsolationDocker || axes.IsolationName == isolation.IsolationPodman {
+		record.Image = axes.Image
+		if record.Image == "" {
+			record.Image = isolation.DefaultContainerImage
+		}
+	}
+	if axes.ProvisionName == provision.ProvisionNix {
+		record.NixRev = options.nixRev
+		record.ClosureHash = closureHash(contribution.RoBindPaths)
+	}
+	return record
+}
+
+// newRunID returns a random run ID.
+func newRunID() string {
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// closureHash identifies a resolved nix closure by its store paths.
+func closureHash(storePaths []string) string {
+	if len(storePaths) == 0 {
+		return ""
+	}
+	sorted := slices.Sorted(slices.Values(storePaths))
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// imageDigest returns the digest a digest-pinned image names, or else the ID
+// the container engine reports for the image the run used.
+func imageDigest(engine isolation.IsolationMethod, image string) string {
+	if _, digest, ok := strings.Cut(image, "@"); ok {
+		return digest
+	}
+	id, err := inspectImage(string(engine), image)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// finishAudit completes record with how the run ended and appends it to the
+// history and, when extra is set, to that log too. A record that cannot be
+// written is a warning: the run has already happened.
+func finishAudit(record auditRecord, exitCode int, runErr error, extra string) {
+	record.EndedAt = time.Now().UTC()
+	record.Status = runFailed
+	switch {
+	case errors.Is(runErr, isoshared.ErrTimedOut):
+		record.Status = runTimedOut
+		record.Error = runErr.Error()
+		record.ExitCode = &exitCode
+	case runErr != nil:
+		record.Error = runErr.Error()
+	default:
+		record.ExitCode = &exitCode
+		if exitCode == 0 {
+			record.Status = runSucceeded
+		}
+	}
+	if record.Image != "" {
+		record.ImageDigest = imageDigest(isolation.IsolationMethod(record.Isolation), record.Image)
+	}
+	var paths []string
+	if history, err := defaultAuditLogPath(); err != nil {
+		logging.LogWarning("record run history: " + err.Error())
+	} else {
+		paths = append(paths, history)
+	}
+	if extra != "" {
+		paths = append(paths, extra)
+	}
+	for _, path := range paths {
+		if err := appendAudit(path, record); err != nil {
+			logging.LogWarning(fmt.Sprintf("write audit log %s: %v", path, err))
+		}
+	}
+}
+
+// appendAudit appends record to the JSONL log at path, creating it private to
+// the user.
+func appendAudit(path string, record auditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readAudit reads every record of the JSONL log at path, oldest first. A log
+// that does not exist yet holds no records.
+func readAudit(path string) ([]auditRecord, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+	var records []auditRecord
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record auditRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("parse audit log %s line %d: %w", path, i+1, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
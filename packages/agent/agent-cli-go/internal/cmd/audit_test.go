@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+func TestNewAuditRecordDescribesTheSandbox(t *testing.T) {
+	axes := resolvedAxes{
+		IsolationName: isolation.IsolationPodman,
+		ProvisionName: provision.ProvisionNix,
+		Network:       "proxy",
+		Runtime:       "krun",
+		Policy:        policy.SandboxPolicy{RequireKernelIsolation: true},
+	}
+	provider := &types.ModelProvider{Name: "glm", Environment: map[string]string{"ANTHROPIC_AUTH_TOKEN": "secret"}}
+	options := runOptions{nixRev: "abc", worktreeBranch: "feature", terminal: "tmux"}
+	contribution := provision.Contribution{RoBindPaths: []string{"/nix/store/b", "/nix/store/a"}}
+
+	record := newAuditRecord(axes, &types.AgentConfig{Type: types.AgentClaude}, provider, options, "/work", contribution)
+
+	if record.ID == "" || record.StartedAt.IsZero() {
+		t.Errorf("record = %+v, want an ID and a start time", record)
+	}
+	if record.Provider != "glm" || record.Isolation != "podman" || record.Runtime != "krun" || !record.Policy.KernelIsolated {
+		t.Errorf("record = %+v, want the provider, cell and policy", record)
+	}
+	if record.Image != isolation.DefaultContainerImage || record.NixRev != "abc" || record.WorktreeBranch != "feature" {
+		t.Errorf("record = %+v, want the default image, nix rev and branch", record)
+	}
+	reordered := closureHash([]string{"/nix/store/a", "/nix/store/b"})
+	if !strings.HasPrefix(record.ClosureHash, "sha256:") || record.ClosureHash != reordered {
+		t.Errorf("ClosureHash = %q, want %q whatever the path order", record.ClosureHash, reordered)
+	}
+}
+
+func TestImageDigestPrefersThePinnedDigest(t *testing.T) {
+	previous := inspectImage
+	t.Cleanup(func() { inspectImage = previous })
+	inspectImage = func(engine, image string) (string, error) {
+		if engine != "docker" || image != "node:26" {
+			return "", errors.New("unexpected inspect")
+		}
+		return "sha256:1234", nil
+	}
+
+	if got := imageDigest(isolation.IsolationDocker, "node@sha256:abcd"); got != "sha256:abcd" {
+		t.Errorf("imageDigest(pinned) = %q, want sha256:abcd", got)
+	}
+	if got := imageDigest(isolation.IsolationDocker, "node:26"); got != "sha256:1234" {
+		t.Errorf("imageDigest(tag) = %q, want the engine's image ID", got)
+	}
+	if got := imageDigest(isolation.IsolationPodman, "node:26"); got != "" {
+		t.Errorf("imageDigest(failed inspect) = %q, want none", got)
+	}
+}
+
+func TestFinishAuditAppendsToTheHistoryAndTheExtraLog(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	extra := filepath.Join(t.TempDir(), "ci", "audit.jsonl")
+
+	finishAudit(auditRecord{ID: "run1", Agent: "claude"}, 3, nil, extra)
+	finishAudit(auditRecord{ID: "run2", Agent: "claude"}, 0, errors.New("sandbox failed"), "")
+
+	history, err := readAudit(filepath.Join(state, "agent-cli", "runs.jsonl"))
+	if err != nil || len(history) != 2 {
+		t.Fatalf("readAudit(history) = (%+v, %v), want both runs", history, err)
+	}
+	if history[0].ExitCode == nil || *history[0].ExitCode != 3 || history[0].EndedAt.IsZero() {
+		t.Errorf("history[0] = %+v, want exit code 3 and an end time", history[0])
+	}
+	if history[1].ExitCode != nil || history[1].Error != "sandbox failed" {
+		t.Errorf("history[1] = %+v, want the error and no exit code", history[1])
+	}
+	collected, err := readAudit(extra)
+	if err != nil || len(collected) != 1 || collected[0].ID != "run1" {
+		t.Errorf("readAudit(extra) = (%+v, %v), want run1 alone", collected, err)
+	}
+	if info, err := os.Stat(extra); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("audit log mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestFinishAuditRecordsTheOperatorStatus(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	timedOut := fmt.Errorf("host execution: %w after 30m0s", isoshared.ErrTimedOut)
+
+	finishAudit(auditRecord{ID: "ok"}, 0, nil, "")
+	finishAudit(auditRecord{ID: "exit"}, 2, nil, "")
+	finishAudit(auditRecord{ID: "slow"}, isoshared.TimeoutExitCode, timedOut, "")
+
+	history, err := readAudit(filepath.Join(state, "agent-cli", "runs.jsonl"))
+	if err != nil || len(history) != 3 {
+		t.Fatalf("readAudit(history) = (%+v, %v), want three runs", history, err)
+	}
+	for i, want := range []string{runSucceeded, runFailed, runTimedOut} {
+		if history[i].Status != want {
+			t.Errorf("history[%d].Status = %q, want %q", i, history[i].Status, want)
+		}
+	}
+	if slow := history[2]; slow.ExitCode == nil || *slow.ExitCode != isoshared.TimeoutExitCode || slow.Error == "" {
+		t.Errorf("timed-out record = %+v, want exit code %d and the error", slow, isoshared.TimeoutExitCode)
+	}
+}
+
+func TestReadAuditNamesTheBrokenLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+	if err := os.WriteFile(path, []byte("{\"id\":\"a\"}\n\nnot json\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := readAudit(path); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("readAudit() error = %v, want line 3 named", err)
+	}
+	if records, err := readAudit(filepath.Join(t.TempDir(), "missing.jsonl")); err != nil || records != nil {
+		t.Errorf("readAudit(missing) = (%v, %v), want no records", records, err)
+	}
+}
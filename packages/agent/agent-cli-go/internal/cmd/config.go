@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	cfgpkg "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/config"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+// Setting sources, from weakest to strongest.
+const (
+	sourceDefault = "default"
+	sourceProject = "project"
+	sourceConfig  = "config"
+	sourceFlag    = "flag"
+)
+
+// runSetting ties a run option to its config key and, when it has one, its
+// flag.
+type runSetting struct {
+	key  string
+	flag string
+	// items is the list of a repeatable setting, nil for a scalar one.
+	items func(o *runOptions) *[]string
+	// apply copies the value c sets into o and reports whether c sets one.
+	apply func(o *runOptions, c *cfgpkg.FileConfig) bool
+	// value renders the setting of o.
+	value func(o *runOptions) string
+}
+
+// scalarSetting is a setting a config layer replaces when it sets a value,
+// even false or "", so a profile can undo what its base config set.
+func scalarSetting[T comparable](key, flag string, opt func(*runOptions) *T, cfg func(*cfgpkg.FileConfig) T) runSetting {
+	return runSetting{
+		key:  key,
+		flag: flag,
+		apply: func(o *runOptions, c *cfgpkg.FileConfig) bool {
+			var zero T
+			if v := cfg(c); v != zero || c.IsSet(key) {
+				*opt(o) = v
+				return true
+			}
+			return false
+		},
+		value: func(o *runOptions) string { return fmt.Sprint(*opt(o)) },
+	}
+}
+
+// listSetting is a repeatable setting: a profile's list replaces the base
+// list, and the flag values append to it.
+func listSetting(key, flag string, opt func(*runOptions) *[]string, cfg func(*cfgpkg.FileConfig) []string) runSetting {
+	return runSetting{
+		key:   key,
+		flag:  flag,
+		items: opt,
+		apply: func(o *runOptions, c *cfgpkg.FileConfig) bool {
+			if v := cfg(c); v != nil {
+				*opt(o) = slices.Clone(v)
+				return true
+			}
+			return false
+		},
+		value: func(o *runOptions) string { return strings.Join(*opt(o), ", ") },
+	}
+}
+
+// runSettings lists every run option a config file can set, in flag order.
+var runSettings = []runSetting{
+	scalarSetting("agent", "agent", func(o *runOptions) *string { return &o.agent }, func(c *cfgpkg.FileConfig) string { return c.Agent }),
+	scalarSetting("provider", "provider", func(o *runOptions) *string { return &o.provider }, func(c *cfgpkg.FileConfig) string { return c.Provider }),
+	scalarSetting("isolation", "isolation", func(o *runOptions) *string { return &o.isolation }, func(c *cfgpkg.FileConfig) string { return c.Isolation }),
+	scalarSetting("provision", "provision", func(o *runOptions) *string { return &o.provision }, func(c *cfgpkg.FileConfig) string { return c.Provision }),
+	scalarSetting("network", "network", func(o *runOptions) *string { return &o.network }, func(c *cfgpkg.FileConfig) string { return c.Network }),
+	scalarSetting("isolationDockerRuntime", "isolation-docker-runtime", func(o *runOptions) *string { return &o.isolationDockerRuntime }, func(c *cfgpkg.FileConfig) string { return c.IsolationDockerRuntime }),
+	scalarSetting("isolationPodmanRuntime", "isolation-podman-runtime", func(o *runOptions) *string { return &o.isolationPodmanRuntime }, func(c *cfgpkg.FileConfig) string { return c.IsolationPodmanRuntime }),
+	scalarSetting("isolationBwrapPassthrough", "isolation-bwrap-passthrough", func(o *runOptions) *bool { return &o.isolationBwrapPassthrough }, func(c *cfgpkg.FileConfig) bool { return c.IsolationBwrapPassthrough }),
+	scalarSetting("isolationLandlockPassthrough", "isolation-landlock-passthrough", func(o *runOptions) *bool { return &o.isolationLandlockPassthrough }, func(c *cfgpkg.FileConfig) bool { return c.IsolationLandlockPassthrough }),
+	scalarSetting("isolationNativePassthrough", "isolation-native-passthrough", func(o *runOptions) *bool { return &o.isolationNativePassthrough }, func(c *cfgpkg.FileConfig) bool { return c.IsolationNativePassthrough }),
+	listSetting("initCommands", "init-command", func(o *runOptions) *[]string { return &o.initCommands }, func(c *cfgpkg.FileConfig) []string { return c.InitCommands }),
+	scalarSetting("nixSource", "nix-source", func(o *runOptions) *string { return &o.nixSource }, func(c *cfgpkg.FileConfig) string { return c.NixSource }),
+	scalarSetting("nixRev", "nix-rev", func(o *runOptions) *string { return &o.nixRev }, func(c *cfgpkg.FileConfig) string { return c.NixRev }),
+	listSetting("nixPackages", "nix-packages", func(o *runOptions) *[]string { return &o.nixPackages }, func(c *cfgpkg.FileConfig) []string { return c.NixPackages }),
+	scalarSetting("nixShell", "nix-shell", func(o *runOptions) *string { return &o.nixShell }, func(c *cfgpkg.FileConfig) string { return c.NixShell }),
+	scalarSetting("image", "image", func(o *runOptions) *string { return &o.image }, func(c *cfgpkg.FileConfig) string { return c.Image }),
+	listSetting("networkProxyAllow", "network-proxy-allow", func(o *runOptions) *[]string { return &o.networkProxyAllow }, func(c *cfgpkg.FileConfig) []string { return c.NetworkProxyAllow }),
+	scalarSetting("shieldCredentials", "shield-credentials", func(o *runOptions) *bool { return &o.shieldCredentials }, func(c *cfgpkg.FileConfig) bool { return c.ShieldCredentials }),
+	scalarSetting("seccompProfile", "seccomp-profile", func(o *runOptions) *string { return &o.seccompProfile }, func(c *cfgpkg.FileConfig) string { return c.SeccompProfile }),
+	scalarSetting("limitMemory", "limit-memory", func(o *runOptions) *string { return &o.limitMemory }, func(c *cfgpkg.FileConfig) string { return c.LimitMemory }),
+	scalarSetting("limitPids", "limit-pids", func(o *runOptions) *int64 { return &o.limitPids }, func(c *cfgpkg.FileConfig) int64 { return c.LimitPids }),
+	scalarSetting("limitCpus", "limit-cpus", func(o *runOptions) *float64 { return &o.limitCPUs }, func(c *cfgpkg.FileConfig) float64 { return c.LimitCPUs }),
+	scalarSetting("usageReport", "usage-report", func(o *runOptions) *string { return &o.usageReport }, func(c *cfgpkg.FileConfig) string { return c.UsageReport }),
+	scalarSetting("auditLog", "audit-log", func(o *runOptions) *string { return &o.auditLog }, func(c *cfgpkg.FileConfig) string { return c.AuditLog }),
+	scalarSetting("prompt", "prompt", func(o *runOptions) *string { return &o.prompt }, func(c *cfgpkg.FileConfig) string { return c.Prompt }),
+	scalarSetting("promptFile", "prompt-file", func(o *runOptions) *string { return &o.promptFile }, func(c *cfgpkg.FileConfig) string { return c.PromptFile }),
+	scalarSetting("outputFile", "output-file", func(o *runOptions) *string { return &o.outputFile }, func(c *cfgpkg.FileConfig) string { return c.OutputFile }),
+	scalarSetting("timeout", "timeout", func(o *runOptions) *string { return &o.timeout }, func(c *cfgpkg.FileConfig) string { return c.Timeout }),
+	scalarSetting("review", "review", func(o *runOptions) *bool { return &o.review }, func(c *cfgpkg.FileConfig) bool { return c.Review }),
+	listSetting("networkForward", "network-forward", func(o *runOptions) *[]string { return &o.networkForward }, func(c *cfgpkg.FileConfig) []string { return c.NetworkForward }),
+	scalarSetting("workDir", "work-dir", func(o *runOptions) *string { return &o.workDir }, func(c *cfgpkg.FileConfig) string { return c.WorkDir }),
+	scalarSetting("worktreeBranch", "worktree-branch", func(o *runOptions) *string { return &o.worktreeBranch }, func(c *cfgpkg.FileConfig) string { return c.WorktreeBranch }),
+	scalarSetting("worktreeSourceBranch", "worktree-source-branch", func(o *runOptions) *string { return &o.worktreeSourceBranch }, func(c *cfgpkg.FileConfig) string { return c.WorktreeSourceBranch }),
+	scalarSetting("worktreeDir", "worktree-dir", func(o *runOptions) *string { return &o.worktreeDir }, func(c *cfgpkg.FileConfig) string { return c.WorktreeDir }),
+	scalarSetting("worktreeCleanup", "worktree-cleanup", func(o *runOptions) *string { return &o.worktreeCleanup }, func(c *cfgpkg.FileConfig) string { return c.WorktreeCleanup }),
+	scalarSetting("gitIntegrity", "git-integrity", func(o *runOptions) *string { return &o.gitIntegrity }, func(c *cfgpkg.FileConfig) string { return c.GitIntegrity }),
+	scalarSetting("homeDir", "", func(o *runOptions) *string { return &o.homeDir }, func(c *cfgpkg.FileConfig) string { return c.HomeDir }),
+	listSetting("bindPaths", "bind", func(o *runOptions) *[]string { return &o.bindPaths }, func(c *cfgpkg.FileConfig) []string { return c.BindPaths }),
+	listSetting("roBindPaths", "ro-bind", func(o *runOptions) *[]string { return &o.roBindPaths }, func(c *cfgpkg.FileConfig) []string { return c.RoBindPaths }),
+	listSetting("protect", "protect", func(o *runOptions) *[]string { return &o.protect }, func(c *cfgpkg.FileConfig) []string { return c.Protect }),
+	listSetting("customEnv", "env", func(o *runOptions) *[]string { return &o.customEnv }, func(c *cfgpkg.FileConfig) []string { return c.CustomEnv }),
+	scalarSetting("terminal", "terminal", func(o *runOptions) *string { return &o.terminal }, func(c *cfgpkg.FileConfig) string { return c.Terminal }),
+	scalarSetting("terminalSession", "terminal-session", func(o *runOptions) *string { return &o.terminalSession }, func(c *cfgpkg.FileConfig) string { return c.TerminalSession }),
+	scalarSetting("terminalWindow", "terminal-window", func(o *runOptions) *string { return &o.terminalWindow }, func(c *cfgpkg.FileConfig) string { return c.TerminalWindow }),
+	scalarSetting("terminalDetach", "terminal-detach", func(o *runOptions) *bool { return &o.terminalDetach }, func(c *cfgpkg.FileConfig) bool { return c.TerminalDetach }),
+	scalarSetting("vcs", "vcs", func(o *runOptions) *string { return &o.vcs }, func(c *cfgpkg.FileConfig) string { return c.VCS }),
+	scalarSetting("dryRun", "dry-run", func(o *runOptions) *bool { return &o.dryRun }, func(c *cfgpkg.FileConfig) bool { return c.DryRun }),
+	scalarSetting("output", "output", func(o *runOptions) *string { return &o.output }, func(c *cfgpkg.FileConfig) string { return c.Output }),
+	scalarSetting("requirePinnedProvision", "require-pinned-provision", func(o *runOptions) *bool { return &o.requirePinnedProvision }, func(c *cfgpkg.FileConfig) bool { return c.RequirePinnedProvision }),
+	scalarSetting("requireHostToolsUnreachable", "require-host-tools-unreachable", func(o *runOptions) *bool { return &o.requireHostToolsUnreachable }, func(c *cfgpkg.FileConfig) bool { return c.RequireHostToolsUnreachable }),
+	scalarSetting("requireEgressRestricted", "require-egress-restricted", func(o *runOptions) *bool { return &o.requireEgressRestricted }, func(c *cfgpkg.FileConfig) bool { return c.RequireEgressRestricted }),
+	scalarSetting("requireKernelIsolation", "require-kernel-isolation", func(o *runOptions) *bool { return &o.requireKernelIsolation }, func(c *cfgpkg.FileConfig) bool { return c.RequireKernelIsolation }),
+	scalarSetting("requireSyscallFilter", "require-syscall-filter", func(o *runOptions) *bool { return &o.requireSyscallFilter }, func(c *cfgpkg.FileConfig) bool { return c.RequireSyscallFilter }),
+}
+
+// settingSources maps a config key to where its effective value came from.
+type settingSources map[string]string
+
+// changed reports whether the key's value was set anywhere but the defaults.
+func (s settingSources) changed(key string) bool { return s[key] != sourceDefault }
+
+// runConfig is the user's config file and the project config file found
+// from the work directory; project is empty when there is none.
+type runConfig struct {
+	user        *cfgpkg.FileConfig
+	project     *cfgpkg.FileConfig
+	projectPath string
+}
+
+// loadRunConfig loads the config file options name, or the default one, and
+// the project config found by walking up from the work directory.
+func loadRunConfig(options runOptions, setFlags []string) (runConfig, error) {
+	user, err := cfgpkg.LoadConfigFile(options.config)
+	if err != nil {
+		return runConfig{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg := runConfig{user: user, project: &cfgpkg.FileConfig{}}
+	dir := projectSearchDir(options, setFlags, user)
+	if dir == "" {
+		if dir, err = os.Getwd(); err != nil {
+			return runConfig{}, fmt.Errorf("resolve current working directory: %w", err)
+		}
+	}
+	if cfg.projectPath, err = cfgpkg.FindProjectConfig(dir); err != nil || cfg.projectPath == "" {
+		return cfg, err
+	}
+	if cfg.project, err = cfgpkg.LoadProjectConfig(cfg.projectPath); err != nil {
+		return runConfig{}, fmt.Errorf("failed to load project config: %w", err)
+	}
+	return cfg, nil
+}
+
+// projectSearchDir returns the work directory the flags and the user's config
+// select, which a project config cannot change; "" is the current directory.
+func projectSearchDir(options runOptions, setFlags []string, user *cfgpkg.FileConfig) string {
+	if slices.Contains(setFlags, "work-dir") {
+		return options.workDir
+	}
+	if profile, ok := user.Profiles[options.profile]; ok && profile.WorkDir != "" {
+		return profile.WorkDir
+	}
+	return user.WorkDir
+}
+
+// checkProjectProvider rejects a non-portable provider preset that only a
+// project config selected, unless the user's config allows it.
+func checkProjectProvider(provider *types.ModelProvider, sources settingSources, cfg runConfig) error {
+	if provider == nil || provider.Portable || !strings.HasPrefix(sources["provider"], sourceProject) ||
+		slices.Contains(cfg.user.AllowProjectProviders, provider.Name) {
+		return nil
+	}
+	return fmt.Errorf("project config %s selects the host-specific provider %q; pass --provider or add it to allowProjectProviders in your config",
+		cfg.projectPath, provider.Name)
+}
+
+// layerOptions returns options with every setting its flags left unset taken
+// from the config files, and where each value came from. setFlags holds the
+// names of the flags given on the command line. The layers, strongest last,
+// are the project config, the user's config, then the --profile of each. A
+// repeatable setting takes the strongest layer's list, and its flag values
+// append.
+func layerOptions(options runOptions, setFlags []string, project, user *cfgpkg.FileConfig) (runOptions, settingSources, error) {
+	type layer struct {
+		source string
+		config *cfgpkg.FileConfig
+	}
+	layers := []layer{{sourceProject, project}, {sourceConfig, user}}
+	if name := options.profile; name != "" {
+		projectProfile, inProject := project.Profiles[name]
+		userProfile, inUser := user.Profiles[name]
+		if !inProject && !inUser {
+			known := cfgpkg.FileConfig{Profiles: map[string]cfgpkg.FileConfig{}}
+			maps.Copy(known.Profiles, project.Profiles)
+			maps.Copy(known.Profiles, user.Profiles)
+			_, err := known.Profile(name)
+			return runOptions{}, nil, err
+		}
+		if inProject {
+			layers = append(layers, layer{sourceProject + " profile " + name, &projectProfile})
+		}
+		if inUser {
+			layers = append(layers, layer{"profile " + name, &userProfile})
+		}
+	}
+
+	layered := options
+	sources := settingSources{}
+	for _, s := range runSettings {
+		flagSet := s.flag != "" && slices.Contains(setFlags, s.flag)
+		if s.items == nil && flagSet {
+			sources[s.key] = sourceFlag
+			continue
+		}
+		var flagItems []string
+		if s.items != nil {
+			flagItems = *s.items(&options)
+		}
+		source := sourceDefault
+		for _, l := range layers {
+			if s.apply(&layered, l.config) {
+				source = l.source
+			}
+		}
+		if s.items != nil && len(flagItems) > 0 {
+			if source == sourceDefault {
+				source = sourceFlag
+			} else {
+				*s.items(&layered) = append(*s.items(&layered), flagItems...)
+				source += " + " + sourceFlag
+			}
+		}
+		sources[s.key] = source
+	}
+	return layered, sources, nil
+}
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the run configuration",
+	}
+	cmd.AddCommand(newConfigShowCommand())
+	return cmd
+}
+
+func newConfigShowCommand() *cobra.Command {
+	options := defaultRunOptions()
+	cmd := &cobra.Command{
+		Use:   "show [run flags]",
+		Short: "Print the effective run settings and where each came from",
+		Long: `Print the effective run settings and where each came from: a flag,
+the --profile, the base config file, the project config file or the flag
+default. It takes every
+flag of run, so a command line can be checked before it is run. Custom
+environment values are redacted.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			setFlags := setFlagNames(cmd.Flags())
+			cfg, err := loadRunConfig(options, setFlags)
+			if err != nil {
+				return err
+			}
+			layered, sources, err := layerOptions(options, setFlags, cfg.project, cfg.user)
+			if err != nil {
+				return err
+			}
+			if cfg.projectPath != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Project config: %s\n\n", cfg.projectPath)
+			}
+			return printSettings(cmd.OutOrStdout(), layered, sources)
+		},
+	}
+	addRunFlags(cmd.Flags(), &options)
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand())
+}
+
+// setFlagNames returns the names of the flags given on the command line.
+func setFlagNames(fs *pflag.FlagSet) []string {
+	var names []string
+	fs.Visit(func(flag *pflag.Flag) { names = append(names, flag.Name) })
+	return names
+}
+
+// printSettings writes every setting of options with its source.
+func printSettings(w io.Writer, options runOptions, sources settingSources) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tFLAG\tVALUE\tSOURCE")
+	for _, s := range runSettings {
+		value := s.value(&options)
+		if s.key == "customEnv" {
+			value = strings.Join(redactEnv(options.customEnv), ", ")
+		}
+		flag := "-"
+		if s.flag != "" {
+			flag = "--" + s.flag
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.key, flag, value, sources[s.key])
+	}
+	return tw.Flush()
+}
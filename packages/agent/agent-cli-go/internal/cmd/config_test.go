@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	cfgpkg "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/config"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+func TestLayerOptionsPutsFlagsOverTheProfileOverTheBase(t *testing.T) {
+	file := &cfgpkg.FileConfig{
+		Isolation: "bwrap",
+		Network:   "proxy",
+		Image:     "base:1",
+		BindPaths: []string{"./base"},
+		CustomEnv: []string{"MODE=base"},
+		Profiles: map[string]cfgpkg.FileConfig{
+			"ci": {Isolation: "podman", Image: "ci:1", BindPaths: []string{"./ci"}},
+		},
+	}
+	options := defaultRunOptions()
+	options.profile = "ci"
+	options.image = "flag:1"
+	options.bindPaths = []string{"./flag"}
+
+	layered, sources, err := layerOptions(options, []string{"profile", "image", "bind"}, &cfgpkg.FileConfig{}, file)
+	if err != nil {
+		t.Fatalf("layerOptions() error = %v", err)
+	}
+
+	if layered.image != "flag:1" || sources["image"] != sourceFlag {
+		t.Errorf("image = %q from %q, want the flag", layered.image, sources["image"])
+	}
+	if layered.isolation != "podman" || sources["isolation"] != "profile ci" {
+		t.Errorf("isolation = %q from %q, want the profile", layered.isolation, sources["isolation"])
+	}
+	if layered.network != "proxy" || sources["network"] != sourceConfig {
+		t.Errorf("network = %q from %q, want the base config", layered.network, sources["network"])
+	}
+	if layered.vcs != "git" || sources["vcs"] != sourceDefault || sources.changed("vcs") {
+		t.Errorf("vcs = %q from %q, want the default", layered.vcs, sources["vcs"])
+	}
+	if want := []string{"./ci", "./flag"}; !slices.Equal(layered.bindPaths, want) || sources["bindPaths"] != "profile ci + flag" {
+		t.Errorf("bindPaths = %v from %q, want %v from the profile and flag", layered.bindPaths, sources["bindPaths"], want)
+	}
+	if !slices.Equal(layered.customEnv, []string{"MODE=base"}) || sources["customEnv"] != sourceConfig {
+		t.Errorf("customEnv = %v from %q, want the base list", layered.customEnv, sources["customEnv"])
+	}
+	if len(file.Profiles["ci"].BindPaths) != 1 {
+		t.Errorf("layerOptions() changed the profile's list to %v", file.Profiles["ci"].BindPaths)
+	}
+}
+
+func TestLayerOptionsLetsAnUnsetFlagDefaultLose(t *testing.T) {
+	layered, sources, err := layerOptions(defaultRunOptions(), nil, &cfgpkg.FileConfig{}, &cfgpkg.FileConfig{Agent: "opencode", Output: outputJSON})
+	if err != nil {
+		t.Fatalf("layerOptions() error = %v", err)
+	}
+	if layered.agent != "opencode" || layered.output != outputJSON || !sources.changed("agent") {
+		t.Errorf("layerOptions() = %+v, want the config's agent and output", layered)
+	}
+
+	options := defaultRunOptions()
+	options.networkForward = []string{"5432"}
+	layered, sources, _ = layerOptions(options, []string{"network-forward"}, &cfgpkg.FileConfig{}, &cfgpkg.FileConfig{})
+	if !slices.Equal(layered.networkForward, []string{"5432"}) || sources["networkForward"] != sourceFlag {
+		t.Errorf("networkForward = %v from %q, want the flag alone", layered.networkForward, sources["networkForward"])
+	}
+}
+
+func TestLayerOptionsRejectsAnUnknownProfile(t *testing.T) {
+	options := defaultRunOptions()
+	options.profile = "missing"
+	if _, _, err := layerOptions(options, []string{"profile"}, &cfgpkg.FileConfig{}, &cfgpkg.FileConfig{}); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Errorf("layerOptions() error = %v, want unknown profile", err)
+	}
+}
+
+func TestLayerOptionsPutsTheProjectConfigUnderTheUserConfig(t *testing.T) {
+	project := &cfgpkg.FileConfig{
+		Isolation: "bwrap",
+		NixRev:    "abc",
+		Profiles:  map[string]cfgpkg.FileConfig{"ci": {NixPackages: []string{"go"}}},
+	}
+	user := &cfgpkg.FileConfig{Isolation: "podman", NixPackages: []string{"ripgrep"}}
+	options := defaultRunOptions()
+	options.profile = "ci"
+
+	layered, sources, err := layerOptions(options, []string{"profile"}, project, user)
+	if err != nil {
+		t.Fatalf("layerOptions() error = %v", err)
+	}
+	if layered.isolation != "podman" || sources["isolation"] != sourceConfig {
+		t.Errorf("isolation = %q from %q, want the user's", layered.isolation, sources["isolation"])
+	}
+	if layered.nixRev != "abc" || sources["nixRev"] != sourceProject {
+		t.Errorf("nixRev = %q from %q, want the project's", layered.nixRev, sources["nixRev"])
+	}
+	if !slices.Equal(layered.nixPackages, []string{"go"}) || sources["nixPackages"] != "project profile ci" {
+		t.Errorf("nixPackages = %v from %q, want the project profile's", layered.nixPackages, sources["nixPackages"])
+	}
+}
+
+// An explicit false or "" in a profile undoes its base config; in a project
+// config it is dropped, so the project never resets the user's settings.
+func TestLayerOptionsLetsAProfileResetABaseValue(t *testing.T) {
+	for name, content := range map[string]string{
+		"yaml": "shieldCredentials: true\ndryRun: true\nrequireEgressRestricted: true\ntimeout: 30m\nprofiles:\n  local:\n    shieldCredentials: false\n    dryRun: false\n    timeout: \"\"\n",
+		"toml": "shieldCredentials = true\ndryRun = true\nrequireEgressRestricted = true\ntimeout = \"30m\"\n\n[profiles.local]\nshieldCredentials = false\ndryRun = false\ntimeout = \"\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			userPath := filepath.Join(dir, "config."+name)
+			projectPath := filepath.Join(dir, ".agent-cli."+name)
+			project := "requireEgressRestricted: false\nprofiles:\n  local:\n    requireEgressRestricted: false\n"
+			if name == "toml" {
+				project = "requireEgressRestricted = false\n\n[profiles.local]\nrequireEgressRestricted = false\n"
+			}
+			for path, content := range map[string]string{userPath: content, projectPath: project} {
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			}
+			user, err := cfgpkg.LoadConfigFile(userPath)
+			if err != nil {
+				t.Fatalf("LoadConfigFile() error = %v", err)
+			}
+			projectConfig, err := cfgpkg.LoadProjectConfig(projectPath)
+			if err != nil {
+				t.Fatalf("LoadProjectConfig() error = %v", err)
+			}
+			options := defaultRunOptions()
+			options.profile = "local"
+
+			layered, sources, err := layerOptions(options, []string{"profile"}, projectConfig, user)
+			if err != nil {
+				t.Fatalf("layerOptions() error = %v", err)
+			}
+			if layered.shieldCredentials || layered.dryRun || sources["dryRun"] != "profile local" {
+				t.Errorf("shieldCredentials, dryRun = %t, %t from %q, want both reset by the profile", layered.shieldCredentials, layered.dryRun, sources["dryRun"])
+			}
+			if layered.timeout != "" || sources["timeout"] != "profile local" {
+				t.Errorf("timeout = %q from %q, want it reset by the profile", layered.timeout, sources["timeout"])
+			}
+			if !layered.requireEgressRestricted || sources["requireEgressRestricted"] != sourceConfig {
+				t.Errorf("requireEgressRestricted = %t from %q, want the user's kept over the project's false", layered.requireEgressRestricted, sources["requireEgressRestricted"])
+			}
+		})
+	}
+}
+
+func TestCheckProjectProviderNeedsTheUserToAllowAHostSpecificPreset(t *testing.T) {
+	gemini, err := resolveProvider(types.AgentClaude, "gemini")
+	if err != nil {
+		t.Fatalf("resolveProvider() error = %v", err)
+	}
+	glm, err := resolveProvider(types.AgentClaude, "glm")
+	if err != nil {
+		t.Fatalf("resolveProvider() error = %v", err)
+	}
+	fromProject := settingSources{"provider": sourceProject}
+	cfg := runConfig{user: &cfgpkg.FileConfig{}, projectPath: "/repo/.agent-cli.yaml"}
+
+	if err := checkProjectProvider(gemini, fromProject, cfg); err == nil || !strings.Contains(err.Error(), "allowProjectProviders") {
+		t.Errorf("checkProjectProvider(gemini) error = %v, want the opt-in named", err)
+	}
+	if err := checkProjectProvider(glm, fromProject, cfg); err != nil {
+		t.Errorf("checkProjectProvider(glm) error = %v, want a portable preset allowed", err)
+	}
+	if err := checkProjectProvider(gemini, settingSources{"provider": sourceFlag}, cfg); err != nil {
+		t.Errorf("checkProjectProvider(--provider gemini) error = %v, want the user's choice allowed", err)
+	}
+	cfg.user.AllowProjectProviders = []string{"gemini"}
+	if err := checkProjectProvider(gemini, fromProject, cfg); err != nil {
+		t.Errorf("checkProjectProvider(allowed gemini) error = %v, want nil", err)
+	}
+}
+
+func TestLoadRunConfigDiscoversTheProjectConfigFromTheWorkDirectory(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "src")
+	if err := os.Mkdir(nested, 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	path := filepath.Join(root, ".agent-cli.toml")
+	if err := os.WriteFile(path, []byte("nixRev = \"abc\"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	userConfig := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(userConfig, []byte("workDir: "+nested+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cfg, err := loadRunConfig(runOptions{config: userConfig}, nil)
+	if err != nil {
+		t.Fatalf("loadRunConfig() error = %v", err)
+	}
+	if cfg.projectPath != path || cfg.project.NixRev != "abc" {
+		t.Errorf("loadRunConfig() = %+v, want %s loaded", cfg, path)
+	}
+}
+
+func TestRunSettingsCoverEveryRunFlag(t *testing.T) {
+	skipped := []string{"config", "plan", "profile"}
+	newRunCommand().Flags().VisitAll(func(flag *pflag.Flag) {
+		if slices.Contains(skipped, flag.Name) {
+			return
+		}
+		if !slices.ContainsFunc(runSettings, func(s runSetting) bool { return s.flag == flag.Name }) {
+			t.Errorf("--%s has no config setting", flag.Name)
+		}
+	})
+}
+
+func TestConfigShowPrintsTheSourcesAndRedactsTheEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "isolation: bwrap\ncustomEnv:\n  - API_KEY=secret\nprofiles:\n  review:\n    network: none\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	command := newConfigShowCommand()
+	var out bytes.Buffer
+	command.SetOut(&out)
+	command.SetErr(&bytes.Buffer{})
+	command.SetArgs([]string{"--config", path, "--profile", "review", "--vcs", "jj"})
+
+	if err := command.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"isolation", "bwrap", "profile review", "--vcs", "API_KEY=" + redacted} {
+		if !strings.Contains(got, want) {
+			t.Errorf("config show = %q, want %q", got, want)
+		}
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("config show = %q, want the environment value redacted", got)
+	}
+}
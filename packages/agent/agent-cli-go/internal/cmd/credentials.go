@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/credshield"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/providers"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// credentialShieldAddr is where the shield listens; the port is chosen per run.
+const credentialShieldAddr = "127.0.0.1:0"
+
+// prepareCredentialShield starts a credential shield holding provider's host
+// credential and returns the provider the sandbox sees instead: its base URL
+// points at the shield and its token is minted for this run. The shield's
+// loopback address is returned so network none/proxy runs forward it in; host
+// runs reach it directly. The returned revoke function is idempotent and stops
+// the shield. With listen unset, for dry runs, nothing serves the credential:
+// the provider points at a free loopback port the shield would take. Either
+// way the host credential is removed from this process's environment. A
+// provider without a credential is returned unchanged.
+func prepareCredentialShield(provider *types.ModelProvider, listen, verbose bool) (*types.ModelProvider, string, func(), error) {
+	if provider == nil {
+		return nil, "", func() {}, nil
+	}
+	upstream, credential, ok, err := providers.CredentialUpstream(provider)
+	if err != nil || !ok {
+		return provider, "", func() {}, err
+	}
+	shield, err := credshield.New(upstream, credential)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	// The shield holds the credential now. Isolators that pass this
+	// process's environment on (landlock, native) would otherwise hand the
+	// agent the raw credential next to its run token.
+	if err := os.Unsetenv(provider.CredentialSourceEnv); err != nil {
+		return nil, "", nil, fmt.Errorf("drop %s from the environment: %w", provider.CredentialSourceEnv, err)
+	}
+	if !listen {
+		addr, err := freeLoopbackAddr()
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return providers.ShieldProvider(provider, "http://"+addr, shield.Token()), addr, func() {}, nil
+	}
+	if verbose {
+		shield.WithLogger(func(message string) { logging.LogDebug(message) })
+	}
+	if err := shield.Listen(credentialShieldAddr); err != nil {
+		return nil, "", nil, err
+	}
+	addr := shield.Addr()
+	if verbose {
+		logging.LogDebug("credential shield for " + provider.Name + " listening on " + addr)
+	}
+	revoke := func() { _ = shield.Close() }
+	return providers.ShieldProvider(provider, "http://"+addr, shield.Token()), addr, revoke, nil
+}
+
+// freeLoopbackAddr returns a loopback address whose port is free right now.
+func freeLoopbackAddr() (string, error) {
+	listener, err := net.Listen("tcp", credentialShieldAddr)
+	if err != nil {
+		return "", fmt.Errorf("reserve credential shield address: %w", err)
+	}
+	defer func() { _ = listener.Close() }()
+	return listener.Addr().String(), nil
+}
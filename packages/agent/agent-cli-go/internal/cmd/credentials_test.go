@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/providers"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+func TestPrepareCredentialShield_HidesHostCredential(t *testing.T) {
+	t.Setenv("ZAI_AUTH_TOKEN", "host-secret")
+	provider, err := providers.GetProvider("glm", types.AgentClaude)
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+
+	shielded, addr, revoke, err := prepareCredentialShield(provider, true, false)
+	if err != nil {
+		t.Fatalf("prepareCredentialShield() error = %v", err)
+	}
+	defer revoke()
+	env, err := providers.BuildProviderEnv(shielded)
+	if err != nil {
+		t.Fatalf("BuildProviderEnv(shielded) error = %v", err)
+	}
+	if env["ANTHROPIC_AUTH_TOKEN"] == "host-secret" || env["ANTHROPIC_AUTH_TOKEN"] == "" {
+		t.Errorf("ANTHROPIC_AUTH_TOKEN = %q, want a per-run token", env["ANTHROPIC_AUTH_TOKEN"])
+	}
+	if env["ANTHROPIC_BASE_URL"] != "http://"+addr {
+		t.Errorf("ANTHROPIC_BASE_URL = %q, want the shield at %s", env["ANTHROPIC_BASE_URL"], addr)
+	}
+
+	// Nothing this process hands an isolator holds the raw credential.
+	for _, entry := range os.Environ() {
+		if strings.Contains(entry, "host-secret") {
+			t.Errorf("environment still holds %q, want the host credential dropped", entry)
+		}
+	}
+
+	// Once revoked, the run token no longer passes the shield.
+	revoke()
+	req, _ := http.NewRequest(http.MethodGet, env["ANTHROPIC_BASE_URL"]+"/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer "+env["ANTHROPIC_AUTH_TOKEN"])
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("revoked token status = %d, want refusal", resp.StatusCode)
+		}
+	}
+}
+
+func TestPrepareCredentialShield_DryRunDoesNotListen(t *testing.T) {
+	t.Setenv("ZAI_AUTH_TOKEN", "host-secret")
+	provider, err := providers.GetProvider("glm", types.AgentClaude)
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+
+	shielded, addr, revoke, err := prepareCredentialShield(provider, false, false)
+	if err != nil {
+		t.Fatalf("prepareCredentialShield() error = %v", err)
+	}
+	defer revoke()
+	env, err := providers.BuildProviderEnv(shielded)
+	if err != nil {
+		t.Fatalf("BuildProviderEnv(shielded) error = %v", err)
+	}
+	if env["ANTHROPIC_BASE_URL"] != "http://"+addr || env["ANTHROPIC_AUTH_TOKEN"] == "host-secret" {
+		t.Errorf("env = %v, want the provider pointed at %s with a per-run token", env, addr)
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		_ = conn.Close()
+		t.Errorf("Dial(%s) succeeded, want nothing serving the credential on a dry run", addr)
+	}
+}
+
+func TestPrepareCredentialShield_PassesThroughProvidersWithoutCredential(t *testing.T) {
+	provider, err := providers.GetProvider("gemini", types.AgentOpencode)
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+	got, addr, revoke, err := prepareCredentialShield(provider, true, false)
+	if err != nil || got != provider || addr != "" {
+		t.Fatalf("prepareCredentialShield(no credential) = (%v, %q, %v), want the provider unchanged", got, addr, err)
+	}
+	revoke()
+	if got, _, _, err := prepareCredentialShield(nil, true, false); got != nil || err != nil {
+		t.Errorf("prepareCredentialShield(nil) = (%v, %v), want (nil, nil)", got, err)
+	}
+}
+
+func TestPrepareCredentialShield_RequiresHostCredential(t *testing.T) {
+	t.Setenv("ZAI_AUTH_TOKEN", "")
+	provider, err := providers.GetProvider("glm", types.AgentClaude)
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+	if _, _, _, err := prepareCredentialShield(provider, true, false); err == nil {
+		t.Error("prepareCredentialShield(missing token) error = nil, want error")
+	}
+}
+
+func TestRunAgentRefusesTheCredentialShieldWithoutASandbox(t *testing.T) {
+	t.Setenv("ZAI_AUTH_TOKEN", "host-secret")
+	workDir := t.TempDir()
+	options := defaultRunOptions()
+	options.workDir = workDir
+	options.config = filepath.Join(workDir, "none.yaml")
+	options.provider = "glm"
+	options.shieldCredentials = true
+	if err := os.WriteFile(options.config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := runAgent(newRunCommand(), nil, options); err == nil || !strings.Contains(err.Error(), "isolation=none") {
+		t.Errorf("runAgent() error = %v, want the shield refused under isolation=none", err)
+	}
+	if got := os.Getenv("ZAI_AUTH_TOKEN"); got != "host-secret" {
+		t.Errorf("ZAI_AUTH_TOKEN = %q, want the refused run to leave it alone", got)
+	}
+}
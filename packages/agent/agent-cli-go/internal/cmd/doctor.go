@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/landlock"
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/provision/nix"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/sandbox"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/sandbox/plugins"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/terminal"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/git"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/jj"
+	wsshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/agents"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/providers"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+)
+
+// Doctor check statuses: pass is ready to use, warn is an optional feature the
+// host lacks, and fail is a feature that is present but broken.
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// landlockNetworkABI is the first Landlock ABI with TCP rules.
+const landlockNetworkABI = 4
+
+// ErrDoctorFailed reports a doctor run with at least one failed check.
+var ErrDoctorFailed = errors.New("host checks failed")
+
+// doctorCheck is one row of the doctor report. Detail never carries a
+// credential value.
+type doctorCheck struct {
+	Area   string `json:"area"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// isolationHints tell the user how to make an unavailable isolator available.
+var isolationHints = map[isolation.IsolationMethod]string{
+	isolation.IsolationBwrap:    "install bubblewrap (bwrap) and allow unprivileged user namespaces",
+	isolation.IsolationDocker:   "install docker and start the daemon, or add your user to the docker group",
+	isolation.IsolationPodman:   "install podman",
+	isolation.IsolationLandlock: "use Linux 5.13 or newer with landlock in the lsm= boot parameter",
+	isolation.IsolationNative:   "allow unprivileged user namespaces (sysctl user.max_user_namespaces, kernel.unprivileged_userns_clone)",
+}
+
+// provisionHints tell the user how to make an unavailable provisioner available.
+var provisionHints = map[provision.ProvisionMethod]string{
+	provision.ProvisionNix: "install nix (https://nixos.org/download)",
+}
+
+// provisionPrerequisites are the further checks of an available provisioner.
+var provisionPrerequisites = map[provision.ProvisionMethod]func() []doctorCheck{
+	provision.ProvisionNix: func() []doctorCheck {
+		return []doctorCheck{
+			errCheck("provision", "nix store", nix.CheckStore(), "reachable",
+				"start the nix daemon (systemctl start nix-daemon) or check the store permissions"),
+			errCheck("provision", "nix flakes", nix.CheckFlakes(), "nix-command and flakes enabled",
+				"add 'experimental-features = nix-command flakes' to nix.conf"),
+		}
+	},
+}
+
+// kernelFeatures are the kernel facilities the isolators build on.
+type kernelFeatures struct {
+	userNamespaces bool
+	cgroupV2       bool
+	landlockABI    int
+	seccomp        bool
+}
+
+func probeKernel() kernelFeatures {
+	return kernelFeatures{
+		userNamespaces: isoshared.UserNamespacesAvailable(),
+		cgroupV2:       isoshared.CgroupV2Available(),
+		landlockABI:    landlock.ABIVersion(),
+		seccomp:        isoshared.SeccompAvailable(),
+	}
+}
+
+func newDoctorCommand() *cobra.Command {
+	output := outputText
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check which sandboxes, provisioners and credentials this host supports",
+		Long: `Check every isolation and provision method, terminal wrapper and VCS,
+the kernel features the sandboxes build on and the provider credentials in
+the environment. Each check passes, warns about an optional feature the host
+lacks, or fails for a feature that is present but broken, with a hint on how
+to fix it. The command exits non-zero when a check fails.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if output != outputText && output != outputJSON {
+				return fmt.Errorf("invalid --output %q (want %s or %s)", output, outputText, outputJSON)
+			}
+			checks := doctorChecks(plugins.DefaultRegistry(), wsshared.NewExecRunner(), probeKernel())
+			if err := printDoctor(cmd.OutOrStdout(), checks, output); err != nil {
+				return err
+			}
+			if slices.ContainsFunc(checks, func(c doctorCheck) bool { return c.Status == doctorFail }) {
+				return ErrDoctorFailed
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", output, "Report format (text, json)")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newDoctorCommand())
+}
+
+// doctorChecks runs every check, grouped by area in report order.
+func doctorChecks(reg *sandbox.Registry, runner wsshared.Runner, kernel kernelFeatures) []doctorCheck {
+	var checks []doctorCheck
+	for _, m := range reg.IsolationMethods() {
+		iso, err := reg.Isolator(m)
+		if err != nil {
+			continue
+		}
+		ok, err := iso.Available()
+		checks = append(checks, availabilityCheck("isolation", string(m), ok, err, isolationHints[m]))
+	}
+	for _, m := range reg.ProvisionMethods() {
+		prov, err := reg.Provisioner(m)
+		if err != nil {
+			continue
+		}
+		ok, err := prov.Available()
+		checks = append(checks, availabilityCheck("provision", string(m), ok, err, provisionHints[m]))
+		if prerequisites := provisionPrerequisites[m]; ok && err == nil && prerequisites != nil {
+			checks = append(checks, prerequisites()...)
+		}
+	}
+	for _, t := range terminal.GetTypes() {
+		executor := terminal.GetExecutor(t)
+		checks = append(checks, availabilityCheck("terminal", string(t), executor != nil && executor.IsAvailable(), nil, "install "+string(t)))
+	}
+	checks = append(checks,
+		availabilityCheck("vcs", "git", git.New(runner).Available(), nil, "install git"),
+		availabilityCheck("vcs", "jj", jj.New(runner).Available(), nil, "install jj (jj-vcs)"),
+	)
+	checks = append(checks, kernelChecks(kernel)...)
+	checks = append(checks, credentialChecks(os.Getenv)...)
+	return checks
+}
+
+// availabilityCheck passes an available method, warns about a missing one and
+// fails one whose probe errored.
+func availabilityCheck(area, name string, ok bool, err error, hint string) doctorCheck {
+	switch {
+	case err != nil:
+		return doctorCheck{Area: area, Name: name, Status: doctorFail, Detail: err.Error(), Hint: hint}
+	case ok:
+		return doctorCheck{Area: area, Name: name, Status: doctorPass, Detail: "available"}
+	default:
+		return doctorCheck{Area: area, Name: name, Status: doctorWarn, Detail: "not available", Hint: hint}
+	}
+}
+
+// errCheck passes with detail when err is nil and fails with err otherwise.
+func errCheck(area, name string, err error, detail, hint string) doctorCheck {
+	if err != nil {
+		return doctorCheck{Area: area, Name: name, Status: doctorFail, Detail: err.Error(), Hint: hint}
+	}
+	return doctorCheck{Area: area, Name: name, Status: doctorPass, Detail: detail}
+}
+
+// featureCheck passes a present kernel feature and warns about a missing one.
+func featureCheck(name string, present bool, detail, hint string) doctorCheck {
+	if present {
+		return doctorCheck{Area: "kernel", Name: name, Status: doctorPass, Detail: "available"}
+	}
+	return doctorCheck{Area: "kernel", Name: name, Status: doctorWarn, Detail: detail, Hint: hint}
+}
+
+func kernelChecks(k kernelFeatures) []doctorCheck {
+	checks := []doctorCheck{
+		featureCheck("user namespaces", k.userNamespaces, "unprivileged user namespaces are disabled; bwrap and native cannot start",
+			"sysctl -w user.max_user_namespaces=15000 kernel.unprivileged_userns_clone=1"),
+		featureCheck("cgroup v2", k.cgroupV2, "no unified cgroup hierarchy; resource limits are not enforced for host-process sandboxes",
+			"boot with systemd.unified_cgroup_hierarchy=1"),
+		featureCheck("seccomp", k.seccomp, "the kernel has no seccomp; --seccomp-profile cannot be installed",
+			"use a kernel built with CONFIG_SECCOMP_FILTER"),
+	}
+	landlockCheck := doctorCheck{Area: "kernel", Name: "landlock", Status: doctorPass, Detail: fmt.Sprintf("ABI %d", k.landlockABI)}
+	switch {
+	case k.landlockABI < 1:
+		landlockCheck = featureCheck("landlock", false, "not supported", isolationHints[isolation.IsolationLandlock])
+	case k.landlockABI < landlockNetworkABI:
+		landlockCheck.Status = doctorWarn
+		landlockCheck.Detail = fmt.Sprintf("ABI %d has no TCP rules; landlock cannot restrict the network", k.landlockABI)
+		landlockCheck.Hint = "use Linux 6.7 or newer for Landlock ABI 4"
+	}
+	return append(checks, landlockCheck)
+}
+
+// credentialChecks reports, by name only, which provider credentials the
+// environment holds.
+func credentialChecks(getenv func(string) string) []doctorCheck {
+	var names []string
+	usedBy := map[string][]string{}
+	for _, agentType := range agents.GetAgentTypes() {
+		for _, name := range providers.GetProviderNames(agentType) {
+			provider, err := providers.GetProvider(name, agentType)
+			if err != nil || provider.CredentialSourceEnv == "" {
+				continue
+			}
+			env := provider.CredentialSourceEnv
+			if _, seen := usedBy[env]; !seen {
+				names = append(names, env)
+			}
+			if !slices.Contains(usedBy[env], name) {
+				usedBy[env] = append(usedBy[env], name)
+			}
+		}
+	}
+	slices.Sort(names)
+	checks := make([]doctorCheck, 0, len(names))
+	for _, env := range names {
+		presets := strings.Join(usedBy[env], ", ")
+		if getenv(env) != "" {
+			checks = append(checks, doctorCheck{Area: "credentials", Name: env, Status: doctorPass, Detail: "set (" + presets + ")"})
+			continue
+		}
+		checks = append(checks, doctorCheck{Area: "credentials", Name: env, Status: doctorWarn,
+			Detail: "not set (" + presets + ")", Hint: "export " + env + " to use --provider " + usedBy[env][0]})
+	}
+	return checks
+}
+
+// printDoctor writes the checks as a table followed by a summary, or as JSON.
+func printDoctor(w io.Writer, checks []doctorCheck, output string) error {
+	if output == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(checks)
+	}
+	counts := map[string]int{}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AREA\tCHECK\tSTATUS\tDETAIL\tHINT")
+	for _, c := range checks {
+		counts[c.Status]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Area, c.Name, c.Status, c.Detail, c.Hint)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", counts[doctorPass], counts[doctorWarn], counts[doctorFail])
+	return err
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	wsshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/shared"
+)
+
+type brokenIsolator struct{ isoshared.Isolator }
+
+func (brokenIsolator) Available() (bool, error) { return false, errors.New("daemon socket refused") }
+
+type doctorRunner struct{ wsshared.Runner }
+
+func (doctorRunner) Available(string) bool { return false }
+
+func findCheck(t *testing.T, checks []doctorCheck, area, name string) doctorCheck {
+	t.Helper()
+	for _, c := range checks {
+		if c.Area == area && c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %s check %q in %+v", area, name, checks)
+	return doctorCheck{}
+}
+
+func TestDoctorChecksEveryAxisAndKernelFeature(t *testing.T) {
+	reg := everythingAvailable()
+	docker, _ := reg.Isolator("docker")
+	reg.RegisterIsolator("docker", func() isoshared.Isolator { return brokenIsolator{docker} })
+	// The fake registry reports nix available; its prerequisites probe no nix.
+	t.Setenv("PATH", t.TempDir())
+
+	checks := doctorChecks(reg, doctorRunner{}, kernelFeatures{cgroupV2: true, landlockABI: 3, seccomp: true})
+
+	if c := findCheck(t, checks, "isolation", "bwrap"); c.Status != doctorPass {
+		t.Errorf("bwrap = %+v, want pass", c)
+	}
+	if c := findCheck(t, checks, "isolation", "docker"); c.Status != doctorFail || c.Detail != "daemon socket refused" || c.Hint == "" {
+		t.Errorf("docker = %+v, want a failure with the probe error and a hint", c)
+	}
+	if c := findCheck(t, checks, "provision", "nix flakes"); c.Status != doctorFail {
+		t.Errorf("nix flakes = %+v, want a failure without the nix CLI", c)
+	}
+	if c := findCheck(t, checks, "terminal", "tmux"); c.Status != doctorWarn || c.Hint != "install tmux" {
+		t.Errorf("tmux = %+v, want a warning to install tmux", c)
+	}
+	if c := findCheck(t, checks, "vcs", "jj"); c.Status != doctorWarn {
+		t.Errorf("jj = %+v, want a warning", c)
+	}
+	if c := findCheck(t, checks, "vcs", "git"); c.Status != doctorPass {
+		t.Errorf("git = %+v, want pass", c)
+	}
+	if c := findCheck(t, checks, "kernel", "user namespaces"); c.Status != doctorWarn || !strings.Contains(c.Hint, "max_user_namespaces") {
+		t.Errorf("user namespaces = %+v, want a warning with the sysctl", c)
+	}
+	if c := findCheck(t, checks, "kernel", "landlock"); c.Status != doctorWarn || !strings.Contains(c.Detail, "ABI 3") {
+		t.Errorf("landlock = %+v, want a warning about ABI 3", c)
+	}
+	if c := findCheck(t, checks, "kernel", "cgroup v2"); c.Status != doctorPass {
+		t.Errorf("cgroup v2 = %+v, want pass", c)
+	}
+}
+
+func TestKernelChecksReportLandlockByABI(t *testing.T) {
+	for abi, want := range map[int]string{0: doctorWarn, 4: doctorPass} {
+		checks := kernelChecks(kernelFeatures{landlockABI: abi})
+		if c := findCheck(t, checks, "kernel", "landlock"); c.Status != want {
+			t.Errorf("landlock at ABI %d = %+v, want %s", abi, c, want)
+		}
+	}
+}
+
+func TestCredentialChecksNameTheVariableNotTheValue(t *testing.T) {
+	env := map[string]string{"ZAI_AUTH_TOKEN": "secret-value"}
+	checks := credentialChecks(func(name string) string { return env[name] })
+	if len(checks) == 0 {
+		t.Fatal("credentialChecks() = none, want one per provider credential")
+	}
+	set := findCheck(t, checks, "credentials", "ZAI_AUTH_TOKEN")
+	if set.Status != doctorPass || strings.Contains(set.Detail, "secret-value") {
+		t.Errorf("ZAI_AUTH_TOKEN = %+v, want pass without the value", set)
+	}
+	for _, c := range checks {
+		if c.Name != "ZAI_AUTH_TOKEN" && (c.Status != doctorWarn || !strings.HasPrefix(c.Hint, "export "+c.Name)) {
+			t.Errorf("%s = %+v, want a warning to export it", c.Name, c)
+		}
+	}
+}
+
+func TestPrintDoctorWritesATableOrJSON(t *testing.T) {
+	checks := []doctorCheck{
+		{Area: "isolation", Name: "none", Status: doctorPass, Detail: "available"},
+		{Area: "terminal", Name: "tmux", Status: doctorWarn, Detail: "not available", Hint: "install tmux"},
+	}
+	var text bytes.Buffer
+	if err := printDoctor(&text, checks, outputText); err != nil {
+		t.Fatalf("printDoctor(text) error = %v", err)
+	}
+	if !strings.Contains(text.String(), "install tmux") || !strings.HasSuffix(text.String(), "1 passed, 1 warnings, 0 failed\n") {
+		t.Errorf("printDoctor(text) = %q, want the hint and the summary", text.String())
+	}
+
+	var out bytes.Buffer
+	if err := printDoctor(&out, checks, outputJSON); err != nil {
+		t.Fatalf("printDoctor(json) error = %v", err)
+	}
+	var decoded []doctorCheck
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1].Hint != "install tmux" {
+		t.Errorf("printDoctor(json) = %s (%v), want both checks", out.String(), err)
+	}
+}
+
+func TestDoctorCommandRejectsAnUnknownOutput(t *testing.T) {
+	command := newDoctorCommand()
+	command.SetArgs([]string{"--output", "yaml"})
+	command.SetOut(&bytes.Buffer{})
+	command.SetErr(&bytes.Buffer{})
+	if err := command.Execute(); err == nil || !strings.Contains(err.Error(), "invalid --output") {
+		t.Errorf("Execute(--output yaml) error = %v, want invalid --output", err)
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/proxy"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// egressSocketName is the proxy socket's file name inside its private directory.
+const egressSocketName = "egress.sock"
+
+// forwardSocketName is a loopback forward's socket file name inside the same
+// directory, by forward index.
+const forwardSocketName = "forward-%d.sock"
+
+// checkRelayBinary verifies the executable bound in as the relay; tests, whose
+// binaries may link dynamically, replace it.
+var checkRelayBinary = netshared.CheckRelayBinary
+
+// prepareRelay validates the network inputs and lays out the relay transport
+// for a network none or proxy run: a private directory holding the proxy socket
+// (network=proxy) and one socket per host loopback endpoint, and this
+// executable as the in-sandbox relay. With listen set it also starts the
+// allowlist proxy and the forwarders on the sockets; dry runs only need the
+// paths. The returned stop function is idempotent and removes the directory.
+func prepareRelay(mode netshared.Mode, hosts, endpoints []string, listen, verbose bool) (netshared.RelayTransport, func(), error) {
+	noop := func() {}
+	if mode == netshared.ModeHost {
+		return netshared.RelayTransport{}, noop, nil
+	}
+	var allow *egress.Allowlist
+	if mode == netshared.ModeProxy {
+		var err error
+		if allow, err = egress.NewAllowlist(hosts); err != nil {
+			return netshared.RelayTransport{}, nil, err
+		}
+		if allow.Len() == 0 {
+			return netshared.RelayTransport{}, nil, fmt.Errorf("%w; pass --network-proxy-allow or set networkProxyAllow", netshared.ErrProxyAllowlistEmpty)
+		}
+	}
+	addrs, err := loopbackEndpoints(endpoints)
+	if err != nil {
+		return netshared.RelayTransport{}, nil, err
+	}
+	if allow == nil && len(addrs) == 0 {
+		return netshared.RelayTransport{}, noop, nil
+	}
+
+	relay, err := os.Executable()
+	if err != nil {
+		return netshared.RelayTransport{}, nil, fmt.Errorf("locate agent-cli executable for the egress relay: %w", err)
+	}
+	if relay, err = filepath.EvalSymlinks(relay); err != nil {
+		return netshared.RelayTransport{}, nil, fmt.Errorf("resolve agent-cli executable for the egress relay: %w", err)
+	}
+	if err := checkRelayBinary(relay); err != nil {
+		return netshared.RelayTransport{}, nil, err
+	}
+	dir, err := os.MkdirTemp("", "agent-cli-egress-")
+	if err != nil {
+		return netshared.RelayTransport{}, nil, fmt.Errorf("create egress relay directory: %w", err)
+	}
+	transport := netshared.RelayTransport{RelayBinary: relay}
+	if allow != nil {
+		transport.ProxySocket = filepath.Join(dir, egressSocketName)
+	}
+	for index, addr := range addrs {
+		transport.Forwards = append(transport.Forwards, netshared.LoopbackForward{
+			Addr:   addr,
+			Socket: filepath.Join(dir, fmt.Sprintf(forwardSocketName, index)),
+		})
+	}
+	if !listen {
+		return transport, sync.OnceFunc(func() { _ = os.RemoveAll(dir) }), nil
+	}
+
+	var closers []io.Closer
+	stop := sync.OnceFunc(func() {
+		for _, closer := range closers {
+			_ = closer.Close()
+		}
+		_ = os.RemoveAll(dir)
+	})
+	if allow != nil {
+		server := egress.NewServer(allow)
+		if verbose {
+			server.WithLogger(func(message string) { logging.LogDebug(message) })
+		}
+		if err := server.ListenUnix(transport.ProxySocket); err != nil {
+			stop()
+			return netshared.RelayTransport{}, nil, err
+		}
+		closers = append(closers, server)
+	}
+	for _, forward := range transport.Forwards {
+		forwarder, err := proxy.ListenForward(forward.Socket, forward.Addr)
+		if err != nil {
+			stop()
+			return netshared.RelayTransport{}, nil, err
+		}
+		closers = append(closers, forwarder)
+		if verbose {
+			logging.LogDebug("forwarding host loopback " + forward.Addr + " into the sandbox")
+		}
+	}
+	return transport, stop, nil
+}
+
+// loopbackEndpoints validates endpoints and returns them normalized, in order,
+// without duplicates.
+func loopbackEndpoints(endpoints []string) ([]string, error) {
+	var addrs []string
+	seen := map[string]bool{}
+	for _, endpoint := range endpoints {
+		addr, err := netshared.ParseLoopbackEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// newEgressRelayCommand is the in-sandbox half of network none and proxy. The
+// isolator binds this binary into the sandbox and runs the agent under it; for
+// the agent's lifetime the relay publishes each bound socket on its sandbox
+// loopback address: the egress proxy's, and one per loopback forward. It is
+// hidden because only the isolators invoke it.
+func newEgressRelayCommand() *cobra.Command {
+	var forwards []string
+	cmd := &cobra.Command{
+		Use:    netshared.RelayCommandName + " --forward addr=socket... -- command [args...]",
+		Short:  "Relay sandbox loopback addresses to bound unix sockets while running a command",
+		Hidden: true,
+		Args:   cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exitCode, err := runEgressRelay(forwards, args)
+			if err != nil {
+				return err
+			}
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVar(&forwards, "forward", nil, "Loopback address and unix socket to relay it to, as addr=socket (repeatable)")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newEgressRelayCommand())
+}
+
+// runEgressRelay starts a relay per addr=socket route, runs command as its
+// child with forwarded termination signals, and returns the child's exit code.
+func runEgressRelay(routes, command []string) (int, error) {
+	if len(routes) == 0 {
+		return 1, fmt.Errorf("egress relay: no --forward routes")
+	}
+	for _, route := range routes {
+		addr, socket, ok := strings.Cut(route, "=")
+		if !ok || addr == "" || socket == "" {
+			return 1, fmt.Errorf("egress relay: invalid route %q, want addr=socket", route)
+		}
+		relay, err := proxy.ListenRelay(addr, socket)
+		if err != nil {
+			return 1, err
+		}
+		defer relay.Close()
+	}
+
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	if err := child.Start(); err != nil {
+		return 1, fmt.Errorf("egress relay: start %s: %w", command[0], err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+	go func() {
+		for sig := range signals {
+			_ = child.Process.Signal(sig)
+		}
+	}()
+
+	if err := child.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, fmt.Errorf("egress relay: wait for %s: %w", command[0], err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+)
+
+func TestPrepareRelay_RequiresAllowlist(t *testing.T) {
+	if _, _, err := prepareRelay(netshared.ModeProxy, nil, nil, false, false); !errors.Is(err, netshared.ErrProxyAllowlistEmpty) {
+		t.Errorf("prepareRelay(proxy, nil) error = %v, want %v", err, netshared.ErrProxyAllowlistEmpty)
+	}
+	if _, _, err := prepareRelay(netshared.ModeProxy, []string{"https://api.anthropic.com"}, nil, false, false); err == nil {
+		t.Error("prepareRelay(proxy, URL) error = nil, want hostname validation error")
+	}
+	if _, _, err := prepareRelay(netshared.ModeNone, nil, []string{"localhost:8317"}, false, false); err == nil {
+		t.Error("prepareRelay(none, localhost) error = nil, want loopback endpoint error")
+	}
+}
+
+func TestPrepareRelay_NothingToRelay(t *testing.T) {
+	for _, mode := range []netshared.Mode{netshared.ModeHost, netshared.ModeNone} {
+		transport, stop, err := prepareRelay(mode, nil, nil, true, false)
+		if err != nil {
+			t.Fatalf("prepareRelay(%s) error = %v", mode, err)
+		}
+		stop()
+		if transport.RelayBinary != "" || transport.ProxySocket != "" || len(transport.Forwards) != 0 {
+			t.Errorf("prepareRelay(%s) = %+v, want an empty transport", mode, transport)
+		}
+	}
+}
+
+// acceptRelayBinary lets the test binary, which may link dynamically, stand in
+// for the relay.
+func acceptRelayBinary(t *testing.T) {
+	previous := checkRelayBinary
+	checkRelayBinary = func(string) error { return nil }
+	t.Cleanup(func() { checkRelayBinary = previous })
+}
+
+func TestPrepareRelay_ListensAndCleansUp(t *testing.T) {
+	acceptRelayBinary(t)
+	transport, stop, err := prepareRelay(netshared.ModeProxy, []string{"api.anthropic.com"}, []string{"8317", "127.0.0.1:8317"}, true, false)
+	if err != nil {
+		t.Fatalf("prepareRelay() error = %v", err)
+	}
+	if len(transport.Forwards) != 1 || transport.Forwards[0].Addr != "127.0.0.1:8317" {
+		t.Fatalf("Forwards = %+v, want one deduplicated 127.0.0.1:8317 forward", transport.Forwards)
+	}
+	for _, socket := range []string{transport.ProxySocket, transport.Forwards[0].Socket} {
+		info, err := os.Stat(socket)
+		if err != nil || info.Mode()&os.ModeSocket == 0 {
+			t.Fatalf("relay socket %q = (%v, %v), want a listening socket", socket, info, err)
+		}
+	}
+	if transport.RelayBinary == "" || !filepath.IsAbs(transport.RelayBinary) {
+		t.Errorf("RelayBinary = %q, want the absolute agent-cli executable", transport.RelayBinary)
+	}
+
+	stop()
+	stop()
+
+	if _, err := os.Stat(filepath.Dir(transport.ProxySocket)); !os.IsNotExist(err) {
+		t.Errorf("relay directory still exists after stop: %v", err)
+	}
+}
+
+func TestPrepareRelay_DryRunDoesNotListen(t *testing.T) {
+	acceptRelayBinary(t)
+	transport, stop, err := prepareRelay(netshared.ModeNone, nil, []string{"8317"}, false, false)
+	if err != nil {
+		t.Fatalf("prepareRelay() error = %v", err)
+	}
+	defer stop()
+	if transport.ProxySocket != "" {
+		t.Errorf("network none ProxySocket = %q, want none", transport.ProxySocket)
+	}
+	if _, err := os.Stat(transport.Forwards[0].Socket); !os.IsNotExist(err) {
+		t.Errorf("dry run created forward socket: %v", err)
+	}
+}
+
+func TestPrepareRelay_RefusesADynamicRelay(t *testing.T) {
+	previous := checkRelayBinary
+	checkRelayBinary = func(path string) error { return fmt.Errorf("%w: %s", netshared.ErrRelayNotStatic, path) }
+	t.Cleanup(func() { checkRelayBinary = previous })
+
+	if _, _, err := prepareRelay(netshared.ModeNone, nil, []string{"8317"}, false, false); !errors.Is(err, netshared.ErrRelayNotStatic) {
+		t.Errorf("prepareRelay() error = %v, want %v", err, netshared.ErrRelayNotStatic)
+	}
+}
+
+func TestRunEgressRelay_ReturnsChildExitCode(t *testing.T) {
+	route := "127.0.0.1:0=" + filepath.Join(t.TempDir(), "egress.sock")
+	code, err := runEgressRelay([]string{route}, []string{"sh", "-c", "exit 3"})
+	if err != nil || code != 3 {
+		t.Fatalf("runEgressRelay() = (%d, %v), want (3, nil)", code, err)
+	}
+	if _, err := runEgressRelay([]string{route}, []string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("runEgressRelay(missing) error = nil, want start error")
+	}
+	for _, routes := range [][]string{nil, {"127.0.0.1:0"}} {
+		if _, err := runEgressRelay(routes, []string{"true"}); err == nil {
+			t.Errorf("runEgressRelay(%v) error = nil, want route error", routes)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// readPrompt returns the headless prompt of a run: --prompt itself, or the
+// content of --prompt-file, where - reads stdin. No prompt is an interactive
+// run.
+func readPrompt(prompt, promptFile string, stdin io.Reader) (string, error) {
+	if promptFile == "" {
+		return prompt, nil
+	}
+	if prompt != "" {
+		return "", fmt.Errorf("--prompt and --prompt-file cannot be combined")
+	}
+	var data []byte
+	var err error
+	if promptFile == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(promptFile)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read prompt: %w", err)
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", fmt.Errorf("prompt file %s is empty", promptFile)
+	}
+	return text, nil
+}
+
+// parseTimeout reads a --timeout duration; empty is no timeout.
+func parseTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid --timeout %q (want a positive duration such as 30m)", value)
+	}
+	return timeout, nil
+}
+
+// captureOutput copies everything the run writes to stdout into the file at
+// path as well. The sandbox inherits os.Stdout, so it is swapped for a pipe
+// until the returned stop is called; stop waits for the copy to drain.
+func captureOutput(path string) (stop func() error, err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open output file: %w", err)
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("capture output: %w", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.MultiWriter(stdout, file), reader)
+		copied <- err
+	}()
+	return func() error {
+		os.Stdout = stdout
+		_ = writer.Close()
+		err := <-copied
+		_ = reader.Close()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("write output file %s: %w", path, err)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadPromptTakesTheFlagAFileOrStdin(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "task.md")
+	if err := os.WriteFile(file, []byte("Fix the build\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	stdin := strings.NewReader("  Review the patch\n")
+
+	if got, err := readPrompt("Inline", "", stdin); err != nil || got != "Inline" {
+		t.Errorf("readPrompt(--prompt) = (%q, %v), want Inline", got, err)
+	}
+	if got, err := readPrompt("", file, stdin); err != nil || got != "Fix the build" {
+		t.Errorf("readPrompt(--prompt-file) = (%q, %v), want the file content", got, err)
+	}
+	if got, err := readPrompt("", "-", stdin); err != nil || got != "Review the patch" {
+		t.Errorf("readPrompt(-) = (%q, %v), want stdin", got, err)
+	}
+	if _, err := readPrompt("Inline", file, stdin); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("readPrompt(both) error = %v, want a conflict", err)
+	}
+	if _, err := readPrompt("", "-", strings.NewReader("\n")); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("readPrompt(empty stdin) error = %v, want an empty prompt", err)
+	}
+	if _, err := readPrompt("", filepath.Join(t.TempDir(), "missing"), stdin); err == nil {
+		t.Error("readPrompt(missing file) error = nil, want a read failure")
+	}
+}
+
+func TestRunAgentRunsThePromptHeadlessAndCapturesItsOutput(t *testing.T) {
+	workDir := t.TempDir()
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte("#!/bin/sh\necho \"$@\"\n"), 0o755); err != nil {
+		t.Fatalf("create agent executable: %v", err)
+	}
+	t.Setenv("PATH", binDir+":/bin:/usr/bin")
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	output := filepath.Join(t.TempDir(), "out.txt")
+	options := defaultRunOptions()
+	options.workDir = workDir
+	options.config = filepath.Join(workDir, "none.yaml")
+	options.prompt = "Fix the build"
+	options.outputFile = output
+	if err := os.WriteFile(options.config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	stdout := captureStdout(t, func() error { return runAgent(newRunCommand(), nil, options) })
+
+	captured, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("ReadFile(output) error = %v", err)
+	}
+	if want := "--print Fix the build\n"; string(captured) != want || string(stdout) != want {
+		t.Errorf("output file = %q, stdout = %q, want both %q", captured, stdout, want)
+	}
+	if info, err := os.Stat(output); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("output file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestRunAgentRejectsAnOutputFileWithATerminalWrapper(t *testing.T) {
+	workDir := t.TempDir()
+	options := defaultRunOptions()
+	options.workDir = workDir
+	options.config = filepath.Join(workDir, "none.yaml")
+	options.terminal = "tmux"
+	options.outputFile = filepath.Join(workDir, "out.txt")
+	if err := os.WriteFile(options.config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := runAgent(newRunCommand(), nil, options); err == nil || !strings.Contains(err.Error(), "output file") {
+		t.Errorf("runAgent() error = %v, want the output file refused", err)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	if got, err := parseTimeout(""); err != nil || got != 0 {
+		t.Errorf("parseTimeout(\"\") = (%v, %v), want no timeout", got, err)
+	}
+	if got, err := parseTimeout("1h30m"); err != nil || got != 90*time.Minute {
+		t.Errorf("parseTimeout(1h30m) = (%v, %v), want 90m", got, err)
+	}
+	for _, value := range []string{"soon", "0s", "-5m"} {
+		if _, err := parseTimeout(value); err == nil || !strings.Contains(err.Error(), "--timeout") {
+			t.Errorf("parseTimeout(%q) error = %v, want an invalid --timeout", value, err)
+		}
+	}
+}
+
+func TestRunAgentRejectsATimeoutWithATerminalWrapper(t *testing.T) {
+	workDir := t.TempDir()
+	options := defaultRunOptions()
+	options.workDir = workDir
+	options.config = filepath.Join(workDir, "none.yaml")
+	options.terminal = "tmux"
+	options.timeout = "30m"
+	if err := os.WriteFile(options.config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := runAgent(newRunCommand(), nil, options); err == nil || !strings.Contains(err.Error(), "a timeout") {
+		t.Errorf("runAgent() error = %v, want the timeout refused", err)
+	}
+}
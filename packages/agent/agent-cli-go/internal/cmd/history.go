@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ErrRunNotFound reports a history ID that matches no run.
+var ErrRunNotFound = errors.New("run not found")
+
+// historyFilter selects audit records; a zero field matches every record.
+type historyFilter struct {
+	agent     string
+	provider  string
+	isolation string
+	provision string
+	since     time.Time
+	failed    bool
+}
+
+func (f historyFilter) matches(r auditRecord) bool {
+	switch {
+	case f.agent != "" && r.Agent != f.agent,
+		f.provider != "" && r.Provider != f.provider,
+		f.isolation != "" && r.Isolation != f.isolation,
+		f.provision != "" && r.Provision != f.provision,
+		!f.since.IsZero() && r.StartedAt.Before(f.since),
+		f.failed && r.Error == "" && r.ExitCode != nil && *r.ExitCode == 0:
+		return false
+	}
+	return true
+}
+
+func newHistoryCommand() *cobra.Command {
+	var (
+		filter   historyFilter
+		since    string
+		limit    int
+		output   = outputText
+		auditLog string
+	)
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List the recorded runs",
+		Long: `List the runs recorded in the audit log, newest first. Every run
+appends a record to $XDG_STATE_HOME/agent-cli/runs.jsonl (default
+~/.local/state/agent-cli/runs.jsonl); --audit-log reads another log, such as
+one a CI job collected with run --audit-log.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if output != outputText && output != outputJSON {
+				return fmt.Errorf("invalid --output %q (want %s or %s)", output, outputText, outputJSON)
+			}
+			if since != "" {
+				var err error
+				if filter.since, err = parseSince(since, time.Now()); err != nil {
+					return err
+				}
+			}
+			records, err := loadHistory(auditLog)
+			if err != nil {
+				return err
+			}
+			var selected []auditRecord
+			for _, r := range slices.Backward(records) {
+				if limit > 0 && len(selected) == limit {
+					break
+				}
+				if filter.matches(r) {
+					selected = append(selected, r)
+				}
+			}
+			return printHistory(cmd.OutOrStdout(), selected, output)
+		},
+	}
+	cmd.Flags().StringVar(&filter.agent, "agent", "", "Only runs of this agent")
+	cmd.Flags().StringVar(&filter.provider, "provider", "", "Only runs with this provider preset")
+	cmd.Flags().StringVar(&filter.isolation, "isolation", "", "Only runs with this isolation method")
+	cmd.Flags().StringVar(&filter.provision, "provision", "", "Only runs with this provision method")
+	cmd.Flags().StringVar(&since, "since", "", "Only runs started after a duration ago (24h) or a date (2006-01-02, RFC 3339)")
+	cmd.Flags().BoolVar(&filter.failed, "failed", false, "Only runs that failed or exited non-zero")
+	cmd.Flags().IntVarP(&limit, "limit", "l", 0, "Show at most this many runs")
+	cmd.Flags().StringVarP(&output, "output", "o", output, "Listing format (text, json)")
+	cmd.Flags().StringVar(&auditLog, "audit-log", "", "Read this audit log instead of the run history")
+	cmd.AddCommand(newHistoryShowCommand())
+	return cmd
+}
+
+func newHistoryShowCommand() *cobra.Command {
+	var auditLog string
+	cmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Print the full record of a run",
+		Long:  `Print the full audit record of a run as JSON. A unique prefix of the ID is enough.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			records, err := loadHistory(auditLog)
+			if err != nil {
+				return err
+			}
+			record, err := findRun(records, args[0])
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(record)
+		},
+	}
+	cmd.Flags().StringVar(&auditLog, "audit-log", "", "Read this audit log instead of the run history")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newHistoryCommand())
+}
+
+// loadHistory reads the audit log at path, or the run history.
+func loadHistory(path string) ([]auditRecord, error) {
+	if path == "" {
+		var err error
+		if path, err = defaultAuditLogPath(); err != nil {
+			return nil, err
+		}
+	}
+	return readAudit(path)
+}
+
+// parseSince reads a --since value: a duration before now, a date or an
+// RFC 3339 time.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want a duration such as 24h, a date or an RFC 3339 time)", value)
+}
+
+// findRun returns the record whose ID is id or, uniquely, starts with it.
+func findRun(records []auditRecord, id string) (auditRecord, error) {
+	var found []auditRecord
+	for _, r := range records {
+		if r.ID == id {
+			return r, nil
+		}
+		if strings.HasPrefix(r.ID, id) {
+			found = append(found, r)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return auditRecord{}, fmt.Errorf("%w: %s", ErrRunNotFound, id)
+	case 1:
+		return found[0], nil
+	default:
+		return auditRecord{}, fmt.Errorf("run ID %q is ambiguous: it matches %d runs", id, len(found))
+	}
+}
+
+// printHistory writes records as a table or as JSON.
+func printHistory(w io.Writer, records []auditRecord, output string) error {
+	if output == outputJSON {
+		if records == nil {
+			records = []auditRecord{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tAGENT\tPROVIDER\tSANDBOX\tEXIT\tDURATION\tWORKDIR")
+	for _, r := range records {
+		provider := r.Provider
+		if provider == "" {
+			provider = "-"
+		}
+		exit := "error"
+		switch {
+		case r.Status == runTimedOut:
+			exit = "timeout"
+		case r.ExitCode != nil:
+			exit = fmt.Sprint(*r.ExitCode)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s/%s/%s\t%s\t%s\t%s\n", r.ID, r.StartedAt.Local().Format(time.DateTime),
+			r.Agent, provider, r.Isolation, r.Provision, r.Network, exit, r.EndedAt.Sub(r.StartedAt).Round(time.Second), r.WorkDir)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeHistory(t *testing.T, records ...auditRecord) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+	for _, r := range records {
+		if err := appendAudit(path, r); err != nil {
+			t.Fatalf("appendAudit() error = %v", err)
+		}
+	}
+	return path
+}
+
+func exitCode(code int) *int { return &code }
+
+func TestHistoryListsTheMatchingRunsNewestFirst(t *testing.T) {
+	started := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	path := writeHistory(t,
+		auditRecord{ID: "aaa111", Agent: "claude", Isolation: "bwrap", StartedAt: started, ExitCode: exitCode(0)},
+		auditRecord{ID: "bbb222", Agent: "opencode", Isolation: "bwrap", StartedAt: started.Add(time.Hour), ExitCode: exitCode(1)},
+		auditRecord{ID: "ccc333", Agent: "claude", Isolation: "podman", StartedAt: started.Add(2 * time.Hour), Error: "pull failed"},
+	)
+	run := func(args ...string) string {
+		t.Helper()
+		command := newHistoryCommand()
+		var out bytes.Buffer
+		command.SetOut(&out)
+		command.SetErr(&bytes.Buffer{})
+		command.SetArgs(append([]string{"--audit-log", path}, args...))
+		if err := command.Execute(); err != nil {
+			t.Fatalf("Execute(%v) error = %v", args, err)
+		}
+		return out.String()
+	}
+
+	var all []auditRecord
+	if err := json.Unmarshal([]byte(run("-o", "json")), &all); err != nil || len(all) != 3 || all[0].ID != "ccc333" {
+		t.Errorf("history = %+v (%v), want three runs, newest first", all, err)
+	}
+	if got := run("--failed"); strings.Contains(got, "aaa111") || !strings.Contains(got, "bbb222") || !strings.Contains(got, "error") {
+		t.Errorf("history --failed = %q, want the failed runs only", got)
+	}
+	if got := run("--agent", "claude", "--limit", "1"); !strings.Contains(got, "ccc333") || strings.Contains(got, "aaa111") {
+		t.Errorf("history --agent claude --limit 1 = %q, want the newest claude run", got)
+	}
+	if got := run("--since", "2026-10-01T12:30:00Z", "--isolation", "bwrap"); strings.Count(got, "\n") != 2 || !strings.Contains(got, "bbb222") {
+		t.Errorf("history --since --isolation = %q, want bbb222 alone", got)
+	}
+}
+
+func TestHistoryShowFindsARunByPrefix(t *testing.T) {
+	path := writeHistory(t, auditRecord{ID: "abc123", Agent: "claude"}, auditRecord{ID: "abd456", Agent: "opencode"})
+	command := newHistoryCommand()
+	var out bytes.Buffer
+	command.SetOut(&out)
+	command.SetErr(&bytes.Buffer{})
+	command.SetArgs([]string{"show", "abd", "--audit-log", path})
+
+	if err := command.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var record auditRecord
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil || record.Agent != "opencode" {
+		t.Errorf("history show = %s (%v), want the opencode run", out.String(), err)
+	}
+
+	records, _ := readAudit(path)
+	if _, err := findRun(records, "ab"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("findRun(ab) error = %v, want ambiguous", err)
+	}
+	if _, err := findRun(records, "zzz"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("findRun(zzz) error = %v, want %v", err, ErrRunNotFound)
+	}
+}
+
+func TestParseSinceTakesADurationOrADate(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	if got, err := parseSince("24h", now); err != nil || !got.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("parseSince(24h) = (%v, %v), want a day ago", got, err)
+	}
+	if got, err := parseSince("2026-10-01", now); err != nil || !got.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseSince(date) = (%v, %v), want the date", got, err)
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Error("parseSince(yesterday) error = nil, want invalid --since")
+	}
+}
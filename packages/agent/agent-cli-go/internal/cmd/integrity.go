@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/integrity"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// The --git-integrity values of run.
+const (
+	integrityRevert = "revert"
+	integrityBlock  = "block"
+	integrityOff    = "off"
+)
+
+// ErrGitTampered reports a run that changed the repository's hooks or git
+// config under --git-integrity block.
+var ErrGitTampered = errors.New("the run changed the repository's hooks or git config")
+
+// validateGitIntegrity checks a --git-integrity value; empty is the default.
+func validateGitIntegrity(mode string) error {
+	if !slices.Contains([]string{"", integrityRevert, integrityBlock, integrityOff}, mode) {
+		return fmt.Errorf("invalid --git-integrity %q (want %s, %s or %s)", mode, integrityRevert, integrityBlock, integrityOff)
+	}
+	return nil
+}
+
+// gitIntegrityMode resolves the default --git-integrity: revert, except for
+// an unisolated run, which reaches the host's git state anyway.
+func gitIntegrityMode(mode string, isolationName isolation.IsolationMethod) string {
+	if mode != "" {
+		return mode
+	}
+	if isolationName == isolation.IsolationNone {
+		return integrityOff
+	}
+	return integrityRevert
+}
+
+// checkTerminalIntegrity refuses a git integrity check under a terminal
+// wrapper: the repository is checked once the sandbox exits, which a wrapper
+// returns before. The default check is refused too rather than dropped, so a
+// config that picks a wrapper cannot turn it off.
+func checkTerminalIntegrity(mode, terminal string) error {
+	if terminal != "" && mode != integrityOff {
+		return fmt.Errorf("a git integrity check cannot be combined with a terminal wrapper; the agent-cli process checks the repository once the run ends (pass --git-integrity off to run without it)")
+	}
+	return nil
+}
+
+// guardRepository snapshots the git state of workDir's repository that a
+// host-side git command would run code from; it guards nothing when off.
+func guardRepository(mode, workDir string) (*integrity.Guard, error) {
+	if mode == integrityOff {
+		return nil, nil
+	}
+	guard, err := integrity.Snapshot(workDir)
+	if err != nil {
+		return nil, fmt.Errorf("git integrity check: %w", err)
+	}
+	return guard, nil
+}
+
+// checkRepository undoes what the run changed in the guarded git state and
+// reports it. Under block the changes fail the run; a change that could not
+// be undone fails it in either mode.
+func checkRepository(guard *integrity.Guard, mode string) ([]string, error) {
+	if guard == nil {
+		return nil, nil
+	}
+	changes, err := guard.Restore()
+	var reverted []string
+	for _, c := range changes {
+		reverted = append(reverted, c.String())
+	}
+	if len(reverted) > 0 {
+		logging.LogWarning("The run changed the repository's hooks or git config; reverted:\n  " + strings.Join(reverted, "\n  "))
+	}
+	if err != nil {
+		return reverted, fmt.Errorf("git integrity check: %w", err)
+	}
+	if mode == integrityBlock && len(reverted) > 0 {
+		return reverted, fmt.Errorf("%w: %s", ErrGitTampered, strings.Join(reverted, "; "))
+	}
+	return reverted, nil
+}
+
+// guardProtected snapshots the workspace paths of --protect, so what a run
+// changed in them past the read-only binds can be put back; it guards nothing
+// when there are none.
+func guardProtected(patterns []string, workDir string) (*integrity.Guard, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	guard, err := integrity.SnapshotProtected(workDir, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("protected paths check: %w", err)
+	}
+	return guard, nil
+}
+
+// checkProtected undoes what the run changed in the protected paths and
+// reports each path it deleted or restored; a change that could not be undone
+// fails the run.
+func checkProtected(guard *integrity.Guard) ([]string, error) {
+	if guard == nil {
+		return nil, nil
+	}
+	changes, err := guard.Restore()
+	var reverted []string
+	for _, c := range changes {
+		reverted = append(reverted, c.String())
+		if c.Removed {
+			logging.LogWarning(fmt.Sprintf("Deleted protected path %s (%s)", c.Path, c.Detail))
+		} else {
+			logging.LogWarning(fmt.Sprintf("Restored protected path %s (%s)", c.Path, c.Detail))
+		}
+	}
+	if err != nil {
+		return reverted, fmt.Errorf("protected paths check: %w", err)
+	}
+	return reverted, nil
+}
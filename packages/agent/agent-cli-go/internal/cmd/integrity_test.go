@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+)
+
+func TestGitIntegrityModeDefaultsToRevertInASandbox(t *testing.T) {
+	if got := gitIntegrityMode("", isolation.IsolationBwrap); got != integrityRevert {
+		t.Errorf("gitIntegrityMode(bwrap) = %q, want %q", got, integrityRevert)
+	}
+	if got := gitIntegrityMode("", isolation.IsolationNone); got != integrityOff {
+		t.Errorf("gitIntegrityMode(none) = %q, want %q", got, integrityOff)
+	}
+	if got := gitIntegrityMode(integrityBlock, isolation.IsolationNone); got != integrityBlock {
+		t.Errorf("gitIntegrityMode(block, none) = %q, want %q", got, integrityBlock)
+	}
+	if err := validateGitIntegrity("sometimes"); err == nil || !strings.Contains(err.Error(), "--git-integrity") {
+		t.Errorf("validateGitIntegrity(sometimes) error = %v, want an invalid value", err)
+	}
+}
+
+func TestCheckRepositoryRevertsAndBlocksOnlyWhenAsked(t *testing.T) {
+	for _, mode := range []string{integrityRevert, integrityBlock} {
+		t.Run(mode, func(t *testing.T) {
+			repo := t.TempDir()
+			hook := filepath.Join(repo, ".git", "hooks", "pre-commit")
+			if err := os.MkdirAll(filepath.Dir(hook), 0o755); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+			guard, err := guardRepository(mode, repo)
+			if err != nil {
+				t.Fatalf("guardRepository() error = %v", err)
+			}
+			if err := os.WriteFile(hook, []byte("#!/bin/sh\n"), 0o755); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			reverted, err := checkRepository(guard, mode)
+			if len(reverted) != 1 || reverted[0] != ".git/hooks/pre-commit: added" {
+				t.Errorf("checkRepository() reverted %q, want the planted hook", reverted)
+			}
+			if blocked := errors.Is(err, ErrGitTampered); blocked != (mode == integrityBlock) {
+				t.Errorf("checkRepository() error = %v, want blocked = %v", err, mode == integrityBlock)
+			}
+			if _, err := os.Stat(hook); !os.IsNotExist(err) {
+				t.Errorf("hook stat error = %v, want it removed", err)
+			}
+		})
+	}
+	if guard, err := guardRepository(integrityOff, t.TempDir()); guard != nil || err != nil {
+		t.Errorf("guardRepository(off) = (%v, %v), want no guard", guard, err)
+	}
+}
+
+func TestRunAgentBlocksARunThatPlantsAHook(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, ".git", "hooks"), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nprintf '#!/bin/sh\\n' > .git/hooks/post-checkout\n"
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0o755); err != nil {
+		t.Fatalf("create agent executable: %v", err)
+	}
+	t.Setenv("PATH", binDir+":/bin:/usr/bin")
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	options := defaultRunOptions()
+	options.workDir = workDir
+	options.config = filepath.Join(t.TempDir(), "none.yaml")
+	options.prompt = "Fix the build"
+	options.gitIntegrity = integrityBlock
+	if err := os.WriteFile(options.config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	captureStdout(t, func() error {
+		if err := runAgent(newRunCommand(), nil, options); !errors.Is(err, ErrGitTampered) {
+			t.Errorf("runAgent() error = %v, want %v", err, ErrGitTampered)
+		}
+		return nil
+	})
+	if _, err := os.Stat(filepath.Join(workDir, ".git", "hooks", "post-checkout")); !os.IsNotExist(err) {
+		t.Errorf("planted hook stat error = %v, want it removed", err)
+	}
+	records, err := loadHistory("")
+	if err != nil || len(records) != 1 || len(records[0].GitIntegrity) != 1 || records[0].Status != runFailed {
+		t.Errorf("history = %+v (%v), want the failed run with the reverted hook", records, err)
+	}
+}
+
+func TestRunAgentRejectsAGitIntegrityCheckWithATerminalWrapper(t *testing.T) {
+	workDir := t.TempDir()
+	options := defaultRunOptions()
+	options.workDir = workDir
+	options.config = filepath.Join(workDir, "none.yaml")
+	options.terminal = "tmux"
+	options.gitIntegrity = integrityRevert
+	if err := os.WriteFile(options.config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := runAgent(newRunCommand(), nil, options); err == nil || !strings.Contains(err.Error(), "git integrity") {
+		t.Errorf("runAgent() error = %v, want the check refused", err)
+	}
+}
+
+// A wrapper never drops the check, not even the default one, so a config
+// that picks a wrapper cannot turn it off.
+func TestCheckTerminalIntegrityRefusesTheDefaultCheck(t *testing.T) {
+	mode := gitIntegrityMode("", isolation.IsolationBwrap)
+	if err := checkTerminalIntegrity(mode, "tmux"); err == nil || !strings.Contains(err.Error(), "--git-integrity off") {
+		t.Errorf("checkTerminalIntegrity(default, tmux) error = %v, want the check refused", err)
+	}
+	if err := checkTerminalIntegrity(integrityOff, "tmux"); err != nil {
+		t.Errorf("checkTerminalIntegrity(off, tmux) error = %v, want nil", err)
+	}
+	if err := checkTerminalIntegrity(mode, ""); err != nil {
+		t.Errorf("checkTerminalIntegrity(default, no wrapper) error = %v, want nil", err)
+	}
+}
+
+func TestCheckProtectedRestoresATreeRenamedAway(t *testing.T) {
+	workDir := t.TempDir()
+	workflow := filepath.Join(workDir, ".github", "workflows", "ci.yml")
+	if err := os.MkdirAll(filepath.Dir(workflow), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(workflow, []byte("on: push\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	guard, err := guardProtected([]string{".github/workflows"}, workDir)
+	if err != nil {
+		t.Fatalf("guardProtected() error = %v", err)
+	}
+	defer func() { _ = guard.Close() }()
+	// A read-only bind of .github/workflows does not stop this.
+	bypass := exec.Command("sh", "-c", "mv .github .github.old && mkdir -p .github/workflows && echo evil > .github/workflows/ci.yml")
+	bypass.Dir = workDir
+	if output, err := bypass.CombinedOutput(); err != nil {
+		t.Fatalf("bypass error = %v: %s", err, output)
+	}
+
+	reverted, err := checkProtected(guard)
+	if err != nil || !slices.Equal(reverted, []string{".github/workflows/ci.yml: modified"}) {
+		t.Errorf("checkProtected() = (%q, %v), want the workflow reverted", reverted, err)
+	}
+	if data, err := os.ReadFile(workflow); err != nil || string(data) != "on: push\n" {
+		t.Errorf("ci.yml = %q (%v), want the original workflow", data, err)
+	}
+	if guard, err := guardProtected(nil, workDir); guard != nil || err != nil {
+		t.Errorf("guardProtected(nil) = (%v, %v), want no guard", guard, err)
+	}
+}
+
+func TestRunAgentRejectsProtectedPathsWithATerminalWrapper(t *testing.T) {
+	workDir := t.TempDir()
+	options := defaultRunOptions()
+	options.workDir = workDir
+	options.config = filepath.Join(workDir, "none.yaml")
+	options.terminal = "tmux"
+	options.protect = []string{"CODEOWNERS"}
+	if err := os.WriteFile(options.config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	if err := runAgent(newRunCommand(), nil, options); err == nil || !strings.Contains(err.Error(), "protected paths") {
+		t.Errorf("runAgent() error = %v, want the protected paths refused", err)
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/landlock"
+)
+
+// newLandlockExecCommand is the re-executed half of isolation=landlock: it
+// confines the agent it starts with the ruleset named by its flags. It is
+// hidden because only the landlock isolator invokes it.
+func newLandlockExecCommand() *cobra.Command {
+	var stage landlock.Stage
+	cmd := &cobra.Command{
+		Use:    landlock.ExecCommandName + " [--rw path] [--ro path] [--deny-tcp] [--env name] -- command [args...]",
+		Short:  "Run a command under a Landlock ruleset",
+		Hidden: true,
+		Args:   cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stage.Command = args
+			exitCode, err := landlock.Exec(stage)
+			if err != nil {
+				return err
+			}
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVar(&stage.ReadWrite, "rw", nil, "Path the command may read and write beneath (repeatable)")
+	cmd.Flags().StringArrayVar(&stage.ReadOnly, "ro", nil, "Path the command may read and execute beneath (repeatable)")
+	cmd.Flags().BoolVar(&stage.DenyTCP, "deny-tcp", false, "Deny every TCP bind and connect")
+	cmd.Flags().StringVar(&stage.Dir, "chdir", "", "Working directory of the command")
+	cmd.Flags().StringArrayVar(&stage.Env, "env", nil, "Variable passed on to the command from this environment (repeatable)")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newLandlockExecCommand())
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// resolveLimits parses the layered --limit-* settings. It reports whether any
+// limit was set explicitly, which makes an unenforceable limit an error rather
+// than a skipped default.
+func resolveLimits(options runOptions) (isoshared.ResourceLimits, bool, error) {
+	memory, pids, cpus := options.limitMemory, options.limitPids, options.limitCPUs
+	limits, err := isoshared.ParseResourceLimits(memory, pids, cpus)
+	if err != nil {
+		return isoshared.ResourceLimits{}, false, err
+	}
+	return limits.Resolved(), memory != "" || pids != 0 || cpus != 0, nil
+}
+
+// prepareResourceLimits places a host-process sandbox under limits and
+// describes the enforcement for dry-run output. Docker and podman apply the
+// limits themselves. A host-process sandbox gets a cgroup v2 child of this
+// process's cgroup or a transient systemd scope; the returned remove function
+// is idempotent. A terminal wrapper outlives this process, so its sandbox
+// cannot be placed. The defaults are skipped where they cannot be enforced;
+// explicit limits fail the run instead.
+func prepareResourceLimits(method isolation.IsolationMethod, limits isoshared.ResourceLimits, explicit, terminal, verbose bool) (isoshared.Cgroup, func(), string, error) {
+	noop := func() {}
+	if method == isolation.IsolationDocker || method == isolation.IsolationPodman {
+		return isoshared.Cgroup{}, noop, limits.String() + " (" + string(method) + ")", nil
+	}
+	if terminal {
+		if explicit {
+			return isoshared.Cgroup{}, nil, "", fmt.Errorf("resource limits cannot be combined with a terminal wrapper for isolation=%s; the cgroup is managed by the agent-cli process", method)
+		}
+		return isoshared.Cgroup{}, noop, limits.String() + " (not enforced under a terminal wrapper)", nil
+	}
+	cgroup, remove, err := isoshared.PrepareCgroup(limits)
+	if err != nil {
+		if explicit {
+			return isoshared.Cgroup{}, nil, "", err
+		}
+		if verbose {
+			logging.LogDebug("default resource limits not enforced: " + err.Error())
+		}
+		return isoshared.Cgroup{}, noop, limits.String() + " (not enforced: no cgroup available)", nil
+	}
+	if verbose {
+		logging.LogDebug("resource limits " + limits.String() + " enforced by " + cgroup.String())
+	}
+	return cgroup, remove, limits.String() + " (" + cgroup.String() + ")", nil
+}
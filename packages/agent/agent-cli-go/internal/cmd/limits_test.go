@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+)
+
+func TestResolveLimitsParsesTheLayeredSettings(t *testing.T) {
+	limits, explicit, err := resolveLimits(runOptions{limitMemory: "2g", limitPids: 64, limitCPUs: 1})
+
+	want := isoshared.ResourceLimits{MemoryBytes: 2 << 30, Pids: 64, CPUs: 1}
+	if err != nil || !explicit || limits != want {
+		t.Errorf("resolveLimits() = (%+v, %v, %v), want (%+v, true, nil)", limits, explicit, err, want)
+	}
+	if limits, explicit, _ := resolveLimits(runOptions{}); explicit || limits != (isoshared.ResourceLimits{}).Resolved() {
+		t.Errorf("resolveLimits(unset) = (%+v, %v), want the defaults, not explicit", limits, explicit)
+	}
+	if _, _, err := resolveLimits(runOptions{limitMemory: "lots"}); !errors.Is(err, isoshared.ErrInvalidResourceLimit) {
+		t.Errorf("resolveLimits(lots) error = %v, want %v", err, isoshared.ErrInvalidResourceLimit)
+	}
+}
+
+func TestPrepareResourceLimitsLeavesContainersToTheEngine(t *testing.T) {
+	limits := isoshared.ResourceLimits{}.Resolved()
+	cgroup, remove, description, err := prepareResourceLimits(isolation.IsolationPodman, limits, true, true, false)
+	if err != nil || cgroup.String() != "none" || description != limits.String()+" (podman)" {
+		t.Errorf("prepareResourceLimits(podman) = (%+v, %q, %v), want no placement", cgroup, description, err)
+	}
+	remove()
+}
+
+// A terminal wrapper outlives this process, so explicit limits are refused and
+// the defaults are reported as unenforced.
+func TestPrepareResourceLimitsUnderATerminalWrapper(t *testing.T) {
+	limits := isoshared.ResourceLimits{}.Resolved()
+	if _, _, _, err := prepareResourceLimits(isolation.IsolationBwrap, limits, true, true, false); err == nil || !strings.Contains(err.Error(), "terminal wrapper") {
+		t.Errorf("explicit limits under a terminal error = %v, want a terminal wrapper error", err)
+	}
+	_, _, description, err := prepareResourceLimits(isolation.IsolationNone, limits, false, true, false)
+	if err != nil || !strings.Contains(description, "not enforced") {
+		t.Errorf("default limits under a terminal = (%q, %v), want them reported as not enforced", description, err)
+	}
+}
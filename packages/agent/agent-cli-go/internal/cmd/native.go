@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/native"
+)
+
+// newNativeExecCommand is the host half of isolation=native: it creates the
+// namespaces and waits for the sandbox the plan in its arguments describes.
+// It is hidden because only the native isolator invokes it; the arguments are
+// positional like bubblewrap's, so cobra does not parse them.
+func newNativeExecCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                native.ExecCommandName + " [--bind src dst] [--ro-bind src dst] [--tmpfs dst] [--proc dst] [--dev dst] [--unshare-net] [--env name] -- command [args...]",
+		Short:              "Run a command in agent-cli's own namespace sandbox",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, err := native.ParseArgs(args)
+			if err != nil {
+				return err
+			}
+			exitCode, err := native.Exec(plan)
+			if err != nil {
+				return err
+			}
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+			return nil
+		},
+	}
+}
+
+// newNativeInitCommand is the half of isolation=native the exec stage
+// re-executes inside the new namespaces, as their PID 1.
+func newNativeInitCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                native.InitCommandName + " [plan] -- command [args...]",
+		Short:              "Build the native sandbox and run a command in it",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, err := native.ParseArgs(args)
+			if err != nil {
+				return err
+			}
+			os.Exit(native.Init(plan))
+			return nil
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newNativeExecCommand(), newNativeInitCommand())
+}
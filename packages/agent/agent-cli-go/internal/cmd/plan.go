@@ -0,0 +1,513 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+// planVersion is the layout of the plan document; replay refuses any other.
+const planVersion = 1
+
+// redacted stands in for every environment value in a plan.
+const redacted = "[redacted]"
+
+// Dry-run output formats (--output).
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	// ErrPlanMismatch reports a plan whose inputs no longer resolve to the
+	// reviewed sandbox on this host.
+	ErrPlanMismatch = errors.New("plan no longer matches")
+	// ErrInvalidPlan reports a plan file that cannot be replayed.
+	ErrInvalidPlan = errors.New("invalid plan")
+)
+
+// planReplayFlags are the run flags --plan may be combined with: the plan fixes
+// every other one. Environment values are redacted from the plan, so --env
+// supplies them again.
+var planReplayFlags = map[string]bool{"plan": true, "env": true, "dry-run": true, "output": true, "usage-report": true, "audit-log": true, "output-file": true}
+
+// runPlan is the reviewable description of one run: the inputs it was resolved
+// from and everything they resolved to. Per-run host paths (relay sockets,
+// compiled seccomp files) and the credential shield's address are replaced by
+// placeholders, so the same inputs yield the same plan on every resolution.
+type runPlan struct {
+	Version      int               `json:"version"`
+	Inputs       planInputs        `json:"inputs"`
+	Agent        string            `json:"agent"`
+	Provider     string            `json:"provider,omitempty"`
+	Isolation    planIsolation     `json:"isolation"`
+	Provision    planProvision     `json:"provision"`
+	Network      planNetwork       `json:"network"`
+	Policy       planGuarantees    `json:"policy"`
+	Capabilities planGuarantees    `json:"capabilities"`
+	WorkDir      string            `json:"workDir"`
+	RepoDir      string            `json:"repoDir,omitempty"`
+	Binds        []planBind        `json:"binds"`
+	Env          map[string]string `json:"env"`
+	Limits       planLimits        `json:"limits"`
+	AgentArgs    []string          `json:"agentArgs"`
+	Command      []string          `json:"command"`
+}
+
+type planIsolation struct {
+	Method         string `json:"method"`
+	Runtime        string `json:"runtime,omitempty"`
+	Image          string `json:"image,omitempty"`
+	Passthrough    bool   `json:"passthrough,omitempty"`
+	SeccompProfile string `json:"seccompProfile,omitempty"`
+}
+
+// planProvision is the provisioner's contribution; Closure is the read-only
+// store paths of a resolved closure.
+type planProvision struct {
+	Method       string   `json:"method"`
+	Closure      []string `json:"closure,omitempty"`
+	PathEntries  []string `json:"pathEntries,omitempty"`
+	InitCommands []string `json:"initCommands,omitempty"`
+}
+
+type planNetwork struct {
+	Mode       string   `json:"mode"`
+	ProxyAllow []string `json:"proxyAllow,omitempty"`
+	Forwards   []string `json:"forwards,omitempty"`
+}
+
+// planGuarantees lists the policy guarantees, either demanded (policy) or
+// provided by the resolved sandbox (capabilities).
+type planGuarantees struct {
+	PinnedProvision      bool `json:"pinnedProvision"`
+	HostToolsUnreachable bool `json:"hostToolsUnreachable"`
+	EgressRestricted     bool `json:"egressRestricted"`
+	KernelIsolated       bool `json:"kernelIsolated"`
+	SyscallFiltered      bool `json:"syscallFiltered"`
+}
+
+type planBind struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Mode   string `json:"mode"`
+}
+
+type planLimits struct {
+	MemoryBytes int64   `json:"memoryBytes"`
+	Pids        int64   `json:"pids"`
+	CPUs        float64 `json:"cpus,omitempty"`
+}
+
+// planInputs are the run flags a plan was resolved from, before the config
+// file is layered under them, with the work directory and config file made
+// absolute and custom environment values redacted. SetFlags names the flags
+// given on the command line. Replay layers the config file again (see
+// options), so a config change shows up as a plan change.
+type planInputs struct {
+	Agent                        string   `json:"agent"`
+	Provider                     string   `json:"provider,omitempty"`
+	Isolation                    string   `json:"isolation"`
+	Provision                    string   `json:"provision"`
+	Network                      string   `json:"network"`
+	NetworkProxyAllow            []string `json:"networkProxyAllow,omitempty"`
+	NetworkForward               []string `json:"networkForward,omitempty"`
+	ShieldCredentials            bool     `json:"shieldCredentials,omitempty"`
+	SeccompProfile               string   `json:"seccompProfile,omitempty"`
+	LimitMemory                  string   `json:"limitMemory,omitempty"`
+	LimitPids                    int64    `json:"limitPids,omitempty"`
+	LimitCPUs                    float64  `json:"limitCpus,omitempty"`
+	IsolationBwrapPassthrough    bool     `json:"isolationBwrapPassthrough,omitempty"`
+	IsolationLandlockPassthrough bool     `json:"isolationLandlockPassthrough,omitempty"`
+	IsolationNativePassthrough   bool     `json:"isolationNativePassthrough,omitempty"`
+	IsolationDockerRuntime       string   `json:"isolationDockerRuntime,omitempty"`
+	IsolationPodmanRuntime       string   `json:"isolationPodmanRuntime,omitempty"`
+	InitCommands                 []string `json:"initCommands,omitempty"`
+	NixSource                    string   `json:"nixSource,omitempty"`
+	NixRev                       string   `json:"nixRev,omitempty"`
+	NixPackages                  []string `json:"nixPackages,omitempty"`
+	NixShell                     string   `json:"nixShell,omitempty"`
+	WorkDir                      string   `json:"workDir"`
+	WorktreeBranch               string   `json:"worktreeBranch,omitempty"`
+	WorktreeSourceBranch         string   `json:"worktreeSourceBranch,omitempty"`
+	WorktreeDir                  string   `json:"worktreeDir,omitempty"`
+	WorktreeCleanup              string   `json:"worktreeCleanup,omitempty"`
+	GitIntegrity                 string   `json:"gitIntegrity,omitempty"`
+	Config                       string   `json:"config,omitempty"`
+	Profile                      string   `json:"profile,omitempty"`
+	SetFlags                     []string `json:"setFlags,omitempty"`
+	BindPaths                    []string `json:"bindPaths,omitempty"`
+	RoBindPaths                  []string `json:"roBindPaths,omitempty"`
+	Protect                      []string `json:"protect,omitempty"`
+	CustomEnv                    []string `json:"customEnv,omitempty"`
+	Image                        string   `json:"image,omitempty"`
+	Terminal                     string   `json:"terminal,omitempty"`
+	TerminalSession              string   `json:"terminalSession,omitempty"`
+	TerminalWindow               string   `json:"terminalWindow,omitempty"`
+	TerminalDetach               bool     `json:"terminalDetach,omitempty"`
+	VCS                          string   `json:"vcs"`
+	RequirePinnedProvision       bool     `json:"requirePinnedProvision,omitempty"`
+	RequireHostToolsUnreachable  bool     `json:"requireHostToolsUnreachable,omitempty"`
+	RequireEgressRestricted      bool     `json:"requireEgressRestricted,omitempty"`
+	RequireKernelIsolation       bool     `json:"requireKernelIsolation,omitempty"`
+	RequireSyscallFilter         bool     `json:"requireSyscallFilter,omitempty"`
+	AgentArgs                    []string `json:"agentArgs,omitempty"`
+	Prompt                       string   `json:"prompt,omitempty"`
+	PromptFile                   string   `json:"promptFile,omitempty"`
+	Timeout                      string   `json:"timeout,omitempty"`
+	Review                       bool     `json:"review,omitempty"`
+}
+
+// newPlanInputs records the flag options as plan inputs. workDir is the
+// resolved absolute work directory.
+func newPlanInputs(options runOptions, args []string, workDir string, setFlags []string) (planInputs, error) {
+	config := options.config
+	if config != "" {
+		var err error
+		if config, err = filepath.Abs(config); err != nil {
+			return planInputs{}, fmt.Errorf("resolve config file %q: %w", options.config, err)
+		}
+	}
+	return planInputs{
+		Agent:                        options.agent,
+		Provider:                     options.provider,
+		Isolation:                    options.isolation,
+		Provision:                    options.provision,
+		Network:                      options.network,
+		NetworkProxyAllow:            options.networkProxyAllow,
+		NetworkForward:               options.networkForward,
+		ShieldCredentials:            options.shieldCredentials,
+		SeccompProfile:               options.seccompProfile,
+		LimitMemory:                  options.limitMemory,
+		LimitPids:                    options.limitPids,
+		LimitCPUs:                    options.limitCPUs,
+		IsolationBwrapPassthrough:    options.isolationBwrapPassthrough,
+		IsolationLandlockPassthrough: options.isolationLandlockPassthrough,
+		IsolationNativePassthrough:   options.isolationNativePassthrough,
+		IsolationDockerRuntime:       options.isolationDockerRuntime,
+		IsolationPodmanRuntime:       options.isolationPodmanRuntime,
+		InitCommands:                 options.initCommands,
+		NixSource:                    options.nixSource,
+		NixRev:                       options.nixRev,
+		NixPackages:                  options.nixPackages,
+		NixShell:                     options.nixShell,
+		WorkDir:                      workDir,
+		WorktreeBranch:               options.worktreeBranch,
+		WorktreeSourceBranch:         options.worktreeSourceBranch,
+		WorktreeDir:                  options.worktreeDir,
+		WorktreeCleanup:              options.worktreeCleanup,
+		GitIntegrity:                 options.gitIntegrity,
+		Config:                       config,
+		Profile:                      options.profile,
+		SetFlags:                     setFlags,
+		BindPaths:                    options.bindPaths,
+		RoBindPaths:                  options.roBindPaths,
+		Protect:                      options.protect,
+		CustomEnv:                    redactEnv(options.customEnv),
+		Image:                        options.image,
+		Terminal:                     options.terminal,
+		TerminalSession:              options.terminalSession,
+		TerminalWindow:               options.terminalWindow,
+		TerminalDetach:               options.terminalDetach,
+		VCS:                          options.vcs,
+		RequirePinnedProvision:       options.requirePinnedProvision,
+		RequireHostToolsUnreachable:  options.requireHostToolsUnreachable,
+		RequireEgressRestricted:      options.requireEgressRestricted,
+		RequireKernelIsolation:       options.requireKernelIsolation,
+		RequireSyscallFilter:         options.requireSyscallFilter,
+		AgentArgs:                    args,
+		Prompt:                       options.prompt,
+		PromptFile:                   options.promptFile,
+		Timeout:                      options.timeout,
+		Review:                       options.review,
+	}, nil
+}
+
+// redactEnv replaces the value of every KEY=VALUE entry.
+func redactEnv(entries []string) []string {
+	redactedEnv := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name, _, _ := strings.Cut(entry, "=")
+		redactedEnv = append(redactedEnv, name+"="+redacted)
+	}
+	return redactedEnv
+}
+
+// options rebuilds the run options the inputs were recorded from. current
+// keeps what a plan does not fix: the custom environment values, the dry-run
+// and output choice, the usage report, the audit log and the output file.
+func (in planInputs) options(current runOptions) runOptions {
+	return runOptions{
+		agent:                        in.Agent,
+		provider:                     in.Provider,
+		isolation:                    in.Isolation,
+		provision:                    in.Provision,
+		network:                      in.Network,
+		networkProxyAllow:            in.NetworkProxyAllow,
+		networkForward:               in.NetworkForward,
+		shieldCredentials:            in.ShieldCredentials,
+		seccompProfile:               in.SeccompProfile,
+		limitMemory:                  in.LimitMemory,
+		limitPids:                    in.LimitPids,
+		limitCPUs:                    in.LimitCPUs,
+		usageReport:                  current.usageReport,
+		auditLog:                     current.auditLog,
+		prompt:                       in.Prompt,
+		promptFile:                   in.PromptFile,
+		timeout:                      in.Timeout,
+		review:                       in.Review,
+		outputFile:                   current.outputFile,
+		isolationBwrapPassthrough:    in.IsolationBwrapPassthrough,
+		isolationLandlockPassthrough: in.IsolationLandlockPassthrough,
+		isolationNativePassthrough:   in.IsolationNativePassthrough,
+		isolationDockerRuntime:       in.IsolationDockerRuntime,
+		isolationPodmanRuntime:       in.IsolationPodmanRuntime,
+		initCommands:                 in.InitCommands,
+		nixSource:                    in.NixSource,
+		nixRev:                       in.NixRev,
+		nixPackages:                  in.NixPackages,
+		nixShell:                     in.NixShell,
+		workDir:                      in.WorkDir,
+		worktreeBranch:               in.WorktreeBranch,
+		worktreeSourceBranch:         in.WorktreeSourceBranch,
+		worktreeDir:                  in.WorktreeDir,
+		worktreeCleanup:              in.WorktreeCleanup,
+		gitIntegrity:                 in.GitIntegrity,
+		config:                       in.Config,
+		profile:                      in.Profile,
+		bindPaths:                    in.BindPaths,
+		roBindPaths:                  in.RoBindPaths,
+		protect:                      in.Protect,
+		customEnv:                    current.customEnv,
+		image:                        in.Image,
+		terminal:                     in.Terminal,
+		terminalSession:              in.TerminalSession,
+		terminalWindow:               in.TerminalWindow,
+		terminalDetach:               in.TerminalDetach,
+		vcs:                          in.VCS,
+		dryRun:                       current.dryRun,
+		output:                       current.output,
+		plan:                         current.plan,
+		requirePinnedProvision:       in.RequirePinnedProvision,
+		requireHostToolsUnreachable:  in.RequireHostToolsUnreachable,
+		requireEgressRestricted:      in.RequireEgressRestricted,
+		requireKernelIsolation:       in.RequireKernelIsolation,
+		requireSyscallFilter:         in.RequireSyscallFilter,
+	}
+}
+
+// readPlan loads a plan for replay and checks that no other run flag tries to
+// change it.
+func readPlan(cmd *cobra.Command, path string) (runPlan, error) {
+	var fixed []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if !planReplayFlags[flag.Name] {
+			fixed = append(fixed, "--"+flag.Name)
+		}
+	})
+	if len(fixed) > 0 {
+		return runPlan{}, fmt.Errorf("--plan fixes the run; it cannot be combined with %s", strings.Join(fixed, ", "))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return runPlan{}, fmt.Errorf("read plan: %w", err)
+	}
+	var plan runPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return runPlan{}, fmt.Errorf("%w %s: %v", ErrInvalidPlan, path, err)
+	}
+	if plan.Version != planVersion {
+		return runPlan{}, fmt.Errorf("%w %s: version %d, want %d", ErrInvalidPlan, path, plan.Version, planVersion)
+	}
+	return plan, nil
+}
+
+// perRunPlaceholders replaces the host paths and addresses that differ on
+// every resolution of the same inputs.
+func perRunPlaceholders(relay netshared.RelayTransport, filter isoshared.SyscallFilter, shieldEndpoint, reviewDir string) *strings.Replacer {
+	values := map[string]string{
+		relay.ProxySocket: "<egress-socket>",
+		filter.OCIFile:    "<seccomp-profile>",
+		filter.BPFFile:    "<seccomp-filter>",
+		shieldEndpoint:    "<credential-shield>",
+		reviewDir:         "<review>",
+	}
+	for index, forward := range relay.Forwards {
+		values[forward.Socket] = fmt.Sprintf("<forward-socket-%d>", index)
+	}
+	var olds []string
+	for old := range values {
+		if old != "" {
+			olds = append(olds, old)
+		}
+	}
+	// Longest first, so a path is never replaced through a prefix of it.
+	sort.Slice(olds, func(i, j int) bool { return len(olds[i]) > len(olds[j]) })
+	pairs := make([]string, 0, 2*len(olds))
+	for _, old := range olds {
+		pairs = append(pairs, old, values[old])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// planSandbox is what a run resolved to, as buildPlan needs it.
+type planSandbox struct {
+	axes         resolvedAxes
+	agent        *types.AgentConfig
+	provider     *types.ModelProvider
+	runCfg       isoshared.RunConfig
+	contribution provision.Contribution
+	exposure     isoshared.Exposure
+	command      []string
+	proxyAllow   []string
+	forwards     []string
+	placeholders *strings.Replacer
+}
+
+// buildPlan describes a resolved run.
+func buildPlan(inputs planInputs, s planSandbox) runPlan {
+	replace := func(values []string) []string {
+		out := make([]string, len(values))
+		for i, value := range values {
+			out[i] = s.placeholders.Replace(value)
+		}
+		return out
+	}
+	plan := runPlan{
+		Version: planVersion,
+		Inputs:  inputs,
+		Agent:   string(s.agent.Type),
+		Isolation: planIsolation{
+			Method:         string(s.axes.IsolationName),
+			Runtime:        s.axes.Runtime,
+			Image:          s.axes.Image,
+			Passthrough:    s.axes.Passthrough,
+			SeccompProfile: s.axes.Seccomp,
+		},
+		Provision: planProvision{
+			Method:       string(s.axes.ProvisionName),
+			Closure:      s.contribution.RoBindPaths,
+			PathEntries:  s.contribution.PathEntries,
+			InitCommands: s.contribution.InitCommands,
+		},
+		Network: planNetwork{
+			Mode:       string(s.axes.Network),
+			ProxyAllow: s.proxyAllow,
+			Forwards:   replace(s.forwards),
+		},
+		Policy:       policyGuarantees(s.axes.Policy),
+		Capabilities: capabilityGuarantees(s.axes.Capabilities),
+		WorkDir:      s.runCfg.WorkDir,
+		RepoDir:      s.runCfg.RepoDir,
+		Binds:        make([]planBind, 0, len(s.exposure.Binds)),
+		Env:          make(map[string]string, len(s.exposure.Env)),
+		Limits: planLimits{
+			MemoryBytes: s.runCfg.Limits.MemoryBytes,
+			Pids:        s.runCfg.Limits.Pids,
+			CPUs:        s.runCfg.Limits.CPUs,
+		},
+		AgentArgs: append([]string{}, s.runCfg.AgentArgs...),
+		Command:   replace(s.command),
+	}
+	if s.provider != nil {
+		plan.Provider = s.provider.Name
+	}
+	for _, bind := range s.exposure.Binds {
+		mode := "rw"
+		if bind.ReadOnly {
+			mode = "ro"
+		}
+		plan.Binds = append(plan.Binds, planBind{Source: s.placeholders.Replace(bind.Source), Target: bind.Target, Mode: mode})
+	}
+	for _, name := range s.exposure.Env {
+		plan.Env[name] = redacted
+	}
+	return plan
+}
+
+func policyGuarantees(pol policy.SandboxPolicy) planGuarantees {
+	return planGuarantees{
+		PinnedProvision:      pol.RequirePinnedProvision,
+		HostToolsUnreachable: pol.RequireHostToolsUnreachable,
+		EgressRestricted:     pol.RequireEgressRestricted,
+		KernelIsolated:       pol.RequireKernelIsolation,
+		SyscallFiltered:      pol.RequireSyscallFilter,
+	}
+}
+
+func capabilityGuarantees(caps policy.Capabilities) planGuarantees {
+	return planGuarantees{
+		PinnedProvision:      caps.Pinned,
+		HostToolsUnreachable: caps.HostToolsUnreachable,
+		EgressRestricted:     caps.EgressRestricted,
+		KernelIsolated:       caps.KernelIsolated,
+		SyscallFiltered:      caps.SyscallFiltered,
+	}
+}
+
+// diffPlans names the fields in which got differs from want, one level into
+// nested objects (e.g. "capabilities.egressRestricted").
+func diffPlans(want, got runPlan) []string {
+	var wantFields, gotFields map[string]any
+	wantData, _ := json.Marshal(want)
+	gotData, _ := json.Marshal(got)
+	_ = json.Unmarshal(wantData, &wantFields)
+	_ = json.Unmarshal(gotData, &gotFields)
+
+	var changed []string
+	for _, key := range unionKeys(wantFields, gotFields) {
+		if reflect.DeepEqual(wantFields[key], gotFields[key]) {
+			continue
+		}
+		wantObject, wantOK := wantFields[key].(map[string]any)
+		gotObject, gotOK := gotFields[key].(map[string]any)
+		if !wantOK || !gotOK {
+			changed = append(changed, key)
+			continue
+		}
+		for _, sub := range unionKeys(wantObject, gotObject) {
+			if !reflect.DeepEqual(wantObject[sub], gotObject[sub]) {
+				changed = append(changed, key+"."+sub)
+			}
+		}
+	}
+	return changed
+}
+
+func unionKeys(a, b map[string]any) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printPlan writes the plan as indented JSON to stdout.
+func printPlan(plan runPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(string(data))
+	return err
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+func TestPlanInputsRedactTheEnvironmentAndRoundTrip(t *testing.T) {
+	options := runOptions{
+		agent:       "claude",
+		isolation:   "bwrap",
+		provision:   "nix",
+		network:     "proxy",
+		nixPackages: []string{"nodejs"},
+		customEnv:   []string{"API_KEY=secret", "EMPTY="},
+		config:      "config.yaml",
+		profile:     "review",
+		vcs:         "git",
+		prompt:      "Fix the build",
+	}
+	inputs, err := newPlanInputs(options, []string{"review"}, "/work", []string{"isolation", "nix-packages"})
+	if err != nil {
+		t.Fatalf("newPlanInputs() error = %v", err)
+	}
+	if want := []string{"API_KEY=" + redacted, "EMPTY=" + redacted}; !slices.Equal(inputs.CustomEnv, want) {
+		t.Errorf("inputs.CustomEnv = %v, want %v", inputs.CustomEnv, want)
+	}
+	if !filepath.IsAbs(inputs.Config) {
+		t.Errorf("inputs.Config = %q, want an absolute path", inputs.Config)
+	}
+
+	current := runOptions{customEnv: []string{"API_KEY=again"}, dryRun: true, output: outputJSON, plan: "plan.json", usageReport: "usage.json", outputFile: "out.txt"}
+	replayed := inputs.options(current)
+	want := options
+	want.workDir, want.config = "/work", inputs.Config
+	want.customEnv, want.dryRun, want.output, want.plan, want.usageReport = current.customEnv, true, outputJSON, "plan.json", "usage.json"
+	want.outputFile = "out.txt"
+	if !reflect.DeepEqual(replayed, want) {
+		t.Errorf("options() = %+v, want %+v", replayed, want)
+	}
+}
+
+func TestPerRunPlaceholdersReplaceEveryPerRunValue(t *testing.T) {
+	relay := netshared.RelayTransport{
+		ProxySocket: "/tmp/run-1/proxy.sock",
+		Forwards:    []netshared.LoopbackForward{{Addr: "127.0.0.1:5432", Socket: "/tmp/run-1/proxy.sock.0"}},
+	}
+	filter := isoshared.SyscallFilter{Profile: "default", OCIFile: "/tmp/seccomp-1/profile.json", BPFFile: "/tmp/seccomp-1/filter.bpf"}
+	replacer := perRunPlaceholders(relay, filter, "127.0.0.1:40123", "/state/reviews/abc")
+
+	got := replacer.Replace("/tmp/run-1/proxy.sock.0 /tmp/run-1/proxy.sock seccomp=/tmp/seccomp-1/profile.json /tmp/seccomp-1/filter.bpf http://127.0.0.1:40123 upperdir=/state/reviews/abc/upper")
+	want := "<forward-socket-0> <egress-socket> seccomp=<seccomp-profile> <seccomp-filter> http://<credential-shield> upperdir=<review>/upper"
+	if got != want {
+		t.Errorf("Replace() = %q, want %q", got, want)
+	}
+	if got := perRunPlaceholders(netshared.RelayTransport{}, isoshared.SyscallFilter{}, "", "").Replace("/work"); got != "/work" {
+		t.Errorf("Replace() without per-run values = %q, want /work", got)
+	}
+}
+
+func TestBuildPlanRedactsValuesAndNamesBindModes(t *testing.T) {
+	s := planSandbox{
+		axes:     resolvedAxes{IsolationName: "bwrap", ProvisionName: "none", Network: netshared.ModeProxy},
+		agent:    &types.AgentConfig{Type: types.AgentClaude},
+		provider: &types.ModelProvider{Name: "gemini"},
+		runCfg:   isoshared.RunConfig{WorkDir: "/work", AgentArgs: []string{"review"}},
+		exposure: isoshared.Exposure{
+			Binds: []isoshared.Bind{
+				{Source: "/work", Target: "/work"},
+				{Source: "/tmp/run-1/proxy.sock", Target: "/run/egress.sock", ReadOnly: true},
+			},
+			Env: []string{"API_KEY", "HOME"},
+		},
+		command:      []string{"bwrap", "--ro-bind", "/tmp/run-1/proxy.sock", "/run/egress.sock"},
+		placeholders: strings.NewReplacer("/tmp/run-1/proxy.sock", "<egress-socket>"),
+	}
+	plan := buildPlan(planInputs{Agent: "claude"}, s)
+
+	wantBinds := []planBind{
+		{Source: "/work", Target: "/work", Mode: "rw"},
+		{Source: "<egress-socket>", Target: "/run/egress.sock", Mode: "ro"},
+	}
+	if !reflect.DeepEqual(plan.Binds, wantBinds) {
+		t.Errorf("plan.Binds = %+v, want %+v", plan.Binds, wantBinds)
+	}
+	if want := map[string]string{"API_KEY": redacted, "HOME": redacted}; !reflect.DeepEqual(plan.Env, want) {
+		t.Errorf("plan.Env = %v, want %v", plan.Env, want)
+	}
+	if slices.Contains(plan.Command, "/tmp/run-1/proxy.sock") {
+		t.Errorf("plan.Command = %v, want the per-run socket replaced", plan.Command)
+	}
+	if plan.Version != planVersion || plan.Provider != "gemini" || plan.Isolation.Method != "bwrap" {
+		t.Errorf("plan = %+v, want version %d, provider gemini and isolation bwrap", plan, planVersion)
+	}
+}
+
+func TestDiffPlansNamesChangedFields(t *testing.T) {
+	want := runPlan{Version: planVersion, Agent: "claude", Env: map[string]string{"A": redacted}}
+	got := want
+	got.Env = map[string]string{"A": redacted, "B": redacted}
+	got.Capabilities.EgressRestricted = true
+	got.Command = []string{"claude"}
+
+	changed := diffPlans(want, got)
+	if wantChanged := []string{"capabilities.egressRestricted", "command", "env.B"}; !slices.Equal(changed, wantChanged) {
+		t.Errorf("diffPlans() = %v, want %v", changed, wantChanged)
+	}
+	if changed := diffPlans(want, want); len(changed) != 0 {
+		t.Errorf("diffPlans(same) = %v, want none", changed)
+	}
+}
+
+func writePlanFile(t *testing.T, plan runPlan) string {
+	t.Helper()
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestReadPlanRefusesFixedFlagsAndOtherVersions(t *testing.T) {
+	path := writePlanFile(t, runPlan{Version: planVersion, Agent: "claude"})
+
+	command := newRunCommand()
+	if err := command.ParseFlags([]string{"--plan", path, "--env", "A=b", "--dry-run"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if plan, err := readPlan(command, path); err != nil || plan.Agent != "claude" {
+		t.Errorf("readPlan() = %+v, %v, want the claude plan", plan, err)
+	}
+
+	command = newRunCommand()
+	if err := command.ParseFlags([]string{"--plan", path, "--isolation", "none", "--network", "host"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if _, err := readPlan(command, path); err == nil || !strings.Contains(err.Error(), "--isolation, --network") {
+		t.Errorf("readPlan() error = %v, want the fixed flags named", err)
+	}
+
+	for _, data := range []string{`{"version": 2}`, `not json`} {
+		invalid := filepath.Join(t.TempDir(), "plan.json")
+		if err := os.WriteFile(invalid, []byte(data), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		if _, err := readPlan(&cobra.Command{}, invalid); !errors.Is(err, ErrInvalidPlan) {
+			t.Errorf("readPlan(%s) error = %v, want %v", data, err, ErrInvalidPlan)
+		}
+	}
+}
+
+// captureStdout runs fn with stdout redirected to a file and returns what it
+// wrote.
+func captureStdout(t *testing.T, fn func() error) []byte {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	err = fn()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return data
+}
+
+func TestRunAgentReplaysOnlyAnUnchangedPlan(t *testing.T) {
+	workDir := t.TempDir()
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("create agent executable: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+	configPath := filepath.Join(workDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	options := runOptions{
+		agent:     "claude",
+		isolation: "none",
+		provision: "none",
+		network:   "host",
+		workDir:   workDir,
+		config:    configPath,
+		vcs:       "git",
+		customEnv: []string{"TOKEN=secret"},
+		dryRun:    true,
+		output:    outputJSON,
+	}
+	data := captureStdout(t, func() error { return runAgent(newRunCommand(), []string{"review"}, options) })
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("plan leaks an environment value:\n%s", data)
+	}
+	var plan runPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		t.Fatalf("Unmarshal(plan) error = %v\n%s", err, data)
+	}
+	if plan.Isolation.Method != "none" || plan.Env["TOKEN"] != redacted || !slices.Equal(plan.AgentArgs, []string{"review"}) {
+		t.Errorf("plan = %+v, want isolation none, TOKEN redacted and the agent args", plan)
+	}
+
+	replay := func(plan runPlan, env ...string) error {
+		return runAgent(newRunCommand(), nil, runOptions{plan: writePlanFile(t, plan), customEnv: env, dryRun: true, output: outputText})
+	}
+	if err := replay(plan, "TOKEN=other"); err != nil {
+		t.Errorf("replay(unchanged) error = %v", err)
+	}
+	if err := replay(plan); !errors.Is(err, ErrPlanMismatch) || !strings.Contains(err.Error(), "env.TOKEN") {
+		t.Errorf("replay(without --env) error = %v, want %v naming env.TOKEN", err, ErrPlanMismatch)
+	}
+	tampered := plan
+	tampered.Capabilities.HostToolsUnreachable = true
+	if err := replay(tampered, "TOKEN=other"); !errors.Is(err, ErrPlanMismatch) || !strings.Contains(err.Error(), "capabilities.hostToolsUnreachable") {
+		t.Errorf("replay(tampered) error = %v, want %v naming the capability", err, ErrPlanMismatch)
+	}
+	if err := runAgent(newRunCommand(), []string{"extra"}, runOptions{plan: writePlanFile(t, plan)}); err == nil {
+		t.Error("runAgent(--plan with agent args) error = nil, want refusal")
+	}
+}
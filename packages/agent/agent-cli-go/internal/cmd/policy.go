@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/sandbox"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/sandbox/plugins"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
+)
+
+func newPolicyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect which sandbox cells meet the policy guarantees",
+	}
+	cmd.AddCommand(newPolicyMatrixCommand())
+	return cmd
+}
+
+func newPolicyMatrixCommand() *cobra.Command {
+	f := flags{network: "host"}
+	cmd := &cobra.Command{
+		Use:   "matrix",
+		Short: "Print the guarantees of every isolation × provision cell on this host",
+		Long: `Print the guarantees of every isolation × provision cell on this host,
+cheapest first, for the given network, image, runtimes and seccomp profile.
+With --require-* flags it also names the cheapest available cell that
+satisfies them, the one agent-cli run picks when neither axis is set.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return printPolicyMatrix(cmd.OutOrStdout(), plugins.DefaultRegistry(), f)
+		},
+	}
+	cmd.Flags().StringVar(&f.network, "network", f.network, "Network egress axis (host, none, proxy)")
+	cmd.Flags().StringVar(&f.image, "image", "", "Container image (for docker or podman isolation)")
+	cmd.Flags().StringVar(&f.isolationDockerRuntime, "isolation-docker-runtime", "", "Kernel-isolating container runtime, e.g. runsc (docker only)")
+	cmd.Flags().StringVar(&f.isolationPodmanRuntime, "isolation-podman-runtime", "", "Kernel-isolating container runtime, e.g. runsc or krun (podman only)")
+	cmd.Flags().StringVar(&f.seccompProfile, "seccomp-profile", "", "Seccomp deny list installed in the sandbox (default, strict; bwrap, docker or podman)")
+	cmd.Flags().BoolVar(&f.requirePinnedProvision, "require-pinned-provision", false, "Require provisioning from a pinned source")
+	cmd.Flags().BoolVar(&f.requireHostToolsUnreachable, "require-host-tools-unreachable", false, "Require host tools to be unreachable from the sandbox")
+	cmd.Flags().BoolVar(&f.requireEgressRestricted, "require-egress-restricted", false, "Require network egress to be disabled or enforced by a supported transport")
+	cmd.Flags().BoolVar(&f.requireKernelIsolation, "require-kernel-isolation", false, "Require a kernel-isolating runtime such as docker with runsc or podman with krun")
+	cmd.Flags().BoolVar(&f.requireSyscallFilter, "require-syscall-filter", false, "Require a hardened seccomp syscall filter selected with --seccomp-profile")
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newPolicyCommand())
+}
+
+// printPolicyMatrix writes the capability table of every cell in reg and, for
+// a non-empty policy, the cheapest available cell that satisfies it.
+func printPolicyMatrix(w io.Writer, reg *sandbox.Registry, f flags) error {
+	net, err := netshared.ParseMode(f.network)
+	if err != nil {
+		return err
+	}
+	if f.seccompProfile != "" {
+		if _, err := isoshared.SeccompDenyList(f.seccompProfile); err != nil {
+			return err
+		}
+	}
+	cells := sandbox.Cells(reg, f.request(net))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ISOLATION\tPROVISION\tAVAILABLE\tCOST\tPINNED\tHOST-TOOLS-UNREACHABLE\tEGRESS-RESTRICTED\tKERNEL-ISOLATED\tSYSCALL-FILTERED")
+	for _, cell := range cells {
+		caps := cell.Capabilities
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			cell.Request.Isolation, cell.Request.Provision, yesNo(cell.Available), cell.Cost,
+			yesNo(caps.Pinned), yesNo(caps.HostToolsUnreachable), yesNo(caps.EgressRestricted),
+			yesNo(caps.KernelIsolated), yesNo(caps.SyscallFiltered))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	pol := f.policy()
+	if pol == (policy.SandboxPolicy{}) {
+		return nil
+	}
+	solved, err := sandbox.Solve(cells, pol)
+	if err != nil {
+		_, err = fmt.Fprintf(w, "\n%v on network %s\n", err, net)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\nCheapest cell that satisfies the policy: --isolation %s --provision %s\n",
+		solved[0].Request.Isolation, solved[0].Request.Provision)
+	return err
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
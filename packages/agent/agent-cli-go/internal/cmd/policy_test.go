@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+)
+
+func TestPrintPolicyMatrixListsEveryCellAndTheCheapestFit(t *testing.T) {
+	var out bytes.Buffer
+	f := flags{network: "none", requireEgressRestricted: true, requireHostToolsUnreachable: true}
+	if err := printPolicyMatrix(&out, everythingAvailable(), f); err != nil {
+		t.Fatalf("printPolicyMatrix() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	// Header, 6 isolators × 3 provisioners, a blank line and the pick.
+	if len(lines) != 1+18+2 {
+		t.Fatalf("printPolicyMatrix() printed %d lines, want 21:\n%s", len(lines), out.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "none" || fields[1] != "none" || fields[3] != "0" {
+		t.Errorf("first row = %q, want the free none × none cell", lines[1])
+	}
+	if want := "--isolation bwrap --provision none"; !strings.HasSuffix(lines[len(lines)-1], want) {
+		t.Errorf("last line = %q, want the cheapest fit %q", lines[len(lines)-1], want)
+	}
+}
+
+func TestPrintPolicyMatrixReportsAnUnsatisfiablePolicy(t *testing.T) {
+	var out bytes.Buffer
+	if err := printPolicyMatrix(&out, everythingAvailable(), flags{network: "host", requireEgressRestricted: true}); err != nil {
+		t.Fatalf("printPolicyMatrix() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "no available sandbox cell satisfies the policy on network host") {
+		t.Errorf("printPolicyMatrix() = %q, want the unsatisfiable policy named", out.String())
+	}
+}
+
+func TestPrintPolicyMatrixRejectsBadInputs(t *testing.T) {
+	if err := printPolicyMatrix(&bytes.Buffer{}, everythingAvailable(), flags{network: "wan"}); err == nil {
+		t.Error("printPolicyMatrix(network wan) error = nil, want an invalid network")
+	}
+	err := printPolicyMatrix(&bytes.Buffer{}, everythingAvailable(), flags{network: "host", seccompProfile: "lenient"})
+	if !errors.Is(err, isoshared.ErrUnknownSeccompProfile) {
+		t.Errorf("printPolicyMatrix(profile lenient) error = %v, want %v", err, isoshared.ErrUnknownSeccompProfile)
+	}
+}
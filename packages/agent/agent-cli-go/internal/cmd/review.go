@@ -0,0 +1,406 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/overlay"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// ErrReviewNotFound reports a review ID that matches no pending review.
+var ErrReviewNotFound = errors.New("review not found")
+
+// reviewFile holds a pending review's metadata next to its layers.
+const reviewFile = "review.json"
+
+// pendingReview is the copy-on-write upper layer of a --review run, kept under
+// $XDG_STATE_HOME/agent-cli/reviews/<id> until it is applied or discarded. Its
+// ID is the run's.
+type pendingReview struct {
+	ID        string    `json:"id"`
+	WorkDir   string    `json:"workDir"`
+	CreatedAt time.Time `json:"createdAt"`
+	dir       string
+}
+
+// reviewsDir returns the directory pending reviews are kept in.
+func reviewsDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reviews"), nil
+}
+
+// newReview names the layers of a reviewed run of workDir; only create lays
+// them out, so a dry run leaves nothing behind.
+func newReview(workDir string) (pendingReview, error) {
+	root, err := reviewsDir()
+	if err != nil {
+		return pendingReview{}, err
+	}
+	review := pendingReview{ID: newRunID(), WorkDir: workDir, CreatedAt: time.Now().UTC()}
+	review.dir = filepath.Join(root, review.ID)
+	return review, nil
+}
+
+// create lays out the review's empty layers and records its metadata.
+func (r pendingReview) create() error {
+	layers := r.layers()
+	for _, dir := range []string{layers.Upper, layers.Work} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("create review layers: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode review %s: %w", r.ID, err)
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, reviewFile), append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("record review %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// layers returns the overlay the sandbox mounts over the workspace.
+func (r pendingReview) layers() isoshared.Overlay {
+	return isoshared.Overlay{Upper: filepath.Join(r.dir, "upper"), Work: filepath.Join(r.dir, "work")}
+}
+
+// changes lists what the run changed in the workspace.
+func (r pendingReview) changes() ([]overlay.Change, error) {
+	return overlay.Changes(r.layers().Upper, r.WorkDir)
+}
+
+// apply copies the changes at paths, or every change when there are none,
+// into the workspace and drops the review. A failed copy keeps it, so the
+// rest can still be applied.
+func (r pendingReview) apply(paths []string) ([]overlay.Change, error) {
+	changes, err := r.changes()
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > 0 {
+		if changes, err = overlay.Select(changes, paths); err != nil {
+			return nil, err
+		}
+	}
+	if err := overlay.Apply(r.layers().Upper, r.WorkDir, changes); err != nil {
+		return nil, fmt.Errorf("apply review %s: %w", r.ID, err)
+	}
+	return changes, r.discard()
+}
+
+// discard drops the review's layers and with them every change it holds.
+func (r pendingReview) discard() error {
+	if err := overlay.Remove(r.dir); err != nil {
+		return fmt.Errorf("discard review %s: %w", r.ID, err)
+	}
+	return nil
+}
+
+// loadReviews reads the pending reviews, oldest first.
+func loadReviews() ([]pendingReview, error) {
+	root, err := reviewsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read reviews: %w", err)
+	}
+	var reviews []pendingReview
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, reviewFile))
+		if err != nil {
+			logging.LogWarning(fmt.Sprintf("skip review %s: %v", entry.Name(), err))
+			continue
+		}
+		var review pendingReview
+		if err := json.Unmarshal(data, &review); err != nil {
+			logging.LogWarning(fmt.Sprintf("skip review %s: %v", entry.Name(), err))
+			continue
+		}
+		review.dir = dir
+		reviews = append(reviews, review)
+	}
+	slices.SortFunc(reviews, func(a, b pendingReview) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return reviews, nil
+}
+
+// findReview returns the review whose ID is, or starts with, id.
+func findReview(reviews []pendingReview, id string) (pendingReview, error) {
+	var found []pendingReview
+	for _, r := range reviews {
+		if r.ID == id {
+			return r, nil
+		}
+		if strings.HasPrefix(r.ID, id) {
+			found = append(found, r)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return pendingReview{}, fmt.Errorf("%w: %s", ErrReviewNotFound, id)
+	case 1:
+		return found[0], nil
+	default:
+		return pendingReview{}, fmt.Errorf("review ID %q is ambiguous: it matches %d reviews", id, len(found))
+	}
+}
+
+// lookupReview loads the pending reviews and finds id among them.
+func lookupReview(id string) (pendingReview, error) {
+	reviews, err := loadReviews()
+	if err != nil {
+		return pendingReview{}, err
+	}
+	return findReview(reviews, id)
+}
+
+// changeMarks are the one-letter marks of a change kind, as git status has.
+var changeMarks = map[overlay.Kind]string{
+	overlay.Added:    "A",
+	overlay.Modified: "M",
+	overlay.Deleted:  "D",
+}
+
+// printChanges writes one marked line per change.
+func printChanges(w io.Writer, changes []overlay.Change) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s %s\n", changeMarks[c.Kind], c.Path)
+	}
+}
+
+// reviewRun shows what a reviewed run changed and, from a terminal, asks
+// whether to apply all of it, some paths, or nothing. A run that changed
+// nothing drops its review; otherwise the review is kept for the review
+// command when there is no terminal, when asked to, or when applying fails.
+// It reports whether the review is still pending.
+func reviewRun(review pendingReview, in io.Reader, out io.Writer, interactive bool) bool {
+	changes, err := review.changes()
+	if err != nil {
+		logging.LogWarning(fmt.Sprintf("review %s: %v", review.ID, err))
+		keptReview(out, review)
+		return true
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(out, "The run changed nothing in %s\n", review.WorkDir)
+		if err := review.discard(); err != nil {
+			logging.LogWarning(err.Error())
+		}
+		return false
+	}
+	fmt.Fprintf(out, "The run changed %d path(s) in %s:\n", len(changes), review.WorkDir)
+	printChanges(out, changes)
+	if !interactive {
+		keptReview(out, review)
+		return true
+	}
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprint(out, "Apply the changes? [a]ll, [s]ome paths, [d]iscard, [k]eep for later: ")
+		answer, readErr := reader.ReadString('\n')
+		var paths []string
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "a", "all":
+		case "s", "some":
+			fmt.Fprint(out, "Paths to apply, separated by spaces: ")
+			line, _ := reader.ReadString('\n')
+			if paths = strings.Fields(line); len(paths) == 0 {
+				continue
+			}
+		case "d", "discard":
+			if err := review.discard(); err != nil {
+				logging.LogWarning(err.Error())
+				return true
+			}
+			fmt.Fprintln(out, "Discarded the changes")
+			return false
+		case "k", "keep":
+			keptReview(out, review)
+			return true
+		default:
+			if readErr != nil {
+				keptReview(out, review)
+				return true
+			}
+			continue
+		}
+		applied, err := review.apply(paths)
+		if errors.Is(err, overlay.ErrNoChange) {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		if err != nil {
+			logging.LogWarning(err.Error())
+			keptReview(out, review)
+			return true
+		}
+		fmt.Fprintf(out, "Applied %d change(s)\n", len(applied))
+		return false
+	}
+}
+
+// keptReview tells how to finish a review that is kept.
+func keptReview(out io.Writer, review pendingReview) {
+	fmt.Fprintf(out, "Kept review %s: apply it with agent-cli review apply %s [path...] or drop it with agent-cli review discard %s\n", review.ID, review.ID, review.ID)
+}
+
+// reviewEntry is one pending review of the listing with its changes.
+type reviewEntry struct {
+	pendingReview
+	Changes []overlay.Change `json:"changes"`
+}
+
+func newReviewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "review",
+		Short: "Apply or discard the changes of reviewed runs",
+		Long: `Finish the reviews that run --review keeps: the agent's changes to the
+workspace, held in a copy-on-write layer under
+$XDG_STATE_HOME/agent-cli/reviews until they are applied or discarded.`,
+	}
+	cmd.AddCommand(newReviewListCommand(), newReviewShowCommand(), newReviewApplyCommand(), newReviewDiscardCommand())
+	return cmd
+}
+
+func newReviewListCommand() *cobra.Command {
+	output := outputText
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the pending reviews",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if output != outputText && output != outputJSON {
+				return fmt.Errorf("invalid --output %q (want %s or %s)", output, outputText, outputJSON)
+			}
+			reviews, err := loadReviews()
+			if err != nil {
+				return err
+			}
+			entries := make([]reviewEntry, 0, len(reviews))
+			for _, r := range reviews {
+				changes, err := r.changes()
+				if err != nil {
+					return err
+				}
+				entries = append(entries, reviewEntry{pendingReview: r, Changes: changes})
+			}
+			return printReviews(cmd.OutOrStdout(), entries, output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", output, "Listing format (text, json)")
+	return cmd
+}
+
+func newReviewShowCommand() *cobra.Command {
+	output := outputText
+	cmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "List the changes a review holds",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != outputText && output != outputJSON {
+				return fmt.Errorf("invalid --output %q (want %s or %s)", output, outputText, outputJSON)
+			}
+			review, err := lookupReview(args[0])
+			if err != nil {
+				return err
+			}
+			changes, err := review.changes()
+			if err != nil {
+				return err
+			}
+			if output == outputJSON {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(reviewEntry{pendingReview: review, Changes: changes})
+			}
+			printChanges(cmd.OutOrStdout(), changes)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", output, "Output format (text, json)")
+	return cmd
+}
+
+func newReviewApplyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "apply <id> [path...]",
+		Short: "Apply a review's changes to the workspace",
+		Long: `Copy the changes a review holds into its workspace and drop the review.
+Paths, relative to the workspace, apply only the changes at or under them;
+the rest are dropped with the review.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			review, err := lookupReview(args[0])
+			if err != nil {
+				return err
+			}
+			applied, err := review.apply(args[1:])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Applied %d change(s) to %s\n", len(applied), review.WorkDir)
+			return nil
+		},
+	}
+}
+
+func newReviewDiscardCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "discard <id>",
+		Short: "Drop a review and every change it holds",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			review, err := lookupReview(args[0])
+			if err != nil {
+				return err
+			}
+			if err := review.discard(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Discarded review %s\n", review.ID)
+			return nil
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newReviewCommand())
+}
+
+// printReviews writes entries as a table or as JSON.
+func printReviews(w io.Writer, entries []reviewEntry, output string) error {
+	if output == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tWORKDIR\tCHANGES")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", e.ID, e.CreatedAt.Local().Format(time.DateTime), e.WorkDir, len(e.Changes))
+	}
+	return tw.Flush()
+}
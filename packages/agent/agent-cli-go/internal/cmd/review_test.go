@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stageReview creates a pending review of a workspace holding a.txt and
+// b.txt, with the run's writes to a.txt and new.txt in its upper layer.
+func stageReview(t *testing.T) pendingReview {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	workDir := t.TempDir()
+	review, err := newReview(workDir)
+	if err != nil {
+		t.Fatalf("newReview() error = %v", err)
+	}
+	if err := review.create(); err != nil {
+		t.Fatalf("create() error = %v", err)
+	}
+	upper := review.layers().Upper
+	for dir, files := range map[string]map[string]string{
+		workDir: {"a.txt": "a\n", "b.txt": "b\n"},
+		upper:   {"a.txt": "changed\n", "new.txt": "new\n"},
+	} {
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+		}
+	}
+	return review
+}
+
+func readWorkspaceFile(t *testing.T, review pendingReview, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(review.WorkDir, name))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func TestReviewRunAppliesTheChosenPathsAndDropsTheReview(t *testing.T) {
+	review := stageReview(t)
+	var out bytes.Buffer
+
+	if pending := reviewRun(review, strings.NewReader("x\ns\nmissing\ns\nnew.txt\n"), &out, true); pending {
+		t.Fatalf("reviewRun() pending = true, want the review finished; output:\n%s", out.String())
+	}
+	if got := out.String(); !strings.Contains(got, "M a.txt\nA new.txt\n") || !strings.Contains(got, "no change at missing") {
+		t.Errorf("reviewRun() output = %q, want the changes listed and the bad path refused", got)
+	}
+	if a, added := readWorkspaceFile(t, review, "a.txt"), readWorkspaceFile(t, review, "new.txt"); a != "a\n" || added != "new\n" {
+		t.Errorf("workspace a.txt = %q, new.txt = %q, want only new.txt applied", a, added)
+	}
+	if _, err := os.Stat(review.dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("review directory stat error = %v, want it removed", err)
+	}
+}
+
+func TestReviewRunDiscardsOnRequestOrWhenNothingChanged(t *testing.T) {
+	review := stageReview(t)
+	var out bytes.Buffer
+	if pending := reviewRun(review, strings.NewReader("d\n"), &out, true); pending || readWorkspaceFile(t, review, "a.txt") != "a\n" {
+		t.Errorf("reviewRun(discard) pending = %v, a.txt = %q, want the changes dropped", pending, readWorkspaceFile(t, review, "a.txt"))
+	}
+
+	unchanged := stageReview(t)
+	for _, name := range []string{"a.txt", "new.txt"} {
+		if err := os.Remove(filepath.Join(unchanged.layers().Upper, name)); err != nil {
+			t.Fatalf("Remove() error = %v", err)
+		}
+	}
+	out.Reset()
+	if pending := reviewRun(unchanged, strings.NewReader(""), &out, true); pending || !strings.Contains(out.String(), "changed nothing") {
+		t.Errorf("reviewRun(unchanged) = %v, output %q, want the review dropped", pending, out.String())
+	}
+	if _, err := os.Stat(unchanged.dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("review directory stat error = %v, want it removed", err)
+	}
+}
+
+func TestReviewRunKeepsTheReviewWithoutATerminal(t *testing.T) {
+	review := stageReview(t)
+	var out bytes.Buffer
+
+	if pending := reviewRun(review, strings.NewReader("a\n"), &out, false); !pending {
+		t.Fatal("reviewRun() pending = false, want the review kept")
+	}
+	if got := out.String(); !strings.Contains(got, "agent-cli review apply "+review.ID) {
+		t.Errorf("reviewRun() output = %q, want how to apply the review", got)
+	}
+	if got := readWorkspaceFile(t, review, "a.txt"); got != "a\n" {
+		t.Errorf("workspace a.txt = %q, want it untouched", got)
+	}
+}
+
+func TestReviewCommandListsShowsAndAppliesAReview(t *testing.T) {
+	review := stageReview(t)
+	run := func(args ...string) string {
+		t.Helper()
+		command := newReviewCommand()
+		var out bytes.Buffer
+		command.SetOut(&out)
+		command.SetErr(&bytes.Buffer{})
+		command.SetArgs(args)
+		if err := command.Execute(); err != nil {
+			t.Fatalf("Execute(%v) error = %v", args, err)
+		}
+		return out.String()
+	}
+
+	var entries []reviewEntry
+	if err := json.Unmarshal([]byte(run("list", "-o", "json")), &entries); err != nil || len(entries) != 1 || len(entries[0].Changes) != 2 {
+		t.Errorf("review list = %+v (%v), want the review with two changes", entries, err)
+	}
+	if got := run("list"); !strings.Contains(got, review.ID) || !strings.Contains(got, review.WorkDir) {
+		t.Errorf("review list = %q, want the review", got)
+	}
+	if got := run("show", review.ID[:4]); got != "M a.txt\nA new.txt\n" {
+		t.Errorf("review show = %q, want the changes", got)
+	}
+	if got := run("apply", review.ID); !strings.Contains(got, "Applied 2 change(s)") {
+		t.Errorf("review apply = %q, want both changes applied", got)
+	}
+	if a := readWorkspaceFile(t, review, "a.txt"); a != "changed\n" {
+		t.Errorf("workspace a.txt = %q, want the change applied", a)
+	}
+	if got := run("list"); strings.Contains(got, review.ID) {
+		t.Errorf("review list after apply = %q, want no pending review", got)
+	}
+}
+
+func TestReviewCommandDiscardsAReview(t *testing.T) {
+	review := stageReview(t)
+	command := newReviewCommand()
+	command.SetOut(&bytes.Buffer{})
+	command.SetErr(&bytes.Buffer{})
+	command.SetArgs([]string{"discard", review.ID})
+
+	if err := command.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, err := lookupReview(review.ID); !errors.Is(err, ErrReviewNotFound) {
+		t.Errorf("lookupReview() error = %v, want %v", err, ErrReviewNotFound)
+	}
+	if got := readWorkspaceFile(t, review, "a.txt"); got != "a\n" {
+		t.Errorf("workspace a.txt = %q, want it untouched", got)
+	}
+}
+
+func TestFindReviewMatchesAnIDPrefix(t *testing.T) {
+	reviews := []pendingReview{{ID: "abc123"}, {ID: "abd456"}}
+	if got, err := findReview(reviews, "abd"); err != nil || got.ID != "abd456" {
+		t.Errorf("findReview(abd) = (%+v, %v), want abd456", got, err)
+	}
+	if _, err := findReview(reviews, "ab"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("findReview(ab) error = %v, want ambiguous", err)
+	}
+	if _, err := findReview(reviews, "zzz"); !errors.Is(err, ErrReviewNotFound) {
+		t.Errorf("findReview(zzz) error = %v, want %v", err, ErrReviewNotFound)
+	}
+}
+
+func TestRunAgentRejectsAReviewItCannotMountCopyOnWrite(t *testing.T) {
+	workDir := t.TempDir()
+	config := filepath.Join(workDir, "none.yaml")
+	if err := os.WriteFile(config, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	for name, tt := range map[string]struct {
+		terminal string
+		want     string
+	}{
+		"terminal wrapper": {"tmux", "terminal wrapper"},
+		"isolation none":   {"", "copy-on-write"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			options := defaultRunOptions()
+			options.workDir = workDir
+			options.config = config
+			options.terminal = tt.terminal
+			options.review = true
+			if err := runAgent(newRunCommand(), nil, options); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("runAgent() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
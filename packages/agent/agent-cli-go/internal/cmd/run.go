@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	cfgpkg "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/config"
 	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
@@ -18,8 +21,6 @@ import (
 	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/sandbox/plugins"
 	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/terminal"
 	termshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/terminal/shared"
-	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/git"
-	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/jj"
 	wsshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/shared"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/agents"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
@@ -28,55 +29,86 @@ import (
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/validation"
-	wsp "github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/workspace"
 	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/envutil"
 	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
 )
 
 type runOptions struct {
-	agent                       string
-	provider                    string
-	isolation                   string
-	provision                   string
-	network                     string
-	isolationBwrapPassthrough   bool
-	isolationDockerRuntime      string
-	initCommands                []string
-	nixSource                   string
-	nixRev                      string
-	nixPackages                 []string
-	nixShell                    string
-	workDir                     string
-	worktreeBranch              string
-	worktreeSourceBranch        string
-	worktreeDir                 string
-	config                      string
-	bindPaths                   []string
-	roBindPaths                 []string
-	customEnv                   []string
-	image                       string
-	terminal                    string
-	terminalSession             string
-	terminalWindow              string
-	terminalDetach              bool
-	vcs                         string
-	dryRun                      bool
-	requirePinnedProvision      bool
-	requireHostToolsUnreachable bool
-	requireEgressRestricted     bool
-	requireKernelIsolation      bool
+	agent                        string
+	provider                     string
+	isolation                    string
+	provision                    string
+	network                      string
+	networkProxyAllow            []string
+	networkForward               []string
+	shieldCredentials            bool
+	seccompProfile               string
+	limitMemory                  string
+	limitPids                    int64
+	limitCPUs                    float64
+	usageReport                  string
+	auditLog                     string
+	prompt                       string
+	promptFile                   string
+	outputFile                   string
+	timeout                      string
+	review                       bool
+	isolationBwrapPassthrough    bool
+	isolationLandlockPassthrough bool
+	isolationNativePassthrough   bool
+	isolationDockerRuntime       string
+	isolationPodmanRuntime       string
+	initCommands                 []string
+	nixSource                    string
+	nixRev                       string
+	nixPackages                  []string
+	nixShell                     string
+	workDir                      string
+	worktreeBranch               string
+	worktreeSourceBranch         string
+	worktreeDir                  string
+	worktreeCleanup              string
+	gitIntegrity                 string
+	config                       string
+	profile                      string
+	homeDir                      string
+	bindPaths                    []string
+	roBindPaths                  []string
+	protect                      []string
+	customEnv                    []string
+	image                        string
+	terminal                     string
+	terminalSession              string
+	terminalWindow               string
+	terminalDetach               bool
+	vcs                          string
+	dryRun                       bool
+	output                       string
+	plan                         string
+	requirePinnedProvision       bool
+	requireHostToolsUnreachable  bool
+	requireEgressRestricted      bool
+	requireKernelIsolation       bool
+	requireSyscallFilter         bool
 }
 
-func newRunCommand() *cobra.Command {
-	options := runOptions{
-		agent:     "claude",
-		isolation: "none",
-		provision: "none",
-		network:   "host",
-		nixSource: "packages",
-		nixShell:  "default",
-		vcs:       "git",
+// defaultRunOptions returns the flag defaults of run.
+func defaultRunOptions() runOptions {
+	return runOptions{
+		agent:           "claude",
+		isolation:       "none",
+		provision:       "none",
+		network:         "host",
+		nixSource:       "packages",
+		nixShell:        "default",
+		vcs:             "git",
+		worktreeCleanup: cleanupNever,
+		output:          outputText,
 	}
+}
+
+func newRunCommand() *cobra.Command {
+	options := defaultRunOptions()
 	cmd := &cobra.Command{
 		Use:   "run [agent-args...]",
 		Short: "Run an AI coding agent",
@@ -85,51 +117,79 @@ func newRunCommand() *cobra.Command {
 			return runAgent(cmd, args, options)
 		},
 	}
+	addRunFlags(cmd.Flags(), &options)
+	return cmd
+}
 
+// addRunFlags registers the run flags on fs, bound to options.
+func addRunFlags(fs *pflag.FlagSet, options *runOptions) {
 	// Bare axis selectors.
-	cmd.Flags().StringVarP(&options.agent, "agent", "a", options.agent, "Agent to run (claude, opencode)")
-	cmd.Flags().StringVarP(&options.provider, "provider", "p", "", "Model provider")
-	cmd.Flags().StringVar(&options.isolation, "isolation", options.isolation, "Isolation axis (none, bwrap, docker)")
-	cmd.Flags().StringVar(&options.provision, "provision", options.provision, "Provision axis (none, nix, command)")
-	cmd.Flags().StringVar(&options.network, "network", options.network, "Network egress axis (host, none)")
+	fs.StringVarP(&options.agent, "agent", "a", options.agent, "Agent to run (claude, opencode)")
+	fs.StringVarP(&options.provider, "provider", "p", "", "Model provider")
+	fs.StringVar(&options.isolation, "isolation", options.isolation, "Isolation axis (none, bwrap, docker, podman, landlock, native)")
+	fs.StringVar(&options.provision, "provision", options.provision, "Provision axis (none, nix, command)")
+	fs.StringVar(&options.network, "network", options.network, "Network egress axis (host, none, proxy)")
 
 	// Per-type knobs under the --<axis>-<type>-<option> grammar.
-	cmd.Flags().StringVar(&options.isolationDockerRuntime, "isolation-docker-runtime", "", "Kernel-isolating container runtime, e.g. runsc (docker only)")
-	cmd.Flags().BoolVar(&options.isolationBwrapPassthrough, "isolation-bwrap-passthrough", false, "Expose host/base-image tools as a fallback (bwrap only; forfeits host-tools-unreachable)")
-	cmd.Flags().StringSliceVar(&options.initCommands, "init-command", nil, "Init command to run before the agent for --provision command (repeatable)")
-	cmd.Flags().StringVar(&options.nixSource, "nix-source", options.nixSource, "Nix source for --provision nix (packages, flake)")
-	cmd.Flags().StringVar(&options.nixRev, "nix-rev", "", "Pinned nixpkgs rev for --nix-source packages")
-	cmd.Flags().StringSliceVar(&options.nixPackages, "nix-packages", nil, "Additional packages for --nix-source packages; selected agent is automatic (repeatable)")
-	cmd.Flags().StringVar(&options.nixShell, "nix-shell", options.nixShell, "devShell name for --nix-source flake")
-	cmd.Flags().StringVar(&options.image, "image", "", "Container image (for docker isolation)")
+	fs.StringVar(&options.isolationDockerRuntime, "isolation-docker-runtime", "", "Kernel-isolating container runtime, e.g. runsc (docker only)")
+	fs.StringVar(&options.isolationPodmanRuntime, "isolation-podman-runtime", "", "Kernel-isolating container runtime, e.g. runsc or krun (podman only)")
+	fs.BoolVar(&options.isolationBwrapPassthrough, "isolation-bwrap-passthrough", false, "Expose host/base-image tools as a fallback (bwrap only; forfeits host-tools-unreachable)")
+	fs.BoolVar(&options.isolationLandlockPassthrough, "isolation-landlock-passthrough", false, "Grant read access to host system directories (landlock only; forfeits host-tools-unreachable)")
+	fs.BoolVar(&options.isolationNativePassthrough, "isolation-native-passthrough", false, "Expose host tools as a fallback (native only; forfeits host-tools-unreachable)")
+	fs.StringSliceVar(&options.initCommands, "init-command", nil, "Init command to run before the agent for --provision command (repeatable)")
+	fs.StringVar(&options.nixSource, "nix-source", options.nixSource, "Nix source for --provision nix (packages, flake)")
+	fs.StringVar(&options.nixRev, "nix-rev", "", "Pinned nixpkgs rev for --nix-source packages")
+	fs.StringSliceVar(&options.nixPackages, "nix-packages", nil, "Additional packages for --nix-source packages; selected agent is automatic (repeatable)")
+	fs.StringVar(&options.nixShell, "nix-shell", options.nixShell, "devShell name for --nix-source flake")
+	fs.StringVar(&options.image, "image", "", "Container image (for docker or podman isolation)")
+	fs.StringSliceVar(&options.networkProxyAllow, "network-proxy-allow", nil, "Hostname or host:port the egress proxy may reach for --network proxy; *.domain matches subdomains (repeatable)")
+	fs.BoolVar(&options.shieldCredentials, "shield-credentials", false, "Keep the provider credential on the host behind a local proxy; the sandbox gets a per-run token")
+	fs.StringVar(&options.seccompProfile, "seccomp-profile", "", "Seccomp deny list installed in the sandbox (default, strict; bwrap, docker or podman)")
+	fs.StringVar(&options.limitMemory, "limit-memory", "", "Memory limit for the sandbox's process tree, e.g. 4g (default 8g)")
+	fs.Int64Var(&options.limitPids, "limit-pids", 0, "Process limit for the sandbox's process tree (default 4096)")
+	fs.Float64Var(&options.limitCPUs, "limit-cpus", 0, "CPU limit for the sandbox's process tree, e.g. 2 or 0.5 (default unlimited)")
+	fs.StringVar(&options.usageReport, "usage-report", "", "Write the run's resource usage (wall time, CPU, memory, pids) as JSON to this file")
+	fs.StringVar(&options.auditLog, "audit-log", "", "Also append the run's audit record to this JSONL file")
+	fs.StringVar(&options.prompt, "prompt", "", "Run the agent headless on this task: it answers and exits")
+	fs.StringVar(&options.promptFile, "prompt-file", "", "Run the agent headless on the task in this file; - reads stdin")
+	fs.StringVar(&options.outputFile, "output-file", "", "Also write the agent's output to this file")
+	fs.StringVar(&options.timeout, "timeout", "", "Stop the agent once it has run this long, e.g. 30m; a timed-out run exits 124")
+	fs.BoolVar(&options.review, "review", false, "Mount the workspace copy-on-write and review the agent's changes before they reach it (bwrap, docker, podman or native)")
+	fs.StringSliceVar(&options.networkForward, "network-forward", nil, "Host loopback port or IP:port to forward into the sandbox for --network none or proxy; adds to the provider's local endpoints (repeatable)")
 
 	// Workspace / terminal / misc.
-	cmd.Flags().StringVarP(&options.workDir, "work-dir", "w", "", "Working directory")
-	cmd.Flags().StringVar(&options.worktreeBranch, "worktree-branch", "", "Create worktree with branch name")
-	cmd.Flags().StringVar(&options.worktreeSourceBranch, "worktree-source-branch", "", "Source branch for worktree")
-	cmd.Flags().StringVar(&options.worktreeDir, "worktree-dir", "", "Worktree directory path")
-	cmd.Flags().StringVarP(&options.config, "config", "c", "", "Configuration file")
-	cmd.Flags().StringSliceVar(&options.bindPaths, "bind", nil, "Read-write bind mount")
-	cmd.Flags().StringSliceVar(&options.roBindPaths, "ro-bind", nil, "Read-only bind mount")
-	cmd.Flags().StringSliceVar(&options.customEnv, "env", nil, "Environment variables (KEY=VALUE)")
-	cmd.Flags().StringVarP(&options.terminal, "terminal", "t", "", "Terminal wrapper (tmux)")
-	cmd.Flags().StringVar(&options.terminalSession, "terminal-session", "", "Custom tmux session name")
-	cmd.Flags().StringVar(&options.terminalWindow, "terminal-window", "", "Custom tmux window name")
-	cmd.Flags().BoolVar(&options.terminalDetach, "terminal-detach", false, "Run in background (detach from terminal)")
-	cmd.Flags().StringVar(&options.vcs, "vcs", options.vcs, "VCS type for worktree (git, jj)")
-	cmd.Flags().BoolVarP(&options.dryRun, "dry-run", "n", false, "Show configuration without executing")
+	fs.StringVarP(&options.workDir, "work-dir", "w", "", "Working directory")
+	fs.StringVar(&options.worktreeBranch, "worktree-branch", "", "Create worktree with branch name")
+	fs.StringVar(&options.worktreeSourceBranch, "worktree-source-branch", "", "Source branch for worktree")
+	fs.StringVar(&options.worktreeDir, "worktree-dir", "", "Worktree directory path")
+	fs.StringVar(&options.worktreeCleanup, "worktree-cleanup", options.worktreeCleanup, "Remove the worktree after the run (on-success, always, never); a dirty worktree is kept")
+	fs.StringVar(&options.gitIntegrity, "git-integrity", "", "Undo a run's changes to the repository's hooks and git config (revert, block, off; default revert unless --isolation none)")
+	fs.StringVarP(&options.config, "config", "c", "", "Configuration file")
+	fs.StringVar(&options.profile, "profile", "", "Configuration profile to apply over the base configuration")
+	fs.StringSliceVar(&options.bindPaths, "bind", nil, "Read-write bind mount")
+	fs.StringSliceVar(&options.roBindPaths, "ro-bind", nil, "Read-only bind mount")
+	fs.StringSliceVar(&options.protect, "protect", nil, "Workspace path or glob kept read-only in the sandbox, e.g. .github/workflows (bwrap, docker, podman or native)")
+	fs.StringSliceVar(&options.customEnv, "env", nil, "Environment variables (KEY=VALUE)")
+	fs.StringVarP(&options.terminal, "terminal", "t", "", "Terminal wrapper (tmux)")
+	fs.StringVar(&options.terminalSession, "terminal-session", "", "Custom tmux session name")
+	fs.StringVar(&options.terminalWindow, "terminal-window", "", "Custom tmux window name")
+	fs.BoolVar(&options.terminalDetach, "terminal-detach", false, "Run in background (detach from terminal)")
+	fs.StringVar(&options.vcs, "vcs", options.vcs, "VCS type for worktree (git, jj)")
+	fs.BoolVarP(&options.dryRun, "dry-run", "n", false, "Show configuration without executing")
+	fs.StringVar(&options.output, "output", options.output, "Dry-run output format (text, json); json prints the run plan")
+	fs.StringVar(&options.plan, "plan", "", "Run the plan written by --dry-run --output json, after checking it still resolves the same")
 
 	// Independent policy guarantees.
-	cmd.Flags().BoolVar(&options.requirePinnedProvision, "require-pinned-provision", false,
+	fs.BoolVar(&options.requirePinnedProvision, "require-pinned-provision", false,
 		"Require provisioning from a pinned source")
-	cmd.Flags().BoolVar(&options.requireHostToolsUnreachable, "require-host-tools-unreachable", false,
+	fs.BoolVar(&options.requireHostToolsUnreachable, "require-host-tools-unreachable", false,
 		"Require host tools to be unreachable from the sandbox")
-	cmd.Flags().BoolVar(&options.requireEgressRestricted, "require-egress-restricted", false,
+	fs.BoolVar(&options.requireEgressRestricted, "require-egress-restricted", false,
 		"Require network egress to be disabled or enforced by a supported transport")
-	cmd.Flags().BoolVar(&options.requireKernelIsolation, "require-kernel-isolation", false,
-		"Require a kernel-isolating runtime such as docker with runsc")
-
-	return cmd
+	fs.BoolVar(&options.requireKernelIsolation, "require-kernel-isolation", false,
+		"Require a kernel-isolating runtime such as docker with runsc or podman with krun")
+	fs.BoolVar(&options.requireSyscallFilter, "require-syscall-filter", false,
+		"Require a hardened seccomp syscall filter selected with --seccomp-profile")
 }
 
 var runCmd = newRunCommand()
@@ -141,19 +201,27 @@ func init() {
 // flags is the axis-resolution view of the run flags. It is the input to
 // resolveAxes, keeping the resolution testable without a live cobra command.
 type flags struct {
-	isolation                   string
-	provision                   string
-	network                     string
-	image                       string
-	isolationDockerRuntime      string
-	isolationBwrapPassthrough   bool
-	requirePinnedProvision      bool
-	requireHostToolsUnreachable bool
-	requireEgressRestricted     bool
-	requireKernelIsolation      bool
-	isolationChanged            bool
-	provisionChanged            bool
-	hasCustomBinds              bool
+	isolation                    string
+	provision                    string
+	network                      string
+	image                        string
+	isolationDockerRuntime       string
+	isolationPodmanRuntime       string
+	isolationBwrapPassthrough    bool
+	isolationLandlockPassthrough bool
+	isolationNativePassthrough   bool
+	requirePinnedProvision       bool
+	requireHostToolsUnreachable  bool
+	requireEgressRestricted      bool
+	requireKernelIsolation       bool
+	requireSyscallFilter         bool
+	seccompProfile               string
+	isolationChanged             bool
+	provisionChanged             bool
+	hasCustomBinds               bool
+	// allowIsolation rejects an isolation method the sandbox policy files
+	// forbid; nil allows every method.
+	allowIsolation func(isolation.IsolationMethod) error
 }
 
 // policy derives the demanded guarantees from the bare policy flags.
@@ -163,6 +231,45 @@ func (f flags) policy() policy.SandboxPolicy {
 		RequireHostToolsUnreachable: f.requireHostToolsUnreachable,
 		RequireEgressRestricted:     f.requireEgressRestricted,
 		RequireKernelIsolation:      f.requireKernelIsolation,
+		RequireSyscallFilter:        f.requireSyscallFilter,
+	}
+}
+
+// runtime returns the container runtime knob of the selected isolator; each
+// container isolator has its own --isolation-<type>-runtime flag.
+func (f flags) runtime(m isolation.IsolationMethod) string {
+	if m == isolation.IsolationPodman {
+		return f.isolationPodmanRuntime
+	}
+	return f.isolationDockerRuntime
+}
+
+// passthrough returns the host-tools passthrough knob of the selected isolator.
+func (f flags) passthrough(m isolation.IsolationMethod) bool {
+	switch m {
+	case isolation.IsolationLandlock:
+		return f.isolationLandlockPassthrough
+	case isolation.IsolationNative:
+		return f.isolationNativePassthrough
+	default:
+		return f.isolationBwrapPassthrough
+	}
+}
+
+// request returns the sandbox request of any cell on network: the per-type
+// knobs follow the cell's isolator.
+func (f flags) request(net netshared.Mode) sandbox.RequestFunc {
+	return func(iso isolation.IsolationMethod, prov provision.ProvisionMethod) sandbox.Request {
+		return sandbox.Request{
+			Isolation:      iso,
+			Provision:      prov,
+			Network:        net,
+			Passthrough:    f.passthrough(iso),
+			Runtime:        f.runtime(iso),
+			Image:          f.image,
+			HasCustomBinds: f.hasCustomBinds,
+			SeccompProfile: f.seccompProfile,
+		}
 	}
 }
 
@@ -176,6 +283,9 @@ type resolvedAxes struct {
 	Passthrough   bool
 	Runtime       string
 	Image         string
+	Seccomp       string
+	Policy        policy.SandboxPolicy
+	Capabilities  policy.Capabilities
 }
 
 // resolveAxes determines and resolves the confinement axes from the flags. The
@@ -184,6 +294,13 @@ type resolvedAxes struct {
 // pinned toolchain is required but no cell was chosen explicitly, the pinned combo
 // (bwrap × nix) is selected.
 func resolveAxes(f flags) (resolvedAxes, error) {
+	return resolveAxesIn(plugins.DefaultRegistry(), f)
+}
+
+// resolveAxesIn resolves the axes against reg. A cell that fails the policy is
+// reported with every unmet guarantee and the cheapest available cell that
+// meets it as a suggestion; the suggestion is never picked for the user.
+func resolveAxesIn(reg *sandbox.Registry, f flags) (resolvedAxes, error) {
 	isoStr, provStr, netStr := f.isolation, f.provision, f.network
 	if isoStr == "" {
 		isoStr = "none"
@@ -199,6 +316,11 @@ func resolveAxes(f flags) (resolvedAxes, error) {
 	if err != nil {
 		return resolvedAxes{}, err
 	}
+	if f.seccompProfile != "" {
+		if _, err := isoshared.SeccompDenyList(f.seccompProfile); err != nil {
+			return resolvedAxes{}, err
+		}
+	}
 
 	pol := f.policy()
 	isoName := isolation.IsolationMethod(isoStr)
@@ -206,37 +328,102 @@ func resolveAxes(f flags) (resolvedAxes, error) {
 	if pol.RequirePinnedProvision && !f.isolationChanged && !f.provisionChanged {
 		isoName, provName = isolation.IsolationBwrap, provision.ProvisionNix
 	}
-
-	reg := plugins.DefaultRegistry()
-	req := sandbox.Request{
-		Isolation:      isoName,
-		Provision:      provName,
-		Network:        net,
-		Passthrough:    f.isolationBwrapPassthrough,
-		Runtime:        f.isolationDockerRuntime,
-		Image:          f.image,
-		HasCustomBinds: f.hasCustomBinds,
+	if f.allowIsolation != nil {
+		if err := f.allowIsolation(isoName); err != nil {
+			return resolvedAxes{}, err
+		}
 	}
+
+	request := f.request(net)
+	req := request(isoName, provName)
 	iso, prov, err := sandbox.Select(reg, req, pol)
+	if errors.Is(err, sandbox.ErrSyscallFilterUnsupported) {
+		return resolvedAxes{}, fmt.Errorf("%w; use bwrap, docker or podman", err)
+	}
+	var unmet *policy.UnmetError
+	if errors.As(err, &unmet) {
+		cells := sandbox.Cells(reg, request)
+		if f.allowIsolation != nil {
+			cells = slices.DeleteFunc(cells, func(c sandbox.Cell) bool { return f.allowIsolation(c.Request.Isolation) != nil })
+		}
+		solved, solveErr := sandbox.Solve(cells, pol)
+		if solveErr != nil {
+			return resolvedAxes{}, fmt.Errorf("%w; no available cell satisfies the policy either (agent-cli policy matrix lists what each provides)", err)
+		}
+		return resolvedAxes{}, fmt.Errorf("%w; the cheapest available cell that satisfies the policy is --isolation %s --provision %s",
+			err, solved[0].Request.Isolation, solved[0].Request.Provision)
+	}
 	if err != nil {
 		return resolvedAxes{}, err
 	}
 
 	return resolvedAxes{
+		Policy:        pol,
+		Capabilities:  sandbox.Capabilities(iso, prov, req),
 		Isolation:     iso,
 		Provision:     prov,
 		IsolationName: isoName,
 		ProvisionName: provName,
 		Network:       net,
-		Passthrough:   f.isolationBwrapPassthrough,
-		Runtime:       f.isolationDockerRuntime,
+		Passthrough:   req.Passthrough,
+		Runtime:       req.Runtime,
 		Image:         f.image,
+		Seccomp:       f.seccompProfile,
 	}, nil
 }
 
+// policyPaths returns the sandbox policy files a run is held to.
+var policyPaths = cfgpkg.DefaultPolicyPaths
+
 func runAgent(cmd *cobra.Command, args []string, options runOptions) error {
 	verbose, _ := cmd.Flags().GetBool("verbose")
 
+	// A reviewed plan replaces the flags it was resolved from; the run goes
+	// ahead only if they still resolve to the same plan.
+	setFlags := setFlagNames(cmd.Flags())
+	var reviewed *runPlan
+	if options.plan != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("--plan fixes the agent arguments; remove %s", strings.Join(args, " "))
+		}
+		plan, err := readPlan(cmd, options.plan)
+		if err != nil {
+			return err
+		}
+		reviewed = &plan
+		options = plan.Inputs.options(options)
+		args = plan.Inputs.AgentArgs
+		setFlags = append(slices.Clone(plan.Inputs.SetFlags), setFlags...)
+	}
+	flagOptions := options
+
+	cfg, err := loadRunConfig(options, setFlags)
+	if err != nil {
+		return err
+	}
+	options, sources, err := layerOptions(options, setFlags, cfg.project, cfg.user)
+	if err != nil {
+		return err
+	}
+	switch {
+	case options.output != "" && options.output != outputText && options.output != outputJSON:
+		return fmt.Errorf("invalid --output %q (want %s or %s)", options.output, outputText, outputJSON)
+	case options.output == outputJSON && !options.dryRun:
+		return fmt.Errorf("--output %s prints the run plan and needs --dry-run", outputJSON)
+	}
+
+	paths, err := policyPaths()
+	if err != nil {
+		return err
+	}
+	sandboxPolicy, err := cfgpkg.LoadPolicy(paths...)
+	if err != nil {
+		return err
+	}
+	if err := sandboxPolicy.CheckAgent(options.agent); err != nil {
+		return err
+	}
+
 	agent, err := agents.GetAgent(types.AgentType(options.agent))
 	if err != nil {
 		logging.LogError(err.Error())
@@ -244,15 +431,20 @@ func runAgent(cmd *cobra.Command, args []string, options runOptions) error {
 		return err
 	}
 
-	fileConfig, err := cfgpkg.LoadConfigFile(options.config)
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+	if err := sandboxPolicy.CheckProvider(options.provider); err != nil {
+		return err
 	}
-
-	provider, err := resolveProvider(agent.Type, options.provider, fileConfig.Provider)
+	provider, err := resolveProvider(agent.Type, options.provider)
 	if err != nil {
 		return err
 	}
+	if err := checkProjectProvider(provider, sources, cfg); err != nil {
+		return err
+	}
+	extraBinds := len(options.bindPaths) + len(options.roBindPaths)
+	if err := sandboxPolicy.CheckBinds(extraBinds); err != nil {
+		return err
+	}
 
 	workDir := options.workDir
 	if workDir == "" {
@@ -266,32 +458,70 @@ func runAgent(cmd *cobra.Command, args []string, options runOptions) error {
 		return fmt.Errorf("resolve work directory %q: %w", workDir, err)
 	}
 
+	if err := validateWorktreeCleanup(options.worktreeCleanup); err != nil {
+		return err
+	}
+	if err := validateGitIntegrity(options.gitIntegrity); err != nil {
+		return err
+	}
+	// A terminal wrapper returns before the agent ends, so there is no end of
+	// the run to clean up after.
+	if options.worktreeBranch != "" && options.terminal != "" && (options.worktreeCleanup == cleanupOnSuccess || options.worktreeCleanup == cleanupAlways) {
+		return fmt.Errorf("worktree cleanup cannot be combined with a terminal wrapper; the run ends outside the agent-cli process")
+	}
 	workspace, err := prepareWorkspace(options, workDir, verbose)
 	if err != nil {
 		return err
 	}
 
-	bindPaths := wsshared.BuildBindPaths(append(append([]string{}, fileConfig.BindPaths...), options.bindPaths...), workspace.sourceRepoDir)
-	roBindPaths := append(append([]string{}, fileConfig.RoBindPaths...), options.roBindPaths...)
+	bindPaths := wsshared.BuildBindPaths(options.bindPaths, workspace.sourceRepoDir)
+	roBindPaths := options.roBindPaths
 
+	guarantees := sandboxPolicy.Guarantees(policy.SandboxPolicy{
+		RequirePinnedProvision:      options.requirePinnedProvision,
+		RequireHostToolsUnreachable: options.requireHostToolsUnreachable,
+		RequireEgressRestricted:     options.requireEgressRestricted,
+		RequireKernelIsolation:      options.requireKernelIsolation,
+		RequireSyscallFilter:        options.requireSyscallFilter,
+	})
 	axes, err := resolveAxes(flags{
-		isolation:                   options.isolation,
-		provision:                   options.provision,
-		network:                     options.network,
-		image:                       options.image,
-		isolationDockerRuntime:      options.isolationDockerRuntime,
-		isolationBwrapPassthrough:   options.isolationBwrapPassthrough,
-		requirePinnedProvision:      options.requirePinnedProvision,
-		requireHostToolsUnreachable: options.requireHostToolsUnreachable,
-		requireEgressRestricted:     options.requireEgressRestricted,
-		requireKernelIsolation:      options.requireKernelIsolation,
-		isolationChanged:            cmd.Flags().Changed("isolation"),
-		provisionChanged:            cmd.Flags().Changed("provision"),
-		hasCustomBinds:              len(fileConfig.BindPaths)+len(options.bindPaths)+len(roBindPaths) > 0,
+		isolation:                    options.isolation,
+		provision:                    options.provision,
+		network:                      options.network,
+		image:                        options.image,
+		isolationDockerRuntime:       options.isolationDockerRuntime,
+		isolationPodmanRuntime:       options.isolationPodmanRuntime,
+		isolationBwrapPassthrough:    options.isolationBwrapPassthrough,
+		isolationLandlockPassthrough: options.isolationLandlockPassthrough,
+		isolationNativePassthrough:   options.isolationNativePassthrough,
+		requirePinnedProvision:       guarantees.RequirePinnedProvision,
+		requireHostToolsUnreachable:  guarantees.RequireHostToolsUnreachable,
+		requireEgressRestricted:      guarantees.RequireEgressRestricted,
+		requireKernelIsolation:       guarantees.RequireKernelIsolation,
+		requireSyscallFilter:         guarantees.RequireSyscallFilter,
+		seccompProfile:               options.seccompProfile,
+		isolationChanged:             sources.changed("isolation"),
+		provisionChanged:             sources.changed("provision"),
+		hasCustomBinds:               extraBinds > 0,
+		allowIsolation: func(m isolation.IsolationMethod) error {
+			return sandboxPolicy.CheckIsolation(string(m))
+		},
 	})
+	if imposedBy := sandboxPolicy.ImposedBy(err); len(imposedBy) > 0 {
+		return fmt.Errorf("%w (guarantee imposed by %s)", err, strings.Join(imposedBy, ", "))
+	}
 	if err != nil {
 		return err
 	}
+	if axes.IsolationName == isolation.IsolationDocker || axes.IsolationName == isolation.IsolationPodman {
+		image := axes.Image
+		if image == "" {
+			image = isolation.DefaultContainerImage
+		}
+		if err := sandboxPolicy.CheckImage(image); err != nil {
+			return err
+		}
+	}
 
 	available, err := axes.Isolation.Available()
 	if err != nil || !available {
@@ -304,7 +534,129 @@ func runAgent(cmd *cobra.Command, args []string, options runOptions) error {
 	// restricted network up front so every dispatch path (run, terminal, dry-run)
 	// fails closed, not just isolator.Run.
 	if axes.IsolationName == isolation.IsolationNone && axes.Network != netshared.ModeHost {
-		return fmt.Errorf("isolation=none cannot restrict network egress (network=%q); use bwrap or docker", axes.Network)
+		return fmt.Errorf("isolation=none cannot restrict network egress (network=%q); use bwrap, docker, podman, landlock or native", axes.Network)
+	}
+
+	// Credential shield: the real provider credential stays in this process and
+	// the sandbox gets a per-run token, revoked as soon as the sandbox exits. A
+	// dry run never starts it. A terminal wrapper would outlive the shield, and
+	// isolation=none runs the agent as the host user, who can read the
+	// credential wherever it came from, so both combinations are refused.
+	revokeShield := func() {}
+	var shieldEndpoint string
+	if options.shieldCredentials {
+		if axes.IsolationName == isolation.IsolationNone {
+			return fmt.Errorf("credential shielding needs a sandbox; isolation=none leaves the host credential in reach of the agent (use bwrap, docker, podman, landlock or native)")
+		}
+		if options.terminal != "" {
+			return fmt.Errorf("credential shielding cannot be combined with a terminal wrapper; the shield runs in the agent-cli process")
+		}
+		provider, shieldEndpoint, revokeShield, err = prepareCredentialShield(provider, !options.dryRun, verbose)
+		if err != nil {
+			return fmt.Errorf("start credential shield: %w", err)
+		}
+		defer revokeShield()
+	}
+
+	// network none/proxy: the allowlist proxy and the host loopback forwards
+	// (the provider's local endpoints plus any requested) live in this process,
+	// so they are started here and stopped once the sandbox exits. A terminal
+	// wrapper would outlive them, so that combination is refused rather than
+	// left without its routes.
+	var relayTransport netshared.RelayTransport
+	var allowed, endpoints []string
+	stopRelay := func() {}
+	if axes.Network != netshared.ModeHost {
+		allowed = slices.Clone(options.networkProxyAllow)
+		if provider != nil {
+			endpoints = append(endpoints, provider.LocalEndpoints...)
+		}
+		if shieldEndpoint != "" {
+			endpoints = append(endpoints, shieldEndpoint)
+		}
+		endpoints = append(endpoints, options.networkForward...)
+		if options.terminal != "" {
+			if axes.Network == netshared.ModeProxy {
+				return fmt.Errorf("network=proxy cannot be combined with a terminal wrapper; the egress proxy runs in the agent-cli process")
+			}
+			if len(endpoints) > 0 {
+				return fmt.Errorf("loopback forwarding (%s) cannot be combined with a terminal wrapper; the forwarders run in the agent-cli process", strings.Join(endpoints, ", "))
+			}
+		}
+		relayTransport, stopRelay, err = prepareRelay(axes.Network, allowed, endpoints, !options.dryRun, verbose)
+		if err != nil {
+			return err
+		}
+		defer stopRelay()
+	}
+
+	// Seccomp: the compiled profile lives in a directory this process removes
+	// once the sandbox exits, so a terminal wrapper is refused like the relay.
+	stopSyscallFilter := func() {}
+	var syscallFilter isoshared.SyscallFilter
+	if axes.Seccomp != "" {
+		if options.terminal != "" {
+			return fmt.Errorf("a seccomp profile cannot be combined with a terminal wrapper; the compiled filter lives only as long as the agent-cli process")
+		}
+		syscallFilter, stopSyscallFilter, err = prepareSyscallFilter(axes.Seccomp, verbose)
+		if err != nil {
+			return err
+		}
+		defer stopSyscallFilter()
+	}
+
+	limits, explicitLimits, err := resolveLimits(options)
+	if err != nil {
+		return err
+	}
+	cgroup, removeCgroup, limitsDescription, err := prepareResourceLimits(axes.IsolationName, limits, explicitLimits, options.terminal != "", verbose)
+	if err != nil {
+		return err
+	}
+	defer removeCgroup()
+
+	// Usage is measured around the sandbox process, which a terminal wrapper
+	// starts outside this process.
+	if options.usageReport != "" && options.terminal != "" {
+		return fmt.Errorf("a usage report cannot be combined with a terminal wrapper; usage is measured by the agent-cli process")
+	}
+	if options.outputFile != "" && options.terminal != "" {
+		return fmt.Errorf("an output file cannot be combined with a terminal wrapper; output is captured by the agent-cli process")
+	}
+	prompt, err := readPrompt(options.prompt, options.promptFile, os.Stdin)
+	if err != nil {
+		return err
+	}
+	timeout, err := parseTimeout(options.timeout)
+	if err != nil {
+		return err
+	}
+	if timeout > 0 && options.terminal != "" {
+		return fmt.Errorf("a timeout cannot be combined with a terminal wrapper; the agent-cli process stops the sandbox")
+	}
+	gitIntegrity := gitIntegrityMode(options.gitIntegrity, axes.IsolationName)
+	if err := checkTerminalIntegrity(gitIntegrity, options.terminal); err != nil {
+		return err
+	}
+	if len(options.protect) > 0 && options.terminal != "" {
+		return fmt.Errorf("protected paths cannot be combined with a terminal wrapper; the agent-cli process restores what the run changed in them once it ends")
+	}
+
+	// Review: the workspace is mounted copy-on-write over layers kept in the
+	// state directory until the changes are applied or discarded.
+	var review *pendingReview
+	if options.review {
+		if options.terminal != "" {
+			return fmt.Errorf("review cannot be combined with a terminal wrapper; the changes are reviewed by the agent-cli process once the run ends")
+		}
+		if !axes.Isolation.CopyOnWrite() {
+			return fmt.Errorf("--review needs an isolation that can mount the workspace copy-on-write (bwrap, docker, podman or native), not %s", axes.IsolationName)
+		}
+		pending, err := newReview(workspace.executionDir)
+		if err != nil {
+			return err
+		}
+		review = &pending
 	}
 
 	input, err := provisionInput(axes.ProvisionName, options, agent, workspace.sourceRepoDir, workspace.executionDir)
@@ -315,7 +667,7 @@ func runAgent(cmd *cobra.Command, args []string, options runOptions) error {
 	if err != nil {
 		return err
 	}
-	customEnv, err := envutil.ParseCustomEnv(append(append([]string{}, fileConfig.CustomEnv...), options.customEnv...))
+	customEnv, err := envutil.ParseCustomEnv(options.customEnv)
 	if err != nil {
 		return fmt.Errorf("invalid custom environment: %w", err)
 	}
@@ -324,21 +676,33 @@ func runAgent(cmd *cobra.Command, args []string, options runOptions) error {
 	}
 
 	runCfg := isoshared.RunConfig{
-		HomeDir:         fileConfig.HomeDir,
+		HomeDir:         options.homeDir,
 		WorkDir:         workspace.executionDir,
 		RepoDir:         workspace.sourceRepoDir,
 		Network:         axes.Network,
+		Relay:           relayTransport,
 		HostPassthrough: axes.Passthrough,
 		Image:           axes.Image,
 		Runtime:         axes.Runtime,
+		Limits:          limits,
+		Cgroup:          cgroup,
+		SyscallFilter:   syscallFilter,
 		BindPaths:       bindPaths,
 		RoBindPaths:     roBindPaths,
+		Protect:         options.protect,
 		CustomEnv:       envutil.EnvMapToSlice(customEnv),
 		Agent:           agent,
 		Provider:        provider,
 		AgentArgs:       args,
+		Prompt:          prompt,
+		Timeout:         timeout,
 		Verbose:         verbose,
 	}
+	reviewDir := ""
+	if review != nil {
+		runCfg.Overlay = review.layers()
+		reviewDir = review.dir
+	}
 
 	// Construct the command before choosing a dispatch path. This shared
 	// preflight makes dry-run, terminal, and direct execution reject the same
@@ -348,31 +712,145 @@ func runAgent(cmd *cobra.Command, args []string, options runOptions) error {
 		return fmt.Errorf("prepare sandbox command: %w", err)
 	}
 
+	if reviewed != nil || options.output == outputJSON {
+		inputs, err := newPlanInputs(flagOptions, args, workDir, setFlags)
+		if err != nil {
+			return err
+		}
+		exposure, err := axes.Isolation.Exposure(runCfg, contribution)
+		if err != nil {
+			return fmt.Errorf("prepare sandbox plan: %w", err)
+		}
+		plan := buildPlan(inputs, planSandbox{
+			axes:         axes,
+			agent:        agent,
+			provider:     provider,
+			runCfg:       runCfg,
+			contribution: contribution,
+			exposure:     exposure,
+			command:      command,
+			proxyAllow:   allowed,
+			forwards:     endpoints,
+			placeholders: perRunPlaceholders(relayTransport, syscallFilter, shieldEndpoint, reviewDir),
+		})
+		if reviewed != nil {
+			if changed := diffPlans(*reviewed, plan); len(changed) > 0 {
+				return fmt.Errorf("%w %s: %s changed since it was reviewed", ErrPlanMismatch, options.plan, strings.Join(changed, ", "))
+			}
+			if verbose {
+				logging.LogDebug("plan " + options.plan + " still resolves the same")
+			}
+		}
+		if options.dryRun && options.output == outputJSON {
+			return printPlan(plan)
+		}
+	}
+
 	if options.dryRun {
-		return printDryRun(axes, command, agent, provider, args, workspace.displayDir)
+		return printDryRun(axes, command, agent, provider, args, workspace.displayDir, limitsDescription)
 	}
 
+	record := newAuditRecord(axes, agent, provider, options, workspace.executionDir, contribution)
 	if options.terminal != "" {
-		return executeWithTerminal(axes, runCfg, contribution, workspace.executionDir, verbose, options)
+		exitCode, session, err := executeWithTerminal(axes, runCfg, contribution, workspace.executionDir, verbose, options)
+		record.TerminalSession = session
+		finishAudit(record, exitCode, err, options.auditLog)
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+		return nil
 	}
 
-	exitCode, err := axes.Isolation.Run(runCfg, contribution)
+	if review != nil {
+		record.ID = review.ID
+		if err := review.create(); err != nil {
+			return err
+		}
+	}
+	guard, err := guardRepository(gitIntegrity, workspace.executionDir)
 	if err != nil {
 		return err
 	}
+	protectGuard, err := guardProtected(options.protect, workspace.executionDir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = protectGuard.Close() }()
+	var usage isoshared.Usage
+	runCfg.Usage = &usage
+	runCfg.NoTTY = !isoshared.StdinIsTerminal()
+	runCfg.RunID = record.ID
+	stopCapture := func() error { return nil }
+	if options.outputFile != "" {
+		if stopCapture, err = captureOutput(options.outputFile); err != nil {
+			return err
+		}
+	}
+	exitCode, err := axes.Isolation.Run(runCfg, contribution)
+	if captureErr := stopCapture(); captureErr != nil {
+		logging.LogWarning(captureErr.Error())
+	}
+	// os.Exit skips deferred calls, so the run token, the relay's host side,
+	// the seccomp files and the cgroup are released explicitly.
+	revokeShield()
+	stopRelay()
+	stopSyscallFilter()
+	removeCgroup()
+	// Under block a tampered repository fails a run that otherwise ended well.
+	reverted, integrityErr := checkRepository(guard, gitIntegrity)
+	record.GitIntegrity = reverted
+	protected, protectErr := checkProtected(protectGuard)
+	record.Protected = protected
+	for _, checkErr := range []error{integrityErr, protectErr} {
+		if err == nil {
+			err = checkErr
+		} else if checkErr != nil {
+			logging.LogError(checkErr.Error())
+		}
+	}
+	finishAudit(record, exitCode, err, options.auditLog)
+	reviewPending := review != nil && reviewRun(*review, os.Stdin, os.Stderr, isoshared.StdinIsTerminal())
+	// Applied changes may reach the guarded state too.
+	if review != nil && !reviewPending {
+		if _, integrityErr := checkRepository(guard, gitIntegrity); err == nil {
+			err = integrityErr
+		}
+		if _, protectErr := checkProtected(protectGuard); err == nil {
+			err = protectErr
+		}
+	}
+	// os.Exit skips deferred calls too, so the protected copies go here.
+	if closeErr := protectGuard.Close(); closeErr != nil {
+		logging.LogWarning(fmt.Sprintf("Could not remove the protected paths' copies: %v", closeErr))
+	}
+	if workspace.vcs != nil {
+		switch {
+		case !reviewPending:
+			cleanupWorktree(workspace.vcs, workspace.sourceRepoDir, workspace.executionDir, options.worktreeCleanup, err == nil && exitCode == 0)
+		case options.worktreeCleanup == cleanupOnSuccess || options.worktreeCleanup == cleanupAlways:
+			logging.LogWarning(fmt.Sprintf("Kept worktree %s: review %s of its changes is pending", workspace.executionDir, review.ID))
+		}
+	}
+	timedOut := errors.Is(err, isoshared.ErrTimedOut)
+	if err != nil && !timedOut {
+		return err
+	}
+	reportUsage(options.usageReport, newUsageReport(axes, agent, provider, usage), usage.String())
+	if timedOut {
+		logging.LogError(err.Error())
+	}
 	if exitCode != 0 {
 		os.Exit(exitCode)
 	}
 	return nil
 }
 
-// resolveProvider resolves the model provider from the flag, falling back to the
-// file config's provider.
-func resolveProvider(agentType types.AgentType, optionProvider, fileProvider string) (*types.ModelProvider, error) {
-	name := optionProvider
-	if name == "" {
-		name = fileProvider
-	}
+// resolveProvider resolves the named model provider; no name is the agent's
+// own provider.
+func resolveProvider(agentType types.AgentType, name string) (*types.ModelProvider, error) {
 	if name == "" {
 		return nil, nil
 	}
@@ -385,10 +863,13 @@ func resolveProvider(agentType types.AgentType, optionProvider, fileProvider str
 	return provider, nil
 }
 
+// preparedWorkspace is where a run executes; vcs is set when that is a
+// worktree of sourceRepoDir.
 type preparedWorkspace struct {
 	sourceRepoDir string
 	executionDir  string
 	displayDir    string
+	vcs           wsshared.VCS
 }
 
 // prepareWorkspace validates and optionally creates the requested workspace.
@@ -410,9 +891,9 @@ func prepareWorkspace(options runOptions, workDir string, verbose bool) (prepare
 		}
 	}
 
-	vcsType := wsp.VCSType(options.vcs)
-	if !vcsType.IsValid() {
-		return preparedWorkspace{}, fmt.Errorf("unknown --vcs %q; valid values: git, jj", options.vcs)
+	vcs, err := newVCS(options.vcs, wsshared.NewExecRunner())
+	if err != nil {
+		return preparedWorkspace{}, err
 	}
 
 	wtDir := options.worktreeDir
@@ -427,12 +908,6 @@ func prepareWorkspace(options runOptions, workDir string, verbose bool) (prepare
 		return preparedWorkspace{sourceRepoDir: workDir, executionDir: workDir, displayDir: displayDir}, nil
 	}
 
-	runner := wsshared.NewExecRunner()
-	var vcs wsshared.VCS = git.New(runner)
-	if vcsType == wsp.VCSJujutsu {
-		vcs = jj.New(runner)
-	}
-
 	created, err := vcs.Setup(wsshared.Config{
 		SourceBranch: options.worktreeSourceBranch,
 		Branch:       options.worktreeBranch,
@@ -441,7 +916,7 @@ func prepareWorkspace(options runOptions, workDir string, verbose bool) (prepare
 	if err != nil {
 		return preparedWorkspace{}, err
 	}
-	return preparedWorkspace{sourceRepoDir: workDir, executionDir: created, displayDir: created}, nil
+	return preparedWorkspace{sourceRepoDir: workDir, executionDir: created, displayDir: created, vcs: vcs}, nil
 }
 
 // provisionInput assembles the neutral provisioner Input. The nix source is built
@@ -491,18 +966,18 @@ func validateAgentExecutable(axes resolvedAxes, agent *types.AgentConfig, contri
 				return fmt.Errorf("agent executable %q is not available on host PATH: %w", agent.Binary, err)
 			}
 		}
-	case isolation.IsolationBwrap:
+	case isolation.IsolationBwrap, isolation.IsolationLandlock, isolation.IsolationNative:
 		if axes.ProvisionName == provision.ProvisionNone {
 			if !axes.Passthrough {
-				return fmt.Errorf("bwrap with provision=none cannot supply agent executable %q while host passthrough is disabled; use provision=nix or enable --isolation-bwrap-passthrough", agent.Binary)
+				return fmt.Errorf("%[1]s with provision=none cannot supply agent executable %[2]q while host passthrough is disabled; use provision=nix or enable --isolation-%[1]s-passthrough", axes.IsolationName, agent.Binary)
 			}
 			if _, err := exec.LookPath(agent.Binary); err != nil {
 				return fmt.Errorf("agent executable %q is not available on passthrough PATH: %w", agent.Binary, err)
 			}
 		}
-	case isolation.IsolationDocker:
+	case isolation.IsolationDocker, isolation.IsolationPodman:
 		if axes.ProvisionName == provision.ProvisionNone && (axes.Image == "" || axes.Image == isolation.DefaultContainerImage) {
-			return fmt.Errorf("the default docker image does not contain agent executable %q; use provision=nix or a digest-pinned --image that contains it", agent.Binary)
+			return fmt.Errorf("the default %s image does not contain agent executable %q; use provision=nix or a digest-pinned --image that contains it", axes.IsolationName, agent.Binary)
 		}
 	}
 	return nil
@@ -518,18 +993,19 @@ func contributionContainsExecutable(contribution provision.Contribution, binary
 	return false
 }
 
-// executeWithTerminal runs the resolved cell inside a terminal wrapper.
-func executeWithTerminal(axes resolvedAxes, runCfg isoshared.RunConfig, contribution provision.Contribution, workDir string, verbose bool, options runOptions) error {
+// executeWithTerminal runs the resolved cell inside a terminal wrapper and
+// returns its exit code and the session it ran in.
+func executeWithTerminal(axes resolvedAxes, runCfg isoshared.RunConfig, contribution provision.Contribution, workDir string, verbose bool, options runOptions) (int, string, error) {
 	terminalType := termshared.TerminalType(options.terminal)
 	executor := terminal.GetExecutor(terminalType)
 	if executor == nil {
 		logging.LogError(fmt.Sprintf("Unknown terminal type: %s", options.terminal))
 		logging.LogInfo(fmt.Sprintf("Available types: %v", terminal.GetAvailableTypes()))
-		return fmt.Errorf("unknown terminal type: %s", options.terminal)
+		return 0, "", fmt.Errorf("unknown terminal type: %s", options.terminal)
 	}
 	if !executor.IsAvailable() {
 		logging.LogError(fmt.Sprintf("Terminal type %s is not available (not installed)", options.terminal))
-		return fmt.Errorf("terminal type %s is not available", options.terminal)
+		return 0, "", fmt.Errorf("terminal type %s is not available", options.terminal)
 	}
 
 	terminalConfig := &termshared.TerminalConfig{
@@ -539,12 +1015,12 @@ func executeWithTerminal(axes resolvedAxes, runCfg isoshared.RunConfig, contribu
 		Detach:      options.terminalDetach,
 	}
 
-	// Each isolator owns its terminal launch: bwrap/docker bake their env into the
+	// Each isolator owns its terminal launch: bwrap/docker/podman bake their env into the
 	// command and return the host env; none returns the agent command with its full
 	// resolved environment (provider + custom + the provisioner's PATH/env).
 	fullCommand, env, err := axes.Isolation.TerminalCommand(runCfg, contribution)
 	if err != nil {
-		return fmt.Errorf("prepare terminal command: %w", err)
+		return 0, "", fmt.Errorf("prepare terminal command: %w", err)
 	}
 
 	if verbose {
@@ -552,17 +1028,11 @@ func executeWithTerminal(axes resolvedAxes, runCfg isoshared.RunConfig, contribu
 	}
 
 	exitCode, err := executor.Execute(terminalConfig, fullCommand, env, workDir, verbose)
-	if err != nil {
-		return err
-	}
-	if exitCode != 0 {
-		os.Exit(exitCode)
-	}
-	return nil
+	return exitCode, terminalConfig.SessionName, err
 }
 
 // printDryRun displays what would be executed without running it.
-func printDryRun(axes resolvedAxes, command []string, agent *types.AgentConfig, provider *types.ModelProvider, args []string, workDir string) error {
+func printDryRun(axes resolvedAxes, command []string, agent *types.AgentConfig, provider *types.ModelProvider, args []string, workDir, limits string) error {
 	logging.LogInfo("Dry run - would execute:")
 
 	if axes.IsolationName == isolation.IsolationNone {
@@ -578,6 +1048,8 @@ func printDryRun(axes resolvedAxes, command []string, agent *types.AgentConfig,
 		}
 	}
 
+	logging.LogInfo("  Resource limits: " + limits)
+
 	if len(command) > 0 {
 		fmt.Println(strings.Join(command, " "))
 	}
@@ -10,8 +10,12 @@ import (
 
 	"github.com/spf13/cobra"
 
+	cfgpkg "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/config"
 	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
 	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	provshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/provision/shared"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/sandbox"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/sandbox/plugins"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
@@ -23,17 +27,24 @@ func TestParseNetwork(t *testing.T) {
 	if _, err := netshared.ParseMode("host"); err != nil {
 		t.Errorf("ParseMode(host) = %v", err)
 	}
-	if _, err := netshared.ParseMode("proxy"); !errors.Is(err, netshared.ErrProxyEnforcementUnavailable) {
-		t.Errorf("ParseMode(proxy) error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+	if _, err := netshared.ParseMode("proxy"); err != nil {
+		t.Errorf("ParseMode(proxy) = %v", err)
 	}
 	if _, err := netshared.ParseMode("bogus"); err == nil {
 		t.Error("ParseMode(bogus) = nil, want error")
 	}
 }
 
-func TestResolveAxes_ProxyFailsClosed(t *testing.T) {
-	if _, err := resolveAxes(flags{network: "proxy"}); !errors.Is(err, netshared.ErrProxyEnforcementUnavailable) {
-		t.Fatalf("resolveAxes(proxy) error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+func TestResolveAxes_ProxySatisfiesEgressRestricted(t *testing.T) {
+	axes, err := resolveAxes(flags{isolationChanged: true, isolation: "bwrap", network: "proxy", requireEgressRestricted: true})
+	if err != nil {
+		t.Fatalf("resolveAxes(proxy) error = %v", err)
+	}
+	if axes.Network != netshared.ModeProxy {
+		t.Errorf("axes.Network = %q, want proxy", axes.Network)
+	}
+	if _, err := resolveAxes(flags{network: "host", requireEgressRestricted: true}); !errors.Is(err, policy.ErrEgressUnrestricted) {
+		t.Errorf("resolveAxes(host) error = %v, want %v", err, policy.ErrEgressUnrestricted)
 	}
 }
 
@@ -50,6 +61,89 @@ func TestResolveAxes_DockerRuntimeWiresKernelIsolation(t *testing.T) {
 	}
 }
 
+// Each container isolator reads its own runtime knob, so a docker runtime never
+// leaks into a podman run.
+func TestResolveAxes_PodmanRuntimeWiresKernelIsolation(t *testing.T) {
+	axes, err := resolveAxes(flags{isolationChanged: true, isolation: "podman", isolationPodmanRuntime: "krun", isolationDockerRuntime: "runsc", requireKernelIsolation: true})
+	if err != nil {
+		t.Fatalf("resolveAxes = %v", err)
+	}
+	if axes.Runtime != "krun" {
+		t.Errorf("axes.Runtime = %q, want krun", axes.Runtime)
+	}
+	if _, err := resolveAxes(flags{isolationChanged: true, isolation: "podman", isolationDockerRuntime: "runsc", requireKernelIsolation: true}); !errors.Is(err, policy.ErrKernelIsolationUnmet) {
+		t.Errorf("resolveAxes(podman, docker runtime) error = %v, want %v", err, policy.ErrKernelIsolationUnmet)
+	}
+}
+
+// The landlock isolator reads its own passthrough knob, which forfeits
+// host-tools-unreachable exactly as bwrap's does.
+func TestResolveAxes_LandlockPassthroughForfeitsHiddenHost(t *testing.T) {
+	if _, err := resolveAxes(flags{isolationChanged: true, isolation: "landlock", isolationBwrapPassthrough: true, requireHostToolsUnreachable: true}); err != nil {
+		t.Errorf("resolveAxes(landlock, bwrap passthrough) error = %v, want nil", err)
+	}
+	axes, err := resolveAxes(flags{isolation: "landlock", isolationLandlockPassthrough: true})
+	if err != nil || !axes.Passthrough {
+		t.Fatalf("resolveAxes(landlock passthrough) = %+v, %v, want passthrough", axes, err)
+	}
+	if _, err := resolveAxes(flags{isolationChanged: true, isolation: "landlock", isolationLandlockPassthrough: true, requireHostToolsUnreachable: true}); !errors.Is(err, policy.ErrHostToolsReachable) {
+		t.Errorf("resolveAxes error = %v, want %v", err, policy.ErrHostToolsReachable)
+	}
+	if _, err := resolveAxes(flags{isolationChanged: true, isolation: "landlock", network: "none", requireEgressRestricted: true}); !errors.Is(err, policy.ErrEgressUnrestricted) {
+		t.Errorf("resolveAxes(landlock, none) error = %v, want %v", err, policy.ErrEgressUnrestricted)
+	}
+}
+
+// The native isolator enforces network none in its own namespace and reads its
+// own passthrough knob.
+func TestResolveAxes_NativePassthroughAndEgress(t *testing.T) {
+	if _, err := resolveAxes(flags{isolationChanged: true, isolation: "native", network: "none", requireEgressRestricted: true, requireHostToolsUnreachable: true}); err != nil {
+		t.Errorf("resolveAxes(native, none) error = %v, want nil", err)
+	}
+	if _, err := resolveAxes(flags{isolationChanged: true, isolation: "native", isolationNativePassthrough: true, requireHostToolsUnreachable: true}); !errors.Is(err, policy.ErrHostToolsReachable) {
+		t.Errorf("resolveAxes(native passthrough) error = %v, want %v", err, policy.ErrHostToolsReachable)
+	}
+}
+
+func TestResolveAxes_SeccompProfile(t *testing.T) {
+	axes, err := resolveAxes(flags{isolationChanged: true, isolation: "bwrap", seccompProfile: "strict", requireSyscallFilter: true})
+	if err != nil || axes.Seccomp != "strict" {
+		t.Errorf("resolveAxes(bwrap, strict) = (%q, %v), want the profile", axes.Seccomp, err)
+	}
+	if _, err := resolveAxes(flags{isolation: "bwrap", seccompProfile: "lenient"}); !errors.Is(err, isoshared.ErrUnknownSeccompProfile) {
+		t.Errorf("resolveAxes(unknown profile) error = %v, want %v", err, isoshared.ErrUnknownSeccompProfile)
+	}
+	if _, err := resolveAxes(flags{isolation: "landlock", seccompProfile: "default"}); err == nil || !strings.Contains(err.Error(), "use bwrap, docker or podman") {
+		t.Errorf("resolveAxes(landlock, default) error = %v, want a hint naming the filtering isolators", err)
+	}
+	if _, err := resolveAxes(flags{isolationChanged: true, isolation: "bwrap", requireSyscallFilter: true}); !errors.Is(err, policy.ErrSyscallFilterUnmet) {
+		t.Errorf("resolveAxes(no profile) error = %v, want %v", err, policy.ErrSyscallFilterUnmet)
+	}
+}
+
+// availableIsolator and availableProvisioner report a plugin available
+// whatever the host has installed, so the solver's pick does not depend on it.
+type availableIsolator struct{ isoshared.Isolator }
+
+func (availableIsolator) Available() (bool, error) { return true, nil }
+
+type availableProvisioner struct{ provshared.Provisioner }
+
+func (availableProvisioner) Available() (bool, error) { return true, nil }
+
+func everythingAvailable() *sandbox.Registry {
+	reg, out := plugins.DefaultRegistry(), sandbox.NewRegistry()
+	for _, m := range reg.IsolationMethods() {
+		iso, _ := reg.Isolator(m)
+		out.RegisterIsolator(m, func() isoshared.Isolator { return availableIsolator{iso} })
+	}
+	for _, m := range reg.ProvisionMethods() {
+		prov, _ := reg.Provisioner(m)
+		out.RegisterProvisioner(m, func() provshared.Provisioner { return availableProvisioner{prov} })
+	}
+	return out
+}
+
 func TestResolveAxes_PinnedComboDefault(t *testing.T) {
 	axes, err := resolveAxes(flags{requirePinnedProvision: true})
 	if err != nil {
@@ -60,6 +154,56 @@ func TestResolveAxes_PinnedComboDefault(t *testing.T) {
 	}
 }
 
+// The cheapest satisfying cell is only suggested: a policy never moves a run
+// onto a cell nobody chose, least of all the unconfined none × nix.
+func TestResolveAxes_PolicyNeverPicksACell(t *testing.T) {
+	axes, err := resolveAxesIn(everythingAvailable(), flags{isolation: "native", isolationChanged: true, requireHostToolsUnreachable: true})
+	if err != nil || axes.IsolationName != isolation.IsolationNative || axes.ProvisionName != provision.ProvisionNone {
+		t.Errorf("resolveAxesIn(native) = (%s × %s, %v), want the chosen native × none", axes.IsolationName, axes.ProvisionName, err)
+	}
+	_, err = resolveAxesIn(everythingAvailable(), flags{requireHostToolsUnreachable: true})
+	if !errors.Is(err, policy.ErrHostToolsReachable) || !strings.Contains(err.Error(), "--isolation landlock --provision none") {
+		t.Errorf("resolveAxesIn(no cell chosen) error = %v, want the default cell refused with landlock suggested", err)
+	}
+}
+
+func TestResolveAxes_ReportsEveryUnmetGuaranteeAndASuggestion(t *testing.T) {
+	_, err := resolveAxesIn(everythingAvailable(), flags{
+		isolation: "none", isolationChanged: true,
+		requirePinnedProvision: true, requireHostToolsUnreachable: true,
+	})
+	for _, want := range []error{policy.ErrPinnedProvisionUnmet, policy.ErrHostToolsReachable} {
+		if !errors.Is(err, want) {
+			t.Errorf("resolveAxesIn() error = %v, want errors.Is %v", err, want)
+		}
+	}
+	if err == nil || !strings.Contains(err.Error(), "--isolation landlock --provision nix") {
+		t.Errorf("resolveAxesIn() error = %v, want the cheapest satisfying cell suggested", err)
+	}
+
+	// No cell can restrict egress on the host network.
+	_, err = resolveAxesIn(everythingAvailable(), flags{requireEgressRestricted: true})
+	if !errors.Is(err, policy.ErrEgressUnrestricted) || !strings.Contains(err.Error(), "no available cell satisfies") {
+		t.Errorf("resolveAxesIn(host network) error = %v, want the unmet guarantee and no suggestion", err)
+	}
+}
+
+func TestResolveAxes_SkipsIsolationsThePolicyForbids(t *testing.T) {
+	onlyBwrap := func(m isolation.IsolationMethod) error {
+		if m != isolation.IsolationBwrap {
+			return cfgpkg.ErrPolicyForbids
+		}
+		return nil
+	}
+	_, err := resolveAxesIn(everythingAvailable(), flags{isolation: "bwrap", isolationChanged: true, provisionChanged: true, requirePinnedProvision: true, allowIsolation: onlyBwrap})
+	if err == nil || !strings.Contains(err.Error(), "--isolation bwrap --provision nix") {
+		t.Errorf("resolveAxesIn() error = %v, want bwrap × nix suggested past the cheaper, forbidden none × nix", err)
+	}
+	if _, err := resolveAxesIn(everythingAvailable(), flags{isolation: "none", isolationChanged: true, allowIsolation: onlyBwrap}); !errors.Is(err, cfgpkg.ErrPolicyForbids) {
+		t.Errorf("resolveAxesIn(isolation none) error = %v, want %v", err, cfgpkg.ErrPolicyForbids)
+	}
+}
+
 func TestPrepareWorkspaceDryRunDoesNotCreateDirectory(t *testing.T) {
 	repoDir := t.TempDir()
 	target := filepath.Join(repoDir, "worktree")
@@ -158,6 +302,60 @@ func TestRunAgentRejectsMalformedCustomEnvironment(t *testing.T) {
 	}
 }
 
+// withPolicy holds the runs of the test to a sandbox policy file with content.
+func withPolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	previous := policyPaths
+	policyPaths = func() ([]string, error) { return []string{path}, nil }
+	t.Cleanup(func() { policyPaths = previous })
+	return path
+}
+
+func TestRunAgentFailsClosedOnTheSandboxPolicy(t *testing.T) {
+	workDir := t.TempDir()
+	configPath := filepath.Join(workDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("bindPaths: [.]\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		policy string
+		modify func(*runOptions)
+		want   string
+	}{
+		{"agent", "allowedAgents: [opencode]\n", nil, `agent "claude"`},
+		{"provider", "allowedProviders: [default]\n", func(o *runOptions) { o.provider = "gemini" }, `provider "gemini"`},
+		{"binds from the config", "maxExtraBinds: 0\n", nil, "1 extra binds exceed maxExtraBinds 0"},
+		{"isolation", "allowedIsolations: [bwrap]\n", nil, `isolation "none"`},
+		{"image", "allowedImages: [\"ghcr.io/acme/*\"]\n", func(o *runOptions) { o.isolation = "docker" }, "matches none of allowedImages"},
+		{"guarantee", "requireEgressRestricted: true\n", nil, "guarantee imposed by"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := withPolicy(t, tt.policy)
+			command := &cobra.Command{}
+			command.Flags().Bool("verbose", false, "")
+			command.Flags().String("isolation", "none", "")
+			command.Flags().String("provision", "none", "")
+			if tt.name != "guarantee" {
+				_ = command.Flags().Set("isolation", "none")
+			}
+			options := runOptions{agent: "claude", isolation: "none", provision: "none", network: "host", workDir: workDir, config: configPath, vcs: "git", dryRun: true}
+			if tt.modify != nil {
+				tt.modify(&options)
+			}
+			err := runAgent(command, nil, options)
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), path) {
+				t.Errorf("runAgent() error = %v, want %q naming %s", err, tt.want, path)
+			}
+		})
+	}
+}
+
 func TestRunAgentRejectsUnknownAgent(t *testing.T) {
 	command := &cobra.Command{}
 	command.Flags().Bool("verbose", false, "")
@@ -169,8 +367,8 @@ func TestRunAgentRejectsUnknownAgent(t *testing.T) {
 	}
 }
 
-func TestResolveProviderPrefersCommandOption(t *testing.T) {
-	provider, err := resolveProvider(types.AgentClaude, "gemini", "glm")
+func TestResolveProviderResolvesTheNamedPreset(t *testing.T) {
+	provider, err := resolveProvider(types.AgentClaude, "gemini")
 
 	if err != nil {
 		t.Fatalf("resolveProvider() error = %v", err)
@@ -178,10 +376,13 @@ func TestResolveProviderPrefersCommandOption(t *testing.T) {
 	if provider == nil || provider.Name != "gemini" {
 		t.Fatalf("resolveProvider() = %+v, want gemini", provider)
 	}
+	if provider, err := resolveProvider(types.AgentClaude, ""); provider != nil || err != nil {
+		t.Fatalf("resolveProvider(\"\") = (%+v, %v), want the agent's own provider", provider, err)
+	}
 }
 
 func TestResolveProviderRejectsUnknownProvider(t *testing.T) {
-	provider, err := resolveProvider(types.AgentClaude, "unknown", "")
+	provider, err := resolveProvider(types.AgentClaude, "unknown")
 
 	if err == nil || provider != nil {
 		t.Fatalf("resolveProvider() = (%+v, %v), want nil provider and error", provider, err)
@@ -260,6 +461,17 @@ func TestValidateAgentExecutableRejectsUnprovisionedDefaultDockerImage(t *testin
 	}
 }
 
+func TestValidateAgentExecutableNamesTheLandlockPassthroughFlag(t *testing.T) {
+	agent := &types.AgentConfig{Binary: "claude", NixPackage: "claude-code"}
+	axes := resolvedAxes{IsolationName: isolation.IsolationLandlock, ProvisionName: provision.ProvisionNone}
+
+	err := validateAgentExecutable(axes, agent, provision.Contribution{})
+
+	if err == nil || !strings.Contains(err.Error(), "--isolation-landlock-passthrough") {
+		t.Fatalf("validateAgentExecutable() error = %v, want the landlock passthrough flag", err)
+	}
+}
+
 func TestValidateAgentExecutableRejectsUnprovisionedBwrapWithoutPassthrough(t *testing.T) {
 	agent := &types.AgentConfig{Binary: "claude", NixPackage: "claude-code"}
 	axes := resolvedAxes{IsolationName: isolation.IsolationBwrap, ProvisionName: provision.ProvisionNone}
@@ -305,7 +517,7 @@ func TestValidateAgentExecutableRejectsNixClosureWithoutAgent(t *testing.T) {
 func TestExecuteWithTerminalRejectsUnknownType(t *testing.T) {
 	options := runOptions{terminal: "unknown"}
 
-	err := executeWithTerminal(resolvedAxes{}, isoshared.RunConfig{}, provision.Contribution{}, t.TempDir(), false, options)
+	_, _, err := executeWithTerminal(resolvedAxes{}, isoshared.RunConfig{}, provision.Contribution{}, t.TempDir(), false, options)
 
 	if err == nil {
 		t.Fatal("executeWithTerminal() error = nil, want unknown-terminal error")
@@ -345,6 +557,7 @@ func TestFlagsPolicyMapsGuaranteesIndependently(t *testing.T) {
 		{"host tools", flags{requireHostToolsUnreachable: true}, policy.SandboxPolicy{RequireHostToolsUnreachable: true}},
 		{"egress", flags{requireEgressRestricted: true}, policy.SandboxPolicy{RequireEgressRestricted: true}},
 		{"kernel", flags{requireKernelIsolation: true}, policy.SandboxPolicy{RequireKernelIsolation: true}},
+		{"syscall filter", flags{requireSyscallFilter: true}, policy.SandboxPolicy{RequireSyscallFilter: true}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
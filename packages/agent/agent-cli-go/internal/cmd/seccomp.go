@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// prepareSyscallFilter compiles the named seccomp profile into a private
+// directory the isolator loads it from: an OCI profile for docker and podman
+// and a BPF program for bwrap. The returned remove function is idempotent and
+// deletes the directory. An empty profile applies no filter.
+func prepareSyscallFilter(profile string, verbose bool) (isoshared.SyscallFilter, func(), error) {
+	if profile == "" {
+		return isoshared.SyscallFilter{}, func() {}, nil
+	}
+	dir, err := os.MkdirTemp("", "agent-cli-seccomp-")
+	if err != nil {
+		return isoshared.SyscallFilter{}, nil, fmt.Errorf("create seccomp directory: %w", err)
+	}
+	remove := sync.OnceFunc(func() { _ = os.RemoveAll(dir) })
+	filter, err := isoshared.WriteSyscallFilter(dir, profile)
+	if err != nil {
+		remove()
+		return isoshared.SyscallFilter{}, nil, err
+	}
+	if verbose {
+		logging.LogDebug("seccomp profile " + profile + " compiled to " + dir)
+	}
+	return filter, remove, nil
+}
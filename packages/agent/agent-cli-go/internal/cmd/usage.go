@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// usageReport is the --usage-report JSON document: the run's cell and what it
+// consumed. Fields the isolator could not measure are omitted.
+type usageReport struct {
+	Agent            string  `json:"agent"`
+	Provider         string  `json:"provider,omitempty"`
+	Isolation        string  `json:"isolation"`
+	Provision        string  `json:"provision"`
+	ExitCode         int     `json:"exitCode"`
+	SetupFailed      bool    `json:"setupFailed,omitempty"`
+	WallSeconds      float64 `json:"wallSeconds"`
+	UserCPUSeconds   float64 `json:"userCpuSeconds,omitempty"`
+	SystemCPUSeconds float64 `json:"systemCpuSeconds,omitempty"`
+	MaxRSSBytes      int64   `json:"maxRssBytes,omitempty"`
+	PeakMemoryBytes  int64   `json:"peakMemoryBytes,omitempty"`
+	PeakPids         int64   `json:"peakPids,omitempty"`
+}
+
+// newUsageReport pairs usage with the cell that produced it.
+func newUsageReport(axes resolvedAxes, agent *types.AgentConfig, provider *types.ModelProvider, usage isoshared.Usage) usageReport {
+	report := usageReport{
+		Agent:            string(agent.Type),
+		Isolation:        string(axes.IsolationName),
+		Provision:        string(axes.ProvisionName),
+		ExitCode:         usage.ExitCode,
+		SetupFailed:      usage.SetupFailed,
+		WallSeconds:      usage.WallTime.Seconds(),
+		UserCPUSeconds:   usage.UserCPU.Seconds(),
+		SystemCPUSeconds: usage.SystemCPU.Seconds(),
+		MaxRSSBytes:      usage.MaxRSSBytes,
+		PeakMemoryBytes:  usage.PeakMemoryBytes,
+		PeakPids:         usage.PeakPids,
+	}
+	if provider != nil {
+		report.Provider = provider.Name
+	}
+	return report
+}
+
+// reportUsage prints the run's usage summary and, when path is set, writes the
+// JSON report there. A report that cannot be written is a warning: the run has
+// already happened and its exit code still matters more.
+func reportUsage(path string, report usageReport, summary string) {
+	logging.LogInfo("Resource usage: " + summary)
+	if path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		logging.LogWarning(fmt.Sprintf("write usage report %s: %v", path, err))
+	}
+}
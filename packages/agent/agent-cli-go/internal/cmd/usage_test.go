@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+func TestReportUsageWritesTheJSONReport(t *testing.T) {
+	axes := resolvedAxes{IsolationName: isolation.IsolationDocker, ProvisionName: provision.ProvisionNone}
+	usage := isoshared.Usage{ExitCode: 1, WallTime: 90 * time.Second, PeakMemoryBytes: 1 << 30, PeakPids: 40}
+	report := newUsageReport(axes, &types.AgentConfig{Type: types.AgentClaude}, &types.ModelProvider{Name: "gemini"}, usage)
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	reportUsage(path, report, usage.String())
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read usage report: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("usage report is not JSON: %v", err)
+	}
+	want := map[string]any{
+		"agent": "claude", "provider": "gemini", "isolation": "docker", "provision": "none",
+		"exitCode": 1.0, "wallSeconds": 90.0, "peakMemoryBytes": float64(1 << 30), "peakPids": 40.0,
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("report[%q] = %v, want %v", key, fields[key], value)
+		}
+	}
+	// The engines report no CPU time, so the report leaves it out.
+	if _, ok := fields["userCpuSeconds"]; ok {
+		t.Errorf("report = %s, want no userCpuSeconds", data)
+	}
+}
+
+func TestReportUsageWithoutAPathWritesNothing(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	reportUsage("", usageReport{}, "exit 0, wall 0s")
+
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Errorf("reportUsage(\"\") wrote %d files, want none", len(entries))
+	}
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/git"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/jj"
+	wsshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/shared"
+	wsp "github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/workspace"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
+)
+
+// The --worktree-cleanup values of run.
+const (
+	cleanupOnSuccess = "on-success"
+	cleanupAlways    = "always"
+	cleanupNever     = "never"
+)
+
+// ErrWorktreeNotFound reports a worktree argument that matches no checkout.
+var ErrWorktreeNotFound = errors.New("worktree not found")
+
+// newVCS returns the workspace leaf of the named VCS.
+func newVCS(vcs string, runner wsshared.Runner) (wsshared.VCS, error) {
+	switch wsp.VCSType(vcs) {
+	case wsp.VCSGit:
+		return git.New(runner), nil
+	case wsp.VCSJujutsu:
+		return jj.New(runner), nil
+	}
+	return nil, fmt.Errorf("unknown --vcs %q; valid values: git, jj", vcs)
+}
+
+// worktreeOptions are the flags every worktree subcommand shares.
+type worktreeOptions struct {
+	vcs     string
+	workDir string
+}
+
+func (o *worktreeOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.vcs, "vcs", "git", "VCS type of the repository (git, jj)")
+	cmd.Flags().StringVarP(&o.workDir, "work-dir", "w", "", "Repository directory (default the current directory)")
+}
+
+// open returns the VCS leaf and the absolute repository directory.
+func (o worktreeOptions) open() (wsshared.VCS, string, error) {
+	vcs, err := newVCS(o.vcs, wsshared.NewExecRunner())
+	if err != nil {
+		return nil, "", err
+	}
+	repoDir, err := filepath.Abs(o.workDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve work directory %q: %w", o.workDir, err)
+	}
+	return vcs, repoDir, nil
+}
+
+// worktreeEntry is one checkout of the listing with the last run in it.
+type worktreeEntry struct {
+	Name    string       `json:"name,omitempty"`
+	Dir     string       `json:"dir"`
+	Branch  string       `json:"branch,omitempty"`
+	Main    bool         `json:"main"`
+	Dirty   bool         `json:"dirty"`
+	Missing bool         `json:"missing"`
+	LastRun *auditRecord `json:"lastRun,omitempty"`
+}
+
+func newWorktreeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "worktree",
+		Short: "Manage the worktrees runs are given",
+		Long: `List and clean up the git worktrees or jj workspaces that run
+--worktree-branch creates. A worktree with uncommitted changes is only removed
+with --force.`,
+	}
+	cmd.AddCommand(newWorktreeListCommand(), newWorktreeRemoveCommand(), newWorktreePruneCommand())
+	return cmd
+}
+
+func newWorktreeListCommand() *cobra.Command {
+	var (
+		options worktreeOptions
+		output  = outputText
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the worktrees of the repository",
+		Long: `List the repository's worktrees with their branch, whether they hold
+uncommitted changes or are gone, and the last run recorded in them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if output != outputText && output != outputJSON {
+				return fmt.Errorf("invalid --output %q (want %s or %s)", output, outputText, outputJSON)
+			}
+			vcs, repoDir, err := options.open()
+			if err != nil {
+				return err
+			}
+			checkouts, err := vcs.List(repoDir)
+			if err != nil {
+				return err
+			}
+			records, err := loadHistory("")
+			if err != nil {
+				return err
+			}
+			return printWorktrees(cmd.OutOrStdout(), worktreeEntries(checkouts, records), output)
+		},
+	}
+	options.addFlags(cmd)
+	cmd.Flags().StringVarP(&output, "output", "o", output, "Listing format (text, json)")
+	return cmd
+}
+
+func newWorktreeRemoveCommand() *cobra.Command {
+	var (
+		options worktreeOptions
+		force   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "remove <dir|branch|name>",
+		Short: "Remove a worktree",
+		Long: `Remove a worktree, named by its directory, its branch or, for jj, its
+workspace name. The branch itself is kept. A worktree with uncommitted changes
+is refused unless --force is given; the main worktree is always refused.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			vcs, repoDir, err := options.open()
+			if err != nil {
+				return err
+			}
+			checkouts, err := vcs.List(repoDir)
+			if err != nil {
+				return err
+			}
+			checkout, err := findCheckout(checkouts, args[0])
+			if err != nil {
+				return err
+			}
+			if err := vcs.Remove(repoDir, checkout, force); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %s\n", checkoutLabel(checkout))
+			return nil
+		},
+	}
+	options.addFlags(cmd)
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Remove the worktree even with uncommitted changes")
+	return cmd
+}
+
+func newWorktreePruneCommand() *cobra.Command {
+	var options worktreeOptions
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Forget the worktrees whose directory is gone",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			vcs, repoDir, err := options.open()
+			if err != nil {
+				return err
+			}
+			pruned, err := vcs.Prune(repoDir)
+			if err != nil {
+				return err
+			}
+			if len(pruned) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Nothing to prune")
+			}
+			for _, checkout := range pruned {
+				fmt.Fprintf(cmd.OutOrStdout(), "Pruned %s\n", checkoutLabel(checkout))
+			}
+			return nil
+		},
+	}
+	options.addFlags(cmd)
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(newWorktreeCommand())
+}
+
+// worktreeEntries pairs each checkout with the newest run recorded in it.
+func worktreeEntries(checkouts []wsshared.Checkout, records []auditRecord) []worktreeEntry {
+	entries := make([]worktreeEntry, 0, len(checkouts))
+	byDir := map[string]int{}
+	for i, c := range checkouts {
+		entries = append(entries, worktreeEntry{Name: c.Name, Dir: c.Dir, Branch: c.Branch, Main: c.Main, Dirty: c.Dirty, Missing: c.Missing})
+		if c.Dir != "" {
+			byDir[resolveDir(c.Dir)] = i
+		}
+	}
+	resolved := map[string]string{}
+	for _, r := range slices.Backward(records) {
+		dir, ok := resolved[r.WorkDir]
+		if !ok {
+			dir = resolveDir(r.WorkDir)
+			resolved[r.WorkDir] = dir
+		}
+		if i, ok := byDir[dir]; ok && entries[i].LastRun == nil {
+			entries[i].LastRun = &r
+		}
+	}
+	return entries
+}
+
+// findCheckout returns the checkout whose directory, branch or name is target.
+func findCheckout(checkouts []wsshared.Checkout, target string) (wsshared.Checkout, error) {
+	var found []wsshared.Checkout
+	for _, c := range checkouts {
+		if (c.Dir != "" && sameDir(c.Dir, target)) || (c.Branch != "" && c.Branch == target) || (c.Name != "" && c.Name == target) {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return wsshared.Checkout{}, fmt.Errorf("%w: %s", ErrWorktreeNotFound, target)
+	case 1:
+		return found[0], nil
+	default:
+		return wsshared.Checkout{}, fmt.Errorf("worktree %q is ambiguous: it matches %d worktrees", target, len(found))
+	}
+}
+
+// sameDir reports whether two paths name the same directory once made
+// absolute and, where they exist, resolved through symlinks.
+func sameDir(a, b string) bool {
+	return resolveDir(a) == resolveDir(b)
+}
+
+func resolveDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	return dir
+}
+
+// checkoutLabel names a checkout in messages: its directory, else its name.
+func checkoutLabel(c wsshared.Checkout) string {
+	if c.Dir == "" {
+		return c.Name
+	}
+	return c.Dir
+}
+
+// printWorktrees writes entries as a table or as JSON.
+func printWorktrees(w io.Writer, entries []worktreeEntry, output string) error {
+	if output == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIR\tNAME\tBRANCH\tSTATE\tLAST RUN")
+	for _, e := range entries {
+		state := "clean"
+		switch {
+		case e.Missing:
+			state = "missing"
+		case e.Dir == "":
+			state = "unknown"
+		case e.Dirty:
+			state = "dirty"
+		}
+		if e.Main {
+			state += " (main)"
+		}
+		lastRun := "-"
+		if e.LastRun != nil {
+			lastRun = e.LastRun.ID + " " + e.LastRun.StartedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", orDash(e.Dir), orDash(e.Name), orDash(e.Branch), state, lastRun)
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// cleanupWorktree removes the worktree a run used once it has ended, as
+// --worktree-cleanup asks. It never forces: a worktree holding uncommitted
+// changes is kept, and a failed removal is a warning since the run is over.
+func cleanupWorktree(vcs wsshared.VCS, repoDir, worktreeDir, mode string, succeeded bool) {
+	if mode != cleanupAlways && (mode != cleanupOnSuccess || !succeeded) {
+		return
+	}
+	checkouts, err := vcs.List(repoDir)
+	if err == nil {
+		var checkout wsshared.Checkout
+		if checkout, err = findCheckout(checkouts, worktreeDir); err == nil {
+			err = vcs.Remove(repoDir, checkout, false)
+		}
+	}
+	switch {
+	case errors.Is(err, wsshared.ErrDirty):
+		logging.LogWarning(fmt.Sprintf("Kept worktree %s: it has uncommitted changes", worktreeDir))
+	case err != nil:
+		logging.LogWarning(fmt.Sprintf("remove worktree %s: %v", worktreeDir, err))
+	default:
+		logging.LogInfo("Removed worktree " + worktreeDir)
+	}
+}
+
+// validateWorktreeCleanup checks a --worktree-cleanup value; empty is never.
+func validateWorktreeCleanup(mode string) error {
+	if !slices.Contains([]string{"", cleanupOnSuccess, cleanupAlways, cleanupNever}, mode) {
+		return fmt.Errorf("invalid --worktree-cleanup %q (want %s, %s or %s)", mode, cleanupOnSuccess, cleanupAlways, cleanupNever)
+	}
+	return nil
+}
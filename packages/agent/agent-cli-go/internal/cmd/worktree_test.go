@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	wsshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/shared"
+)
+
+// fakeVCS lists a fixed set of checkouts and records the removals, refusing a
+// dirty checkout without force as the leaves do.
+type fakeVCS struct {
+	checkouts []wsshared.Checkout
+	removed   []string
+}
+
+func (f *fakeVCS) Setup(wsshared.Config, string, bool) (string, error) { return "", nil }
+func (f *fakeVCS) Available() bool                                     { return true }
+
+func (f *fakeVCS) List(string) ([]wsshared.Checkout, error) { return f.checkouts, nil }
+
+func (f *fakeVCS) Remove(_ string, checkout wsshared.Checkout, force bool) error {
+	if checkout.Dirty && !force {
+		return fmt.Errorf("%w: %s", wsshared.ErrDirty, checkout.Dir)
+	}
+	f.removed = append(f.removed, checkout.Dir)
+	return nil
+}
+
+func (f *fakeVCS) Prune(string) ([]wsshared.Checkout, error) { return nil, nil }
+
+func TestWorktreeEntriesShowTheLastRunInEachCheckout(t *testing.T) {
+	repo, feature := t.TempDir(), t.TempDir()
+	started := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	checkouts := []wsshared.Checkout{
+		{Dir: repo, Branch: "main", Main: true},
+		{Dir: feature, Branch: "feature", Dirty: true},
+	}
+	records := []auditRecord{
+		{ID: "old", WorkDir: feature, StartedAt: started},
+		{ID: "new", WorkDir: feature + "/", StartedAt: started.Add(time.Hour)},
+		{ID: "elsewhere", WorkDir: t.TempDir(), StartedAt: started.Add(2 * time.Hour)},
+	}
+
+	entries := worktreeEntries(checkouts, records)
+	if entries[0].LastRun != nil || entries[1].LastRun == nil || entries[1].LastRun.ID != "new" {
+		t.Fatalf("worktreeEntries() = %+v, want the newest run in the feature worktree only", entries)
+	}
+	var out bytes.Buffer
+	if err := printWorktrees(&out, entries, outputText); err != nil {
+		t.Fatalf("printWorktrees() error = %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "clean (main)") || !strings.Contains(got, "dirty") || !strings.Contains(got, "new ") {
+		t.Errorf("printWorktrees() = %q, want the states and the last run", got)
+	}
+}
+
+func TestFindCheckoutMatchesADirectoryBranchOrName(t *testing.T) {
+	dir := t.TempDir()
+	checkouts := []wsshared.Checkout{
+		{Dir: dir, Branch: "feature"},
+		{Name: "agent", Dir: "/elsewhere", Branch: "shared"},
+		{Name: "other", Branch: "shared"},
+	}
+	for _, target := range []string{dir, "feature"} {
+		if got, err := findCheckout(checkouts, target); err != nil || got.Dir != dir {
+			t.Errorf("findCheckout(%q) = (%+v, %v), want %s", target, got, err, dir)
+		}
+	}
+	if got, err := findCheckout(checkouts, "agent"); err != nil || got.Name != "agent" {
+		t.Errorf("findCheckout(agent) = (%+v, %v), want the agent workspace", got, err)
+	}
+	if _, err := findCheckout(checkouts, "shared"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("findCheckout(shared) error = %v, want ambiguous", err)
+	}
+	if _, err := findCheckout(checkouts, "missing"); !errors.Is(err, ErrWorktreeNotFound) {
+		t.Errorf("findCheckout(missing) error = %v, want %v", err, ErrWorktreeNotFound)
+	}
+}
+
+func TestCleanupWorktreeFollowsTheModeAndKeepsDirtyWork(t *testing.T) {
+	clean, dirty := t.TempDir(), t.TempDir()
+	tests := []struct {
+		name      string
+		dir       string
+		mode      string
+		succeeded bool
+		removed   bool
+	}{
+		{"never", clean, cleanupNever, true, false},
+		{"unset", clean, "", true, false},
+		{"on-success after a success", clean, cleanupOnSuccess, true, true},
+		{"on-success after a failure", clean, cleanupOnSuccess, false, false},
+		{"always after a failure", clean, cleanupAlways, false, true},
+		{"dirty", dirty, cleanupAlways, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vcs := &fakeVCS{checkouts: []wsshared.Checkout{{Dir: clean, Branch: "a"}, {Dir: dirty, Branch: "b", Dirty: true}}}
+			cleanupWorktree(vcs, "/repo", tt.dir, tt.mode, tt.succeeded)
+			if removed := len(vcs.removed) == 1; removed != tt.removed {
+				t.Errorf("cleanupWorktree() removed %v, want removed = %v", vcs.removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestValidateWorktreeCleanup(t *testing.T) {
+	for _, mode := range []string{"", cleanupOnSuccess, cleanupAlways, cleanupNever} {
+		if err := validateWorktreeCleanup(mode); err != nil {
+			t.Errorf("validateWorktreeCleanup(%q) error = %v", mode, err)
+		}
+	}
+	if err := validateWorktreeCleanup("sometimes"); err == nil || !strings.Contains(err.Error(), "--worktree-cleanup") {
+		t.Errorf("validateWorktreeCleanup(sometimes) error = %v, want an invalid value", err)
+	}
+}
+
+func TestWorktreeCommandRejectsAnUnknownVCS(t *testing.T) {
+	command := newWorktreeCommand()
+	command.SetOut(&bytes.Buffer{})
+	command.SetErr(&bytes.Buffer{})
+	command.SetArgs([]string{"prune", "--vcs", "svn"})
+
+	if err := command.Execute(); err == nil || !strings.Contains(err.Error(), "unknown --vcs") {
+		t.Errorf("Execute() error = %v, want unknown --vcs", err)
+	}
+}
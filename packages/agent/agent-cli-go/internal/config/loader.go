@@ -4,21 +4,133 @@ package config
 import (
 	"bytes"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/yaml.v3"
 )
 
-// FileConfig represents configuration loaded from file.
+// FileConfig represents configuration loaded from file. Every run setting has
+// a key named after its flag; an unset key leaves the layer below, and a key
+// set to its zero value, such as false or "", resets it.
 type FileConfig struct {
+	Agent       string   `yaml:"agent" toml:"agent"`
 	Provider    string   `yaml:"provider" toml:"provider"`
 	HomeDir     string   `yaml:"homeDir" toml:"homeDir"`
 	BindPaths   []string `yaml:"bindPaths" toml:"bindPaths"`
 	RoBindPaths []string `yaml:"roBindPaths" toml:"roBindPaths"`
 	CustomEnv   []string `yaml:"customEnv" toml:"customEnv"`
+	// Protect lists workspace paths or globs kept read-only in the sandbox.
+	Protect []string `yaml:"protect" toml:"protect"`
+	// Isolation, Provision and Network select the three sandbox axes.
+	Isolation string `yaml:"isolation" toml:"isolation"`
+	Provision string `yaml:"provision" toml:"provision"`
+	Network   string `yaml:"network" toml:"network"`
+	// NetworkProxyAllow lists the hosts the network=proxy egress proxy may reach.
+	NetworkProxyAllow []string `yaml:"networkProxyAllow" toml:"networkProxyAllow"`
+	// NetworkForward lists host loopback endpoints forwarded into the sandbox.
+	NetworkForward []string `yaml:"networkForward" toml:"networkForward"`
+	// ShieldCredentials keeps provider credentials on the host behind a
+	// per-run credential shield.
+	ShieldCredentials bool `yaml:"shieldCredentials" toml:"shieldCredentials"`
+	// SeccompProfile names the seccomp deny list installed in the sandbox.
+	SeccompProfile string `yaml:"seccompProfile" toml:"seccompProfile"`
+	// LimitMemory, LimitPids and LimitCPUs cap the sandbox's process tree.
+	LimitMemory string  `yaml:"limitMemory" toml:"limitMemory"`
+	LimitPids   int64   `yaml:"limitPids" toml:"limitPids"`
+	LimitCPUs   float64 `yaml:"limitCpus" toml:"limitCpus"`
+	UsageReport string  `yaml:"usageReport" toml:"usageReport"`
+	AuditLog    string  `yaml:"auditLog" toml:"auditLog"`
+	// Prompt or PromptFile runs the agent headless; OutputFile captures what it
+	// prints.
+	Prompt     string `yaml:"prompt" toml:"prompt"`
+	PromptFile string `yaml:"promptFile" toml:"promptFile"`
+	OutputFile string `yaml:"outputFile" toml:"outputFile"`
+	// Timeout stops the agent once it has run this long, e.g. "30m".
+	Timeout string `yaml:"timeout" toml:"timeout"`
+	// Review mounts the workspace copy-on-write and holds the agent's changes
+	// for review.
+	Review bool `yaml:"review" toml:"review"`
+	// The per-isolator knobs.
+	IsolationBwrapPassthrough    bool   `yaml:"isolationBwrapPassthrough" toml:"isolationBwrapPassthrough"`
+	IsolationLandlockPassthrough bool   `yaml:"isolationLandlockPassthrough" toml:"isolationLandlockPassthrough"`
+	IsolationNativePassthrough   bool   `yaml:"isolationNativePassthrough" toml:"isolationNativePassthrough"`
+	IsolationDockerRuntime       string `yaml:"isolationDockerRuntime" toml:"isolationDockerRuntime"`
+	IsolationPodmanRuntime       string `yaml:"isolationPodmanRuntime" toml:"isolationPodmanRuntime"`
+	Image                        string `yaml:"image" toml:"image"`
+	// The provisioner inputs.
+	InitCommands []string `yaml:"initCommands" toml:"initCommands"`
+	NixSource    string   `yaml:"nixSource" toml:"nixSource"`
+	NixRev       string   `yaml:"nixRev" toml:"nixRev"`
+	NixPackages  []string `yaml:"nixPackages" toml:"nixPackages"`
+	NixShell     string   `yaml:"nixShell" toml:"nixShell"`
+	// The workspace, worktree and terminal settings.
+	WorkDir              string `yaml:"workDir" toml:"workDir"`
+	WorktreeBranch       string `yaml:"worktreeBranch" toml:"worktreeBranch"`
+	WorktreeSourceBranch string `yaml:"worktreeSourceBranch" toml:"worktreeSourceBranch"`
+	WorktreeDir          string `yaml:"worktreeDir" toml:"worktreeDir"`
+	WorktreeCleanup      string `yaml:"worktreeCleanup" toml:"worktreeCleanup"`
+	GitIntegrity         string `yaml:"gitIntegrity" toml:"gitIntegrity"`
+	VCS                  string `yaml:"vcs" toml:"vcs"`
+	Terminal             string `yaml:"terminal" toml:"terminal"`
+	TerminalSession      string `yaml:"terminalSession" toml:"terminalSession"`
+	TerminalWindow       string `yaml:"terminalWindow" toml:"terminalWindow"`
+	TerminalDetach       bool   `yaml:"terminalDetach" toml:"terminalDetach"`
+	DryRun               bool   `yaml:"dryRun" toml:"dryRun"`
+	Output               string `yaml:"output" toml:"output"`
+	// The policy guarantees.
+	RequirePinnedProvision      bool `yaml:"requirePinnedProvision" toml:"requirePinnedProvision"`
+	RequireHostToolsUnreachable bool `yaml:"requireHostToolsUnreachable" toml:"requireHostToolsUnreachable"`
+	RequireEgressRestricted     bool `yaml:"requireEgressRestricted" toml:"requireEgressRestricted"`
+	RequireKernelIsolation      bool `yaml:"requireKernelIsolation" toml:"requireKernelIsolation"`
+	RequireSyscallFilter        bool `yaml:"requireSyscallFilter" toml:"requireSyscallFilter"`
+	// AllowProjectProviders lists the non-portable provider presets a project
+	// config may select. Only the top level of the user's config sets it.
+	AllowProjectProviders []string `yaml:"allowProjectProviders" toml:"allowProjectProviders"`
+	// Profiles are named sets of the same keys, selected with --profile, that
+	// override this base configuration.
+	Profiles map[string]FileConfig `yaml:"profiles" toml:"profiles"`
+
+	// set holds the keys the file spells out, zero values included.
+	set map[string]bool
+}
+
+// IsSet reports whether the file spells out key, even as false or "".
+func (c *FileConfig) IsSet(key string) bool { return c.set[key] }
+
+// markSet records the keys of a decoded file, and of each of its profiles.
+func (c *FileConfig) markSet(keys map[string]any) {
+	c.set = make(map[string]bool, len(keys))
+	for key := range keys {
+		c.set[key] = key != "profiles"
+	}
+	profiles, _ := keys["profiles"].(map[string]any)
+	for name, value := range profiles {
+		profileKeys, ok := value.(map[string]any)
+		profile, found := c.Profiles[name]
+		if !ok || !found {
+			continue
+		}
+		profile.markSet(profileKeys)
+		c.Profiles[name] = profile
+	}
+}
+
+// Profile returns the named profile.
+func (c *FileConfig) Profile(name string) (*FileConfig, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := slices.Sorted(maps.Keys(c.Profiles))
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown profile %q: the config defines no profiles", name)
+		}
+		return nil, fmt.Errorf("unknown profile %q (profiles: %s)", name, strings.Join(names, ", "))
+	}
+	return &profile, nil
 }
 
 // GetDefaultConfigPath returns the default config file path.
@@ -60,12 +172,12 @@ func LoadConfigFile(path string) (*FileConfig, error) {
 
 	switch ext {
 	case ".yaml", ".yml":
-		err = decodeYAML(data, config)
+		err = decodeFile(data, config, decodeYAML)
 	case ".toml":
-		err = decodeTOML(data, config)
+		err = decodeFile(data, config, decodeTOML)
 	default:
-		if err = decodeYAML(data, config); err != nil {
-			if err = decodeTOML(data, config); err != nil {
+		if err = decodeFile(data, config, decodeYAML); err != nil {
+			if err = decodeFile(data, config, decodeTOML); err != nil {
 				config, err = parseKeyValueConfig(string(data))
 			}
 		}
@@ -74,18 +186,40 @@ func LoadConfigFile(path string) (*FileConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	for name, profile := range config.Profiles {
+		if len(profile.Profiles) > 0 {
+			return nil, fmt.Errorf("profile %q: profiles cannot be nested", name)
+		}
+		if profile.AllowProjectProviders != nil {
+			return nil, fmt.Errorf("profile %q: allowProjectProviders is a top-level key", name)
+		}
+	}
 
 	return config, nil
 }
 
-func decodeYAML(data []byte, config *FileConfig) error {
+// decodeFile decodes data into config, then again into a map to record which
+// keys it sets, since a decoded false or "" looks like an absent key.
+func decodeFile(data []byte, config *FileConfig, decode func([]byte, any) error) error {
+	if err := decode(data, config); err != nil {
+		return err
+	}
+	keys := map[string]any{}
+	if err := decode(data, &keys); err != nil {
+		return err
+	}
+	config.markSet(keys)
+	return nil
+}
+
+func decodeYAML(data []byte, v any) error {
 	decoder := yaml.NewDecoder(bytes.NewReader(data))
 	decoder.KnownFields(true)
-	return decoder.Decode(config)
+	return decoder.Decode(v)
 }
 
-func decodeTOML(data []byte, config *FileConfig) error {
-	return toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(config)
+func decodeTOML(data []byte, v any) error {
+	return toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(v)
 }
 
 // parseKeyValueConfig parses a simple key=value config format.
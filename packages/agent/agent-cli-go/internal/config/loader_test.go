@@ -4,12 +4,13 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestLoadConfigFileLoadsLaunchFields(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "config.yaml")
-	content := []byte("provider: gemini\nhomeDir: /tmp/home\nbindPaths:\n  - ./rw\nroBindPaths:\n  - ./ro\ncustomEnv:\n  - FEATURE=true\n")
+	content := []byte("provider: gemini\nhomeDir: /tmp/home\nbindPaths:\n  - ./rw\nroBindPaths:\n  - ./ro\ncustomEnv:\n  - FEATURE=true\nnetworkProxyAllow:\n  - api.anthropic.com\nnetworkForward:\n  - \"8317\"\nlimitMemory: 4g\nlimitPids: 1024\nlimitCpus: 1.5\n")
 	if err := os.WriteFile(path, content, 0o600); err != nil {
 		t.Fatalf("WriteFile() error = %v", err)
 	}
@@ -28,6 +29,69 @@ func TestLoadConfigFileLoadsLaunchFields(t *testing.T) {
 	if !reflect.DeepEqual(config.CustomEnv, []string{"FEATURE=true"}) {
 		t.Errorf("customEnv = %v, want FEATURE=true", config.CustomEnv)
 	}
+	if !reflect.DeepEqual(config.NetworkProxyAllow, []string{"api.anthropic.com"}) {
+		t.Errorf("networkProxyAllow = %v, want api.anthropic.com", config.NetworkProxyAllow)
+	}
+	if !reflect.DeepEqual(config.NetworkForward, []string{"8317"}) {
+		t.Errorf("networkForward = %v, want 8317", config.NetworkForward)
+	}
+	if config.LimitMemory != "4g" || config.LimitPids != 1024 || config.LimitCPUs != 1.5 {
+		t.Errorf("limits = (%q, %d, %g), want (4g, 1024, 1.5)", config.LimitMemory, config.LimitPids, config.LimitCPUs)
+	}
+}
+
+func TestLoadConfigFileLoadsRunSettingsAndProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		ext     string
+		content string
+	}{
+		{name: "yaml", ext: ".yaml", content: "isolation: bwrap\nnetwork: proxy\nnixPackages:\n  - nodejs\nrequireEgressRestricted: true\nprofiles:\n  untrusted:\n    isolation: podman\n    isolationPodmanRuntime: krun\n    nixPackages:\n      - python3\n"},
+		{name: "toml", ext: ".toml", content: "isolation = \"bwrap\"\nnetwork = \"proxy\"\nnixPackages = [\"nodejs\"]\nrequireEgressRestricted = true\n\n[profiles.untrusted]\nisolation = \"podman\"\nisolationPodmanRuntime = \"krun\"\nnixPackages = [\"python3\"]\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config"+test.ext)
+			if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			config, err := LoadConfigFile(path)
+			if err != nil {
+				t.Fatalf("LoadConfigFile() error = %v", err)
+			}
+			if config.Isolation != "bwrap" || config.Network != "proxy" || !config.RequireEgressRestricted || !reflect.DeepEqual(config.NixPackages, []string{"nodejs"}) {
+				t.Errorf("base config = %#v, want bwrap, proxy, nodejs and egress restricted", config)
+			}
+			profile, err := config.Profile("untrusted")
+			if err != nil {
+				t.Fatalf("Profile(untrusted) error = %v", err)
+			}
+			if profile.Isolation != "podman" || profile.IsolationPodmanRuntime != "krun" || !reflect.DeepEqual(profile.NixPackages, []string{"python3"}) {
+				t.Errorf("profile = %#v, want podman on krun with python3", profile)
+			}
+			if _, err := config.Profile("trusted"); err == nil || !strings.Contains(err.Error(), "profiles: untrusted") {
+				t.Errorf("Profile(trusted) error = %v, want the known profiles listed", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileRejectsNestedProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  outer:\n    profiles:\n      inner:\n        isolation: bwrap\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := LoadConfigFile(path); err == nil || !strings.Contains(err.Error(), "cannot be nested") {
+		t.Errorf("LoadConfigFile() error = %v, want a nested-profile error", err)
+	}
+}
+
+func TestProfileOfAConfigWithoutProfiles(t *testing.T) {
+	if _, err := (&FileConfig{}).Profile("ci"); err == nil || !strings.Contains(err.Error(), "defines no profiles") {
+		t.Errorf("Profile(ci) error = %v, want no profiles defined", err)
+	}
 }
 
 func TestLoadConfigFileRejectsMissingExplicitPath(t *testing.T) {
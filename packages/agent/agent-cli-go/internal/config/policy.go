@@ -0,0 +1,205 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
+)
+
+// SystemPolicyPath is the organisation-wide sandbox policy.
+const SystemPolicyPath = "/etc/agent-cli/policy.yaml"
+
+// DefaultProvider names the agent's own provider, used when no --provider
+// preset is selected, in AllowedProviders.
+const DefaultProvider = "default"
+
+// ErrPolicyForbids reports a run the sandbox policy files forbid.
+var ErrPolicyForbids = errors.New("forbidden by sandbox policy")
+
+// PolicyFile is one sandbox policy file. Every field can only tighten a run:
+// a guarantee set to true is demanded whatever the flags say, and an allowlist
+// that is present admits only the values it names. An absent field imposes
+// nothing.
+type PolicyFile struct {
+	RequirePinnedProvision      bool `yaml:"requirePinnedProvision" toml:"requirePinnedProvision"`
+	RequireHostToolsUnreachable bool `yaml:"requireHostToolsUnreachable" toml:"requireHostToolsUnreachable"`
+	RequireEgressRestricted     bool `yaml:"requireEgressRestricted" toml:"requireEgressRestricted"`
+	RequireKernelIsolation      bool `yaml:"requireKernelIsolation" toml:"requireKernelIsolation"`
+	RequireSyscallFilter        bool `yaml:"requireSyscallFilter" toml:"requireSyscallFilter"`
+	// AllowedIsolations, AllowedProviders and AllowedAgents list the methods,
+	// provider presets (DefaultProvider for none) and agents a run may use.
+	AllowedIsolations []string `yaml:"allowedIsolations" toml:"allowedIsolations"`
+	AllowedProviders  []string `yaml:"allowedProviders" toml:"allowedProviders"`
+	AllowedAgents     []string `yaml:"allowedAgents" toml:"allowedAgents"`
+	// MaxExtraBinds caps the --bind and --ro-bind paths of a run, config
+	// included; 0 forbids them.
+	MaxExtraBinds *int `yaml:"maxExtraBinds" toml:"maxExtraBinds"`
+	// AllowedImages lists path.Match patterns of the container images docker
+	// and podman may run, e.g. ghcr.io/acme/*.
+	AllowedImages []string `yaml:"allowedImages" toml:"allowedImages"`
+}
+
+// guarantees returns the guarantees the file demands.
+func (f PolicyFile) guarantees() policy.SandboxPolicy {
+	return policy.SandboxPolicy{
+		RequirePinnedProvision:      f.RequirePinnedProvision,
+		RequireHostToolsUnreachable: f.RequireHostToolsUnreachable,
+		RequireEgressRestricted:     f.RequireEgressRestricted,
+		RequireKernelIsolation:      f.RequireKernelIsolation,
+		RequireSyscallFilter:        f.RequireSyscallFilter,
+	}
+}
+
+// LoadedPolicy is a policy file and the path it was read from.
+type LoadedPolicy struct {
+	Path string
+	PolicyFile
+}
+
+// Policy merges the policy files that exist, system first, so that only the
+// stricter setting wins: a guarantee any file demands is demanded, a value
+// must pass every file's allowlist and the smallest bind cap applies. Each
+// violation names the file that imposed the rule.
+type Policy struct {
+	Files []LoadedPolicy
+}
+
+// DefaultPolicyPaths returns the system policy and the user policy next to
+// the default config file.
+func DefaultPolicyPaths() ([]string, error) {
+	configPath, err := GetDefaultConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return []string{SystemPolicyPath, filepath.Join(filepath.Dir(configPath), "policy.yaml")}, nil
+}
+
+// LoadPolicy reads the policy files at paths, skipping those that do not
+// exist. A file that exists but cannot be read or parsed is an error, so a
+// broken policy never silently stops applying.
+func LoadPolicy(paths ...string) (*Policy, error) {
+	merged := &Policy{}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read sandbox policy: %w", err)
+		}
+		var file PolicyFile
+		if filepath.Ext(p) == ".toml" {
+			err = decodeTOML(data, &file)
+		} else {
+			err = decodeYAML(data, &file)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parse sandbox policy %s: %w", p, err)
+		}
+		if file.MaxExtraBinds != nil && *file.MaxExtraBinds < 0 {
+			return nil, fmt.Errorf("parse sandbox policy %s: maxExtraBinds must not be negative", p)
+		}
+		merged.Files = append(merged.Files, LoadedPolicy{Path: p, PolicyFile: file})
+	}
+	return merged, nil
+}
+
+// Guarantees returns pol with every guarantee a policy file demands added.
+func (p *Policy) Guarantees(pol policy.SandboxPolicy) policy.SandboxPolicy {
+	for _, f := range p.Files {
+		g := f.guarantees()
+		pol.RequirePinnedProvision = pol.RequirePinnedProvision || g.RequirePinnedProvision
+		pol.RequireHostToolsUnreachable = pol.RequireHostToolsUnreachable || g.RequireHostToolsUnreachable
+		pol.RequireEgressRestricted = pol.RequireEgressRestricted || g.RequireEgressRestricted
+		pol.RequireKernelIsolation = pol.RequireKernelIsolation || g.RequireKernelIsolation
+		pol.RequireSyscallFilter = pol.RequireSyscallFilter || g.RequireSyscallFilter
+	}
+	return pol
+}
+
+// ImposedBy returns the policy files that demand a guarantee err reports as
+// unmet.
+func (p *Policy) ImposedBy(err error) []string {
+	unmet := map[error]func(policy.SandboxPolicy) bool{
+		policy.ErrPinnedProvisionUnmet: func(g policy.SandboxPolicy) bool { return g.RequirePinnedProvision },
+		policy.ErrHostToolsReachable:   func(g policy.SandboxPolicy) bool { return g.RequireHostToolsUnreachable },
+		policy.ErrEgressUnrestricted:   func(g policy.SandboxPolicy) bool { return g.RequireEgressRestricted },
+		policy.ErrKernelIsolationUnmet: func(g policy.SandboxPolicy) bool { return g.RequireKernelIsolation },
+		policy.ErrSyscallFilterUnmet:   func(g policy.SandboxPolicy) bool { return g.RequireSyscallFilter },
+	}
+	var paths []string
+	for _, f := range p.Files {
+		for sentinel, demands := range unmet {
+			if errors.Is(err, sentinel) && demands(f.guarantees()) {
+				paths = append(paths, f.Path)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// CheckIsolation reports an isolation method a policy file does not allow.
+func (p *Policy) CheckIsolation(method string) error {
+	return p.checkAllowed("isolation", method, func(f PolicyFile) []string { return f.AllowedIsolations })
+}
+
+// CheckProvider reports a provider preset a policy file does not allow; the
+// empty name is DefaultProvider.
+func (p *Policy) CheckProvider(name string) error {
+	if name == "" {
+		name = DefaultProvider
+	}
+	return p.checkAllowed("provider", name, func(f PolicyFile) []string { return f.AllowedProviders })
+}
+
+// CheckAgent reports an agent a policy file does not allow.
+func (p *Policy) CheckAgent(name string) error {
+	return p.checkAllowed("agent", name, func(f PolicyFile) []string { return f.AllowedAgents })
+}
+
+// CheckImage reports a container image that matches no pattern of a policy
+// file's AllowedImages.
+func (p *Policy) CheckImage(image string) error {
+	for _, f := range p.Files {
+		if f.AllowedImages == nil {
+			continue
+		}
+		if !slices.ContainsFunc(f.AllowedImages, func(pattern string) bool {
+			matched, err := path.Match(pattern, image)
+			return err == nil && matched
+		}) {
+			return fmt.Errorf("%w: image %q matches none of allowedImages (%s) in %s",
+				ErrPolicyForbids, image, strings.Join(f.AllowedImages, ", "), f.Path)
+		}
+	}
+	return nil
+}
+
+// CheckBinds reports more extra binds than a policy file allows.
+func (p *Policy) CheckBinds(count int) error {
+	for _, f := range p.Files {
+		if f.MaxExtraBinds != nil && count > *f.MaxExtraBinds {
+			return fmt.Errorf("%w: %d extra binds exceed maxExtraBinds %d in %s", ErrPolicyForbids, count, *f.MaxExtraBinds, f.Path)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) checkAllowed(kind, value string, allowed func(PolicyFile) []string) error {
+	for _, f := range p.Files {
+		list := allowed(f.PolicyFile)
+		if list != nil && !slices.Contains(list, value) {
+			return fmt.Errorf("%w: %s %q is not in the allowed %ss (%s) of %s",
+				ErrPolicyForbids, kind, value, kind, strings.Join(list, ", "), f.Path)
+		}
+	}
+	return nil
+}
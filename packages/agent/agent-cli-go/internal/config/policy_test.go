@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
+)
+
+func writePolicy(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoadPolicyMergesSoTheStricterSettingWins(t *testing.T) {
+	system := writePolicy(t, "policy.yaml", "requireEgressRestricted: true\nallowedIsolations: [bwrap, docker]\nmaxExtraBinds: 2\n")
+	user := writePolicy(t, "policy.toml", "requirePinnedProvision = true\nallowedIsolations = [\"bwrap\", \"none\"]\nmaxExtraBinds = 5\n")
+	merged, err := LoadPolicy(system, filepath.Join(t.TempDir(), "missing.yaml"), user)
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	if len(merged.Files) != 2 {
+		t.Fatalf("LoadPolicy() loaded %d files, want the 2 that exist", len(merged.Files))
+	}
+
+	// A flag demanding a guarantee keeps it; the files add theirs.
+	got := merged.Guarantees(policy.SandboxPolicy{RequireSyscallFilter: true})
+	want := policy.SandboxPolicy{RequirePinnedProvision: true, RequireEgressRestricted: true, RequireSyscallFilter: true}
+	if got != want {
+		t.Errorf("Guarantees() = %+v, want %+v", got, want)
+	}
+
+	if err := merged.CheckIsolation("bwrap"); err != nil {
+		t.Errorf("CheckIsolation(bwrap) error = %v, want allowed by both files", err)
+	}
+	for isolation, file := range map[string]string{"docker": user, "none": system} {
+		if err := merged.CheckIsolation(isolation); !errors.Is(err, ErrPolicyForbids) || !strings.Contains(err.Error(), file) {
+			t.Errorf("CheckIsolation(%s) error = %v, want %v naming %s", isolation, err, ErrPolicyForbids, file)
+		}
+	}
+	if err := merged.CheckBinds(3); !errors.Is(err, ErrPolicyForbids) || !strings.Contains(err.Error(), system) {
+		t.Errorf("CheckBinds(3) error = %v, want the system cap of 2", err)
+	}
+	if err := merged.CheckBinds(2); err != nil {
+		t.Errorf("CheckBinds(2) error = %v", err)
+	}
+}
+
+func TestPolicyAllowlists(t *testing.T) {
+	path := writePolicy(t, "policy.yaml", "allowedProviders: [default, gemini]\nallowedAgents: [claude]\nallowedImages: [\"ghcr.io/acme/*\"]\n")
+	merged, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		err     error
+		allowed bool
+	}{
+		{"no provider preset", merged.CheckProvider(""), true},
+		{"gemini", merged.CheckProvider("gemini"), true},
+		{"glm", merged.CheckProvider("glm"), false},
+		{"claude", merged.CheckAgent("claude"), true},
+		{"opencode", merged.CheckAgent("opencode"), false},
+		{"acme image", merged.CheckImage("ghcr.io/acme/agent@sha256:abc"), true},
+		{"other image", merged.CheckImage("docker.io/library/node:26"), false},
+	}
+	for _, tt := range tests {
+		if tt.allowed && tt.err != nil {
+			t.Errorf("%s: error = %v, want allowed", tt.name, tt.err)
+		}
+		if !tt.allowed && (!errors.Is(tt.err, ErrPolicyForbids) || !strings.Contains(tt.err.Error(), path)) {
+			t.Errorf("%s: error = %v, want %v naming %s", tt.name, tt.err, ErrPolicyForbids, path)
+		}
+	}
+	if err := merged.CheckIsolation("docker"); err != nil {
+		t.Errorf("CheckIsolation() error = %v, want no isolation allowlist", err)
+	}
+}
+
+func TestPolicyImposedByNamesTheFilesDemandingAnUnmetGuarantee(t *testing.T) {
+	system := writePolicy(t, "policy.yaml", "requireEgressRestricted: true\n")
+	user := writePolicy(t, "policy.yaml", "requirePinnedProvision: true\n")
+	merged, err := LoadPolicy(system, user)
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	unmet := &policy.UnmetError{Unmet: []error{fmt.Errorf("no proxy: %w", policy.ErrEgressUnrestricted)}}
+	if got := merged.ImposedBy(unmet); !slices.Equal(got, []string{system}) {
+		t.Errorf("ImposedBy(egress) = %v, want %v", got, []string{system})
+	}
+	if got := merged.ImposedBy(policy.ErrKernelIsolationUnmet); len(got) != 0 {
+		t.Errorf("ImposedBy(kernel) = %v, want none", got)
+	}
+	if got := merged.ImposedBy(nil); len(got) != 0 {
+		t.Errorf("ImposedBy(nil) = %v, want none", got)
+	}
+}
+
+func TestLoadPolicyRejectsABrokenFile(t *testing.T) {
+	for _, content := range []string{"allowedIsolation: [bwrap]\n", "maxExtraBinds: -1\n", "requireEgressRestricted: [\n"} {
+		if _, err := LoadPolicy(writePolicy(t, "policy.yaml", content)); err == nil {
+			t.Errorf("LoadPolicy(%q) error = nil, want a parse error", content)
+		}
+	}
+	if merged, err := LoadPolicy(writePolicy(t, "policy.yaml", "")); err != nil || len(merged.Files) != 1 {
+		t.Errorf("LoadPolicy(empty) = %+v, %v, want one file imposing nothing", merged, err)
+	}
+	if _, err := LoadPolicy(t.TempDir()); err == nil {
+		t.Error("LoadPolicy(directory) error = nil, want a read error")
+	}
+}
+
+func TestDefaultPolicyPathsPutTheSystemPolicyFirst(t *testing.T) {
+	t.Setenv("HOME", "/home/agent")
+	paths, err := DefaultPolicyPaths()
+	if err != nil {
+		t.Fatalf("DefaultPolicyPaths() error = %v", err)
+	}
+	if want := []string{SystemPolicyPath, "/home/agent/.config/sandboxed-claude/policy.yaml"}; !slices.Equal(paths, want) {
+		t.Errorf("DefaultPolicyPaths() = %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+)
+
+// ProjectConfigNames are the project config files, in lookup order.
+var ProjectConfigNames = []string{".agent-cli.yaml", ".agent-cli.yml", ".agent-cli.toml"}
+
+// FindProjectConfig walks up from dir to the filesystem root and returns the
+// first project config file it finds, or "" when there is none.
+func FindProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve project directory %q: %w", dir, err)
+	}
+	for {
+		for _, name := range ProjectConfigNames {
+			candidate := filepath.Join(dir, name)
+			info, err := os.Stat(candidate)
+			if err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+			if err != nil && !os.IsNotExist(err) {
+				return "", fmt.Errorf("inspect project config %q: %w", candidate, err)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig loads a project config file. A repository's file is not
+// trusted like the user's own config: relative paths resolve against its
+// directory, binds must stay inside that directory, and keys that reach
+// outside the repository or weaken the sandbox are rejected.
+func LoadProjectConfig(path string) (*FileConfig, error) {
+	config, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("resolve project directory of %s: %w", path, err)
+	}
+	if err := config.restrictToProject(root); err != nil {
+		return nil, fmt.Errorf("project config %s: %w", path, err)
+	}
+	for name, profile := range config.Profiles {
+		if err := profile.restrictToProject(root); err != nil {
+			return nil, fmt.Errorf("project config %s: profile %q: %w", path, name, err)
+		}
+		config.Profiles[name] = profile
+	}
+	return config, nil
+}
+
+// restrictToProject rejects the keys a project config cannot set and resolves
+// its bind paths under root.
+func (c *FileConfig) restrictToProject(root string) error {
+	forbidden := []struct {
+		key string
+		set bool
+	}{
+		{"homeDir", c.HomeDir != ""},
+		{"workDir", c.WorkDir != ""},
+		{"worktreeDir", c.WorktreeDir != ""},
+		{"usageReport", c.UsageReport != ""},
+		{"auditLog", c.AuditLog != ""},
+		{"prompt", c.Prompt != ""},
+		{"promptFile", c.PromptFile != ""},
+		{"image", c.Image != ""},
+		{"outputFile", c.OutputFile != ""},
+		{"terminal", c.Terminal != ""},
+		{"terminalSession", c.TerminalSession != ""},
+		{"terminalWindow", c.TerminalWindow != ""},
+		{"networkForward", c.NetworkForward != nil},
+		{"networkProxyAllow", c.NetworkProxyAllow != nil},
+		{"customEnv", c.CustomEnv != nil},
+		{"initCommands", c.InitCommands != nil},
+		{"isolation: none", c.Isolation == "none"},
+		{"network: host", c.Network == "host"},
+		{"gitIntegrity: off", c.GitIntegrity == "off"},
+		{"isolationBwrapPassthrough", c.IsolationBwrapPassthrough},
+		{"isolationLandlockPassthrough", c.IsolationLandlockPassthrough},
+		{"isolationNativePassthrough", c.IsolationNativePassthrough},
+		{"allowProjectProviders", c.AllowProjectProviders != nil},
+	}
+	for _, f := range forbidden {
+		if f.set {
+			return fmt.Errorf("%s cannot be set by a project config", f.key)
+		}
+	}
+	// Limits may only tighten the defaults.
+	if c.LimitMemory != "" {
+		memory, err := isoshared.ParseMemory(c.LimitMemory)
+		if err != nil {
+			return err
+		}
+		if memory > isoshared.DefaultMemoryLimit {
+			return fmt.Errorf("limitMemory %s cannot be raised above %s by a project config", c.LimitMemory, isoshared.FormatMemory(isoshared.DefaultMemoryLimit))
+		}
+	}
+	if c.LimitPids > isoshared.DefaultPidsLimit {
+		return fmt.Errorf("limitPids %d cannot be raised above %d by a project config", c.LimitPids, isoshared.DefaultPidsLimit)
+	}
+	// A project config only sets values: its explicit false or "" is dropped,
+	// so it never resets the user's, such as a require* guarantee.
+	c.set = nil
+	for i, p := range c.BindPaths {
+		resolved, err := containedPath(root, p, "read-write bind")
+		if err != nil {
+			return err
+		}
+		c.BindPaths[i] = resolved
+	}
+	for i, p := range c.RoBindPaths {
+		resolved, err := containedPath(root, p, "read-only bind")
+		if err != nil {
+			return err
+		}
+		c.RoBindPaths[i] = resolved
+	}
+	return nil
+}
+
+// containedPath resolves p, a bind of the given kind, against root, following
+// symlinks, and rejects a path outside root. The path must exist, so no
+// symlink can later redirect it.
+func containedPath(root, p, kind string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s %q: %w", kind, p, err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s %q is outside the project directory %s", kind, p, root)
+	}
+	return resolved, nil
+}
@@ -2,11 +2,16 @@
 package bwrap
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/agentcmd"
@@ -15,6 +20,10 @@ import (
 	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/envutil"
 )
 
+// seccompFd is the descriptor Run passes the compiled seccomp filter on: the
+// first of exec.Cmd's ExtraFiles.
+const seccompFd = "3"
+
 // Isolator confines the agent with bubblewrap namespaces and applies a
 // Contribution via read-only binds plus an explicit setenv allowlist.
 //
@@ -27,7 +36,8 @@ import (
 // ro-bound and the host PATH is appended.
 //
 // bwrap and default runc are attack-surface reduction, not a kernel trust
-// boundary (see KernelIsolated); no-new-privs and cap-drop are bwrap defaults.
+// boundary (see KernelIsolated); no-new-privs and cap-drop are bwrap defaults,
+// and a RunConfig.SyscallFilter adds a seccomp deny list (--seccomp).
 type Isolator struct{}
 
 // NewIsolator creates a bwrap isolator.
@@ -53,6 +63,15 @@ func (i *Isolator) PinnedProvision(_ provision.ProvisionMethod, provisionerPinne
 // trust boundary.
 func (i *Isolator) KernelIsolated(_ string) bool { return false }
 
+// FiltersSyscalls reports that bwrap installs any named seccomp profile.
+func (i *Isolator) FiltersSyscalls(profile string) bool { return profile != "" }
+
+// Cost reports a namespace sandbox set up by an external bwrap process.
+func (i *Isolator) Cost(string) int { return 2 }
+
+// CopyOnWrite reports that bwrap mounts the work directory with --overlay.
+func (i *Isolator) CopyOnWrite() bool { return true }
+
 // Run executes the agent in the bubblewrap sandbox.
 func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int, error) {
 	args, err := i.buildArgs(cfg, c)
@@ -63,7 +82,56 @@ func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int,
 	if err != nil {
 		return 1, err
 	}
-	return isoshared.SpawnSandbox("bwrap", args, env, "Bubblewrap sandbox", cfg.Verbose)
+	opts := isoshared.SpawnOptions{Cgroup: cfg.Cgroup, Timeout: cfg.Timeout}
+	if cfg.SyscallFilter.Profile != "" {
+		// The filter reaches bwrap as the file descriptor seccompFd names.
+		filter, err := os.Open(cfg.SyscallFilter.BPFFile)
+		if err != nil {
+			return 1, fmt.Errorf("open seccomp filter: %w", err)
+		}
+		defer filter.Close()
+		opts.Files = []*os.File{filter}
+	}
+	if cfg.Usage == nil {
+		return isoshared.SpawnSandboxWithOptions("bwrap", args, env, opts, "Bubblewrap sandbox", cfg.Verbose)
+	}
+
+	// bwrap reports the agent's own exit code on --json-status-fd, which tells a
+	// failing agent from a sandbox that never started.
+	status, statusWriter, err := os.Pipe()
+	if err != nil {
+		return 1, fmt.Errorf("create bwrap status pipe: %w", err)
+	}
+	defer status.Close()
+	args = append([]string{"--json-status-fd", strconv.Itoa(3 + len(opts.Files))}, args...)
+	opts.Files = append(opts.Files, statusWriter)
+	opts.Usage = cfg.Usage
+	code, err := isoshared.SpawnSandboxWithOptions("bwrap", args, env, opts, "Bubblewrap sandbox", cfg.Verbose)
+	statusWriter.Close()
+	// bwrap has exited; the deadline only guards against a sandboxed process
+	// that kept the descriptor open.
+	_ = status.SetReadDeadline(time.Now().Add(time.Second))
+	readStatus(status, cfg.Usage)
+	return code, err
+}
+
+// readStatus applies bwrap's --json-status-fd report to usage: a stream of JSON
+// objects, one of which carries "exit-code" once the agent has exited.
+func readStatus(r io.Reader, usage *isoshared.Usage) {
+	decoder := json.NewDecoder(r)
+	for {
+		var message struct {
+			ExitCode *int `json:"exit-code"`
+		}
+		if decoder.Decode(&message) != nil {
+			usage.SetupFailed = true
+			return
+		}
+		if message.ExitCode != nil {
+			usage.ExitCode = *message.ExitCode
+			return
+		}
+	}
 }
 
 // Command returns the full bwrap command (for display / terminal wrappers).
@@ -85,6 +153,39 @@ func (i *Isolator) TerminalCommand(cfg isoshared.RunConfig, c provision.Contribu
 	return command, env, err
 }
 
+// Exposure reports the binds of the bwrap command and the sandbox's
+// environment allowlist.
+func (i *Isolator) Exposure(cfg isoshared.RunConfig, c provision.Contribution) (isoshared.Exposure, error) {
+	args, err := i.buildArgs(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	env, err := i.sandboxEnv(cfg, c, homeDir)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	// An overlay reaches its source read-only; its writes land in the upper
+	// directory.
+	var binds []isoshared.Bind
+	for j := 0; j+2 < len(args) && args[j] != "--"; j++ {
+		switch args[j] {
+		case "--bind", "--ro-bind":
+			binds = append(binds, isoshared.Bind{Source: args[j+1], Target: args[j+2], ReadOnly: args[j] == "--ro-bind"})
+			j += 2
+		case "--overlay-src":
+			if j+5 < len(args) && args[j+2] == "--overlay" {
+				binds = append(binds, isoshared.Bind{Source: args[j+1], Target: args[j+5], ReadOnly: true})
+				j += 5
+			}
+		}
+	}
+	return isoshared.Exposure{Binds: binds, Env: isoshared.EnvNames(env)}, nil
+}
+
 func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
 	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
 	if err != nil {
@@ -132,12 +233,20 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 	}
 
 	// Network is applied explicitly through the network bridge.
-	netArgs, err := networkArgs(cfg.Network)
+	netArgs, err := networkArgs(cfg.Network, cfg.Relay)
 	if err != nil {
 		return nil, err
 	}
 	args = append(args, netArgs...)
 
+	// Seccomp deny list, read by bwrap from an inherited file descriptor.
+	if cfg.SyscallFilter.Profile != "" {
+		if cfg.SyscallFilter.BPFFile == "" {
+			return nil, fmt.Errorf("seccomp profile %q has no BPF filter for this architecture", cfg.SyscallFilter.Profile)
+		}
+		args = append(args, "--seccomp", seccompFd)
+	}
+
 	// Sandbox-local HOME: a tmpfs at the home path (no host-$HOME bind), with only
 	// the curated config paths bound back read-only.
 	args = append(args, "--tmpfs", homeDir)
@@ -151,8 +260,16 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 		}
 	}
 
-	// Workspace (rw) + source repo (ro for worktrees).
-	args = append(args, "--bind", workDir, workDir)
+	// Workspace (rw, or copy-on-write for a reviewed run) + source repo (ro for
+	// worktrees).
+	if cfg.Overlay.Enabled() {
+		if _, err := cfg.Overlay.Options(workDir); err != nil {
+			return nil, err
+		}
+		args = append(args, "--overlay-src", workDir, "--overlay", cfg.Overlay.Upper, cfg.Overlay.Work, workDir)
+	} else {
+		args = append(args, "--bind", workDir, workDir)
+	}
 	if repoDir != "" && repoDir != workDir {
 		args = append(args, "--ro-bind", repoDir, repoDir)
 	}
@@ -191,12 +308,23 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 		args = append(args, "--ro-bind", resolved, resolved)
 	}
 
+	// Protected workspace paths, bound read-only over every writable bind
+	// above.
+	protected, err := isoshared.ResolveProtectedPaths(workDir, cfg.Protect)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range protected {
+		args = append(args, "--ro-bind", path, path)
+	}
+
 	args = append(args, "--chdir", workDir)
 
-	// Agent command, wrapped with the provisioner's init commands.
+	// Agent command, wrapped with the provisioner's init commands and, in proxy
+	// mode, the egress relay.
 	args = append(args, "--")
-	agentCmd := agentcmd.BuildAgentCommand(cfg.Agent, cfg.Provider, cfg.AgentArgs, "")
-	args = append(args, isoshared.WrapWithInitCommands(agentCmd, c.InitCommands)...)
+	agentCmd := agentcmd.BuildAgentCommand(cfg.Agent, cfg.Provider, cfg.AgentArgs, cfg.Prompt, "")
+	args = append(args, networkCommand(cfg.Network, cfg.Relay, isoshared.WrapWithInitCommands(agentCmd, c.InitCommands))...)
 
 	return args, nil
 }
@@ -214,8 +342,9 @@ func (i *Isolator) processEnv(cfg isoshared.RunConfig, c provision.Contribution)
 }
 
 // sandboxEnv builds the explicit environment allowlist for inside the sandbox:
-// HOME/PATH/TMPDIR/SHELL, the contribution's env, the provider tokens, and the
-// caller's custom env. API keys reach the sandbox only through this allowlist.
+// HOME/PATH/TMPDIR/SHELL, the contribution's env, the provider tokens, the proxy
+// variables in proxy mode, and the caller's custom env. API keys reach the
+// sandbox only through this allowlist.
 func (i *Isolator) sandboxEnv(cfg isoshared.RunConfig, c provision.Contribution, homeDir string) (map[string]string, error) {
 	env := map[string]string{
 		"HOME":   homeDir,
@@ -245,6 +374,9 @@ func (i *Isolator) sandboxEnv(cfg isoshared.RunConfig, c provision.Contribution,
 	for k, v := range c.Env {
 		env[k] = v
 	}
+	for k, v := range networkEnv(cfg.Network) {
+		env[k] = v
+	}
 	customEnv, err := envutil.ParseCustomEnv(cfg.CustomEnv)
 	if err != nil {
 		return nil, err
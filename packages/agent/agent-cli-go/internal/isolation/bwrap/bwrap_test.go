@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -146,7 +147,71 @@ func TestBwrap_NetworkExplicit(t *testing.T) {
 		t.Error("network host must --share-net and not --unshare-net")
 	}
 	if _, err := NewIsolator().Command(claudeCfg(t, netshared.ModeProxy, false, work), provision.Contribution{}); !errors.Is(err, netshared.ErrProxyEnforcementUnavailable) {
-		t.Errorf("network proxy error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+		t.Errorf("network proxy without transport error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+	}
+}
+
+// Proxy mode unshares the network and binds in only the proxy socket and the
+// relay, which wraps the agent; HTTPS_PROXY points at the relay's loopback port.
+func TestBwrap_NetworkProxyFunnelsThroughRelay(t *testing.T) {
+	work := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeProxy, false, work)
+	cfg.Relay = netshared.RelayTransport{ProxySocket: "/tmp/agent-cli-egress/egress.sock", RelayBinary: "/usr/local/bin/agent-cli"}
+
+	args := bwrapCommand(t, cfg, provision.Contribution{})
+	env, err := NewIsolator().sandboxEnv(cfg, provision.Contribution{}, work)
+	if err != nil {
+		t.Fatalf("sandboxEnv() error = %v", err)
+	}
+
+	if !argHas(args, "--unshare-net") || argHas(args, "--share-net") {
+		t.Error("network proxy must --unshare-net and not --share-net")
+	}
+	if !argHasTriple(args, "--ro-bind", cfg.Relay.ProxySocket, netshared.SandboxProxySocket) {
+		t.Error("network proxy must bind the proxy socket")
+	}
+	if !argHasTriple(args, "--ro-bind", cfg.Relay.RelayBinary, netshared.SandboxRelayBinary) {
+		t.Error("network proxy must bind the relay binary")
+	}
+	if !argHasPair(args, netshared.SandboxRelayBinary, netshared.RelayCommandName) {
+		t.Error("network proxy must wrap the agent with the relay")
+	}
+	if env["HTTPS_PROXY"] != "http://"+netshared.SandboxProxyAddr {
+		t.Errorf("HTTPS_PROXY = %q, want the relay address", env["HTTPS_PROXY"])
+	}
+}
+
+// None mode with a loopback forward stays unshared and binds in only the relay
+// and the forward socket; the relay republishes it at the same loopback address
+// and no proxy environment is added.
+func TestBwrap_NetworkNoneForwardsLoopbackEndpoint(t *testing.T) {
+	work := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeNone, false, work)
+	cfg.Relay = netshared.RelayTransport{
+		RelayBinary: "/usr/local/bin/agent-cli",
+		Forwards:    []netshared.LoopbackForward{{Addr: "127.0.0.1:8317", Socket: "/tmp/agent-cli-egress/forward-0.sock"}},
+	}
+
+	args := bwrapCommand(t, cfg, provision.Contribution{})
+	env, err := NewIsolator().sandboxEnv(cfg, provision.Contribution{}, work)
+	if err != nil {
+		t.Fatalf("sandboxEnv() error = %v", err)
+	}
+
+	if !argHas(args, "--unshare-net") || argHas(args, "--share-net") {
+		t.Error("network none with a forward must --unshare-net and not --share-net")
+	}
+	if !argHasTriple(args, "--ro-bind", cfg.Relay.Forwards[0].Socket, "/run/agent-cli/forward-0.sock") {
+		t.Error("network none must bind the forward socket")
+	}
+	if argHasTriple(args, "--ro-bind", cfg.Relay.ProxySocket, netshared.SandboxProxySocket) {
+		t.Error("network none must not bind a proxy socket")
+	}
+	if !argHasPair(args, "--forward", "127.0.0.1:8317=/run/agent-cli/forward-0.sock") {
+		t.Error("network none must relay the forwarded endpoint")
+	}
+	if _, ok := env["HTTPS_PROXY"]; ok {
+		t.Error("network none must not set HTTPS_PROXY")
 	}
 }
 
@@ -237,6 +302,22 @@ func argHasTriple(args []string, a, b, c string) bool {
 	return false
 }
 
+// A seccomp profile reaches bwrap as --seccomp on the descriptor Run passes.
+func TestBwrap_SyscallFilter(t *testing.T) {
+	cfg := claudeCfg(t, netshared.ModeNone, false, t.TempDir())
+	if args := bwrapCommand(t, cfg, provision.Contribution{}); argHas(args, "--seccomp") {
+		t.Error("no profile must not emit --seccomp")
+	}
+	cfg.SyscallFilter = isoshared.SyscallFilter{Profile: isoshared.SeccompProfileDefault, BPFFile: "/tmp/seccomp.bpf"}
+	if args := bwrapCommand(t, cfg, provision.Contribution{}); !argHasPair(args, "--seccomp", seccompFd) {
+		t.Errorf("args = %v, want --seccomp %s", args, seccompFd)
+	}
+	cfg.SyscallFilter.BPFFile = ""
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want an error for a profile without a BPF filter")
+	}
+}
+
 func TestBwrap_Capabilities(t *testing.T) {
 	i := NewIsolator()
 	if !i.HidesHost(false, "") {
@@ -248,4 +329,85 @@ func TestBwrap_Capabilities(t *testing.T) {
 	if i.KernelIsolated("runsc") {
 		t.Error("bwrap is never a kernel boundary")
 	}
+	if !i.FiltersSyscalls(isoshared.SeccompProfileDefault) || i.FiltersSyscalls("") {
+		t.Error("bwrap filters syscalls exactly when a profile is named")
+	}
+	if i.Cost("") != 2 {
+		t.Errorf("Cost() = %d, want 2", i.Cost(""))
+	}
+}
+
+// Exposure names the workspace read-write and the closure read-only, and lists
+// the environment by name only.
+func TestBwrap_ExposureReportsBindsAndEnvNames(t *testing.T) {
+	work := t.TempDir()
+	closure := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeHost, false, work)
+	cfg.CustomEnv = []string{"FOO=secret"}
+
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{RoBindPaths: []string{closure}})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	for _, want := range []isoshared.Bind{
+		{Source: canonicalPath(t, work), Target: canonicalPath(t, work)},
+		{Source: canonicalPath(t, closure), Target: canonicalPath(t, closure), ReadOnly: true},
+	} {
+		if !slices.Contains(exposure.Binds, want) {
+			t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+		}
+	}
+	if !slices.Contains(exposure.Env, "FOO") || slices.Contains(exposure.Env, "FOO=secret") {
+		t.Errorf("Exposure().Env = %v, want the name FOO without its value", exposure.Env)
+	}
+}
+
+// A reviewed run mounts the workspace copy-on-write, which the exposure
+// reports as read-only.
+func TestBwrap_OverlayMountsTheWorkspaceCopyOnWrite(t *testing.T) {
+	work := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeHost, false, work)
+	cfg.Overlay = isoshared.Overlay{Upper: "/layers/upper", Work: "/layers/work"}
+	workDir := canonicalPath(t, work)
+
+	args := bwrapCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--overlay-src", workDir) || !argHas(args, "--overlay") || argHasPair(args, "--bind", workDir) {
+		t.Errorf("args = %v, want the workspace mounted as an overlay", args)
+	}
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	if want := (isoshared.Bind{Source: workDir, Target: workDir, ReadOnly: true}); !slices.Contains(exposure.Binds, want) {
+		t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+	}
+	cfg.Overlay.Upper = "/layers/up,per"
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want an overlay path with a comma refused")
+	}
+}
+
+// A protected workspace path is mounted read-only over the writable
+// workspace; a pattern that leaves the workspace is refused.
+func TestBwrap_ProtectMountsWorkspacePathsReadOnly(t *testing.T) {
+	work := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(work, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	cfg := claudeCfg(t, netshared.ModeHost, false, work)
+	cfg.Protect = []string{".github/workflows", "flake.lock"}
+	workDir := canonicalPath(t, work)
+	workflows := filepath.Join(workDir, ".github", "workflows")
+
+	args := bwrapCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--ro-bind", workflows) || slices.Index(args, workflows) < slices.Index(args, workDir) {
+		t.Errorf("args = %v, want %s bound read-only after the workspace", args, workflows)
+	}
+	if !argHasPair(args, "--bind", workDir) {
+		t.Errorf("args = %v, want the workspace still bound read-write", args)
+	}
+	cfg.Protect = []string{"../outside"}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want a protected path outside the workspace refused")
+	}
 }
@@ -2,18 +2,44 @@ package bwrap
 
 import netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
 
-// networkArgs returns the bubblewrap network flags for the mode. Proxy mode is
-// rejected until a transport exists that reaches an allowlist proxy without
-// also exposing unrestricted network routes.
+// networkArgs returns the bubblewrap network flags for the mode. None and proxy
+// unshare the network and bind only the relay binary and its sockets in (the
+// proxy socket for proxy, one socket per loopback forward), so the allowlist
+// proxy and the forwarded endpoints are the sandbox's sole routes out.
 //
 // This is a cross-axis bridge (isolation -> network/shared): the dependent
 // isolation leaf owns the glue; network/shared never reaches back into isolation.
-func networkArgs(m netshared.Mode) ([]string, error) {
+func networkArgs(m netshared.Mode, relay netshared.RelayTransport) ([]string, error) {
 	if m == netshared.ModeHost {
 		return []string{"--share-net"}, nil
 	}
-	if m == netshared.ModeProxy {
-		return nil, netshared.ErrProxyEnforcementUnavailable
+	binds, err := netshared.RelayBinds(m, relay)
+	if err != nil {
+		return nil, err
 	}
-	return []string{"--unshare-net"}, nil
+	args := []string{"--unshare-net"}
+	for _, bind := range binds {
+		args = append(args, "--ro-bind", bind.Host, bind.Sandbox)
+	}
+	return args, nil
+}
+
+// networkCommand wraps the agent command with the in-sandbox relay when the mode
+// routes anything through it.
+func networkCommand(m netshared.Mode, relay netshared.RelayTransport, command []string) []string {
+	return netshared.RelayCommand(m, relay, command)
+}
+
+// networkEnv returns the environment the mode adds inside the sandbox.
+func networkEnv(m netshared.Mode) map[string]string {
+	if m != netshared.ModeProxy {
+		return nil
+	}
+	return netshared.ProxyEnv()
+}
+
+// RestrictsEgress reports that a restricted mode is fully enforced: the
+// sandbox has no network route of its own beyond the relay.
+func (i *Isolator) RestrictsEgress(m netshared.Mode) bool {
+	return netshared.EgressIsRestricted(m)
 }
@@ -0,0 +1,55 @@
+package bwrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+)
+
+func TestReadStatus_TakesTheAgentExitCode(t *testing.T) {
+	var usage isoshared.Usage
+	readStatus(strings.NewReader(`{ "child-pid": 12 }
+{ "exit-code": 3 }
+`), &usage)
+
+	if usage.ExitCode != 3 || usage.SetupFailed {
+		t.Errorf("usage = %+v, want exit code 3 from the status stream", usage)
+	}
+}
+
+func TestReadStatus_MarksASandboxThatNeverRanTheAgent(t *testing.T) {
+	usage := isoshared.Usage{ExitCode: 1}
+	readStatus(strings.NewReader(`{ "child-pid": 12 }`), &usage)
+
+	if !usage.SetupFailed || usage.ExitCode != 1 {
+		t.Errorf("usage = %+v, want SetupFailed with bwrap's exit code kept", usage)
+	}
+}
+
+// The stub stands in for bwrap: it reports on the descriptor --json-status-fd
+// names, as bwrap does once the agent exits.
+func TestRun_ReportsUsageFromTheStatusDescriptor(t *testing.T) {
+	binDir := t.TempDir()
+	stub := "#!/bin/sh\n[ \"$1\" = --json-status-fd ] || exit 99\necho '{ \"exit-code\": 5 }' >&$2\nexit 5\n"
+	if err := os.WriteFile(filepath.Join(binDir, "bwrap"), []byte(stub), 0o755); err != nil {
+		t.Fatalf("write bwrap stub: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+	var usage isoshared.Usage
+	cfg := claudeCfg(t, netshared.ModeHost, false, t.TempDir())
+	cfg.Usage = &usage
+
+	exitCode, err := NewIsolator().Run(cfg, provision.Contribution{})
+
+	if err != nil || exitCode != 5 {
+		t.Fatalf("Run() = %d, %v, want 5, nil", exitCode, err)
+	}
+	if usage.ExitCode != 5 || usage.SetupFailed || usage.WallTime <= 0 {
+		t.Errorf("usage = %+v, want exit code 5 and a wall time", usage)
+	}
+}
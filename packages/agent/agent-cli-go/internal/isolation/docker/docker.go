@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 
 	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
@@ -22,14 +23,6 @@ import (
 // $HOME is never mounted; only the curated config paths are bound read-only here.
 const containerHome = "/home/agent"
 
-// DoS ceilings applied as defaults. They are deliberately generous (containment,
-// not quotas); --cpus is clamped to the host CPU count since docker rejects a
-// value above the available cores.
-const (
-	dockerPidsLimit = "4096"
-	dockerMemory    = "8g"
-)
-
 // Isolator confines the agent in a container with deny-default security flags
 // applied as DEFAULTS (not opt-in): --security-opt=no-new-privileges,
 // --cap-drop ALL, the default seccomp + apparmor=docker-default profiles,
@@ -76,6 +69,22 @@ func (i *Isolator) KernelIsolated(runtime string) bool {
 	return runtime == "runsc" || runtime == "gvisor"
 }
 
+// FiltersSyscalls reports that docker installs any named seccomp profile.
+func (i *Isolator) FiltersSyscalls(profile string) bool { return profile != "" }
+
+// Cost reports a container started through the docker daemon; a
+// kernel-isolating runtime adds its own overhead.
+func (i *Isolator) Cost(runtime string) int {
+	if i.KernelIsolated(runtime) {
+		return 7
+	}
+	return 5
+}
+
+// CopyOnWrite reports that docker mounts the work directory as an overlay
+// volume.
+func (i *Isolator) CopyOnWrite() bool { return true }
+
 // Run executes the agent in a docker container.
 func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int, error) {
 	args, err := i.buildArgs(cfg, c)
@@ -86,7 +95,11 @@ func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int,
 	if err != nil {
 		return 1, err
 	}
-	return isoshared.SpawnSandbox("docker", args, env, "Docker sandbox", cfg.Verbose)
+	opts := isoshared.SpawnOptions{Usage: cfg.Usage, Timeout: cfg.Timeout}
+	if cfg.RunID != "" {
+		opts.Container = isoshared.ContainerName(cfg.RunID)
+	}
+	return isoshared.SpawnContainer("docker", args, env, opts, "Docker sandbox", cfg.Verbose)
 }
 
 // Command returns the full docker command (for display / terminal wrappers).
@@ -108,6 +121,20 @@ func (i *Isolator) TerminalCommand(cfg isoshared.RunConfig, c provision.Contribu
 	return command, env, err
 }
 
+// Exposure reports the volumes of the docker command and the container's
+// environment.
+func (i *Isolator) Exposure(cfg isoshared.RunConfig, c provision.Contribution) (isoshared.Exposure, error) {
+	args, err := i.buildArgs(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	env, err := i.containerEnv(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	return isoshared.Exposure{Binds: isoshared.ContainerBinds(args, resolveImage(cfg.Image)), Env: isoshared.EnvNames(env)}, nil
+}
+
 func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
 	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
 	if err != nil {
@@ -125,7 +152,14 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 		}
 	}
 
+	limits := cfg.Limits.Resolved()
 	args := []string{"run", "--rm", "-it"}
+	if cfg.NoTTY {
+		args = []string{"run", "--rm", "-i"}
+	}
+	if cfg.RunID != "" {
+		args = append(args, "--name", isoshared.ContainerName(cfg.RunID), "--label", isoshared.ContainerRunLabel+"="+cfg.RunID)
+	}
 
 	// Kernel-isolating runtime (e.g. runsc/gVisor); empty means default runc.
 	if cfg.Runtime != "" {
@@ -141,13 +175,18 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 		"--tmpfs", "/tmp:rw,noexec,nosuid",
 		// Writable synthetic HOME (mode 1777 so the mapped uid can write).
 		"--tmpfs", containerHome+":rw,mode=1777",
-		"--pids-limit", dockerPidsLimit,
-		"--memory", dockerMemory,
-		"--cpus", fmt.Sprintf("%d", runtime.NumCPU()),
+		"--pids-limit", strconv.FormatInt(limits.Pids, 10),
+		"--memory", isoshared.FormatMemory(limits.MemoryBytes),
+		"--cpus", dockerCPUs(limits.CPUs),
 	)
 
+	// Seccomp deny list, in place of the engine's default profile.
+	if cfg.SyscallFilter.Profile != "" {
+		args = append(args, "--security-opt", "seccomp="+cfg.SyscallFilter.OCIFile)
+	}
+
 	// Network — applied EXPLICITLY via the network bridge.
-	netArgs, err := networkArgs(cfg.Network)
+	netArgs, err := networkArgs(cfg.Network, cfg.Relay)
 	if err != nil {
 		return nil, err
 	}
@@ -157,7 +196,17 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 	args = append(args, "-u", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
 
 	// Workspace (rw — the only writable host bind) + working directory.
-	args = append(args, "-w", workDir, "-v", fmt.Sprintf("%s:%s", workDir, workDir))
+	// A reviewed run gets the workspace as an overlay volume whose writes land
+	// in the host upper directory.
+	if cfg.Overlay.Enabled() {
+		options, err := cfg.Overlay.Options(workDir)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-w", workDir, "--mount", fmt.Sprintf(`type=volume,dst=%s,volume-driver=local,volume-opt=type=overlay,volume-opt=device=overlay,"volume-opt=o=%s"`, workDir, options))
+	} else {
+		args = append(args, "-w", workDir, "-v", fmt.Sprintf("%s:%s", workDir, workDir))
+	}
 	if repoDir != "" && repoDir != workDir {
 		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", repoDir, repoDir))
 	}
@@ -198,6 +247,16 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", resolved, resolved))
 	}
 
+	// Protected workspace paths, mounted read-only over the writable
+	// workspace; the engine mounts the nested paths after it.
+	protected, err := isoshared.ResolveProtectedPaths(workDir, cfg.Protect)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range protected {
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", path, path))
+	}
+
 	// Environment.
 	containerEnv, err := i.containerEnv(cfg, c)
 	if err != nil {
@@ -215,9 +274,10 @@ func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution)
 	// Image (pinned by the caller; falls back to the shared default).
 	args = append(args, resolveImage(cfg.Image))
 
-	// Agent command, wrapped with the provisioner's init commands.
-	agentCmd := agentcmd.BuildAgentCommand(cfg.Agent, cfg.Provider, cfg.AgentArgs, "")
-	args = append(args, isoshared.WrapWithInitCommands(agentCmd, c.InitCommands)...)
+	// Agent command, wrapped with the provisioner's init commands and, in proxy
+	// mode, the egress relay.
+	agentCmd := agentcmd.BuildAgentCommand(cfg.Agent, cfg.Provider, cfg.AgentArgs, cfg.Prompt, "")
+	args = append(args, networkCommand(cfg.Network, cfg.Relay, isoshared.WrapWithInitCommands(agentCmd, c.InitCommands))...)
 
 	return args, nil
 }
@@ -238,7 +298,8 @@ func resolveImage(image string) string {
 }
 
 // containerEnv builds the container environment: HOME/TMPDIR/SHELL/PATH, the
-// contribution's env, the provider tokens, and the caller's custom env.
+// contribution's env, the provider tokens, the proxy variables in proxy mode, and
+// the caller's custom env.
 func (i *Isolator) containerEnv(cfg isoshared.RunConfig, c provision.Contribution) (map[string]string, error) {
 	env := map[string]string{
 		"HOME":   containerHome,
@@ -263,6 +324,9 @@ func (i *Isolator) containerEnv(cfg isoshared.RunConfig, c provision.Contributio
 	for k, v := range c.Env {
 		env[k] = v
 	}
+	for k, v := range networkEnv(cfg.Network) {
+		env[k] = v
+	}
 	customEnv, err := envutil.ParseCustomEnv(cfg.CustomEnv)
 	if err != nil {
 		return nil, err
@@ -272,3 +336,13 @@ func (i *Isolator) containerEnv(cfg isoshared.RunConfig, c provision.Contributio
 	}
 	return env, nil
 }
+
+// dockerCPUs renders the --cpus value: the limit, or every host CPU when there
+// is none, clamped to the host CPU count since docker rejects a value above
+// the available cores.
+func dockerCPUs(cpus float64) string {
+	if host := float64(runtime.NumCPU()); cpus == 0 || cpus > host {
+		cpus = host
+	}
+	return strconv.FormatFloat(cpus, 'f', -1, 64)
+}
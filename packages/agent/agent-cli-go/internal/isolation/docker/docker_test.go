@@ -4,6 +4,9 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -84,10 +87,10 @@ func TestDocker_SecurityDefaults(t *testing.T) {
 	if !argHasPair(args, "--tmpfs", "/tmp:rw,noexec,nosuid") {
 		t.Error("missing hardened /tmp tmpfs")
 	}
-	if !argHasPair(args, "--pids-limit", dockerPidsLimit) {
+	if !argHasPair(args, "--pids-limit", "4096") {
 		t.Error("missing --pids-limit")
 	}
-	if !argHasPair(args, "--memory", dockerMemory) {
+	if !argHasPair(args, "--memory", "8g") {
 		t.Error("missing --memory limit")
 	}
 	if !argHas(args, "--cpus") {
@@ -153,7 +156,57 @@ func TestDocker_NetworkExplicit(t *testing.T) {
 		t.Error("network host must emit --network host")
 	}
 	if _, err := NewIsolator().Command(dockerCfg(t, netshared.ModeProxy, work), provision.Contribution{}); !errors.Is(err, netshared.ErrProxyEnforcementUnavailable) {
-		t.Errorf("network proxy error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+		t.Errorf("network proxy without transport error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+	}
+}
+
+// Proxy mode keeps --network none and reaches the host proxy only through the
+// mounted socket, with the relay wrapping the agent and HTTPS_PROXY pointing at it.
+func TestDocker_NetworkProxyFunnelsThroughRelay(t *testing.T) {
+	cfg := dockerCfg(t, netshared.ModeProxy, t.TempDir())
+	cfg.Relay = netshared.RelayTransport{ProxySocket: "/tmp/agent-cli-egress/egress.sock", RelayBinary: "/usr/local/bin/agent-cli"}
+
+	args := dockerCommand(t, cfg, provision.Contribution{})
+	env, err := NewIsolator().containerEnv(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("containerEnv() error = %v", err)
+	}
+
+	if !argHasPair(args, "--network", "none") || argHasPair(args, "--network", "host") {
+		t.Error("network proxy must keep --network none")
+	}
+	if !argHasPair(args, "-v", cfg.Relay.ProxySocket+":"+netshared.SandboxProxySocket+":ro") {
+		t.Error("network proxy must mount the proxy socket")
+	}
+	if !argHasPair(args, "-v", cfg.Relay.RelayBinary+":"+netshared.SandboxRelayBinary+":ro") {
+		t.Error("network proxy must mount the relay binary read-only")
+	}
+	if !argHasPair(args, netshared.SandboxRelayBinary, netshared.RelayCommandName) {
+		t.Error("network proxy must wrap the agent with the relay")
+	}
+	if env["HTTPS_PROXY"] != "http://"+netshared.SandboxProxyAddr {
+		t.Errorf("HTTPS_PROXY = %q, want the relay address", env["HTTPS_PROXY"])
+	}
+}
+
+// None mode with a loopback forward keeps --network none and mounts only the
+// relay and the forward socket.
+func TestDocker_NetworkNoneForwardsLoopbackEndpoint(t *testing.T) {
+	cfg := dockerCfg(t, netshared.ModeNone, t.TempDir())
+	cfg.Relay = netshared.RelayTransport{
+		RelayBinary: "/usr/local/bin/agent-cli",
+		Forwards:    []netshared.LoopbackForward{{Addr: "127.0.0.1:8317", Socket: "/tmp/agent-cli-egress/forward-0.sock"}},
+	}
+
+	args := dockerCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--network", "none") {
+		t.Error("network none with a forward must keep --network none")
+	}
+	if !argHasPair(args, "-v", cfg.Relay.Forwards[0].Socket+":/run/agent-cli/forward-0.sock:ro") {
+		t.Error("network none must mount the forward socket")
+	}
+	if !argHasPair(args, "--forward", "127.0.0.1:8317=/run/agent-cli/forward-0.sock") {
+		t.Error("network none must relay the forwarded endpoint")
 	}
 }
 
@@ -170,6 +223,64 @@ func TestDocker_RuntimeWired(t *testing.T) {
 	}
 }
 
+func TestDocker_NoTTYAttachesStdinWithoutATerminal(t *testing.T) {
+	cfg := dockerCfg(t, netshared.ModeNone, t.TempDir())
+	if args := dockerCommand(t, cfg, provision.Contribution{}); !argHas(args, "-it") {
+		t.Error("a run from a terminal must allocate one with -it")
+	}
+	cfg.NoTTY = true
+	if args := dockerCommand(t, cfg, provision.Contribution{}); argHas(args, "-it") || !argHas(args, "-i") {
+		t.Errorf("args = %v, want -i without -t for a run without a terminal", args)
+	}
+}
+
+func TestDocker_RunIDNamesAndLabelsTheContainer(t *testing.T) {
+	cfg := dockerCfg(t, netshared.ModeNone, t.TempDir())
+	if args := dockerCommand(t, cfg, provision.Contribution{}); argHas(args, "--name") {
+		t.Error("a run without an ID must leave the container unnamed")
+	}
+	cfg.RunID = "abc123"
+	args := dockerCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--name", "agent-cli-abc123") || !argHasPair(args, "--label", "agent-cli.run=abc123") {
+		t.Errorf("args = %v, want the container named and labelled after the run", args)
+	}
+}
+
+func TestDocker_PromptRunsTheAgentHeadless(t *testing.T) {
+	cfg := dockerCfg(t, netshared.ModeNone, t.TempDir())
+	cfg.Prompt = "Fix the build"
+	if args := dockerCommand(t, cfg, provision.Contribution{}); !argHasPair(args, "--print", "Fix the build") {
+		t.Errorf("args = %v, want the prompt passed to the agent", args)
+	}
+}
+
+func TestDocker_LimitsWired(t *testing.T) {
+	cfg := dockerCfg(t, netshared.ModeNone, t.TempDir())
+	cfg.Limits = isoshared.ResourceLimits{MemoryBytes: 512 << 20, Pids: 256, CPUs: 0.5}
+	args := dockerCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--memory", "512m") || !argHasPair(args, "--pids-limit", "256") || !argHasPair(args, "--cpus", "0.5") {
+		t.Errorf("args = %v, want the RunConfig limits", args)
+	}
+	if got, want := dockerCPUs(1e6), strconv.Itoa(runtime.NumCPU()); got != want {
+		t.Errorf("dockerCPUs(1e6) = %s, want the host CPU count %s", got, want)
+	}
+}
+
+func TestDocker_SyscallFilterWired(t *testing.T) {
+	work := t.TempDir()
+	cfg := dockerCfg(t, netshared.ModeNone, work)
+	cfg.SyscallFilter = isoshared.SyscallFilter{Profile: isoshared.SeccompProfileDefault, OCIFile: "/tmp/seccomp.json"}
+	if args := dockerCommand(t, cfg, provision.Contribution{}); !argHasPair(args, "--security-opt", "seccomp=/tmp/seccomp.json") {
+		t.Error("RunConfig.SyscallFilter must emit --security-opt seccomp=<profile>")
+	}
+	if args := dockerCommand(t, dockerCfg(t, netshared.ModeNone, work), provision.Contribution{}); slices.ContainsFunc(args, func(a string) bool { return strings.HasPrefix(a, "seccomp=") }) {
+		t.Error("no profile must keep the engine's default seccomp profile")
+	}
+	if !NewIsolator().FiltersSyscalls(isoshared.SeccompProfileStrict) || NewIsolator().FiltersSyscalls("") {
+		t.Error("docker filters syscalls exactly when a profile is named")
+	}
+}
+
 func TestDocker_AppliesContribution(t *testing.T) {
 	work := t.TempDir()
 	closure := t.TempDir()
@@ -221,6 +332,9 @@ func TestDocker_Capabilities(t *testing.T) {
 	if i.KernelIsolated("") {
 		t.Error("docker default runc is not a kernel boundary")
 	}
+	if i.Cost("runsc") <= i.Cost("") {
+		t.Error("a kernel-isolating runtime must cost more than runc")
+	}
 }
 
 func TestDocker_CommandRejectsMissingBindAndProviderToken(t *testing.T) {
@@ -257,3 +371,79 @@ func TestDocker_CommandKeepsProviderSecretOutOfArguments(t *testing.T) {
 		t.Fatal("docker command arguments contain the provider secret")
 	}
 }
+
+// Exposure names the workspace read-write and the closure read-only, and lists
+// the environment by name only.
+func TestDocker_ExposureReportsBindsAndEnvNames(t *testing.T) {
+	work := t.TempDir()
+	closure := t.TempDir()
+	cfg := dockerCfg(t, netshared.ModeHost, work)
+	cfg.CustomEnv = []string{"FOO=secret"}
+
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{RoBindPaths: []string{closure}})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	for _, want := range []isoshared.Bind{
+		{Source: canonicalPath(t, work), Target: canonicalPath(t, work)},
+		{Source: canonicalPath(t, closure), Target: canonicalPath(t, closure), ReadOnly: true},
+	} {
+		if !slices.Contains(exposure.Binds, want) {
+			t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+		}
+	}
+	if !slices.Contains(exposure.Env, "FOO") || slices.Contains(exposure.Env, "FOO=secret") {
+		t.Errorf("Exposure().Env = %v, want the name FOO without its value", exposure.Env)
+	}
+}
+
+// A reviewed run mounts the workspace copy-on-write, which the exposure
+// reports as read-only.
+func TestDocker_OverlayMountsTheWorkspaceCopyOnWrite(t *testing.T) {
+	work := t.TempDir()
+	cfg := dockerCfg(t, netshared.ModeHost, work)
+	cfg.Overlay = isoshared.Overlay{Upper: "/layers/upper", Work: "/layers/work"}
+	workDir := canonicalPath(t, work)
+
+	args := dockerCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--mount", `type=volume,dst=`+workDir+`,volume-driver=local,volume-opt=type=overlay,volume-opt=device=overlay,"volume-opt=o=lowerdir=`+workDir+`,upperdir=/layers/upper,workdir=/layers/work"`) {
+		t.Errorf("args = %v, want the workspace mounted as an overlay", args)
+	}
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	if want := (isoshared.Bind{Source: workDir, Target: workDir, ReadOnly: true}); !slices.Contains(exposure.Binds, want) {
+		t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+	}
+	cfg.Overlay.Upper = "/layers/up,per"
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want an overlay path with a comma refused")
+	}
+}
+
+// A protected workspace path is mounted read-only over the writable
+// workspace; a pattern that leaves the workspace is refused.
+func TestDocker_ProtectMountsWorkspacePathsReadOnly(t *testing.T) {
+	work := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(work, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	cfg := dockerCfg(t, netshared.ModeHost, work)
+	cfg.Protect = []string{".github/workflows", "flake.lock"}
+	workDir := canonicalPath(t, work)
+	workflows := filepath.Join(workDir, ".github", "workflows")
+
+	args := dockerCommand(t, cfg, provision.Contribution{})
+	workspace := slices.Index(args, workDir+":"+workDir)
+	if workspace < 0 {
+		t.Errorf("args = %v, want the workspace still bound read-write", args)
+	}
+	if protected := slices.Index(args, workflows+":"+workflows+":ro"); protected < workspace {
+		t.Errorf("args = %v, want %s bound read-only after the workspace", args, workflows)
+	}
+	cfg.Protect = []string{"../outside"}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want a protected path outside the workspace refused")
+	}
+}
@@ -0,0 +1,84 @@
+package landlock
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+)
+
+// Exec is the exec stage. It creates a private scratch directory for the
+// agent's TMPDIR, confines a dedicated OS thread with the stage's ruleset plus
+// that directory, and starts the agent from the confined thread so the agent
+// and every descendant inherit the domain. The stage itself stays unconfined:
+// it forwards termination signals, removes the scratch directory once the agent
+// exits, and returns the agent's exit code.
+func Exec(s Stage) (int, error) {
+	if err := s.validate(); err != nil {
+		return 1, err
+	}
+	scratch, err := os.MkdirTemp("", "agent-cli-landlock-")
+	if err != nil {
+		return 1, fmt.Errorf("%s: create scratch directory: %w", ExecCommandName, err)
+	}
+	defer os.RemoveAll(scratch)
+
+	ruleset := s.Ruleset
+	ruleset.ReadWrite = append(append([]string{}, s.ReadWrite...), scratch)
+
+	child := exec.Command(s.Command[0], s.Command[1:]...)
+	child.Dir = s.Dir
+	child.Env = stageEnv(s.Env, scratch)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	started := make(chan error, 1)
+	go func() {
+		// Never unlocked: the confined thread exits with this goroutine
+		// instead of running other goroutines.
+		runtime.LockOSThread()
+		if err := restrictThread(ruleset); err != nil {
+			started <- err
+			return
+		}
+		started <- child.Start()
+	}()
+	if err := <-started; err != nil {
+		return 1, fmt.Errorf("%s: start %s: %w", ExecCommandName, s.Command[0], err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+	go func() {
+		for sig := range signals {
+			_ = child.Process.Signal(sig)
+		}
+	}()
+
+	if err := child.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, fmt.Errorf("%s: wait for %s: %w", ExecCommandName, s.Command[0], err)
+	}
+	return 0, nil
+}
+
+// stageEnv returns the agent's environment: the named variables from the
+// stage's own environment, with TMPDIR pointing at the scratch directory.
+func stageEnv(names []string, scratch string) []string {
+	env := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok && name != "TMPDIR" {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, "TMPDIR="+scratch)
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package landlock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// systemDirs lets the test's shell load; the agent would get them only under
+// HostPassthrough.
+func systemDirs() []string {
+	var dirs []string
+	for _, dir := range passthroughDirs {
+		if _, err := os.Stat(dir); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// The exec stage confines the command it starts: a file beneath a granted path
+// is readable, one outside every rule is not, and TMPDIR is writable.
+func TestExec_ConfinesTheCommand(t *testing.T) {
+	if ABIVersion() < 1 {
+		t.Skip("kernel has no landlock")
+	}
+	allowed := t.TempDir()
+	denied := t.TempDir()
+	for _, dir := range []string{allowed, denied} {
+		if err := os.WriteFile(filepath.Join(dir, "file"), []byte("x"), 0o600); err != nil {
+			t.Fatalf("write probe file: %v", err)
+		}
+	}
+	t.Setenv("PATH", "/usr/bin:/bin")
+	run := func(script string) int {
+		t.Helper()
+		code, err := Exec(Stage{
+			Ruleset: Ruleset{ReadWrite: []string{"/dev/null"}, ReadOnly: append(systemDirs(), allowed)},
+			Dir:     allowed,
+			Env:     []string{"PATH"},
+			Command: []string{"sh", "-c", script + " >/dev/null 2>&1"},
+		})
+		if err != nil {
+			t.Fatalf("Exec(%q) error = %v", script, err)
+		}
+		return code
+	}
+
+	if code := run("cat " + filepath.Join(allowed, "file")); code != 0 {
+		t.Errorf("reading a granted path exited %d, want 0", code)
+	}
+	if code := run("cat " + filepath.Join(denied, "file")); code == 0 {
+		t.Error("reading a path outside the ruleset succeeded")
+	}
+	if code := run("touch " + filepath.Join(allowed, "new")); code == 0 {
+		t.Error("writing beneath a read-only path succeeded")
+	}
+	if code := run(`touch "$TMPDIR/new"`); code != 0 {
+		t.Errorf("writing the scratch TMPDIR exited %d, want 0", code)
+	}
+	if code := run("test -z \"$HOME\""); code != 0 {
+		t.Error("a variable the stage does not name reached the command")
+	}
+}
+
+func TestExec_RejectsAnEmptyCommand(t *testing.T) {
+	if _, err := Exec(Stage{}); err == nil || !strings.Contains(err.Error(), "no command") {
+		t.Errorf("Exec() error = %v, want a missing command error", err)
+	}
+}
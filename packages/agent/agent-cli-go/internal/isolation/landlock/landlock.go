@@ -0,0 +1,292 @@
+// Package landlock is the isolation=landlock leaf: unprivileged filesystem
+// (and TCP) confinement with Linux Landlock, for hosts that cannot install
+// bubblewrap.
+package landlock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/agentcmd"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/envutil"
+)
+
+// passthroughDirs are the host system directories HostPassthrough grants
+// read-only, matching the bwrap leaf.
+var passthroughDirs = []string{"/usr", "/lib", "/lib64", "/bin", "/etc"}
+
+// deviceFiles are the device nodes every agent may read and write, in place of
+// bwrap's minimal devtmpfs.
+var deviceFiles = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty", "/dev/ptmx", "/dev/pts"}
+
+// Isolator confines the agent with a Landlock ruleset applied by agent-cli
+// itself: Run re-executes agent-cli as the hidden ExecCommandName stage, which
+// confines the agent it starts. There is no namespace, so the agent sees the
+// host's processes and network, but every filesystem access outside the rules
+// is denied.
+//
+// Deny-default (HostPassthrough off): the workspace and the caller's binds are
+// read-write; RepoDir, the curated config paths under the real HOME, the
+// provisioned closure and the caller's read-only binds are read-only; /proc is
+// read-only (Landlock's ptrace rules keep processes outside the sandbox
+// unreadable); a fixed set of device nodes is read-write; TMPDIR is a private
+// scratch directory. The environment is reduced to the same allowlist the bwrap
+// leaf uses. HostPassthrough adds host /usr,/lib,/bin,/etc read-only and the
+// host PATH.
+//
+// Landlock is attack-surface reduction, not a kernel trust boundary (see
+// KernelIsolated).
+type Isolator struct{}
+
+// NewIsolator creates a Landlock isolator.
+func NewIsolator() *Isolator { return &Isolator{} }
+
+// Available reports whether the kernel supports Landlock.
+func (i *Isolator) Available() (bool, error) { return ABIVersion() >= 1, nil }
+
+// HidesHost reports that host tools are neither on PATH nor readable in
+// deny-default mode; HostPassthrough forfeits the guarantee.
+func (i *Isolator) HidesHost(passthrough bool, _ string) bool { return !passthrough }
+
+// PinnedProvision mirrors the selected provisioner's immutability because
+// Landlock supplies no image-based tools of its own.
+func (i *Isolator) PinnedProvision(_ provision.ProvisionMethod, provisionerPinned bool, _ string) bool {
+	return provisionerPinned
+}
+
+// KernelIsolated reports false: the agent shares the host kernel and its
+// namespaces.
+func (i *Isolator) KernelIsolated(_ string) bool { return false }
+
+// FiltersSyscalls reports false: the exec stage installs no seccomp filter.
+func (i *Isolator) FiltersSyscalls(string) bool { return false }
+
+// Cost reports an unprivileged ruleset applied by one exec stage.
+func (i *Isolator) Cost(string) int { return 1 }
+
+// CopyOnWrite reports that a ruleset cannot redirect writes: landlock mounts
+// nothing.
+func (i *Isolator) CopyOnWrite() bool { return false }
+
+// Run executes the agent under the Landlock exec stage.
+func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int, error) {
+	command, env, err := i.TerminalCommand(cfg, c)
+	if err != nil {
+		return 1, err
+	}
+	return isoshared.SpawnSandboxWithOptions(command[0], command[1:], env, isoshared.SpawnOptions{Cgroup: cfg.Cgroup, Usage: cfg.Usage, Timeout: cfg.Timeout}, "Landlock sandbox", cfg.Verbose)
+}
+
+// Command returns the full exec-stage command (for display / terminal wrappers).
+func (i *Isolator) Command(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
+	stage, err := i.buildStage(cfg, c)
+	if err != nil {
+		return nil, err
+	}
+	self, err := executable()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{self}, stage.Args()...), nil
+}
+
+// TerminalCommand returns the exec-stage command plus the environment used to
+// launch it; the stage passes only the allowlisted names on to the agent.
+func (i *Isolator) TerminalCommand(cfg isoshared.RunConfig, c provision.Contribution) ([]string, []string, error) {
+	command, err := i.Command(cfg, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	env, err := i.processEnv(cfg, c)
+	return command, env, err
+}
+
+// Exposure reports the paths the ruleset grants, each at its own place, and
+// the names the stage passes on to the agent, plus the TMPDIR it adds.
+func (i *Isolator) Exposure(cfg isoshared.RunConfig, c provision.Contribution) (isoshared.Exposure, error) {
+	stage, err := i.buildStage(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	var binds []isoshared.Bind
+	for _, path := range stage.ReadWrite {
+		binds = append(binds, isoshared.Bind{Source: path, Target: path})
+	}
+	for _, path := range stage.ReadOnly {
+		binds = append(binds, isoshared.Bind{Source: path, Target: path, ReadOnly: true})
+	}
+	env := append([]string{"TMPDIR"}, stage.Env...)
+	sort.Strings(env)
+	return isoshared.Exposure{Binds: binds, Env: env}, nil
+}
+
+// executable returns the canonical path of the running agent-cli, which runs
+// the exec stage.
+func executable() (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locate agent-cli executable for the landlock stage: %w", err)
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		return "", fmt.Errorf("resolve agent-cli executable for the landlock stage: %w", err)
+	}
+	return self, nil
+}
+
+func (i *Isolator) buildStage(cfg isoshared.RunConfig, c provision.Contribution) (Stage, error) {
+	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
+	if err != nil {
+		return Stage{}, err
+	}
+	workDir, err := isoshared.ResolveDirectory(cfg.WorkDir, "work directory")
+	if err != nil {
+		return Stage{}, err
+	}
+	denyTCP, err := networkRules(cfg.Network, cfg.Relay)
+	if err != nil {
+		return Stage{}, err
+	}
+	// Landlock rules only add access, so nothing under the writable work
+	// directory can be taken back.
+	if len(cfg.Protect) > 0 {
+		return Stage{}, fmt.Errorf("isolation=landlock cannot keep workspace paths read-only; use bwrap, docker, podman or native")
+	}
+
+	stage := Stage{Ruleset: Ruleset{DenyTCP: denyTCP}, Dir: workDir}
+
+	// Workspace (rw) + source repo (ro for worktrees).
+	stage.ReadWrite = append(stage.ReadWrite, workDir)
+	if cfg.RepoDir != "" {
+		repoDir, err := isoshared.ResolveDirectory(cfg.RepoDir, "repository directory")
+		if err != nil {
+			return Stage{}, err
+		}
+		if repoDir != workDir {
+			stage.ReadOnly = append(stage.ReadOnly, repoDir)
+		}
+	}
+
+	// Curated config paths, read-only, at their place in the real HOME.
+	for _, configPath := range isolation.UserConfigPaths(cfg.Agent.Type) {
+		src, exists, err := isoshared.ResolveContainedOptionalPath(homeDir, configPath, "user config path")
+		if err != nil {
+			return Stage{}, err
+		}
+		if exists {
+			stage.ReadOnly = append(stage.ReadOnly, src)
+		}
+	}
+
+	// Process information and the device nodes a shell expects.
+	stage.ReadOnly = append(stage.ReadOnly, "/proc")
+	for _, device := range deviceFiles {
+		if _, err := os.Stat(device); err == nil {
+			stage.ReadWrite = append(stage.ReadWrite, device)
+		}
+	}
+
+	// HostPassthrough: restore host tool reachability (leaky mode).
+	if cfg.HostPassthrough {
+		for _, dir := range passthroughDirs {
+			if _, err := os.Stat(dir); err == nil {
+				stage.ReadOnly = append(stage.ReadOnly, dir)
+			}
+		}
+	}
+
+	// Contribution: the provisioned closure's requisites, read-only.
+	for _, p := range c.RoBindPaths {
+		resolved, err := isoshared.ResolveExistingPath(p, "provisioned read-only bind")
+		if err != nil {
+			return Stage{}, err
+		}
+		stage.ReadOnly = append(stage.ReadOnly, resolved)
+	}
+
+	// Caller-supplied extra paths.
+	for _, path := range cfg.BindPaths {
+		resolved, err := isoshared.ResolveExistingPath(path, "read-write bind")
+		if err != nil {
+			return Stage{}, err
+		}
+		stage.ReadWrite = append(stage.ReadWrite, resolved)
+	}
+	for _, path := range cfg.RoBindPaths {
+		resolved, err := isoshared.ResolveExistingPath(path, "read-only bind")
+		if err != nil {
+			return Stage{}, err
+		}
+		stage.ReadOnly = append(stage.ReadOnly, resolved)
+	}
+
+	sandboxEnv, err := i.sandboxEnv(cfg, c, homeDir)
+	if err != nil {
+		return Stage{}, err
+	}
+	for key := range sandboxEnv {
+		stage.Env = append(stage.Env, key)
+	}
+	sort.Strings(stage.Env)
+
+	agentCmd := agentcmd.BuildAgentCommand(cfg.Agent, cfg.Provider, cfg.AgentArgs, cfg.Prompt, "")
+	stage.Command = isoshared.WrapWithInitCommands(agentCmd, c.InitCommands)
+	return stage, nil
+}
+
+func (i *Isolator) processEnv(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
+	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
+	if err != nil {
+		return nil, err
+	}
+	sandboxEnv, err := i.sandboxEnv(cfg, c, homeDir)
+	if err != nil {
+		return nil, err
+	}
+	return envutil.EnvMapToSlice(envutil.MergeEnvMaps(envutil.ParseEnv(os.Environ()), sandboxEnv)), nil
+}
+
+// sandboxEnv builds the explicit environment allowlist for the agent:
+// HOME/PATH/SHELL, the contribution's env, the provider tokens and the caller's
+// custom env. The exec stage adds TMPDIR.
+func (i *Isolator) sandboxEnv(cfg isoshared.RunConfig, c provision.Contribution, homeDir string) (map[string]string, error) {
+	env := map[string]string{
+		"HOME":  homeDir,
+		"SHELL": "/bin/bash",
+	}
+
+	// PATH: the contribution's tool dirs first, then standard dirs (readable
+	// only under HostPassthrough), then the host PATH when passing through.
+	pathEntries := append([]string{}, c.PathEntries...)
+	pathEntries = append(pathEntries, "/usr/local/bin", "/usr/bin", "/bin")
+	if cfg.HostPassthrough {
+		if hostPath := os.Getenv("PATH"); hostPath != "" {
+			pathEntries = append(pathEntries, hostPath)
+		}
+	}
+	env["PATH"] = strings.Join(pathEntries, ":")
+
+	providerEnv, err := agentcmd.BuildProviderEnv(cfg.Agent, cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range providerEnv {
+		env[k] = v
+	}
+	for k, v := range c.Env {
+		env[k] = v
+	}
+	customEnv, err := envutil.ParseCustomEnv(cfg.CustomEnv)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range customEnv {
+		env[k] = v
+	}
+	return env, nil
+}
@@ -0,0 +1,238 @@
+package landlock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+// landlockCfg builds a run config rooted in an empty temporary home directory,
+// so the ruleset depends on the config paths a test creates rather than on the
+// host user's configuration.
+func landlockCfg(t *testing.T, net netshared.Mode, workDir string) isoshared.RunConfig {
+	t.Helper()
+	return isoshared.RunConfig{
+		Agent:   &types.AgentConfig{Type: types.AgentClaude, Binary: "claude"},
+		WorkDir: workDir,
+		HomeDir: t.TempDir(),
+		Network: net,
+	}
+}
+
+func canonicalPath(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatalf("EvalSymlinks(%q) error = %v", path, err)
+	}
+	return resolved
+}
+
+func buildStage(t *testing.T, cfg isoshared.RunConfig, c provision.Contribution) Stage {
+	t.Helper()
+	stage, err := NewIsolator().buildStage(cfg, c)
+	if err != nil {
+		t.Fatalf("buildStage() error = %v", err)
+	}
+	return stage
+}
+
+// Deny-default grants the workspace read-write and only the curated config
+// paths and the closure read-only; the home and host system dirs get nothing.
+func TestLandlock_DenyDefaultRuleset(t *testing.T) {
+	work := t.TempDir()
+	repo := t.TempDir()
+	closure := t.TempDir()
+	cfg := landlockCfg(t, netshared.ModeHost, work)
+	cfg.RepoDir = repo
+	if err := os.Mkdir(filepath.Join(cfg.HomeDir, ".claude"), 0o755); err != nil {
+		t.Fatalf("create config dir: %v", err)
+	}
+
+	stage := buildStage(t, cfg, provision.Contribution{RoBindPaths: []string{closure}})
+
+	if !slices.Contains(stage.ReadWrite, canonicalPath(t, work)) || stage.Dir != canonicalPath(t, work) {
+		t.Errorf("ReadWrite = %v, Dir = %q, want the workspace", stage.ReadWrite, stage.Dir)
+	}
+	for _, want := range []string{canonicalPath(t, repo), closure, canonicalPath(t, filepath.Join(cfg.HomeDir, ".claude")), "/proc"} {
+		if !slices.Contains(stage.ReadOnly, want) {
+			t.Errorf("ReadOnly = %v, want %q", stage.ReadOnly, want)
+		}
+	}
+	home := canonicalPath(t, cfg.HomeDir)
+	for _, path := range append(append([]string{}, stage.ReadWrite...), stage.ReadOnly...) {
+		if path == home || path == "/" || slices.Contains(passthroughDirs, path) {
+			t.Errorf("deny-default ruleset grants %q", path)
+		}
+	}
+	if stage.DenyTCP {
+		t.Error("network=host must not deny TCP")
+	}
+}
+
+func TestLandlock_PassthroughGrantsHostSystemDirs(t *testing.T) {
+	cfg := landlockCfg(t, netshared.ModeHost, t.TempDir())
+	cfg.HostPassthrough = true
+	t.Setenv("PATH", "/host/bin")
+
+	stage := buildStage(t, cfg, provision.Contribution{})
+	env, err := NewIsolator().sandboxEnv(cfg, provision.Contribution{}, cfg.HomeDir)
+	if err != nil {
+		t.Fatalf("sandboxEnv() error = %v", err)
+	}
+
+	if _, err := os.Stat("/usr"); err == nil && !slices.Contains(stage.ReadOnly, "/usr") {
+		t.Errorf("ReadOnly = %v, want host /usr", stage.ReadOnly)
+	}
+	if !strings.HasSuffix(env["PATH"], ":/host/bin") {
+		t.Errorf("PATH = %q, want the host PATH appended", env["PATH"])
+	}
+}
+
+func TestLandlock_NetworkRules(t *testing.T) {
+	work := t.TempDir()
+	if stage := buildStage(t, landlockCfg(t, netshared.ModeNone, work), provision.Contribution{}); !stage.DenyTCP {
+		t.Error("network=none must deny TCP")
+	}
+
+	proxy := landlockCfg(t, netshared.ModeProxy, work)
+	proxy.Relay = netshared.RelayTransport{ProxySocket: "/tmp/egress.sock", RelayBinary: "/usr/local/bin/agent-cli"}
+	if _, err := NewIsolator().Command(proxy, provision.Contribution{}); !errors.Is(err, netshared.ErrProxyEnforcementUnavailable) {
+		t.Errorf("network=proxy error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+	}
+
+	forward := landlockCfg(t, netshared.ModeNone, work)
+	forward.Relay = netshared.RelayTransport{
+		RelayBinary: "/usr/local/bin/agent-cli",
+		Forwards:    []netshared.LoopbackForward{{Addr: "127.0.0.1:8317", Socket: "/tmp/forward-0.sock"}},
+	}
+	if _, err := NewIsolator().Command(forward, provision.Contribution{}); !errors.Is(err, netshared.ErrRelayUnavailable) {
+		t.Errorf("loopback forward error = %v, want %v", err, netshared.ErrRelayUnavailable)
+	}
+
+	if _, err := NewIsolator().Command(landlockCfg(t, "bogus", work), provision.Contribution{}); err == nil {
+		t.Error("an unknown network mode must be rejected")
+	}
+}
+
+// The command re-executes agent-cli as the exec stage, and provider secrets
+// reach the agent only through the stage's environment.
+func TestLandlock_CommandRunsTheExecStage(t *testing.T) {
+	const secret = "landlock-secret-must-not-appear"
+	t.Setenv("LANDLOCK_TEST_TOKEN", secret)
+	cfg := landlockCfg(t, netshared.ModeNone, t.TempDir())
+	cfg.Provider = &types.ModelProvider{
+		CredentialSourceEnv: "LANDLOCK_TEST_TOKEN",
+		CredentialTargetEnv: "ANTHROPIC_AUTH_TOKEN",
+	}
+	cfg.CustomEnv = []string{"CUSTOM=1"}
+
+	command, env, err := NewIsolator().TerminalCommand(cfg, provision.Contribution{InitCommands: []string{"true"}})
+	if err != nil {
+		t.Fatalf("TerminalCommand() error = %v", err)
+	}
+
+	self, err := executable()
+	if err != nil {
+		t.Fatalf("executable() error = %v", err)
+	}
+	if command[0] != self || command[1] != ExecCommandName {
+		t.Errorf("command = %v, want %s %s", command[:2], self, ExecCommandName)
+	}
+	joined := strings.Join(command, " ")
+	if strings.Contains(joined, secret) {
+		t.Fatal("exec stage arguments contain the provider secret")
+	}
+	for _, name := range []string{"ANTHROPIC_AUTH_TOKEN", "CUSTOM", "HOME", "PATH"} {
+		if !strings.Contains(joined, "--env "+name+" ") {
+			t.Errorf("command = %v, want --env %s", command, name)
+		}
+	}
+	if !slices.Contains(env, "ANTHROPIC_AUTH_TOKEN="+secret) {
+		t.Error("the stage environment must carry the token for --env to pass through")
+	}
+	if !strings.Contains(joined, "-- sh -c true && exec 'claude'") {
+		t.Errorf("command = %v, want the agent wrapped with its init commands", command)
+	}
+}
+
+func TestLandlock_CommandRejectsMissingPaths(t *testing.T) {
+	work := t.TempDir()
+	cfg := landlockCfg(t, netshared.ModeHost, work)
+	cfg.BindPaths = []string{filepath.Join(work, "missing")}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want missing bind error")
+	}
+	cfg.BindPaths = nil
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{RoBindPaths: []string{filepath.Join(work, "missing")}}); err == nil {
+		t.Error("Command() error = nil, want missing closure error")
+	}
+	cfg.WorkDir = filepath.Join(work, "missing")
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want missing work directory error")
+	}
+}
+
+func TestLandlock_Capabilities(t *testing.T) {
+	i := NewIsolator()
+	if !i.HidesHost(false, "") || i.HidesHost(true, "") {
+		t.Error("landlock hides host tools exactly when passthrough is off")
+	}
+	if !i.PinnedProvision(provision.ProvisionNix, true, "") || i.PinnedProvision(provision.ProvisionNone, false, "") {
+		t.Error("landlock pinning must mirror the provisioner")
+	}
+	if i.KernelIsolated("runsc") {
+		t.Error("landlock is not a kernel boundary")
+	}
+	if i.FiltersSyscalls("default") {
+		t.Error("landlock installs no syscall filter")
+	}
+	if i.RestrictsEgress(netshared.ModeNone) {
+		t.Error("landlock leaves UDP open, so it must not claim restricted egress")
+	}
+	if i.Cost("") != 1 {
+		t.Errorf("Cost() = %d, want 1", i.Cost(""))
+	}
+}
+
+// Exposure reports each granted path at its own place and the names the stage
+// passes on, including the TMPDIR it adds.
+func TestLandlock_ExposureReportsGrantedPaths(t *testing.T) {
+	work := t.TempDir()
+	closure := t.TempDir()
+	cfg := landlockCfg(t, netshared.ModeHost, work)
+	cfg.CustomEnv = []string{"FOO=secret"}
+
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{RoBindPaths: []string{closure}})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	for _, want := range []isoshared.Bind{
+		{Source: canonicalPath(t, work), Target: canonicalPath(t, work)},
+		{Source: canonicalPath(t, closure), Target: canonicalPath(t, closure), ReadOnly: true},
+	} {
+		if !slices.Contains(exposure.Binds, want) {
+			t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+		}
+	}
+	if !slices.Contains(exposure.Env, "FOO") || !slices.Contains(exposure.Env, "TMPDIR") || !slices.IsSorted(exposure.Env) {
+		t.Errorf("Exposure().Env = %v, want sorted names including FOO and TMPDIR", exposure.Env)
+	}
+}
+
+// Landlock can only add access, so it cannot take a workspace path back.
+func TestLandlock_RefusesProtectedPaths(t *testing.T) {
+	cfg := landlockCfg(t, netshared.ModeHost, t.TempDir())
+	cfg.Protect = []string{".github/workflows"}
+	if _, err := NewIsolator().buildStage(cfg, provision.Contribution{}); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("buildStage() error = %v, want protected paths refused", err)
+	}
+}
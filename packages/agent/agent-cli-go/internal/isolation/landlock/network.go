@@ -0,0 +1,36 @@
+package landlock
+
+import (
+	"fmt"
+
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+)
+
+// networkRules reports whether the mode denies TCP. Landlock cannot unshare the
+// network: its rules match TCP ports, not addresses, so the relay's loopback
+// sockets cannot be published without also opening the same port on every
+// remote host. Proxy mode and loopback forwards are therefore refused; none
+// denies every TCP bind and connect.
+//
+// This is a cross-axis bridge (isolation -> network/shared): the dependent
+// isolation leaf owns the glue; network/shared never reaches back into isolation.
+func networkRules(m netshared.Mode, relay netshared.RelayTransport) (bool, error) {
+	switch m {
+	case netshared.ModeHost:
+		return false, nil
+	case netshared.ModeNone:
+		if len(relay.Forwards) > 0 {
+			return false, fmt.Errorf("landlock cannot limit a loopback forward to loopback; use bwrap, docker, podman or native: %w", netshared.ErrRelayUnavailable)
+		}
+		return true, nil
+	case netshared.ModeProxy:
+		return false, fmt.Errorf("landlock cannot remove the direct route out; use bwrap, docker, podman or native: %w", netshared.ErrProxyEnforcementUnavailable)
+	default:
+		return false, &netshared.InvalidModeError{Value: string(m)}
+	}
+}
+
+// RestrictsEgress reports false: Landlock network rules cover TCP only, so
+// under network=none UDP (including DNS) still leaves the host, and the mode
+// does not satisfy RequireEgressRestricted.
+func (i *Isolator) RestrictsEgress(netshared.Mode) bool { return false }
@@ -0,0 +1,134 @@
+package landlock
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ExecCommandName is the hidden agent-cli subcommand that applies a Stage's
+// ruleset and runs the agent under it.
+const ExecCommandName = "landlock-exec"
+
+var (
+	// ErrUnsupported reports a kernel without Landlock (Linux < 5.13, or
+	// Landlock not enabled in the LSM list).
+	ErrUnsupported = errors.New("landlock is not supported by this kernel")
+	// ErrNetworkUnsupported reports a kernel whose Landlock ABI predates
+	// network rules (ABI < 4, Linux < 6.7) for a run that denies TCP.
+	ErrNetworkUnsupported = errors.New("landlock network rules need ABI 4 (Linux 6.7) or later")
+)
+
+// Filesystem access rights (LANDLOCK_ACCESS_FS_*), in uapi bit order. REFER
+// arrived with ABI 2, TRUNCATE with ABI 3 and IOCTL_DEV with ABI 5.
+const (
+	accessExecute uint64 = 1 << iota
+	accessWriteFile
+	accessReadFile
+	accessReadDir
+	accessRemoveDir
+	accessRemoveFile
+	accessMakeChar
+	accessMakeDir
+	accessMakeReg
+	accessMakeSock
+	accessMakeFifo
+	accessMakeBlock
+	accessMakeSym
+	accessRefer
+	accessTruncate
+	accessIoctlDev
+)
+
+// Network access rights (LANDLOCK_ACCESS_NET_*), ABI 4.
+const (
+	accessNetBindTCP uint64 = 1 << iota
+	accessNetConnectTCP
+)
+
+// IPC scopes (LANDLOCK_SCOPE_*), ABI 6: the domain may not connect to abstract
+// unix sockets or signal processes created outside it.
+const (
+	scopeAbstractUnixSocket uint64 = 1 << iota
+	scopeSignal
+)
+
+// readOnlyAccess lets a path be listed, read and executed.
+const readOnlyAccess = accessExecute | accessReadFile | accessReadDir
+
+// fileAccess is the subset of rights the kernel accepts on a non-directory.
+const fileAccess = accessExecute | accessWriteFile | accessReadFile | accessTruncate | accessIoctlDev
+
+// handledAccess returns every filesystem right the ABI knows, so anything not
+// granted by a rule is denied.
+func handledAccess(abi int) uint64 {
+	access := accessMakeSym<<1 - 1
+	if abi >= 2 {
+		access |= accessRefer
+	}
+	if abi >= 3 {
+		access |= accessTruncate
+	}
+	if abi >= 5 {
+		access |= accessIoctlDev
+	}
+	return access
+}
+
+// ruleAccess returns the rights a rule grants on one path: the requested
+// rights the ABI handles, narrowed to the file rights for a non-directory.
+func ruleAccess(requested uint64, abi int, dir bool) uint64 {
+	access := requested & handledAccess(abi)
+	if !dir {
+		access &= fileAccess
+	}
+	return access
+}
+
+// Ruleset is the confinement the exec stage applies: every filesystem access
+// not beneath a ReadWrite or ReadOnly path is denied, and with DenyTCP so is
+// every TCP bind and connect.
+type Ruleset struct {
+	ReadWrite []string
+	ReadOnly  []string
+	DenyTCP   bool
+}
+
+// Stage is the input of the exec stage: the ruleset, the agent's working
+// directory, the names of the variables passed on to the agent (their values
+// come from the stage's own environment, so secrets never enter argv), and the
+// agent command.
+type Stage struct {
+	Ruleset
+	Dir     string
+	Env     []string
+	Command []string
+}
+
+// Args renders the stage as the arguments of the ExecCommandName subcommand.
+func (s Stage) Args() []string {
+	args := []string{ExecCommandName}
+	for _, path := range s.ReadWrite {
+		args = append(args, "--rw", path)
+	}
+	for _, path := range s.ReadOnly {
+		args = append(args, "--ro", path)
+	}
+	if s.DenyTCP {
+		args = append(args, "--deny-tcp")
+	}
+	if s.Dir != "" {
+		args = append(args, "--chdir", s.Dir)
+	}
+	for _, key := range s.Env {
+		args = append(args, "--env", key)
+	}
+	return append(append(args, "--"), s.Command...)
+}
+
+// validate reports a stage the exec stage cannot run.
+func (s Stage) validate() error {
+	if len(s.Command) == 0 {
+		return fmt.Errorf("%s: no command", ExecCommandName)
+	}
+	return nil
+}
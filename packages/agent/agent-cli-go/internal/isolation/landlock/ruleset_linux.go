@@ -0,0 +1,118 @@
+//go:build linux
+
+package landlock
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock system calls share one number across architectures.
+const (
+	sysCreateRuleset = 444
+	sysAddRule       = 445
+	sysRestrictSelf  = 446
+
+	createRulesetVersion = 1 << 0
+	rulePathBeneath      = 1
+	prSetNoNewPrivs      = 38
+	oPath                = 0x200000
+)
+
+// rulesetAttr is struct landlock_ruleset_attr. Older kernels accept only a
+// prefix of it, so its size grows with the fields the ABI knows.
+type rulesetAttr struct {
+	handledAccessFS  uint64
+	handledAccessNet uint64
+	scoped           uint64
+}
+
+// pathBeneathAttr is the packed struct landlock_path_beneath_attr; the Go
+// layout has the same field offsets and only adds trailing padding.
+type pathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// ABIVersion returns the kernel's Landlock ABI version, or 0 without Landlock.
+func ABIVersion() int {
+	version, _, errno := syscall.Syscall(sysCreateRuleset, 0, 0, createRulesetVersion)
+	if errno != 0 {
+		return 0
+	}
+	return int(version)
+}
+
+// restrictThread confines the calling OS thread and every process it starts
+// afterwards to r. The caller must hold runtime.LockOSThread and never release
+// it, so the confined thread is not handed back to the scheduler.
+func restrictThread(r Ruleset) error {
+	abi := ABIVersion()
+	if abi < 1 {
+		return ErrUnsupported
+	}
+	if r.DenyTCP && abi < 4 {
+		return ErrNetworkUnsupported
+	}
+
+	attr := rulesetAttr{handledAccessFS: handledAccess(abi)}
+	size := unsafe.Sizeof(attr.handledAccessFS)
+	if abi >= 4 {
+		size += unsafe.Sizeof(attr.handledAccessNet)
+		if r.DenyTCP {
+			attr.handledAccessNet = accessNetBindTCP | accessNetConnectTCP
+		}
+	}
+	if abi >= 6 {
+		size += unsafe.Sizeof(attr.scoped)
+		attr.scoped = scopeAbstractUnixSocket | scopeSignal
+	}
+	fd, _, errno := syscall.Syscall(sysCreateRuleset, uintptr(unsafe.Pointer(&attr)), size, 0)
+	if errno != 0 {
+		return fmt.Errorf("create landlock ruleset: %w", errno)
+	}
+	defer syscall.Close(int(fd))
+
+	for _, path := range r.ReadWrite {
+		if err := addPathRule(int(fd), path, handledAccess(abi), abi); err != nil {
+			return err
+		}
+	}
+	for _, path := range r.ReadOnly {
+		if err := addPathRule(int(fd), path, readOnlyAccess, abi); err != nil {
+			return err
+		}
+	}
+
+	// restrict_self needs no_new_privs on the same thread; both are inherited
+	// by every process the thread starts.
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("set no_new_privs: %w", errno)
+	}
+	if _, _, errno := syscall.Syscall(sysRestrictSelf, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("enforce landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+// addPathRule grants access beneath path.
+func addPathRule(rulesetFd int, path string, access uint64, abi int) error {
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open landlock path %q: %w", path, err)
+	}
+	defer syscall.Close(fd)
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		return fmt.Errorf("inspect landlock path %q: %w", path, err)
+	}
+	attr := pathBeneathAttr{
+		allowedAccess: ruleAccess(access, abi, stat.Mode&syscall.S_IFMT == syscall.S_IFDIR),
+		parentFd:      int32(fd),
+	}
+	if _, _, errno := syscall.Syscall6(sysAddRule, uintptr(rulesetFd), rulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("add landlock rule for %q: %w", path, errno)
+	}
+	return nil
+}
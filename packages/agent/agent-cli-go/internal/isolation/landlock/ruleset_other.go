@@ -0,0 +1,9 @@
+//go:build !linux
+
+package landlock
+
+// ABIVersion reports no Landlock: it is a Linux security module.
+func ABIVersion() int { return 0 }
+
+// restrictThread always fails: Landlock is a Linux security module.
+func restrictThread(Ruleset) error { return ErrUnsupported }
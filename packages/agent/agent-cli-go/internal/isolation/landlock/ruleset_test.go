@@ -0,0 +1,63 @@
+package landlock
+
+import (
+	"slices"
+	"testing"
+)
+
+// Every right the ABI knows is handled, so nothing is allowed by omission.
+func TestHandledAccessGrowsWithTheABI(t *testing.T) {
+	tests := []struct {
+		abi  int
+		want uint64
+	}{
+		{abi: 1, want: 1<<13 - 1},
+		{abi: 2, want: 1<<14 - 1},
+		{abi: 4, want: 1<<15 - 1},
+		{abi: 5, want: 1<<16 - 1},
+		{abi: 6, want: 1<<16 - 1},
+	}
+	for _, test := range tests {
+		if got := handledAccess(test.abi); got != test.want {
+			t.Errorf("handledAccess(%d) = %#x, want %#x", test.abi, got, test.want)
+		}
+	}
+}
+
+// The kernel rejects directory rights on a file rule, so they are dropped.
+func TestRuleAccessNarrowsFilesToFileRights(t *testing.T) {
+	if got := ruleAccess(handledAccess(5), 5, true); got != handledAccess(5) {
+		t.Errorf("directory access = %#x, want every handled right", got)
+	}
+	if got := ruleAccess(handledAccess(5), 5, false); got != fileAccess {
+		t.Errorf("file access = %#x, want %#x", got, fileAccess)
+	}
+	if got := ruleAccess(accessTruncate|accessReadFile, 2, false); got != accessReadFile {
+		t.Errorf("ABI 2 file access = %#x, want TRUNCATE dropped", got)
+	}
+}
+
+func TestStageArgsRoundTripTheFlags(t *testing.T) {
+	stage := Stage{
+		Ruleset: Ruleset{ReadWrite: []string{"/work"}, ReadOnly: []string{"/nix/store/a", "/proc"}, DenyTCP: true},
+		Dir:     "/work",
+		Env:     []string{"HOME", "PATH"},
+		Command: []string{"claude", "--print"},
+	}
+
+	want := []string{
+		ExecCommandName,
+		"--rw", "/work",
+		"--ro", "/nix/store/a", "--ro", "/proc",
+		"--deny-tcp",
+		"--chdir", "/work",
+		"--env", "HOME", "--env", "PATH",
+		"--", "claude", "--print",
+	}
+	if got := stage.Args(); !slices.Equal(got, want) {
+		t.Errorf("Args() = %v, want %v", got, want)
+	}
+	if got := (Stage{Command: []string{"true"}}).Args(); !slices.Equal(got, []string{ExecCommandName, "--", "true"}) {
+		t.Errorf("Args() = %v, want only the command", got)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build linux
+
+package native
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// namespaceFlags are the namespaces every sandbox gets; the network namespace
+// is added when the plan unshares it.
+const namespaceFlags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+	syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWCGROUP
+
+// Exec is the exec stage. It re-executes agent-cli as the init stage in new
+// user, mount, PID, UTS, IPC and cgroup namespaces (plus a network namespace
+// when the plan unshares it), mapping the caller's uid and gid to themselves.
+// The init stage reports a failed setup step over a pipe, which Exec returns as
+// a *SetupError; otherwise Exec forwards termination signals and returns the
+// agent's exit code. The sandbox is killed if the exec stage dies.
+func Exec(p Plan) (int, error) {
+	if err := p.validate(); err != nil {
+		return 1, err
+	}
+	report, reportWriter, err := os.Pipe()
+	if err != nil {
+		return 1, fmt.Errorf("%s: create report pipe: %w", ExecCommandName, err)
+	}
+	defer report.Close()
+
+	flags := uintptr(namespaceFlags)
+	if p.UnshareNet {
+		flags |= syscall.CLONE_NEWNET
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	child := exec.Command("/proc/self/exe", append([]string{InitCommandName}, p.flags()...)...)
+	child.Env = stageEnv(p.Env)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	child.ExtraFiles = []*os.File{reportWriter}
+	child.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 flags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+	startErr := child.Start()
+	reportWriter.Close()
+	if startErr != nil {
+		return 1, &SetupError{Op: "create namespaces", Path: "/", Err: startErr}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+	go func() {
+		for sig := range signals {
+			_ = child.Process.Signal(sig)
+		}
+	}()
+
+	// The init stage closes its end once the agent has started, or writes the
+	// failed step and exits.
+	failure, _ := io.ReadAll(report)
+	waitErr := child.Wait()
+	if len(failure) > 0 {
+		return 1, decodeSetupError(failure)
+	}
+	if waitErr != nil {
+		if exitErr, ok := waitErr.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), nil
+		}
+		return 1, fmt.Errorf("%s: wait for the sandbox: %w", ExecCommandName, waitErr)
+	}
+	return 0, nil
+}
+
+// stageEnv returns the sandbox environment: the named variables from the exec
+// stage's own environment.
+func stageEnv(names []string) []string {
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
@@ -0,0 +1,197 @@
+//go:build linux
+
+package native
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+)
+
+// TestMain lets the test binary serve as the init stage Exec re-executes.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == InitCommandName {
+		plan, err := ParseArgs(os.Args[2:])
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(Init(plan))
+	}
+	os.Exit(m.Run())
+}
+
+// sandboxPlan binds the host system dirs read-only so the test's shell loads.
+func sandboxPlan(dir, script string) Plan {
+	plan := Plan{
+		Mounts: []Mount{
+			{Kind: MountDev, Target: "/dev"},
+			{Kind: MountProc, Target: "/proc"},
+			{Kind: MountTmpfs, Target: "/tmp"},
+		},
+		UnshareNet: true,
+		Dir:        dir,
+		Env:        []string{"PATH"},
+		Command:    []string{"sh", "-c", script + " >/dev/null 2>&1"},
+	}
+	for _, dir := range passthroughDirs {
+		if _, err := os.Stat(dir); err == nil {
+			plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: dir, Target: dir})
+		}
+	}
+	return plan
+}
+
+// The sandbox holds exactly the plan: the read-write bind is writable, the
+// read-only bind is not, anything unbound is absent, the init stage is the
+// sandbox's PID 1, only loopback exists and no capability survives.
+func TestExec_BuildsThePlan(t *testing.T) {
+	if !isoshared.UserNamespacesAvailable() {
+		t.Skip("kernel does not allow unprivileged user namespaces")
+	}
+	rw := t.TempDir()
+	ro := t.TempDir()
+	hidden := t.TempDir()
+	for _, dir := range []string{ro, hidden} {
+		if err := os.WriteFile(filepath.Join(dir, "file"), []byte("x"), 0o600); err != nil {
+			t.Fatalf("write probe file: %v", err)
+		}
+	}
+	t.Setenv("PATH", "/usr/bin:/bin")
+	t.Setenv("NATIVE_UNNAMED", "must-not-enter-sandbox")
+	run := func(script string) int {
+		t.Helper()
+		plan := sandboxPlan(rw, script)
+		plan.Mounts = append(plan.Mounts,
+			Mount{Kind: MountBind, Source: rw, Target: rw},
+			Mount{Kind: MountROBind, Source: ro, Target: ro},
+		)
+		code, err := Exec(plan)
+		if errors.Is(err, syscall.EPERM) {
+			t.Skipf("sandbox setup not permitted here: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("Exec(%q) error = %v", script, err)
+		}
+		return code
+	}
+
+	if code := run("touch " + filepath.Join(rw, "new")); code != 0 {
+		t.Errorf("writing the read-write bind exited %d, want 0", code)
+	}
+	if _, err := os.Stat(filepath.Join(rw, "new")); err != nil {
+		t.Errorf("the write did not reach the host: %v", err)
+	}
+	if code := run("cat " + filepath.Join(ro, "file")); code != 0 {
+		t.Errorf("reading the read-only bind exited %d, want 0", code)
+	}
+	if code := run("touch " + filepath.Join(ro, "new")); code == 0 {
+		t.Error("writing beneath a read-only bind succeeded")
+	}
+	if code := run("test ! -e " + filepath.Join(hidden, "file")); code != 0 {
+		t.Error("a path outside the plan is visible")
+	}
+	if code := run("touch /new"); code == 0 {
+		t.Error("the sandbox root is writable")
+	}
+	if code := run("touch /tmp/new && test -c /dev/null && test ! -e /dev/sda"); code != 0 {
+		t.Errorf("tmpfs /tmp and the minimal /dev exited %d, want 0", code)
+	}
+	if code := run(`grep -q ` + InitCommandName + ` /proc/1/cmdline && test "$(grep -c : /proc/net/dev)" = 1`); code != 0 {
+		t.Errorf("PID and network namespaces exited %d, want 0", code)
+	}
+	if code := run(`grep -q '^CapEff:.0*$' /proc/self/status && grep -q '^NoNewPrivs:.1$' /proc/self/status`); code != 0 {
+		t.Error("the sandboxed command holds capabilities or lacks no-new-privileges")
+	}
+	if code := run(`test -z "$NATIVE_UNNAMED"`); code != 0 {
+		t.Error("a variable the plan does not name reached the command")
+	}
+	if code := run("exit 7"); code != 7 {
+		t.Errorf("exit code = %d, want 7", code)
+	}
+}
+
+// A step the init stage cannot perform comes back as a SetupError naming it.
+func TestExec_ReportsTheFailedStep(t *testing.T) {
+	if !isoshared.UserNamespacesAvailable() {
+		t.Skip("kernel does not allow unprivileged user namespaces")
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+	plan := sandboxPlan("/", "true")
+	plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: missing, Target: missing})
+
+	_, err := Exec(plan)
+
+	var setupErr *SetupError
+	if !errors.As(err, &setupErr) || setupErr.Path != missing || !errors.Is(err, syscall.ENOENT) {
+		t.Errorf("Exec() error = %v, want a SetupError for %q wrapping ENOENT", err, missing)
+	}
+
+	plan = sandboxPlan("/", "true")
+	plan.Command = []string{"native-test-command-that-does-not-exist"}
+	if _, err := Exec(plan); !errors.As(err, &setupErr) || !strings.Contains(err.Error(), "find command") {
+		t.Errorf("Exec() error = %v, want the find command step", err)
+	}
+}
+
+func TestExec_RejectsAnEmptyCommand(t *testing.T) {
+	if _, err := Exec(Plan{}); err == nil || !strings.Contains(err.Error(), "no command") {
+		t.Errorf("Exec() error = %v, want a missing command error", err)
+	}
+}
+
+// A read-only remount keeps the flags a user namespace cannot clear; statfs
+// reports relatime with a different bit than mount takes it.
+func TestLockedFlagsKeepsTheHostMountFlags(t *testing.T) {
+	const stRelatime = 0x1000
+	got := lockedFlags(syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV | stRelatime)
+	if want := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_RELATIME); got != want {
+		t.Errorf("lockedFlags() = %#x, want %#x", got, want)
+	}
+}
+
+// An overlay leaves the workspace as it was and collects the sandbox's writes
+// and deletions in the upper directory.
+func TestExec_OverlayKeepsTheWorkspaceUntouched(t *testing.T) {
+	if !isoshared.UserNamespacesAvailable() {
+		t.Skip("kernel does not allow unprivileged user namespaces")
+	}
+	work, layers := t.TempDir(), t.TempDir()
+	for _, name := range []string{"kept", "deleted"} {
+		if err := os.WriteFile(filepath.Join(work, name), []byte("before"), 0o600); err != nil {
+			t.Fatalf("write workspace file: %v", err)
+		}
+	}
+	upper, workdir := filepath.Join(layers, "upper"), filepath.Join(layers, "work")
+	for _, dir := range []string{upper, workdir} {
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatalf("create overlay layer: %v", err)
+		}
+	}
+	t.Setenv("PATH", "/usr/bin:/bin")
+	plan := sandboxPlan(work, "echo after > kept && rm deleted && echo new > added && true")
+	plan.Mounts = append(plan.Mounts, Mount{Kind: MountOverlay, Source: work, Upper: upper, Work: workdir, Target: work})
+
+	code, err := Exec(plan)
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) {
+		t.Skipf("overlay mount not permitted here: %v", err)
+	}
+	if err != nil || code != 0 {
+		t.Fatalf("Exec() = %d, %v, want the script to succeed", code, err)
+	}
+	for name, want := range map[string]string{"kept": "before", "deleted": "before"} {
+		if got, err := os.ReadFile(filepath.Join(work, name)); err != nil || string(got) != want {
+			t.Errorf("workspace %s = (%q, %v), want it untouched", name, got, err)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(upper, "added")); err != nil || string(got) != "new\n" {
+		t.Errorf("upper added = (%q, %v), want the sandbox's write", got, err)
+	}
+	if info, err := os.Lstat(filepath.Join(upper, "deleted")); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		t.Errorf("upper deleted = (%v, %v), want a whiteout", info, err)
+	}
+}
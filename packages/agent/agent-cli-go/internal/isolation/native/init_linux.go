@@ -0,0 +1,379 @@
+//go:build linux
+
+package native
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+)
+
+const (
+	// reportFd is the init stage's end of the exec stage's report pipe.
+	reportFd = 3
+	// setupRoot is where the sandbox root tmpfs is mounted before pivot_root;
+	// after the pivot the host root is reachable beneath oldRoot until it is
+	// detached.
+	setupRoot = "/tmp"
+	oldRoot   = "/oldroot"
+
+	prSetNoNewPrivs    = 38
+	prCapBSetDrop      = 24
+	prCapAmbient       = 47
+	prCapAmbientClear  = 4
+	linuxCapVersion3   = 0x20080522
+	lastCapability     = 63
+	mountLockedFlagsMS = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+		syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+)
+
+// devNodes are the host device nodes a minimal /dev binds, in place of a full
+// /dev that would expose disks, memory and input devices.
+var devNodes = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+// devLinks are the symlinks a minimal /dev carries.
+var devLinks = map[string]string{
+	"fd":     "/proc/self/fd",
+	"stdin":  "/proc/self/fd/0",
+	"stdout": "/proc/self/fd/1",
+	"stderr": "/proc/self/fd/2",
+	"ptmx":   "pts/ptmx",
+}
+
+// Init is the init stage, running as PID 1 of the new namespaces. It builds
+// the plan's root: a tmpfs it pivots into, each mount applied in order with
+// its source read through the old root, the loopback interface brought up in
+// an unshared network, the old root detached and the new root made read-only.
+// It then sets no-new-privileges, drops every capability and starts the
+// agent, reaping orphans until the agent exits. A failed step is reported to
+// the exec stage; the returned value is the exit code.
+func Init(p Plan) int {
+	report := os.NewFile(reportFd, "report")
+	agent, err := startSandbox(p)
+	if err != nil {
+		_, _ = report.Write(encodeSetupError(err))
+		report.Close()
+		return 1
+	}
+	report.Close()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range signals {
+			_ = agent.Signal(sig)
+		}
+	}()
+	return reap(agent.Pid)
+}
+
+func startSandbox(p Plan) (*os.Process, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if err := buildRoot(p); err != nil {
+		return nil, err
+	}
+	if err := chdir(p.Dir); err != nil {
+		return nil, err
+	}
+	// Capabilities and no-new-privileges are per thread: the agent must be
+	// started from the thread that dropped them. The thread stays locked for
+	// the rest of the init stage, which needs no privilege afterwards.
+	runtime.LockOSThread()
+	if err := dropPrivileges(); err != nil {
+		return nil, err
+	}
+	path, err := exec.LookPath(p.Command[0])
+	if err != nil {
+		return nil, &SetupError{Op: "find command", Path: p.Command[0], Err: err}
+	}
+	agent, err := os.StartProcess(path, p.Command, &os.ProcAttr{
+		Env:   os.Environ(),
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr},
+	})
+	if err != nil {
+		return nil, &SetupError{Op: "start command", Path: path, Err: err}
+	}
+	return agent, nil
+}
+
+func chdir(dir string) error {
+	if dir == "" {
+		dir = "/"
+	}
+	if err := syscall.Chdir(dir); err != nil {
+		return &SetupError{Op: "chdir", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// buildRoot realizes the plan's mounts on a fresh tmpfs root.
+func buildRoot(p Plan) error {
+	// Nothing done here may propagate back to the host.
+	if err := mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return err
+	}
+	if err := mount("tmpfs", setupRoot, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return err
+	}
+	if err := os.Mkdir(setupRoot+oldRoot, 0o700); err != nil {
+		return &SetupError{Op: "mkdir", Path: oldRoot, Err: err}
+	}
+	if err := syscall.PivotRoot(setupRoot, setupRoot+oldRoot); err != nil {
+		return &SetupError{Op: "pivot_root", Path: setupRoot, Err: err}
+	}
+	if err := chdir("/"); err != nil {
+		return err
+	}
+
+	for _, m := range p.Mounts {
+		if err := applyMount(m); err != nil {
+			return err
+		}
+	}
+	if p.UnshareNet {
+		if err := loopbackUp(); err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Unmount(oldRoot, syscall.MNT_DETACH); err != nil {
+		return &SetupError{Op: "detach old root", Path: oldRoot, Err: err}
+	}
+	if err := os.Remove(oldRoot); err != nil {
+		return &SetupError{Op: "remove", Path: oldRoot, Err: err}
+	}
+	return mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, "")
+}
+
+func applyMount(m Mount) error {
+	switch m.Kind {
+	case MountBind, MountROBind:
+		source := filepath.Join(oldRoot, m.Source)
+		info, err := os.Stat(source)
+		if err != nil {
+			return &SetupError{Op: "stat bind source", Path: m.Source, Err: err}
+		}
+		if err := makeTarget(m.Target, info.IsDir()); err != nil {
+			return err
+		}
+		if m.Kind == MountBind {
+			return mount(source, m.Target, "", syscall.MS_BIND|syscall.MS_REC, "")
+		}
+		if err := mount(source, m.Target, "", syscall.MS_BIND, ""); err != nil {
+			return err
+		}
+		return remountReadOnly(m.Target)
+	case MountTmpfs:
+		if err := makeTarget(m.Target, true); err != nil {
+			return err
+		}
+		return mount("tmpfs", m.Target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755")
+	case MountProc:
+		if err := makeTarget(m.Target, true); err != nil {
+			return err
+		}
+		return mount("proc", m.Target, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+	case MountDev:
+		return mountDev(m.Target)
+	case MountOverlay:
+		return mountOverlay(m)
+	default:
+		return &SetupError{Op: "mount", Path: m.Target, Err: errors.New("unknown mount kind " + string(m.Kind))}
+	}
+}
+
+// mountOverlay mounts the overlay of m from the host paths under the old root.
+// Mounted in the sandbox's user namespace, overlayfs keeps its metadata in
+// user xattrs.
+func mountOverlay(m Mount) error {
+	layers := isoshared.Overlay{Upper: filepath.Join(oldRoot, m.Upper), Work: filepath.Join(oldRoot, m.Work)}
+	options, err := layers.Options(filepath.Join(oldRoot, m.Source))
+	if err != nil {
+		return &SetupError{Op: "mount overlay", Path: m.Target, Err: err}
+	}
+	if err := makeTarget(m.Target, true); err != nil {
+		return err
+	}
+	return mount("overlay", m.Target, "overlay", syscall.MS_NOSUID|syscall.MS_NODEV, options+",userxattr")
+}
+
+// mountDev builds a minimal /dev at target.
+func mountDev(target string) error {
+	if err := makeTarget(target, true); err != nil {
+		return err
+	}
+	if err := mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, "mode=0755"); err != nil {
+		return err
+	}
+	for _, node := range devNodes {
+		source := filepath.Join(oldRoot, "dev", node)
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		dst := filepath.Join(target, node)
+		if err := makeTarget(dst, false); err != nil {
+			return err
+		}
+		if err := mount(source, dst, "", syscall.MS_BIND, ""); err != nil {
+			return err
+		}
+	}
+	for name, link := range devLinks {
+		if err := os.Symlink(link, filepath.Join(target, name)); err != nil {
+			return &SetupError{Op: "symlink", Path: filepath.Join(target, name), Err: err}
+		}
+	}
+	for _, dir := range []string{"pts", "shm"} {
+		if err := makeTarget(filepath.Join(target, dir), true); err != nil {
+			return err
+		}
+	}
+	if err := mount("devpts", filepath.Join(target, "pts"), "devpts", syscall.MS_NOSUID|syscall.MS_NOEXEC, "newinstance,ptmxmode=0666,mode=620"); err != nil {
+		return err
+	}
+	return mount("tmpfs", filepath.Join(target, "shm"), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777")
+}
+
+// makeTarget creates a mount point: a directory, or an empty file to bind a
+// non-directory onto.
+func makeTarget(target string, dir bool) error {
+	if dir {
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return &SetupError{Op: "mkdir", Path: target, Err: err}
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return &SetupError{Op: "mkdir", Path: filepath.Dir(target), Err: err}
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return &SetupError{Op: "create mount point", Path: target, Err: err}
+	}
+	return f.Close()
+}
+
+func mount(source, target, fstype string, flags uintptr, data string) error {
+	if err := syscall.Mount(source, target, fstype, flags, data); err != nil {
+		op := "mount " + fstype
+		if flags&syscall.MS_BIND != 0 {
+			op = "bind " + source
+		}
+		return &SetupError{Op: op, Path: target, Err: err}
+	}
+	return nil
+}
+
+// remountReadOnly makes a bind read-only. A user namespace may not clear the
+// flags the host mount was locked with, so they are carried over.
+func remountReadOnly(target string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(target, &st); err != nil {
+		return &SetupError{Op: "statfs", Path: target, Err: err}
+	}
+	return mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|lockedFlags(int64(st.Flags)), "")
+}
+
+// lockedFlags maps statfs flags (ST_*) to the mount flags (MS_*) a remount
+// must keep. They share values except for relatime.
+func lockedFlags(statFlags int64) uintptr {
+	const stRelatime = 0x1000
+	flags := uintptr(statFlags) & (mountLockedFlagsMS &^ syscall.MS_RELATIME)
+	if statFlags&stRelatime != 0 {
+		flags |= syscall.MS_RELATIME
+	}
+	return flags
+}
+
+// ifreqFlags is struct ifreq as SIOCGIFFLAGS and SIOCSIFFLAGS use it.
+type ifreqFlags struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// loopbackUp brings up lo in the fresh network namespace, so the in-sandbox
+// relay can listen on 127.0.0.1.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return &SetupError{Op: "socket", Path: "lo", Err: err}
+	}
+	defer syscall.Close(fd)
+	var req ifreqFlags
+	copy(req.name[:], "lo")
+	for _, op := range []uintptr{syscall.SIOCGIFFLAGS, syscall.SIOCSIFFLAGS} {
+		if op == syscall.SIOCSIFFLAGS {
+			req.flags |= syscall.IFF_UP
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), op, uintptr(unsafe.Pointer(&req))); errno != 0 {
+			return &SetupError{Op: "bring up", Path: "lo", Err: errno}
+		}
+	}
+	return nil
+}
+
+// capHeader and capData are the v3 capget/capset structures.
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective, permitted, inheritable uint32
+}
+
+// dropPrivileges sets no-new-privileges and empties the bounding, ambient,
+// effective, permitted and inheritable capability sets, so the agent holds no
+// capability in the user namespace even when the caller is root.
+func dropPrivileges() error {
+	if _, _, errno := syscall.Syscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return &SetupError{Op: "set no_new_privs", Path: "/", Err: errno}
+	}
+	for c := uintptr(0); c <= lastCapability; c++ {
+		if _, _, errno := syscall.Syscall6(syscall.SYS_PRCTL, prCapBSetDrop, c, 0, 0, 0, 0); errno != 0 && errno != syscall.EINVAL {
+			return &SetupError{Op: "drop bounding capability", Path: "/", Err: errno}
+		}
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClear, 0, 0, 0, 0); errno != 0 && errno != syscall.EINVAL {
+		return &SetupError{Op: "clear ambient capabilities", Path: "/", Err: errno}
+	}
+	header := capHeader{version: linuxCapVersion3}
+	var data [2]capData
+	if _, _, errno := syscall.Syscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return &SetupError{Op: "clear capabilities", Path: "/", Err: errno}
+	}
+	return nil
+}
+
+// reap waits for children until the agent exits, collecting the orphans the
+// sandbox's PID 1 inherits, and returns the agent's exit code. Every other
+// process in the namespace is killed when PID 1 exits.
+func reap(agent int) int {
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return 1
+		}
+		if pid != agent {
+			continue
+		}
+		if status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return status.ExitStatus()
+	}
+}
@@ -0,0 +1,310 @@
+// Package native is the isolation=native leaf: the bwrap leaf's namespace
+// confinement built by agent-cli itself, with no bubblewrap binary.
+package native
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/agentcmd"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/envutil"
+)
+
+// passthroughDirs are the host system directories HostPassthrough binds
+// read-only, matching the bwrap leaf.
+var passthroughDirs = []string{"/usr", "/lib", "/lib64", "/bin", "/etc"}
+
+// Isolator confines the agent in user, mount, PID, UTS, IPC and cgroup
+// namespaces (plus network for none and proxy) that agent-cli creates itself:
+// Run re-executes agent-cli as the hidden ExecCommandName stage, which clones
+// the init stage into the namespaces; the init stage pivots into a tmpfs root
+// carrying the mount plan and runs the agent as a child of the sandbox's PID 1.
+//
+// The plan is the bwrap leaf's, as data: a minimal /dev, /proc, a tmpfs /tmp,
+// a sandbox-local HOME (tmpfs) with only the curated config paths bound back
+// read-only, the workspace (rw), RepoDir (ro), the provisioned closure (ro) and
+// the caller's binds; the environment is reduced to the same allowlist.
+// HostPassthrough adds host /usr,/lib,/bin,/etc read-only and the host PATH.
+// The root is read-only once built, and the agent starts with
+// no-new-privileges and no capabilities.
+//
+// Like bwrap this is attack-surface reduction, not a kernel trust boundary
+// (see KernelIsolated).
+type Isolator struct{}
+
+// NewIsolator creates a native namespace isolator.
+func NewIsolator() *Isolator { return &Isolator{} }
+
+// Available reports whether the kernel lets agent-cli create a user namespace.
+func (i *Isolator) Available() (bool, error) { return isoshared.UserNamespacesAvailable(), nil }
+
+// HidesHost reports that host tools are off PATH and not bind-reachable in
+// deny-default mode; HostPassthrough forfeits the guarantee.
+func (i *Isolator) HidesHost(passthrough bool, _ string) bool { return !passthrough }
+
+// PinnedProvision mirrors the selected provisioner's immutability because the
+// namespaces supply no image-based tools of their own.
+func (i *Isolator) PinnedProvision(_ provision.ProvisionMethod, provisionerPinned bool, _ string) bool {
+	return provisionerPinned
+}
+
+// KernelIsolated reports false: the agent shares the host kernel.
+func (i *Isolator) KernelIsolated(_ string) bool { return false }
+
+// FiltersSyscalls reports false: the init stage installs no seccomp filter.
+func (i *Isolator) FiltersSyscalls(string) bool { return false }
+
+// Cost reports namespaces set up by the exec stage itself, as bwrap would.
+func (i *Isolator) Cost(string) int { return 2 }
+
+// CopyOnWrite reports that the init stage mounts the work directory as an
+// overlay.
+func (i *Isolator) CopyOnWrite() bool { return true }
+
+// Run executes the agent under the native exec stage.
+func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int, error) {
+	command, env, err := i.TerminalCommand(cfg, c)
+	if err != nil {
+		return 1, err
+	}
+	return isoshared.SpawnSandboxWithOptions(command[0], command[1:], env, isoshared.SpawnOptions{Cgroup: cfg.Cgroup, Usage: cfg.Usage, Timeout: cfg.Timeout}, "Native sandbox", cfg.Verbose)
+}
+
+// Command returns the full exec-stage command (for display / terminal wrappers).
+func (i *Isolator) Command(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
+	plan, err := i.buildPlan(cfg, c)
+	if err != nil {
+		return nil, err
+	}
+	self, err := executable()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{self}, plan.Args()...), nil
+}
+
+// TerminalCommand returns the exec-stage command plus the environment used to
+// launch it; the stage passes only the allowlisted names into the sandbox.
+func (i *Isolator) TerminalCommand(cfg isoshared.RunConfig, c provision.Contribution) ([]string, []string, error) {
+	command, err := i.Command(cfg, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	env, err := i.processEnv(cfg, c)
+	return command, env, err
+}
+
+// Exposure reports the binds of the mount plan and the names it passes into
+// the sandbox.
+func (i *Isolator) Exposure(cfg isoshared.RunConfig, c provision.Contribution) (isoshared.Exposure, error) {
+	plan, err := i.buildPlan(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	var binds []isoshared.Bind
+	for _, m := range plan.Mounts {
+		if m.Kind.takesSource() {
+			binds = append(binds, isoshared.Bind{Source: m.Source, Target: m.Target, ReadOnly: m.Kind != MountBind})
+		}
+	}
+	return isoshared.Exposure{Binds: binds, Env: plan.Env}, nil
+}
+
+// executable returns the canonical path of the running agent-cli, which runs
+// the exec stage.
+func executable() (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locate agent-cli executable for the native stage: %w", err)
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		return "", fmt.Errorf("resolve agent-cli executable for the native stage: %w", err)
+	}
+	return self, nil
+}
+
+func (i *Isolator) buildPlan(cfg isoshared.RunConfig, c provision.Contribution) (Plan, error) {
+	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
+	if err != nil {
+		return Plan{}, err
+	}
+	workDir, err := isoshared.ResolveDirectory(cfg.WorkDir, "work directory")
+	if err != nil {
+		return Plan{}, err
+	}
+	repoDir := ""
+	if cfg.RepoDir != "" {
+		repoDir, err = isoshared.ResolveDirectory(cfg.RepoDir, "repository directory")
+		if err != nil {
+			return Plan{}, err
+		}
+	}
+
+	plan := Plan{
+		Mounts: []Mount{
+			// Minimal devtmpfs, not the host /dev with its disks and memory.
+			{Kind: MountDev, Target: "/dev"},
+			{Kind: MountProc, Target: "/proc"},
+			{Kind: MountTmpfs, Target: "/tmp"},
+		},
+		Dir: workDir,
+	}
+
+	// Network is applied explicitly through the network bridge.
+	unshareNet, netMounts, err := networkMounts(cfg.Network, cfg.Relay)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan.UnshareNet = unshareNet
+	plan.Mounts = append(plan.Mounts, netMounts...)
+
+	// Sandbox-local HOME: a tmpfs at the home path (no host-$HOME bind), with only
+	// the curated config paths bound back read-only.
+	plan.Mounts = append(plan.Mounts, Mount{Kind: MountTmpfs, Target: homeDir})
+	for _, configPath := range isolation.UserConfigPaths(cfg.Agent.Type) {
+		src, exists, err := isoshared.ResolveContainedOptionalPath(homeDir, configPath, "user config path")
+		if err != nil {
+			return Plan{}, err
+		}
+		if exists {
+			plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: src, Target: filepath.Join(homeDir, configPath)})
+		}
+	}
+
+	// Workspace (rw, or copy-on-write for a reviewed run) + source repo (ro for
+	// worktrees).
+	if cfg.Overlay.Enabled() {
+		if _, err := cfg.Overlay.Options(workDir); err != nil {
+			return Plan{}, err
+		}
+		plan.Mounts = append(plan.Mounts, Mount{Kind: MountOverlay, Source: workDir, Upper: cfg.Overlay.Upper, Work: cfg.Overlay.Work, Target: workDir})
+	} else {
+		plan.Mounts = append(plan.Mounts, Mount{Kind: MountBind, Source: workDir, Target: workDir})
+	}
+	if repoDir != "" && repoDir != workDir {
+		plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: repoDir, Target: repoDir})
+	}
+
+	// HostPassthrough: restore host tool reachability (leaky mode).
+	if cfg.HostPassthrough {
+		for _, dir := range passthroughDirs {
+			if _, err := os.Stat(dir); err == nil {
+				plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: dir, Target: dir})
+			}
+		}
+	}
+
+	// Contribution: read-only binds of the provisioned closure's requisites.
+	for _, p := range c.RoBindPaths {
+		resolved, err := isoshared.ResolveExistingPath(p, "provisioned read-only bind")
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: resolved, Target: resolved})
+	}
+
+	// Caller-supplied extra binds.
+	for _, path := range cfg.BindPaths {
+		resolved, err := isoshared.ResolveExistingPath(path, "read-write bind")
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Mounts = append(plan.Mounts, Mount{Kind: MountBind, Source: resolved, Target: resolved})
+	}
+	for _, path := range cfg.RoBindPaths {
+		resolved, err := isoshared.ResolveExistingPath(path, "read-only bind")
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: resolved, Target: resolved})
+	}
+
+	// Protected workspace paths, bound read-only over every writable bind
+	// above.
+	protected, err := isoshared.ResolveProtectedPaths(workDir, cfg.Protect)
+	if err != nil {
+		return Plan{}, err
+	}
+	for _, path := range protected {
+		plan.Mounts = append(plan.Mounts, Mount{Kind: MountROBind, Source: path, Target: path})
+	}
+
+	sandboxEnv, err := i.sandboxEnv(cfg, c, homeDir)
+	if err != nil {
+		return Plan{}, err
+	}
+	for key := range sandboxEnv {
+		plan.Env = append(plan.Env, key)
+	}
+	sort.Strings(plan.Env)
+
+	// Agent command, wrapped with the provisioner's init commands and, in proxy
+	// mode, the egress relay.
+	agentCmd := agentcmd.BuildAgentCommand(cfg.Agent, cfg.Provider, cfg.AgentArgs, cfg.Prompt, "")
+	plan.Command = networkCommand(cfg.Network, cfg.Relay, isoshared.WrapWithInitCommands(agentCmd, c.InitCommands))
+	return plan, nil
+}
+
+func (i *Isolator) processEnv(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
+	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
+	if err != nil {
+		return nil, err
+	}
+	sandboxEnv, err := i.sandboxEnv(cfg, c, homeDir)
+	if err != nil {
+		return nil, err
+	}
+	return envutil.EnvMapToSlice(envutil.MergeEnvMaps(envutil.ParseEnv(os.Environ()), sandboxEnv)), nil
+}
+
+// sandboxEnv builds the explicit environment allowlist for inside the sandbox:
+// HOME/PATH/TMPDIR/SHELL, the contribution's env, the provider tokens, the proxy
+// variables in proxy mode, and the caller's custom env. API keys reach the
+// sandbox only through this allowlist.
+func (i *Isolator) sandboxEnv(cfg isoshared.RunConfig, c provision.Contribution, homeDir string) (map[string]string, error) {
+	env := map[string]string{
+		"HOME":   homeDir,
+		"TMPDIR": "/tmp",
+		"SHELL":  "/bin/bash",
+	}
+
+	// PATH: the contribution's tool dirs first, then standard dirs (resolvable
+	// only under HostPassthrough), then the host PATH when passing through.
+	pathEntries := append([]string{}, c.PathEntries...)
+	pathEntries = append(pathEntries, "/usr/local/bin", "/usr/bin", "/bin")
+	if cfg.HostPassthrough {
+		if hostPath := os.Getenv("PATH"); hostPath != "" {
+			pathEntries = append(pathEntries, hostPath)
+		}
+	}
+	env["PATH"] = strings.Join(pathEntries, ":")
+
+	// Provider tokens + contribution env + custom env.
+	providerEnv, err := agentcmd.BuildProviderEnv(cfg.Agent, cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range providerEnv {
+		env[k] = v
+	}
+	for k, v := range c.Env {
+		env[k] = v
+	}
+	for k, v := range networkEnv(cfg.Network) {
+		env[k] = v
+	}
+	customEnv, err := envutil.ParseCustomEnv(cfg.CustomEnv)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range customEnv {
+		env[k] = v
+	}
+	return env, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package native
+
+// Exec always fails: the namespaces are a Linux feature.
+func Exec(Plan) (int, error) { return 1, ErrUnsupported }
+
+// Init always fails: the namespaces are a Linux feature.
+func Init(Plan) int { return 1 }
@@ -0,0 +1,299 @@
+package native
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+// claudeCfg builds a run config rooted in an empty temporary home directory, so
+// the plan depends on the config paths a test creates rather than on the host
+// user's configuration.
+func claudeCfg(t *testing.T, net netshared.Mode, passthrough bool, workDir string) isoshared.RunConfig {
+	t.Helper()
+	return isoshared.RunConfig{
+		Agent:           &types.AgentConfig{Type: types.AgentClaude, Binary: "claude"},
+		WorkDir:         workDir,
+		HomeDir:         t.TempDir(),
+		Network:         net,
+		HostPassthrough: passthrough,
+	}
+}
+
+// canonicalPath resolves the symlinks a bind source is reported through, which
+// is how the isolator names a path it mounts.
+func canonicalPath(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatalf("EvalSymlinks(%q) error = %v", path, err)
+	}
+	return resolved
+}
+
+func buildPlan(t *testing.T, cfg isoshared.RunConfig, c provision.Contribution) Plan {
+	t.Helper()
+	plan, err := NewIsolator().buildPlan(cfg, c)
+	if err != nil {
+		t.Fatalf("buildPlan() error = %v", err)
+	}
+	return plan
+}
+
+// mountIndex returns the position of m in the plan, or -1.
+func mountIndex(p Plan, m Mount) int { return slices.Index(p.Mounts, m) }
+
+func TestNative_DenyDefaultPlan(t *testing.T) {
+	work := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeNone, false, work)
+	plan := buildPlan(t, cfg, provision.Contribution{})
+
+	wantPrefix := []Mount{
+		{Kind: MountDev, Target: "/dev"},
+		{Kind: MountProc, Target: "/proc"},
+		{Kind: MountTmpfs, Target: "/tmp"},
+	}
+	if len(plan.Mounts) < len(wantPrefix) || !slices.Equal(plan.Mounts[:len(wantPrefix)], wantPrefix) {
+		t.Errorf("plan starts with %v, want %v", plan.Mounts, wantPrefix)
+	}
+	homeDir := canonicalPath(t, cfg.HomeDir)
+	if mountIndex(plan, Mount{Kind: MountTmpfs, Target: homeDir}) < 0 {
+		t.Error("HOME must be a sandbox-local tmpfs")
+	}
+	workDir := canonicalPath(t, work)
+	if mountIndex(plan, Mount{Kind: MountBind, Source: workDir, Target: workDir}) < 0 || plan.Dir != workDir {
+		t.Errorf("plan = %+v, want the workspace bound read-write and used as the working directory", plan)
+	}
+	for _, m := range plan.Mounts {
+		if m.Source == homeDir || slices.Contains(passthroughDirs, m.Source) {
+			t.Errorf("deny-default plan binds %q", m.Source)
+		}
+	}
+	if !plan.UnshareNet {
+		t.Error("network none must unshare the network")
+	}
+}
+
+// The curated config paths are bound back read-only after HOME's tmpfs, so
+// they land inside it rather than being hidden by it.
+func TestNative_BindsUserConfigPathsAfterTheHomeTmpfs(t *testing.T) {
+	cfg := claudeCfg(t, netshared.ModeHost, false, t.TempDir())
+	if err := os.Mkdir(filepath.Join(cfg.HomeDir, ".claude"), 0o755); err != nil {
+		t.Fatalf("create config dir: %v", err)
+	}
+
+	plan := buildPlan(t, cfg, provision.Contribution{})
+
+	homeDir := canonicalPath(t, cfg.HomeDir)
+	home := mountIndex(plan, Mount{Kind: MountTmpfs, Target: homeDir})
+	config := mountIndex(plan, Mount{Kind: MountROBind, Source: canonicalPath(t, filepath.Join(cfg.HomeDir, ".claude")), Target: filepath.Join(homeDir, ".claude")})
+	if home < 0 || config < home {
+		t.Errorf("plan = %v, want the config path bound read-only after the HOME tmpfs", plan.Mounts)
+	}
+	if plan.UnshareNet {
+		t.Error("network host must share the network")
+	}
+}
+
+func TestNative_HostPassthroughAndContribution(t *testing.T) {
+	work := t.TempDir()
+	closure := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeHost, true, work)
+	c := provision.Contribution{
+		RoBindPaths:  []string{closure},
+		PathEntries:  []string{"/nix/store/abc/bin"},
+		InitCommands: []string{"echo hi"},
+	}
+
+	plan := buildPlan(t, cfg, c)
+
+	if _, err := os.Stat("/usr"); err == nil && mountIndex(plan, Mount{Kind: MountROBind, Source: "/usr", Target: "/usr"}) < 0 {
+		t.Error("HostPassthrough must bind host /usr read-only")
+	}
+	if mountIndex(plan, Mount{Kind: MountROBind, Source: closure, Target: closure}) < 0 {
+		t.Error("contribution RoBindPaths must be bound read-only")
+	}
+	if joined := strings.Join(plan.Command, " "); !strings.Contains(joined, "sh -c") || !strings.Contains(joined, "echo hi") {
+		t.Errorf("Command = %v, want the agent wrapped with its init commands", plan.Command)
+	}
+}
+
+// Proxy mode binds in only the proxy socket and the relay, which wraps the
+// agent; HTTPS_PROXY reaches the sandbox through the allowlist.
+func TestNative_NetworkProxyFunnelsThroughRelay(t *testing.T) {
+	work := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeProxy, false, work)
+	if _, err := NewIsolator().buildPlan(cfg, provision.Contribution{}); !errors.Is(err, netshared.ErrProxyEnforcementUnavailable) {
+		t.Errorf("network proxy without transport error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+	}
+
+	cfg.Relay = netshared.RelayTransport{ProxySocket: "/tmp/agent-cli-egress/egress.sock", RelayBinary: "/usr/local/bin/agent-cli"}
+	plan := buildPlan(t, cfg, provision.Contribution{})
+
+	if !plan.UnshareNet {
+		t.Error("network proxy must unshare the network")
+	}
+	for _, want := range []Mount{
+		{Kind: MountROBind, Source: cfg.Relay.ProxySocket, Target: netshared.SandboxProxySocket},
+		{Kind: MountROBind, Source: cfg.Relay.RelayBinary, Target: netshared.SandboxRelayBinary},
+	} {
+		if mountIndex(plan, want) < 0 {
+			t.Errorf("plan = %v, want %+v", plan.Mounts, want)
+		}
+	}
+	if len(plan.Command) < 2 || plan.Command[0] != netshared.SandboxRelayBinary || plan.Command[1] != netshared.RelayCommandName {
+		t.Errorf("Command = %v, want the relay wrapping the agent", plan.Command)
+	}
+	if !slices.Contains(plan.Env, "HTTPS_PROXY") {
+		t.Errorf("Env = %v, want HTTPS_PROXY", plan.Env)
+	}
+}
+
+// The command re-executes agent-cli as the exec stage, and provider secrets
+// reach the sandbox only through the stage's environment.
+func TestNative_CommandRunsTheExecStage(t *testing.T) {
+	const secret = "native-secret-must-not-appear"
+	t.Setenv("NATIVE_TEST_TOKEN", secret)
+	t.Setenv("NATIVE_UNRELATED_HOST_VALUE", "must-not-enter-sandbox")
+	cfg := claudeCfg(t, netshared.ModeNone, false, t.TempDir())
+	cfg.Provider = &types.ModelProvider{
+		CredentialSourceEnv: "NATIVE_TEST_TOKEN",
+		CredentialTargetEnv: "ANTHROPIC_AUTH_TOKEN",
+	}
+
+	command, env, err := NewIsolator().TerminalCommand(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("TerminalCommand() error = %v", err)
+	}
+
+	self, err := executable()
+	if err != nil {
+		t.Fatalf("executable() error = %v", err)
+	}
+	if command[0] != self || command[1] != ExecCommandName {
+		t.Errorf("command = %v, want %s %s", command[:2], self, ExecCommandName)
+	}
+	joined := strings.Join(command, " ")
+	if strings.Contains(joined, secret) {
+		t.Fatal("exec stage arguments contain the provider secret")
+	}
+	if !strings.Contains(joined, "--env ANTHROPIC_AUTH_TOKEN ") || strings.Contains(joined, "NATIVE_UNRELATED_HOST_VALUE") {
+		t.Errorf("command = %v, want only the allowlisted names", command)
+	}
+	if !slices.Contains(env, "ANTHROPIC_AUTH_TOKEN="+secret) {
+		t.Error("the stage environment must carry the token for --env to pass through")
+	}
+	if _, err := ParseArgs(command[2:]); err != nil {
+		t.Errorf("ParseArgs(command) error = %v", err)
+	}
+}
+
+func TestNative_CommandRejectsMissingPaths(t *testing.T) {
+	work := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeNone, false, work)
+	cfg.RoBindPaths = []string{filepath.Join(work, "missing")}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want missing bind error")
+	}
+	cfg.RoBindPaths = nil
+	cfg.WorkDir = filepath.Join(work, "missing")
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want missing work directory error")
+	}
+}
+
+// A reviewed run mounts the workspace as an overlay, which the exposure
+// reports as read-only.
+func TestNative_OverlayMountsTheWorkspaceCopyOnWrite(t *testing.T) {
+	work := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeNone, false, work)
+	cfg.Overlay = isoshared.Overlay{Upper: "/layers/upper", Work: "/layers/work"}
+	workDir := canonicalPath(t, work)
+
+	plan := buildPlan(t, cfg, provision.Contribution{})
+	overlay := Mount{Kind: MountOverlay, Source: workDir, Upper: "/layers/upper", Work: "/layers/work", Target: workDir}
+	if mountIndex(plan, overlay) < 0 || mountIndex(plan, Mount{Kind: MountBind, Source: workDir, Target: workDir}) >= 0 {
+		t.Errorf("Mounts = %+v, want the workspace as an overlay only", plan.Mounts)
+	}
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	if want := (isoshared.Bind{Source: workDir, Target: workDir, ReadOnly: true}); !slices.Contains(exposure.Binds, want) {
+		t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+	}
+}
+
+func TestNative_Capabilities(t *testing.T) {
+	i := NewIsolator()
+	if !i.HidesHost(false, "") || i.HidesHost(true, "") {
+		t.Error("native hides host tools exactly when passthrough is off")
+	}
+	if !i.PinnedProvision(provision.ProvisionNix, true, "") || i.PinnedProvision(provision.ProvisionNone, false, "") {
+		t.Error("native pinning must mirror the provisioner")
+	}
+	if i.KernelIsolated("runsc") {
+		t.Error("native is never a kernel boundary")
+	}
+	if i.FiltersSyscalls("default") {
+		t.Error("native installs no syscall filter")
+	}
+	if !i.RestrictsEgress(netshared.ModeNone) || i.RestrictsEgress(netshared.ModeHost) {
+		t.Error("native enforces network none in its own namespace")
+	}
+	if i.Cost("") != 2 {
+		t.Errorf("Cost() = %d, want 2", i.Cost(""))
+	}
+}
+
+// Exposure reports the plan's binds, not its tmpfs and proc mounts.
+func TestNative_ExposureReportsTheBinds(t *testing.T) {
+	work := t.TempDir()
+	closure := t.TempDir()
+	cfg := claudeCfg(t, netshared.ModeHost, false, work)
+
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{RoBindPaths: []string{closure}})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	for _, want := range []isoshared.Bind{
+		{Source: canonicalPath(t, work), Target: canonicalPath(t, work)},
+		{Source: canonicalPath(t, closure), Target: canonicalPath(t, closure), ReadOnly: true},
+	} {
+		if !slices.Contains(exposure.Binds, want) {
+			t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+		}
+	}
+	for _, bind := range exposure.Binds {
+		if bind.Source == "" {
+			t.Errorf("Exposure().Binds has a sourceless mount %+v", bind)
+		}
+	}
+}
+
+// A protected workspace path is bound read-only after the writable
+// workspace, so it is mounted over it.
+func TestNative_ProtectBindsWorkspacePathsReadOnly(t *testing.T) {
+	work := t.TempDir()
+	if err := os.WriteFile(filepath.Join(work, "flake.lock"), nil, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	cfg := claudeCfg(t, netshared.ModeNone, false, work)
+	cfg.Protect = []string{"*.lock"}
+	workDir := canonicalPath(t, work)
+	lock := filepath.Join(workDir, "flake.lock")
+
+	plan := buildPlan(t, cfg, provision.Contribution{})
+	protected := mountIndex(plan, Mount{Kind: MountROBind, Source: lock, Target: lock})
+	if protected < 0 || protected < mountIndex(plan, Mount{Kind: MountBind, Source: workDir, Target: workDir}) {
+		t.Errorf("Mounts = %+v, want %s bound read-only after the workspace", plan.Mounts, lock)
+	}
+}
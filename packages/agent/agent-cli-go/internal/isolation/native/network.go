@@ -0,0 +1,46 @@
+package native
+
+import netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+
+// networkMounts reports whether the mode unshares the network and returns the
+// read-only binds it adds. None and proxy unshare the network and bind only the
+// relay binary and its sockets in (the proxy socket for proxy, one socket per
+// loopback forward), so the allowlist proxy and the forwarded endpoints are the
+// sandbox's sole routes out.
+//
+// This is a cross-axis bridge (isolation -> network/shared): the dependent
+// isolation leaf owns the glue; network/shared never reaches back into isolation.
+func networkMounts(m netshared.Mode, relay netshared.RelayTransport) (bool, []Mount, error) {
+	if m == netshared.ModeHost {
+		return false, nil, nil
+	}
+	binds, err := netshared.RelayBinds(m, relay)
+	if err != nil {
+		return false, nil, err
+	}
+	mounts := make([]Mount, 0, len(binds))
+	for _, bind := range binds {
+		mounts = append(mounts, Mount{Kind: MountROBind, Source: bind.Host, Target: bind.Sandbox})
+	}
+	return true, mounts, nil
+}
+
+// networkCommand wraps the agent command with the in-sandbox relay when the mode
+// routes anything through it.
+func networkCommand(m netshared.Mode, relay netshared.RelayTransport, command []string) []string {
+	return netshared.RelayCommand(m, relay, command)
+}
+
+// networkEnv returns the environment the mode adds inside the sandbox.
+func networkEnv(m netshared.Mode) map[string]string {
+	if m != netshared.ModeProxy {
+		return nil
+	}
+	return netshared.ProxyEnv()
+}
+
+// RestrictsEgress reports that a restricted mode is fully enforced: the
+// sandbox has no network route of its own beyond the relay.
+func (i *Isolator) RestrictsEgress(m netshared.Mode) bool {
+	return netshared.EgressIsRestricted(m)
+}
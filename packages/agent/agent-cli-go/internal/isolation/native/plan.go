@@ -0,0 +1,235 @@
+package native
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	// ExecCommandName is the hidden agent-cli subcommand that creates the
+	// namespaces and waits for the sandbox; it runs in the host namespaces.
+	ExecCommandName = "native-exec"
+	// InitCommandName is the hidden agent-cli subcommand the exec stage
+	// re-executes inside the new namespaces: it realizes the mount plan,
+	// pivots into it and runs the agent as the sandbox's PID 1.
+	InitCommandName = "native-init"
+)
+
+// ErrUnsupported reports a host that cannot create the namespaces: a non-Linux
+// OS, or a kernel with unprivileged user namespaces disabled.
+var ErrUnsupported = errors.New("native isolation needs Linux with unprivileged user namespaces")
+
+// MountKind is what a Mount places at its target.
+type MountKind string
+
+const (
+	// MountBind binds a host path read-write, with its submounts.
+	MountBind MountKind = "bind"
+	// MountROBind binds a host path read-only. The bind is not recursive, so
+	// no writable submount comes along.
+	MountROBind MountKind = "ro-bind"
+	// MountTmpfs mounts an empty tmpfs.
+	MountTmpfs MountKind = "tmpfs"
+	// MountProc mounts a proc filesystem for the sandbox's PID namespace.
+	MountProc MountKind = "proc"
+	// MountDev mounts a minimal /dev: a tmpfs holding the null, zero, full,
+	// random, urandom and tty nodes, a private devpts instance and /dev/shm.
+	MountDev MountKind = "dev"
+	// MountOverlay mounts an overlayfs of a host path whose writes land in a
+	// host upper directory, leaving the path itself untouched.
+	MountOverlay MountKind = "overlay"
+)
+
+// takesSource reports whether the kind mounts a host path.
+func (k MountKind) takesSource() bool { return k == MountBind || k == MountROBind || k == MountOverlay }
+
+// Mount is one step of the mount plan. Source is a host path and is set only
+// for binds and overlays; Upper and Work are the host directories of an
+// overlay; Target is the path inside the sandbox.
+type Mount struct {
+	Kind   MountKind
+	Source string
+	Upper  string
+	Work   string
+	Target string
+}
+
+// Plan is the sandbox the init stage builds, as data: the mounts in the order
+// they are applied on an empty tmpfs root, whether the network is unshared,
+// the agent's working directory, the names of the variables passed on to the
+// agent (their values come from the exec stage's own environment, so secrets
+// never enter argv), and the agent command.
+type Plan struct {
+	Mounts     []Mount
+	UnshareNet bool
+	Dir        string
+	Env        []string
+	Command    []string
+}
+
+// Args renders the plan as the arguments of the ExecCommandName subcommand.
+// Mounts keep their order, so they use bubblewrap's positional grammar.
+func (p Plan) Args() []string {
+	return append([]string{ExecCommandName}, p.flags()...)
+}
+
+// flags renders the plan without a subcommand name; ParseArgs reverses it.
+func (p Plan) flags() []string {
+	var args []string
+	for _, m := range p.Mounts {
+		args = append(args, "--"+string(m.Kind))
+		if m.Kind.takesSource() {
+			args = append(args, m.Source)
+		}
+		if m.Kind == MountOverlay {
+			args = append(args, m.Upper, m.Work)
+		}
+		args = append(args, m.Target)
+	}
+	if p.UnshareNet {
+		args = append(args, "--unshare-net")
+	}
+	if p.Dir != "" {
+		args = append(args, "--chdir", p.Dir)
+	}
+	for _, key := range p.Env {
+		args = append(args, "--env", key)
+	}
+	return append(append(args, "--"), p.Command...)
+}
+
+// ParseArgs parses the arguments Args renders, without the subcommand name.
+func ParseArgs(args []string) (Plan, error) {
+	var p Plan
+	for i := 0; i < len(args); i++ {
+		operands := func(n int) ([]string, error) {
+			if i+n >= len(args) {
+				return nil, fmt.Errorf("%s needs %d argument(s)", args[i], n)
+			}
+			values := args[i+1 : i+1+n]
+			i += n
+			return values, nil
+		}
+		switch flag := args[i]; flag {
+		case "--":
+			p.Command = append([]string{}, args[i+1:]...)
+			return p, p.validate()
+		case "--unshare-net":
+			p.UnshareNet = true
+		case "--chdir", "--env":
+			values, err := operands(1)
+			if err != nil {
+				return Plan{}, err
+			}
+			if flag == "--chdir" {
+				p.Dir = values[0]
+			} else {
+				p.Env = append(p.Env, values[0])
+			}
+		default:
+			kind, ok := strings.CutPrefix(flag, "--")
+			switch MountKind(kind) {
+			case MountBind, MountROBind, MountTmpfs, MountProc, MountDev, MountOverlay:
+			default:
+				ok = false
+			}
+			if !ok {
+				return Plan{}, fmt.Errorf("unknown argument %q", flag)
+			}
+			m := Mount{Kind: MountKind(kind)}
+			n := 1
+			if m.Kind.takesSource() {
+				n = 2
+			}
+			if m.Kind == MountOverlay {
+				n = 4
+			}
+			values, err := operands(n)
+			if err != nil {
+				return Plan{}, err
+			}
+			m.Target = values[n-1]
+			if m.Kind.takesSource() {
+				m.Source = values[0]
+			}
+			if m.Kind == MountOverlay {
+				m.Upper, m.Work = values[1], values[2]
+			}
+			p.Mounts = append(p.Mounts, m)
+		}
+	}
+	return Plan{}, errors.New("missing -- before the command")
+}
+
+// validate reports a plan the init stage cannot build.
+func (p Plan) validate() error {
+	if len(p.Command) == 0 {
+		return fmt.Errorf("%s: no command", ExecCommandName)
+	}
+	if p.Dir != "" && !filepath.IsAbs(p.Dir) {
+		return fmt.Errorf("%s: working directory %q is not absolute", ExecCommandName, p.Dir)
+	}
+	for _, m := range p.Mounts {
+		if !filepath.IsAbs(m.Target) || (m.Kind.takesSource() && !filepath.IsAbs(m.Source)) ||
+			(m.Kind == MountOverlay && (!filepath.IsAbs(m.Upper) || !filepath.IsAbs(m.Work))) {
+			return fmt.Errorf("%s: %s mount %q -> %q needs absolute paths", ExecCommandName, m.Kind, m.Source, m.Target)
+		}
+	}
+	return nil
+}
+
+// SetupError is a failed step of building the sandbox: the operation, the
+// sandbox path it acted on, and the cause (a syscall.Errno for a failed system
+// call). The init stage reports it to the exec stage, which returns it.
+type SetupError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *SetupError) Error() string {
+	return fmt.Sprintf("native sandbox setup: %s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *SetupError) Unwrap() error { return e.Err }
+
+// setupReport is the wire form of a SetupError on the init stage's report pipe.
+type setupReport struct {
+	Op      string `json:"op"`
+	Path    string `json:"path"`
+	Errno   int    `json:"errno,omitempty"`
+	Message string `json:"message"`
+}
+
+// encodeSetupError renders err for the report pipe, keeping the errno so the
+// exec stage can match it with errors.Is.
+func encodeSetupError(err error) []byte {
+	report := setupReport{Op: "start", Message: err.Error()}
+	var setupErr *SetupError
+	if errors.As(err, &setupErr) {
+		report.Op, report.Path, report.Message = setupErr.Op, setupErr.Path, setupErr.Err.Error()
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		report.Errno = int(errno)
+	}
+	data, _ := json.Marshal(report)
+	return data
+}
+
+// decodeSetupError rebuilds the SetupError the init stage reported.
+func decodeSetupError(data []byte) error {
+	var report setupReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return &SetupError{Op: "report", Err: fmt.Errorf("malformed init stage report %q: %w", data, err)}
+	}
+	var cause error = errors.New(report.Message)
+	if report.Errno != 0 {
+		cause = syscall.Errno(report.Errno)
+	}
+	return &SetupError{Op: report.Op, Path: report.Path, Err: cause}
+}
@@ -0,0 +1,99 @@
+package native
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestPlanArgsRoundTrip(t *testing.T) {
+	plan := Plan{
+		Mounts: []Mount{
+			{Kind: MountDev, Target: "/dev"},
+			{Kind: MountProc, Target: "/proc"},
+			{Kind: MountTmpfs, Target: "/home/user"},
+			{Kind: MountROBind, Source: "/home/user/.claude", Target: "/home/user/.claude"},
+			{Kind: MountBind, Source: "/work", Target: "/work"},
+			{Kind: MountOverlay, Source: "/src", Upper: "/layers/upper", Work: "/layers/work", Target: "/src"},
+		},
+		UnshareNet: true,
+		Dir:        "/work",
+		Env:        []string{"HOME", "PATH"},
+		Command:    []string{"claude", "--", "--print"},
+	}
+
+	args := plan.Args()
+	want := []string{
+		ExecCommandName,
+		"--dev", "/dev", "--proc", "/proc", "--tmpfs", "/home/user",
+		"--ro-bind", "/home/user/.claude", "/home/user/.claude",
+		"--bind", "/work", "/work",
+		"--overlay", "/src", "/layers/upper", "/layers/work", "/src",
+		"--unshare-net", "--chdir", "/work", "--env", "HOME", "--env", "PATH",
+		"--", "claude", "--", "--print",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("Args() = %v, want %v", args, want)
+	}
+	parsed, err := ParseArgs(args[1:])
+	if err != nil {
+		t.Fatalf("ParseArgs() error = %v", err)
+	}
+	if !reflect.DeepEqual(parsed, plan) {
+		t.Errorf("ParseArgs() = %+v, want %+v", parsed, plan)
+	}
+}
+
+func TestParseArgsRejectsMalformedPlans(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "unknown flag", args: []string{"--squashfs", "/x", "--", "true"}, want: "unknown argument"},
+		{name: "relative overlay upper", args: []string{"--overlay", "/work", "upper", "/layers/work", "/work", "--", "true"}, want: "absolute"},
+		{name: "bare word", args: []string{"bind", "/x", "--", "true"}, want: "unknown argument"},
+		{name: "missing operand", args: []string{"--bind", "/x"}, want: "needs 2 argument(s)"},
+		{name: "no separator", args: []string{"--tmpfs", "/tmp"}, want: "missing --"},
+		{name: "no command", args: []string{"--"}, want: "no command"},
+		{name: "relative target", args: []string{"--tmpfs", "tmp", "--", "true"}, want: "needs absolute paths"},
+		{name: "relative source", args: []string{"--ro-bind", "src", "/src", "--", "true"}, want: "needs absolute paths"},
+		{name: "relative dir", args: []string{"--chdir", "work", "--", "true"}, want: "not absolute"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseArgs(test.args); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("ParseArgs(%v) error = %v, want %q", test.args, err, test.want)
+			}
+		})
+	}
+}
+
+// A failed step crosses the report pipe with its errno, so the exec stage's
+// caller can match it.
+func TestSetupErrorCrossesTheReportPipe(t *testing.T) {
+	sent := &SetupError{Op: "bind /oldroot/work", Path: "/work", Err: syscall.EPERM}
+
+	got := decodeSetupError(encodeSetupError(sent))
+
+	var setupErr *SetupError
+	if !errors.As(got, &setupErr) || setupErr.Op != sent.Op || setupErr.Path != sent.Path {
+		t.Fatalf("decoded = %#v, want %#v", got, sent)
+	}
+	if !errors.Is(got, syscall.EPERM) {
+		t.Errorf("decoded error %v does not match EPERM", got)
+	}
+	if got.Error() != sent.Error() {
+		t.Errorf("Error() = %q, want %q", got.Error(), sent.Error())
+	}
+
+	plain := decodeSetupError(encodeSetupError(errors.New("exec: \"claude\": not found")))
+	if !strings.Contains(plain.Error(), "start") || !strings.Contains(plain.Error(), "not found") {
+		t.Errorf("decoded plain error = %v, want the start step and its message", plain)
+	}
+	if err := decodeSetupError([]byte("garbage")); !strings.Contains(err.Error(), "malformed") {
+		t.Errorf("decodeSetupError(garbage) = %v, want a malformed report error", err)
+	}
+}
@@ -42,6 +42,20 @@ func (i *Isolator) PinnedProvision(_ provision.ProvisionMethod, provisionerPinne
 // KernelIsolated reports false: there is no namespace or kernel boundary.
 func (i *Isolator) KernelIsolated(string) bool { return false }
 
+// FiltersSyscalls reports false: the agent runs unconfined on the host.
+func (i *Isolator) FiltersSyscalls(string) bool { return false }
+
+// Cost reports that host execution adds nothing.
+func (i *Isolator) Cost(string) int { return 0 }
+
+// CopyOnWrite reports that host execution has no mount namespace to overlay
+// the work directory in.
+func (i *Isolator) CopyOnWrite() bool { return false }
+
+// RestrictsEgress reports false: without a network namespace host execution
+// cannot restrict egress (Run refuses any mode but host).
+func (i *Isolator) RestrictsEgress(netshared.Mode) bool { return false }
+
 // Run executes the agent on the host. It fails CLOSED when a network restriction
 // is requested: with no namespace there is no way to enforce restricted egress.
 func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int, error) {
@@ -49,7 +63,7 @@ func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int,
 	if err != nil {
 		return 1, err
 	}
-	return isoshared.SpawnSandboxInDir(cmd[0], cmd[1:], env, cfg.WorkDir, "host execution", cfg.Verbose)
+	return isoshared.SpawnSandboxWithOptions(cmd[0], cmd[1:], env, isoshared.SpawnOptions{Dir: cfg.WorkDir, Cgroup: cfg.Cgroup, Usage: cfg.Usage, Timeout: cfg.Timeout}, "host execution", cfg.Verbose)
 }
 
 // Command returns the host command (for display / terminal wrappers).
@@ -66,10 +80,23 @@ func (i *Isolator) TerminalCommand(cfg isoshared.RunConfig, c provision.Contribu
 	return i.hostCommand(cfg, c)
 }
 
+// Exposure reports the whole host filesystem, read-write, and the agent's
+// full environment: host execution confines nothing.
+func (i *Isolator) Exposure(cfg isoshared.RunConfig, c provision.Contribution) (isoshared.Exposure, error) {
+	_, env, err := i.hostCommand(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	return isoshared.Exposure{Binds: []isoshared.Bind{{Source: "/", Target: "/"}}, Env: isoshared.EnvNames(envutil.ParseEnv(env))}, nil
+}
+
 // hostCommand builds the host command and environment, applying the Contribution.
 func (i *Isolator) hostCommand(cfg isoshared.RunConfig, c provision.Contribution) ([]string, []string, error) {
 	if cfg.Network != netshared.ModeHost {
-		return nil, nil, fmt.Errorf("isolation=none cannot restrict network egress (network=%q); use bwrap or docker", cfg.Network)
+		return nil, nil, fmt.Errorf("isolation=none cannot restrict network egress (network=%q); use bwrap, docker, podman, landlock or native", cfg.Network)
+	}
+	if len(cfg.Protect) > 0 {
+		return nil, nil, fmt.Errorf("isolation=none cannot keep workspace paths read-only; use bwrap, docker, podman or native")
 	}
 	if _, err := isoshared.ResolveDirectory(cfg.WorkDir, "work directory"); err != nil {
 		return nil, nil, err
@@ -84,7 +111,7 @@ func (i *Isolator) hostCommand(cfg isoshared.RunConfig, c provision.Contribution
 			return nil, nil, err
 		}
 	}
-	execOpts := types.AgentExecOptions{Sandbox: false, ProviderCliArgs: providerCliArgs}
+	execOpts := types.AgentExecOptions{Sandbox: false, ProviderCliArgs: providerCliArgs, Prompt: cfg.Prompt}
 
 	var agentArgs []string
 	switch cfg.Agent.Type {
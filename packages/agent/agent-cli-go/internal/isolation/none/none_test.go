@@ -1,6 +1,7 @@
 package none
 
 import (
+	"slices"
 	"strings"
 	"testing"
 
@@ -101,4 +102,35 @@ func TestNone_Capabilities(t *testing.T) {
 	if i.KernelIsolated("runsc") {
 		t.Error("none has no kernel boundary")
 	}
+	if i.FiltersSyscalls("default") {
+		t.Error("none installs no syscall filter")
+	}
+	if i.Cost("") != 0 {
+		t.Errorf("Cost() = %d, want 0: host execution adds nothing", i.Cost(""))
+	}
+}
+
+func TestNone_ExposureIsTheWholeHost(t *testing.T) {
+	c := provision.Contribution{Env: map[string]string{"FOO": "bar"}}
+	exposure, err := NewIsolator().Exposure(cfg(netshared.ModeHost), c)
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	if len(exposure.Binds) != 1 || exposure.Binds[0] != (isoshared.Bind{Source: "/", Target: "/"}) {
+		t.Errorf("Exposure().Binds = %+v, want / read-write", exposure.Binds)
+	}
+	if !slices.Contains(exposure.Env, "FOO") || slices.Contains(exposure.Env, "bar") {
+		t.Errorf("Exposure().Env = %v, want the name FOO without its value", exposure.Env)
+	}
+	if _, err := NewIsolator().Exposure(cfg(netshared.ModeNone), c); err == nil {
+		t.Error("Exposure(network=none) error = nil, want fail-closed error")
+	}
+}
+
+func TestNone_RefusesProtectedPaths(t *testing.T) {
+	cfg := cfg(netshared.ModeHost)
+	cfg.Protect = []string{".github/workflows"}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("Command() error = %v, want protected paths refused", err)
+	}
 }
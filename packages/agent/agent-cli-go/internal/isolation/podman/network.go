@@ -0,0 +1,67 @@
+package podman
+
+import (
+	"fmt"
+	"runtime"
+
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+)
+
+// networkArgs returns the podman network flags for the mode. Proxy mode keeps
+// --network none and mounts only the relay binary and its sockets, because
+// slirp4netns/pasta plus proxy environment variables would not prevent
+// untrusted code from opening direct sockets. None mode mounts the same relay
+// when loopback forwards are requested.
+//
+// This is a cross-axis bridge (isolation -> network/shared): the dependent
+// isolation leaf owns the glue; network/shared never reaches back into isolation.
+func networkArgs(m netshared.Mode, relay netshared.RelayTransport) ([]string, error) {
+	switch m {
+	case netshared.ModeHost:
+		return []string{"--network", "host"}, nil
+	case netshared.ModeNone, netshared.ModeProxy:
+		binds, err := netshared.RelayBinds(m, relay)
+		if err != nil {
+			return nil, err
+		}
+		args := []string{"--network", "none"}
+		if len(binds) == 0 {
+			return args, nil
+		}
+		// The relay is the host agent-cli binary; it only runs in a Linux
+		// container when the host is Linux on the same architecture (podman
+		// machine on macOS/Windows runs a Linux VM).
+		if runtime.GOOS != "linux" {
+			if m == netshared.ModeProxy {
+				return nil, fmt.Errorf("podman network=proxy requires a linux host to run the in-container relay: %w", netshared.ErrProxyEnforcementUnavailable)
+			}
+			return nil, fmt.Errorf("podman loopback forwarding requires a linux host to run the in-container relay: %w", netshared.ErrRelayUnavailable)
+		}
+		for _, bind := range binds {
+			args = append(args, "-v", fmt.Sprintf("%s:%s:ro", bind.Host, bind.Sandbox))
+		}
+		return args, nil
+	default:
+		return nil, &netshared.InvalidModeError{Value: string(m)}
+	}
+}
+
+// networkCommand wraps the agent command with the in-container relay when the
+// mode routes anything through it.
+func networkCommand(m netshared.Mode, relay netshared.RelayTransport, command []string) []string {
+	return netshared.RelayCommand(m, relay, command)
+}
+
+// networkEnv returns the environment the mode adds inside the container.
+func networkEnv(m netshared.Mode) map[string]string {
+	if m != netshared.ModeProxy {
+		return nil
+	}
+	return netshared.ProxyEnv()
+}
+
+// RestrictsEgress reports that a restricted mode is fully enforced: the
+// sandbox has no network route of its own beyond the relay.
+func (i *Isolator) RestrictsEgress(m netshared.Mode) bool {
+	return netshared.EgressIsRestricted(m)
+}
@@ -0,0 +1,346 @@
+// Package podman is the isolation=podman leaf: rootless container confinement
+// with the same deny-default security flags as the docker leaf.
+package podman
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/agentcmd"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/envutil"
+)
+
+// containerHome is the synthetic, writable HOME inside the container. The host
+// $HOME is never mounted; only the curated config paths are bound read-only here.
+const containerHome = "/home/agent"
+
+// Isolator confines the agent in a rootless podman container with the docker
+// leaf's deny-default flags applied as DEFAULTS: --security-opt=no-new-privileges,
+// --cap-drop ALL, podman's default seccomp and apparmor/SELinux profiles,
+// --read-only rootfs + tmpfs /tmp + a writable workdir bind (the only writable
+// host bind), and --pids-limit/--memory. The curated config paths are mounted
+// read-only into a synthetic HOME; the host $HOME is never mounted.
+//
+// --userns=keep-id maps the caller's uid and gid to themselves inside the
+// user namespace, so files written to the workdir stay owned by the caller
+// without root on the host. Default crun/runc is attack-surface reduction; a
+// sandboxed runtime (runsc/gVisor, or the krun microVM) is the kernel boundary
+// (see KernelIsolated), wired via RunConfig.Runtime.
+type Isolator struct{}
+
+// NewIsolator creates a podman isolator.
+func NewIsolator() *Isolator { return &Isolator{} }
+
+// Available reports whether podman can reach its (rootless) storage and runtime.
+func (i *Isolator) Available() (bool, error) {
+	cmd := exec.Command("podman", "info")
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	return cmd.Run() == nil, nil
+}
+
+// HidesHost reports whether host tools are unreachable. The host filesystem is
+// never mounted, so the actual host is always hidden; an image-less container,
+// however, resolves host-equivalent tools, so a pinned image is required.
+func (i *Isolator) HidesHost(_ bool, image string) bool {
+	return isolation.IsDigestPinnedImage(resolveImage(image))
+}
+
+// PinnedProvision requires the container image itself to be digest-pinned. A
+// separate provisioner must also be pinned unless the image is the only source
+// of tools.
+func (i *Isolator) PinnedProvision(method provision.ProvisionMethod, provisionerPinned bool, image string) bool {
+	if !isolation.IsDigestPinnedImage(resolveImage(image)) {
+		return false
+	}
+	return method == provision.ProvisionNone || provisionerPinned
+}
+
+// KernelIsolated reports whether the runtime gives a kernel boundary: gVisor
+// runsc, or krun, which runs the container in a libkrun microVM. Default
+// crun/runc is attack-surface reduction, not a kernel boundary.
+func (i *Isolator) KernelIsolated(runtime string) bool {
+	return runtime == "runsc" || runtime == "gvisor" || runtime == "krun"
+}
+
+// FiltersSyscalls reports that podman installs any named seccomp profile.
+func (i *Isolator) FiltersSyscalls(profile string) bool { return profile != "" }
+
+// Cost reports a daemonless container; a kernel-isolating runtime adds its
+// own overhead.
+func (i *Isolator) Cost(runtime string) int {
+	if i.KernelIsolated(runtime) {
+		return 6
+	}
+	return 4
+}
+
+// CopyOnWrite reports that podman mounts the work directory as an overlay
+// with :O.
+func (i *Isolator) CopyOnWrite() bool { return true }
+
+// Run executes the agent in a podman container.
+func (i *Isolator) Run(cfg isoshared.RunConfig, c provision.Contribution) (int, error) {
+	args, err := i.buildArgs(cfg, c)
+	if err != nil {
+		return 1, err
+	}
+	env, err := i.processEnv(cfg, c)
+	if err != nil {
+		return 1, err
+	}
+	opts := isoshared.SpawnOptions{Usage: cfg.Usage, Timeout: cfg.Timeout}
+	if cfg.RunID != "" {
+		opts.Container = isoshared.ContainerName(cfg.RunID)
+	}
+	return isoshared.SpawnContainer("podman", args, env, opts, "Podman sandbox", cfg.Verbose)
+}
+
+// Command returns the full podman command (for display / terminal wrappers).
+func (i *Isolator) Command(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
+	args, err := i.buildArgs(cfg, c)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"podman"}, args...), nil
+}
+
+// TerminalCommand returns the podman command plus the environment used to launch it.
+func (i *Isolator) TerminalCommand(cfg isoshared.RunConfig, c provision.Contribution) ([]string, []string, error) {
+	command, err := i.Command(cfg, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	env, err := i.processEnv(cfg, c)
+	return command, env, err
+}
+
+// Exposure reports the volumes of the podman command and the container's
+// environment.
+func (i *Isolator) Exposure(cfg isoshared.RunConfig, c provision.Contribution) (isoshared.Exposure, error) {
+	args, err := i.buildArgs(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	env, err := i.containerEnv(cfg, c)
+	if err != nil {
+		return isoshared.Exposure{}, err
+	}
+	return isoshared.Exposure{Binds: isoshared.ContainerBinds(args, resolveImage(cfg.Image)), Env: isoshared.EnvNames(env)}, nil
+}
+
+func (i *Isolator) buildArgs(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
+	homeDir, err := isoshared.ResolveHomeDir(cfg.HomeDir)
+	if err != nil {
+		return nil, err
+	}
+	workDir, err := isoshared.ResolveDirectory(cfg.WorkDir, "work directory")
+	if err != nil {
+		return nil, err
+	}
+	repoDir := ""
+	if cfg.RepoDir != "" {
+		repoDir, err = isoshared.ResolveDirectory(cfg.RepoDir, "repository directory")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limits := cfg.Limits.Resolved()
+	args := []string{"run", "--rm", "-it"}
+	if cfg.NoTTY {
+		args = []string{"run", "--rm", "-i"}
+	}
+	if cfg.RunID != "" {
+		args = append(args, "--name", isoshared.ContainerName(cfg.RunID), "--label", isoshared.ContainerRunLabel+"="+cfg.RunID)
+	}
+
+	// Kernel-isolating runtime (e.g. runsc/gVisor or krun); empty means the
+	// podman default (crun).
+	if cfg.Runtime != "" {
+		args = append(args, "--runtime", cfg.Runtime)
+	}
+
+	// Security defaults (deny-default; never seccomp=unconfined). Podman applies
+	// its default seccomp and apparmor/SELinux profiles unless told otherwise.
+	args = append(args,
+		"--security-opt", "no-new-privileges",
+		"--cap-drop", "ALL",
+		"--read-only",
+		"--tmpfs", "/tmp:rw,noexec,nosuid",
+		// Writable synthetic HOME (mode 1777 so the mapped uid can write).
+		"--tmpfs", containerHome+":rw,mode=1777",
+		"--pids-limit", strconv.FormatInt(limits.Pids, 10),
+		"--memory", isoshared.FormatMemory(limits.MemoryBytes),
+	)
+	// --cpus only on request: rootless podman can apply it only when the cpu
+	// cgroup controller is delegated to the user, which most distributions do
+	// not do by default.
+	if limits.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64))
+	}
+
+	// Seccomp deny list, in place of the engine's default profile.
+	if cfg.SyscallFilter.Profile != "" {
+		args = append(args, "--security-opt", "seccomp="+cfg.SyscallFilter.OCIFile)
+	}
+
+	// Network — applied EXPLICITLY via the network bridge.
+	netArgs, err := networkArgs(cfg.Network, cfg.Relay)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, netArgs...)
+
+	// Map the caller to themselves in the user namespace so written files are
+	// owned by the caller on the host.
+	args = append(args, "--userns", "keep-id")
+
+	// Workspace (rw — the only writable host bind) + working directory.
+	// A reviewed run gets the workspace as an overlay whose writes land in the
+	// host upper directory.
+	if cfg.Overlay.Enabled() {
+		if _, err := cfg.Overlay.Options(workDir); err != nil {
+			return nil, err
+		}
+		args = append(args, "-w", workDir, "-v", fmt.Sprintf("%s:%s:O,upperdir=%s,workdir=%s", workDir, workDir, cfg.Overlay.Upper, cfg.Overlay.Work))
+	} else {
+		args = append(args, "-w", workDir, "-v", fmt.Sprintf("%s:%s", workDir, workDir))
+	}
+	if repoDir != "" && repoDir != workDir {
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", repoDir, repoDir))
+	}
+
+	// Curated config paths, read-only, into the synthetic HOME.
+	for _, configPath := range isolation.UserConfigPaths(cfg.Agent.Type) {
+		src, exists, err := isoshared.ResolveContainedOptionalPath(homeDir, configPath, "user config path")
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			args = append(args, "-v", fmt.Sprintf("%s:%s:ro", src, filepath.Join(containerHome, configPath)))
+		}
+	}
+
+	// Contribution: read-only binds of the provisioned closure's requisites.
+	for _, p := range c.RoBindPaths {
+		resolved, err := isoshared.ResolveExistingPath(p, "provisioned read-only bind")
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", resolved, resolved))
+	}
+
+	// Caller-supplied extra binds.
+	for _, path := range cfg.BindPaths {
+		resolved, err := isoshared.ResolveExistingPath(path, "read-write bind")
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s", resolved, resolved))
+	}
+	for _, path := range cfg.RoBindPaths {
+		resolved, err := isoshared.ResolveExistingPath(path, "read-only bind")
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", resolved, resolved))
+	}
+
+	// Protected workspace paths, mounted read-only over the writable
+	// workspace; the engine mounts the nested paths after it.
+	protected, err := isoshared.ResolveProtectedPaths(workDir, cfg.Protect)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range protected {
+		args = append(args, "-v", fmt.Sprintf("%s:%s:ro", path, path))
+	}
+
+	// Environment.
+	containerEnv, err := i.containerEnv(cfg, c)
+	if err != nil {
+		return nil, err
+	}
+	envNames := make([]string, 0, len(containerEnv))
+	for key := range containerEnv {
+		envNames = append(envNames, key)
+	}
+	sort.Strings(envNames)
+	for _, key := range envNames {
+		args = append(args, "-e", key)
+	}
+
+	// Image (pinned by the caller; falls back to the shared default, which is
+	// fully qualified as podman requires without a search-registry config).
+	args = append(args, resolveImage(cfg.Image))
+
+	// Agent command, wrapped with the provisioner's init commands and, in proxy
+	// mode, the egress relay.
+	agentCmd := agentcmd.BuildAgentCommand(cfg.Agent, cfg.Provider, cfg.AgentArgs, cfg.Prompt, "")
+	args = append(args, networkCommand(cfg.Network, cfg.Relay, isoshared.WrapWithInitCommands(agentCmd, c.InitCommands))...)
+
+	return args, nil
+}
+
+func (i *Isolator) processEnv(cfg isoshared.RunConfig, c provision.Contribution) ([]string, error) {
+	containerEnv, err := i.containerEnv(cfg, c)
+	if err != nil {
+		return nil, err
+	}
+	return envutil.EnvMapToSlice(envutil.MergeEnvMaps(envutil.ParseEnv(os.Environ()), containerEnv)), nil
+}
+
+func resolveImage(image string) string {
+	if image == "" {
+		return isolation.DefaultContainerImage
+	}
+	return image
+}
+
+// containerEnv builds the container environment: HOME/TMPDIR/SHELL/PATH, the
+// contribution's env, the provider tokens, the proxy variables in proxy mode, and
+// the caller's custom env.
+func (i *Isolator) containerEnv(cfg isoshared.RunConfig, c provision.Contribution) (map[string]string, error) {
+	env := map[string]string{
+		"HOME":   containerHome,
+		"TMPDIR": "/tmp",
+		"SHELL":  "/bin/bash",
+	}
+
+	// PATH: the contribution's tool dirs first, then the image's standard dirs
+	// (the pinned image, not the host).
+	pathEntries := append([]string{}, c.PathEntries...)
+	pathEntries = append(pathEntries, "/usr/local/bin", "/usr/bin", "/bin")
+	env["PATH"] = strings.Join(pathEntries, ":")
+
+	providerEnv, err := agentcmd.BuildProviderEnv(cfg.Agent, cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range providerEnv {
+		env[k] = v
+	}
+	for k, v := range c.Env {
+		env[k] = v
+	}
+	for k, v := range networkEnv(cfg.Network) {
+		env[k] = v
+	}
+	customEnv, err := envutil.ParseCustomEnv(cfg.CustomEnv)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range customEnv {
+		env[k] = v
+	}
+	return env, nil
+}
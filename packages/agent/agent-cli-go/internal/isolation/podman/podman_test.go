@@ -0,0 +1,473 @@
+package podman
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
+)
+
+// podmanCfg builds a run config rooted in an empty temporary home directory, so
+// the arguments depend on the config paths a test creates rather than on the
+// host user's configuration.
+func podmanCfg(t *testing.T, net netshared.Mode, workDir string) isoshared.RunConfig {
+	t.Helper()
+	return isoshared.RunConfig{
+		Agent:   &types.AgentConfig{Type: types.AgentClaude, Binary: "claude"},
+		WorkDir: workDir,
+		HomeDir: t.TempDir(),
+		Network: net,
+	}
+}
+
+// canonicalPath resolves the symlinks a bind source is reported through, which
+// is how the isolator names a path it mounts.
+func canonicalPath(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatalf("EvalSymlinks(%q) error = %v", path, err)
+	}
+	return resolved
+}
+
+func podmanCommand(t *testing.T, cfg isoshared.RunConfig, c provision.Contribution) []string {
+	t.Helper()
+	command, err := NewIsolator().Command(cfg, c)
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	return command
+}
+
+func argHas(args []string, s string) bool {
+	for _, a := range args {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
+
+func argHasPair(args []string, a, b string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == a && args[i+1] == b {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPodman_SecurityDefaults(t *testing.T) {
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	args := podmanCommand(t, cfg, provision.Contribution{})
+
+	if args[0] != "podman" {
+		t.Errorf("command = %v, want podman", args)
+	}
+	if !argHas(args, "--read-only") {
+		t.Error("missing --read-only rootfs")
+	}
+	if !argHasPair(args, "--cap-drop", "ALL") {
+		t.Error("missing --cap-drop ALL")
+	}
+	if !argHasPair(args, "--security-opt", "no-new-privileges") {
+		t.Error("missing no-new-privileges")
+	}
+	if !argHasPair(args, "--tmpfs", "/tmp:rw,noexec,nosuid") {
+		t.Error("missing hardened /tmp tmpfs")
+	}
+	if !argHasPair(args, "--tmpfs", containerHome+":rw,mode=1777") {
+		t.Error("missing synthetic HOME tmpfs")
+	}
+	if !argHasPair(args, "--pids-limit", "4096") {
+		t.Error("missing --pids-limit")
+	}
+	if !argHasPair(args, "--memory", "8g") {
+		t.Error("missing --memory limit")
+	}
+	for _, a := range args {
+		if strings.Contains(a, "seccomp=unconfined") || strings.Contains(a, "label=disable") {
+			t.Errorf("must never weaken the default profiles, got %q", a)
+		}
+	}
+	homeDir, err := filepath.EvalSymlinks(cfg.HomeDir)
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	if argHasPair(args, "-v", homeDir+":"+homeDir) {
+		t.Error("must not mount the whole host home directory")
+	}
+}
+
+// keep-id maps the caller to themselves, so podman needs no -u and the workdir
+// stays owned by the caller.
+func TestPodman_KeepsTheCallerIdentity(t *testing.T) {
+	args := podmanCommand(t, podmanCfg(t, netshared.ModeNone, t.TempDir()), provision.Contribution{})
+
+	if !argHasPair(args, "--userns", "keep-id") {
+		t.Error("missing --userns keep-id")
+	}
+	if argHas(args, "-u") {
+		t.Error("keep-id already maps the caller; -u must not override it")
+	}
+}
+
+func TestPodman_MountsExistingUserConfigPathsReadOnly(t *testing.T) {
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	configDir := filepath.Join(cfg.HomeDir, ".claude")
+	if err := os.Mkdir(configDir, 0o755); err != nil {
+		t.Fatalf("create %q: %v", configDir, err)
+	}
+
+	args := podmanCommand(t, cfg, provision.Contribution{})
+
+	source, err := filepath.EvalSymlinks(configDir)
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	if bind := source + ":" + filepath.Join(containerHome, ".claude") + ":ro"; !argHasPair(args, "-v", bind) {
+		t.Errorf("missing read-only user config bind %q", bind)
+	}
+	if strings.Contains(strings.Join(args, " "), filepath.Join(containerHome, ".claude.json")) {
+		t.Error("absent user config path .claude.json must not be mounted")
+	}
+}
+
+func TestPodman_NetworkExplicit(t *testing.T) {
+	work := t.TempDir()
+	if args := podmanCommand(t, podmanCfg(t, netshared.ModeNone, work), provision.Contribution{}); !argHasPair(args, "--network", "none") {
+		t.Error("network none must emit --network none")
+	}
+	if args := podmanCommand(t, podmanCfg(t, netshared.ModeHost, work), provision.Contribution{}); !argHasPair(args, "--network", "host") {
+		t.Error("network host must emit --network host")
+	}
+	if _, err := NewIsolator().Command(podmanCfg(t, netshared.ModeProxy, work), provision.Contribution{}); !errors.Is(err, netshared.ErrProxyEnforcementUnavailable) {
+		t.Errorf("network proxy without transport error = %v, want %v", err, netshared.ErrProxyEnforcementUnavailable)
+	}
+	if _, err := NewIsolator().Command(podmanCfg(t, "bogus", work), provision.Contribution{}); err == nil {
+		t.Error("an unknown network mode must be rejected")
+	}
+}
+
+// Proxy mode keeps --network none and reaches the host proxy only through the
+// mounted socket, with the relay wrapping the agent and HTTPS_PROXY pointing at it.
+func TestPodman_NetworkProxyFunnelsThroughRelay(t *testing.T) {
+	cfg := podmanCfg(t, netshared.ModeProxy, t.TempDir())
+	cfg.Relay = netshared.RelayTransport{ProxySocket: "/tmp/agent-cli-egress/egress.sock", RelayBinary: "/usr/local/bin/agent-cli"}
+
+	args := podmanCommand(t, cfg, provision.Contribution{})
+	env, err := NewIsolator().containerEnv(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("containerEnv() error = %v", err)
+	}
+
+	if !argHasPair(args, "--network", "none") {
+		t.Error("network proxy must keep --network none")
+	}
+	if !argHasPair(args, "-v", cfg.Relay.ProxySocket+":"+netshared.SandboxProxySocket+":ro") {
+		t.Error("network proxy must mount the proxy socket")
+	}
+	if !argHasPair(args, netshared.SandboxRelayBinary, netshared.RelayCommandName) {
+		t.Error("network proxy must wrap the agent with the relay")
+	}
+	if env["HTTPS_PROXY"] != "http://"+netshared.SandboxProxyAddr {
+		t.Errorf("HTTPS_PROXY = %q, want the relay address", env["HTTPS_PROXY"])
+	}
+}
+
+func TestPodman_RuntimeWired(t *testing.T) {
+	work := t.TempDir()
+	cfg := podmanCfg(t, netshared.ModeNone, work)
+	cfg.Runtime = "krun"
+	if args := podmanCommand(t, cfg, provision.Contribution{}); !argHasPair(args, "--runtime", "krun") {
+		t.Error("RunConfig.Runtime must emit --runtime <runtime>")
+	}
+	if args := podmanCommand(t, podmanCfg(t, netshared.ModeNone, work), provision.Contribution{}); argHas(args, "--runtime") {
+		t.Error("empty Runtime must not emit --runtime")
+	}
+}
+
+func TestPodman_NoTTYAttachesStdinWithoutATerminal(t *testing.T) {
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	if args := podmanCommand(t, cfg, provision.Contribution{}); !argHas(args, "-it") {
+		t.Error("a run from a terminal must allocate one with -it")
+	}
+	cfg.NoTTY = true
+	if args := podmanCommand(t, cfg, provision.Contribution{}); argHas(args, "-it") || !argHas(args, "-i") {
+		t.Errorf("args = %v, want -i without -t for a run without a terminal", args)
+	}
+}
+
+func TestPodman_RunIDNamesAndLabelsTheContainer(t *testing.T) {
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	if args := podmanCommand(t, cfg, provision.Contribution{}); argHas(args, "--name") {
+		t.Error("a run without an ID must leave the container unnamed")
+	}
+	cfg.RunID = "abc123"
+	args := podmanCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--name", "agent-cli-abc123") || !argHasPair(args, "--label", "agent-cli.run=abc123") {
+		t.Errorf("args = %v, want the container named and labelled after the run", args)
+	}
+}
+
+func TestPodman_RunStartsTheNamedContainer(t *testing.T) {
+	binDir := t.TempDir()
+	argsFile := filepath.Join(t.TempDir(), "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(binDir, "podman"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write podman stub: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	cfg.RunID = "abc123"
+
+	exitCode, err := NewIsolator().Run(cfg, provision.Contribution{})
+
+	if err != nil || exitCode != 3 {
+		t.Fatalf("Run() = %d, %v, want the container's exit code 3", exitCode, err)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "--name agent-cli-abc123") {
+		t.Errorf("podman args = %q, want the container named after the run", args)
+	}
+}
+
+func TestPodman_PromptRunsTheAgentHeadless(t *testing.T) {
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	cfg.Prompt = "Fix the build"
+	if args := podmanCommand(t, cfg, provision.Contribution{}); !argHasPair(args, "--print", "Fix the build") {
+		t.Errorf("args = %v, want the prompt passed to the agent", args)
+	}
+}
+
+func TestPodman_LimitsWired(t *testing.T) {
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	if args := podmanCommand(t, cfg, provision.Contribution{}); argHas(args, "--cpus") {
+		t.Error("no CPU limit must not emit --cpus")
+	}
+	cfg.Limits = isoshared.ResourceLimits{MemoryBytes: 2 << 30, Pids: 512, CPUs: 2}
+	args := podmanCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "--memory", "2g") || !argHasPair(args, "--pids-limit", "512") || !argHasPair(args, "--cpus", "2") {
+		t.Errorf("args = %v, want the RunConfig limits", args)
+	}
+}
+
+func TestPodman_SyscallFilterWired(t *testing.T) {
+	work := t.TempDir()
+	cfg := podmanCfg(t, netshared.ModeNone, work)
+	cfg.SyscallFilter = isoshared.SyscallFilter{Profile: isoshared.SeccompProfileDefault, OCIFile: "/tmp/seccomp.json"}
+	if args := podmanCommand(t, cfg, provision.Contribution{}); !argHasPair(args, "--security-opt", "seccomp=/tmp/seccomp.json") {
+		t.Error("RunConfig.SyscallFilter must emit --security-opt seccomp=<profile>")
+	}
+	if !NewIsolator().FiltersSyscalls(isoshared.SeccompProfileStrict) || NewIsolator().FiltersSyscalls("") {
+		t.Error("podman filters syscalls exactly when a profile is named")
+	}
+}
+
+func TestPodman_AppliesContributionAndBinds(t *testing.T) {
+	work := t.TempDir()
+	repo := t.TempDir()
+	closure := t.TempDir()
+	bind := t.TempDir()
+	roBind := t.TempDir()
+	cfg := podmanCfg(t, netshared.ModeNone, work)
+	cfg.RepoDir = repo
+	cfg.BindPaths = []string{bind}
+	cfg.RoBindPaths = []string{roBind}
+	cfg.CustomEnv = []string{"CUSTOM=1"}
+	c := provision.Contribution{
+		RoBindPaths: []string{closure},
+		PathEntries: []string{"/nix/store/abc/bin"},
+		Env:         map[string]string{"FOO": "bar"},
+	}
+
+	args := podmanCommand(t, cfg, c)
+	env, err := NewIsolator().containerEnv(cfg, c)
+	if err != nil {
+		t.Fatalf("containerEnv() error = %v", err)
+	}
+
+	canonical := func(path string) string {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			t.Fatalf("EvalSymlinks(%q) error = %v", path, err)
+		}
+		return resolved
+	}
+	for _, want := range []string{
+		canonical(work) + ":" + canonical(work),
+		canonical(repo) + ":" + canonical(repo) + ":ro",
+		closure + ":" + closure + ":ro",
+		bind + ":" + bind,
+		roBind + ":" + roBind + ":ro",
+	} {
+		if !argHasPair(args, "-v", want) {
+			t.Errorf("missing bind %q", want)
+		}
+	}
+	if path := env["PATH"]; !strings.HasPrefix(path, "/nix/store/abc/bin:") {
+		t.Errorf("PATH = %q, want contribution entry prepended", path)
+	}
+	if env["FOO"] != "bar" || env["CUSTOM"] != "1" || env["HOME"] != containerHome {
+		t.Errorf("env = %v, want contribution, custom env and the synthetic HOME", env)
+	}
+	for _, key := range []string{"PATH", "FOO", "CUSTOM", "HOME"} {
+		if !argHasPair(args, "-e", key) {
+			t.Errorf("podman command missing inherited environment name %q", key)
+		}
+	}
+}
+
+func TestPodman_Capabilities(t *testing.T) {
+	i := NewIsolator()
+	if !i.HidesHost(false, "") {
+		t.Error("podman with the digest-pinned default image hides host-equivalent tools")
+	}
+	if i.HidesHost(false, "alpine:3.20") {
+		t.Error("podman with a mutable tag must not claim to hide host-equivalent image tools")
+	}
+	if i.PinnedProvision(provision.ProvisionNone, false, "alpine:3.20") {
+		t.Error("a mutable image is not pinned")
+	}
+	if !i.PinnedProvision(provision.ProvisionNone, false, "") || i.PinnedProvision(provision.ProvisionCommand, false, "") {
+		t.Error("the pinned default image is pinned only without an unpinned provisioner")
+	}
+	for _, runtime := range []string{"runsc", "gvisor", "krun"} {
+		if !i.KernelIsolated(runtime) {
+			t.Errorf("podman + %s is a kernel boundary", runtime)
+		}
+	}
+	if i.KernelIsolated("") || i.KernelIsolated("crun") {
+		t.Error("podman default crun is not a kernel boundary")
+	}
+	if i.Cost("krun") <= i.Cost("") {
+		t.Error("a kernel-isolating runtime must cost more than crun")
+	}
+}
+
+func TestPodman_CommandRejectsMissingBindAndProviderToken(t *testing.T) {
+	work := t.TempDir()
+	cfg := podmanCfg(t, netshared.ModeNone, work)
+	cfg.BindPaths = []string{work + "/missing"}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want missing bind error")
+	}
+
+	cfg.BindPaths = nil
+	cfg.Provider = &types.ModelProvider{
+		CredentialSourceEnv: "MISSING_PODMAN_TEST_TOKEN",
+		CredentialTargetEnv: "AGENT_PROVIDER_TOKEN",
+	}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want missing provider token error")
+	}
+	if _, _, err := NewIsolator().TerminalCommand(cfg, provision.Contribution{}); err == nil {
+		t.Error("TerminalCommand() error = nil, want missing provider token error")
+	}
+}
+
+func TestPodman_KeepsProviderSecretOutOfArguments(t *testing.T) {
+	const secret = "podman-secret-must-not-appear"
+	t.Setenv("PODMAN_TEST_TOKEN", secret)
+	cfg := podmanCfg(t, netshared.ModeNone, t.TempDir())
+	cfg.Provider = &types.ModelProvider{
+		CredentialSourceEnv: "PODMAN_TEST_TOKEN",
+		CredentialTargetEnv: "ANTHROPIC_AUTH_TOKEN",
+	}
+
+	command, env, err := NewIsolator().TerminalCommand(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("TerminalCommand() error = %v", err)
+	}
+
+	if strings.Contains(strings.Join(command, " "), secret) {
+		t.Fatal("podman command arguments contain the provider secret")
+	}
+	if !argHas(env, "ANTHROPIC_AUTH_TOKEN="+secret) {
+		t.Error("the podman client environment must carry the token for -e to pass through")
+	}
+}
+
+// Exposure names the workspace read-write and the closure read-only, and lists
+// the environment by name only.
+func TestPodman_ExposureReportsBindsAndEnvNames(t *testing.T) {
+	work := t.TempDir()
+	closure := t.TempDir()
+	cfg := podmanCfg(t, netshared.ModeHost, work)
+	cfg.CustomEnv = []string{"FOO=secret"}
+
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{RoBindPaths: []string{closure}})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	for _, want := range []isoshared.Bind{
+		{Source: canonicalPath(t, work), Target: canonicalPath(t, work)},
+		{Source: canonicalPath(t, closure), Target: canonicalPath(t, closure), ReadOnly: true},
+	} {
+		if !slices.Contains(exposure.Binds, want) {
+			t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+		}
+	}
+	if !slices.Contains(exposure.Env, "FOO") || slices.Contains(exposure.Env, "FOO=secret") {
+		t.Errorf("Exposure().Env = %v, want the name FOO without its value", exposure.Env)
+	}
+}
+
+// A reviewed run mounts the workspace copy-on-write, which the exposure
+// reports as read-only.
+func TestPodman_OverlayMountsTheWorkspaceCopyOnWrite(t *testing.T) {
+	work := t.TempDir()
+	cfg := podmanCfg(t, netshared.ModeHost, work)
+	cfg.Overlay = isoshared.Overlay{Upper: "/layers/upper", Work: "/layers/work"}
+	workDir := canonicalPath(t, work)
+
+	args := podmanCommand(t, cfg, provision.Contribution{})
+	if !argHasPair(args, "-v", workDir+":"+workDir+":O,upperdir=/layers/upper,workdir=/layers/work") {
+		t.Errorf("args = %v, want the workspace mounted as an overlay", args)
+	}
+	exposure, err := NewIsolator().Exposure(cfg, provision.Contribution{})
+	if err != nil {
+		t.Fatalf("Exposure() error = %v", err)
+	}
+	if want := (isoshared.Bind{Source: workDir, Target: workDir, ReadOnly: true}); !slices.Contains(exposure.Binds, want) {
+		t.Errorf("Exposure().Binds = %+v, want %+v among them", exposure.Binds, want)
+	}
+	cfg.Overlay.Upper = "/layers/up,per"
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want an overlay path with a comma refused")
+	}
+}
+
+// A protected workspace path is mounted read-only over the writable
+// workspace; a pattern that leaves the workspace is refused.
+func TestPodman_ProtectMountsWorkspacePathsReadOnly(t *testing.T) {
+	work := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(work, ".github", "workflows"), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	cfg := podmanCfg(t, netshared.ModeHost, work)
+	cfg.Protect = []string{".github/workflows", "flake.lock"}
+	workDir := canonicalPath(t, work)
+	workflows := filepath.Join(workDir, ".github", "workflows")
+
+	args := podmanCommand(t, cfg, provision.Contribution{})
+	workspace := slices.Index(args, workDir+":"+workDir)
+	if workspace < 0 {
+		t.Errorf("args = %v, want the workspace still bound read-write", args)
+	}
+	if protected := slices.Index(args, workflows+":"+workflows+":ro"); protected < workspace {
+		t.Errorf("args = %v, want %s bound read-only after the workspace", args, workflows)
+	}
+	cfg.Protect = []string{"../outside"}
+	if _, err := NewIsolator().Command(cfg, provision.Contribution{}); err == nil {
+		t.Error("Command() error = nil, want a protected path outside the workspace refused")
+	}
+}
@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrCgroupUnavailable reports a host with neither a delegated cgroup v2
+// subtree agent-cli can write nor a systemd manager to create a scope.
+var ErrCgroupUnavailable = errors.New("no cgroup v2 subtree or systemd scope available for resource limits")
+
+// Cgroup places a host-process sandbox (none, bwrap, landlock, native) in a
+// cgroup carrying the run's ResourceLimits, so the limits cover every process
+// the agent starts. The zero value places nothing.
+type Cgroup struct {
+	// Dir is a cgroup v2 directory created for this run; the sandbox process is
+	// cloned straight into it.
+	Dir string
+	// Scope is a systemd-run prefix that starts the sandbox in a transient
+	// scope unit carrying the limits.
+	Scope []string
+}
+
+// String names the placement for dry-run output.
+func (c Cgroup) String() string {
+	switch {
+	case c.Dir != "":
+		return "cgroup " + c.Dir
+	case len(c.Scope) > 0:
+		return "systemd scope"
+	default:
+		return "none"
+	}
+}
+
+// scopeProperties renders limits as systemd resource-control properties.
+func scopeProperties(limits ResourceLimits) []string {
+	props := []string{
+		"-p", "MemoryMax=" + strconv.FormatInt(limits.MemoryBytes, 10),
+		"-p", "TasksMax=" + strconv.FormatInt(limits.Pids, 10),
+	}
+	if limits.CPUs > 0 {
+		props = append(props, "-p", "CPUQuota="+strconv.FormatInt(int64(limits.CPUs*100), 10)+"%")
+	}
+	return props
+}
@@ -0,0 +1,183 @@
+//go:build linux
+
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	cgroupRoot       = "/sys/fs/cgroup"
+	selfCgroupFile   = "/proc/self/cgroup"
+	cpuPeriodMicros  = 100000
+	cgroupRemoveWait = 2 * time.Second
+)
+
+// PrepareCgroup creates the placement for limits: a child of agent-cli's own
+// cgroup when that subtree is delegated to it, otherwise a transient systemd
+// scope. The returned remove function is idempotent; for a directory it kills
+// whatever the sandbox left running and removes the cgroup.
+func PrepareCgroup(limits ResourceLimits) (Cgroup, func(), error) {
+	return prepareCgroup(cgroupRoot, selfCgroupFile, limits.Resolved(), systemdScope)
+}
+
+func prepareCgroup(root, self string, limits ResourceLimits, scope func(string, ResourceLimits) ([]string, error)) (Cgroup, func(), error) {
+	dir, directErr := createCgroup(root, self, limits)
+	if directErr == nil {
+		return Cgroup{Dir: dir}, sync.OnceFunc(func() { removeCgroup(dir) }), nil
+	}
+	prefix, scopeErr := scope(root, limits)
+	if scopeErr == nil {
+		return Cgroup{Scope: prefix}, func() {}, nil
+	}
+	return Cgroup{}, nil, fmt.Errorf("%w: %v; %v", ErrCgroupUnavailable, directErr, scopeErr)
+}
+
+// createCgroup creates a limited child of the caller's cgroup v2 directory.
+func createCgroup(root, self string, limits ResourceLimits) (string, error) {
+	parent, err := ownCgroup(root, self)
+	if err != nil {
+		return "", err
+	}
+	controllers := []string{"memory", "pids"}
+	if limits.CPUs > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	available, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return "", fmt.Errorf("cgroup %s: %w", parent, err)
+	}
+	enabled, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return "", fmt.Errorf("cgroup %s: %w", parent, err)
+	}
+	var enable []string
+	for _, controller := range controllers {
+		if !slices.Contains(strings.Fields(string(available)), controller) {
+			return "", fmt.Errorf("cgroup %s does not delegate the %s controller", parent, controller)
+		}
+		if !slices.Contains(strings.Fields(string(enabled)), controller) {
+			enable = append(enable, "+"+controller)
+		}
+	}
+	if len(enable) > 0 {
+		// EBUSY here means the parent holds processes (agent-cli itself) and is
+		// not the root, so its children cannot take controllers.
+		if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0); err != nil {
+			return "", fmt.Errorf("enable controllers in cgroup %s: %w", parent, err)
+		}
+	}
+
+	dir, err := os.MkdirTemp(parent, "agent-cli-")
+	if err != nil {
+		return "", fmt.Errorf("create cgroup: %w", err)
+	}
+	files := map[string]string{
+		"memory.max": fmt.Sprint(limits.MemoryBytes),
+		"pids.max":   fmt.Sprint(limits.Pids),
+	}
+	if limits.CPUs > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", int64(limits.CPUs*cpuPeriodMicros), cpuPeriodMicros)
+	}
+	for name, value := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0); err != nil {
+			removeCgroup(dir)
+			return "", fmt.Errorf("set %s in cgroup %s: %w", name, dir, err)
+		}
+	}
+	return dir, nil
+}
+
+// ownCgroup returns the cgroup v2 directory the caller runs in.
+func ownCgroup(root, self string) (string, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%s is not a cgroup v2 hierarchy", root)
+	}
+	data, err := os.ReadFile(self)
+	if err != nil {
+		return "", fmt.Errorf("read own cgroup: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return filepath.Join(root, path), nil
+		}
+	}
+	return "", errors.New("the caller is in no cgroup v2 cgroup")
+}
+
+// removeCgroup kills any process left in dir and removes it once empty.
+func removeCgroup(dir string) {
+	_ = os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0)
+	deadline := time.Now().Add(cgroupRemoveWait)
+	for {
+		err := syscall.Rmdir(dir)
+		if !errors.Is(err, syscall.EBUSY) || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// systemdScope returns the systemd-run prefix for a transient scope, using the
+// user manager unless agent-cli runs as root.
+func systemdScope(root string, limits ResourceLimits) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err != nil {
+		return nil, errors.New("systemd scopes need the unified cgroup v2 hierarchy")
+	}
+	systemdRun, err := exec.LookPath("systemd-run")
+	if err != nil {
+		return nil, errors.New("systemd-run is not installed")
+	}
+	prefix := []string{systemdRun}
+	if os.Geteuid() == 0 {
+		if _, err := os.Stat("/run/systemd/system"); err != nil {
+			return nil, errors.New("systemd is not the init system")
+		}
+	} else {
+		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		if _, err := os.Stat(filepath.Join(runtimeDir, "systemd", "private")); runtimeDir == "" || err != nil {
+			return nil, errors.New("no systemd user manager is running")
+		}
+		prefix = append(prefix, "--user")
+	}
+	prefix = append(prefix, "--scope", "--quiet", "--collect")
+	return append(append(prefix, scopeProperties(limits)...), "--"), nil
+}
+
+// cgroupProcAttr clones the process straight into dir, so not even its first
+// instruction runs outside the limits.
+func cgroupProcAttr(dir string) (*syscall.SysProcAttr, func(), error) {
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open cgroup %s: %w", dir, err)
+	}
+	return &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: fd}, func() { _ = syscall.Close(fd) }, nil
+}
+
+// cgroupPeaks reads the memory and pids high-water marks of dir. Kernels before
+// 5.19 (memory.peak) and 6.1 (pids.peak) lack them; a missing file reads as 0.
+func cgroupPeaks(dir string) (memory, pids int64) {
+	return readCgroupInt(filepath.Join(dir, "memory.peak")), readCgroupInt(filepath.Join(dir, "pids.peak"))
+}
+
+func readCgroupInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
@@ -0,0 +1,152 @@
+//go:build linux
+
+package shared
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeCgroupfs lays out a cgroup v2 hierarchy in which the caller runs in
+// /user.slice/agent, delegating controllers with subtree already enabled.
+func fakeCgroupfs(t *testing.T, controllers, subtree string) (root, self string) {
+	t.Helper()
+	root = t.TempDir()
+	own := filepath.Join(root, "user.slice", "agent")
+	if err := os.MkdirAll(own, 0o755); err != nil {
+		t.Fatalf("create fake cgroup: %v", err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(root, "cgroup.controllers"):    "cpu memory pids",
+		filepath.Join(own, "cgroup.controllers"):     controllers,
+		filepath.Join(own, "cgroup.subtree_control"): subtree,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	self = filepath.Join(t.TempDir(), "cgroup")
+	if err := os.WriteFile(self, []byte("1:name=systemd:/\n0::/user.slice/agent\n"), 0o644); err != nil {
+		t.Fatalf("write self cgroup: %v", err)
+	}
+	return root, self
+}
+
+func noScope(string, ResourceLimits) ([]string, error) { return nil, errors.New("no systemd") }
+
+func readCgroupFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestPrepareCgroupCreatesALimitedChild(t *testing.T) {
+	root, self := fakeCgroupfs(t, "cpu memory pids", "memory")
+	limits := ResourceLimits{MemoryBytes: 1 << 30, Pids: 128, CPUs: 1.5}
+
+	cgroup, remove, err := prepareCgroup(root, self, limits, noScope)
+	if err != nil {
+		t.Fatalf("prepareCgroup() error = %v", err)
+	}
+	defer remove()
+
+	own := filepath.Join(root, "user.slice", "agent")
+	if filepath.Dir(cgroup.Dir) != own || len(cgroup.Scope) > 0 {
+		t.Fatalf("cgroup = %+v, want a child of %s", cgroup, own)
+	}
+	for name, want := range map[string]string{"memory.max": "1073741824", "pids.max": "128", "cpu.max": "150000 100000"} {
+		if got := readCgroupFile(t, cgroup.Dir, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := readCgroupFile(t, own, "cgroup.subtree_control"); got != "+pids +cpu" {
+		t.Errorf("subtree_control write = %q, want the missing controllers enabled", got)
+	}
+}
+
+func TestPrepareCgroupFallsBackToASystemdScope(t *testing.T) {
+	root, self := fakeCgroupfs(t, "memory", "")
+	scope := func(string, ResourceLimits) ([]string, error) { return []string{"systemd-run", "--scope", "--"}, nil }
+
+	cgroup, _, err := prepareCgroup(root, self, ResourceLimits{MemoryBytes: 1 << 30, Pids: 64}, scope)
+
+	if err != nil || cgroup.Dir != "" || cgroup.Scope[0] != "systemd-run" || cgroup.String() != "systemd scope" {
+		t.Errorf("prepareCgroup() = (%+v, %v), want the scope after the pids controller is missing", cgroup, err)
+	}
+}
+
+func TestPrepareCgroupReportsBothFailures(t *testing.T) {
+	root, self := fakeCgroupfs(t, "memory", "")
+
+	_, _, err := prepareCgroup(root, self, ResourceLimits{MemoryBytes: 1 << 30, Pids: 64}, noScope)
+
+	if !errors.Is(err, ErrCgroupUnavailable) || !strings.Contains(err.Error(), "pids controller") || !strings.Contains(err.Error(), "no systemd") {
+		t.Errorf("prepareCgroup() error = %v, want both reasons", err)
+	}
+	if _, err := ownCgroup(t.TempDir(), self); err == nil {
+		t.Error("ownCgroup() on a hierarchy without cgroup.controllers succeeded")
+	}
+}
+
+func TestScopePropertiesCarryTheLimits(t *testing.T) {
+	props := scopeProperties(ResourceLimits{MemoryBytes: 1 << 30, Pids: 64, CPUs: 0.5})
+	want := []string{"-p", "MemoryMax=1073741824", "-p", "TasksMax=64", "-p", "CPUQuota=50%"}
+	if !slices.Equal(props, want) {
+		t.Errorf("scopeProperties() = %v, want %v", props, want)
+	}
+}
+
+func TestSystemdScopeNeedsTheUnifiedHierarchyAndSystemdRun(t *testing.T) {
+	limits := ResourceLimits{MemoryBytes: 1 << 30, Pids: 64}
+	if _, err := systemdScope(t.TempDir(), limits); err == nil || !strings.Contains(err.Error(), "cgroup v2") {
+		t.Errorf("systemdScope(v1) error = %v, want the unified hierarchy error", err)
+	}
+	root, _ := fakeCgroupfs(t, "", "")
+	t.Setenv("PATH", t.TempDir())
+	if _, err := systemdScope(root, limits); err == nil || !strings.Contains(err.Error(), "systemd-run") {
+		t.Errorf("systemdScope(no systemd-run) error = %v, want a missing systemd-run error", err)
+	}
+}
+
+func TestCgroupProcAttrClonesIntoTheDirectory(t *testing.T) {
+	attr, release, err := cgroupProcAttr(t.TempDir())
+	if err != nil {
+		t.Fatalf("cgroupProcAttr() error = %v", err)
+	}
+	defer release()
+	if !attr.UseCgroupFD || attr.CgroupFD <= 0 {
+		t.Errorf("attr = %+v, want a cgroup fd", attr)
+	}
+
+	missing := Cgroup{Dir: filepath.Join(t.TempDir(), "missing")}
+	if _, err := SpawnSandboxWithOptions("true", nil, nil, SpawnOptions{Cgroup: missing}, "spawn test", false); err == nil {
+		t.Error("SpawnSandboxWithOptions() with a missing cgroup succeeded")
+	}
+	if got := missing.String(); got != "cgroup "+missing.Dir {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Cgroup{}).String(); got != "none" {
+		t.Errorf("zero String() = %q, want none", got)
+	}
+}
+
+func TestCgroupPeaksReadsTheHighWaterMarks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "memory.peak"), []byte("1048576\n"), 0o644); err != nil {
+		t.Fatalf("write memory.peak: %v", err)
+	}
+
+	memory, pids := cgroupPeaks(dir)
+
+	// pids.peak is absent, as on kernels before 6.1.
+	if memory != 1<<20 || pids != 0 {
+		t.Errorf("cgroupPeaks() = %d, %d, want %d, 0", memory, pids, 1<<20)
+	}
+}
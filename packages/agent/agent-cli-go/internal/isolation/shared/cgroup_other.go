@@ -0,0 +1,22 @@
+//go:build !linux
+
+package shared
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// PrepareCgroup reports ErrCgroupUnavailable: cgroups are Linux-only.
+func PrepareCgroup(ResourceLimits) (Cgroup, func(), error) {
+	return Cgroup{}, nil, fmt.Errorf("%w on %s", ErrCgroupUnavailable, runtime.GOOS)
+}
+
+func cgroupProcAttr(dir string) (*syscall.SysProcAttr, func(), error) {
+	return nil, nil, fmt.Errorf("%w on %s", ErrCgroupUnavailable, runtime.GOOS)
+}
+
+func cgroupPeaks(string) (int64, int64) {
+	return 0, 0
+}
@@ -0,0 +1,173 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// containerStatsInterval is how often a running container is sampled for its
+// memory and pids peaks. Peaks between samples are missed, so the figures are
+// lower bounds.
+const containerStatsInterval = time.Second
+
+// SpawnContainer runs engine with run arguments (args[0] is "run") like
+// SpawnSandboxWithOptions; opts.Container, when set, must be the --name the
+// arguments give the container. When opts.Usage is set it also records the
+// exit code, the wall time and the container's sampled memory and pids peaks;
+// the engine client's own CPU time and RSS say nothing about the container and
+// are not reported.
+func SpawnContainer(engine string, args []string, env []string, opts SpawnOptions, errorPrefix string, verbose bool) (int, error) {
+	opts.Engine = engine
+	usage := opts.Usage
+	if usage == nil {
+		return SpawnSandboxWithOptions(engine, args, env, opts, errorPrefix, verbose)
+	}
+	opts.Usage = nil
+	dir, err := os.MkdirTemp("", "agent-cli-cid-")
+	if err != nil {
+		return 1, fmt.Errorf("%s: %w", errorPrefix, err)
+	}
+	defer os.RemoveAll(dir)
+	cidFile := filepath.Join(dir, "cid")
+	args = append([]string{args[0], "--cidfile", cidFile}, args[1:]...)
+
+	stop := sampleContainer(engine, cidFile, containerStatsInterval)
+	start := time.Now()
+	code, err := SpawnSandboxWithOptions(engine, args, env, opts, errorPrefix, verbose)
+	usage.WallTime = time.Since(start)
+	usage.PeakMemoryBytes, usage.PeakPids = stop()
+	usage.ExitCode = code
+	return code, err
+}
+
+// ContainerName is the name a container engine gives the container of run id;
+// the container is also labelled with ContainerRunLabel=id.
+func ContainerName(id string) string {
+	return "agent-cli-" + id
+}
+
+// ContainerRunLabel is the label naming the run a container belongs to.
+const ContainerRunLabel = "agent-cli.run"
+
+// sampleContainer polls "<engine> stats" for the container whose id the engine
+// writes to cidFile. The returned stop function ends sampling and returns the
+// highest memory and pids seen.
+func sampleContainer(engine, cidFile string, interval time.Duration) func() (int64, int64) {
+	var memory, pids int64
+	done, finished := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		id := ""
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if id == "" {
+				data, _ := os.ReadFile(cidFile)
+				if id = strings.TrimSpace(string(data)); id == "" {
+					continue
+				}
+			}
+			out, err := exec.Command(engine, "stats", "--no-stream", "--format", "{{.MemUsage}}\t{{.PIDs}}", id).Output()
+			if err != nil {
+				continue
+			}
+			m, p := parseContainerStats(string(out))
+			memory, pids = max(memory, m), max(pids, p)
+		}
+	}()
+	return sync.OnceValues(func() (int64, int64) {
+		close(done)
+		<-finished
+		return memory, pids
+	})
+}
+
+// parseContainerStats reads a "<used> / <limit>\t<pids>" stats line, e.g.
+// "12.5MiB / 8GiB\t7". Fields it cannot read are 0.
+func parseContainerStats(line string) (memory, pids int64) {
+	memUsage, pidField, _ := strings.Cut(strings.TrimSpace(line), "\t")
+	used, _, _ := strings.Cut(memUsage, "/")
+	memory = parseStatsSize(strings.TrimSpace(used))
+	pids, _ = strconv.ParseInt(strings.TrimSpace(pidField), 10, 64)
+	return memory, pids
+}
+
+// statsUnits are the size suffixes docker (binary) and podman (decimal) print,
+// longest first so "MiB" is not read as "B".
+var statsUnits = []struct {
+	suffix string
+	scale  float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseStatsSize parses a size such as "12.5MiB" or "300kB"; it returns 0 for
+// anything else.
+func parseStatsSize(size string) int64 {
+	for _, unit := range statsUnits {
+		if number, ok := strings.CutSuffix(size, unit.suffix); ok {
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil || value < 0 {
+				return 0
+			}
+			return int64(value * unit.scale)
+		}
+	}
+	return 0
+}
+
+// ContainerBinds reads the "-v source:target[:ro]" volumes and the overlay
+// "--mount" volumes of container run arguments, up to image; the agent's own
+// arguments follow it. An overlay ("-v source:target:O,..." or a volume with
+// lowerdir) reaches its source read-only.
+func ContainerBinds(args []string, image string) []Bind {
+	var binds []Bind
+	for i := 0; i+1 < len(args) && args[i] != image; i++ {
+		switch args[i] {
+		case "-v":
+			i++
+			parts := strings.Split(args[i], ":")
+			if len(parts) < 2 {
+				continue
+			}
+			readOnly := len(parts) > 2 && (parts[2] == "ro" || strings.HasPrefix(parts[2], "O"))
+			binds = append(binds, Bind{Source: parts[0], Target: parts[1], ReadOnly: readOnly})
+		case "--mount":
+			i++
+			if bind, ok := overlayVolumeBind(args[i]); ok {
+				binds = append(binds, bind)
+			}
+		}
+	}
+	return binds
+}
+
+// overlayVolumeBind reads the workspace of an overlay volume's "--mount"
+// value: its dst and the lowerdir of its options.
+func overlayVolumeBind(mount string) (Bind, bool) {
+	var bind Bind
+	for _, field := range strings.Split(mount, ",") {
+		field = strings.Trim(field, `"`)
+		if target, ok := strings.CutPrefix(field, "dst="); ok {
+			bind.Target = target
+		}
+		if _, lower, ok := strings.Cut(field, "lowerdir="); ok {
+			bind.Source = lower
+		}
+	}
+	bind.ReadOnly = true
+	return bind, bind.Source != "" && bind.Target != ""
+}
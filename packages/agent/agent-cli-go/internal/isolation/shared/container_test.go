@@ -0,0 +1,151 @@
+package shared
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContainerStats(t *testing.T) {
+	tests := []struct {
+		line         string
+		memory, pids int64
+	}{
+		{"12.5MiB / 8GiB\t7\n", 12.5 * (1 << 20), 7},
+		{"300kB / 8.59GB\t3", 300e3, 3},
+		{"0B / 0B\t--", 0, 0},
+		{"", 0, 0},
+	}
+	for _, tt := range tests {
+		if memory, pids := parseContainerStats(tt.line); memory != tt.memory || pids != tt.pids {
+			t.Errorf("parseContainerStats(%q) = %d, %d, want %d, %d", tt.line, memory, pids, tt.memory, tt.pids)
+		}
+	}
+}
+
+// fakeEngine installs an engine stub on PATH that answers "stats" with line and
+// runs a container by writing its id to --cidfile and sleeping briefly.
+func fakeEngine(t *testing.T, line string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the engine stub is a shell script")
+	}
+	binDir := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+stats) printf '` + line + `\n' ;;
+run) [ "$2" = --cidfile ] || exit 99; echo abc123 > "$3"; sleep 0.3; exit 4 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(binDir, "engine"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write engine stub: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSampleContainerKeepsThePeaks(t *testing.T) {
+	fakeEngine(t, `64MiB / 8GiB\t9`)
+	cidFile := filepath.Join(t.TempDir(), "cid")
+	if err := os.WriteFile(cidFile, []byte("abc123\n"), 0o644); err != nil {
+		t.Fatalf("write cid file: %v", err)
+	}
+
+	stop := sampleContainer("engine", cidFile, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	memory, pids := stop()
+
+	if memory != 64<<20 || pids != 9 {
+		t.Errorf("peaks = %d, %d, want %d, 9", memory, pids, 64<<20)
+	}
+	if again, _ := stop(); again != memory {
+		t.Errorf("second stop() = %d, want the same peaks", again)
+	}
+}
+
+func TestSpawnContainerRecordsUsage(t *testing.T) {
+	fakeEngine(t, `1GiB / 8GiB\t2`)
+	var usage Usage
+
+	exitCode, err := SpawnContainer("engine", []string{"run", "--rm", "image"}, os.Environ(), SpawnOptions{Usage: &usage}, "container test", false)
+
+	if err != nil || exitCode != 4 {
+		t.Fatalf("SpawnContainer() = %d, %v, want 4, nil", exitCode, err)
+	}
+	if usage.ExitCode != 4 || usage.WallTime < 300*time.Millisecond {
+		t.Errorf("usage = %+v, want exit code 4 and the run's wall time", usage)
+	}
+	if usage.UserCPU != 0 || usage.MaxRSSBytes != 0 {
+		t.Errorf("usage = %+v, want no engine-client CPU or RSS", usage)
+	}
+}
+
+func TestSpawnContainerWithoutUsageRunsTheArgumentsUnchanged(t *testing.T) {
+	fakeEngine(t, "")
+
+	// Without --cidfile the stub exits 99.
+	if exitCode, err := SpawnContainer("engine", []string{"run", "image"}, os.Environ(), SpawnOptions{}, "container test", false); err != nil || exitCode != 99 {
+		t.Fatalf("SpawnContainer() = %d, %v, want 99, nil", exitCode, err)
+	}
+}
+
+func TestSpawnContainerStopsTheNamedContainerAtTheTimeout(t *testing.T) {
+	spawnFrom(t, false)
+	dir := t.TempDir()
+	// The stub's stop ends the running container, as the engine would.
+	script := `#!/bin/sh
+case "$1" in
+run) echo $$ > "$STUB_DIR/pid"; exec sleep 5 ;;
+*) echo "$@" >> "$STUB_DIR/calls"; kill "$(cat "$STUB_DIR/pid")" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "engine"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write engine stub: %v", err)
+	}
+	t.Setenv("STUB_DIR", dir)
+	opts := SpawnOptions{Timeout: 100 * time.Millisecond, Container: ContainerName("abc123")}
+
+	exitCode, err := SpawnContainer(filepath.Join(dir, "engine"), []string{"run", "image"}, os.Environ(), opts, "container test", false)
+
+	if exitCode != TimeoutExitCode || !errors.Is(err, ErrTimedOut) {
+		t.Fatalf("SpawnContainer() = %d, %v, want %d, %v", exitCode, err, TimeoutExitCode, ErrTimedOut)
+	}
+	calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+	if got := strings.TrimSpace(string(calls)); got != "stop --time 0 agent-cli-abc123" {
+		t.Errorf("engine calls = %q, want the named container stopped", got)
+	}
+}
+
+func TestContainerBindsStopsAtTheImage(t *testing.T) {
+	args := []string{
+		"run", "--rm", "-v", "/work:/work", "--tmpfs", "/tmp:rw",
+		"-v", "/nix/store/x:/nix/store/x:ro", "-v", "bad",
+		"image:tag", "sh", "-c", "-v", "/host:/host",
+	}
+	want := []Bind{
+		{Source: "/work", Target: "/work"},
+		{Source: "/nix/store/x", Target: "/nix/store/x", ReadOnly: true},
+	}
+	if got := ContainerBinds(args, "image:tag"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ContainerBinds() = %+v, want %+v", got, want)
+	}
+}
+
+func TestContainerBindsReadsACopyOnWriteWorkspaceAsReadOnly(t *testing.T) {
+	args := []string{
+		"run", "-v", "/work:/work:O,upperdir=/r/upper,workdir=/r/work",
+		"--mount", `type=volume,dst=/src,volume-driver=local,volume-opt=type=overlay,"volume-opt=o=lowerdir=/src,upperdir=/r/upper,workdir=/r/work"`,
+		"image:tag",
+	}
+	want := []Bind{
+		{Source: "/work", Target: "/work", ReadOnly: true},
+		{Source: "/src", Target: "/src", ReadOnly: true},
+	}
+	if got := ContainerBinds(args, "image:tag"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ContainerBinds() = %+v, want %+v", got, want)
+	}
+}
@@ -1,10 +1,16 @@
 // Package shared is the isolation axis core: the Isolator port plus the neutral
 // RunConfig the composition root hands every isolator. It names no concrete
-// isolator (none/bwrap/docker) and no sibling axis leaf; the per-isolator network
-// flags live in isolation/<type>/network.go bridge files.
+// isolator (none/bwrap/docker/podman/landlock/native) and no sibling axis leaf;
+// the per-isolator network flags live in isolation/<type>/network.go bridge
+// files.
 package shared
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
@@ -13,7 +19,7 @@ import (
 // RunConfig carries the run-wide inputs every isolator needs to launch the agent.
 // It replaces the old flat SandboxConfig god-struct: it holds only the fields the
 // isolators actually consume (data coupling), and the provisioning inputs live in
-// the provision axis, not here. Per-isolator knobs (docker Image/Runtime, bwrap
+// the provision axis, not here. Per-isolator knobs (container Image/Runtime, bwrap
 // HostPassthrough) are assembled into their leaf Options at the composition root
 // and copied onto the fields below.
 type RunConfig struct {
@@ -24,14 +30,34 @@ type RunConfig struct {
 	// Network is the egress mode; the isolator emits its own network flags via its
 	// network.go bridge.
 	Network netshared.Mode
+	// Relay is the host side of the in-sandbox relay: the egress proxy socket for
+	// network=proxy and any loopback forwards for network none or proxy.
+	Relay netshared.RelayTransport
 
-	// HostPassthrough is the bwrap knob: expose host tool dirs as a fallback.
+	// HostPassthrough is the bwrap/landlock/native knob: expose host tool dirs
+	// as a fallback.
 	HostPassthrough bool
-	// Image and Runtime are the docker knobs: the pinned image and a kernel-isolating
-	// container runtime (e.g. runsc); empty Runtime means default runc.
+	// Image and Runtime are the container (docker/podman) knobs: the pinned
+	// image and a kernel-isolating container runtime (e.g. runsc); empty Runtime
+	// means the engine's default runc/crun.
 	Image   string
 	Runtime string
 
+	// Limits are the resource ceilings for the sandbox's process tree; the
+	// container isolators pass them to the engine. Cgroup is where the
+	// host-process isolators start the sandbox so the same limits apply; the
+	// zero value leaves it in agent-cli's own cgroup.
+	Limits ResourceLimits
+	Cgroup Cgroup
+
+	// SyscallFilter is the seccomp deny list the isolator installs; the zero
+	// value installs none beyond the isolator's own defaults.
+	SyscallFilter SyscallFilter
+
+	// Usage, when set, receives what the run consumed once Run returns; fields
+	// the isolator cannot measure stay zero.
+	Usage *Usage
+
 	BindPaths   []string
 	RoBindPaths []string
 	CustomEnv   []string
@@ -39,24 +65,84 @@ type RunConfig struct {
 	Agent     *types.AgentConfig
 	Provider  *types.ModelProvider
 	AgentArgs []string
+	// Prompt, when set, runs the agent headless on this task.
+	Prompt string
+
+	// NoTTY makes the container isolators attach stdin without allocating a
+	// terminal, for a run whose stdin is a pipe or file.
+	NoTTY bool
+	// Timeout stops the sandbox once it has run this long; zero never does.
+	Timeout time.Duration
+	// RunID, when set, names and labels the run's container so it can be
+	// stopped through the engine.
+	RunID string
+	// Overlay, when set, mounts the work directory copy-on-write: the sandbox
+	// sees it through an overlayfs whose writes land in Overlay.Upper, and the
+	// work directory itself stays untouched until the writes are reviewed.
+	Overlay Overlay
+	// Protect lists workspace-relative globs whose matches stay read-only
+	// inside the sandbox, bound over the writable work directory.
+	Protect []string
 
 	Verbose bool
 }
 
+// Overlay is the host side of a copy-on-write work directory: the overlayfs
+// upper directory that receives the sandbox's writes and the work directory
+// overlayfs needs on the same filesystem. The zero value is a plain bind.
+type Overlay struct {
+	Upper string
+	Work  string
+}
+
+// Enabled reports whether the overlay is set.
+func (o Overlay) Enabled() bool { return o.Upper != "" }
+
+// Options renders the overlayfs mount options of o over lower. The option
+// syntax cannot quote a comma or a colon, so a path holding one is refused.
+func (o Overlay) Options(lower string) (string, error) {
+	for _, path := range []string{lower, o.Upper, o.Work} {
+		if strings.ContainsAny(path, ",:\"") {
+			return "", fmt.Errorf("cannot mount %q copy-on-write: overlayfs paths may not contain a comma, colon or quote", path)
+		}
+	}
+	return "lowerdir=" + lower + ",upperdir=" + o.Upper + ",workdir=" + o.Work, nil
+}
+
+// Bind is a host path reachable inside the sandbox at Target.
+type Bind struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Exposure is what a sandbox reaches of the host: its binds (or, for rule-based
+// isolators, the paths it may access, with Target equal to Source) and the
+// names of the environment variables set inside it. Values are left out so a
+// secret never reaches a plan.
+type Exposure struct {
+	Binds []Bind
+	Env   []string
+}
+
 // Isolator confines the agent process and applies a Provisioner's Contribution
-// via its own mechanism (bwrap binds / docker -v / direct host exec). It applies
-// the network mode explicitly and declares its host-tools / kernel-isolation
-// capabilities so the policy engine never names a concrete isolator.
+// via its own mechanism (bwrap binds / docker or podman -v / direct host exec). It applies
+// the network mode explicitly and declares its host-tools / kernel-isolation /
+// egress capabilities so the policy engine never names a concrete isolator.
 type Isolator interface {
 	Available() (bool, error)
 	Run(cfg RunConfig, c provision.Contribution) (int, error)
 	Command(cfg RunConfig, c provision.Contribution) ([]string, error)
 	// TerminalCommand returns the command AND the environment to launch it under a
 	// terminal wrapper. Isolators that bake the environment into the command
-	// (bwrap/docker) return the host environment; the host (none) isolator, which
-	// bakes nothing, returns the agent command with its full resolved environment
-	// (provider tokens, custom env, and the provisioner's PATH/env).
+	// (bwrap/docker/podman/landlock/native) return the host environment; the
+	// host (none) isolator, which bakes nothing, returns the agent command with
+	// its full resolved environment (provider tokens, custom env, and the
+	// provisioner's PATH/env).
 	TerminalCommand(cfg RunConfig, c provision.Contribution) (command []string, env []string, err error)
+	// Exposure reports what of the host the sandbox Command builds can reach,
+	// derived from that same command so a reviewed plan shows what runs.
+	Exposure(cfg RunConfig, c provision.Contribution) (Exposure, error)
 	// PinnedProvision reports whether all tool inputs used by this isolator are
 	// immutable. Image-based isolators must account for the image as well as any
 	// separate provisioner contribution.
@@ -69,4 +155,30 @@ type Isolator interface {
 	// KernelIsolated reports whether this isolator plus runtime gives a kernel
 	// boundary (so RequireKernelIsolation can be honored).
 	KernelIsolated(runtime string) bool
+	// FiltersSyscalls reports whether this isolator installs the named seccomp
+	// profile (so RequireSyscallFilter can be honored); an empty profile is
+	// never a filter.
+	FiltersSyscalls(profile string) bool
+	// RestrictsEgress reports whether this isolator enforces the network mode's
+	// egress restriction for every protocol (so RequireEgressRestricted can be
+	// honored); a restricted mode the isolator only partially enforces does not
+	// count.
+	RestrictsEgress(network netshared.Mode) bool
+	// Cost is the isolator's relative startup and runtime overhead with the
+	// given container runtime, which the policy solver uses to rank cells that
+	// satisfy a policy; 0 is running on the host unconfined.
+	Cost(runtime string) int
+	// CopyOnWrite reports whether this isolator can mount the work directory
+	// through RunConfig.Overlay (so a run's writes can be reviewed).
+	CopyOnWrite() bool
+}
+
+// EnvNames returns the sorted names of env.
+func EnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
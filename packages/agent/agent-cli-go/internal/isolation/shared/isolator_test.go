@@ -0,0 +1,27 @@
+package shared
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnvNamesAreSortedAndValueFree(t *testing.T) {
+	got := EnvNames(map[string]string{"PATH": "/bin", "HOME": "/home/agent", "API_KEY": "secret"})
+	if want := []string{"API_KEY", "HOME", "PATH"}; !slices.Equal(got, want) {
+		t.Errorf("EnvNames() = %v, want %v", got, want)
+	}
+}
+
+func TestOverlayOptionsRefuseAPathTheySyntaxCannotHold(t *testing.T) {
+	o := Overlay{Upper: "/r/upper", Work: "/r/work"}
+	if got, err := o.Options("/work"); err != nil || got != "lowerdir=/work,upperdir=/r/upper,workdir=/r/work" {
+		t.Errorf("Options() = (%q, %v), want the three layers", got, err)
+	}
+	if _, err := o.Options("/work,x"); err == nil || !strings.Contains(err.Error(), "copy-on-write") {
+		t.Errorf("Options(comma) error = %v, want it refused", err)
+	}
+	if (Overlay{}).Enabled() || !o.Enabled() {
+		t.Error("Enabled() should report whether an upper layer is set")
+	}
+}
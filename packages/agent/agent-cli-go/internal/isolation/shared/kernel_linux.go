@@ -0,0 +1,70 @@
+//go:build linux
+
+package shared
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// userNamespaceSysctls are the sysctls that gate unprivileged user namespaces
+// and the minimum value each must hold.
+var userNamespaceSysctls = map[string]int{
+	"/proc/sys/user/max_user_namespaces": 1,
+	// Debian and older Ubuntu kernels gate unprivileged user namespaces.
+	"/proc/sys/kernel/unprivileged_userns_clone": 1,
+}
+
+// UserNamespacesAvailable reports whether this kernel lets an unprivileged
+// process create a user namespace.
+func UserNamespacesAvailable() bool {
+	return userNamespacesAvailable("/proc/self/ns/user", userNamespaceSysctls)
+}
+
+func userNamespacesAvailable(nsFile string, sysctls map[string]int) bool {
+	if _, err := os.Stat(nsFile); err != nil {
+		return false
+	}
+	for path, minimum := range sysctls {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if value, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && value < minimum {
+			return false
+		}
+	}
+	return true
+}
+
+// CgroupV2Available reports whether the unified cgroup v2 hierarchy is
+// mounted, which resource limits need.
+func CgroupV2Available() bool {
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	return err == nil
+}
+
+// SeccompAvailable reports whether this kernel supports seccomp filters, which
+// SeccompDenyList profiles need.
+func SeccompAvailable() bool {
+	return seccompAvailable("/proc/self/status")
+}
+
+func seccompAvailable(statusFile string) bool {
+	f, err := os.Open(statusFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The field exists only on kernels built with CONFIG_SECCOMP.
+		if strings.HasPrefix(scanner.Text(), "Seccomp:") {
+			return true
+		}
+	}
+	return false
+}
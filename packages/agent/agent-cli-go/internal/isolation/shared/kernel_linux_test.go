@@ -0,0 +1,40 @@
+//go:build linux
+
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProbeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "probe")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestUserNamespacesAvailableHonoursTheGatingSysctls(t *testing.T) {
+	ns := writeProbeFile(t, "")
+	if !userNamespacesAvailable(ns, map[string]int{writeProbeFile(t, "15000\n"): 1, "/missing": 1}) {
+		t.Error("userNamespacesAvailable(open sysctls) = false, want true")
+	}
+	if userNamespacesAvailable(ns, map[string]int{writeProbeFile(t, "0\n"): 1}) {
+		t.Error("userNamespacesAvailable(sysctl 0) = true, want false")
+	}
+	if userNamespacesAvailable(filepath.Join(t.TempDir(), "user"), nil) {
+		t.Error("userNamespacesAvailable(no namespace file) = true, want false")
+	}
+}
+
+func TestSeccompAvailableLooksForTheStatusField(t *testing.T) {
+	if !seccompAvailable(writeProbeFile(t, "Name:\tagent\nSeccomp:\t0\n")) {
+		t.Error("seccompAvailable(Seccomp field) = false, want true")
+	}
+	if seccompAvailable(writeProbeFile(t, "Name:\tagent\n")) || seccompAvailable("/missing") {
+		t.Error("seccompAvailable(no field) = true, want false")
+	}
+}
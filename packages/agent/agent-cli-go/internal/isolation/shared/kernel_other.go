@@ -0,0 +1,12 @@
+//go:build !linux
+
+package shared
+
+// UserNamespacesAvailable reports false: user namespaces are Linux-only.
+func UserNamespacesAvailable() bool { return false }
+
+// CgroupV2Available reports false: cgroups are Linux-only.
+func CgroupV2Available() bool { return false }
+
+// SeccompAvailable reports false: seccomp is Linux-only.
+func SeccompAvailable() bool { return false }
@@ -0,0 +1,109 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Default DoS ceilings. They are deliberately generous (containment, not
+// quotas): enough for a large build, low enough that a runaway one cannot take
+// the host down. CPU has no default ceiling.
+const (
+	DefaultMemoryLimit int64 = 8 << 30
+	DefaultPidsLimit   int64 = 4096
+)
+
+// ErrInvalidResourceLimit reports a memory, pids or cpus limit that is not a
+// positive quantity.
+var ErrInvalidResourceLimit = errors.New("invalid resource limit")
+
+// ResourceLimits are the ceilings every isolator applies to the sandbox's whole
+// process tree: the container engines through their own flags, the host-process
+// isolators through a cgroup v2 subtree (see Cgroup). A zero field takes its
+// default (see Resolved).
+type ResourceLimits struct {
+	// MemoryBytes caps resident memory (memory.max, --memory).
+	MemoryBytes int64
+	// Pids caps the number of tasks (pids.max, --pids-limit).
+	Pids int64
+	// CPUs caps CPU time in whole or fractional CPUs (cpu.max, --cpus); zero
+	// leaves CPU unlimited.
+	CPUs float64
+}
+
+// Resolved returns l with every zero field set to its default.
+func (l ResourceLimits) Resolved() ResourceLimits {
+	if l.MemoryBytes == 0 {
+		l.MemoryBytes = DefaultMemoryLimit
+	}
+	if l.Pids == 0 {
+		l.Pids = DefaultPidsLimit
+	}
+	return l
+}
+
+// String renders the limits for dry-run output, e.g. "memory=8g pids=4096
+// cpus=unlimited".
+func (l ResourceLimits) String() string {
+	cpus := "unlimited"
+	if l.CPUs > 0 {
+		cpus = strconv.FormatFloat(l.CPUs, 'f', -1, 64)
+	}
+	return fmt.Sprintf("memory=%s pids=%d cpus=%s", FormatMemory(l.MemoryBytes), l.Pids, cpus)
+}
+
+// ParseResourceLimits validates limits given as flags or config values: memory
+// as a byte count with an optional b, k, m or g suffix (binary units, as docker
+// reads them), pids and cpus as numbers. Empty or zero values keep the default.
+func ParseResourceLimits(memory string, pids int64, cpus float64) (ResourceLimits, error) {
+	limits := ResourceLimits{Pids: pids, CPUs: cpus}
+	if memory != "" {
+		bytes, err := ParseMemory(memory)
+		if err != nil {
+			return ResourceLimits{}, err
+		}
+		limits.MemoryBytes = bytes
+	}
+	if pids < 0 {
+		return ResourceLimits{}, fmt.Errorf("%w: pids %d", ErrInvalidResourceLimit, pids)
+	}
+	if cpus < 0 {
+		return ResourceLimits{}, fmt.Errorf("%w: cpus %g", ErrInvalidResourceLimit, cpus)
+	}
+	return limits, nil
+}
+
+var memoryUnits = []struct {
+	suffix string
+	shift  uint
+}{{"g", 30}, {"m", 20}, {"k", 10}, {"b", 0}}
+
+// ParseMemory parses a positive byte count with an optional b, k, m or g
+// suffix, in either case.
+func ParseMemory(value string) (int64, error) {
+	number, shift := strings.ToLower(strings.TrimSpace(value)), uint(0)
+	for _, unit := range memoryUnits {
+		if trimmed, ok := strings.CutSuffix(number, unit.suffix); ok {
+			number, shift = trimmed, unit.shift
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 || n > (1<<62)>>shift {
+		return 0, fmt.Errorf("%w: memory %q (want a positive size such as 512m or 8g)", ErrInvalidResourceLimit, value)
+	}
+	return n << shift, nil
+}
+
+// FormatMemory renders bytes in the largest unit that divides it exactly, as
+// ParseMemory and the container engines read it.
+func FormatMemory(bytes int64) string {
+	for _, unit := range memoryUnits[:3] {
+		if size := int64(1) << unit.shift; bytes >= size && bytes%size == 0 {
+			return strconv.FormatInt(bytes/size, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(bytes, 10)
+}
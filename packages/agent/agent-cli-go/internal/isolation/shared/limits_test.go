@@ -0,0 +1,62 @@
+package shared
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"8g", 8 << 30}, {"512M", 512 << 20}, {"64k", 64 << 10}, {"100b", 100}, {"4096", 4096},
+	}
+	for _, test := range tests {
+		if got, err := ParseMemory(test.in); err != nil || got != test.want {
+			t.Errorf("ParseMemory(%q) = (%d, %v), want %d", test.in, got, err, test.want)
+		}
+	}
+	for _, bad := range []string{"", "g", "-1g", "0", "1.5g", "8t", "99999999999g"} {
+		if _, err := ParseMemory(bad); !errors.Is(err, ErrInvalidResourceLimit) {
+			t.Errorf("ParseMemory(%q) error = %v, want %v", bad, err, ErrInvalidResourceLimit)
+		}
+	}
+}
+
+func TestFormatMemoryRoundTrips(t *testing.T) {
+	for _, bytes := range []int64{8 << 30, 1536 << 20, 3 << 10, 1000} {
+		parsed, err := ParseMemory(FormatMemory(bytes))
+		if err != nil || parsed != bytes {
+			t.Errorf("ParseMemory(FormatMemory(%d)) = (%d, %v)", bytes, parsed, err)
+		}
+	}
+	if got := FormatMemory(1536 << 20); got != "1536m" {
+		t.Errorf("FormatMemory(1536m) = %q", got)
+	}
+}
+
+func TestParseResourceLimits(t *testing.T) {
+	limits, err := ParseResourceLimits("2g", 0, 1.5)
+	if err != nil {
+		t.Fatalf("ParseResourceLimits() error = %v", err)
+	}
+	want := ResourceLimits{MemoryBytes: 2 << 30, Pids: DefaultPidsLimit, CPUs: 1.5}
+	if got := limits.Resolved(); got != want {
+		t.Errorf("Resolved() = %+v, want %+v", got, want)
+	}
+	if got := limits.Resolved().String(); got != "memory=2g pids=4096 cpus=1.5" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (ResourceLimits{}).Resolved().String(); got != "memory=8g pids=4096 cpus=unlimited" {
+		t.Errorf("default String() = %q", got)
+	}
+	for _, bad := range []struct {
+		pids int64
+		cpus float64
+	}{{-1, 0}, {0, -0.5}} {
+		if _, err := ParseResourceLimits("", bad.pids, bad.cpus); !errors.Is(err, ErrInvalidResourceLimit) {
+			t.Errorf("ParseResourceLimits(pids %d, cpus %g) error = %v, want %v", bad.pids, bad.cpus, err, ErrInvalidResourceLimit)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package shared
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -85,3 +87,42 @@ func ResolveContainedOptionalPath(homeDir, relative, purpose string) (string, bo
 	}
 	return resolved, true, nil
 }
+
+// ResolveProtectedPaths expands workspace-relative globs into the canonical
+// paths they match inside workDir, which must itself be canonical. A pattern
+// that is absolute or climbs out of the work directory is rejected, as is a
+// match that resolves outside it through a symlink. A pattern that matches
+// nothing binds nothing; what comes to match it is removed after the run.
+func ResolveProtectedPaths(workDir string, patterns []string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		if filepath.IsAbs(pattern) {
+			return nil, fmt.Errorf("protected path %q must be relative to the work directory", pattern)
+		}
+		clean := filepath.Clean(pattern)
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("protected path %q is not inside the work directory", pattern)
+		}
+		matches, err := fs.Glob(os.DirFS(workDir), filepath.ToSlash(clean))
+		if err != nil {
+			return nil, fmt.Errorf("invalid protected path %q: %w", pattern, err)
+		}
+		for _, match := range matches {
+			candidate := filepath.Join(workDir, filepath.FromSlash(match))
+			resolved, err := filepath.EvalSymlinks(candidate)
+			if err != nil {
+				return nil, fmt.Errorf("resolve protected path %q: %w", candidate, err)
+			}
+			relative, err := filepath.Rel(workDir, resolved)
+			if err != nil {
+				return nil, fmt.Errorf("verify protected path containment: %w", err)
+			}
+			if relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) || filepath.IsAbs(relative) {
+				return nil, fmt.Errorf("protected path %q resolves outside work directory %q", match, workDir)
+			}
+			paths = append(paths, resolved)
+		}
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths), nil
+}
@@ -3,6 +3,7 @@ package shared
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -84,3 +85,49 @@ func TestResolveExistingPath(t *testing.T) {
 		t.Fatal("ResolveExistingPath() missing error = nil, want error")
 	}
 }
+
+func TestResolveProtectedPathsExpandsGlobsInsideTheWorkDir(t *testing.T) {
+	work, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	for _, name := range []string{".github/workflows/ci.yml", "flake.lock", "go.sum"} {
+		path := filepath.Join(work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	got, err := ResolveProtectedPaths(work, []string{"*.lock", ".github/workflows", "./flake.lock", "CODEOWNERS"})
+
+	want := []string{filepath.Join(work, ".github", "workflows"), filepath.Join(work, "flake.lock")}
+	if err != nil || !slices.Equal(got, want) {
+		t.Fatalf("ResolveProtectedPaths() = (%q, %v), want %q", got, err, want)
+	}
+}
+
+func TestResolveProtectedPathsRejectsPathsOutsideTheWorkDir(t *testing.T) {
+	work, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks() error = %v", err)
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(work, "escape")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	for pattern, want := range map[string]string{
+		"/etc/passwd":   "relative to the work directory",
+		".":             "not inside the work directory",
+		"../sibling":    "not inside the work directory",
+		"a/../../other": "not inside the work directory",
+		"[":             "invalid protected path",
+		"esc*":          "resolves outside work directory",
+	} {
+		if _, err := ResolveProtectedPaths(work, []string{pattern}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ResolveProtectedPaths(%q) error = %v, want %q", pattern, err, want)
+		}
+	}
+}
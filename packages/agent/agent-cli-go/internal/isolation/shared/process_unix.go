@@ -0,0 +1,28 @@
+//go:build !windows
+
+package shared
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd as the leader of a new process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcess sends sig to cmd's process group when it leads one, else to
+// the process alone.
+func signalProcess(cmd *exec.Cmd, group bool, sig os.Signal) {
+	number, ok := sig.(syscall.Signal)
+	if !group || !ok {
+		_ = cmd.Process.Signal(sig)
+		return
+	}
+	_ = syscall.Kill(-cmd.Process.Pid, number)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package shared
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing: Windows has no process groups to signal.
+func setProcessGroup(*exec.Cmd) {}
+
+// signalProcess kills the process: Windows cannot deliver other signals.
+func signalProcess(cmd *exec.Cmd, _ bool, _ os.Signal) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,246 @@
+package shared
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// Seccomp profiles (--seccomp-profile). Each is a deny list: the listed system
+// calls fail with EPERM and every other call is allowed.
+const (
+	// SeccompProfileDefault denies debugging other processes, the kernel
+	// keyring, eBPF, userfaultfd, every mount API and perf events.
+	SeccompProfileDefault = "default"
+	// SeccompProfileStrict adds kernel modules and kexec, cross-process memory
+	// access, io_uring, file handles, swap, reboot, process accounting and new
+	// namespaces or roots.
+	SeccompProfileStrict = "strict"
+)
+
+// ErrUnknownSeccompProfile reports a profile name no deny list is defined for.
+var ErrUnknownSeccompProfile = errors.New("unknown seccomp profile")
+
+var seccompDefaultDeny = []string{
+	"ptrace",
+	"keyctl", "add_key", "request_key",
+	"bpf",
+	"userfaultfd",
+	"mount", "umount2", "fsopen", "fsconfig", "fsmount", "fspick", "move_mount", "open_tree", "mount_setattr",
+	"perf_event_open",
+}
+
+var seccompProfiles = map[string][]string{
+	SeccompProfileDefault: seccompDefaultDeny,
+	SeccompProfileStrict: append(append([]string{}, seccompDefaultDeny...),
+		"init_module", "finit_module", "delete_module", "kexec_load", "kexec_file_load",
+		"process_vm_readv", "process_vm_writev",
+		"io_uring_setup", "io_uring_enter", "io_uring_register",
+		"name_to_handle_at", "open_by_handle_at",
+		"swapon", "swapoff", "reboot", "acct",
+		"unshare", "setns", "pivot_root", "chroot",
+	),
+}
+
+// SeccompProfiles returns the defined profile names, sorted.
+func SeccompProfiles() []string {
+	names := make([]string, 0, len(seccompProfiles))
+	for name := range seccompProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SeccompDenyList returns the system calls profile denies.
+func SeccompDenyList(profile string) ([]string, error) {
+	deny, ok := seccompProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (want one of %v)", ErrUnknownSeccompProfile, profile, SeccompProfiles())
+	}
+	return append([]string{}, deny...), nil
+}
+
+// SyscallFilter is a seccomp profile compiled to the host files the isolators
+// load it from. The zero value applies no filter.
+type SyscallFilter struct {
+	// Profile names the deny list.
+	Profile string
+	// BPFFile holds the filter as a raw array of struct sock_filter for the
+	// host architecture, as bwrap --seccomp reads it. It is empty where no
+	// syscall table is known.
+	BPFFile string
+	// OCIFile holds the filter as an OCI seccomp profile, as docker and podman
+	// --security-opt seccomp= read it.
+	OCIFile string
+}
+
+// WriteSyscallFilter compiles profile into dir, which the caller owns and
+// removes once the sandbox exits.
+func WriteSyscallFilter(dir, profile string) (SyscallFilter, error) {
+	oci, err := SeccompOCIProfile(profile)
+	if err != nil {
+		return SyscallFilter{}, err
+	}
+	filter := SyscallFilter{Profile: profile, OCIFile: filepath.Join(dir, "seccomp.json")}
+	if err := os.WriteFile(filter.OCIFile, oci, 0o600); err != nil {
+		return SyscallFilter{}, fmt.Errorf("write seccomp profile: %w", err)
+	}
+	program, err := CompileSeccompBPF(profile, runtime.GOARCH)
+	if errors.Is(err, errSeccompArchUnsupported) {
+		return filter, nil
+	}
+	if err != nil {
+		return SyscallFilter{}, err
+	}
+	filter.BPFFile = filepath.Join(dir, "seccomp.bpf")
+	if err := os.WriteFile(filter.BPFFile, program, 0o600); err != nil {
+		return SyscallFilter{}, fmt.Errorf("write seccomp filter: %w", err)
+	}
+	return filter, nil
+}
+
+// ociSeccompProfile is the subset of the OCI runtime seccomp profile the deny
+// lists need.
+type ociSeccompProfile struct {
+	DefaultAction string              `json:"defaultAction"`
+	Syscalls      []ociSeccompSyscall `json:"syscalls"`
+}
+
+type ociSeccompSyscall struct {
+	Names    []string `json:"names"`
+	Action   string   `json:"action"`
+	ErrnoRet int      `json:"errnoRet"`
+}
+
+// SeccompOCIProfile renders profile as an OCI seccomp profile. It replaces the
+// container engine's default profile, so the deny list is exactly what the
+// container gets.
+func SeccompOCIProfile(profile string) ([]byte, error) {
+	deny, err := SeccompDenyList(profile)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(ociSeccompProfile{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Syscalls:      []ociSeccompSyscall{{Names: deny, Action: "SCMP_ACT_ERRNO", ErrnoRet: errnoEPERM}},
+	}, "", "  ")
+}
+
+// Classic BPF as seccomp runs it (linux/filter.h, linux/seccomp.h, linux/audit.h).
+const (
+	bpfLoadWordAbs = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJumpEqual   = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJumpGreater = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfReturn      = 0x06 // BPF_RET | BPF_K
+
+	seccompDataNr   = 0 // offsetof(struct seccomp_data, nr)
+	seccompDataArch = 4 // offsetof(struct seccomp_data, arch)
+
+	seccompRetAllow = 0x7fff0000
+	seccompRetErrno = 0x00050000
+	errnoEPERM      = 1
+
+	// x32SyscallBit marks the x32 ABI's calls, which share AUDIT_ARCH_X86_64.
+	x32SyscallBit = 0x40000000
+)
+
+var errSeccompArchUnsupported = errors.New("no seccomp syscall table for this architecture")
+
+// seccompArch is the audit architecture and syscall numbers of one GOARCH.
+type seccompArch struct {
+	audit    uint32
+	x32      bool
+	syscalls map[string]uint32
+}
+
+var seccompArches = map[string]seccompArch{
+	"amd64": {audit: 0xc000003e, x32: true, syscalls: map[string]uint32{
+		"ptrace": 101, "keyctl": 250, "add_key": 248, "request_key": 249, "bpf": 321, "userfaultfd": 323,
+		"mount": 165, "umount2": 166, "fsopen": 430, "fsconfig": 431, "fsmount": 432, "fspick": 433,
+		"move_mount": 429, "open_tree": 428, "mount_setattr": 442, "perf_event_open": 298,
+		"init_module": 175, "finit_module": 313, "delete_module": 176, "kexec_load": 246, "kexec_file_load": 320,
+		"process_vm_readv": 310, "process_vm_writev": 311,
+		"io_uring_setup": 425, "io_uring_enter": 426, "io_uring_register": 427,
+		"name_to_handle_at": 303, "open_by_handle_at": 304,
+		"swapon": 167, "swapoff": 168, "reboot": 169, "acct": 163,
+		"unshare": 272, "setns": 308, "pivot_root": 155, "chroot": 161,
+	}},
+	"arm64": {audit: 0xc00000b7, syscalls: map[string]uint32{
+		"ptrace": 117, "keyctl": 219, "add_key": 217, "request_key": 218, "bpf": 280, "userfaultfd": 282,
+		"mount": 40, "umount2": 39, "fsopen": 430, "fsconfig": 431, "fsmount": 432, "fspick": 433,
+		"move_mount": 429, "open_tree": 428, "mount_setattr": 442, "perf_event_open": 241,
+		"init_module": 105, "finit_module": 273, "delete_module": 106, "kexec_load": 104, "kexec_file_load": 294,
+		"process_vm_readv": 270, "process_vm_writev": 271,
+		"io_uring_setup": 425, "io_uring_enter": 426, "io_uring_register": 427,
+		"name_to_handle_at": 264, "open_by_handle_at": 265,
+		"swapon": 224, "swapoff": 225, "reboot": 142, "acct": 89,
+		"unshare": 97, "setns": 268, "pivot_root": 41, "chroot": 51,
+	}},
+}
+
+// sockFilter is struct sock_filter.
+type sockFilter struct {
+	code uint16
+	jt   uint8
+	jf   uint8
+	k    uint32
+}
+
+// CompileSeccompBPF compiles profile for goarch into a seccomp BPF program. A
+// call from another architecture (or, on amd64, the x32 ABI) is denied too, so
+// the deny list cannot be bypassed through a second syscall table.
+func CompileSeccompBPF(profile, goarch string) ([]byte, error) {
+	deny, err := SeccompDenyList(profile)
+	if err != nil {
+		return nil, err
+	}
+	arch, ok := seccompArches[goarch]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errSeccompArchUnsupported, goarch)
+	}
+
+	// Every check jumps forward to the deny return at the end.
+	checks := len(deny)
+	if arch.x32 {
+		checks++
+	}
+	denyAt := 3 + checks + 1
+	if denyAt > 0xff {
+		return nil, fmt.Errorf("seccomp profile %q has too many rules", profile)
+	}
+	toDeny := func(at int) uint8 { return uint8(denyAt - at - 1) }
+
+	program := []sockFilter{
+		{code: bpfLoadWordAbs, k: seccompDataArch},
+		{code: bpfJumpEqual, jf: toDeny(1), k: arch.audit},
+		{code: bpfLoadWordAbs, k: seccompDataNr},
+	}
+	if arch.x32 {
+		program = append(program, sockFilter{code: bpfJumpGreater, jt: toDeny(len(program)), k: x32SyscallBit})
+	}
+	for _, name := range deny {
+		nr, ok := arch.syscalls[name]
+		if !ok {
+			return nil, fmt.Errorf("seccomp profile %q: no %s number for %s", profile, goarch, name)
+		}
+		program = append(program, sockFilter{code: bpfJumpEqual, jt: toDeny(len(program)), k: nr})
+	}
+	program = append(program,
+		sockFilter{code: bpfReturn, k: seccompRetAllow},
+		sockFilter{code: bpfReturn, k: seccompRetErrno | errnoEPERM},
+	)
+
+	out := make([]byte, 0, len(program)*8)
+	for _, insn := range program {
+		out = binary.NativeEndian.AppendUint16(out, insn.code)
+		out = append(out, insn.jt, insn.jf)
+		out = binary.NativeEndian.AppendUint32(out, insn.k)
+	}
+	return out, nil
+}
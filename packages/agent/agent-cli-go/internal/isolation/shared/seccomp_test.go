@@ -0,0 +1,157 @@
+package shared
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// runSeccompBPF interprets the subset of classic BPF CompileSeccompBPF emits
+// against one seccomp_data, returning the filter's action.
+func runSeccompBPF(t *testing.T, program []byte, arch, nr uint32) uint32 {
+	t.Helper()
+	if len(program)%8 != 0 {
+		t.Fatalf("program length %d is not a whole number of instructions", len(program))
+	}
+	var acc uint32
+	for pc := 0; pc < len(program)/8; pc++ {
+		insn := program[pc*8 : pc*8+8]
+		code := binary.NativeEndian.Uint16(insn)
+		jt, jf := int(insn[2]), int(insn[3])
+		k := binary.NativeEndian.Uint32(insn[4:])
+		switch code {
+		case bpfLoadWordAbs:
+			switch k {
+			case seccompDataNr:
+				acc = nr
+			case seccompDataArch:
+				acc = arch
+			default:
+				t.Fatalf("load from unexpected offset %d", k)
+			}
+		case bpfJumpEqual, bpfJumpGreater:
+			taken := acc == k
+			if code == bpfJumpGreater {
+				taken = acc >= k
+			}
+			if taken {
+				pc += jt
+			} else {
+				pc += jf
+			}
+		case bpfReturn:
+			return k
+		default:
+			t.Fatalf("unexpected instruction %#x at %d", code, pc)
+		}
+	}
+	t.Fatal("program ran off its end")
+	return 0
+}
+
+func TestCompileSeccompBPFDeniesTheProfile(t *testing.T) {
+	const denied = seccompRetErrno | errnoEPERM
+	for _, goarch := range []string{"amd64", "arm64"} {
+		t.Run(goarch, func(t *testing.T) {
+			arch := seccompArches[goarch]
+			program, err := CompileSeccompBPF(SeccompProfileDefault, goarch)
+			if err != nil {
+				t.Fatalf("CompileSeccompBPF() error = %v", err)
+			}
+			for _, name := range seccompDefaultDeny {
+				if got := runSeccompBPF(t, program, arch.audit, arch.syscalls[name]); got != denied {
+					t.Errorf("%s returned %#x, want EPERM", name, got)
+				}
+			}
+			// read is 0 on amd64 and 63 on arm64; neither profile lists it.
+			read := map[string]uint32{"amd64": 0, "arm64": 63}[goarch]
+			if got := runSeccompBPF(t, program, arch.audit, read); got != seccompRetAllow {
+				t.Errorf("read returned %#x, want allow", got)
+			}
+			// unshare is only in the strict profile.
+			if got := runSeccompBPF(t, program, arch.audit, arch.syscalls["unshare"]); got != seccompRetAllow {
+				t.Errorf("default profile denies unshare (%#x)", got)
+			}
+			if got := runSeccompBPF(t, program, 0x40000003, read); got != denied {
+				t.Errorf("a call from a foreign architecture returned %#x, want EPERM", got)
+			}
+		})
+	}
+}
+
+func TestCompileSeccompBPFDeniesTheX32ABI(t *testing.T) {
+	program, err := CompileSeccompBPF(SeccompProfileDefault, "amd64")
+	if err != nil {
+		t.Fatalf("CompileSeccompBPF() error = %v", err)
+	}
+	// x32 ptrace is 521 with the x32 bit set, a number the deny list never names.
+	if got := runSeccompBPF(t, program, seccompArches["amd64"].audit, x32SyscallBit|521); got != seccompRetErrno|errnoEPERM {
+		t.Errorf("x32 call returned %#x, want EPERM", got)
+	}
+}
+
+func TestCompileSeccompBPFStrictAddsToDefault(t *testing.T) {
+	program, err := CompileSeccompBPF(SeccompProfileStrict, "arm64")
+	if err != nil {
+		t.Fatalf("CompileSeccompBPF() error = %v", err)
+	}
+	arch := seccompArches["arm64"]
+	for _, name := range []string{"ptrace", "unshare", "io_uring_setup", "init_module"} {
+		if got := runSeccompBPF(t, program, arch.audit, arch.syscalls[name]); got != seccompRetErrno|errnoEPERM {
+			t.Errorf("strict %s returned %#x, want EPERM", name, got)
+		}
+	}
+}
+
+func TestSeccompRejectsUnknownProfilesAndArchitectures(t *testing.T) {
+	if _, err := CompileSeccompBPF("lenient", "amd64"); !errors.Is(err, ErrUnknownSeccompProfile) {
+		t.Errorf("CompileSeccompBPF(lenient) error = %v, want %v", err, ErrUnknownSeccompProfile)
+	}
+	if _, err := SeccompOCIProfile("lenient"); !errors.Is(err, ErrUnknownSeccompProfile) {
+		t.Errorf("SeccompOCIProfile(lenient) error = %v, want %v", err, ErrUnknownSeccompProfile)
+	}
+	if _, err := CompileSeccompBPF(SeccompProfileDefault, "mips"); !errors.Is(err, errSeccompArchUnsupported) {
+		t.Errorf("CompileSeccompBPF(mips) error = %v, want %v", err, errSeccompArchUnsupported)
+	}
+	if got := SeccompProfiles(); !slices.Equal(got, []string{SeccompProfileDefault, SeccompProfileStrict}) {
+		t.Errorf("SeccompProfiles() = %v", got)
+	}
+}
+
+func TestWriteSyscallFilterWritesBothForms(t *testing.T) {
+	filter, err := WriteSyscallFilter(t.TempDir(), SeccompProfileDefault)
+	if err != nil {
+		t.Fatalf("WriteSyscallFilter() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filter.OCIFile)
+	if err != nil {
+		t.Fatalf("read OCI profile: %v", err)
+	}
+	var oci ociSeccompProfile
+	if err := json.Unmarshal(data, &oci); err != nil {
+		t.Fatalf("OCI profile is not JSON: %v", err)
+	}
+	if oci.DefaultAction != "SCMP_ACT_ALLOW" || len(oci.Syscalls) != 1 ||
+		oci.Syscalls[0].Action != "SCMP_ACT_ERRNO" || !slices.Equal(oci.Syscalls[0].Names, seccompDefaultDeny) {
+		t.Errorf("OCI profile = %+v, want the default deny list returning an errno", oci)
+	}
+
+	if _, known := seccompArches[runtime.GOARCH]; !known {
+		if filter.BPFFile != "" {
+			t.Errorf("BPFFile = %q on %s, want none", filter.BPFFile, runtime.GOARCH)
+		}
+		return
+	}
+	program, err := os.ReadFile(filter.BPFFile)
+	if err != nil {
+		t.Fatalf("read BPF filter: %v", err)
+	}
+	if want, _ := CompileSeccompBPF(SeccompProfileDefault, runtime.GOARCH); !slices.Equal(program, want) {
+		t.Error("BPF file differs from the compiled program")
+	}
+}
@@ -1,15 +1,40 @@
 package shared
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
 	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/shell"
 )
 
+// TimeoutExitCode is the exit code of a run stopped by its timeout, the one
+// timeout(1) uses.
+const TimeoutExitCode = 124
+
+// ErrTimedOut reports a sandbox stopped because its timeout elapsed.
+var ErrTimedOut = errors.New("timed out")
+
+// stopGrace is how long a stopped sandbox has to exit after SIGTERM before it
+// is killed.
+var stopGrace = 10 * time.Second
+
+// stdinIsTerminal reports whether the sandbox shares agent-cli's terminal.
+var stdinIsTerminal = StdinIsTerminal
+
+// StdinIsTerminal reports whether agent-cli's stdin is a terminal.
+func StdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 // SpawnSandbox spawns a sandbox process and waits for completion. The process
 // inherits the caller's working directory; isolators that need a specific cwd
 // (e.g. host execution) use SpawnSandboxInDir.
@@ -20,18 +45,84 @@ func SpawnSandbox(command string, args []string, env []string, errorPrefix strin
 // SpawnSandboxInDir spawns a process in dir (empty = inherit the caller's cwd)
 // and waits for completion, returning the child exit code.
 func SpawnSandboxInDir(command string, args []string, env []string, dir string, errorPrefix string, verbose bool) (int, error) {
+	return SpawnSandboxWithOptions(command, args, env, SpawnOptions{Dir: dir}, errorPrefix, verbose)
+}
+
+// SpawnOptions are the optional settings of a sandbox process.
+type SpawnOptions struct {
+	// Dir is the working directory; empty inherits the caller's.
+	Dir string
+	// Files are inherited as file descriptors 3, 4, ... (for arguments such as
+	// bwrap --seccomp <fd>).
+	Files []*os.File
+	// Cgroup places the process tree under the run's resource limits.
+	Cgroup Cgroup
+	// Usage, when set, receives what the process consumed.
+	Usage *Usage
+	// Timeout stops the process once it has run this long; zero never does.
+	Timeout time.Duration
+	// Container is the name of the container Engine runs the process in. A
+	// stopped sandbox is then stopped through the engine, since signalling the
+	// engine client does not reach a detached or slow-starting container.
+	Engine    string
+	Container string
+}
+
+// SpawnSandboxWithOptions spawns a sandbox process with opts and waits for
+// completion, returning the child exit code.
+//
+// SIGINT, SIGTERM and SIGHUP sent to agent-cli stop the sandbox: it is sent the
+// signal and, if it has not exited after a grace period, killed. Without a
+// terminal the sandbox runs in its own process group and the whole group is
+// signalled. With one it stays in the terminal's foreground group, so job
+// control keeps working and the terminal's own SIGINT is left to the agent,
+// which may only cancel its current turn. A process still running at
+// opts.Timeout is stopped the same way and reported as ErrTimedOut with
+// TimeoutExitCode.
+func SpawnSandboxWithOptions(command string, args []string, env []string, opts SpawnOptions, errorPrefix string, verbose bool) (int, error) {
+	if len(opts.Cgroup.Scope) > 0 {
+		args = append(append(append([]string{}, opts.Cgroup.Scope[1:]...), command), args...)
+		command = opts.Cgroup.Scope[0]
+	}
 	if verbose {
 		logging.LogDebug(fmt.Sprintf("Executing: %s %s", command, strings.Join(args, " ")))
 	}
 
 	cmd := exec.Command(command, args...)
-	cmd.Dir = dir
+	cmd.Dir = opts.Dir
 	cmd.Env = env
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = opts.Files
+	if opts.Cgroup.Dir != "" {
+		attr, release, err := cgroupProcAttr(opts.Cgroup.Dir)
+		if err != nil {
+			return 1, fmt.Errorf("%s: %w", errorPrefix, err)
+		}
+		defer release()
+		cmd.SysProcAttr = attr
+	}
+	sandbox := &runningSandbox{cmd: cmd, group: !stdinIsTerminal(), engine: opts.Engine, container: opts.Container}
+	if sandbox.group {
+		setProcessGroup(cmd)
+	}
 
-	if err := cmd.Run(); err != nil {
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return 1, fmt.Errorf("%s: %w", errorPrefix, err)
+	}
+	timedOut, err := sandbox.wait(opts.Timeout)
+	if opts.Usage != nil && cmd.ProcessState != nil {
+		recordUsage(opts.Usage, cmd.ProcessState, time.Since(start), opts.Cgroup.Dir)
+	}
+	if timedOut {
+		if opts.Usage != nil {
+			opts.Usage.ExitCode = TimeoutExitCode
+		}
+		return TimeoutExitCode, fmt.Errorf("%s: %w after %s", errorPrefix, ErrTimedOut, opts.Timeout)
+	}
+	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return exitErr.ExitCode(), nil
 		}
@@ -41,6 +132,117 @@ func SpawnSandboxInDir(command string, args []string, env []string, dir string,
 	return 0, nil
 }
 
+// runningSandbox is a started sandbox process. group is set when it leads its
+// own process group; container names the container it runs, if any.
+type runningSandbox struct {
+	cmd       *exec.Cmd
+	group     bool
+	engine    string
+	container string
+}
+
+// wait waits for the sandbox to exit, stopping it on a forwarded signal or
+// once timeout elapses, and reports whether the timeout stopped it.
+func (s *runningSandbox) wait(timeout time.Duration) (timedOut bool, err error) {
+	done := make(chan error, 1)
+	go func() { done <- s.cmd.Wait() }()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var deadline, kill <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	// The grace period starts once the first stop is delivered: an engine
+	// stop only returns after its own grace period.
+	var delivered <-chan struct{}
+	stop := func(sig os.Signal) {
+		sent := s.signal(sig)
+		if delivered == nil && kill == nil {
+			delivered = sent
+		}
+	}
+	for {
+		select {
+		case err := <-done:
+			return timedOut, err
+		case sig := <-signals:
+			// The terminal has already sent its SIGINT to the whole
+			// foreground group, the sandbox included.
+			if s.group || sig != os.Interrupt {
+				stop(sig)
+			}
+		case <-deadline:
+			timedOut = true
+			stop(syscall.SIGTERM)
+		case <-delivered:
+			delivered = nil
+			kill = time.After(stopGrace)
+		case <-kill:
+			s.kill()
+		}
+	}
+}
+
+// signal sends sig to the sandbox: to its container through the engine, where
+// SIGTERM is an engine stop, or else to its process group or process. The
+// returned channel is closed once the signal has been delivered.
+func (s *runningSandbox) signal(sig os.Signal) <-chan struct{} {
+	sent := make(chan struct{})
+	if s.container == "" {
+		signalProcess(s.cmd, s.group, sig)
+		close(sent)
+		return sent
+	}
+	args := []string{"kill", "--signal", signalNumber(sig), s.container}
+	if sig == syscall.SIGTERM {
+		args = []string{"stop", "--time", strconv.Itoa(int(stopGrace.Seconds())), s.container}
+	}
+	go func() {
+		defer close(sent)
+		// A container that is not running yet is stopped with its client.
+		if exec.Command(s.engine, args...).Run() != nil {
+			signalProcess(s.cmd, s.group, sig)
+		}
+	}()
+	return sent
+}
+
+// kill ends a sandbox that outlived its grace period.
+func (s *runningSandbox) kill() {
+	go func() {
+		if s.container != "" {
+			_ = exec.Command(s.engine, "kill", s.container).Run()
+		}
+		signalProcess(s.cmd, s.group, os.Kill)
+	}()
+}
+
+// signalNumber formats sig for "<engine> kill --signal".
+func signalNumber(sig os.Signal) string {
+	if number, ok := sig.(syscall.Signal); ok {
+		return strconv.Itoa(int(number))
+	}
+	return "KILL"
+}
+
+// recordUsage fills usage from a finished process and, when the process ran in
+// a cgroup directory, the cgroup's peaks. The cgroup is read before the caller
+// removes it.
+func recordUsage(usage *Usage, state *os.ProcessState, wall time.Duration, cgroupDir string) {
+	usage.ExitCode = state.ExitCode()
+	usage.WallTime = wall
+	usage.UserCPU = state.UserTime()
+	usage.SystemCPU = state.SystemTime()
+	usage.MaxRSSBytes = maxRSS(state)
+	if cgroupDir != "" {
+		usage.PeakMemoryBytes, usage.PeakPids = cgroupPeaks(cgroupDir)
+	}
+}
+
 // buildShellCommand builds a shell command string from an array of arguments.
 func buildShellCommand(args []string) string {
 	quoted := make([]string, len(args))
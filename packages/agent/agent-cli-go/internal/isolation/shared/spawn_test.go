@@ -1,13 +1,29 @@
 package shared
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
+// spawnFrom runs the test's sandboxes as a run from a terminal, or without one,
+// would, with a short grace period.
+func spawnFrom(t *testing.T, terminal bool) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the sandboxes are shell scripts")
+	}
+	savedTerminal, savedGrace := stdinIsTerminal, stopGrace
+	stdinIsTerminal = func() bool { return terminal }
+	stopGrace = 200 * time.Millisecond
+	t.Cleanup(func() { stdinIsTerminal, stopGrace = savedTerminal, savedGrace })
+}
+
 func TestSpawnSandboxInDirUsesDirectoryAndEnvironment(t *testing.T) {
 	workDir := t.TempDir()
 	outputPath := filepath.Join(t.TempDir(), "result")
@@ -35,6 +51,35 @@ func TestSpawnSandboxReturnsChildExitCode(t *testing.T) {
 	}
 }
 
+func TestSpawnSandboxWithOptionsInheritsDescriptors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter")
+	if err := os.WriteFile(path, []byte("inherited"), 0o600); err != nil {
+		t.Fatalf("write inherited file: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open inherited file: %v", err)
+	}
+	defer file.Close()
+
+	exitCode, err := SpawnSandboxWithOptions("sh", []string{"-c", `test "$(cat <&3)" = inherited`}, os.Environ(), SpawnOptions{Files: []*os.File{file}}, "spawn test", false)
+
+	if err != nil || exitCode != 0 {
+		t.Fatalf("SpawnSandboxWithOptions() exitCode = %d, error = %v, want fd 3 readable", exitCode, err)
+	}
+}
+
+// A systemd scope is a command prefix; env stands in for systemd-run here.
+func TestSpawnSandboxWithOptionsRunsUnderTheScopePrefix(t *testing.T) {
+	opts := SpawnOptions{Cgroup: Cgroup{Scope: []string{"env", "SCOPED=1"}}}
+
+	exitCode, err := SpawnSandboxWithOptions("sh", []string{"-c", `test "$SCOPED" = 1`}, os.Environ(), opts, "spawn test", false)
+
+	if err != nil || exitCode != 0 {
+		t.Fatalf("SpawnSandboxWithOptions() exitCode = %d, error = %v, want the command run through the prefix", exitCode, err)
+	}
+}
+
 func TestSpawnSandboxReportsStartFailure(t *testing.T) {
 	exitCode, err := SpawnSandbox("/missing/xonovex-command", nil, os.Environ(), "start sandbox", true)
 
@@ -43,6 +88,80 @@ func TestSpawnSandboxReportsStartFailure(t *testing.T) {
 	}
 }
 
+func TestSpawnSandboxStopsTheProcessGroupAtTheTimeout(t *testing.T) {
+	spawnFrom(t, false)
+	marker := filepath.Join(t.TempDir(), "survived")
+	// The shell ignores SIGTERM, so only the kill after the grace period ends
+	// it; its background child must go with the group.
+	script := `trap '' TERM; (sleep 1; touch "$1") & sleep 5`
+	start := time.Now()
+
+	exitCode, err := SpawnSandboxWithOptions("sh", []string{"-c", script, "sh", marker}, os.Environ(), SpawnOptions{Timeout: 100 * time.Millisecond}, "spawn test", false)
+
+	if exitCode != TimeoutExitCode || !errors.Is(err, ErrTimedOut) {
+		t.Fatalf("SpawnSandboxWithOptions() = %d, %v, want %d, %v", exitCode, err, TimeoutExitCode, ErrTimedOut)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("the sandbox ran %s, want it killed after the grace period", elapsed)
+	}
+	time.Sleep(1200 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("a process of the sandbox's group outlived the kill")
+	}
+}
+
+func TestSpawnSandboxKillsAContainerOnlyOnceItsStopReturns(t *testing.T) {
+	spawnFrom(t, false)
+	dir := t.TempDir()
+	log := filepath.Join(dir, "engine.log")
+	// The engine's stop outlasts the grace period, as "docker stop --time"
+	// does while it waits out its own.
+	engine := filepath.Join(dir, "engine")
+	script := "#!/bin/sh\necho \"$1\" >> " + log + "\nif [ \"$1\" = stop ]; then sleep 0.4; echo stopped >> " + log + "; fi\n"
+	if err := os.WriteFile(engine, []byte(script), 0o755); err != nil {
+		t.Fatalf("write engine: %v", err)
+	}
+	opts := SpawnOptions{Timeout: 100 * time.Millisecond, Engine: engine, Container: "agent-cli-test"}
+
+	exitCode, err := SpawnSandboxWithOptions("sh", []string{"-c", "trap '' TERM; sleep 5"}, os.Environ(), opts, "spawn test", false)
+
+	if exitCode != TimeoutExitCode || !errors.Is(err, ErrTimedOut) {
+		t.Fatalf("SpawnSandboxWithOptions() = %d, %v, want %d, %v", exitCode, err, TimeoutExitCode, ErrTimedOut)
+	}
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("read engine log: %v", err)
+	}
+	if got := strings.Fields(string(calls)); !slices.Equal(got, []string{"stop", "stopped", "kill"}) {
+		t.Errorf("engine calls = %v, want the kill after the stop returned", got)
+	}
+}
+
+func TestSpawnSandboxForwardsSignalsToTheSandbox(t *testing.T) {
+	spawnFrom(t, false)
+	// The sandbox signals agent-cli itself, once agent-cli is waiting on it.
+	script := `trap 'exit 3' TERM; sleep 0.2; kill -TERM $PPID; sleep 5 & wait`
+
+	exitCode, err := SpawnSandboxWithOptions("sh", []string{"-c", script}, os.Environ(), SpawnOptions{}, "spawn test", false)
+
+	if err != nil || exitCode != 3 {
+		t.Fatalf("SpawnSandboxWithOptions() = %d, %v, want the sandbox's SIGTERM handler to exit 3", exitCode, err)
+	}
+}
+
+func TestSpawnSandboxLeavesTheTerminalInterruptToTheAgent(t *testing.T) {
+	spawnFrom(t, true)
+	// From a terminal, SIGINT has reached the agent already; agent-cli must
+	// neither forward it nor stop the agent, which may only cancel a turn.
+	script := `trap 'exit 3' INT; sleep 0.2; kill -INT $PPID; sleep 0.5; exit 5`
+
+	exitCode, err := SpawnSandboxWithOptions("sh", []string{"-c", script}, os.Environ(), SpawnOptions{}, "spawn test", false)
+
+	if err != nil || exitCode != 5 {
+		t.Fatalf("SpawnSandboxWithOptions() = %d, %v, want the agent left running to exit 5", exitCode, err)
+	}
+}
+
 func TestWrapWithInitCommands(t *testing.T) {
 	command := []string{"agent", "argument with spaces"}
 
@@ -57,3 +176,19 @@ func TestWrapWithInitCommands(t *testing.T) {
 		t.Fatalf("WrapWithInitCommands() = %v, want %v", wrapped, want)
 	}
 }
+
+func TestSpawnSandboxWithOptionsRecordsUsage(t *testing.T) {
+	var usage Usage
+
+	exitCode, err := SpawnSandboxWithOptions("sh", []string{"-c", "exit 3"}, os.Environ(), SpawnOptions{Usage: &usage}, "spawn test", false)
+
+	if err != nil || exitCode != 3 {
+		t.Fatalf("SpawnSandboxWithOptions() exitCode = %d, error = %v, want 3, nil", exitCode, err)
+	}
+	if usage.ExitCode != 3 || usage.WallTime <= 0 {
+		t.Errorf("usage = %+v, want exit code 3 and a wall time", usage)
+	}
+	if runtime.GOOS == "linux" && usage.MaxRSSBytes <= 0 {
+		t.Errorf("usage.MaxRSSBytes = %d, want the child's RSS", usage.MaxRSSBytes)
+	}
+}
@@ -0,0 +1,67 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Usage is what one sandbox run consumed. A field the isolator cannot measure
+// stays zero: the container engines report no CPU time or RSS, because the
+// engine client's own usage says nothing about the container, and the peaks
+// need a cgroup (Cgroup.Dir) or engine statistics.
+type Usage struct {
+	// ExitCode is the agent's exit code.
+	ExitCode int
+	// SetupFailed reports that the isolator exited before the agent ran, so
+	// ExitCode is the isolator's (bwrap only).
+	SetupFailed bool
+	// WallTime runs from spawning the isolator until it exits.
+	WallTime time.Duration
+	// UserCPU and SystemCPU are the CPU time of the sandbox process and the
+	// descendants it waited for.
+	UserCPU   time.Duration
+	SystemCPU time.Duration
+	// MaxRSSBytes is the largest resident set of any one of those processes.
+	MaxRSSBytes int64
+	// PeakMemoryBytes and PeakPids are the whole process tree's peaks.
+	PeakMemoryBytes int64
+	PeakPids        int64
+}
+
+// String renders the measured fields as a one-line summary.
+func (u Usage) String() string {
+	parts := []string{fmt.Sprintf("exit %d", u.ExitCode)}
+	if u.SetupFailed {
+		parts[0] += " (sandbox setup failed)"
+	}
+	parts = append(parts, "wall "+u.WallTime.Round(10*time.Millisecond).String())
+	if u.UserCPU > 0 || u.SystemCPU > 0 {
+		parts = append(parts, fmt.Sprintf("cpu %s user + %s system",
+			u.UserCPU.Round(10*time.Millisecond), u.SystemCPU.Round(10*time.Millisecond)))
+	}
+	if u.MaxRSSBytes > 0 {
+		parts = append(parts, "max RSS "+humanBytes(u.MaxRSSBytes))
+	}
+	if u.PeakMemoryBytes > 0 {
+		parts = append(parts, "peak memory "+humanBytes(u.PeakMemoryBytes))
+	}
+	if u.PeakPids > 0 {
+		parts = append(parts, fmt.Sprintf("peak pids %d", u.PeakPids))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// humanBytes renders bytes in binary units with one decimal, e.g. "1.5GiB".
+func humanBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 3 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, "KMGT"[exponent])
+}
@@ -0,0 +1,17 @@
+//go:build darwin
+
+package shared
+
+import (
+	"os"
+	"syscall"
+)
+
+// maxRSS returns the largest resident set of the process and the children it
+// waited for; macOS reports ru_maxrss in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return rusage.Maxrss
+	}
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package shared
+
+import (
+	"os"
+	"syscall"
+)
+
+// maxRSS returns the largest resident set of the process and the children it
+// waited for; Linux reports ru_maxrss in KiB.
+func maxRSS(state *os.ProcessState) int64 {
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return rusage.Maxrss * 1024
+	}
+	return 0
+}
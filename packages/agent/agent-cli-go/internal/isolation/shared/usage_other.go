@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package shared
+
+import "os"
+
+// maxRSS reports nothing where the rusage layout is not known.
+func maxRSS(*os.ProcessState) int64 {
+	return 0
+}
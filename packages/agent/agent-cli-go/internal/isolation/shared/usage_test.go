@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsageStringOmitsWhatWasNotMeasured(t *testing.T) {
+	tests := []struct {
+		usage Usage
+		want  string
+	}{
+		{Usage{ExitCode: 2, WallTime: 1500 * time.Millisecond}, "exit 2, wall 1.5s"},
+		{
+			Usage{WallTime: time.Minute, UserCPU: 2 * time.Second, SystemCPU: 250 * time.Millisecond, MaxRSSBytes: 3 << 29, PeakMemoryBytes: 2 << 30, PeakPids: 12},
+			"exit 0, wall 1m0s, cpu 2s user + 250ms system, max RSS 1.5GiB, peak memory 2.0GiB, peak pids 12",
+		},
+		{Usage{ExitCode: 1, SetupFailed: true}, "exit 1 (sandbox setup failed), wall 0s"},
+	}
+	for _, tt := range tests {
+		if got := tt.usage.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	for bytes, want := range map[int64]string{512: "512B", 1536: "1.5KiB", 5 << 20: "5.0MiB", 3 << 40: "3.0TiB"} {
+		if got := humanBytes(bytes); got != want {
+			t.Errorf("humanBytes(%d) = %q, want %q", bytes, got, want)
+		}
+	}
+}
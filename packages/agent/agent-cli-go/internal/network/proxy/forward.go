@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
+)
+
+// Forwarder runs on the host: it serves a unix socket the isolator binds into
+// the sandbox and forwards every connection to one host loopback endpoint. It is
+// the host half of a loopback forward, the mirror of Relay.
+type Forwarder struct {
+	addr     string
+	listener net.Listener
+}
+
+// ListenForward listens on the unix socket and forwards connections to addr in
+// the background. The socket is restricted to the current user.
+func ListenForward(socket, addr string) (*Forwarder, error) {
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("listen on forward socket %q: %w", socket, err)
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("restrict forward socket %q: %w", socket, err)
+	}
+	f := &Forwarder{addr: addr, listener: listener}
+	go f.serve(listener)
+	return f, nil
+}
+
+// Close stops the forwarder.
+func (f *Forwarder) Close() error { return f.listener.Close() }
+
+func (f *Forwarder) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go f.forward(conn)
+	}
+}
+
+func (f *Forwarder) forward(client net.Conn) {
+	defer client.Close()
+	upstream, err := net.Dial("tcp", f.addr)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	egress.Pipe(client, upstream)
+}
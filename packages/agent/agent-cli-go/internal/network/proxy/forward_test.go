@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestForwarder_RelaysLoopbackEndpointThroughSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "forward.sock")
+	forwarder, err := ListenForward(socket, echoUpstream(t))
+	if err != nil {
+		t.Fatalf("ListenForward() error = %v", err)
+	}
+	defer forwarder.Close()
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("stat forward socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("forward socket mode = %o, want 600", perm)
+	}
+
+	relay, err := ListenRelay("127.0.0.1:0", socket)
+	if err != nil {
+		t.Fatalf("ListenRelay() error = %v", err)
+	}
+	defer relay.Close()
+	conn, err := net.Dial("tcp", relay.Addr().String())
+	if err != nil {
+		t.Fatalf("dial relay: %v", err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatalf("write through forward: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("forward echo = (%q, %v), want ping", line, err)
+	}
+}
+
+func TestListenForward_RejectsUnusableSocket(t *testing.T) {
+	if _, err := ListenForward(filepath.Join(t.TempDir(), "missing", "forward.sock"), "127.0.0.1:1"); err == nil {
+		t.Error("ListenForward(missing dir) error = nil, want listen error")
+	}
+}
@@ -0,0 +1,66 @@
+// Package proxy carries TCP across the sandbox's network boundary over unix
+// sockets: the in-sandbox Relay republishes a bound socket on a sandbox
+// loopback, and the host-side Forwarder serves such a socket for a host loopback
+// endpoint. The allowlist proxy itself is the shared egress package; the
+// isolators never import this package, they bind the sockets and wrap the agent
+// through the network/shared transport contract, and the composition root owns
+// the host side's lifetime.
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
+)
+
+// Relay runs inside the sandbox: it listens on a loopback TCP address and
+// forwards every connection to a bound socket: the egress proxy's or a loopback
+// forward's. The sandbox has no other network route, so the relay carries
+// nothing the host side does not allow.
+type Relay struct {
+	socket   string
+	listener net.Listener
+}
+
+// ListenRelay listens on addr and relays connections to the unix socket in the
+// background.
+func ListenRelay(addr, socket string) (*Relay, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen on relay address %q: %w", addr, err)
+	}
+	r := &Relay{socket: socket, listener: listener}
+	go r.serve(listener)
+	return r, nil
+}
+
+// Addr returns the address the relay listens on.
+func (r *Relay) Addr() net.Addr { return r.listener.Addr() }
+
+// Close stops the relay.
+func (r *Relay) Close() error { return r.listener.Close() }
+
+func (r *Relay) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go r.forward(conn)
+	}
+}
+
+func (r *Relay) forward(client net.Conn) {
+	defer client.Close()
+	upstream, err := net.Dial("unix", r.socket)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	egress.Pipe(client, upstream)
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
+)
+
+// echoUpstream listens on loopback and echoes every connection, standing in for
+// an allowlisted API host.
+func echoUpstream(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// startProxy serves an allowlist proxy on a unix socket whose dialer sends
+// every allowed destination to upstream, and returns the socket path.
+func startProxy(t *testing.T, entries []string, upstream string) string {
+	t.Helper()
+	allow, err := egress.NewAllowlist(entries)
+	if err != nil {
+		t.Fatalf("NewAllowlist() error = %v", err)
+	}
+	var dialer net.Dialer
+	server := egress.NewServer(allow).WithDialer(func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, upstream)
+	})
+	socket := filepath.Join(t.TempDir(), "egress.sock")
+	if err := server.ListenUnix(socket); err != nil {
+		t.Fatalf("ListenUnix() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return socket
+}
+
+// connect issues a CONNECT through conn and returns the response status.
+func connect(t *testing.T, conn net.Conn, target string) (*bufio.Reader, int) {
+	t.Helper()
+	if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target); err != nil {
+		t.Fatalf("write CONNECT: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("read CONNECT response: %v", err)
+	}
+	return reader, resp.StatusCode
+}
+
+func TestRelay_TunnelsAllowedHostToProxySocket(t *testing.T) {
+	socket := startProxy(t, []string{"api.anthropic.com"}, echoUpstream(t))
+	relay, err := ListenRelay("127.0.0.1:0", socket)
+	if err != nil {
+		t.Fatalf("ListenRelay() error = %v", err)
+	}
+	defer relay.Close()
+
+	conn, err := net.Dial("tcp", relay.Addr().String())
+	if err != nil {
+		t.Fatalf("dial relay: %v", err)
+	}
+	defer conn.Close()
+	reader, status := connect(t, conn, "api.anthropic.com:443")
+	if status != http.StatusOK {
+		t.Fatalf("CONNECT status = %d, want 200", status)
+	}
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatalf("write through tunnel: %v", err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("tunnel echo = (%q, %v), want ping", line, err)
+	}
+}
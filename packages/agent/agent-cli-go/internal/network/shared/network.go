@@ -1,17 +1,40 @@
-// Package shared is the network axis core. Network is a closed enum with host
-// and none realized here and proxy reserved until an enforceable transport is
-// available. The per-isolator network flags live in isolation bridge files,
-// which depend on this package one-way only.
+// Package shared is the network axis core. Network is a closed enum: host and
+// none are realized by the isolator flags alone, and proxy by an allowlist proxy
+// the composition root runs on a host unix socket. Under none and proxy, host
+// loopback endpoints (local model proxies) are forwarded in over unix sockets by
+// the same in-sandbox relay. The per-isolator network flags live in isolation
+// bridge files, which depend on this package one-way only.
 package shared
 
 import (
+	"debug/elf"
 	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
 
 	netenum "github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/network"
 )
 
+// ErrProxyEnforcementUnavailable reports a proxy-mode run without a proxy
+// transport to bind into the sandbox, or under an isolator that cannot remove
+// the direct route out.
 var ErrProxyEnforcementUnavailable = errors.New("network=proxy has no enforceable transport backend")
 
+// ErrRelayUnavailable reports loopback forwards without a relay binary to run
+// them inside the sandbox.
+var ErrRelayUnavailable = errors.New("loopback forwarding has no in-sandbox relay")
+
+// ErrRelayNotStatic reports a relay binary that needs a dynamic loader, which
+// a container or minimal sandbox root may not have.
+var ErrRelayNotStatic = errors.New("the in-sandbox relay must be a statically linked executable (build with CGO_ENABLED=0)")
+
+// ErrProxyAllowlistEmpty reports a proxy-mode run with no allowed host; an empty
+// allowlist denies everything, which network=none already expresses.
+var ErrProxyAllowlistEmpty = errors.New("network=proxy requires at least one allowed host")
+
 // Mode is the network-egress selection. It aliases the shared closed enum so the
 // CLI names the axis locally without redefining the variant set (one owner: the
 // shared pkg/network package).
@@ -23,24 +46,171 @@ const (
 	ModeProxy = netenum.NetworkProxy
 )
 
+// In-sandbox locations of the relay transport. The sandbox network is
+// unshared, so the proxy and forward sockets and the relay binary are bound in
+// at fixed paths and the relay republishes each socket on the sandbox loopback,
+// where the proxy environment and the forwarded endpoints point.
+const (
+	SandboxProxySocket = "/run/agent-cli/egress.sock"
+	SandboxRelayBinary = "/run/agent-cli/relay"
+	SandboxProxyAddr   = "127.0.0.1:3128"
+	// RelayCommandName is the hidden agent-cli subcommand the relay binary runs.
+	RelayCommandName = "egress-relay"
+
+	sandboxForwardSocket = "/run/agent-cli/forward-%d.sock"
+)
+
+// LoopbackForward publishes one host loopback TCP endpoint inside the sandbox
+// at the same address.
+type LoopbackForward struct {
+	// Addr is the loopback host:port, identical on the host and in the sandbox.
+	Addr string
+	// Socket is the host path of the unix socket the host forwarder serves.
+	Socket string
+}
+
+// RelayTransport locates the host side of the in-sandbox relay for one run:
+// the allowlist proxy socket (network=proxy only) and the loopback forwards.
+type RelayTransport struct {
+	// ProxySocket is the host path of the allowlist proxy's unix socket.
+	ProxySocket string
+	// RelayBinary is the host path of the agent-cli executable that runs as the
+	// in-sandbox relay; CheckRelayBinary verifies it is statically linked.
+	RelayBinary string
+	// Forwards are the host loopback endpoints published in the sandbox.
+	Forwards []LoopbackForward
+}
+
+// CheckRelayBinary verifies that path, the relay binary, runs without a
+// dynamic loader: an ELF executable must not request a program interpreter. A
+// file that is not ELF is left to the isolator that binds it.
+func CheckRelayBinary(path string) error {
+	file, err := elf.Open(path)
+	if err != nil {
+		var format *elf.FormatError
+		if errors.As(err, &format) {
+			return nil
+		}
+		return fmt.Errorf("inspect relay binary: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	for _, prog := range file.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return fmt.Errorf("%w: %s is dynamically linked", ErrRelayNotStatic, path)
+		}
+	}
+	return nil
+}
+
+// Bind is a host path bound read-only into the sandbox.
+type Bind struct {
+	Host    string
+	Sandbox string
+}
+
+// RelayBinds validates the transport for mode m and returns what the isolator
+// must bind in: the relay binary plus each socket it republishes. Host mode
+// shares the host loopback, so it needs no relay and returns nil; proxy mode
+// fails closed without its proxy socket.
+func RelayBinds(m Mode, t RelayTransport) ([]Bind, error) {
+	if m == ModeHost {
+		return nil, nil
+	}
+	if m == ModeProxy && (t.ProxySocket == "" || t.RelayBinary == "") {
+		return nil, ErrProxyEnforcementUnavailable
+	}
+	if len(t.Forwards) > 0 && t.RelayBinary == "" {
+		return nil, ErrRelayUnavailable
+	}
+	if m != ModeProxy && len(t.Forwards) == 0 {
+		return nil, nil
+	}
+	binds := []Bind{{Host: t.RelayBinary, Sandbox: SandboxRelayBinary}}
+	if m == ModeProxy {
+		binds = append(binds, Bind{Host: t.ProxySocket, Sandbox: SandboxProxySocket})
+	}
+	for index, forward := range t.Forwards {
+		binds = append(binds, Bind{Host: forward.Socket, Sandbox: fmt.Sprintf(sandboxForwardSocket, index)})
+	}
+	return binds, nil
+}
+
+// ParseLoopbackEndpoint validates a host loopback endpoint, given as a port or
+// an IPv4/IPv6 loopback host:port, and returns it as host:port. Names such as
+// localhost are refused: the relay binds an address, not a resolver answer.
+func ParseLoopbackEndpoint(endpoint string) (string, error) {
+	host, port := "127.0.0.1", endpoint
+	if strings.Contains(endpoint, ":") {
+		var err error
+		if host, port, err = net.SplitHostPort(endpoint); err != nil {
+			return "", fmt.Errorf("loopback endpoint %q: %w", endpoint, err)
+		}
+	}
+	address, err := netip.ParseAddr(host)
+	if err != nil || !address.IsLoopback() {
+		return "", fmt.Errorf("loopback endpoint %q must be a loopback IP address", endpoint)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("loopback endpoint %q has an invalid port", endpoint)
+	}
+	return net.JoinHostPort(address.String(), port), nil
+}
+
 // EgressIsRestricted reports whether the mode enforceably restricts egress.
 func EgressIsRestricted(m Mode) bool { return netenum.EgressIsRestricted(m) }
 
 // ParseMode validates s and returns the corresponding Mode.
 func ParseMode(s string) (Mode, error) {
 	switch Mode(s) {
-	case ModeHost, ModeNone:
+	case ModeHost, ModeNone, ModeProxy:
 		return Mode(s), nil
-	case ModeProxy:
-		return "", ErrProxyEnforcementUnavailable
 	default:
 		return "", &InvalidModeError{Value: s}
 	}
 }
 
+// ProxyEnv returns the environment that points HTTP clients inside the sandbox at
+// the relayed proxy. Loopback stays direct so local tools and forwarded
+// endpoints keep working.
+func ProxyEnv() map[string]string {
+	proxyURL := "http://" + SandboxProxyAddr
+	return map[string]string{
+		"HTTPS_PROXY": proxyURL,
+		"HTTP_PROXY":  proxyURL,
+		"https_proxy": proxyURL,
+		"http_proxy":  proxyURL,
+		"NO_PROXY":    "localhost,127.0.0.1",
+		"no_proxy":    "localhost,127.0.0.1",
+	}
+}
+
+// RelayCommand wraps command with the in-sandbox relay when mode m needs one:
+// the relay listens on each route's loopback address, forwards connections to
+// the route's bound socket, and runs command as its child until it exits.
+// Otherwise command is returned unchanged.
+func RelayCommand(m Mode, t RelayTransport, command []string) []string {
+	var routes []string
+	if m == ModeProxy {
+		routes = append(routes, SandboxProxyAddr+"="+SandboxProxySocket)
+	}
+	if m != ModeHost {
+		for index, forward := range t.Forwards {
+			routes = append(routes, forward.Addr+"="+fmt.Sprintf(sandboxForwardSocket, index))
+		}
+	}
+	if len(routes) == 0 {
+		return command
+	}
+	relay := []string{SandboxRelayBinary, RelayCommandName}
+	for _, route := range routes {
+		relay = append(relay, "--forward", route)
+	}
+	return append(append(relay, "--"), command...)
+}
+
 // InvalidModeError reports an unrecognised network mode string.
 type InvalidModeError struct{ Value string }
 
 func (e *InvalidModeError) Error() string {
-	return "unknown network mode " + e.Value + "; valid: host, none"
+	return "unknown network mode " + e.Value + "; valid: host, none, proxy"
 }
@@ -1,29 +1,152 @@
 package shared
 
 import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
 	"errors"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
 func TestParseMode(t *testing.T) {
-	for _, s := range []string{"host", "none"} {
+	for _, s := range []string{"host", "none", "proxy"} {
 		if m, err := ParseMode(s); err != nil || string(m) != s {
 			t.Errorf("ParseMode(%q) = (%q, %v), want (%q, nil)", s, m, err, s)
 		}
 	}
-	if _, err := ParseMode("proxy"); !errors.Is(err, ErrProxyEnforcementUnavailable) {
-		t.Errorf("ParseMode(proxy) error = %v, want %v", err, ErrProxyEnforcementUnavailable)
-	}
 	if _, err := ParseMode("firewall"); err == nil {
 		t.Error("ParseMode(firewall) = nil, want error")
 	}
 }
 
 func TestEgressIsRestricted(t *testing.T) {
-	cases := map[Mode]bool{ModeHost: false, ModeNone: true, ModeProxy: false}
+	cases := map[Mode]bool{ModeHost: false, ModeNone: true, ModeProxy: true}
 	for m, want := range cases {
 		if got := EgressIsRestricted(m); got != want {
 			t.Errorf("EgressIsRestricted(%q) = %t, want %t", m, got, want)
 		}
 	}
 }
+
+func TestProxyEnvPointsAtRelay(t *testing.T) {
+	env := ProxyEnv()
+	for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "https_proxy", "http_proxy"} {
+		if env[key] != "http://"+SandboxProxyAddr {
+			t.Errorf("%s = %q, want http://%s", key, env[key], SandboxProxyAddr)
+		}
+	}
+}
+
+func TestRelayCommandWrapsAgent(t *testing.T) {
+	transport := RelayTransport{
+		ProxySocket: "/tmp/egress.sock",
+		RelayBinary: "/usr/local/bin/agent-cli",
+		Forwards:    []LoopbackForward{{Addr: "127.0.0.1:8317", Socket: "/tmp/forward-0.sock"}},
+	}
+	got := RelayCommand(ModeProxy, transport, []string{"claude", "--print"})
+	want := []string{
+		SandboxRelayBinary, RelayCommandName,
+		"--forward", SandboxProxyAddr + "=" + SandboxProxySocket,
+		"--forward", "127.0.0.1:8317=/run/agent-cli/forward-0.sock",
+		"--", "claude", "--print",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("RelayCommand(proxy) = %v, want %v", got, want)
+	}
+	command := []string{"claude"}
+	if got := RelayCommand(ModeNone, RelayTransport{}, command); !slices.Equal(got, command) {
+		t.Errorf("RelayCommand(none, no forwards) = %v, want the bare command", got)
+	}
+	if got := RelayCommand(ModeHost, transport, command); !slices.Equal(got, command) {
+		t.Errorf("RelayCommand(host) = %v, want the bare command", got)
+	}
+}
+
+func TestRelayBinds(t *testing.T) {
+	forward := LoopbackForward{Addr: "127.0.0.1:8317", Socket: "/tmp/forward-0.sock"}
+	binds, err := RelayBinds(ModeNone, RelayTransport{RelayBinary: "/bin/agent-cli", Forwards: []LoopbackForward{forward}})
+	want := []Bind{{Host: "/bin/agent-cli", Sandbox: SandboxRelayBinary}, {Host: forward.Socket, Sandbox: "/run/agent-cli/forward-0.sock"}}
+	if err != nil || !slices.Equal(binds, want) {
+		t.Errorf("RelayBinds(none, forward) = (%v, %v), want (%v, nil)", binds, err, want)
+	}
+	if binds, err := RelayBinds(ModeNone, RelayTransport{}); err != nil || binds != nil {
+		t.Errorf("RelayBinds(none) = (%v, %v), want (nil, nil)", binds, err)
+	}
+	if binds, err := RelayBinds(ModeHost, RelayTransport{Forwards: []LoopbackForward{forward}}); err != nil || binds != nil {
+		t.Errorf("RelayBinds(host) = (%v, %v), want (nil, nil)", binds, err)
+	}
+	if _, err := RelayBinds(ModeProxy, RelayTransport{RelayBinary: "/bin/agent-cli"}); !errors.Is(err, ErrProxyEnforcementUnavailable) {
+		t.Errorf("RelayBinds(proxy, no socket) error = %v, want %v", err, ErrProxyEnforcementUnavailable)
+	}
+	if _, err := RelayBinds(ModeNone, RelayTransport{Forwards: []LoopbackForward{forward}}); !errors.Is(err, ErrRelayUnavailable) {
+		t.Errorf("RelayBinds(none, no relay) error = %v, want %v", err, ErrRelayUnavailable)
+	}
+}
+
+func TestParseLoopbackEndpoint(t *testing.T) {
+	valid := map[string]string{
+		"8317":           "127.0.0.1:8317",
+		"127.0.0.1:8317": "127.0.0.1:8317",
+		"127.0.0.2:80":   "127.0.0.2:80",
+		"[::1]:8317":     "[::1]:8317",
+	}
+	for endpoint, want := range valid {
+		if got, err := ParseLoopbackEndpoint(endpoint); err != nil || got != want {
+			t.Errorf("ParseLoopbackEndpoint(%q) = (%q, %v), want (%q, nil)", endpoint, got, err, want)
+		}
+	}
+	for _, endpoint := range []string{"localhost:8317", "10.0.0.1:8317", "0", "70000", "127.0.0.1:http", "127.0.0.1"} {
+		if _, err := ParseLoopbackEndpoint(endpoint); err == nil {
+			t.Errorf("ParseLoopbackEndpoint(%q) error = nil, want error", endpoint)
+		}
+	}
+}
+
+// writeELF writes a minimal 64-bit ELF executable with one program header of
+// type prog.
+func writeELF(t *testing.T, prog elf.ProgType) string {
+	t.Helper()
+	header := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     1,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	var data bytes.Buffer
+	_ = binary.Write(&data, binary.LittleEndian, header)
+	_ = binary.Write(&data, binary.LittleEndian, elf.Prog64{Type: uint32(prog)})
+	path := filepath.Join(t.TempDir(), "agent-cli")
+	if err := os.WriteFile(path, data.Bytes(), 0o755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestCheckRelayBinaryRefusesADynamicExecutable(t *testing.T) {
+	if err := CheckRelayBinary(writeELF(t, elf.PT_LOAD)); err != nil {
+		t.Errorf("CheckRelayBinary(static) error = %v, want nil", err)
+	}
+	if err := CheckRelayBinary(writeELF(t, elf.PT_INTERP)); !errors.Is(err, ErrRelayNotStatic) {
+		t.Errorf("CheckRelayBinary(dynamic) error = %v, want %v", err, ErrRelayNotStatic)
+	}
+	script := filepath.Join(t.TempDir(), "relay.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := CheckRelayBinary(script); err != nil {
+		t.Errorf("CheckRelayBinary(script) error = %v, want a file that is not ELF left alone", err)
+	}
+	if err := CheckRelayBinary(filepath.Join(t.TempDir(), "missing")); err == nil || errors.Is(err, ErrRelayNotStatic) {
+		t.Errorf("CheckRelayBinary(missing) error = %v, want an inspection error", err)
+	}
+}
@@ -13,6 +13,9 @@ type Provisioner struct{}
 // New creates the command (init-command list) provisioner.
 func New() *Provisioner { return &Provisioner{} }
 
+// Available reports true: the init commands run in the sandbox.
+func (Provisioner) Available() (bool, error) { return true, nil }
+
 // Contribute returns a Contribution carrying the init commands.
 func (Provisioner) Contribute(in provshared.Input) (provision.Contribution, error) {
 	return provision.Contribution{InitCommands: in.InitCommands}, nil
@@ -20,3 +23,6 @@ func (Provisioner) Contribute(in provshared.Input) (provision.Contribution, erro
 
 // Pinned reports false: an init-command list is not a pinned source.
 func (Provisioner) Pinned() bool { return false }
+
+// Cost reports the init commands that run before the agent.
+func (Provisioner) Cost() int { return 1 }
@@ -8,6 +8,12 @@ import (
 
 func TestCommand_ContributesInitCommandsUnpinned(t *testing.T) {
 	p := New()
+	if ok, err := p.Available(); !ok || err != nil {
+		t.Errorf("Available() = (%v, %v), want (true, nil)", ok, err)
+	}
+	if p.Cost() != 1 {
+		t.Errorf("Cost() = %d, want 1", p.Cost())
+	}
 	if p.Pinned() {
 		t.Error("command provisioner must report Pinned()=false")
 	}
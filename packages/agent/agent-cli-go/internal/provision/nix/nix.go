@@ -8,6 +8,7 @@ package nix
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 
 	provshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/provision/shared"
@@ -78,9 +79,18 @@ func New() *Provisioner {
 	return &Provisioner{resolve: ResolveClosure, root: registerGCRoot}
 }
 
+// Available reports whether the host nix CLI is on PATH.
+func (p *Provisioner) Available() (bool, error) {
+	_, err := exec.LookPath("nix")
+	return err == nil, nil
+}
+
 // Pinned reports true: a nix closure resolves from a flake.lock/rev-pinned source.
 func (p *Provisioner) Pinned() bool { return true }
 
+// Cost reports a closure resolve and GC-root registration on every run.
+func (p *Provisioner) Cost() int { return 3 }
+
 // Contribute resolves the closure and returns a mount-only Contribution: the
 // requisites read-only, the closure's PATH entries, and its env. There is no
 // daemon socket and no /nix/store bind — that bind discipline is what makes
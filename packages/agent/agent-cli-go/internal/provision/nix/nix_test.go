@@ -130,6 +130,43 @@ func TestPinned(t *testing.T) {
 	}
 }
 
+func TestAvailableLooksForTheNixCLI(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if ok, err := New().Available(); ok || err != nil {
+		t.Errorf("Available() without nix = (%v, %v), want (false, nil)", ok, err)
+	}
+	installFakeNix(t, "exit 0")
+	if ok, err := New().Available(); !ok || err != nil {
+		t.Errorf("Available() with nix = (%v, %v), want (true, nil)", ok, err)
+	}
+}
+
+func TestCheckStoreAndFlakesWithFakeNix(t *testing.T) {
+	installFakeNix(t, `case "$1 $2" in
+  "store ping") exit 0 ;;
+  "config show") printf 'flakes nix-command\n' ;;
+  *) exit 9 ;;
+esac`)
+	if err := CheckStore(); err != nil {
+		t.Errorf("CheckStore() error = %v", err)
+	}
+	if err := CheckFlakes(); err != nil {
+		t.Errorf("CheckFlakes() error = %v", err)
+	}
+
+	// Nix before 2.20 answers only show-config, here without flakes.
+	installFakeNix(t, `case "$1" in
+  show-config) printf 'cores = 0\nexperimental-features = nix-command\n' ;;
+  *) echo "daemon unreachable" >&2; exit 1 ;;
+esac`)
+	if err := CheckStore(); err == nil || !strings.Contains(err.Error(), "daemon unreachable") {
+		t.Errorf("CheckStore() error = %v, want the nix stderr", err)
+	}
+	if err := CheckFlakes(); err == nil || !strings.Contains(err.Error(), "flakes experimental feature") {
+		t.Errorf("CheckFlakes() error = %v, want flakes named", err)
+	}
+}
+
 func TestSourceFromFlags(t *testing.T) {
 	pkgs, err := SourceFromFlags("packages", testRevision, []string{"ripgrep"}, "", "", "", "")
 	if err != nil || pkgs.Kind != sharednix.NixSourcePackages || pkgs.Rev != testRevision {
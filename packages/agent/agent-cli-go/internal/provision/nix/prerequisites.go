@@ -0,0 +1,61 @@
+package nix
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// requiredFeatures are the experimental features ResolveClosure relies on: the
+// nix CLI itself and flake references.
+var requiredFeatures = []string{"nix-command", "flakes"}
+
+// CheckStore reports whether the host nix CLI reaches its store, through the
+// nix daemon on a multi-user install.
+func CheckStore() error {
+	_, err := runNix("store", "ping")
+	return err
+}
+
+// CheckFlakes reports whether the host nix enables the experimental features
+// ResolveClosure relies on.
+func CheckFlakes() error {
+	out, err := runNix("config", "show", "experimental-features")
+	if err != nil {
+		// Nix before 2.20 has no `nix config show`.
+		if out, err = runNix("show-config"); err != nil {
+			return err
+		}
+	}
+	return missingFeatures(experimentalFeatures(out))
+}
+
+// experimentalFeatures returns the features named by `nix config show
+// experimental-features` (a bare list) or `nix show-config` (key = value lines).
+func experimentalFeatures(out string) []string {
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			if strings.TrimSpace(key) == "experimental-features" {
+				return strings.Fields(value)
+			}
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			return fields
+		}
+	}
+	return nil
+}
+
+func missingFeatures(enabled []string) error {
+	var missing []string
+	for _, feature := range requiredFeatures {
+		if !slices.Contains(enabled, feature) {
+			missing = append(missing, feature)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("nix does not enable the %s experimental feature(s)", strings.Join(missing, " and "))
+	}
+	return nil
+}
@@ -13,6 +13,9 @@ type Provisioner struct{}
 // New creates the no-op provisioner.
 func New() *Provisioner { return &Provisioner{} }
 
+// Available reports true: nothing is needed on the host.
+func (Provisioner) Available() (bool, error) { return true, nil }
+
 // Contribute returns an empty Contribution.
 func (Provisioner) Contribute(provshared.Input) (provision.Contribution, error) {
 	return provision.Contribution{}, nil
@@ -20,3 +23,6 @@ func (Provisioner) Contribute(provshared.Input) (provision.Contribution, error)
 
 // Pinned reports false: no provisioning source.
 func (Provisioner) Pinned() bool { return false }
+
+// Cost reports 0: nothing is resolved.
+func (Provisioner) Cost() int { return 0 }
@@ -8,6 +8,12 @@ import (
 
 func TestNone_ContributesNothingUnpinned(t *testing.T) {
 	p := New()
+	if ok, err := p.Available(); !ok || err != nil {
+		t.Errorf("Available() = (%v, %v), want (true, nil)", ok, err)
+	}
+	if p.Cost() != 0 {
+		t.Errorf("Cost() = %d, want 0", p.Cost())
+	}
 	if p.Pinned() {
 		t.Error("none provisioner must report Pinned()=false")
 	}
@@ -21,8 +21,13 @@ type Input struct {
 // Provisioner produces a Contribution (tools/binds/env/init) that an Isolator
 // applies, and declares its own guarantees so the policy engine never names it.
 type Provisioner interface {
+	// Available reports whether the provisioner's host tooling is present.
+	Available() (bool, error)
 	Contribute(in Input) (provision.Contribution, error)
 	// Pinned reports whether provisioning comes from a pinned source (so
 	// RequirePinnedProvision can be honored).
 	Pinned() bool
+	// Cost is the provisioner's relative per-run overhead, added to the
+	// isolator's when the policy solver ranks cells; 0 adds nothing.
+	Cost() int
 }
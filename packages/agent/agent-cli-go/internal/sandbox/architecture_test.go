@@ -11,7 +11,8 @@ package sandbox
 //   - TestSharedCoresImportNoLeaf — one-way cross-axis glue: an axis shared/ core
 //     imports no leaf of any axis; bridges flow leaf -> other-axis/shared only.
 //   - TestSelectionLayerNamesNoVariant — microkernel capability gate: the Select /
-//     policy layer names no concrete method constant; it gates on Capabilities.
+//     policy layer and the solver name no concrete method constant; they gate on
+//     Capabilities.
 //   - TestConcreteConstructorsOnlyInPlugins — sole composition root: the leaf
 //     constructors are referenced only from plugins.go.
 //   - TestRegistryFactoriesAreLazy — microkernel lazy binding: the registry stores
@@ -148,37 +149,43 @@ func TestSharedCoresImportNoLeaf(t *testing.T) {
 func TestSelectionLayerNamesNoVariant(t *testing.T) {
 	root := internalRoot(t)
 	banned := map[string]bool{
-		"IsolationNone": true, "IsolationBwrap": true, "IsolationDocker": true,
+		"IsolationNone": true, "IsolationBwrap": true, "IsolationDocker": true, "IsolationPodman": true, "IsolationLandlock": true, "IsolationNative": true,
 		"ProvisionNone": true, "ProvisionNix": true, "ProvisionCommand": true,
 		"NetworkHost": true, "NetworkNone": true, "NetworkProxy": true,
 		"ModeHost": true, "ModeNone": true, "ModeProxy": true,
 	}
 	// The selection / policy gate (registry.go: Select + Capabilities + EnforcePolicy)
-	// must name no concrete variant; it gates on the Capabilities booleans only.
-	file := filepath.Join(root, "sandbox", "registry.go")
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, file, nil, 0)
-	if err != nil {
-		t.Fatalf("parse %s: %v", file, err)
-	}
-	ast.Inspect(f, func(n ast.Node) bool {
-		if id, ok := n.(*ast.Ident); ok && banned[id.Name] {
-			t.Errorf("selection layer names concrete variant %q", id.Name)
+	// and the policy solver (solve.go) must name no concrete variant; they gate on
+	// the Capabilities booleans and rank on the plugins' declared costs only.
+	for _, name := range []string{"registry.go", "solve.go"} {
+		file := filepath.Join(root, "sandbox", name)
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatalf("parse %s: %v", file, err)
 		}
-		return true
-	})
+		ast.Inspect(f, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && banned[id.Name] {
+				t.Errorf("selection layer (%s) names concrete variant %q", name, id.Name)
+			}
+			return true
+		})
+	}
 }
 
 func TestConcreteConstructorsOnlyInPlugins(t *testing.T) {
 	root := internalRoot(t)
 	// leaf import path -> its concrete constructor name.
 	ctors := map[string]string{
-		modPath + "/internal/isolation/none":    "NewIsolator",
-		modPath + "/internal/isolation/bwrap":   "NewIsolator",
-		modPath + "/internal/isolation/docker":  "NewIsolator",
-		modPath + "/internal/provision/none":    "New",
-		modPath + "/internal/provision/command": "New",
-		modPath + "/internal/provision/nix":     "New",
+		modPath + "/internal/isolation/none":     "NewIsolator",
+		modPath + "/internal/isolation/bwrap":    "NewIsolator",
+		modPath + "/internal/isolation/docker":   "NewIsolator",
+		modPath + "/internal/isolation/podman":   "NewIsolator",
+		modPath + "/internal/isolation/landlock": "NewIsolator",
+		modPath + "/internal/isolation/native":   "NewIsolator",
+		modPath + "/internal/provision/none":     "New",
+		modPath + "/internal/provision/command":  "New",
+		modPath + "/internal/provision/nix":      "New",
 	}
 	pluginsFile := filepath.Join(root, "sandbox", "plugins", "plugins.go")
 
@@ -1,15 +1,18 @@
 // Package plugins is the sandbox composition root: it assembles the built-in
 // isolators and provisioners into a Registry. This is the ONLY place that imports
-// the concrete leaf packages (isolation/{none,bwrap,docker}, provision/{none,
-// command,nix}) — the core sandbox package depends only on the axis ports. Adding
-// a plugin means a new leaf package plus one Register call here; the selection and
-// policy code never changes.
+// the concrete leaf packages (isolation/{none,bwrap,docker,podman,
+// landlock,native}, provision/{none,command,nix}) — the core sandbox package depends only on the axis ports.
+// Adding a plugin means a new leaf package plus one Register call here; the
+// selection and policy code never changes.
 package plugins
 
 import (
 	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/bwrap"
 	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/docker"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/landlock"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/native"
 	isonone "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/none"
+	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/podman"
 	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
 	"github.com/xonovex/platform/packages/agent/agent-cli-go/internal/provision/command"
 	provnix "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/provision/nix"
@@ -26,6 +29,9 @@ func DefaultRegistry() *sandbox.Registry {
 		RegisterIsolator(isolation.IsolationNone, func() isoshared.Isolator { return isonone.NewIsolator() }).
 		RegisterIsolator(isolation.IsolationBwrap, func() isoshared.Isolator { return bwrap.NewIsolator() }).
 		RegisterIsolator(isolation.IsolationDocker, func() isoshared.Isolator { return docker.NewIsolator() }).
+		RegisterIsolator(isolation.IsolationPodman, func() isoshared.Isolator { return podman.NewIsolator() }).
+		RegisterIsolator(isolation.IsolationLandlock, func() isoshared.Isolator { return landlock.NewIsolator() }).
+		RegisterIsolator(isolation.IsolationNative, func() isoshared.Isolator { return native.NewIsolator() }).
 		RegisterProvisioner(provision.ProvisionNone, func() provshared.Provisioner { return provnone.New() }).
 		RegisterProvisioner(provision.ProvisionCommand, func() provshared.Provisioner { return command.New() }).
 		RegisterProvisioner(provision.ProvisionNix, func() provshared.Provisioner { return provnix.New() })
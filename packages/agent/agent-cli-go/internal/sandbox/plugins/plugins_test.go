@@ -13,7 +13,7 @@ import (
 func TestDefaultRegistry(t *testing.T) {
 	reg := DefaultRegistry()
 
-	for _, m := range []isolation.IsolationMethod{isolation.IsolationNone, isolation.IsolationBwrap, isolation.IsolationDocker} {
+	for _, m := range []isolation.IsolationMethod{isolation.IsolationNone, isolation.IsolationBwrap, isolation.IsolationDocker, isolation.IsolationPodman, isolation.IsolationLandlock, isolation.IsolationNative} {
 		if _, err := reg.Isolator(m); err != nil {
 			t.Errorf("isolator %q not registered: %v", m, err)
 		}
@@ -1,6 +1,6 @@
 // Package sandbox is the confinement composition/selection layer: it holds only
-// the Registry (lazy plugin factories), Select (fail-closed policy gate), and the
-// plugins composition root. The axis ports and concrete leaves live under
+// the Registry (lazy plugin factories), Select (fail-closed policy gate), the
+// policy solver (Cells, Solve), and the plugins composition root. The axis ports and concrete leaves live under
 // internal/{isolation,provision,network}; this package names no concrete leaf
 // (only internal/sandbox/plugins does).
 package sandbox
@@ -23,6 +23,9 @@ var (
 	ErrNoIsolator = errors.New("no isolator registered")
 	// ErrNoProvisioner reports a provisioning method with no registered provisioner.
 	ErrNoProvisioner = errors.New("no provisioner registered")
+	// ErrSyscallFilterUnsupported reports a seccomp profile the selected
+	// isolator cannot install.
+	ErrSyscallFilterUnsupported = errors.New("isolation cannot install a seccomp profile")
 )
 
 // IsolatorFactory and ProvisionerFactory construct a fresh plugin instance.
@@ -88,6 +91,16 @@ func (r *Registry) IsolationMethods() []isolation.IsolationMethod {
 	return out
 }
 
+// ProvisionMethods returns the registered provisioning methods, sorted for stable output.
+func (r *Registry) ProvisionMethods() []provision.ProvisionMethod {
+	out := make([]provision.ProvisionMethod, 0, len(r.provisioners))
+	for m := range r.provisioners {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
 // Request bundles the per-run axis selection handed to Select.
 type Request struct {
 	Isolation      isolation.IsolationMethod
@@ -97,6 +110,7 @@ type Request struct {
 	Runtime        string
 	Image          string
 	HasCustomBinds bool
+	SeccompProfile string
 }
 
 // Select resolves the isolator and provisioner for a request and enforces the
@@ -112,18 +126,27 @@ func Select(reg *Registry, req Request, pol policy.SandboxPolicy) (isoshared.Iso
 	if err != nil {
 		return nil, nil, err
 	}
-	caps := policy.Capabilities{
-		Pinned:               iso.PinnedProvision(req.Provision, prov.Pinned(), req.Image),
-		HostToolsUnreachable: iso.HidesHost(req.Passthrough, req.Image) && !req.HasCustomBinds,
-		EgressRestricted:     netshared.EgressIsRestricted(req.Network),
-		KernelIsolated:       iso.KernelIsolated(req.Runtime),
+	// A requested profile is never dropped silently, with or without a policy.
+	if req.SeccompProfile != "" && !iso.FiltersSyscalls(req.SeccompProfile) {
+		return nil, nil, fmt.Errorf("%w: isolation %q with profile %q", ErrSyscallFilterUnsupported, req.Isolation, req.SeccompProfile)
 	}
-	if err := policy.EnforcePolicy(caps, pol); err != nil {
+	if err := policy.EnforcePolicy(Capabilities(iso, prov, req), pol); err != nil {
 		return nil, nil, err
 	}
 	return iso, prov, nil
 }
 
+// Capabilities returns the guarantees the resolved plugins declare for req.
+func Capabilities(iso isoshared.Isolator, prov provshared.Provisioner, req Request) policy.Capabilities {
+	return policy.Capabilities{
+		Pinned:               iso.PinnedProvision(req.Provision, prov.Pinned(), req.Image),
+		HostToolsUnreachable: iso.HidesHost(req.Passthrough, req.Image) && !req.HasCustomBinds,
+		EgressRestricted:     iso.RestrictsEgress(req.Network),
+		KernelIsolated:       iso.KernelIsolated(req.Runtime),
+		SyscallFiltered:      iso.FiltersSyscalls(req.SeccompProfile),
+	}
+}
+
 // AvailableIsolations returns the registered isolation methods whose isolator is
 // currently available on this host.
 func AvailableIsolations(reg *Registry) []isolation.IsolationMethod {
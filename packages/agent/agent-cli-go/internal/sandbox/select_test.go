@@ -15,9 +15,12 @@ import (
 // fakeIsolator / fakeProvisioner let the registry + Select + policy be unit-tested
 // without importing the concrete plugin packages.
 type fakeIsolator struct {
-	available bool
-	hidesHost bool
-	kernelIso bool
+	available   bool
+	hidesHost   bool
+	kernelIso   bool
+	leaksEgress bool
+	seccomp     bool
+	cost        int
 }
 
 func (f fakeIsolator) Available() (bool, error)                                     { return f.available, nil }
@@ -28,18 +31,36 @@ func (f fakeIsolator) Command(isoshared.RunConfig, provision.Contribution) ([]st
 func (f fakeIsolator) TerminalCommand(isoshared.RunConfig, provision.Contribution) ([]string, []string, error) {
 	return nil, nil, nil
 }
+func (f fakeIsolator) Exposure(isoshared.RunConfig, provision.Contribution) (isoshared.Exposure, error) {
+	return isoshared.Exposure{}, nil
+}
 func (f fakeIsolator) PinnedProvision(_ provision.ProvisionMethod, provisionerPinned bool, _ string) bool {
 	return provisionerPinned
 }
 func (f fakeIsolator) HidesHost(_ bool, _ string) bool { return f.hidesHost }
 func (f fakeIsolator) KernelIsolated(_ string) bool    { return f.kernelIso }
+func (f fakeIsolator) FiltersSyscalls(profile string) bool {
+	return f.seccomp && profile != ""
+}
+func (f fakeIsolator) RestrictsEgress(m netshared.Mode) bool {
+	return netshared.EgressIsRestricted(m) && !f.leaksEgress
+}
+func (f fakeIsolator) Cost(string) int   { return f.cost }
+func (f fakeIsolator) CopyOnWrite() bool { return false }
 
-type fakeProvisioner struct{ pinned bool }
+type fakeProvisioner struct {
+	pinned      bool
+	unavailable bool
+	cost        int
+}
+
+func (f fakeProvisioner) Available() (bool, error) { return !f.unavailable, nil }
 
 func (f fakeProvisioner) Contribute(provshared.Input) (provision.Contribution, error) {
 	return provision.Contribution{}, nil
 }
 func (f fakeProvisioner) Pinned() bool { return f.pinned }
+func (f fakeProvisioner) Cost() int    { return f.cost }
 
 func testRegistry(iso fakeIsolator, prov fakeProvisioner) *Registry {
 	return NewRegistry().
@@ -81,7 +102,9 @@ func TestSelect_PolicyFromPluginCapabilities(t *testing.T) {
 		{"pinned unmet", fakeIsolator{hidesHost: true}, fakeProvisioner{pinned: false}, netshared.ModeNone, policy.SandboxPolicy{RequirePinnedProvision: true}, policy.ErrPinnedProvisionUnmet},
 		{"host-tools unmet", fakeIsolator{hidesHost: false}, fakeProvisioner{pinned: true}, netshared.ModeNone, policy.SandboxPolicy{RequireHostToolsUnreachable: true}, policy.ErrHostToolsReachable},
 		{"egress unmet (host net)", fakeIsolator{}, fakeProvisioner{}, netshared.ModeHost, policy.SandboxPolicy{RequireEgressRestricted: true}, policy.ErrEgressUnrestricted},
+		{"egress unmet (partially enforced)", fakeIsolator{leaksEgress: true}, fakeProvisioner{}, netshared.ModeNone, policy.SandboxPolicy{RequireEgressRestricted: true}, policy.ErrEgressUnrestricted},
 		{"kernel unmet", fakeIsolator{kernelIso: false}, fakeProvisioner{}, netshared.ModeNone, policy.SandboxPolicy{RequireKernelIsolation: true}, policy.ErrKernelIsolationUnmet},
+		{"syscall filter unmet", fakeIsolator{seccomp: true}, fakeProvisioner{}, netshared.ModeNone, policy.SandboxPolicy{RequireSyscallFilter: true}, policy.ErrSyscallFilterUnmet},
 		{"all met", fakeIsolator{hidesHost: true, kernelIso: true}, fakeProvisioner{pinned: true}, netshared.ModeNone, policy.SandboxPolicy{RequirePinnedProvision: true, RequireHostToolsUnreachable: true, RequireEgressRestricted: true, RequireKernelIsolation: true}, nil},
 	}
 	for _, tc := range cases {
@@ -115,6 +138,19 @@ func TestSelect_RejectsCustomBindsWhenHostToolsMustBeUnreachable(t *testing.T) {
 	}
 }
 
+func TestSelect_SeccompProfile(t *testing.T) {
+	req := bwrapNixReq()
+	req.SeccompProfile = "default"
+
+	if _, _, err := Select(testRegistry(fakeIsolator{}, fakeProvisioner{}), req, policy.SandboxPolicy{}); !errors.Is(err, ErrSyscallFilterUnsupported) {
+		t.Errorf("unsupported profile err = %v, want ErrSyscallFilterUnsupported", err)
+	}
+	reg := testRegistry(fakeIsolator{seccomp: true}, fakeProvisioner{})
+	if _, _, err := Select(reg, req, policy.SandboxPolicy{RequireSyscallFilter: true}); err != nil {
+		t.Errorf("Select = %v, want the installed profile to meet the policy", err)
+	}
+}
+
 func TestAvailableIsolations(t *testing.T) {
 	reg := NewRegistry().
 		RegisterIsolator(isolation.IsolationNone, func() isoshared.Isolator { return fakeIsolator{available: true} }).
@@ -0,0 +1,78 @@
+package sandbox
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+)
+
+// ErrNoCellSatisfies reports a policy no available cell can meet.
+var ErrNoCellSatisfies = errors.New("no available sandbox cell satisfies the policy")
+
+// Cell is one isolator × provisioner pairing, the request it is resolved for
+// and the guarantees it provides.
+type Cell struct {
+	Request      Request
+	Available    bool
+	Capabilities policy.Capabilities
+	// Cost is the isolator's and provisioner's declared overhead combined.
+	Cost int
+}
+
+// Usable reports whether Select would accept the cell's request at all: a
+// requested seccomp profile must be one the isolator installs.
+func (c Cell) Usable() bool {
+	return c.Available && (c.Request.SeccompProfile == "" || c.Capabilities.SyscallFiltered)
+}
+
+// RequestFunc builds the request of one cell; the per-isolator knobs (runtime,
+// passthrough) differ between isolators.
+type RequestFunc func(isolation.IsolationMethod, provision.ProvisionMethod) Request
+
+// Cells returns every registered isolator × provisioner cell with its
+// guarantees for the request, cheapest first, then by name.
+func Cells(reg *Registry, request RequestFunc) []Cell {
+	var cells []Cell
+	for _, isoName := range reg.IsolationMethods() {
+		iso, err := reg.Isolator(isoName)
+		if err != nil {
+			continue
+		}
+		isoAvailable, err := iso.Available()
+		isoAvailable = isoAvailable && err == nil
+		for _, provName := range reg.ProvisionMethods() {
+			prov, err := reg.Provisioner(provName)
+			if err != nil {
+				continue
+			}
+			provAvailable, err := prov.Available()
+			req := request(isoName, provName)
+			cells = append(cells, Cell{
+				Request:      req,
+				Available:    isoAvailable && provAvailable && err == nil,
+				Capabilities: Capabilities(iso, prov, req),
+				Cost:         iso.Cost(req.Runtime) + prov.Cost(),
+			})
+		}
+	}
+	sort.SliceStable(cells, func(i, j int) bool { return cells[i].Cost < cells[j].Cost })
+	return cells
+}
+
+// Solve returns the usable cells that satisfy pol, cheapest first, or
+// ErrNoCellSatisfies.
+func Solve(cells []Cell, pol policy.SandboxPolicy) ([]Cell, error) {
+	var satisfying []Cell
+	for _, cell := range cells {
+		if cell.Usable() && policy.EnforcePolicy(cell.Capabilities, pol) == nil {
+			satisfying = append(satisfying, cell)
+		}
+	}
+	if len(satisfying) == 0 {
+		return nil, ErrNoCellSatisfies
+	}
+	return satisfying, nil
+}
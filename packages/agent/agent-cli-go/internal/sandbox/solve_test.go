@@ -0,0 +1,101 @@
+package sandbox
+
+import (
+	"errors"
+	"testing"
+
+	isoshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/isolation/shared"
+	netshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/network/shared"
+	provshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/provision/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/isolation"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/policy"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/provision"
+)
+
+// solverRegistry registers a cheap host-exposing isolator, a dearer hiding one,
+// an unavailable kernel-isolating one, and an unpinned and a pinned provisioner.
+func solverRegistry() *Registry {
+	return NewRegistry().
+		RegisterIsolator(isolation.IsolationNone, func() isoshared.Isolator { return fakeIsolator{available: true} }).
+		RegisterIsolator(isolation.IsolationBwrap, func() isoshared.Isolator {
+			return fakeIsolator{available: true, hidesHost: true, seccomp: true, cost: 2}
+		}).
+		RegisterIsolator(isolation.IsolationDocker, func() isoshared.Isolator {
+			return fakeIsolator{hidesHost: true, kernelIso: true, cost: 5}
+		}).
+		RegisterProvisioner(provision.ProvisionNone, func() provshared.Provisioner { return fakeProvisioner{} }).
+		RegisterProvisioner(provision.ProvisionNix, func() provshared.Provisioner { return fakeProvisioner{pinned: true, cost: 3} })
+}
+
+func hostRequest(iso isolation.IsolationMethod, prov provision.ProvisionMethod) Request {
+	return Request{Isolation: iso, Provision: prov, Network: netshared.ModeNone}
+}
+
+func TestCellsCoverEveryPairingCheapestFirst(t *testing.T) {
+	cells := Cells(solverRegistry(), hostRequest)
+
+	if len(cells) != 6 {
+		t.Fatalf("Cells() = %d cells, want 3 isolators × 2 provisioners", len(cells))
+	}
+	for i := 1; i < len(cells); i++ {
+		if cells[i-1].Cost > cells[i].Cost {
+			t.Errorf("Cells() not sorted by cost: %d before %d", cells[i-1].Cost, cells[i].Cost)
+		}
+	}
+	first, last := cells[0], cells[len(cells)-1]
+	if first.Request.Isolation != isolation.IsolationNone || first.Request.Provision != provision.ProvisionNone || first.Cost != 0 {
+		t.Errorf("cheapest cell = %+v, want none × none at cost 0", first)
+	}
+	if last.Request.Isolation != isolation.IsolationDocker || last.Available || last.Cost != 8 {
+		t.Errorf("dearest cell = %+v, want the unavailable docker × nix at cost 8", last)
+	}
+}
+
+func TestSolvePicksTheCheapestAvailableCell(t *testing.T) {
+	cells := Cells(solverRegistry(), hostRequest)
+
+	solved, err := Solve(cells, policy.SandboxPolicy{RequirePinnedProvision: true, RequireHostToolsUnreachable: true})
+	if err != nil {
+		t.Fatalf("Solve() error = %v", err)
+	}
+	if got := solved[0].Request; got.Isolation != isolation.IsolationBwrap || got.Provision != provision.ProvisionNix {
+		t.Errorf("Solve()[0] = %s × %s, want bwrap × nix", got.Isolation, got.Provision)
+	}
+	if len(solved) != 1 {
+		t.Errorf("Solve() = %d cells, want only the available one", len(solved))
+	}
+
+	// Only the unavailable docker cell gives a kernel boundary.
+	if _, err := Solve(cells, policy.SandboxPolicy{RequireKernelIsolation: true}); !errors.Is(err, ErrNoCellSatisfies) {
+		t.Errorf("Solve(kernel) error = %v, want %v", err, ErrNoCellSatisfies)
+	}
+}
+
+func TestSolveSkipsCellsThatCannotInstallTheProfile(t *testing.T) {
+	cells := Cells(solverRegistry(), func(iso isolation.IsolationMethod, prov provision.ProvisionMethod) Request {
+		req := hostRequest(iso, prov)
+		req.SeccompProfile = "default"
+		return req
+	})
+
+	solved, err := Solve(cells, policy.SandboxPolicy{})
+	if err != nil {
+		t.Fatalf("Solve() error = %v", err)
+	}
+	for _, cell := range solved {
+		if cell.Request.Isolation != isolation.IsolationBwrap {
+			t.Errorf("Solve() kept %s, which cannot install the profile", cell.Request.Isolation)
+		}
+	}
+}
+
+func TestCellsMarkUnavailableProvisioners(t *testing.T) {
+	reg := NewRegistry().
+		RegisterIsolator(isolation.IsolationNone, func() isoshared.Isolator { return fakeIsolator{available: true} }).
+		RegisterProvisioner(provision.ProvisionNix, func() provshared.Provisioner { return fakeProvisioner{unavailable: true} })
+
+	cells := Cells(reg, hostRequest)
+	if len(cells) != 1 || cells[0].Available {
+		t.Errorf("Cells() = %+v, want one unavailable cell", cells)
+	}
+}
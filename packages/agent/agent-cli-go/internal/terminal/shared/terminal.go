@@ -12,8 +12,8 @@ const (
 // TerminalConfig holds terminal wrapper configuration.
 type TerminalConfig struct {
 	Type           TerminalType
-	SessionName    string // Auto-generated if empty
-	WindowName     string // Defaults to directory basename
+	SessionName    string // Auto-generated if empty; Execute sets the name it used
+	WindowName     string // Defaults to directory basename; Execute sets the name it used
 	Detach         bool   // Run in background
 	AttachExisting bool   // Attach to existing session
 }
@@ -19,12 +19,16 @@ func GetExecutor(terminalType termshared.TerminalType) termshared.TerminalExecut
 	}
 }
 
-// GetAvailableTypes returns all terminal types that are currently available.
-func GetAvailableTypes() []termshared.TerminalType {
-	allTypes := []termshared.TerminalType{
+// GetTypes returns every terminal type with an executor leaf.
+func GetTypes() []termshared.TerminalType {
+	return []termshared.TerminalType{
 		termshared.TerminalTmux,
 	}
+}
 
+// GetAvailableTypes returns all terminal types that are currently available.
+func GetAvailableTypes() []termshared.TerminalType {
+	allTypes := GetTypes()
 	available := make([]termshared.TerminalType, 0, len(allTypes))
 	for _, t := range allTypes {
 		executor := GetExecutor(t)
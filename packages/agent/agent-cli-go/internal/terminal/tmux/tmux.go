@@ -41,7 +41,7 @@ func (e *Executor) IsInside() bool {
 
 // Execute runs the command in a tmux session
 func (e *Executor) Execute(config *termshared.TerminalConfig, command []string, env []string, workDir string, verbose bool) (int, error) {
-	// Generate session and window names if not provided
+	// Generate session and window names if not provided, and report them back
 	sessionName := config.SessionName
 	if sessionName == "" {
 		sessionName = generateSessionName(workDir)
@@ -51,6 +51,7 @@ func (e *Executor) Execute(config *termshared.TerminalConfig, command []string,
 	if windowName == "" {
 		windowName = generateWindowName(workDir)
 	}
+	config.SessionName, config.WindowName = sessionName, windowName
 
 	launchScript, err := createLaunchScript(command, env)
 	if err != nil {
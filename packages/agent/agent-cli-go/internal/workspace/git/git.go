@@ -194,3 +194,79 @@ func (w Worktree) Setup(config wsshared.Config, repoDir string, verbose bool) (s
 	}
 	return resolvedDir, nil
 }
+
+// List returns the repository's worktrees from `git worktree list --porcelain`,
+// the main worktree first.
+func (w Worktree) List(repoDir string) ([]wsshared.Checkout, error) {
+	out, err := wsshared.ExecGit(w.runner, []string{"worktree", "list", "--porcelain"}, repoDir)
+	if err != nil {
+		return nil, fmt.Errorf("list git worktrees: %w", err)
+	}
+	var checkouts []wsshared.Checkout
+	for _, block := range strings.Split(out, "\n\n") {
+		var checkout wsshared.Checkout
+		for _, line := range strings.Split(strings.TrimSpace(block), "\n") {
+			field, value, _ := strings.Cut(line, " ")
+			switch field {
+			case "worktree":
+				checkout.Dir = value
+			case "branch":
+				checkout.Branch = strings.TrimPrefix(value, "refs/heads/")
+			case "prunable":
+				checkout.Missing = true
+			}
+		}
+		if checkout.Dir == "" {
+			continue
+		}
+		checkout.Main = len(checkouts) == 0
+		if _, err := os.Stat(checkout.Dir); err != nil {
+			checkout.Missing = true
+		}
+		if !checkout.Missing {
+			status, err := wsshared.ExecGit(w.runner, []string{"status", "--porcelain"}, checkout.Dir)
+			if err != nil {
+				return nil, fmt.Errorf("inspect worktree %s: %w", checkout.Dir, err)
+			}
+			checkout.Dirty = status != ""
+		}
+		checkouts = append(checkouts, checkout)
+	}
+	return checkouts, nil
+}
+
+// Remove deletes a worktree with `git worktree remove`. Its branch is kept.
+func (w Worktree) Remove(repoDir string, checkout wsshared.Checkout, force bool) error {
+	if checkout.Main {
+		return fmt.Errorf("%s is the main worktree of the repository", checkout.Dir)
+	}
+	if checkout.Dirty && !force {
+		return fmt.Errorf("%w: %s (use --force to remove it anyway)", wsshared.ErrDirty, checkout.Dir)
+	}
+	args := []string{"worktree", "remove", checkout.Dir}
+	if force {
+		args = []string{"worktree", "remove", "--force", checkout.Dir}
+	}
+	return w.runner.Stream("git", args, repoDir)
+}
+
+// Prune forgets the worktrees whose directory is gone with `git worktree prune`.
+func (w Worktree) Prune(repoDir string) ([]wsshared.Checkout, error) {
+	checkouts, err := w.List(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []wsshared.Checkout
+	for _, checkout := range checkouts {
+		if checkout.Missing {
+			pruned = append(pruned, checkout)
+		}
+	}
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+	if _, err := wsshared.ExecGit(w.runner, []string{"worktree", "prune"}, repoDir); err != nil {
+		return nil, fmt.Errorf("prune git worktrees: %w", err)
+	}
+	return pruned, nil
+}
@@ -269,3 +269,91 @@ func TestAvailable(t *testing.T) {
 		t.Error("git worktree variant must always report available")
 	}
 }
+
+func TestList_ReadsThePorcelainListing(t *testing.T) {
+	repoDir, clean, dirty := t.TempDir(), t.TempDir(), t.TempDir()
+	gone := filepath.Join(t.TempDir(), "gone")
+	runner := &fakeRunner{output: map[string]string{
+		key(repoDir, "git", []string{"worktree", "list", "--porcelain"}): "worktree " + repoDir + "\nHEAD abc\nbranch refs/heads/main\n\n" +
+			"worktree " + clean + "\nHEAD def\nbranch refs/heads/feature/x\n\n" +
+			"worktree " + dirty + "\nHEAD 123\ndetached\n\n" +
+			"worktree " + gone + "\nHEAD 456\nbranch refs/heads/old\nprunable gitdir file points to non-existent location\n",
+		key(repoDir, "git", []string{"status", "--porcelain"}): "",
+		key(clean, "git", []string{"status", "--porcelain"}):   "",
+		key(dirty, "git", []string{"status", "--porcelain"}):   " M README.md",
+	}}
+
+	checkouts, err := New(runner).List(repoDir)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []wsshared.Checkout{
+		{Dir: repoDir, Branch: "main", Main: true},
+		{Dir: clean, Branch: "feature/x"},
+		{Dir: dirty, Dirty: true},
+		{Dir: gone, Branch: "old", Missing: true},
+	}
+	if len(checkouts) != len(want) {
+		t.Fatalf("List() = %+v, want %+v", checkouts, want)
+	}
+	for i := range want {
+		if checkouts[i] != want[i] {
+			t.Errorf("List()[%d] = %+v, want %+v", i, checkouts[i], want[i])
+		}
+	}
+}
+
+func TestRemove_RefusesTheMainAndDirtyWorktrees(t *testing.T) {
+	runner := &fakeRunner{}
+	worktree := New(runner)
+
+	if err := worktree.Remove("/repo", wsshared.Checkout{Dir: "/repo", Main: true}, true); err == nil {
+		t.Error("Remove(main) error = nil, want a refusal")
+	}
+	if err := worktree.Remove("/repo", wsshared.Checkout{Dir: "/wt", Dirty: true}, false); !errors.Is(err, wsshared.ErrDirty) {
+		t.Errorf("Remove(dirty) error = %v, want %v", err, wsshared.ErrDirty)
+	}
+	if len(runner.streamed) != 0 {
+		t.Fatalf("Remove() ran %v, want nothing for a refused worktree", runner.streamed)
+	}
+	if err := worktree.Remove("/repo", wsshared.Checkout{Dir: "/wt", Dirty: true}, true); err != nil {
+		t.Fatalf("Remove(dirty, force) error = %v", err)
+	}
+	if want := key("/repo", "git", []string{"worktree", "remove", "--force", "/wt"}); len(runner.streamed) != 1 || runner.streamed[0] != want {
+		t.Errorf("Remove(force) ran %v, want %q", runner.streamed, want)
+	}
+}
+
+func TestPrune_ReportsTheMissingWorktrees(t *testing.T) {
+	repoDir := t.TempDir()
+	gone := filepath.Join(t.TempDir(), "gone")
+	runner := &fakeRunner{output: map[string]string{
+		key(repoDir, "git", []string{"worktree", "list", "--porcelain"}): "worktree " + repoDir + "\nbranch refs/heads/main\n\nworktree " + gone + "\nbranch refs/heads/old\n",
+		key(repoDir, "git", []string{"status", "--porcelain"}):           "",
+		key(repoDir, "git", []string{"worktree", "prune"}):               "",
+	}}
+
+	pruned, err := New(runner).Prune(repoDir)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].Dir != gone || pruned[0].Branch != "old" {
+		t.Errorf("Prune() = %+v, want the missing worktree on old", pruned)
+	}
+}
+
+func TestPrune_LeavesAListingWithNothingMissingAlone(t *testing.T) {
+	repoDir := t.TempDir()
+	runner := &fakeRunner{output: map[string]string{
+		key(repoDir, "git", []string{"worktree", "list", "--porcelain"}): "worktree " + repoDir + "\nbranch refs/heads/main\n",
+		key(repoDir, "git", []string{"status", "--porcelain"}):           "",
+	}}
+
+	// No recording for `git worktree prune`: running it would fail.
+	if pruned, err := New(runner).Prune(repoDir); err != nil || pruned != nil {
+		t.Errorf("Prune() = (%+v, %v), want nothing pruned", pruned, err)
+	}
+	if _, err := New(&fakeRunner{}).List(repoDir); err == nil || !strings.Contains(err.Error(), "list git worktrees") {
+		t.Errorf("List() error = %v, want a wrapped listing failure", err)
+	}
+}
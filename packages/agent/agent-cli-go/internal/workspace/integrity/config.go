@@ -0,0 +1,359 @@
+package integrity
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// guardedKeys are the config keys that make git run a program, load another
+// file, or reach another host: a run that sets one plants code the next
+// host-side git command would execute. A * segment matches any subsection
+// or, in a two-segment key, any name.
+var guardedKeys = []string{
+	"alias.*",
+	"browser.*.cmd",
+	"core.askpass",
+	"core.attributesfile",
+	"core.editor",
+	"core.fsmonitor",
+	"core.gitproxy",
+	"core.hookspath",
+	"core.pager",
+	"core.sshcommand",
+	"core.worktree",
+	"credential.helper",
+	"credential.*.helper",
+	"diff.external",
+	"diff.*.command",
+	"diff.*.textconv",
+	"difftool.*.cmd",
+	"filter.*.clean",
+	"filter.*.process",
+	"filter.*.smudge",
+	"gpg.program",
+	"gpg.*.program",
+	"include.path",
+	"includeif.*.path",
+	"man.*.cmd",
+	"merge.*.driver",
+	"mergetool.*.cmd",
+	"pager.*",
+	"remote.*.receivepack",
+	"remote.*.uploadpack",
+	"sequence.editor",
+	"uploadpack.packobjectshook",
+	"url.*.insteadof",
+	"url.*.pushinsteadof",
+}
+
+// isGuardedKey reports whether key, as parseConfig names it, is guarded.
+func isGuardedKey(key string) bool {
+	section, rest, _ := strings.Cut(key, ".")
+	subsection, name := "", rest
+	if i := strings.LastIndex(rest, "."); i >= 0 {
+		subsection, name = rest[:i], rest[i+1:]
+	}
+	for _, pattern := range guardedKeys {
+		parts := strings.Split(pattern, ".")
+		if parts[0] != section {
+			continue
+		}
+		if len(parts) == 2 && subsection == "" && (parts[1] == "*" || parts[1] == name) {
+			return true
+		}
+		if len(parts) == 3 && subsection != "" && parts[2] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// configValues are the values of each guarded key of a config file, in file
+// order.
+type configValues map[string][]string
+
+// guardedValues parses a git config file and keeps its guarded keys.
+func guardedValues(data []byte) (configValues, error) {
+	vars, err := parseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	values := configValues{}
+	for _, v := range vars {
+		if isGuardedKey(v.key) {
+			values[v.key] = append(values[v.key], v.value)
+		}
+	}
+	return values, nil
+}
+
+// diffValues describes how the guarded keys changed from before to after.
+func diffValues(before, after configValues) []string {
+	var keys []string
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	var details []string
+	for _, key := range keys {
+		was, now := before[key], after[key]
+		switch {
+		case slices.Equal(was, now):
+		case len(was) == 0:
+			details = append(details, fmt.Sprintf("%s set to %s", key, quoteValues(now)))
+		case len(now) == 0:
+			details = append(details, fmt.Sprintf("%s unset (was %s)", key, quoteValues(was)))
+		default:
+			details = append(details, fmt.Sprintf("%s changed from %s to %s", key, quoteValues(was), quoteValues(now)))
+		}
+	}
+	return details
+}
+
+func quoteValues(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// configVar is one variable of a config file. key is section[.subsection].name
+// with the section and name lowercased, as git names it; a variable without a
+// value is "true".
+type configVar struct {
+	key   string
+	value string
+}
+
+var errConfigSyntax = errors.New("bad config syntax")
+
+// parseConfig reads the variables of a git config file the way git's own
+// parser does, so what it finds is what git would act on. Includes are not
+// followed: include.path is itself a guarded key.
+func parseConfig(data []byte) ([]configVar, error) {
+	p := &configParser{data: data}
+	if strings.HasPrefix(string(data), "\xef\xbb\xbf") {
+		p.pos = 3
+	}
+	var vars []configVar
+	section := ""
+	for {
+		c, ok := p.next()
+		switch {
+		case !ok:
+			return vars, nil
+		case c == '#' || c == ';':
+			p.skipLine()
+		case c == '\n' || isSpace(c):
+		case c == '[':
+			var err error
+			if section, err = p.section(); err != nil {
+				return nil, p.fail(err)
+			}
+		case isAlpha(c):
+			name, value, err := p.variable(c)
+			if err != nil {
+				return nil, p.fail(err)
+			}
+			// Before any section header git keeps the bare name, which no
+			// guarded key is.
+			key := name
+			if section != "" {
+				key = section + "." + name
+			}
+			vars = append(vars, configVar{key: key, value: value})
+		default:
+			return nil, p.fail(fmt.Errorf("%w: unexpected %q", errConfigSyntax, c))
+		}
+	}
+}
+
+type configParser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+// next returns the next byte, reading \r\n as \n.
+func (p *configParser) next() (byte, bool) {
+	if p.pos >= len(p.data) {
+		return 0, false
+	}
+	c := p.data[p.pos]
+	p.pos++
+	if c == '\r' && p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		c = '\n'
+		p.pos++
+	}
+	if c == '\n' {
+		p.line++
+	}
+	return c, true
+}
+
+func (p *configParser) skipLine() {
+	for {
+		if c, ok := p.next(); !ok || c == '\n' {
+			return
+		}
+	}
+}
+
+func (p *configParser) fail(err error) error {
+	return fmt.Errorf("line %d: %w", p.line+1, err)
+}
+
+// section reads a section header after its [: a lowercased name, then either
+// a quoted subsection, kept as written, or the legacy dotted form.
+func (p *configParser) section() (string, error) {
+	var name strings.Builder
+	for {
+		c, ok := p.next()
+		switch {
+		case !ok || c == '\n':
+			return "", fmt.Errorf("%w: unterminated section header", errConfigSyntax)
+		case c == ']':
+			if name.Len() == 0 {
+				return "", fmt.Errorf("%w: empty section name", errConfigSyntax)
+			}
+			return name.String(), nil
+		case isSpace(c):
+			return p.subsection(name.String())
+		case isKeyChar(c) || c == '.':
+			name.WriteByte(toLower(c))
+		default:
+			return "", fmt.Errorf("%w: %q in a section name", errConfigSyntax, c)
+		}
+	}
+}
+
+func (p *configParser) subsection(section string) (string, error) {
+	c, ok := p.next()
+	for ok && isSpace(c) {
+		c, ok = p.next()
+	}
+	if !ok || c != '"' {
+		return "", fmt.Errorf("%w: subsection is not quoted", errConfigSyntax)
+	}
+	var sub strings.Builder
+	for {
+		c, ok := p.next()
+		if !ok || c == '\n' {
+			return "", fmt.Errorf("%w: unterminated subsection", errConfigSyntax)
+		}
+		if c == '"' {
+			break
+		}
+		if c == '\\' {
+			if c, ok = p.next(); !ok || c == '\n' {
+				return "", fmt.Errorf("%w: unterminated subsection", errConfigSyntax)
+			}
+		}
+		sub.WriteByte(c)
+	}
+	if c, ok := p.next(); !ok || c != ']' {
+		return "", fmt.Errorf("%w: unterminated section header", errConfigSyntax)
+	}
+	return section + "." + sub.String(), nil
+}
+
+// variable reads a variable whose name starts with first: the name, and the
+// value after an = or "true" when there is none.
+func (p *configParser) variable(first byte) (string, string, error) {
+	name := []byte{toLower(first)}
+	c, ok := p.next()
+	for ok && isKeyChar(c) {
+		name = append(name, toLower(c))
+		c, ok = p.next()
+	}
+	for ok && (c == ' ' || c == '\t') {
+		c, ok = p.next()
+	}
+	if !ok || c == '\n' {
+		return string(name), "true", nil
+	}
+	if c != '=' {
+		return "", "", fmt.Errorf("%w: %q after variable %s", errConfigSyntax, c, name)
+	}
+	value, err := p.value()
+	return string(name), value, err
+}
+
+// value reads a value up to the end of its line: whitespace outside quotes
+// collapses to what lies between words, # and ; start a comment, and a
+// trailing backslash continues the line.
+func (p *configParser) value() (string, error) {
+	var value []byte
+	quoted, comment, spaces := false, false, 0
+	for {
+		c, ok := p.next()
+		if !ok || c == '\n' {
+			if quoted {
+				return "", fmt.Errorf("%w: unterminated quote", errConfigSyntax)
+			}
+			return string(value), nil
+		}
+		if comment {
+			continue
+		}
+		if !quoted {
+			if c == '#' || c == ';' {
+				comment = true
+				continue
+			}
+			if isSpace(c) {
+				if len(value) > 0 {
+					spaces++
+				}
+				continue
+			}
+		}
+		for ; spaces > 0; spaces-- {
+			value = append(value, ' ')
+		}
+		switch c {
+		case '\\':
+			c, ok = p.next()
+			switch {
+			case !ok:
+				return "", fmt.Errorf("%w: unterminated escape", errConfigSyntax)
+			case c == '\n':
+				continue
+			case c == 't':
+				c = '\t'
+			case c == 'b':
+				c = '\b'
+			case c == 'n':
+				c = '\n'
+			case c != '\\' && c != '"':
+				return "", fmt.Errorf("%w: unknown escape \\%c", errConfigSyntax, c)
+			}
+			value = append(value, c)
+		case '"':
+			quoted = !quoted
+		default:
+			value = append(value, c)
+		}
+	}
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f' }
+
+func isAlpha(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+func isKeyChar(c byte) bool { return isAlpha(c) || c >= '0' && c <= '9' || c == '-' }
+
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
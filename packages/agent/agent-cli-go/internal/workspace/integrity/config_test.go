@@ -0,0 +1,85 @@
+package integrity
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigReadsWhatGitReads(t *testing.T) {
+	data := "\xef\xbb\xbf# comment\nloose = value\n" +
+		"[core]\n" +
+		"\tbare = false\n" +
+		"\tHooksPath = \" /tmp/my hooks \" ; trailing comment\n" +
+		"[Remote \"Origin\"]\n" +
+		"\turl = git@example.com:repo.git\n" +
+		"[branch.Main] remote = origin\n" +
+		"[alias]\n" +
+		"\tst = status \\\n  --short\n" +
+		"\tflag\r\n" +
+		"\tmsg = a\\tb\\\\c\\\"d\\n\n"
+	want := []configVar{
+		{"loose", "value"},
+		{"core.bare", "false"},
+		{"core.hookspath", " /tmp/my hooks "},
+		{"remote.Origin.url", "git@example.com:repo.git"},
+		{"branch.main.remote", "origin"},
+		{"alias.st", "status   --short"},
+		{"alias.flag", "true"},
+		{"alias.msg", "a\tb\\c\"d\n"},
+	}
+
+	got, err := parseConfig([]byte(data))
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig() = (%q, %v), want %q", got, err, want)
+	}
+}
+
+func TestParseConfigRefusesWhatGitRefuses(t *testing.T) {
+	for _, data := range []string{
+		"[core\n",
+		"[core \"unterminated]\n",
+		"[core]\n\tpager = \"less\n",
+		"[core]\n\tpager = less \\q\n",
+		"[core]\n\tpager less\n",
+		"[core]\n\t1pager = less\n",
+	} {
+		if _, err := parseConfig([]byte(data)); !errors.Is(err, errConfigSyntax) {
+			t.Errorf("parseConfig(%q) error = %v, want %v", data, err, errConfigSyntax)
+		}
+	}
+}
+
+func TestGuardedKeysNameTheKeysThatRunCode(t *testing.T) {
+	for key, want := range map[string]bool{
+		"core.hookspath":              true,
+		"core.fsmonitor":              true,
+		"alias.st":                    true,
+		"filter.lfs.smudge":           true,
+		"diff.my.driver.textconv":     true,
+		"includeif.gitdir:/src/.path": true,
+		"core.bare":                   false,
+		"alias.sub.st":                false,
+		"filter.clean":                false,
+		"remote.origin.url":           false,
+		"branch.main.remote":          false,
+	} {
+		if got := isGuardedKey(key); got != want {
+			t.Errorf("isGuardedKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestDiffValuesDescribesEachGuardedKey(t *testing.T) {
+	before := configValues{"core.pager": {"less"}, "alias.st": {"status"}}
+	after := configValues{"core.pager": {"sh -c evil"}, "core.hookspath": {"/tmp/h"}}
+
+	got := strings.Join(diffValues(before, after), "\n")
+	want := `alias.st unset (was "status")` + "\n" +
+		`core.hookspath set to "/tmp/h"` + "\n" +
+		`core.pager changed from "less" to "sh -c evil"`
+	if got != want {
+		t.Errorf("diffValues() =\n%s\nwant\n%s", got, want)
+	}
+}
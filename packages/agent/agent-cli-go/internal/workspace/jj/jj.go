@@ -3,13 +3,24 @@ package jj
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	wsshared "github.com/xonovex/platform/packages/agent/agent-cli-go/internal/workspace/shared"
 	"github.com/xonovex/platform/packages/shared/shared-core-go/pkg/logging"
 )
 
+// defaultWorkspace is the name jj gives the repository's own workspace.
+const defaultWorkspace = "default"
+
+// indexFile, under the repository's .jj directory, records the directory of
+// each workspace Setup creates: jj names a workspace but does not keep its
+// path.
+const indexFile = "agent-cli-workspaces"
+
 // Workspace is the jj VCS variant: it creates or reuses a jj workspace.
 type Workspace struct{ runner wsshared.Runner }
 
@@ -54,9 +65,195 @@ func (w Workspace) Setup(config wsshared.Config, repoDir string, verbose bool) (
 	if err := w.runner.Stream("jj", []string{"workspace", "add", resolvedDir, "--revision", sourceBranch}, repoDir); err != nil {
 		return "", fmt.Errorf("jj workspace add failed: %w", err)
 	}
+	// Without the index the workspace still works; only `worktree list` loses
+	// its directory.
+	if err := w.recordWorkspace(repoDir, filepath.Base(resolvedDir), resolvedDir); err != nil {
+		logging.LogWarning("record jj workspace: " + err.Error())
+	}
 
 	if verbose {
 		logging.LogSuccess("jj workspace created successfully")
 	}
 	return resolvedDir, nil
 }
+
+// List returns the repository's workspaces from `jj workspace list`, the
+// default workspace first. A workspace Setup did not create is looked for next
+// to the repository, where jj puts it by default; its Dir is empty when it is
+// not there.
+func (w Workspace) List(repoDir string) ([]wsshared.Checkout, error) {
+	root, err := w.root(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := w.runner.Capture("jj", []string{"workspace", "list"}, root)
+	if err != nil {
+		return nil, fmt.Errorf("list jj workspaces: %w", err)
+	}
+	index, err := readIndex(root)
+	if err != nil {
+		return nil, err
+	}
+	var checkouts []wsshared.Checkout
+	for _, line := range strings.Split(out, "\n") {
+		name, _, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		checkout := wsshared.Checkout{Name: name, Dir: index[name], Main: name == defaultWorkspace}
+		switch {
+		case checkout.Main:
+			checkout.Dir = root
+		case checkout.Dir == "":
+			if sibling := filepath.Join(filepath.Dir(root), name); isWorkspaceDir(sibling) {
+				checkout.Dir = sibling
+			}
+		}
+		if checkout.Dir != "" {
+			if _, err := os.Stat(checkout.Dir); err != nil {
+				checkout.Missing = true
+			} else {
+				w.inspect(&checkout)
+			}
+		}
+		checkouts = append(checkouts, checkout)
+	}
+	slices.SortStableFunc(checkouts, func(a, b wsshared.Checkout) int {
+		if a.Main == b.Main {
+			return 0
+		}
+		if a.Main {
+			return -1
+		}
+		return 1
+	})
+	return checkouts, nil
+}
+
+// inspect fills in the bookmark a workspace builds on and whether its working
+// copy holds changes.
+func (w Workspace) inspect(checkout *wsshared.Checkout) {
+	empty, err := w.runner.Capture("jj", []string{"log", "-r", "@", "--no-graph", "-T", "empty"}, checkout.Dir)
+	checkout.Dirty = err == nil && empty == "false"
+	bookmarks, err := w.runner.Capture("jj", []string{"log", "-r", "heads(::@ & bookmarks())", "--no-graph", "-T", `bookmarks ++ "\n"`}, checkout.Dir)
+	if fields := strings.Fields(bookmarks); err == nil && len(fields) > 0 {
+		checkout.Branch = strings.TrimSuffix(fields[0], "*")
+	}
+}
+
+// Remove forgets a workspace with `jj workspace forget` and deletes its
+// directory.
+func (w Workspace) Remove(repoDir string, checkout wsshared.Checkout, force bool) error {
+	if checkout.Main {
+		return fmt.Errorf("%s is the default workspace of the repository", checkout.Dir)
+	}
+	if checkout.Dirty && !force {
+		return fmt.Errorf("%w: %s (use --force to remove it anyway)", wsshared.ErrDirty, checkout.Dir)
+	}
+	if err := w.forget(repoDir, checkout.Name); err != nil {
+		return err
+	}
+	if checkout.Dir != "" && !checkout.Missing {
+		if err := os.RemoveAll(checkout.Dir); err != nil {
+			return fmt.Errorf("remove jj workspace directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Prune forgets the workspaces whose directory is gone.
+func (w Workspace) Prune(repoDir string) ([]wsshared.Checkout, error) {
+	checkouts, err := w.List(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []wsshared.Checkout
+	for _, checkout := range checkouts {
+		if !checkout.Missing {
+			continue
+		}
+		if err := w.forget(repoDir, checkout.Name); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, checkout)
+	}
+	return pruned, nil
+}
+
+// forget forgets the named workspace and drops it from the index.
+func (w Workspace) forget(repoDir, name string) error {
+	root, err := w.root(repoDir)
+	if err != nil {
+		return err
+	}
+	if _, err := w.runner.Capture("jj", []string{"workspace", "forget", name}, root); err != nil {
+		return fmt.Errorf("jj workspace forget %s: %w", name, err)
+	}
+	index, err := readIndex(root)
+	if err != nil {
+		return err
+	}
+	delete(index, name)
+	return writeIndex(root, index)
+}
+
+// recordWorkspace adds a created workspace to the index.
+func (w Workspace) recordWorkspace(repoDir, name, dir string) error {
+	root, err := w.root(repoDir)
+	if err != nil {
+		return err
+	}
+	index, err := readIndex(root)
+	if err != nil {
+		return err
+	}
+	index[name] = dir
+	return writeIndex(root, index)
+}
+
+// root returns the root of the jj workspace repoDir belongs to.
+func (w Workspace) root(repoDir string) (string, error) {
+	root, err := w.runner.Capture("jj", []string{"workspace", "root"}, repoDir)
+	if err == nil && root == "" {
+		err = fmt.Errorf("jj printed no workspace root")
+	}
+	if err != nil {
+		return "", fmt.Errorf("find jj repository: %w", err)
+	}
+	return root, nil
+}
+
+// isWorkspaceDir reports whether dir holds a jj workspace.
+func isWorkspaceDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, ".jj"))
+	return err == nil && info.IsDir()
+}
+
+// readIndex reads the name-to-directory index of the repository at root.
+func readIndex(root string) (map[string]string, error) {
+	index := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(root, ".jj", indexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read jj workspace index: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, dir, ok := strings.Cut(line, "\t"); ok {
+			index[name] = dir
+		}
+	}
+	return index, nil
+}
+
+func writeIndex(root string, index map[string]string) error {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(index)) {
+		fmt.Fprintf(&b, "%s\t%s\n", name, index[name])
+	}
+	if err := os.WriteFile(filepath.Join(root, ".jj", indexFile), []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("write jj workspace index: %w", err)
+	}
+	return nil
+}
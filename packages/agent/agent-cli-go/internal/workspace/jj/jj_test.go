@@ -2,6 +2,7 @@ package jj
 
 import (
 	"errors"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -150,3 +151,168 @@ func TestAvailable(t *testing.T) {
 		t.Error("Available() must be false when jj is absent")
 	}
 }
+
+// jjRepo makes a repository root whose jj workspace root answers root, with
+// an index recording the named directories.
+func jjRepo(t *testing.T, listing string, index map[string]string) (string, *fakeRunner) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".jj"), 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	if err := writeIndex(root, index); err != nil {
+		t.Fatalf("writeIndex() error = %v", err)
+	}
+	return root, &fakeRunner{output: map[string]string{
+		"jj workspace root":               root,
+		"jj workspace list":               listing,
+		"jj log -r @ --no-graph -T empty": "false",
+		`jj log -r heads(::@ & bookmarks()) --no-graph -T bookmarks ++ "\n"`: "feature/x* main\n",
+	}}
+}
+
+func TestList_ResolvesWorkspaceDirectories(t *testing.T) {
+	present := t.TempDir()
+	gone := filepath.Join(t.TempDir(), "gone")
+	root, runner := jjRepo(t, "agent: qpvuntsm 1234 (empty) (no description set)\ndefault: rlvkpnrz 5678 feat\ngone: zsuskuln 9abc (empty)\nstray: kkmpptxz def0 (empty)\n",
+		map[string]string{"agent": present, "gone": gone})
+
+	checkouts, err := New(runner).List(t.TempDir())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []wsshared.Checkout{
+		{Name: "default", Dir: root, Branch: "feature/x", Main: true, Dirty: true},
+		{Name: "agent", Dir: present, Branch: "feature/x", Dirty: true},
+		{Name: "gone", Dir: gone, Missing: true},
+		{Name: "stray"},
+	}
+	if len(checkouts) != len(want) {
+		t.Fatalf("List() = %+v, want %+v", checkouts, want)
+	}
+	for i := range want {
+		if checkouts[i] != want[i] {
+			t.Errorf("List()[%d] = %+v, want %+v", i, checkouts[i], want[i])
+		}
+	}
+}
+
+func TestRemove_ForgetsTheWorkspaceAndDeletesItsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	root, runner := jjRepo(t, "", map[string]string{"agent": dir, "other": "/elsewhere"})
+	workspace := New(runner)
+	checkout := wsshared.Checkout{Name: "agent", Dir: dir, Dirty: true}
+
+	if err := workspace.Remove(root, checkout, false); !errors.Is(err, wsshared.ErrDirty) {
+		t.Fatalf("Remove(dirty) error = %v, want %v", err, wsshared.ErrDirty)
+	}
+	if err := workspace.Remove(root, wsshared.Checkout{Name: "default", Dir: root, Main: true}, true); err == nil {
+		t.Fatal("Remove(default) error = nil, want a refusal")
+	}
+	if err := workspace.Remove(root, checkout, true); err != nil {
+		t.Fatalf("Remove(force) error = %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("workspace directory stat = %v, want it deleted", err)
+	}
+	if index, err := readIndex(root); err != nil || len(index) != 1 || index["other"] != "/elsewhere" {
+		t.Errorf("index = %v (%v), want only other left", index, err)
+	}
+}
+
+func TestPrune_ForgetsTheMissingWorkspaces(t *testing.T) {
+	gone := filepath.Join(t.TempDir(), "gone")
+	root, runner := jjRepo(t, "default: rlvkpnrz 5678\ngone: zsuskuln 9abc\n", map[string]string{"gone": gone})
+	runner.err = map[string]error{"jj workspace forget default": errors.New("must not forget default")}
+
+	pruned, err := New(runner).Prune(root)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].Name != "gone" {
+		t.Errorf("Prune() = %+v, want the gone workspace", pruned)
+	}
+	if index, _ := readIndex(root); len(index) != 0 {
+		t.Errorf("index = %v, want it emptied", index)
+	}
+}
+
+func TestSetup_RecordsTheCreatedWorkspace(t *testing.T) {
+	root, runner := jjRepo(t, "", nil)
+	runner.output["git rev-parse --abbrev-ref HEAD"] = "main"
+	target := filepath.Join(t.TempDir(), "agent")
+
+	if _, err := New(runner).Setup(wsshared.Config{Dir: target}, root, false); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if index, err := readIndex(root); err != nil || index["agent"] != target {
+		t.Errorf("index = %v (%v), want agent at %s", index, err, target)
+	}
+}
+
+func TestList_FindsAnUnrecordedWorkspaceNextToTheRepository(t *testing.T) {
+	root, runner := jjRepo(t, "default: rlvkpnrz 5678\nsibling: zsuskuln 9abc\n", nil)
+	sibling := filepath.Join(filepath.Dir(root), "sibling")
+	if err := os.MkdirAll(filepath.Join(sibling, ".jj"), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(sibling) })
+
+	checkouts, err := New(runner).List(root)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(checkouts) != 2 || checkouts[1].Dir != sibling {
+		t.Errorf("List() = %+v, want the sibling workspace found", checkouts)
+	}
+}
+
+func TestFailingJJCommandsAreReported(t *testing.T) {
+	failed := errors.New("exit status 1")
+	tests := map[string]struct {
+		command string
+		call    func(*Workspace, string) error
+		want    string
+	}{
+		"list root": {"jj workspace root", func(w *Workspace, root string) error {
+			_, err := w.List(root)
+			return err
+		}, "find jj repository"},
+		"list": {"jj workspace list", func(w *Workspace, root string) error {
+			_, err := w.List(root)
+			return err
+		}, "list jj workspaces"},
+		"prune": {"jj workspace list", func(w *Workspace, root string) error {
+			_, err := w.Prune(root)
+			return err
+		}, "list jj workspaces"},
+		"forget": {"jj workspace forget agent", func(w *Workspace, root string) error {
+			return w.Remove(root, wsshared.Checkout{Name: "agent"}, false)
+		}, "jj workspace forget agent"},
+		"forget root": {"jj workspace root", func(w *Workspace, root string) error {
+			return w.Remove(root, wsshared.Checkout{Name: "agent"}, false)
+		}, "find jj repository"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			root, runner := jjRepo(t, "", nil)
+			runner.err = map[string]error{tt.command: failed}
+			if err := tt.call(New(runner), root); !errors.Is(err, failed) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q wrapping the failure", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexErrorsAreReported(t *testing.T) {
+	root := t.TempDir()
+	if err := writeIndex(root, map[string]string{"agent": "/dir"}); err == nil {
+		t.Error("writeIndex(no .jj) error = nil, want a write failure")
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".jj", indexFile), 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if _, err := readIndex(root); err == nil {
+		t.Error("readIndex(directory) error = nil, want a read failure")
+	}
+}
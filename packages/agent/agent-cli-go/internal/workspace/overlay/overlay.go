@@ -0,0 +1,272 @@
+// Package overlay reads and applies what a copy-on-write run wrote: the
+// overlayfs upper directory the sandbox's writes landed in, compared with the
+// workspace it was mounted over.
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Kind is how a path of the workspace changed.
+type Kind string
+
+const (
+	Added    Kind = "added"
+	Modified Kind = "modified"
+	Deleted  Kind = "deleted"
+)
+
+// Change is one changed path, relative to the workspace. Path is slash
+// separated.
+type Change struct {
+	Path string `json:"path"`
+	Kind Kind   `json:"kind"`
+}
+
+// ErrNoChange reports a selected path that names no change of the workspace.
+var ErrNoChange = errors.New("no change")
+
+// The whiteout names fuse-overlayfs falls back to where it cannot create the
+// kernel's whiteout devices and opaque xattrs.
+const (
+	whiteoutPrefix = ".wh."
+	opaqueMarker   = ".wh..wh..opq"
+)
+
+// Changes compares upper with the workspace lower it was mounted over and
+// returns the changed paths in lexical order, so a directory precedes what it
+// holds. A file the agent copied up without changing it is not a change.
+func Changes(upper, lower string) ([]Change, error) {
+	var changes []Change
+	err := filepath.WalkDir(upper, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upper, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := d.Name()
+		if name == opaqueMarker {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if target, ok := strings.CutPrefix(name, whiteoutPrefix); ok || isWhiteout(info) {
+			if ok {
+				rel = filepath.Join(filepath.Dir(rel), target)
+			}
+			if exists(filepath.Join(lower, rel)) {
+				changes = append(changes, Change{Path: filepath.ToSlash(rel), Kind: Deleted})
+			}
+			return nil
+		}
+		lowerPath := filepath.Join(lower, rel)
+		lowerInfo, err := os.Lstat(lowerPath)
+		switch {
+		case missing(err):
+			changes = append(changes, Change{Path: filepath.ToSlash(rel), Kind: Added})
+			return nil
+		case err != nil:
+			return err
+		}
+		if !d.IsDir() {
+			if same, err := sameFile(path, info, lowerPath, lowerInfo); err != nil || same {
+				return err
+			}
+			changes = append(changes, Change{Path: filepath.ToSlash(rel), Kind: Modified})
+			return nil
+		}
+		if !lowerInfo.IsDir() {
+			changes = append(changes, Change{Path: filepath.ToSlash(rel), Kind: Modified})
+			return nil
+		}
+		if isOpaque(path) || exists(filepath.Join(path, opaqueMarker)) {
+			hidden, err := hiddenEntries(path, lowerPath)
+			if err != nil {
+				return err
+			}
+			for _, entry := range hidden {
+				changes = append(changes, Change{Path: filepath.ToSlash(filepath.Join(rel, entry)), Kind: Deleted})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read overlay changes: %w", err)
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Path, b.Path) })
+	return changes, nil
+}
+
+// Select returns the changes at or under paths; every path must name at least
+// one change.
+func Select(changes []Change, paths []string) ([]Change, error) {
+	var selected []Change
+	for _, path := range paths {
+		clean := filepath.ToSlash(filepath.Clean(path))
+		if filepath.IsAbs(path) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("%w at %s: it is not inside the workspace", ErrNoChange, path)
+		}
+		found := false
+		for _, c := range changes {
+			if c.Path == clean || strings.HasPrefix(c.Path, clean+"/") || clean == "." {
+				found = true
+				if !slices.Contains(selected, c) {
+					selected = append(selected, c)
+				}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w at %s", ErrNoChange, path)
+		}
+	}
+	slices.SortFunc(selected, func(a, b Change) int { return strings.Compare(a.Path, b.Path) })
+	return selected, nil
+}
+
+// Apply carries changes from upper into the workspace lower. The changes must
+// be in the lexical order Changes returns them in.
+func Apply(upper, lower string, changes []Change) error {
+	for _, c := range changes {
+		rel := filepath.FromSlash(c.Path)
+		dst := filepath.Join(lower, rel)
+		if c.Kind == Deleted {
+			if err := os.RemoveAll(dst); err != nil {
+				return fmt.Errorf("apply %s: %w", c.Path, err)
+			}
+			continue
+		}
+		if err := applyEntry(filepath.Join(upper, rel), dst); err != nil {
+			return fmt.Errorf("apply %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// applyEntry makes dst what src is: a directory, a symlink or a copy of a
+// regular file, replacing whatever of another type was there.
+func applyEntry(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if current, err := os.Lstat(dst); err == nil && (current.Mode().Type() != info.Mode().Type() || current.Mode()&fs.ModeSymlink != 0) {
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir():
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chmod(dst, info.Mode().Perm())
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.Mode().IsRegular():
+		return copyFile(src, dst, info.Mode().Perm())
+	}
+	return fmt.Errorf("%s is not a file, directory or symlink", src)
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, perm)
+}
+
+// Remove deletes the layers under dir. The kernel leaves overlayfs work
+// directories without permissions, so they are opened up first.
+func Remove(dir string) error {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if d != nil && d.IsDir() {
+			_ = os.Chmod(path, 0o700)
+		}
+		return nil
+	})
+	return os.RemoveAll(dir)
+}
+
+// sameFile reports whether an upper entry holds what its lower one does: the
+// same type, permissions and content or symlink target.
+func sameFile(upperPath string, upper fs.FileInfo, lowerPath string, lower fs.FileInfo) (bool, error) {
+	if upper.Mode() != lower.Mode() {
+		return false, nil
+	}
+	if upper.Mode()&fs.ModeSymlink != 0 {
+		a, err := os.Readlink(upperPath)
+		if err != nil {
+			return false, err
+		}
+		b, err := os.Readlink(lowerPath)
+		return a == b, err
+	}
+	if !upper.Mode().IsRegular() || upper.Size() != lower.Size() {
+		return false, nil
+	}
+	a, err := os.ReadFile(upperPath)
+	if err != nil {
+		return false, err
+	}
+	b, err := os.ReadFile(lowerPath)
+	return bytes.Equal(a, b), err
+}
+
+// hiddenEntries returns the entries of lowerDir an opaque upperDir hides.
+func hiddenEntries(upperDir, lowerDir string) ([]string, error) {
+	entries, err := os.ReadDir(lowerDir)
+	if err != nil {
+		return nil, err
+	}
+	var hidden []string
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(upperDir, entry.Name())); missing(err) {
+			hidden = append(hidden, entry.Name())
+		}
+	}
+	return hidden, nil
+}
+
+// missing reports an error for a path that does not exist, including one
+// under what is not a directory.
+func missing(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
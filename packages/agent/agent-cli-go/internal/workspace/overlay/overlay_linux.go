@@ -0,0 +1,27 @@
+//go:build linux
+
+package overlay
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// isWhiteout reports an overlayfs whiteout: a character device numbered 0/0.
+func isWhiteout(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && info.Mode()&fs.ModeCharDevice != 0 && st.Rdev == 0
+}
+
+// isOpaque reports an overlayfs opaque directory, which hides the lower
+// directory's entries. An overlay mounted in a user namespace marks it with a
+// user xattr instead of a trusted one.
+func isOpaque(dir string) bool {
+	value := make([]byte, 1)
+	for _, name := range []string{"trusted.overlay.opaque", "user.overlay.opaque"} {
+		if n, err := syscall.Getxattr(dir, name, value); err == nil && n == 1 && value[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestChangesReadTheKernelWhiteoutsAndOpaqueDirectories(t *testing.T) {
+	upper, lower := t.TempDir(), t.TempDir()
+	writeTree(t, lower, map[string]string{"gone.txt": "gone", "old/x": "x"})
+	writeTree(t, upper, map[string]string{"old/y": "y"})
+	if err := syscall.Mknod(filepath.Join(upper, "gone.txt"), syscall.S_IFCHR, 0); err != nil {
+		t.Skipf("cannot create a whiteout device: %v", err)
+	}
+	if err := syscall.Setxattr(filepath.Join(upper, "old"), "user.overlay.opaque", []byte("y"), 0); err != nil {
+		t.Skipf("cannot set an opaque xattr: %v", err)
+	}
+
+	changes, err := Changes(upper, lower)
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	want := []Change{{"gone.txt", Deleted}, {"old/x", Deleted}, {"old/y", Added}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Changes() = %v, want %v", changes, want)
+	}
+	if err := Apply(upper, lower, changes); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(lower, "gone.txt")); !os.IsNotExist(err) {
+		t.Errorf("gone.txt still exists (%v)", err)
+	}
+}
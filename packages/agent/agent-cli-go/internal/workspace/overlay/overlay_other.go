@@ -0,0 +1,11 @@
+//go:build !linux
+
+package overlay
+
+import "io/fs"
+
+// isWhiteout reports nothing: overlayfs whiteout devices are Linux-only.
+func isWhiteout(fs.FileInfo) bool { return false }
+
+// isOpaque reports nothing: overlayfs opaque xattrs are Linux-only.
+func isOpaque(string) bool { return false }
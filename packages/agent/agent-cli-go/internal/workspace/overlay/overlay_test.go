@@ -0,0 +1,154 @@
+package overlay
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files under root from a path -> content map; a path ending
+// in / is a directory.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if strings.HasSuffix(path, "/") {
+			if err := os.MkdirAll(full, 0o755); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+}
+
+// layers returns a workspace and the upper directory of a run over it, in the
+// fuse-overlayfs whiteout convention.
+func layers(t *testing.T) (upper, lower string) {
+	t.Helper()
+	upper, lower = t.TempDir(), t.TempDir()
+	writeTree(t, lower, map[string]string{
+		"same.txt": "same", "edited.txt": "before", "gone.txt": "gone",
+		"old/x": "x", "kept/k": "k", "file-then-dir": "file",
+	})
+	writeTree(t, upper, map[string]string{
+		"same.txt": "same", "edited.txt": "after", ".wh.gone.txt": "",
+		"new/n.txt": "n", "old/.wh..wh..opq": "", "old/y": "y",
+		".wh.never-there": "", "file-then-dir/inner": "inner",
+	})
+	return upper, lower
+}
+
+func TestChangesReadTheUpperDirectory(t *testing.T) {
+	upper, lower := layers(t)
+
+	changes, err := Changes(upper, lower)
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	want := []Change{
+		{"edited.txt", Modified},
+		{"file-then-dir", Modified},
+		{"file-then-dir/inner", Added},
+		{"gone.txt", Deleted},
+		{"new", Added},
+		{"new/n.txt", Added},
+		{"old/x", Deleted},
+		{"old/y", Added},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Changes() = %v, want %v", changes, want)
+	}
+}
+
+func TestApplyMakesTheWorkspaceWhatTheRunSaw(t *testing.T) {
+	upper, lower := layers(t)
+	changes, err := Changes(upper, lower)
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+
+	if err := Apply(upper, lower, changes); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	for path, want := range map[string]string{"edited.txt": "after", "new/n.txt": "n", "old/y": "y", "file-then-dir/inner": "inner", "kept/k": "k"} {
+		if got, err := os.ReadFile(filepath.Join(lower, path)); err != nil || string(got) != want {
+			t.Errorf("%s = (%q, %v), want %q", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"gone.txt", "old/x"} {
+		if _, err := os.Lstat(filepath.Join(lower, path)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists (%v), want it deleted", path, err)
+		}
+	}
+	if again, err := Changes(upper, lower); err != nil || len(again) != 0 {
+		t.Errorf("Changes() after Apply = (%v, %v), want none", again, err)
+	}
+}
+
+func TestSelectTakesThePathsAndWhatIsUnderThem(t *testing.T) {
+	changes := []Change{{"a.txt", Modified}, {"new", Added}, {"new/n.txt", Added}, {"newer", Added}}
+
+	got, err := Select(changes, []string{"new/", "a.txt"})
+	if want := []Change{{"a.txt", Modified}, {"new", Added}, {"new/n.txt", Added}}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Select() = (%v, %v), want %v", got, err, want)
+	}
+	if got, err := Select(changes, []string{"."}); err != nil || len(got) != len(changes) {
+		t.Errorf("Select(.) = (%v, %v), want every change", got, err)
+	}
+	if _, err := Select(changes, []string{"missing"}); !errors.Is(err, ErrNoChange) {
+		t.Errorf("Select(missing) error = %v, want no change", err)
+	}
+	if _, err := Select(changes, []string{"../outside"}); !errors.Is(err, ErrNoChange) || !strings.Contains(err.Error(), "not inside") {
+		t.Errorf("Select(../outside) error = %v, want it refused", err)
+	}
+}
+
+func TestApplyCopiesSymlinksAndPermissions(t *testing.T) {
+	upper, lower := t.TempDir(), t.TempDir()
+	writeTree(t, lower, map[string]string{"link": "was a file"})
+	if err := os.Symlink("target", filepath.Join(upper, "link")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(upper, "run.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	changes, err := Changes(upper, lower)
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Changes() = (%v, %v), want the link and the script", changes, err)
+	}
+	if err := Apply(upper, lower, changes); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(lower, "link")); err != nil || target != "target" {
+		t.Errorf("link = (%q, %v), want a symlink to target", target, err)
+	}
+	if info, err := os.Stat(filepath.Join(lower, "run.sh")); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("run.sh mode = %v (%v), want 0755", info.Mode().Perm(), err)
+	}
+}
+
+func TestRemoveDeletesLayersWithoutPermissions(t *testing.T) {
+	dir := t.TempDir()
+	locked := filepath.Join(dir, "work", "work")
+	writeTree(t, dir, map[string]string{"work/work/": "", "upper/file": "x"})
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+
+	if err := Remove(dir); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Stat() error = %v, want the layers gone", err)
+	}
+}
@@ -5,6 +5,7 @@
 package shared
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"strings"
@@ -17,11 +18,36 @@ type Config struct {
 	Dir          string
 }
 
+// ErrDirty reports a checkout with uncommitted changes, which is only removed
+// when forced.
+var ErrDirty = errors.New("checkout has uncommitted changes")
+
+// Checkout is one worktree or workspace of a repository.
+type Checkout struct {
+	// Name is the jj workspace name; git worktrees have none.
+	Name   string
+	Dir    string
+	Branch string
+	// Main marks the repository's own checkout, which is never removed.
+	Main  bool
+	Dirty bool
+	// Missing marks a checkout whose directory is gone; prune forgets it.
+	Missing bool
+}
+
 // VCS is the workspace port: create or reuse a checkout, returning its resolved
-// directory. Each variant declares its own availability.
+// directory, and list, remove and prune the checkouts of a repository. Each
+// variant declares its own availability.
 type VCS interface {
 	Setup(config Config, repoDir string, verbose bool) (string, error)
 	Available() bool
+	// List returns every checkout of the repository at repoDir, main first.
+	List(repoDir string) ([]Checkout, error)
+	// Remove deletes a checkout and its directory; a dirty one fails with
+	// ErrDirty unless force is set.
+	Remove(repoDir string, checkout Checkout, force bool) error
+	// Prune forgets the checkouts whose directory is gone and returns them.
+	Prune(repoDir string) ([]Checkout, error)
 }
 
 // Runner is the process port for this axis. Every command the workspace variants
@@ -122,7 +122,7 @@ func TestRunCommand_Agents(t *testing.T) {
 }
 
 func TestRunCommand_IsolationMethods(t *testing.T) {
-	methods := []string{"none", "bwrap", "docker"}
+	methods := []string{"none", "bwrap", "docker", "podman", "landlock", "native"}
 
 	for _, method := range methods {
 		t.Run(method, func(t *testing.T) {
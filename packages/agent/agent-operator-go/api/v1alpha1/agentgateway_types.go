@@ -0,0 +1,56 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AgentGatewaySpec defines the desired state of AgentGateway
+type AgentGatewaySpec struct {
+	// Upstream is the provider API base URL the gateway forwards to, e.g.
+	// https://api.anthropic.com.
+	// +kubebuilder:validation:Pattern=`^https?://[^/?#]+`
+	Upstream string `json:"upstream"`
+	// CredentialSecretRef references the Secret holding the provider credential.
+	// Only the gateway pod mounts it; runs receive a per-run token instead.
+	CredentialSecretRef SecretKeyRef `json:"credentialSecretRef"`
+}
+
+// AgentGatewayStatus defines the observed state of AgentGateway
+type AgentGatewayStatus struct {
+	// Conditions of the AgentGateway
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Ready indicates the gateway resources exist and its credential Secret
+	// is accessible
+	Ready bool `json:"ready,omitempty"`
+	// URL is the in-cluster base URL runs are pointed at
+	URL string `json:"url,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Upstream",type=string,JSONPath=`.spec.upstream`
+// +kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// AgentGateway is the Schema for the agentgateways API: an in-cluster
+// credential-injecting gateway that keeps a provider Secret out of agent pods.
+type AgentGateway struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AgentGatewaySpec   `json:"spec,omitempty"`
+	Status AgentGatewayStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AgentGatewayList contains a list of AgentGateway
+type AgentGatewayList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AgentGateway `json:"items"`
+}
+
+func init() {
+	registerTypes(&AgentGateway{}, &AgentGatewayList{})
+}
@@ -24,7 +24,9 @@ type AgentPolicyEnforced struct {
 	RequireNetworkPolicy bool `json:"requireNetworkPolicy,omitempty"`
 
 	// RequireEgressRestricted, if true, rejects host networking and custom egress
-	// rules whose effective destination set cannot be proven restricted.
+	// rules whose effective destination set cannot be proven restricted. none,
+	// proxy (egress through the managed allowlist proxy) and fqdn (egress
+	// restricted by DNS name) satisfy it.
 	RequireEgressRestricted bool `json:"requireEgressRestricted,omitempty"`
 
 	// MaxTimeout is the maximum allowed timeout for AgentRuns.
@@ -60,6 +62,9 @@ type AgentPolicyDefaults struct {
 
 	// RuntimeClassName is the default runtimeClassName when not set on AgentRun or AgentHarness.
 	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+
+	// EgressAllow is the default hostname allowlist when not set on AgentRun or AgentHarness.
+	EgressAllow []string `json:"egressAllow,omitempty"`
 }
 
 // AgentPolicySpec defines the desired state of AgentPolicy.
@@ -18,9 +18,19 @@ type AgentProviderSpec struct {
 	DisplayName string `json:"displayName,omitempty"`
 	// AuthTokenSecretRef references a Secret containing the auth token
 	AuthTokenSecretRef *SecretKeyRef `json:"authTokenSecretRef,omitempty"`
-	// AuthTokenEnv is the environment variable that receives AuthTokenSecretRef.
-	// A portable preset supplies this value when omitted.
+	// AuthTokenEnv is the environment variable that receives AuthTokenSecretRef,
+	// or the per-run gateway token with GatewayRef. A portable preset supplies
+	// this value when omitted.
 	AuthTokenEnv string `json:"authTokenEnv,omitempty"`
+	// GatewayRef references an AgentGateway in the namespace that holds the
+	// provider credential. Runs then receive only a short-lived per-run token
+	// and the gateway URL; mutually exclusive with AuthTokenSecretRef.
+	// +optional
+	GatewayRef string `json:"gatewayRef,omitempty"`
+	// BaseURLEnv is the environment variable that receives the gateway URL with
+	// GatewayRef. A portable preset supplies this value when omitted.
+	// +optional
+	BaseURLEnv string `json:"baseURLEnv,omitempty"`
 	// Environment variables to set when using this provider
 	Environment map[string]string `json:"environment,omitempty"`
 	// CliArgs are additional CLI arguments for the provider
@@ -46,7 +46,7 @@ const (
 )
 
 // NetworkMode is the egress axis mapped onto the per-AgentRun NetworkPolicy.
-// +kubebuilder:validation:Enum=host;none;proxy
+// +kubebuilder:validation:Enum=host;none;proxy;fqdn
 type NetworkMode string
 
 const (
@@ -54,9 +54,13 @@ const (
 	NetworkModeHost NetworkMode = "host"
 	// NetworkModeNone allows DNS only.
 	NetworkModeNone NetworkMode = "none"
-	// NetworkModeProxy is reserved for an enforceable FQDN-aware proxy backend.
-	// Admission rejects it until such a backend is configured.
+	// NetworkModeProxy allows DNS plus the namespace's operator-managed egress
+	// proxy, which tunnels only to the run's EgressAllow hostnames.
 	NetworkModeProxy NetworkMode = "proxy"
+	// NetworkModeFQDN allows DNS plus the run's EgressAllow hostnames, enforced
+	// by the cluster's FQDN-aware network plugin (CiliumNetworkPolicy toFQDNs)
+	// instead of a proxy.
+	NetworkModeFQDN NetworkMode = "fqdn"
 )
 
 // ToolchainType represents the type of toolchain
@@ -198,6 +202,8 @@ type AgentSpec struct {
 	Env []corev1.EnvVar `json:"env,omitempty"`
 	// DefaultNetworkPolicy is the default network policy for all runs
 	DefaultNetworkPolicy *AgentNetworkPolicy `json:"defaultNetworkPolicy,omitempty"`
+	// DefaultEgressAllow is the default hostname allowlist for network=proxy and network=fqdn runs
+	DefaultEgressAllow []string `json:"defaultEgressAllow,omitempty"`
 	// DefaultTTLSecondsAfterFinished is the default TTL for completed Jobs (default: 3600)
 	DefaultTTLSecondsAfterFinished *int32 `json:"defaultTtlSecondsAfterFinished,omitempty"`
 }
@@ -243,9 +249,16 @@ type AgentRunSpec struct {
 	// PodSecurityContext overrides the default pod-level security context
 	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
 	// Network is the egress axis mapped onto the per-AgentRun NetworkPolicy.
-	// host is unrestricted, none is DNS-only, and proxy is rejected until an
-	// enforceable FQDN-aware backend is available. Defaults to none.
+	// host is unrestricted, none is DNS-only, proxy reaches only the namespace
+	// egress proxy, which enforces EgressAllow, and fqdn enforces EgressAllow as
+	// a CiliumNetworkPolicy instead. Defaults to none.
 	Network NetworkMode `json:"network,omitempty"`
+	// EgressAllow is the hostname allowlist enforced for network=proxy and
+	// network=fqdn. An entry is a hostname (port 443) or hostname:port; a
+	// leading "*." matches any subdomain but not the apex (with fqdn, one label
+	// deep). Required for both; defaults from the harness or the namespace
+	// AgentPolicy.
+	EgressAllow []string `json:"egressAllow,omitempty"`
 	// NetworkPolicy configures the NetworkPolicy applied to agent pods. When set it
 	// takes precedence over Network. Defaults to deny-all egress. Set Disabled:true
 	// to skip creation.
@@ -274,6 +287,20 @@ type AgentRunStatus struct {
 	ExitCode *int32 `json:"exitCode,omitempty"`
 	// WorkspacePVC is the name of the workspace PersistentVolumeClaim
 	WorkspacePVC string `json:"workspacePVC,omitempty"`
+	// GatewayToken records the per-run AgentGateway token minted at Job creation
+	GatewayToken *GatewayTokenStatus `json:"gatewayToken,omitempty"`
+}
+
+// GatewayTokenStatus records a run's AgentGateway token. The token itself is
+// only in the agent Job; the status tracks its lifetime.
+type GatewayTokenStatus struct {
+	// Gateway is the AgentGateway the token is valid for
+	Gateway string `json:"gateway"`
+	// ExpiresAt is when the token stops verifying, revoked or not
+	ExpiresAt metav1.Time `json:"expiresAt"`
+	// Revoked reports that the token was revoked when the run reached a
+	// terminal phase
+	Revoked bool `json:"revoked,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -15,6 +15,7 @@ const maxFillDepth = 8
 // deep copies the API server relies on.
 func retainedRootTypes() []runtime.Object {
 	return []runtime.Object{
+		&AgentGateway{}, &AgentGatewayList{},
 		&AgentHarness{}, &AgentHarnessList{},
 		&AgentPolicy{}, &AgentPolicyList{},
 		&AgentProvider{}, &AgentProviderList{},
@@ -12,6 +12,8 @@ func TestAddToSchemeRegistersRetainedTypes(t *testing.T) {
 		kind     string
 		wantType runtime.Object
 	}{
+		{kind: "AgentGateway", wantType: &AgentGateway{}},
+		{kind: "AgentGatewayList", wantType: &AgentGatewayList{}},
 		{kind: "AgentHarness", wantType: &AgentHarness{}},
 		{kind: "AgentHarnessList", wantType: &AgentHarnessList{}},
 		{kind: "AgentPolicy", wantType: &AgentPolicy{}},
@@ -46,6 +48,8 @@ func TestAddToSchemeRegistersRetainedTypes(t *testing.T) {
 
 func TestRetainedTypesSupportRuntimeDeepCopy(t *testing.T) {
 	objects := []runtime.Object{
+		&AgentGateway{},
+		&AgentGatewayList{},
 		&AgentHarness{},
 		&AgentHarnessList{},
 		&AgentPolicy{},
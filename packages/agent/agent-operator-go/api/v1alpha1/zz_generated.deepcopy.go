@@ -261,6 +261,11 @@ func (in *AgentSpec) DeepCopyInto(out *AgentSpec) {
 		*out = new(AgentNetworkPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.DefaultEgressAllow != nil {
+		in, out := &in.DefaultEgressAllow, &out.DefaultEgressAllow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.DefaultTTLSecondsAfterFinished != nil {
 		in, out := &in.DefaultTTLSecondsAfterFinished, &out.DefaultTTLSecondsAfterFinished
 		*out = new(int32)
@@ -347,6 +352,11 @@ func (in *AgentRunSpec) DeepCopyInto(out *AgentRunSpec) {
 		*out = new(AgentNetworkPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.EgressAllow != nil {
+		in, out := &in.EgressAllow, &out.EgressAllow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.TTLSecondsAfterFinished != nil {
 		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
 		*out = new(int32)
@@ -387,6 +397,11 @@ func (in *AgentRunStatus) DeepCopyInto(out *AgentRunStatus) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.GatewayToken != nil {
+		in, out := &in.GatewayToken, &out.GatewayToken
+		*out = new(GatewayTokenStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentRunStatus.
@@ -399,6 +414,22 @@ func (in *AgentRunStatus) DeepCopy() *AgentRunStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayTokenStatus) DeepCopyInto(out *GatewayTokenStatus) {
+	*out = *in
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayTokenStatus.
+func (in *GatewayTokenStatus) DeepCopy() *GatewayTokenStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayTokenStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AgentRun) DeepCopyInto(out *AgentRun) {
 	*out = *in
@@ -571,6 +602,103 @@ func (in *AgentProviderList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentGatewaySpec) DeepCopyInto(out *AgentGatewaySpec) {
+	*out = *in
+	out.CredentialSecretRef = in.CredentialSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentGatewaySpec.
+func (in *AgentGatewaySpec) DeepCopy() *AgentGatewaySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentGatewaySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentGatewayStatus) DeepCopyInto(out *AgentGatewayStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentGatewayStatus.
+func (in *AgentGatewayStatus) DeepCopy() *AgentGatewayStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentGatewayStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentGateway) DeepCopyInto(out *AgentGateway) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentGateway.
+func (in *AgentGateway) DeepCopy() *AgentGateway {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentGateway)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AgentGateway) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentGatewayList) DeepCopyInto(out *AgentGatewayList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AgentGateway, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentGatewayList.
+func (in *AgentGatewayList) DeepCopy() *AgentGatewayList {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentGatewayList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AgentGatewayList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SharedVolumeSpec) DeepCopyInto(out *SharedVolumeSpec) {
 	*out = *in
@@ -935,6 +1063,11 @@ func (in *AgentPolicyDefaults) DeepCopyInto(out *AgentPolicyDefaults) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.EgressAllow != nil {
+		in, out := &in.EgressAllow, &out.EgressAllow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentPolicyDefaults.
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provider"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provider/gateway"
+)
+
+func main() {
+	var listen, namespace, name, upstream, keyFile, revokedFile, credentialFile string
+	var reload time.Duration
+	var verbose bool
+
+	flag.StringVar(&listen, "listen", fmt.Sprintf(":%d", provider.GatewayPort), "The address the gateway listens on.")
+	flag.StringVar(&namespace, "namespace", "", "The namespace whose run tokens the gateway accepts.")
+	flag.StringVar(&name, "gateway", "", "The AgentGateway whose run tokens the gateway accepts.")
+	flag.StringVar(&upstream, "upstream", "", "The provider API base URL requests are forwarded to.")
+	flag.StringVar(&keyFile, "key-file", provider.GatewayKeyDir+"/"+provider.GatewayKeyName, "The file holding the token signing key.")
+	flag.StringVar(&revokedFile, "revoked-file", provider.GatewayKeyDir+"/"+provider.GatewayRevokedName, "The file holding the token revocation list.")
+	flag.StringVar(&credentialFile, "credential-file", provider.GatewayCredentialDir+"/"+provider.GatewayCredentialName, "The file holding the provider credential.")
+	flag.DurationVar(&reload, "reload-interval", 10*time.Second, "How often the revocation list is reloaded.")
+	flag.BoolVar(&verbose, "verbose", false, "Log every refused request.")
+
+	opts := zap.Options{Development: true}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("agent-gateway")
+	if namespace == "" || name == "" || upstream == "" {
+		log.Error(nil, "--namespace, --gateway and --upstream are required")
+		os.Exit(1)
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		log.Error(err, "unable to read the token signing key", "path", keyFile)
+		os.Exit(1)
+	}
+	credential, err := os.ReadFile(credentialFile)
+	if err != nil {
+		log.Error(err, "unable to read the provider credential", "path", credentialFile)
+		os.Exit(1)
+	}
+
+	g := gateway.New(key, namespace, name)
+	if err := g.LoadRevoked(revokedFile); err != nil {
+		log.Error(err, "unable to load the revocation list", "path", revokedFile)
+		os.Exit(1)
+	}
+	server, err := g.Server(upstream, strings.TrimSpace(string(credential)))
+	if err != nil {
+		log.Error(err, "unable to create the gateway")
+		os.Exit(1)
+	}
+	if verbose {
+		server.WithLogger(func(message string) { log.Info(message) })
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Error(err, "unable to listen", "address", listen)
+		os.Exit(1)
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+	go func() {
+		ticker := time.NewTicker(reload)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = server.Close()
+				return
+			case <-ticker.C:
+				if err := g.LoadRevoked(revokedFile); err != nil {
+					log.Error(err, "unable to reload the revocation list", "path", revokedFile)
+				}
+			}
+		}
+	}()
+	log.Info("serving agent gateway", "address", listen, "namespace", namespace, "gateway", name, "upstream", upstream)
+	if err := server.Serve(listener); err != nil {
+		log.Error(err, "agent gateway stopped")
+		os.Exit(1)
+	}
+}
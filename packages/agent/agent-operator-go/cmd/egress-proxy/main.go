@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/proxy"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
+)
+
+func main() {
+	var listen, namespace, keyFile string
+	var verbose bool
+
+	flag.StringVar(&listen, "listen", fmt.Sprintf(":%d", netshared.EgressProxyPort), "The address the egress proxy listens on.")
+	flag.StringVar(&namespace, "namespace", "", "The namespace whose run credentials the proxy accepts.")
+	flag.StringVar(&keyFile, "key-file", netshared.EgressProxyKeyDir+"/"+netshared.EgressProxyKeyName, "The file holding the credential signing key.")
+	flag.BoolVar(&verbose, "verbose", false, "Log every tunnel decision.")
+
+	opts := zap.Options{Development: true}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	log := ctrl.Log.WithName("egress-proxy")
+	if namespace == "" {
+		log.Error(nil, "--namespace is required")
+		os.Exit(1)
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		log.Error(err, "unable to read the credential signing key", "path", keyFile)
+		os.Exit(1)
+	}
+
+	server := proxy.NewServer(key, namespace)
+	if verbose {
+		server.WithLogger(func(message string) { log.Info(message) })
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Error(err, "unable to listen", "address", listen)
+		os.Exit(1)
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	log.Info("serving egress proxy", "address", listen, "namespace", namespace)
+	if err := server.Serve(listener); err != nil {
+		log.Error(err, "egress proxy stopped")
+		os.Exit(1)
+	}
+}
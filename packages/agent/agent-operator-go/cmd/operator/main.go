@@ -21,10 +21,14 @@ func main() {
 	var probeAddr string
 	var enableLeaderElection bool
 	var workspaceInitImage string
+	var egressProxyImage string
+	var gatewayImage string
 
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager.")
 	flag.StringVar(&workspaceInitImage, "workspace-init-image", controller.DefaultWorkspaceInitImage, "Digest-pinned image used to initialize shared workspaces.")
+	flag.StringVar(&egressProxyImage, "egress-proxy-image", "", "Digest-pinned operator image that runs the per-namespace egress proxy for network=proxy runs; empty disables network=proxy.")
+	flag.StringVar(&gatewayImage, "agent-gateway-image", "", "Digest-pinned operator image that runs AgentGateways; empty leaves every AgentGateway not ready.")
 
 	opts := zap.Options{Development: true}
 	opts.BindFlags(flag.CommandLine)
@@ -41,6 +45,18 @@ func main() {
 		setupLog.Error(err, "invalid workspace init image", "image", workspaceInitImage)
 		os.Exit(1)
 	}
+	if egressProxyImage != "" {
+		if err := validator.ValidatePinnedImageReference(egressProxyImage); err != nil {
+			setupLog.Error(err, "invalid egress proxy image", "image", egressProxyImage)
+			os.Exit(1)
+		}
+	}
+	if gatewayImage != "" {
+		if err := validator.ValidatePinnedImageReference(gatewayImage); err != nil {
+			setupLog.Error(err, "invalid agent gateway image", "image", gatewayImage)
+			os.Exit(1)
+		}
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
@@ -54,9 +70,10 @@ func main() {
 	}
 
 	if err = (&controller.AgentRunReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorder("agent-operator"),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		Recorder:         mgr.GetEventRecorder("agent-operator"),
+		EgressProxyImage: egressProxyImage,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AgentRun")
 		os.Exit(1)
@@ -71,6 +88,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = (&controller.AgentGatewayReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Recorder:     mgr.GetEventRecorder("agent-operator"),
+		GatewayImage: gatewayImage,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AgentGateway")
+		os.Exit(1)
+	}
+
 	if err = (&controller.AgentWorkspaceReconciler{
 		Client:             mgr.GetClient(),
 		Scheme:             mgr.GetScheme(),
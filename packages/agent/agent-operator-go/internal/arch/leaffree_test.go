@@ -65,6 +65,8 @@ func TestSharedCoresAreLeafFree(t *testing.T) {
 		opModule + "/internal/workspace/jj":     true,
 		opModule + "/internal/harness/claude":   true,
 		opModule + "/internal/harness/opencode": true,
+		opModule + "/internal/network/proxy":    true,
+		opModule + "/internal/network/cilium":   true,
 	}
 	// The composition root is the only package permitted to reach the leaves, so a
 	// port importing it would transitively acquire a leaf — also a violation.
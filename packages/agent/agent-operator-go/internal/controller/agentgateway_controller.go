@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provider"
+)
+
+// AgentGatewayReconciler reconciles an AgentGateway object
+type AgentGatewayReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+	// GatewayImage is the digest-pinned image gateways run (the operator
+	// image). Gateways stay not ready while it is empty.
+	GatewayImage string
+}
+
+// +kubebuilder:rbac:groups=agent.xonovex.com,resources=agentgateways,verbs=get;list;watch
+// +kubebuilder:rbac:groups=agent.xonovex.com,resources=agentgateways/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+func (r *AgentGatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues(
+		"gateway", req.Name,
+		"namespace", req.Namespace,
+	)
+
+	var gateway agentv1alpha1.AgentGateway
+	if err := r.Get(ctx, req.NamespacedName, &gateway); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if !gateway.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	ready, reason, message := false, "GatewayImageMissing", "the operator has no --agent-gateway-image configured"
+	if r.GatewayImage != "" {
+		if err := r.ensureGatewayResources(ctx, &gateway); err != nil {
+			return ctrl.Result{}, err
+		}
+		credentialReady, err := r.credentialReady(ctx, &gateway)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		ready, reason, message = true, "GatewayReady", "Gateway is ready"
+		if !credentialReady {
+			ready, reason, message = false, "SecretValidation", "Referenced credential secret or key not found"
+			log.Info("credential secret or key not found", "secret", gateway.Spec.CredentialSecretRef.Name)
+		}
+	}
+
+	conditionStatus := metav1.ConditionFalse
+	if ready {
+		conditionStatus = metav1.ConditionTrue
+	}
+	previous := meta.FindStatusCondition(gateway.Status.Conditions, "Ready")
+	reportTransition := previous == nil || previous.Status != conditionStatus || previous.Reason != reason
+
+	desired := gateway.DeepCopy()
+	desired.Status.Ready = ready
+	desired.Status.URL = provider.GatewayURL(gateway.Namespace, gateway.Name)
+	meta.SetStatusCondition(&desired.Status.Conditions, metav1.Condition{
+		Type:               "Ready",
+		Status:             conditionStatus,
+		ObservedGeneration: gateway.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	if err := r.updateGatewayStatus(ctx, desired); err != nil {
+		return ctrl.Result{}, err
+	}
+	if reportTransition && r.Recorder != nil {
+		eventType := corev1.EventTypeWarning
+		if ready {
+			eventType = corev1.EventTypeNormal
+		}
+		r.Recorder.Eventf(desired, nil, eventType, reason, reason, "%s", message)
+	}
+	return ctrl.Result{}, nil
+}
+
+// ensureGatewayResources ensures the gateway's key Secret, Deployment, Service
+// and NetworkPolicy. Unlike the namespace egress proxy they belong to one
+// AgentGateway and are garbage-collected with it. The key is never rotated, so
+// tokens already handed out stay valid; the rest is reconciled back to the
+// desired spec so an operator upgrade rolls the gateway image.
+func (r *AgentGatewayReconciler) ensureGatewayResources(ctx context.Context, gateway *agentv1alpha1.AgentGateway) error {
+	secret := provider.BuildGatewayKeySecret(gateway, provider.NewGatewayKey())
+	var existingSecret corev1.Secret
+	if err := r.ensureGatewayObject(ctx, gateway, secret, &existingSecret, func() bool { return false }); err != nil {
+		return err
+	}
+
+	deployment := provider.BuildGatewayDeployment(gateway, r.GatewayImage)
+	var existingDeployment appsv1.Deployment
+	if err := r.ensureGatewayObject(ctx, gateway, deployment, &existingDeployment, func() bool {
+		if equality.Semantic.DeepDerivative(deployment.Spec, existingDeployment.Spec) {
+			return false
+		}
+		existingDeployment.Spec = deployment.Spec
+		return true
+	}); err != nil {
+		return err
+	}
+	service := provider.BuildGatewayService(gateway)
+	var existingService corev1.Service
+	if err := r.ensureGatewayObject(ctx, gateway, service, &existingService, func() bool {
+		if equality.Semantic.DeepDerivative(service.Spec, existingService.Spec) {
+			return false
+		}
+		existingService.Spec.Selector = service.Spec.Selector
+		existingService.Spec.Ports = service.Spec.Ports
+		return true
+	}); err != nil {
+		return err
+	}
+	policy := provider.BuildGatewayNetworkPolicy(gateway)
+	var existingPolicy networkingv1.NetworkPolicy
+	return r.ensureGatewayObject(ctx, gateway, policy, &existingPolicy, func() bool {
+		if equality.Semantic.DeepDerivative(policy.Spec, existingPolicy.Spec) {
+			return false
+		}
+		existingPolicy.Spec = policy.Spec
+		return true
+	})
+}
+
+// ensureGatewayObject creates desired owned by gateway, or loads it into
+// existing and applies sync when it already exists. A same-named object the
+// gateway does not control is never overwritten.
+func (r *AgentGatewayReconciler) ensureGatewayObject(ctx context.Context, gateway *agentv1alpha1.AgentGateway, desired, existing client.Object, sync func() bool) error {
+	kind := fmt.Sprintf("%T", desired)[1:]
+	if err := ctrl.SetControllerReference(gateway, desired, r.Scheme); err != nil {
+		return err
+	}
+	err := r.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("create agent gateway %s: %w", kind, err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		return fmt.Errorf("get agent gateway %s: %w", kind, err)
+	}
+	if !metav1.IsControlledBy(existing, gateway) {
+		return fmt.Errorf("existing %s %q is not controlled by AgentGateway %q", kind, desired.GetName(), gateway.Name)
+	}
+	if sync() {
+		if err := r.Update(ctx, existing); err != nil {
+			return fmt.Errorf("update agent gateway %s: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+func (r *AgentGatewayReconciler) credentialReady(ctx context.Context, gateway *agentv1alpha1.AgentGateway) (bool, error) {
+	ref := gateway.Spec.CredentialSecretRef
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: gateway.Namespace}, &secret); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	_, ok := secret.Data[ref.Key]
+	return ok, nil
+}
+
+func (r *AgentGatewayReconciler) updateGatewayStatus(ctx context.Context, gateway *agentv1alpha1.AgentGateway) error {
+	desired := gateway.DeepCopy().Status
+	key := client.ObjectKeyFromObject(gateway)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var current agentv1alpha1.AgentGateway
+		if err := r.Get(ctx, key, &current); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if equality.Semantic.DeepEqual(current.Status, desired) {
+			*gateway = current
+			return nil
+		}
+		current.Status = desired
+		if err := r.Status().Update(ctx, &current); err != nil {
+			return err
+		}
+		*gateway = current
+		return nil
+	})
+}
+
+func (r *AgentGatewayReconciler) gatewaysForSecret(ctx context.Context, object client.Object) []reconcile.Request {
+	secret, ok := object.(*corev1.Secret)
+	if !ok {
+		return nil
+	}
+
+	var gateways agentv1alpha1.AgentGatewayList
+	if err := r.List(ctx, &gateways, client.InNamespace(secret.Namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "list gateways for secret", "secret", client.ObjectKeyFromObject(secret))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for i := range gateways.Items {
+		gateway := &gateways.Items[i]
+		if gateway.Spec.CredentialSecretRef.Name == secret.Name {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(gateway)})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager watches the gateway's own resources and the credential
+// Secrets gateways reference.
+func (r *AgentGatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&agentv1alpha1.AgentGateway{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.gatewaysForSecret)).
+		Complete(r)
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provider"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/test/testutil"
+)
+
+func newGatewayReconciler(objects ...client.Object) (*AgentGatewayReconciler, client.Client) {
+	scheme := testutil.NewScheme()
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&agentv1alpha1.AgentGateway{}).
+		WithObjects(objects...).
+		Build()
+	return &AgentGatewayReconciler{
+		Client:       fakeClient,
+		Scheme:       scheme,
+		Recorder:     events.NewFakeRecorder(20),
+		GatewayImage: testEgressProxyImage,
+	}, fakeClient
+}
+
+func reconcileGateway(t *testing.T, reconciler *AgentGatewayReconciler, name string) agentv1alpha1.AgentGateway {
+	t.Helper()
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	if _, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	var gateway agentv1alpha1.AgentGateway
+	if err := reconciler.Get(ctx, key, &gateway); err != nil {
+		t.Fatalf("Get gateway: %v", err)
+	}
+	return gateway
+}
+
+func gatewayObjectKey(name string) types.NamespacedName {
+	return types.NamespacedName{Namespace: "default", Name: provider.GatewayResourceName(name)}
+}
+
+func TestAgentGatewayReconcile_ProvisionsTheGatewayAndReportsItsURL(t *testing.T) {
+	reconciler, c := newGatewayReconciler(
+		testutil.NewAgentGateway("default", "anthropic", "https://api.anthropic.com", "anthropic-credential", "api-key"),
+		testutil.NewSecret("default", "anthropic-credential", map[string][]byte{"api-key": []byte("sk-real")}),
+	)
+
+	gateway := reconcileGateway(t, reconciler, "anthropic")
+
+	if !gateway.Status.Ready || gateway.Status.URL != "http://anthropic-gateway.default.svc:8080" {
+		t.Errorf("status = %+v, want ready at the gateway Service URL", gateway.Status)
+	}
+	ctx := context.Background()
+	var secret corev1.Secret
+	if err := c.Get(ctx, gatewayObjectKey("anthropic"), &secret); err != nil {
+		t.Fatalf("key Secret was not created: %v", err)
+	}
+	if _, err := provider.GatewayKey(&secret); err != nil {
+		t.Errorf("GatewayKey() error = %v", err)
+	}
+	var deployment appsv1.Deployment
+	if err := c.Get(ctx, gatewayObjectKey("anthropic"), &deployment); err != nil {
+		t.Fatalf("Deployment was not created: %v", err)
+	}
+	if !metav1.IsControlledBy(&deployment, &gateway) {
+		t.Error("Deployment is not owned by the AgentGateway")
+	}
+	if err := c.Get(ctx, gatewayObjectKey("anthropic"), &corev1.Service{}); err != nil {
+		t.Errorf("Service was not created: %v", err)
+	}
+	if err := c.Get(ctx, gatewayObjectKey("anthropic"), &networkingv1.NetworkPolicy{}); err != nil {
+		t.Errorf("NetworkPolicy was not created: %v", err)
+	}
+
+	// A second pass keeps the key, so tokens already minted stay valid.
+	reconcileGateway(t, reconciler, "anthropic")
+	var again corev1.Secret
+	if err := c.Get(ctx, gatewayObjectKey("anthropic"), &again); err != nil {
+		t.Fatalf("get key Secret: %v", err)
+	}
+	if string(again.Data[provider.GatewayKeyName]) != string(secret.Data[provider.GatewayKeyName]) {
+		t.Error("the gateway key changed on a second reconcile")
+	}
+}
+
+func TestAgentGatewayReconcile_RepairsADriftedDeployment(t *testing.T) {
+	gateway := testutil.NewAgentGateway("default", "anthropic", "https://api.anthropic.com", "anthropic-credential", "api-key")
+	reconciler, c := newGatewayReconciler(gateway,
+		testutil.NewSecret("default", "anthropic-credential", map[string][]byte{"api-key": []byte("sk-real")}))
+	reconcileGateway(t, reconciler, "anthropic")
+
+	ctx := context.Background()
+	var deployment appsv1.Deployment
+	if err := c.Get(ctx, gatewayObjectKey("anthropic"), &deployment); err != nil {
+		t.Fatalf("get Deployment: %v", err)
+	}
+	deployment.Spec.Template.Spec.Containers[0].Image = "ghcr.io/example/stale@sha256:" + strings.Repeat("0", 64)
+	if err := c.Update(ctx, &deployment); err != nil {
+		t.Fatalf("update Deployment: %v", err)
+	}
+
+	reconcileGateway(t, reconciler, "anthropic")
+	if err := c.Get(ctx, gatewayObjectKey("anthropic"), &deployment); err != nil {
+		t.Fatalf("get Deployment: %v", err)
+	}
+	if got := deployment.Spec.Template.Spec.Containers[0].Image; got != testEgressProxyImage {
+		t.Errorf("image = %q, want it restored to %q", got, testEgressProxyImage)
+	}
+}
+
+func TestAgentGatewayReconcile_NotReadyWithoutTheCredentialOrAnImage(t *testing.T) {
+	gateway := testutil.NewAgentGateway("default", "anthropic", "https://api.anthropic.com", "anthropic-credential", "api-key")
+	reconciler, _ := newGatewayReconciler(gateway)
+
+	if status := reconcileGateway(t, reconciler, "anthropic").Status; status.Ready {
+		t.Error("gateway without its credential Secret reported ready")
+	}
+
+	reconciler.GatewayImage = ""
+	status := reconcileGateway(t, reconciler, "anthropic").Status
+	if status.Ready || len(status.Conditions) != 1 || status.Conditions[0].Reason != "GatewayImageMissing" {
+		t.Errorf("status = %+v, want not ready for a missing gateway image", status)
+	}
+}
+
+// A same-named object the gateway does not own is never taken over.
+func TestAgentGatewayReconcile_RefusesAForeignService(t *testing.T) {
+	foreign := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "anthropic-gateway"}}
+	reconciler, _ := newGatewayReconciler(
+		testutil.NewAgentGateway("default", "anthropic", "https://api.anthropic.com", "anthropic-credential", "api-key"),
+		foreign,
+	)
+
+	_, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "anthropic"}})
+	if err == nil || !strings.Contains(err.Error(), "not controlled by AgentGateway") {
+		t.Fatalf("Reconcile() error = %v, want a refusal to adopt the foreign Service", err)
+	}
+}
+
+func TestAgentGatewayReconcile_IgnoresAnAbsentGateway(t *testing.T) {
+	reconciler, _ := newGatewayReconciler()
+	if _, err := reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "absent"}}); err != nil {
+		t.Errorf("Reconcile() error = %v, want nil", err)
+	}
+}
+
+func TestGatewaysForSecret_EnqueuesGatewaysUsingTheSecret(t *testing.T) {
+	reconciler, _ := newGatewayReconciler(
+		testutil.NewAgentGateway("default", "anthropic", "https://api.anthropic.com", "anthropic-credential", "api-key"),
+		testutil.NewAgentGateway("default", "openai", "https://api.openai.com", "openai-credential", "api-key"),
+	)
+
+	requests := reconciler.gatewaysForSecret(context.Background(), testutil.NewSecret("default", "anthropic-credential", nil))
+	if len(requests) != 1 || requests[0].Name != "anthropic" {
+		t.Errorf("requests = %v, want the anthropic gateway only", requests)
+	}
+	if requests := reconciler.gatewaysForSecret(context.Background(), &corev1.ConfigMap{}); requests != nil {
+		t.Errorf("requests for a non-Secret = %v, want nil", requests)
+	}
+}
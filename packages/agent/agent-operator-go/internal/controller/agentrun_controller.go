@@ -6,12 +6,14 @@ import (
 	"strings"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/events"
@@ -34,6 +36,9 @@ type AgentRunReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder events.EventRecorder
+	// EgressProxyImage is the digest-pinned image the per-namespace egress proxy
+	// runs (the operator image). network=proxy runs fail while it is empty.
+	EgressProxyImage string
 }
 
 // +kubebuilder:rbac:groups=agent.xonovex.com,resources=agentruns,verbs=get;list;watch;create;update;patch;delete
@@ -46,8 +51,12 @@ type AgentRunReconciler struct {
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=agent.xonovex.com,resources=agentgateways,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cilium.io,resources=ciliumnetworkpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *AgentRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -63,11 +72,11 @@ func (r *AgentRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, nil
 	}
 
-	// Skip if already completed
+	// Skip if already completed, once its gateway token is revoked
 	if agentRun.Status.Phase == agentv1alpha1.AgentRunPhaseSucceeded ||
 		agentRun.Status.Phase == agentv1alpha1.AgentRunPhaseFailed ||
 		agentRun.Status.Phase == agentv1alpha1.AgentRunPhaseTimedOut {
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, r.revokeGatewayToken(ctx, &agentRun)
 	}
 
 	// Branch based on workspace mode
@@ -82,6 +91,8 @@ type resolvedRunExecution struct {
 	agentType       agentv1alpha1.AgentType
 	providerEnv     []corev1.EnvVar
 	providerCliArgs []string
+	gateway         string
+	gatewayTokenEnv string
 	toolchain       *agentv1alpha1.ToolchainSpec
 	defaults        resolver.ResolvedDefaults
 	image           string
@@ -124,6 +135,8 @@ func (r *AgentRunReconciler) resolveRunExecution(ctx context.Context, run *agent
 		agentType:       agentType,
 		providerEnv:     resolvedProvider.Environment,
 		providerCliArgs: resolvedProvider.CliArgs,
+		gateway:         resolvedProvider.Gateway,
+		gatewayTokenEnv: resolvedProvider.TokenEnv,
 		toolchain:       toolchain,
 		defaults:        defaults,
 		image:           image,
@@ -139,9 +152,35 @@ func (r *AgentRunReconciler) reconcileExecutionResources(
 	binding *isoshared.WorkspaceBinding,
 ) (ctrl.Result, error) {
 	logger := log.FromContext(ctx).WithValues("agentRun", run.Name, "namespace", run.Namespace)
+	providerEnv := execution.providerEnv
+	if run.Spec.Network == agentv1alpha1.NetworkModeProxy {
+		credential, err := r.ensureEgressProxy(ctx, run.Namespace)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if credential == nil {
+			return r.updatePhase(ctx, run, agentv1alpha1.AgentRunPhaseFailed,
+				"EgressProxyUnavailable: the operator has no --egress-proxy-image configured for network=proxy")
+		}
+		signed := netshared.SignProxyCredential(credential, run.Namespace, run.Name, run.Spec.EgressAllow)
+		providerEnv = append(append([]corev1.EnvVar{}, providerEnv...), netshared.ProxyEnv(run.Namespace, run.Name, signed)...)
+	}
+	var peers []netshared.PodPeer
+	if execution.gateway != "" {
+		peers = append(peers, provider.GatewayPeer(execution.gateway))
+	}
 	networkPolicy := execution.defaults.NetworkPolicy
-	if networkPolicy == nil || !networkPolicy.Disabled {
-		resource, err := netshared.BuildNetworkPolicy(run, networkPolicy)
+	if run.Spec.Network == agentv1alpha1.NetworkModeFQDN {
+		realizer := netshared.ResolveFQDNPolicyRealizer(r.RESTMapper(), plugins.FQDNPolicyRealizers())
+		if realizer == nil {
+			return r.updatePhase(ctx, run, agentv1alpha1.AgentRunPhaseFailed,
+				fmt.Sprintf("FQDNPolicyUnavailable: %v", netshared.ErrFQDNPolicyUnavailable))
+		}
+		if err := r.ensureFQDNPolicy(ctx, run, realizer, peers...); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if networkPolicy == nil || !networkPolicy.Disabled {
+		resource, err := netshared.BuildNetworkPolicy(run, networkPolicy, peers...)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
@@ -170,9 +209,20 @@ func (r *AgentRunReconciler) reconcileExecutionResources(
 		if err := ensureAgentServiceAccount(ctx, r.Client, run.Namespace); err != nil {
 			return ctrl.Result{}, err
 		}
+		if execution.gateway != "" {
+			tokenEnv, err := r.mintGatewayToken(ctx, run, execution)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if tokenEnv == nil {
+				logger.Info("gateway not ready, requeuing", "gateway", execution.gateway)
+				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			}
+			providerEnv = append(append([]corev1.EnvVar{}, providerEnv...), *tokenEnv)
+		}
 		job, err := isoshared.BuildJob(
 			run,
-			execution.providerEnv,
+			providerEnv,
 			execution.providerCliArgs,
 			pvcName,
 			execution.image,
@@ -287,6 +337,191 @@ func (r *AgentRunReconciler) reconcileStandalone(ctx context.Context, agentRun *
 	return r.reconcileExecutionResources(ctx, agentRun, execution, pvcName, wsType, nil)
 }
 
+// ensureFQDNPolicy creates the run's FQDN-aware network policy in place of the
+// NetworkPolicy. An existing policy must be the run's own and match the
+// admitted allowlist.
+func (r *AgentRunReconciler) ensureFQDNPolicy(ctx context.Context, run *agentv1alpha1.AgentRun, realizer netshared.FQDNPolicyRealizer, peers ...netshared.PodPeer) error {
+	resource, err := realizer.BuildPolicy(run, peers...)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.SetControllerReference(run, resource, r.Scheme); err != nil {
+		return err
+	}
+	kind := resource.GetKind()
+	if err := r.Create(ctx, resource); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("create %s: %w", kind, err)
+		}
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(resource.GroupVersionKind())
+		if err := r.Get(ctx, client.ObjectKeyFromObject(resource), existing); err != nil {
+			return fmt.Errorf("get existing %s: %w", kind, err)
+		}
+		if err := verifyControlledResource(run, existing, resource.Object["spec"], existing.Object["spec"]); err != nil {
+			return fmt.Errorf("existing %s %q: %w", kind, resource.GetName(), err)
+		}
+		return nil
+	}
+	r.Recorder.Eventf(run, nil, corev1.EventTypeNormal, "NetworkPolicyCreated", "NetworkPolicyCreated",
+		"Created %s %s", kind, resource.GetName())
+	return nil
+}
+
+// ensureEgressProxy ensures the namespace's egress proxy (key Secret,
+// Deployment, Service and the proxy's own NetworkPolicy) and returns the key run
+// credentials are signed with, or nil when no proxy image is configured. The
+// proxy is shared by every network=proxy run in the namespace, so like the agent
+// ServiceAccount it has no owner reference; it is reconciled back to the
+// desired spec so an operator upgrade rolls the proxy image.
+func (r *AgentRunReconciler) ensureEgressProxy(ctx context.Context, namespace string) ([]byte, error) {
+	if r.EgressProxyImage == "" {
+		return nil, nil
+	}
+
+	secret := netshared.BuildEgressProxySecret(namespace, netshared.NewEgressProxyKey())
+	if err := r.Create(ctx, secret); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("create egress proxy Secret: %w", err)
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+			return nil, fmt.Errorf("get egress proxy Secret: %w", err)
+		}
+	}
+	key, err := netshared.EgressProxyKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := netshared.BuildEgressProxyDeployment(namespace, r.EgressProxyImage)
+	var existingDeployment appsv1.Deployment
+	if err := r.ensureEgressProxyObject(ctx, deployment, &existingDeployment, func() bool {
+		if equality.Semantic.DeepDerivative(deployment.Spec, existingDeployment.Spec) {
+			return false
+		}
+		existingDeployment.Spec = deployment.Spec
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	service := netshared.BuildEgressProxyService(namespace)
+	var existingService corev1.Service
+	if err := r.ensureEgressProxyObject(ctx, service, &existingService, func() bool {
+		if equality.Semantic.DeepDerivative(service.Spec, existingService.Spec) {
+			return false
+		}
+		existingService.Spec.Selector = service.Spec.Selector
+		existingService.Spec.Ports = service.Spec.Ports
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	policy := netshared.BuildEgressProxyNetworkPolicy(namespace)
+	var existingPolicy networkingv1.NetworkPolicy
+	if err := r.ensureEgressProxyObject(ctx, policy, &existingPolicy, func() bool {
+		if equality.Semantic.DeepDerivative(policy.Spec, existingPolicy.Spec) {
+			return false
+		}
+		existingPolicy.Spec = policy.Spec
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ensureEgressProxyObject creates desired, or loads it into existing and applies
+// sync when it already exists. A same-named object without the proxy component
+// label is not the operator's and is never overwritten.
+func (r *AgentRunReconciler) ensureEgressProxyObject(ctx context.Context, desired, existing client.Object, sync func() bool) error {
+	kind := fmt.Sprintf("%T", desired)[1:]
+	err := r.Create(ctx, desired)
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("create egress proxy %s: %w", kind, err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		return fmt.Errorf("get egress proxy %s: %w", kind, err)
+	}
+	if existing.GetLabels()["app.kubernetes.io/component"] != netshared.EgressProxyComponent {
+		return fmt.Errorf("existing %s %q is not managed by the agent operator", kind, desired.GetName())
+	}
+	if sync() {
+		if err := r.Update(ctx, existing); err != nil {
+			return fmt.Errorf("update egress proxy %s: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// mintGatewayToken returns the env var carrying the run's token for its
+// provider's AgentGateway and records the token's lifetime in the run status,
+// or nil while the gateway is not ready. The token lives exactly as long as the
+// run may, plus a grace period, and is revoked when the run ends.
+func (r *AgentRunReconciler) mintGatewayToken(ctx context.Context, run *agentv1alpha1.AgentRun, execution *resolvedRunExecution) (*corev1.EnvVar, error) {
+	var gateway agentv1alpha1.AgentGateway
+	if err := r.Get(ctx, types.NamespacedName{Name: execution.gateway, Namespace: run.Namespace}, &gateway); err != nil {
+		return nil, fmt.Errorf("get agent gateway %q: %w", execution.gateway, err)
+	}
+	if !gateway.Status.Ready {
+		return nil, nil
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Name: provider.GatewayResourceName(gateway.Name), Namespace: run.Namespace}, &secret); err != nil {
+		return nil, fmt.Errorf("get agent gateway %q key Secret: %w", gateway.Name, err)
+	}
+	key, err := provider.GatewayKey(&secret)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := provider.GatewayTokenExpiry(run, execution.defaults.Timeout)
+	token := provider.SignGatewayToken(key, provider.GatewayClaims{
+		Namespace: run.Namespace,
+		Gateway:   gateway.Name,
+		Run:       run.Name,
+		UID:       string(run.UID),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	run.Status.GatewayToken = &agentv1alpha1.GatewayTokenStatus{Gateway: gateway.Name, ExpiresAt: metav1.NewTime(expiresAt)}
+	return &corev1.EnvVar{Name: execution.gatewayTokenEnv, Value: token}, nil
+}
+
+// revokeGatewayToken adds a finished run's token to its gateway's revocation
+// list, so a pod that outlives its run loses provider access. A token whose
+// gateway is gone, or that has expired, needs no revocation.
+func (r *AgentRunReconciler) revokeGatewayToken(ctx context.Context, run *agentv1alpha1.AgentRun) error {
+	status := run.Status.GatewayToken
+	if status == nil || status.Revoked {
+		return nil
+	}
+	key := types.NamespacedName{Name: provider.GatewayResourceName(status.Gateway), Namespace: run.Namespace}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var secret corev1.Secret
+		if err := r.Get(ctx, key, &secret); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if err := provider.RevokeGatewayToken(&secret, string(run.UID), status.ExpiresAt.Time, time.Now()); err != nil {
+			return err
+		}
+		return r.Update(ctx, &secret)
+	})
+	if err != nil {
+		return fmt.Errorf("revoke agent gateway token: %w", err)
+	}
+	status.Revoked = true
+	if err := r.updateStatus(ctx, run); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(run, nil, corev1.EventTypeNormal, "GatewayTokenRevoked", "GatewayTokenRevoked",
+		"Revoked the run's token for AgentGateway %s", status.Gateway)
+	return nil
+}
+
 // ensureAgentServiceAccount creates the dedicated zero-RBAC ServiceAccount used
 // by all untrusted agent and workspace-init pods. It is a shared namespace
 // resource, so it has no owner reference.
@@ -483,12 +718,24 @@ func (r *AgentRunReconciler) updateStatus(ctx context.Context, run *agentv1alpha
 	})
 }
 
+// SetupWithManager watches the run's Jobs and NetworkPolicies, plus the
+// FQDN-aware policy kinds the cluster serves at startup; a kind installed later
+// is still created, but watched only after an operator restart.
 func (r *AgentRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&agentv1alpha1.AgentRun{}).
 		Owns(&batchv1.Job{}).
-		Owns(&networkingv1.NetworkPolicy{}).
-		Complete(r)
+		Owns(&networkingv1.NetworkPolicy{})
+	for _, realizer := range plugins.FQDNPolicyRealizers() {
+		gvk := realizer.GroupVersionKind()
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			continue
+		}
+		owned := &unstructured.Unstructured{}
+		owned.SetGroupVersionKind(gvk)
+		builder = builder.Owns(owned)
+	}
+	return builder.Complete(r)
 }
 
 func ptrOrEmpty(s *string) string {
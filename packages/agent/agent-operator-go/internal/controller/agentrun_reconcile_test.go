@@ -39,11 +39,13 @@ func newRunReconciler(objects ...client.Object) (*AgentRunReconciler, client.Cli
 	}, fakeClient
 }
 
+func ctrlRequestFor(name string) ctrl.Request {
+	return ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+}
+
 func reconcileRun(t *testing.T, reconciler *AgentRunReconciler, name string) ctrl.Result {
 	t.Helper()
-	result, err := reconciler.Reconcile(context.Background(), ctrl.Request{
-		NamespacedName: types.NamespacedName{Namespace: "default", Name: name},
-	})
+	result, err := reconciler.Reconcile(context.Background(), ctrlRequestFor(name))
 	if err != nil {
 		t.Fatalf("Reconcile() error = %v", err)
 	}
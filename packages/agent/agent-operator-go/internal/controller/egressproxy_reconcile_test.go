@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/test/testutil"
+)
+
+const testEgressProxyImage = "ghcr.io/xonovex/agent-operator-go@sha256:0cab8c2115522f6e352cd3e096740c79cdc5effebccb38bfda85e7bbe94f4549"
+
+func proxyKey() types.NamespacedName {
+	return types.NamespacedName{Namespace: "default", Name: netshared.EgressProxyName}
+}
+
+func jobEnv(job batchv1.Job, name string) string {
+	for _, container := range job.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == name {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// A network=proxy run brings up the namespace proxy and gets a credential that
+// the proxy accepts for exactly the run's allowlist.
+func TestAgentRunReconcile_ProxyNetworkProvisionsTheNamespaceProxy(t *testing.T) {
+	reconciler, c := newRunReconciler(runnableAgentRun("solo", testutil.WithProxyNetwork("api.anthropic.com")))
+	reconciler.EgressProxyImage = testEgressProxyImage
+
+	reconcileRun(t, reconciler, "solo")
+
+	ctx := context.Background()
+	var secret corev1.Secret
+	if err := c.Get(ctx, proxyKey(), &secret); err != nil {
+		t.Fatalf("proxy key Secret was not created: %v", err)
+	}
+	var deployment appsv1.Deployment
+	if err := c.Get(ctx, proxyKey(), &deployment); err != nil {
+		t.Fatalf("proxy Deployment was not created: %v", err)
+	}
+	if got := deployment.Spec.Template.Spec.Containers[0].Image; got != testEgressProxyImage {
+		t.Errorf("proxy image = %q, want %q", got, testEgressProxyImage)
+	}
+	if err := c.Get(ctx, proxyKey(), &corev1.Service{}); err != nil {
+		t.Errorf("proxy Service was not created: %v", err)
+	}
+	if err := c.Get(ctx, proxyKey(), &networkingv1.NetworkPolicy{}); err != nil {
+		t.Errorf("proxy NetworkPolicy was not created: %v", err)
+	}
+
+	var job batchv1.Job
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "solo"}, &job); err != nil {
+		t.Fatalf("agent Job was not created: %v", err)
+	}
+	proxyURL := jobEnv(job, "HTTPS_PROXY")
+	prefix := "http://solo:"
+	suffix := "@agent-egress-proxy.default.svc:3128"
+	if !strings.HasPrefix(proxyURL, prefix) || !strings.HasSuffix(proxyURL, suffix) {
+		t.Fatalf("HTTPS_PROXY = %q, want the run's credential on the namespace proxy", proxyURL)
+	}
+	credential := strings.TrimSuffix(strings.TrimPrefix(proxyURL, prefix), suffix)
+	key, err := netshared.EgressProxyKey(&secret)
+	if err != nil {
+		t.Fatalf("EgressProxyKey() error = %v", err)
+	}
+	allow, err := netshared.VerifyProxyCredential(key, "default", "solo", credential)
+	if err != nil || len(allow) != 1 || allow[0] != "api.anthropic.com" {
+		t.Errorf("credential allowlist = (%v, %v), want [api.anthropic.com]", allow, err)
+	}
+}
+
+// An existing proxy keeps its key, so credentials already handed out stay
+// valid, and a drifted Deployment is rolled back to the desired spec.
+func TestAgentRunReconcile_ProxyNetworkReusesTheKeyAndRepairsTheDeployment(t *testing.T) {
+	key := netshared.NewEgressProxyKey()
+	drifted := netshared.BuildEgressProxyDeployment("default", "ghcr.io/example/stale@sha256:"+strings.Repeat("0", 64))
+	reconciler, c := newRunReconciler(
+		runnableAgentRun("solo", testutil.WithProxyNetwork("api.anthropic.com")),
+		netshared.BuildEgressProxySecret("default", key),
+		drifted,
+	)
+	reconciler.EgressProxyImage = testEgressProxyImage
+
+	reconcileRun(t, reconciler, "solo")
+
+	ctx := context.Background()
+	var deployment appsv1.Deployment
+	if err := c.Get(ctx, proxyKey(), &deployment); err != nil {
+		t.Fatalf("get proxy Deployment: %v", err)
+	}
+	if got := deployment.Spec.Template.Spec.Containers[0].Image; got != testEgressProxyImage {
+		t.Errorf("proxy image = %q, want it updated to %q", got, testEgressProxyImage)
+	}
+	var job batchv1.Job
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "solo"}, &job); err != nil {
+		t.Fatalf("agent Job was not created: %v", err)
+	}
+	credential := strings.TrimSuffix(strings.TrimPrefix(jobEnv(job, "HTTPS_PROXY"), "http://solo:"), "@agent-egress-proxy.default.svc:3128")
+	if _, err := netshared.VerifyProxyCredential(key, "default", "solo", credential); err != nil {
+		t.Errorf("credential does not verify against the existing key: %v", err)
+	}
+}
+
+// A same-named object the operator did not create is never taken over.
+func TestAgentRunReconcile_ProxyNetworkRefusesAForeignService(t *testing.T) {
+	foreign := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: netshared.EgressProxyName}}
+	reconciler, _ := newRunReconciler(runnableAgentRun("solo", testutil.WithProxyNetwork("api.anthropic.com")), foreign)
+	reconciler.EgressProxyImage = testEgressProxyImage
+
+	_, err := reconciler.Reconcile(context.Background(), ctrlRequestFor("solo"))
+	if err == nil || !strings.Contains(err.Error(), "not managed by the agent operator") {
+		t.Fatalf("Reconcile() error = %v, want a refusal to adopt the foreign Service", err)
+	}
+}
+
+// Without a proxy image the operator cannot enforce the allowlist, so the run
+// fails instead of starting with open egress.
+func TestAgentRunReconcile_ProxyNetworkFailsWithoutAProxyImage(t *testing.T) {
+	reconciler, c := newRunReconciler(runnableAgentRun("solo", testutil.WithProxyNetwork("api.anthropic.com")))
+
+	reconcileRun(t, reconciler, "solo")
+
+	resolved := getRun(t, c, "solo")
+	if resolved.Status.Phase != agentv1alpha1.AgentRunPhaseFailed {
+		t.Errorf("phase = %q, want %q", resolved.Status.Phase, agentv1alpha1.AgentRunPhaseFailed)
+	}
+	if message := phaseMessage(resolved, agentv1alpha1.AgentRunPhaseFailed); !strings.Contains(message, "EgressProxyUnavailable") {
+		t.Errorf("message = %q, want it to name the missing proxy", message)
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "solo"}, &batchv1.Job{}); err == nil {
+		t.Error("agent Job was created without an enforcing proxy")
+	}
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/cilium"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/test/testutil"
+)
+
+// newCiliumRunReconciler is newRunReconciler on a cluster that serves
+// CiliumNetworkPolicy.
+func newCiliumRunReconciler(objects ...client.Object) (*AgentRunReconciler, client.Client) {
+	scheme := testutil.NewScheme()
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(testutil.NewRESTMapper(scheme, cilium.NetworkPolicyGVK)).
+		WithStatusSubresource(&agentv1alpha1.AgentRun{}).
+		WithObjects(objects...).
+		Build()
+	return &AgentRunReconciler{
+		Client:   fakeClient,
+		Scheme:   scheme,
+		Recorder: events.NewFakeRecorder(20),
+	}, fakeClient
+}
+
+func fqdnRun(allow ...string) *agentv1alpha1.AgentRun {
+	run := runnableAgentRun("solo")
+	run.Spec.Network = agentv1alpha1.NetworkModeFQDN
+	run.Spec.EgressAllow = allow
+	return run
+}
+
+// On Cilium the run's egress allowlist becomes an owned CiliumNetworkPolicy in
+// place of the IP-only NetworkPolicy.
+func TestAgentRunReconcile_FQDNNetworkCreatesAnOwnedCiliumPolicy(t *testing.T) {
+	reconciler, c := newCiliumRunReconciler(fqdnRun("api.anthropic.com"))
+
+	reconcileRun(t, reconciler, "solo")
+	reconcileRun(t, reconciler, "solo")
+
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "solo-netpol"}
+	policy := &unstructured.Unstructured{}
+	policy.SetGroupVersionKind(cilium.NetworkPolicyGVK)
+	if err := c.Get(ctx, key, policy); err != nil {
+		t.Fatalf("CiliumNetworkPolicy was not created: %v", err)
+	}
+	owners := policy.GetOwnerReferences()
+	if len(owners) != 1 || owners[0].Kind != "AgentRun" || owners[0].Name != "solo" {
+		t.Errorf("owner references = %v, want the AgentRun as controller", owners)
+	}
+	if err := c.Get(ctx, key, &networkingv1.NetworkPolicy{}); err == nil {
+		t.Error("an IP-only NetworkPolicy was created alongside the CiliumNetworkPolicy")
+	}
+}
+
+// A same-named policy that is not the run's own is never adopted.
+func TestAgentRunReconcile_FQDNNetworkRefusesAForeignCiliumPolicy(t *testing.T) {
+	foreign := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{}}}
+	foreign.SetGroupVersionKind(cilium.NetworkPolicyGVK)
+	foreign.SetNamespace("default")
+	foreign.SetName("solo-netpol")
+	reconciler, _ := newCiliumRunReconciler(fqdnRun("api.anthropic.com"), foreign)
+
+	_, err := reconciler.Reconcile(context.Background(), ctrlRequestFor("solo"))
+	if err == nil || !strings.Contains(err.Error(), "not controlled by AgentRun") {
+		t.Fatalf("Reconcile() error = %v, want a refusal to adopt the foreign policy", err)
+	}
+}
+
+// Without an FQDN-aware policy API the allowlist cannot be enforced, so the run
+// fails instead of starting with DNS-only or open egress.
+func TestAgentRunReconcile_FQDNNetworkFailsWithoutCilium(t *testing.T) {
+	reconciler, c := newRunReconciler(fqdnRun("api.anthropic.com"))
+
+	reconcileRun(t, reconciler, "solo")
+
+	resolved := getRun(t, c, "solo")
+	if resolved.Status.Phase != agentv1alpha1.AgentRunPhaseFailed {
+		t.Errorf("phase = %q, want %q", resolved.Status.Phase, agentv1alpha1.AgentRunPhaseFailed)
+	}
+	if message := phaseMessage(resolved, agentv1alpha1.AgentRunPhaseFailed); !strings.Contains(message, "FQDNPolicyUnavailable") {
+		t.Errorf("message = %q, want it to name the missing policy API", message)
+	}
+}
+
+// An allowlist admission should have rejected surfaces as a reconcile error.
+func TestAgentRunReconcile_FQDNNetworkReportsABadAllowlist(t *testing.T) {
+	reconciler, _ := newCiliumRunReconciler(fqdnRun())
+
+	if _, err := reconciler.Reconcile(context.Background(), ctrlRequestFor("solo")); err == nil {
+		t.Fatal("Reconcile() error = nil, want the empty allowlist reported")
+	}
+}
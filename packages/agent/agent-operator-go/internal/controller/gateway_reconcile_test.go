@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provider"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/test/testutil"
+)
+
+// newGatedRunReconciler seeds a run whose provider's credential is held by the
+// "anthropic" gateway, and returns the gateway's signing key.
+func newGatedRunReconciler(ready bool, opts ...testutil.AgentRunOption) (*AgentRunReconciler, client.Client, []byte) {
+	run := runnableAgentRun("solo", append([]testutil.AgentRunOption{testutil.WithProviderRef("gated")}, opts...)...)
+	run.UID = "run-uid"
+	run.CreationTimestamp = metav1.Now()
+	gateway := testutil.NewAgentGateway("default", "anthropic", "https://api.anthropic.com", "anthropic-credential", "api-key")
+	gateway.Status.Ready = ready
+	key := provider.NewGatewayKey()
+	reconciler, c := newRunReconciler(
+		run,
+		testutil.NewAgentProvider("default", "gated", testutil.WithPresetRef("glm"), testutil.WithGatewayRef("anthropic")),
+		gateway,
+		provider.BuildGatewayKeySecret(gateway, key),
+	)
+	return reconciler, c, key
+}
+
+func getGatewayKeySecret(t *testing.T, c client.Client) corev1.Secret {
+	t.Helper()
+	var secret corev1.Secret
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "anthropic-gateway"}, &secret); err != nil {
+		t.Fatalf("get gateway key Secret: %v", err)
+	}
+	return secret
+}
+
+// The Job carries the gateway URL and a per-run token that the gateway accepts,
+// never the credential Secret, and the run may reach the gateway pods.
+func TestAgentRunReconcile_GatewayProviderMintsAPerRunToken(t *testing.T) {
+	reconciler, c, key := newGatedRunReconciler(true)
+
+	reconcileRun(t, reconciler, "solo")
+
+	ctx := context.Background()
+	var job batchv1.Job
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "solo"}, &job); err != nil {
+		t.Fatalf("agent Job was not created: %v", err)
+	}
+	if got := jobEnv(job, "ANTHROPIC_BASE_URL"); got != "http://anthropic-gateway.default.svc:8080" {
+		t.Errorf("ANTHROPIC_BASE_URL = %q, want the gateway URL", got)
+	}
+	claims, err := provider.VerifyGatewayToken(key, "default", "anthropic", jobEnv(job, "ANTHROPIC_AUTH_TOKEN"), time.Now())
+	if err != nil {
+		t.Fatalf("run token does not verify: %v", err)
+	}
+	if claims.Run != "solo" || claims.UID != "run-uid" {
+		t.Errorf("claims = %+v, want run solo uid run-uid", claims)
+	}
+	for _, container := range job.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				t.Errorf("container %s mounts Secret %s through %s", container.Name, env.ValueFrom.SecretKeyRef.Name, env.Name)
+			}
+		}
+	}
+
+	run := getRun(t, c, "solo")
+	if run.Status.GatewayToken == nil || run.Status.GatewayToken.Gateway != "anthropic" || run.Status.GatewayToken.Revoked {
+		t.Fatalf("gatewayToken = %+v, want a live token for anthropic", run.Status.GatewayToken)
+	}
+	if !run.Status.GatewayToken.ExpiresAt.Equal(&metav1.Time{Time: time.Unix(claims.ExpiresAt, 0)}) {
+		t.Errorf("status expiry = %v, want the token's %v", run.Status.GatewayToken.ExpiresAt, time.Unix(claims.ExpiresAt, 0))
+	}
+
+	var policy networkingv1.NetworkPolicy
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "solo-netpol"}, &policy); err != nil {
+		t.Fatalf("get run NetworkPolicy: %v", err)
+	}
+	last := policy.Spec.Egress[len(policy.Spec.Egress)-1]
+	if last.To[0].PodSelector == nil || last.To[0].PodSelector.MatchLabels[provider.GatewayLabel] != "anthropic" {
+		t.Errorf("egress = %+v, want a rule for the gateway pods", policy.Spec.Egress)
+	}
+}
+
+// Until the gateway is ready there is no key to sign with, so the run waits
+// rather than starting without provider access.
+func TestAgentRunReconcile_GatewayProviderWaitsForTheGateway(t *testing.T) {
+	reconciler, c, _ := newGatedRunReconciler(false)
+
+	if result := reconcileRun(t, reconciler, "solo"); result.RequeueAfter == 0 {
+		t.Error("Reconcile() did not requeue while the gateway is not ready")
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "solo"}, &batchv1.Job{}); err == nil {
+		t.Error("agent Job was created before the gateway was ready")
+	}
+}
+
+// A finished run's token joins the gateway's revocation list, once.
+func TestAgentRunReconcile_TerminalPhaseRevokesTheGatewayToken(t *testing.T) {
+	reconciler, c, _ := newGatedRunReconciler(true)
+	reconcileRun(t, reconciler, "solo")
+
+	run := getRun(t, c, "solo")
+	run.Status.Phase = agentv1alpha1.AgentRunPhaseSucceeded
+	if err := c.Status().Update(context.Background(), &run); err != nil {
+		t.Fatalf("update run status: %v", err)
+	}
+	reconcileRun(t, reconciler, "solo")
+
+	secret := getGatewayKeySecret(t, c)
+	revoked, err := provider.GatewayRevocations(secret.Data[provider.GatewayRevokedName])
+	if err != nil {
+		t.Fatalf("GatewayRevocations() error = %v", err)
+	}
+	if _, ok := revoked["run-uid"]; !ok {
+		t.Errorf("revoked = %v, want the run's UID", revoked)
+	}
+	if token := getRun(t, c, "solo").Status.GatewayToken; token == nil || !token.Revoked {
+		t.Errorf("gatewayToken = %+v, want it marked revoked", token)
+	}
+
+	version := secret.ResourceVersion
+	reconcileRun(t, reconciler, "solo")
+	if again := getGatewayKeySecret(t, c); again.ResourceVersion != version {
+		t.Error("a revoked token was revoked again")
+	}
+}
+
+// A gateway that is gone has no list to add to; its tokens are useless.
+func TestAgentRunReconcile_RevocationToleratesADeletedGateway(t *testing.T) {
+	reconciler, c, _ := newGatedRunReconciler(true)
+	reconcileRun(t, reconciler, "solo")
+	secret := getGatewayKeySecret(t, c)
+	if err := c.Delete(context.Background(), &secret); err != nil {
+		t.Fatalf("delete gateway key Secret: %v", err)
+	}
+
+	run := getRun(t, c, "solo")
+	run.Status.Phase = agentv1alpha1.AgentRunPhaseFailed
+	if err := c.Status().Update(context.Background(), &run); err != nil {
+		t.Fatalf("update run status: %v", err)
+	}
+	reconcileRun(t, reconciler, "solo")
+
+	if token := getRun(t, c, "solo").Status.GatewayToken; token == nil || !token.Revoked {
+		t.Errorf("gatewayToken = %+v, want it marked revoked", token)
+	}
+}
+
+func TestAgentRunReconcile_GatewayProviderFailsWithACorruptKey(t *testing.T) {
+	reconciler, c, _ := newGatedRunReconciler(true)
+	secret := getGatewayKeySecret(t, c)
+	secret.Data[provider.GatewayKeyName] = []byte("short")
+	if err := c.Update(context.Background(), &secret); err != nil {
+		t.Fatalf("update gateway key Secret: %v", err)
+	}
+
+	_, err := reconciler.Reconcile(context.Background(), ctrlRequestFor("solo"))
+	if err == nil || !strings.Contains(err.Error(), "key") {
+		t.Fatalf("Reconcile() error = %v, want a key error", err)
+	}
+}
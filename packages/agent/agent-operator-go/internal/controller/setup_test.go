@@ -45,6 +45,13 @@ func setupFuncs() map[string]func(ctrl.Manager) error {
 				Recorder: events.NewFakeRecorder(10),
 			}).SetupWithManager(mgr)
 		},
+		"AgentGateway": func(mgr ctrl.Manager) error {
+			return (&AgentGatewayReconciler{
+				Client:   mgr.GetClient(),
+				Scheme:   mgr.GetScheme(),
+				Recorder: events.NewFakeRecorder(10),
+			}).SetupWithManager(mgr)
+		},
 		"AgentWorkspace": func(mgr ctrl.Manager) error {
 			return (&AgentWorkspaceReconciler{
 				Client:   mgr.GetClient(),
@@ -56,7 +63,7 @@ func setupFuncs() map[string]func(ctrl.Manager) error {
 }
 
 // Controller name validation is process-global rather than per-manager, so
-// registering all three here also proves their names do not collide the way
+// registering all of them here also proves their names do not collide the way
 // they would in cmd/operator.
 func TestSetupWithManager_RegistersEveryReconciler(t *testing.T) {
 	for name, setup := range setupFuncs() {
@@ -0,0 +1,125 @@
+// Package cilium is the Cilium FQDNPolicyRealizer leaf: it renders a
+// network=fqdn run as a CiliumNetworkPolicy whose toFQDNs rules admit only the
+// run's EgressAllow hostnames. The operator builds the object unstructured so
+// it carries no Cilium API dependency; the realizer applies only where the
+// cluster serves the CRD.
+package cilium
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
+)
+
+// NetworkPolicyGVK is the CiliumNetworkPolicy kind.
+var NetworkPolicyGVK = schema.GroupVersionKind{Group: "cilium.io", Version: "v2", Kind: "CiliumNetworkPolicy"}
+
+// Realizer renders network=fqdn runs as CiliumNetworkPolicies.
+type Realizer struct{}
+
+var _ netshared.FQDNPolicyRealizer = Realizer{}
+
+// GroupVersionKind implements netshared.FQDNPolicyRealizer.
+func (Realizer) GroupVersionKind() schema.GroupVersionKind { return NetworkPolicyGVK }
+
+// BuildPolicy implements netshared.FQDNPolicyRealizer. Ingress is denied.
+// Egress allows DNS to kube-dns through Cilium's DNS proxy, which is what
+// populates toFQDNs, and one rule per allowlist entry on its port; IP literal
+// entries become toCIDR rules. A "*." entry becomes a matchPattern, which
+// Cilium matches one label deep, narrower than the proxy's any-depth match.
+// Each peer becomes a toEndpoints rule in the run's namespace.
+func (Realizer) BuildPolicy(run *agentv1alpha1.AgentRun, peers ...netshared.PodPeer) (*unstructured.Unstructured, error) {
+	if len(run.Spec.EgressAllow) == 0 {
+		return nil, fmt.Errorf("build CiliumNetworkPolicy: %w", netshared.ErrEgressAllowlistEmpty)
+	}
+	destinations, err := egress.ParseDestinations(run.Spec.EgressAllow)
+	if err != nil {
+		return nil, fmt.Errorf("build CiliumNetworkPolicy: %w", err)
+	}
+
+	rules := []any{dnsRule()}
+	for _, destination := range destinations {
+		rules = append(rules, destinationRule(destination))
+	}
+	for _, peer := range peers {
+		rules = append(rules, peerRule(peer))
+	}
+
+	policy := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"endpointSelector": map[string]any{
+				"matchLabels": map[string]any{"app.kubernetes.io/instance": run.Name},
+			},
+			// A single empty rule puts the endpoint in ingress default-deny.
+			"ingress": []any{map[string]any{}},
+			"egress":  rules,
+		},
+	}}
+	policy.SetGroupVersionKind(NetworkPolicyGVK)
+	policy.SetName(run.Name + "-netpol")
+	policy.SetNamespace(run.Namespace)
+	policy.SetLabels(map[string]string{
+		"app.kubernetes.io/name":      "agent-operator",
+		"app.kubernetes.io/instance":  run.Name,
+		"app.kubernetes.io/component": "agent-network-policy",
+	})
+	return policy, nil
+}
+
+// dnsRule allows lookups to kube-dns and routes them through Cilium's DNS
+// proxy so resolved addresses of allowlisted names are admitted.
+func dnsRule() map[string]any {
+	return map[string]any{
+		"toEndpoints": []any{map[string]any{
+			"matchLabels": map[string]any{
+				"k8s:io.kubernetes.pod.namespace": metav1.NamespaceSystem,
+				"k8s:k8s-app":                     "kube-dns",
+			},
+		}},
+		"toPorts": []any{map[string]any{
+			"ports": []any{map[string]any{"port": "53", "protocol": "ANY"}},
+			"rules": map[string]any{"dns": []any{map[string]any{"matchPattern": "*"}}},
+		}},
+	}
+}
+
+func destinationRule(destination egress.Destination) map[string]any {
+	rule := map[string]any{
+		"toPorts": []any{map[string]any{
+			"ports": []any{map[string]any{"port": destination.Port, "protocol": "TCP"}},
+		}},
+	}
+	if address, err := netip.ParseAddr(destination.Host); err == nil && !destination.Wildcard {
+		rule["toCIDR"] = []any{netip.PrefixFrom(address, address.BitLen()).String()}
+		return rule
+	}
+	selector := map[string]any{"matchName": destination.Host}
+	if destination.Wildcard {
+		selector = map[string]any{"matchPattern": "*." + destination.Host}
+	}
+	rule["toFQDNs"] = []any{selector}
+	return rule
+}
+
+// peerRule allows the pods carrying the peer's labels on its port. A namespaced
+// policy's endpoint selectors match only its own namespace.
+func peerRule(peer netshared.PodPeer) map[string]any {
+	labels := make(map[string]any, len(peer.Labels))
+	for key, value := range peer.Labels {
+		labels[key] = value
+	}
+	return map[string]any{
+		"toEndpoints": []any{map[string]any{"matchLabels": labels}},
+		"toPorts": []any{map[string]any{
+			"ports": []any{map[string]any{"port": strconv.Itoa(int(peer.Port)), "protocol": "TCP"}},
+		}},
+	}
+}
@@ -0,0 +1,102 @@
+package cilium
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
+)
+
+func fqdnRun(allow ...string) *agentv1alpha1.AgentRun {
+	return &agentv1alpha1.AgentRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "review", Namespace: "agents"},
+		Spec: agentv1alpha1.AgentRunSpec{
+			Network:     agentv1alpha1.NetworkModeFQDN,
+			EgressAllow: allow,
+		},
+	}
+}
+
+func TestRealizer_BuildsDenyIngressAndFQDNEgress(t *testing.T) {
+	policy, err := (Realizer{}).BuildPolicy(fqdnRun("api.anthropic.com", "*.githubusercontent.com", "registry.example:8443", "203.0.113.7"))
+	if err != nil {
+		t.Fatalf("BuildPolicy() error = %v", err)
+	}
+
+	if policy.GroupVersionKind() != NetworkPolicyGVK || (Realizer{}).GroupVersionKind() != NetworkPolicyGVK {
+		t.Errorf("kind = %v, want %v", policy.GroupVersionKind(), NetworkPolicyGVK)
+	}
+	if policy.GetName() != "review-netpol" || policy.GetNamespace() != "agents" {
+		t.Errorf("object = %s/%s, want agents/review-netpol", policy.GetNamespace(), policy.GetName())
+	}
+	spec := policy.Object["spec"].(map[string]any)
+	selector := spec["endpointSelector"].(map[string]any)["matchLabels"].(map[string]any)
+	if selector["app.kubernetes.io/instance"] != "review" {
+		t.Errorf("endpointSelector = %v, want the run's pods", selector)
+	}
+	if !reflect.DeepEqual(spec["ingress"], []any{map[string]any{}}) {
+		t.Errorf("ingress = %v, want a single empty default-deny rule", spec["ingress"])
+	}
+
+	egress := spec["egress"].([]any)
+	if len(egress) != 5 {
+		t.Fatalf("egress rules = %d, want DNS plus one per entry", len(egress))
+	}
+	dns := egress[0].(map[string]any)["toPorts"].([]any)[0].(map[string]any)
+	if _, ok := dns["rules"].(map[string]any)["dns"]; !ok {
+		t.Errorf("DNS rule = %v, want it routed through the DNS proxy", dns)
+	}
+	want := []struct {
+		key      string
+		selector any
+		port     string
+	}{
+		{"toFQDNs", []any{map[string]any{"matchName": "api.anthropic.com"}}, "443"},
+		{"toFQDNs", []any{map[string]any{"matchPattern": "*.githubusercontent.com"}}, "443"},
+		{"toFQDNs", []any{map[string]any{"matchName": "registry.example"}}, "8443"},
+		{"toCIDR", []any{"203.0.113.7/32"}, "443"},
+	}
+	for index, expected := range want {
+		rule := egress[index+1].(map[string]any)
+		if !reflect.DeepEqual(rule[expected.key], expected.selector) {
+			t.Errorf("rule %d %s = %v, want %v", index, expected.key, rule[expected.key], expected.selector)
+		}
+		port := rule["toPorts"].([]any)[0].(map[string]any)["ports"].([]any)[0].(map[string]any)
+		if port["port"] != expected.port || port["protocol"] != "TCP" {
+			t.Errorf("rule %d port = %v, want TCP %s", index, port, expected.port)
+		}
+	}
+}
+
+func TestRealizer_RefusesAnEmptyOrInvalidAllowlist(t *testing.T) {
+	if _, err := (Realizer{}).BuildPolicy(fqdnRun()); !errors.Is(err, netshared.ErrEgressAllowlistEmpty) {
+		t.Errorf("BuildPolicy(empty) error = %v, want %v", err, netshared.ErrEgressAllowlistEmpty)
+	}
+	if _, err := (Realizer{}).BuildPolicy(fqdnRun("https://api.anthropic.com")); err == nil {
+		t.Error("BuildPolicy(URL) error = nil, want hostname validation error")
+	}
+}
+
+func TestRealizer_AllowsPeerEndpoints(t *testing.T) {
+	peer := netshared.PodPeer{Labels: map[string]string{"agent.xonovex.com/gateway": "anthropic"}, Port: 8080}
+	policy, err := (Realizer{}).BuildPolicy(fqdnRun("api.anthropic.com"), peer)
+	if err != nil {
+		t.Fatalf("BuildPolicy() error = %v", err)
+	}
+
+	egress := policy.Object["spec"].(map[string]any)["egress"].([]any)
+	if len(egress) != 3 {
+		t.Fatalf("egress rules = %d, want DNS, the entry and the peer", len(egress))
+	}
+	want := map[string]any{
+		"toEndpoints": []any{map[string]any{"matchLabels": map[string]any{"agent.xonovex.com/gateway": "anthropic"}}},
+		"toPorts":     []any{map[string]any{"ports": []any{map[string]any{"port": "8080", "protocol": "TCP"}}}},
+	}
+	if !reflect.DeepEqual(egress[2], want) {
+		t.Errorf("peer rule = %v, want %v", egress[2], want)
+	}
+}
@@ -0,0 +1,85 @@
+// Package proxy is the network=proxy leaf: the per-namespace egress proxy
+// process the managed Deployment runs. It is the shared allowlist CONNECT proxy
+// with one twist: the namespace's runs share it, so each request carries its
+// run's signed credential and is tunnelled only to the allowlist that
+// credential carries.
+package proxy
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"sync"
+
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
+)
+
+// maxCachedAllowlists bounds the verified-credential cache; it is cleared
+// rather than evicted piecemeal because rebuilding an entry is cheap.
+const maxCachedAllowlists = 1024
+
+// NewServer returns the egress proxy for namespace, verifying run credentials
+// with key.
+func NewServer(key []byte, namespace string) *egress.Server {
+	a := &authorizer{key: key, namespace: namespace, cache: map[string]*egress.Allowlist{}}
+	return egress.NewAuthorizingServer(a.authorize)
+}
+
+type authorizer struct {
+	key       []byte
+	namespace string
+
+	mu    sync.Mutex
+	cache map[string]*egress.Allowlist
+}
+
+// authorize admits a CONNECT whose Proxy-Authorization verifies and whose
+// target is on the credential's allowlist.
+func (a *authorizer) authorize(req *http.Request) bool {
+	run, credential, ok := proxyBasicAuth(req)
+	if !ok {
+		return false
+	}
+	allow, err := a.allowlist(run, credential)
+	if err != nil {
+		return false
+	}
+	return allow.Allows(req.Host)
+}
+
+func (a *authorizer) allowlist(run, credential string) (*egress.Allowlist, error) {
+	cacheKey := run + ":" + credential
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if allow, ok := a.cache[cacheKey]; ok {
+		return allow, nil
+	}
+	hosts, err := netshared.VerifyProxyCredential(a.key, a.namespace, run, credential)
+	if err != nil {
+		return nil, err
+	}
+	allow, err := egress.NewAllowlist(hosts)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.cache) >= maxCachedAllowlists {
+		clear(a.cache)
+	}
+	a.cache[cacheKey] = allow
+	return allow, nil
+}
+
+// proxyBasicAuth returns the Basic credentials of the Proxy-Authorization
+// header.
+func proxyBasicAuth(req *http.Request) (string, string, bool) {
+	encoded, ok := strings.CutPrefix(req.Header.Get("Proxy-Authorization"), "Basic ")
+	if !ok {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
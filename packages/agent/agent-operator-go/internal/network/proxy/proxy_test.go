@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
+)
+
+// startProxy serves the namespace proxy on loopback with a dialer that sends
+// every authorized tunnel to an echo listener, and returns the proxy address.
+func startProxy(t *testing.T, key []byte) string {
+	t.Helper()
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = upstream.Close() })
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	var dialer net.Dialer
+	server := NewServer(key, "agents").WithDialer(func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, upstream.Addr().String())
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen proxy: %v", err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+	return listener.Addr().String()
+}
+
+// connectStatus issues a CONNECT with the given Proxy-Authorization header and
+// returns the response status.
+func connectStatus(t *testing.T, proxyAddr, target, authorization string) int {
+	t.Helper()
+	conn, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
+	if authorization != "" {
+		request += "Proxy-Authorization: " + authorization + "\r\n"
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		t.Fatalf("write CONNECT: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("read CONNECT response: %v", err)
+	}
+	return resp.StatusCode
+}
+
+func basic(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func TestServer_TunnelsOnlyTheCredentialAllowlist(t *testing.T) {
+	key := netshared.NewEgressProxyKey()
+	proxyAddr := startProxy(t, key)
+	runA := netshared.SignProxyCredential(key, "agents", "run-a", []string{"api.anthropic.com"})
+	runB := netshared.SignProxyCredential(key, "agents", "run-b", []string{"registry.npmjs.org"})
+
+	cases := []struct {
+		name, target, authorization string
+		want                        int
+	}{
+		{"run a to its host", "api.anthropic.com:443", basic("run-a", runA), http.StatusOK},
+		{"run a again from cache", "api.anthropic.com:443", basic("run-a", runA), http.StatusOK},
+		{"run a to run b's host", "registry.npmjs.org:443", basic("run-a", runA), http.StatusForbidden},
+		{"run b to its host", "registry.npmjs.org:443", basic("run-b", runB), http.StatusOK},
+		{"run a credential as run b", "api.anthropic.com:443", basic("run-b", runA), http.StatusForbidden},
+		{"no credential", "api.anthropic.com:443", "", http.StatusForbidden},
+		{"bearer scheme", "api.anthropic.com:443", "Bearer " + runA, http.StatusForbidden},
+		{"malformed basic", "api.anthropic.com:443", "Basic !", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if got := connectStatus(t, proxyAddr, tc.target, tc.authorization); got != tc.want {
+			t.Errorf("%s: CONNECT status = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestServer_RefusesASignedInvalidAllowlist(t *testing.T) {
+	key := netshared.NewEgressProxyKey()
+	proxyAddr := startProxy(t, key)
+	credential := netshared.SignProxyCredential(key, "agents", "run", []string{"https://api.anthropic.com"})
+
+	if got := connectStatus(t, proxyAddr, "api.anthropic.com:443", basic("run", credential)); got != http.StatusForbidden {
+		t.Fatalf("CONNECT status = %d, want 403", got)
+	}
+}
+
+func TestAuthorizer_BoundsItsCache(t *testing.T) {
+	key := netshared.NewEgressProxyKey()
+	a := &authorizer{key: key, namespace: "agents", cache: map[string]*egress.Allowlist{}}
+	for index := range maxCachedAllowlists + 1 {
+		run := fmt.Sprintf("run-%d", index)
+		if _, err := a.allowlist(run, netshared.SignProxyCredential(key, "agents", run, []string{"example.com"})); err != nil {
+			t.Fatalf("allowlist(%s) error = %v", run, err)
+		}
+	}
+	if len(a.cache) != 1 {
+		t.Fatalf("cache size = %d, want 1 after overflowing the bound", len(a.cache))
+	}
+}
@@ -0,0 +1,239 @@
+package shared
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// EgressProxyName names every per-namespace egress proxy resource: the
+	// Deployment, Service, key Secret and the proxy's own NetworkPolicy.
+	EgressProxyName = "agent-egress-proxy"
+	// EgressProxyComponent labels the proxy resources and selects its pods.
+	EgressProxyComponent = "egress-proxy"
+	// EgressProxyPort is the proxy's CONNECT port.
+	EgressProxyPort int32 = 3128
+	// EgressProxyKeyDir is where the proxy mounts its credential key Secret.
+	EgressProxyKeyDir = "/etc/agent-egress-proxy"
+	// EgressProxyKeyName is the key Secret's data key and mounted file name.
+	EgressProxyKeyName = "key"
+	// EgressProxyBinary is the proxy executable shipped in the operator image.
+	EgressProxyBinary = "/egress-proxy"
+
+	egressProxyKeySize = 32
+)
+
+// ErrEgressAllowlistEmpty reports a network=proxy or network=fqdn run without a
+// hostname allowlist; it could reach no destination.
+var ErrEgressAllowlistEmpty = errors.New("network=proxy and network=fqdn require a non-empty egressAllow allowlist")
+
+// ErrProxyCredentialInvalid reports a proxy credential that does not verify
+// against the namespace key.
+var ErrProxyCredentialInvalid = errors.New("invalid egress proxy credential")
+
+// egressProxyLabels are the labels on every proxy resource and its pods. They
+// deliberately omit app.kubernetes.io/instance, which run NetworkPolicies select
+// on, so no run's policy can ever apply to the proxy.
+func egressProxyLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":      "agent-operator",
+		"app.kubernetes.io/component": EgressProxyComponent,
+	}
+}
+
+// NewEgressProxyKey returns a random key for signing proxy credentials.
+func NewEgressProxyKey() []byte {
+	key := make([]byte, egressProxyKeySize)
+	_, _ = rand.Read(key) // crypto/rand.Read never returns an error.
+	return key
+}
+
+// EgressProxyKey returns the signing key held by the proxy key Secret.
+func EgressProxyKey(secret *corev1.Secret) ([]byte, error) {
+	key := secret.Data[EgressProxyKeyName]
+	if len(key) < egressProxyKeySize {
+		return nil, fmt.Errorf("egress proxy Secret %q has no %d-byte %q key", secret.Name, egressProxyKeySize, EgressProxyKeyName)
+	}
+	return key, nil
+}
+
+// BuildEgressProxySecret holds the namespace key the operator signs run
+// credentials with and the proxy verifies them with. Agent pods run under a
+// zero-RBAC ServiceAccount and never mount it.
+func BuildEgressProxySecret(namespace string, key []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: EgressProxyName, Namespace: namespace, Labels: egressProxyLabels()},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{EgressProxyKeyName: key},
+	}
+}
+
+// BuildEgressProxyDeployment runs the namespace egress proxy from image (the
+// operator image, which ships the proxy binary). The pod is hardened like agent
+// pods and has no Kubernetes API access.
+func BuildEgressProxyDeployment(namespace, image string) *appsv1.Deployment {
+	replicas := int32(1)
+	automount := false
+	nonRoot := true
+	noEscalation := false
+	readOnly := true
+	keyMode := int32(0o440)
+	labels := egressProxyLabels()
+	port := intstr.FromInt32(EgressProxyPort)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: EgressProxyName, Namespace: namespace, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: egressProxyLabels()},
+				Spec: corev1.PodSpec{
+					AutomountServiceAccountToken: &automount,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot:   &nonRoot,
+						SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+					},
+					Containers: []corev1.Container{{
+						Name:    "proxy",
+						Image:   image,
+						Command: []string{EgressProxyBinary},
+						Args: []string{
+							fmt.Sprintf("--listen=:%d", EgressProxyPort),
+							"--namespace=" + namespace,
+							"--key-file=" + EgressProxyKeyDir + "/" + EgressProxyKeyName,
+						},
+						Ports: []corev1.ContainerPort{{Name: "proxy", ContainerPort: EgressProxyPort, Protocol: corev1.ProtocolTCP}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: port}},
+						},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("10m"),
+								corev1.ResourceMemory: resource.MustParse("32Mi"),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("500m"),
+								corev1.ResourceMemory: resource.MustParse("128Mi"),
+							},
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: &noEscalation,
+							ReadOnlyRootFilesystem:   &readOnly,
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "key", MountPath: EgressProxyKeyDir, ReadOnly: true}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "key",
+						VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+							SecretName:  EgressProxyName,
+							DefaultMode: &keyMode,
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// BuildEgressProxyService exposes the proxy pods on EgressProxyPort.
+func BuildEgressProxyService(namespace string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: EgressProxyName, Namespace: namespace, Labels: egressProxyLabels()},
+		Spec: corev1.ServiceSpec{
+			Selector: egressProxyLabels(),
+			Ports: []corev1.ServicePort{{
+				Name:       "proxy",
+				Port:       EgressProxyPort,
+				TargetPort: intstr.FromInt32(EgressProxyPort),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// BuildEgressProxyNetworkPolicy confines the proxy itself: ingress only from
+// agent-run pods on the proxy port, egress only to DNS and public addresses, so
+// an allowlisted name that resolves into the cluster or the metadata service is
+// still unreachable.
+func BuildEgressProxyNetworkPolicy(namespace string) *networkingv1.NetworkPolicy {
+	return BuildRunServiceNetworkPolicy(namespace, EgressProxyName, egressProxyLabels(), EgressProxyPort)
+}
+
+// egressProxyRule allows a run's pods to reach only the namespace proxy pods
+// on the proxy port.
+func egressProxyRule() networkingv1.NetworkPolicyEgressRule {
+	tcp := corev1.ProtocolTCP
+	port := intstr.FromInt32(EgressProxyPort)
+	return networkingv1.NetworkPolicyEgressRule{
+		To:    []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: egressProxyLabels()}}},
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}},
+	}
+}
+
+// SignProxyCredential returns the proxy password for a run: its allowlist and
+// an HMAC binding the allowlist to the namespace and run name. The proxy is
+// shared by the namespace, so the credential is what scopes each run to its
+// own hosts; a pod cannot widen its allowlist without the namespace key.
+func SignProxyCredential(key []byte, namespace, run string, allow []string) string {
+	payload, _ := json.Marshal(allow) // a []string always encodes.
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(credentialMAC(key, namespace, run, encoded))
+}
+
+// VerifyProxyCredential checks a run's proxy username and password against the
+// namespace key and returns the allowlist it carries.
+func VerifyProxyCredential(key []byte, namespace, run, credential string) ([]string, error) {
+	encoded, signature, found := strings.Cut(credential, ".")
+	if !found || run == "" {
+		return nil, ErrProxyCredentialInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, credentialMAC(key, namespace, run, encoded)) {
+		return nil, ErrProxyCredentialInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrProxyCredentialInvalid
+	}
+	var allow []string
+	if err := json.Unmarshal(payload, &allow); err != nil {
+		return nil, ErrProxyCredentialInvalid
+	}
+	return allow, nil
+}
+
+func credentialMAC(key []byte, namespace, run, encoded string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%s", namespace, run, encoded)
+	return mac.Sum(nil)
+}
+
+// ProxyEnv points an agent container at the namespace proxy with the run's
+// credential. Clients send the URL's userinfo as Proxy-Authorization; loopback
+// stays direct.
+func ProxyEnv(namespace, run, credential string) []corev1.EnvVar {
+	proxyURL := fmt.Sprintf("http://%s:%s@%s.%s.svc:%d", run, credential, EgressProxyName, namespace, EgressProxyPort)
+	noProxy := "localhost,127.0.0.1"
+	return []corev1.EnvVar{
+		{Name: "HTTPS_PROXY", Value: proxyURL},
+		{Name: "https_proxy", Value: proxyURL},
+		{Name: "HTTP_PROXY", Value: proxyURL},
+		{Name: "http_proxy", Value: proxyURL},
+		{Name: "NO_PROXY", Value: noProxy},
+		{Name: "no_proxy", Value: noProxy},
+	}
+}
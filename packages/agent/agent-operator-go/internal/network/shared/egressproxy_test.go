@@ -0,0 +1,135 @@
+package shared
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestProxyCredential_RoundTripsAndBindsTheRun(t *testing.T) {
+	key := NewEgressProxyKey()
+	allow := []string{"api.anthropic.com", "*.githubusercontent.com"}
+	credential := SignProxyCredential(key, "agents", "run-a", allow)
+
+	got, err := VerifyProxyCredential(key, "agents", "run-a", credential)
+	if err != nil || !slices.Equal(got, allow) {
+		t.Fatalf("VerifyProxyCredential() = (%v, %v), want (%v, nil)", got, err, allow)
+	}
+
+	encoded, signature, _ := strings.Cut(credential, ".")
+	widened := SignProxyCredential(key, "agents", "run-a", []string{"*.com"})
+	widenedPayload, _, _ := strings.Cut(widened, ".")
+	for name, tc := range map[string]struct {
+		key                        []byte
+		namespace, run, credential string
+	}{
+		"other run":       {key, "agents", "run-b", credential},
+		"other namespace": {key, "other", "run-a", credential},
+		"other key":       {NewEgressProxyKey(), "agents", "run-a", credential},
+		"swapped payload": {key, "agents", "run-a", widenedPayload + "." + signature},
+		"unsigned":        {key, "agents", "run-a", encoded},
+		"bad signature":   {key, "agents", "run-a", encoded + ".!"},
+		"bad payload":     {key, "agents", "run-a", "!." + signature},
+		"empty run":       {key, "agents", "", credential},
+	} {
+		if _, err := VerifyProxyCredential(tc.key, tc.namespace, tc.run, tc.credential); !errors.Is(err, ErrProxyCredentialInvalid) {
+			t.Errorf("%s: VerifyProxyCredential() error = %v, want %v", name, err, ErrProxyCredentialInvalid)
+		}
+	}
+}
+
+func TestVerifyProxyCredential_RejectsASignedNonList(t *testing.T) {
+	key := NewEgressProxyKey()
+	// Signed payloads that are not base64url, or not a JSON list once decoded.
+	for _, encoded := range []string{"!", "bm90LWpzb24"} {
+		credential := encoded + "." + base64.RawURLEncoding.EncodeToString(credentialMAC(key, "agents", "run", encoded))
+		if _, err := VerifyProxyCredential(key, "agents", "run", credential); !errors.Is(err, ErrProxyCredentialInvalid) {
+			t.Errorf("VerifyProxyCredential(%q) error = %v, want %v", encoded, err, ErrProxyCredentialInvalid)
+		}
+	}
+}
+
+func TestEgressProxyKey_RequiresAFullKey(t *testing.T) {
+	secret := BuildEgressProxySecret("agents", NewEgressProxyKey())
+	if key, err := EgressProxyKey(secret); err != nil || len(key) != egressProxyKeySize {
+		t.Fatalf("EgressProxyKey() = (%d bytes, %v), want a %d-byte key", len(key), err, egressProxyKeySize)
+	}
+	secret.Data[EgressProxyKeyName] = []byte("short")
+	if _, err := EgressProxyKey(secret); err == nil {
+		t.Fatal("EgressProxyKey(short) error = nil, want an error")
+	}
+}
+
+func TestProxyEnv_PointsAtTheNamespaceProxyWithTheCredential(t *testing.T) {
+	env := map[string]string{}
+	for _, variable := range ProxyEnv("agents", "run-a", "payload.signature") {
+		env[variable.Name] = variable.Value
+	}
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		parsed, err := url.Parse(env[name])
+		if err != nil {
+			t.Fatalf("%s = %q: %v", name, env[name], err)
+		}
+		password, _ := parsed.User.Password()
+		if parsed.Host != "agent-egress-proxy.agents.svc:3128" || parsed.User.Username() != "run-a" || password != "payload.signature" {
+			t.Errorf("%s = %q, want the run credential at the namespace proxy", name, env[name])
+		}
+	}
+	if env["NO_PROXY"] != "localhost,127.0.0.1" || env["no_proxy"] != env["NO_PROXY"] {
+		t.Errorf("NO_PROXY = %q, want loopback only", env["NO_PROXY"])
+	}
+}
+
+func TestEgressProxyResources_ShareTheProxySelector(t *testing.T) {
+	deployment := BuildEgressProxyDeployment("agents", "registry.example/operator@sha256:abc")
+	service := BuildEgressProxyService("agents")
+	policy := BuildEgressProxyNetworkPolicy("agents")
+
+	podLabels := deployment.Spec.Template.Labels
+	for name, selector := range map[string]map[string]string{
+		"deployment":    deployment.Spec.Selector.MatchLabels,
+		"service":       service.Spec.Selector,
+		"networkpolicy": policy.Spec.PodSelector.MatchLabels,
+		"run egress":    egressProxyRule().To[0].PodSelector.MatchLabels,
+	} {
+		for key, value := range selector {
+			if podLabels[key] != value {
+				t.Errorf("%s selector %s=%s does not match the proxy pods %v", name, key, value, podLabels)
+			}
+		}
+	}
+	if _, ok := podLabels["app.kubernetes.io/instance"]; ok {
+		t.Error("proxy pods carry an instance label that a run NetworkPolicy could select")
+	}
+
+	pod := deployment.Spec.Template.Spec
+	if pod.AutomountServiceAccountToken == nil || *pod.AutomountServiceAccountToken {
+		t.Error("proxy pods must not mount a ServiceAccount token")
+	}
+	container := pod.Containers[0]
+	if container.Command[0] != EgressProxyBinary || !slices.Contains(container.Args, "--namespace=agents") {
+		t.Errorf("proxy command = %v %v, want %s scoped to the namespace", container.Command, container.Args, EgressProxyBinary)
+	}
+	if pod.Volumes[0].Secret == nil || pod.Volumes[0].Secret.SecretName != EgressProxyName {
+		t.Errorf("proxy volumes = %v, want the key Secret", pod.Volumes)
+	}
+	if service.Spec.Ports[0].Port != EgressProxyPort {
+		t.Errorf("service port = %d, want %d", service.Spec.Ports[0].Port, EgressProxyPort)
+	}
+
+	if len(policy.Spec.Ingress) != 1 || policy.Spec.Ingress[0].From[0].PodSelector.MatchLabels["app.kubernetes.io/component"] != "agent-run" {
+		t.Errorf("proxy ingress = %v, want agent-run pods only", policy.Spec.Ingress)
+	}
+	upstream := policy.Spec.Egress[1]
+	if len(upstream.To) != 2 || !containsCIDR(upstream.To[0].IPBlock.Except, "10.0.0.0/8") {
+		t.Errorf("proxy upstream egress = %v, want public addresses only", upstream.To)
+	}
+	if upstream.Ports[0].Protocol == nil || *upstream.Ports[0].Protocol != corev1.ProtocolTCP {
+		t.Errorf("proxy upstream ports = %v, want TCP", upstream.Ports)
+	}
+}
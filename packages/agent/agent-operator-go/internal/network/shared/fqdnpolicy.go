@@ -0,0 +1,39 @@
+package shared
+
+import (
+	"errors"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+)
+
+// ErrFQDNPolicyUnavailable reports a network=fqdn run on a cluster that serves
+// no FQDN-aware network policy API.
+var ErrFQDNPolicyUnavailable = errors.New("network=fqdn requires an FQDN-aware network policy API (CiliumNetworkPolicy), which the cluster does not serve")
+
+// FQDNPolicyRealizer renders a network=fqdn run as a network-plugin policy that
+// matches egress by DNS name, the alternative to the IP-only NetworkPolicy that
+// BuildNetworkPolicy emits. Realizers are leaves wired in internal/plugins.
+type FQDNPolicyRealizer interface {
+	// GroupVersionKind is the policy kind the realizer emits; it applies only
+	// where the API server serves that kind.
+	GroupVersionKind() schema.GroupVersionKind
+	// BuildPolicy returns the run's policy: ingress denied, egress limited to
+	// DNS, the run's EgressAllow destinations and peers.
+	BuildPolicy(run *agentv1alpha1.AgentRun, peers ...PodPeer) (*unstructured.Unstructured, error)
+}
+
+// ResolveFQDNPolicyRealizer returns the first realizer whose policy kind mapper
+// resolves, or nil when the cluster serves none of them.
+func ResolveFQDNPolicyRealizer(mapper meta.RESTMapper, realizers []FQDNPolicyRealizer) FQDNPolicyRealizer {
+	for _, realizer := range realizers {
+		gvk := realizer.GroupVersionKind()
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			return realizer
+		}
+	}
+	return nil
+}
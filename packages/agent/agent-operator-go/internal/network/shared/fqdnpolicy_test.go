@@ -0,0 +1,33 @@
+package shared
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+)
+
+type stubRealizer schema.GroupVersionKind
+
+func (s stubRealizer) GroupVersionKind() schema.GroupVersionKind { return schema.GroupVersionKind(s) }
+
+func (stubRealizer) BuildPolicy(*agentv1alpha1.AgentRun, ...PodPeer) (*unstructured.Unstructured, error) {
+	return nil, nil
+}
+
+func TestResolveFQDNPolicyRealizer_PicksTheFirstServedKind(t *testing.T) {
+	served := stubRealizer{Group: "served.example", Version: "v1", Kind: "Policy"}
+	missing := stubRealizer{Group: "missing.example", Version: "v1", Kind: "Policy"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind(served), meta.RESTScopeNamespace)
+
+	if got := ResolveFQDNPolicyRealizer(mapper, []FQDNPolicyRealizer{missing, served}); got != served {
+		t.Errorf("ResolveFQDNPolicyRealizer() = %v, want the served realizer", got)
+	}
+	if got := ResolveFQDNPolicyRealizer(mapper, []FQDNPolicyRealizer{missing}); got != nil {
+		t.Errorf("ResolveFQDNPolicyRealizer() = %v, want nil when no kind is served", got)
+	}
+}
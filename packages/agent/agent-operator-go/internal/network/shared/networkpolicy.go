@@ -1,10 +1,13 @@
 // Package shared is the operator's network axis core: the per-AgentRun
-// NetworkPolicy builder. Network is a closed set mapped to egress rules; there is
-// no per-type leaf (the operator does not vary the realizer per network mode).
+// NetworkPolicy builder, the per-namespace egress proxy resources that
+// network=proxy runs are funnelled through, and the FQDNPolicyRealizer port
+// network=fqdn runs are rendered by, and the run-service policy shared by the
+// pods runs reach on their behalf (the proxy, model gateways). The other modes are a closed set mapped to
+// NetworkPolicy egress rules with no per-mode leaf. The proxy process and the
+// FQDN-aware realizers are leaves (network/proxy, network/cilium).
 package shared
 
 import (
-	"errors"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
@@ -16,27 +19,35 @@ import (
 	agentvalidation "github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/validation"
 )
 
-// ErrProxyEnforcementUnavailable reports that proxy mode has no backend capable
-// of preventing direct sockets from bypassing an HTTP(S) allowlist.
-var ErrProxyEnforcementUnavailable = errors.New("network=proxy has no enforceable FQDN-aware backend")
-
 // BuildNetworkPolicy creates a per-AgentRun NetworkPolicy. Ingress is always
 // denied. Egress is ALWAYS default-deny unless rules open it:
-//   - an explicit NetworkPolicy takes precedence, including an empty deny-all
-//     egress list;
-//   - otherwise host allows all and none/unset allows DNS only;
-//   - proxy fails closed until an FQDN-aware backend is available.
-func BuildNetworkPolicy(run *agentv1alpha1.AgentRun, np *agentv1alpha1.AgentNetworkPolicy) (*networkingv1.NetworkPolicy, error) {
-	if run.Spec.Network == agentv1alpha1.NetworkModeProxy {
-		return nil, fmt.Errorf("build network policy: %w", ErrProxyEnforcementUnavailable)
-	}
-
+//   - proxy allows DNS and the namespace egress proxy only, whatever the
+//     explicit NetworkPolicy says, so the proxy's allowlist is the only route
+//     out; it fails closed without an allowlist;
+//   - otherwise an explicit NetworkPolicy takes precedence, including an empty
+//     deny-all egress list;
+//   - otherwise host allows all and none/unset allows DNS only.
+//
+// Every mode but host also allows peers, the in-cluster services the run needs
+// whatever its network mode, such as its model gateway.
+func BuildNetworkPolicy(run *agentv1alpha1.AgentRun, np *agentv1alpha1.AgentNetworkPolicy, peers ...PodPeer) (*networkingv1.NetworkPolicy, error) {
 	var egress []networkingv1.NetworkPolicyEgressRule
-	if np != nil {
+	switch {
+	case run.Spec.Network == agentv1alpha1.NetworkModeProxy:
+		if len(run.Spec.EgressAllow) == 0 {
+			return nil, fmt.Errorf("build network policy: %w", ErrEgressAllowlistEmpty)
+		}
+		egress = []networkingv1.NetworkPolicyEgressRule{dnsEgressRule(), egressProxyRule()}
+	case np != nil:
 		egress = np.Egress
-	} else {
+	default:
 		egress = egressForNetwork(run.Spec.Network)
 	}
+	if run.Spec.Network != agentv1alpha1.NetworkModeHost {
+		for _, peer := range peers {
+			egress = append(egress, peer.egressRule())
+		}
+	}
 
 	return &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -105,6 +116,54 @@ func BuildWorkspaceInitNetworkPolicy(workspace *agentv1alpha1.AgentWorkspace) (*
 	}, nil
 }
 
+// PodPeer is an in-cluster service a run's pods may reach in its own
+// namespace: the pods carrying Labels, on TCP Port.
+type PodPeer struct {
+	Labels map[string]string
+	Port   int32
+}
+
+func (p PodPeer) egressRule() networkingv1.NetworkPolicyEgressRule {
+	tcp := corev1.ProtocolTCP
+	port := intstr.FromInt32(p.Port)
+	return networkingv1.NetworkPolicyEgressRule{
+		To:    []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: p.Labels}}},
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}},
+	}
+}
+
+// BuildRunServiceNetworkPolicy confines an operator-managed service that runs
+// reach, selected by labels: ingress only from agent-run pods on port, egress
+// only to DNS and public addresses, so nothing it forwards to can land in the
+// cluster or on the metadata service.
+func BuildRunServiceNetworkPolicy(namespace, name string, labels map[string]string, port int32) *networkingv1.NetworkPolicy {
+	tcp := corev1.ProtocolTCP
+	servicePort := intstr.FromInt32(port)
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: labels},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+				networkingv1.PolicyTypeEgress,
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app.kubernetes.io/name":      "agent-operator",
+						"app.kubernetes.io/component": "agent-run",
+					},
+				}}},
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &servicePort}},
+			}},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				dnsEgressRule(),
+				{To: publicNetworkPeers(), Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp}}},
+			},
+		},
+	}
+}
+
 func publicNetworkPeers() []networkingv1.NetworkPolicyPeer {
 	return []networkingv1.NetworkPolicyPeer{
 		{IPBlock: &networkingv1.IPBlock{
@@ -70,11 +70,34 @@ func TestBuildNetworkPolicy_NetworkMapping(t *testing.T) {
 		t.Errorf("host egress = %v, want one allow-all rule", eg)
 	}
 
-	// proxy fails closed because NetworkPolicy cannot enforce FQDN allowlists.
+	// proxy fails closed without an allowlist for the proxy to enforce.
 	proxy := newTestAgentRun("p", "default")
 	proxy.Spec.Network = "proxy"
-	if _, err := BuildNetworkPolicy(proxy, nil); !errors.Is(err, ErrProxyEnforcementUnavailable) {
-		t.Fatalf("BuildNetworkPolicy(proxy) error = %v, want %v", err, ErrProxyEnforcementUnavailable)
+	if _, err := BuildNetworkPolicy(proxy, nil); !errors.Is(err, ErrEgressAllowlistEmpty) {
+		t.Fatalf("BuildNetworkPolicy(proxy) error = %v, want %v", err, ErrEgressAllowlistEmpty)
+	}
+}
+
+func TestBuildNetworkPolicy_EgressAllowsOnlyDNSAndTheEgressProxy(t *testing.T) {
+	run := newTestAgentRun("p", "default")
+	run.Spec.Network = agentv1alpha1.NetworkModeProxy
+	run.Spec.EgressAllow = []string{"api.anthropic.com"}
+
+	// An explicit policy cannot open a route around the proxy.
+	np := mustBuildNetworkPolicy(t, run, &agentv1alpha1.AgentNetworkPolicy{
+		Egress: []networkingv1.NetworkPolicyEgressRule{{}},
+	})
+
+	if len(np.Spec.Egress) != 2 {
+		t.Fatalf("egress rules = %v, want DNS and the egress proxy", np.Spec.Egress)
+	}
+	proxyRule := np.Spec.Egress[1]
+	if len(proxyRule.To) != 1 || proxyRule.To[0].PodSelector == nil ||
+		proxyRule.To[0].PodSelector.MatchLabels["app.kubernetes.io/component"] != EgressProxyComponent {
+		t.Errorf("proxy rule destinations = %v, want the egress proxy pods", proxyRule.To)
+	}
+	if len(proxyRule.Ports) != 1 || proxyRule.Ports[0].Port.IntValue() != int(EgressProxyPort) {
+		t.Errorf("proxy rule ports = %v, want %d", proxyRule.Ports, EgressProxyPort)
 	}
 }
 
@@ -220,3 +243,56 @@ func containsCIDR(cidrs []string, target string) bool {
 func protocolPtr(p corev1.Protocol) *corev1.Protocol {
 	return &p
 }
+
+// Peers are the in-cluster services a run needs whatever its mode; host already
+// reaches everything, so it gains no rule.
+func TestBuildNetworkPolicy_AllowsPeersInEveryModeButHost(t *testing.T) {
+	peer := PodPeer{Labels: map[string]string{"agent.xonovex.com/gateway": "anthropic"}, Port: 8080}
+
+	none := newTestAgentRun("n", "default")
+	policy, err := BuildNetworkPolicy(none, nil, peer)
+	if err != nil {
+		t.Fatalf("BuildNetworkPolicy() error = %v", err)
+	}
+	if len(policy.Spec.Egress) != 2 {
+		t.Fatalf("egress rules = %d, want DNS plus the peer", len(policy.Spec.Egress))
+	}
+	rule := policy.Spec.Egress[1]
+	if rule.To[0].PodSelector == nil || rule.To[0].PodSelector.MatchLabels["agent.xonovex.com/gateway"] != "anthropic" {
+		t.Errorf("peer rule = %+v, want the gateway pods", rule)
+	}
+	if rule.Ports[0].Port.IntValue() != 8080 || *rule.Ports[0].Protocol != corev1.ProtocolTCP {
+		t.Errorf("peer ports = %+v, want TCP 8080", rule.Ports)
+	}
+
+	explicit, err := BuildNetworkPolicy(none, &agentv1alpha1.AgentNetworkPolicy{}, peer)
+	if err != nil || len(explicit.Spec.Egress) != 1 {
+		t.Errorf("explicit deny-all egress = %v (%v), want only the peer", explicit.Spec.Egress, err)
+	}
+
+	host := newTestAgentRun("h", "default")
+	host.Spec.Network = agentv1alpha1.NetworkModeHost
+	if eg := mustBuildNetworkPolicy(t, host, nil).Spec.Egress; len(eg) != 1 {
+		t.Errorf("host egress = %v, want only the allow-all rule", eg)
+	}
+	hostPolicy, err := BuildNetworkPolicy(host, nil, peer)
+	if err != nil || len(hostPolicy.Spec.Egress) != 1 {
+		t.Errorf("host egress with a peer = %v (%v), want only the allow-all rule", hostPolicy.Spec.Egress, err)
+	}
+}
+
+func TestBuildRunServiceNetworkPolicy_AdmitsOnlyAgentRuns(t *testing.T) {
+	labels := map[string]string{"app.kubernetes.io/component": "agent-gateway"}
+	policy := BuildRunServiceNetworkPolicy("agents", "anthropic-gateway", labels, 8080)
+
+	if policy.Name != "anthropic-gateway" || policy.Spec.PodSelector.MatchLabels["app.kubernetes.io/component"] != "agent-gateway" {
+		t.Errorf("policy = %s selecting %v", policy.Name, policy.Spec.PodSelector.MatchLabels)
+	}
+	from := policy.Spec.Ingress[0].From[0].PodSelector.MatchLabels
+	if from["app.kubernetes.io/component"] != "agent-run" || policy.Spec.Ingress[0].Ports[0].Port.IntValue() != 8080 {
+		t.Errorf("ingress = %+v, want agent-run pods on 8080", policy.Spec.Ingress)
+	}
+	if len(policy.Spec.Egress) != 2 {
+		t.Errorf("egress = %+v, want DNS and public addresses", policy.Spec.Egress)
+	}
+}
@@ -1,6 +1,7 @@
 // Package plugins is the operator composition root: it holds the per-axis
 // registries and is the ONLY package that imports the concrete axis leaves
-// (provision/nix, workspace/{git,jj}, harness/{claude,opencode}). Each axis's
+// (provision/nix, workspace/{git,jj}, harness/{claude,opencode},
+// network/cilium). Each axis's
 // shared/ package holds only its leaf-free port, so the cores stay neutral and
 // symmetric with the CLI's internal/sandbox/plugins composition root.
 package plugins
@@ -12,6 +13,8 @@ import (
 	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/harness/claude"
 	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/harness/opencode"
 	harnessshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/harness/shared"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/cilium"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
 	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provision/nix"
 	provshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provision/shared"
 	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/workspace/git"
@@ -56,3 +59,9 @@ func GetVCSStrategy(wsType agentv1alpha1.WorkspaceType) (wsshared.VCSStrategy, e
 		return nil, fmt.Errorf("unsupported workspace type: %s", wsType)
 	}
 }
+
+// FQDNPolicyRealizers returns the FQDN-aware network policy realizers in
+// preference order; the first one the cluster serves renders network=fqdn runs.
+func FQDNPolicyRealizers() []netshared.FQDNPolicyRealizer {
+	return []netshared.FQDNPolicyRealizer{cilium.Realizer{}}
+}
@@ -108,3 +108,10 @@ func TestGetVCSStrategyRejectsUnregisteredWorkspace(t *testing.T) {
 		t.Errorf("error %q must name the unsupported workspace type", err)
 	}
 }
+
+func TestFQDNPolicyRealizersPreferCilium(t *testing.T) {
+	realizers := FQDNPolicyRealizers()
+	if len(realizers) == 0 || realizers[0].GroupVersionKind().Kind != "CiliumNetworkPolicy" {
+		t.Fatalf("FQDNPolicyRealizers() = %v, want the Cilium realizer first", realizers)
+	}
+}
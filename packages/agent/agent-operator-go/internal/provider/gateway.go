@@ -0,0 +1,320 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
+)
+
+const (
+	// GatewayComponent labels every AgentGateway resource and selects its pods.
+	GatewayComponent = "agent-gateway"
+	// GatewayLabel carries the AgentGateway name on its resources and pods.
+	GatewayLabel = "agent.xonovex.com/gateway"
+	// GatewayPort is the gateway's HTTP port.
+	GatewayPort int32 = 8080
+	// GatewayKeyDir is where the gateway mounts its key Secret.
+	GatewayKeyDir = "/etc/agent-gateway/key"
+	// GatewayKeyName is the key Secret's signing key data key and file name.
+	GatewayKeyName = "key"
+	// GatewayRevokedName is the key Secret's revocation list data key and file
+	// name: a JSON object of revoked run UIDs to their token expiry (Unix).
+	GatewayRevokedName = "revoked"
+	// GatewayCredentialDir is where the gateway mounts the provider credential.
+	GatewayCredentialDir = "/etc/agent-gateway/credential"
+	// GatewayCredentialName is the mounted credential's file name.
+	GatewayCredentialName = "credential"
+	// GatewayBinary is the gateway executable shipped in the operator image.
+	GatewayBinary = "/agent-gateway"
+
+	gatewayKeySize     = 32
+	gatewayTokenPrefix = "agw1."
+	// gatewayTokenGrace outlives the run timeout so a token never expires
+	// under a run that is still inside its deadline.
+	gatewayTokenGrace = 15 * time.Minute
+)
+
+// ErrGatewayTokenInvalid reports a gateway token that is malformed, signed
+// with another key, for another gateway, expired or revoked.
+var ErrGatewayTokenInvalid = errors.New("invalid agent gateway token")
+
+// GatewayClaims is what a per-run gateway token asserts.
+type GatewayClaims struct {
+	Namespace string `json:"ns"`
+	Gateway   string `json:"gw"`
+	Run       string `json:"run"`
+	UID       string `json:"uid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// GatewayResourceName names the Deployment, Service, key Secret and
+// NetworkPolicy of the AgentGateway called name.
+func GatewayResourceName(name string) string {
+	return name + "-gateway"
+}
+
+// GatewayURL is the in-cluster base URL of an AgentGateway.
+func GatewayURL(namespace, name string) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", GatewayResourceName(name), namespace, GatewayPort)
+}
+
+// GatewayPeer is the run NetworkPolicy peer that admits the gateway's pods.
+func GatewayPeer(name string) netshared.PodPeer {
+	return netshared.PodPeer{Labels: gatewayLabels(name), Port: GatewayPort}
+}
+
+// gatewayLabels deliberately omit app.kubernetes.io/instance, which run
+// NetworkPolicies select on, so no run's policy can ever apply to a gateway.
+func gatewayLabels(name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":      "agent-operator",
+		"app.kubernetes.io/component": GatewayComponent,
+		GatewayLabel:                  name,
+	}
+}
+
+// NewGatewayKey returns a random key for signing gateway tokens.
+func NewGatewayKey() []byte {
+	key := make([]byte, gatewayKeySize)
+	_, _ = rand.Read(key) // crypto/rand.Read never returns an error.
+	return key
+}
+
+// GatewayKey returns the signing key held by a gateway key Secret.
+func GatewayKey(secret *corev1.Secret) ([]byte, error) {
+	key := secret.Data[GatewayKeyName]
+	if len(key) < gatewayKeySize {
+		return nil, fmt.Errorf("agent gateway Secret %q has no %d-byte %q key", secret.Name, gatewayKeySize, GatewayKeyName)
+	}
+	return key, nil
+}
+
+// BuildGatewayKeySecret holds the key the operator signs run tokens with and
+// the revocation list, both of which the gateway mounts. Agent pods never do.
+func BuildGatewayKeySecret(gateway *agentv1alpha1.AgentGateway, key []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GatewayResourceName(gateway.Name),
+			Namespace: gateway.Namespace,
+			Labels:    gatewayLabels(gateway.Name),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{GatewayKeyName: key, GatewayRevokedName: []byte("{}")},
+	}
+}
+
+// BuildGatewayDeployment runs an AgentGateway from image (the operator image,
+// which ships the gateway binary). The pod alone mounts the provider credential;
+// it is hardened like agent pods and has no Kubernetes API access.
+func BuildGatewayDeployment(gateway *agentv1alpha1.AgentGateway, image string) *appsv1.Deployment {
+	replicas := int32(1)
+	automount := false
+	nonRoot := true
+	noEscalation := false
+	readOnly := true
+	secretMode := int32(0o440)
+	labels := gatewayLabels(gateway.Name)
+	name := GatewayResourceName(gateway.Name)
+	port := intstr.FromInt32(GatewayPort)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: gateway.Namespace, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: gatewayLabels(gateway.Name)},
+				Spec: corev1.PodSpec{
+					AutomountServiceAccountToken: &automount,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot:   &nonRoot,
+						SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+					},
+					Containers: []corev1.Container{{
+						Name:    "gateway",
+						Image:   image,
+						Command: []string{GatewayBinary},
+						Args: []string{
+							fmt.Sprintf("--listen=:%d", GatewayPort),
+							"--namespace=" + gateway.Namespace,
+							"--gateway=" + gateway.Name,
+							"--upstream=" + gateway.Spec.Upstream,
+							"--key-file=" + GatewayKeyDir + "/" + GatewayKeyName,
+							"--revoked-file=" + GatewayKeyDir + "/" + GatewayRevokedName,
+							"--credential-file=" + GatewayCredentialDir + "/" + GatewayCredentialName,
+						},
+						Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: GatewayPort, Protocol: corev1.ProtocolTCP}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: port}},
+						},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("10m"),
+								corev1.ResourceMemory: resource.MustParse("32Mi"),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("500m"),
+								corev1.ResourceMemory: resource.MustParse("128Mi"),
+							},
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: &noEscalation,
+							ReadOnlyRootFilesystem:   &readOnly,
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "key", MountPath: GatewayKeyDir, ReadOnly: true},
+							{Name: "credential", MountPath: GatewayCredentialDir, ReadOnly: true},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{
+							Name: "key",
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+								SecretName:  name,
+								DefaultMode: &secretMode,
+							}},
+						},
+						{
+							Name: "credential",
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+								SecretName:  gateway.Spec.CredentialSecretRef.Name,
+								Items:       []corev1.KeyToPath{{Key: gateway.Spec.CredentialSecretRef.Key, Path: GatewayCredentialName}},
+								DefaultMode: &secretMode,
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// BuildGatewayService exposes the gateway pods on GatewayPort.
+func BuildGatewayService(gateway *agentv1alpha1.AgentGateway) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GatewayResourceName(gateway.Name),
+			Namespace: gateway.Namespace,
+			Labels:    gatewayLabels(gateway.Name),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: gatewayLabels(gateway.Name),
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Port:       GatewayPort,
+				TargetPort: intstr.FromInt32(GatewayPort),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// BuildGatewayNetworkPolicy confines the gateway: ingress only from agent-run
+// pods, egress only to DNS and public addresses.
+func BuildGatewayNetworkPolicy(gateway *agentv1alpha1.AgentGateway) *networkingv1.NetworkPolicy {
+	return netshared.BuildRunServiceNetworkPolicy(gateway.Namespace, GatewayResourceName(gateway.Name), gatewayLabels(gateway.Name), GatewayPort)
+}
+
+// GatewayTokenExpiry is when a run's gateway token stops verifying: timeout
+// after the run was created, plus a grace period. Deriving it from the run
+// keeps the token, and so the Job spec, the same on every reconcile.
+func GatewayTokenExpiry(run *agentv1alpha1.AgentRun, timeout time.Duration) time.Time {
+	return run.CreationTimestamp.Add(timeout + gatewayTokenGrace).Truncate(time.Second)
+}
+
+// SignGatewayToken returns the run's gateway token: its claims and an HMAC
+// over them. The gateway is shared by every run that references it, so the
+// token is what binds a request to one run and one lifetime.
+func SignGatewayToken(key []byte, claims GatewayClaims) string {
+	payload, _ := json.Marshal(claims) // a struct of strings and ints always encodes.
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return gatewayTokenPrefix + encoded + "." + base64.RawURLEncoding.EncodeToString(gatewayTokenMAC(key, encoded))
+}
+
+// VerifyGatewayToken checks a token against key and returns its claims when it
+// was issued for the gateway and has not expired at now.
+func VerifyGatewayToken(key []byte, namespace, gateway, token string, now time.Time) (GatewayClaims, error) {
+	body, found := strings.CutPrefix(token, gatewayTokenPrefix)
+	if !found {
+		return GatewayClaims{}, ErrGatewayTokenInvalid
+	}
+	encoded, signature, found := strings.Cut(body, ".")
+	if !found {
+		return GatewayClaims{}, ErrGatewayTokenInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, gatewayTokenMAC(key, encoded)) {
+		return GatewayClaims{}, ErrGatewayTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return GatewayClaims{}, ErrGatewayTokenInvalid
+	}
+	var claims GatewayClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return GatewayClaims{}, ErrGatewayTokenInvalid
+	}
+	if claims.Namespace != namespace || claims.Gateway != gateway || claims.UID == "" || !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return GatewayClaims{}, ErrGatewayTokenInvalid
+	}
+	return claims, nil
+}
+
+func gatewayTokenMAC(key []byte, encoded string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(gatewayTokenPrefix + encoded))
+	return mac.Sum(nil)
+}
+
+// GatewayRevocations parses a revocation list: revoked run UIDs to the Unix
+// time their token expires anyway. An empty list is valid.
+func GatewayRevocations(data []byte) (map[string]int64, error) {
+	revoked := map[string]int64{}
+	if len(data) == 0 {
+		return revoked, nil
+	}
+	if err := json.Unmarshal(data, &revoked); err != nil {
+		return nil, fmt.Errorf("parse agent gateway revocation list: %w", err)
+	}
+	return revoked, nil
+}
+
+// RevokeGatewayToken adds a run's token to the key Secret's revocation list
+// and drops entries whose tokens have expired by now, which bounds the list
+// by the runs still inside their token lifetime.
+func RevokeGatewayToken(secret *corev1.Secret, uid string, expiresAt, now time.Time) error {
+	revoked, err := GatewayRevocations(secret.Data[GatewayRevokedName])
+	if err != nil {
+		return err
+	}
+	for entry, expiry := range revoked {
+		if !now.Before(time.Unix(expiry, 0)) {
+			delete(revoked, entry)
+		}
+	}
+	if now.Before(expiresAt) {
+		revoked[uid] = expiresAt.Unix()
+	}
+	data, _ := json.Marshal(revoked) // a map of strings to ints always encodes.
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[GatewayRevokedName] = data
+	return nil
+}
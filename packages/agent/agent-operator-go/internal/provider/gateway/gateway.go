@@ -0,0 +1,70 @@
+// Package gateway is the AgentGateway process the managed Deployment runs: the
+// shared credential shield with per-run tokens. Every run that references the
+// gateway presents its own signed token; the gateway verifies it against the
+// gateway key, refuses it once expired or listed as revoked, and otherwise
+// forwards upstream with the provider credential in its place.
+package gateway
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provider"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/credshield"
+)
+
+// Gateway verifies run tokens for one AgentGateway.
+type Gateway struct {
+	key       []byte
+	namespace string
+	name      string
+	now       func() time.Time
+
+	mu      sync.RWMutex
+	revoked map[string]int64
+}
+
+// New returns the gateway for the AgentGateway name in namespace, verifying
+// run tokens with key.
+func New(key []byte, namespace, name string) *Gateway {
+	return &Gateway{key: key, namespace: namespace, name: name, now: time.Now, revoked: map[string]int64{}}
+}
+
+// Server returns the credential shield that forwards to upstream with
+// credential for every token the gateway accepts.
+func (g *Gateway) Server(upstream, credential string) (*credshield.Server, error) {
+	return credshield.NewAuthorizing(upstream, credential, g.Authorize)
+}
+
+// Authorize reports whether token is a current, unrevoked token for this
+// gateway.
+func (g *Gateway) Authorize(token string) bool {
+	claims, err := provider.VerifyGatewayToken(g.key, g.namespace, g.name, token, g.now())
+	if err != nil {
+		return false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, revoked := g.revoked[claims.UID]
+	return !revoked
+}
+
+// LoadRevoked replaces the revocation list with the one in path, the key
+// Secret's mounted revocation file. A list that fails to load leaves the
+// current one in force.
+func (g *Gateway) LoadRevoked(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read agent gateway revocation list: %w", err)
+	}
+	revoked, err := provider.GatewayRevocations(data)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.revoked = revoked
+	g.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/provider"
+)
+
+func mint(t *testing.T, key []byte, uid string, expiresAt time.Time) string {
+	t.Helper()
+	return provider.SignGatewayToken(key, provider.GatewayClaims{
+		Namespace: "agents",
+		Gateway:   "anthropic",
+		Run:       "solo",
+		UID:       uid,
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
+
+func writeRevoked(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), provider.GatewayRevokedName)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write revocation list: %v", err)
+	}
+	return path
+}
+
+func TestAuthorize_AcceptsCurrentTokensUntilRevoked(t *testing.T) {
+	key := provider.NewGatewayKey()
+	g := New(key, "agents", "anthropic")
+	token := mint(t, key, "uid-1", time.Now().Add(time.Hour))
+
+	if !g.Authorize(token) {
+		t.Fatal("Authorize(current token) = false, want true")
+	}
+	if g.Authorize(mint(t, key, "uid-2", time.Now().Add(-time.Minute))) {
+		t.Error("Authorize(expired token) = true, want false")
+	}
+	if New(key, "agents", "openai").Authorize(token) {
+		t.Error("Authorize(token for another gateway) = true, want false")
+	}
+
+	if err := g.LoadRevoked(writeRevoked(t, `{"uid-1":4102444800}`)); err != nil {
+		t.Fatalf("LoadRevoked() error = %v", err)
+	}
+	if g.Authorize(token) {
+		t.Error("Authorize(revoked token) = true, want false")
+	}
+}
+
+// A list that cannot be read or parsed leaves the last good one in force
+// rather than readmitting revoked runs.
+func TestLoadRevoked_KeepsTheCurrentListOnError(t *testing.T) {
+	key := provider.NewGatewayKey()
+	g := New(key, "agents", "anthropic")
+	if err := g.LoadRevoked(writeRevoked(t, `{"uid-1":4102444800}`)); err != nil {
+		t.Fatalf("LoadRevoked() error = %v", err)
+	}
+
+	if err := g.LoadRevoked(filepath.Join(t.TempDir(), "absent")); err == nil {
+		t.Error("LoadRevoked(absent) error = nil, want a read error")
+	}
+	if err := g.LoadRevoked(writeRevoked(t, "[")); err == nil {
+		t.Error("LoadRevoked(corrupt) error = nil, want a parse error")
+	}
+	if g.Authorize(mint(t, key, "uid-1", time.Now().Add(time.Hour))) {
+		t.Error("a failed reload readmitted a revoked token")
+	}
+}
+
+func TestServer_SwapsTheRunTokenForTheCredential(t *testing.T) {
+	var authorization string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer upstream.Close()
+
+	key := provider.NewGatewayKey()
+	g := New(key, "agents", "anthropic")
+	server, err := g.Server(upstream.URL, "real-credential")
+	if err != nil {
+		t.Fatalf("Server() error = %v", err)
+	}
+	front := httptest.NewServer(server)
+	defer front.Close()
+
+	for token, want := range map[string]int{
+		mint(t, key, "uid-1", time.Now().Add(time.Hour)): http.StatusOK,
+		"agw1.forged.token": http.StatusUnauthorized,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, front.URL+"/v1/messages", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request through gateway: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("status = %d, want %d", resp.StatusCode, want)
+		}
+	}
+	if authorization != "Bearer real-credential" {
+		t.Errorf("upstream Authorization = %q, want the credential", authorization)
+	}
+}
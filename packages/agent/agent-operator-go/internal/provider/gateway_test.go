@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+)
+
+func testGateway() *agentv1alpha1.AgentGateway {
+	return &agentv1alpha1.AgentGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "anthropic", Namespace: "agents"},
+		Spec: agentv1alpha1.AgentGatewaySpec{
+			Upstream:            "https://api.anthropic.com",
+			CredentialSecretRef: agentv1alpha1.SecretKeyRef{Name: "anthropic-credential", Key: "api-key"},
+		},
+	}
+}
+
+func testClaims(expiresAt time.Time) GatewayClaims {
+	return GatewayClaims{Namespace: "agents", Gateway: "anthropic", Run: "solo", UID: "uid-1", ExpiresAt: expiresAt.Unix()}
+}
+
+// The gateway mounts the credential under a fixed name and the key Secret
+// beside it; nothing it runs is selectable by a run NetworkPolicy.
+func TestBuildGatewayDeployment_MountsTheCredentialOnlyInTheGateway(t *testing.T) {
+	deployment := BuildGatewayDeployment(testGateway(), "ghcr.io/xonovex/agent-operator-go@sha256:"+strings.Repeat("0", 64))
+
+	if deployment.Name != "anthropic-gateway" || deployment.Namespace != "agents" {
+		t.Errorf("Deployment = %s/%s, want agents/anthropic-gateway", deployment.Namespace, deployment.Name)
+	}
+	if _, ok := deployment.Spec.Template.Labels["app.kubernetes.io/instance"]; ok {
+		t.Error("gateway pods carry app.kubernetes.io/instance, which run NetworkPolicies select on")
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if !slices.Equal(container.Command, []string{GatewayBinary}) {
+		t.Errorf("command = %v, want [%s]", container.Command, GatewayBinary)
+	}
+	for _, want := range []string{"--namespace=agents", "--gateway=anthropic", "--upstream=https://api.anthropic.com"} {
+		if !slices.Contains(container.Args, want) {
+			t.Errorf("args = %v, want %q", container.Args, want)
+		}
+	}
+	volumes := deployment.Spec.Template.Spec.Volumes
+	if len(volumes) != 2 || volumes[0].Secret.SecretName != "anthropic-gateway" {
+		t.Fatalf("volumes = %+v, want the key Secret then the credential", volumes)
+	}
+	credential := volumes[1].Secret
+	if credential.SecretName != "anthropic-credential" || len(credential.Items) != 1 ||
+		credential.Items[0].Key != "api-key" || credential.Items[0].Path != GatewayCredentialName {
+		t.Errorf("credential volume = %+v, want api-key mounted as %q", credential, GatewayCredentialName)
+	}
+	if automount := deployment.Spec.Template.Spec.AutomountServiceAccountToken; automount == nil || *automount {
+		t.Error("gateway pod mounts a ServiceAccount token")
+	}
+}
+
+func TestBuildGatewayResources_ShareTheGatewaySelector(t *testing.T) {
+	gateway := testGateway()
+	secret := BuildGatewayKeySecret(gateway, NewGatewayKey())
+	service := BuildGatewayService(gateway)
+	policy := BuildGatewayNetworkPolicy(gateway)
+
+	if string(secret.Data[GatewayRevokedName]) != "{}" {
+		t.Errorf("revocation list = %q, want an empty list", secret.Data[GatewayRevokedName])
+	}
+	if _, err := GatewayKey(secret); err != nil {
+		t.Errorf("GatewayKey() error = %v", err)
+	}
+	if service.Spec.Selector[GatewayLabel] != "anthropic" || service.Spec.Ports[0].Port != GatewayPort {
+		t.Errorf("Service = %+v, want the anthropic gateway pods on %d", service.Spec, GatewayPort)
+	}
+	if policy.Name != "anthropic-gateway" || policy.Spec.PodSelector.MatchLabels[GatewayLabel] != "anthropic" {
+		t.Errorf("NetworkPolicy = %s selecting %v, want the anthropic gateway", policy.Name, policy.Spec.PodSelector.MatchLabels)
+	}
+	peer := GatewayPeer("anthropic")
+	if peer.Port != GatewayPort || peer.Labels[GatewayLabel] != "anthropic" {
+		t.Errorf("GatewayPeer() = %+v, want the anthropic gateway pods", peer)
+	}
+	if got := GatewayURL("agents", "anthropic"); got != "http://anthropic-gateway.agents.svc:8080" {
+		t.Errorf("GatewayURL() = %q", got)
+	}
+}
+
+func TestGatewayKey_RejectsAShortKey(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "anthropic-gateway"}, Data: map[string][]byte{GatewayKeyName: []byte("short")}}
+	if _, err := GatewayKey(secret); err == nil {
+		t.Error("GatewayKey() error = nil, want a short-key error")
+	}
+}
+
+func TestVerifyGatewayToken_AcceptsOnlyTheIssuedGatewayUntilExpiry(t *testing.T) {
+	key := NewGatewayKey()
+	now := time.Unix(1_800_000_000, 0)
+	token := SignGatewayToken(key, testClaims(now.Add(time.Hour)))
+
+	claims, err := VerifyGatewayToken(key, "agents", "anthropic", token, now)
+	if err != nil {
+		t.Fatalf("VerifyGatewayToken() error = %v", err)
+	}
+	if claims.Run != "solo" || claims.UID != "uid-1" {
+		t.Errorf("claims = %+v, want run solo uid-1", claims)
+	}
+
+	for name, verify := range map[string]func() error{
+		"other key": func() error {
+			_, err := VerifyGatewayToken(NewGatewayKey(), "agents", "anthropic", token, now)
+			return err
+		},
+		"other namespace": func() error { _, err := VerifyGatewayToken(key, "other", "anthropic", token, now); return err },
+		"other gateway":   func() error { _, err := VerifyGatewayToken(key, "agents", "openai", token, now); return err },
+		"expired": func() error {
+			_, err := VerifyGatewayToken(key, "agents", "anthropic", token, now.Add(time.Hour))
+			return err
+		},
+		"no prefix": func() error { _, err := VerifyGatewayToken(key, "agents", "anthropic", "x"+token, now); return err },
+		"no signature": func() error {
+			_, err := VerifyGatewayToken(key, "agents", "anthropic", strings.Split(token, ".")[0]+"."+strings.Split(token, ".")[1], now)
+			return err
+		},
+		"bad signature": func() error { _, err := VerifyGatewayToken(key, "agents", "anthropic", token+"!", now); return err },
+	} {
+		if err := verify(); !errors.Is(err, ErrGatewayTokenInvalid) {
+			t.Errorf("%s: error = %v, want %v", name, err, ErrGatewayTokenInvalid)
+		}
+	}
+}
+
+func TestVerifyGatewayToken_RejectsSignedButMalformedPayloads(t *testing.T) {
+	key := NewGatewayKey()
+	now := time.Unix(1_800_000_000, 0)
+	for name, encoded := range map[string]string{"not base64": "!!!", "not json": "bm90IGpzb24"} {
+		token := gatewayTokenPrefix + encoded + "." + base64.RawURLEncoding.EncodeToString(gatewayTokenMAC(key, encoded))
+		if _, err := VerifyGatewayToken(key, "agents", "anthropic", token, now); !errors.Is(err, ErrGatewayTokenInvalid) {
+			t.Errorf("%s: error = %v, want %v", name, err, ErrGatewayTokenInvalid)
+		}
+	}
+	claims := testClaims(now.Add(time.Hour))
+	claims.UID = ""
+	if _, err := VerifyGatewayToken(key, "agents", "anthropic", SignGatewayToken(key, claims), now); !errors.Is(err, ErrGatewayTokenInvalid) {
+		t.Errorf("token without a run UID: error = %v, want %v", err, ErrGatewayTokenInvalid)
+	}
+}
+
+// The expiry is derived from the run rather than the clock, so re-minting on a
+// retried reconcile yields the same token and the same Job spec.
+func TestGatewayTokenExpiry_IsDerivedFromTheRun(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+	run := &agentv1alpha1.AgentRun{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+
+	got := GatewayTokenExpiry(run, time.Hour)
+	if want := created.Add(time.Hour + gatewayTokenGrace).Truncate(time.Second); !got.Equal(want) {
+		t.Errorf("GatewayTokenExpiry() = %v, want %v", got, want)
+	}
+}
+
+func TestRevokeGatewayToken_AddsTheRunAndPrunesExpiredEntries(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	secret := &corev1.Secret{Data: map[string][]byte{GatewayRevokedName: []byte(`{"stale":1700000000,"live":1900000000}`)}}
+
+	if err := RevokeGatewayToken(secret, "uid-1", now.Add(time.Hour), now); err != nil {
+		t.Fatalf("RevokeGatewayToken() error = %v", err)
+	}
+	if err := RevokeGatewayToken(secret, "uid-expired", now.Add(-time.Hour), now); err != nil {
+		t.Fatalf("RevokeGatewayToken() error = %v", err)
+	}
+	revoked, err := GatewayRevocations(secret.Data[GatewayRevokedName])
+	if err != nil {
+		t.Fatalf("GatewayRevocations() error = %v", err)
+	}
+	if len(revoked) != 2 || revoked["uid-1"] != now.Add(time.Hour).Unix() || revoked["live"] == 0 {
+		t.Errorf("revoked = %v, want live and uid-1 only", revoked)
+	}
+
+	empty := &corev1.Secret{}
+	if err := RevokeGatewayToken(empty, "uid-1", now.Add(time.Hour), now); err != nil || len(empty.Data[GatewayRevokedName]) == 0 {
+		t.Errorf("RevokeGatewayToken(no data) = %v, %q; want a new list", err, empty.Data[GatewayRevokedName])
+	}
+	corrupt := &corev1.Secret{Data: map[string][]byte{GatewayRevokedName: []byte("[")}}
+	if err := RevokeGatewayToken(corrupt, "uid-1", now.Add(time.Hour), now); err == nil {
+		t.Error("RevokeGatewayToken(corrupt list) error = nil, want a parse error")
+	}
+}
+
+func TestResolveProvider_GatewayRefGivesTheRunTheGatewayURLAndNoSecret(t *testing.T) {
+	provider := &agentv1alpha1.AgentProvider{
+		ObjectMeta: metav1.ObjectMeta{Name: "gated", Namespace: "agents"},
+		Spec:       agentv1alpha1.AgentProviderSpec{PresetRef: "glm", GatewayRef: "anthropic"},
+	}
+	c := fake.NewClientBuilder().WithScheme(newScheme()).WithObjects(provider, testGateway()).Build()
+	run := &agentv1alpha1.AgentRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "solo", Namespace: "agents"},
+		Spec:       agentv1alpha1.AgentRunSpec{ProviderRef: "gated"},
+	}
+
+	resolved, err := ResolveProvider(context.Background(), c, run, "")
+	if err != nil {
+		t.Fatalf("ResolveProvider() error = %v", err)
+	}
+	if resolved.Gateway != "anthropic" || resolved.TokenEnv != "ANTHROPIC_AUTH_TOKEN" {
+		t.Errorf("gateway = (%q, %q), want (anthropic, ANTHROPIC_AUTH_TOKEN)", resolved.Gateway, resolved.TokenEnv)
+	}
+	if got := envValue(t, resolved.Environment, "ANTHROPIC_BASE_URL"); got != "http://anthropic-gateway.agents.svc:8080" {
+		t.Errorf("ANTHROPIC_BASE_URL = %q, want the gateway URL", got)
+	}
+	for _, variable := range resolved.Environment {
+		if variable.ValueFrom != nil || variable.Name == "ANTHROPIC_AUTH_TOKEN" {
+			t.Errorf("environment carries %s, want no credential before the token is minted", variable.Name)
+		}
+	}
+}
+
+func TestResolveProvider_GatewayRefFailures(t *testing.T) {
+	tests := map[string]agentv1alpha1.AgentProviderSpec{
+		"missing gateway": {PresetRef: "glm", GatewayRef: "absent"},
+		"also a secret": {
+			PresetRef:          "glm",
+			GatewayRef:         "anthropic",
+			AuthTokenSecretRef: &agentv1alpha1.SecretKeyRef{Name: "anthropic-credential", Key: "api-key"},
+		},
+		"no destinations": {GatewayRef: "anthropic"},
+	}
+	for name, spec := range tests {
+		t.Run(name, func(t *testing.T) {
+			provider := &agentv1alpha1.AgentProvider{ObjectMeta: metav1.ObjectMeta{Name: "gated", Namespace: "agents"}, Spec: spec}
+			c := fake.NewClientBuilder().WithScheme(newScheme()).WithObjects(provider, testGateway()).Build()
+			run := &agentv1alpha1.AgentRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "solo", Namespace: "agents"},
+				Spec:       agentv1alpha1.AgentRunSpec{ProviderRef: "gated"},
+			}
+			if _, err := ResolveProvider(context.Background(), c, run, ""); err == nil {
+				t.Error("ResolveProvider() error = nil, want an error")
+			}
+		})
+	}
+}
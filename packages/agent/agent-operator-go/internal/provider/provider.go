@@ -1,6 +1,7 @@
 // Package provider is the provider axis: it resolves an AgentRun's model-provider
 // configuration (inline or referenced) into the environment the agent container
-// needs while preserving Secret references.
+// needs while preserving Secret references, and builds the AgentGateway that
+// holds a provider credential on behalf of runs.
 package provider
 
 import (
@@ -21,6 +22,10 @@ import (
 type ResolvedProvider struct {
 	Environment []corev1.EnvVar
 	CliArgs     []string
+	// Gateway is the AgentGateway holding the provider credential, if any. The
+	// run then needs a gateway token minted into TokenEnv.
+	Gateway  string
+	TokenEnv string
 }
 
 // ResolveProvider resolves the provider configuration for an agent execution.
@@ -61,6 +66,9 @@ func ResolveProvider(ctx context.Context, c client.Client, run *agentv1alpha1.Ag
 	if authTokenEnv == "" {
 		authTokenEnv = preset.authTokenEnv
 	}
+	if provider.Spec.GatewayRef != "" {
+		return resolveGatewayProvider(ctx, c, run.Namespace, &provider, preset, authTokenEnv, env, cliArgs)
+	}
 	if err := validateAuthToken(ctx, c, run.Namespace, authTokenEnv, provider.Spec.AuthTokenSecretRef, env); err != nil {
 		return ResolvedProvider{}, fmt.Errorf("failed to resolve auth token: %w", err)
 	}
@@ -68,6 +76,43 @@ func ResolveProvider(ctx context.Context, c client.Client, run *agentv1alpha1.Ag
 	return ResolvedProvider{Environment: environmentVariables(env, authTokenEnv, provider.Spec.AuthTokenSecretRef), CliArgs: cliArgs}, nil
 }
 
+// resolveGatewayProvider points the agent at the provider's AgentGateway. The
+// run gets the gateway URL here and only a per-run token later, never the
+// credential Secret.
+func resolveGatewayProvider(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	provider *agentv1alpha1.AgentProvider,
+	preset presetConfig,
+	tokenEnv string,
+	env map[string]string,
+	cliArgs []string,
+) (ResolvedProvider, error) {
+	if provider.Spec.AuthTokenSecretRef != nil {
+		return ResolvedProvider{}, fmt.Errorf("provider %s sets both gatewayRef and authTokenSecretRef", provider.Name)
+	}
+	baseURLEnv := provider.Spec.BaseURLEnv
+	if baseURLEnv == "" {
+		baseURLEnv = preset.baseURLEnv
+	}
+	if tokenEnv == "" || baseURLEnv == "" {
+		return ResolvedProvider{}, fmt.Errorf("provider %s needs authTokenEnv and baseURLEnv (or a preset supplying them) for gatewayRef", provider.Name)
+	}
+	var gateway agentv1alpha1.AgentGateway
+	if err := c.Get(ctx, types.NamespacedName{Name: provider.Spec.GatewayRef, Namespace: namespace}, &gateway); err != nil {
+		return ResolvedProvider{}, fmt.Errorf("failed to get gateway %s: %w", provider.Spec.GatewayRef, err)
+	}
+	delete(env, tokenEnv)
+	env[baseURLEnv] = GatewayURL(namespace, gateway.Name)
+	return ResolvedProvider{
+		Environment: environmentVariables(env, "", nil),
+		CliArgs:     cliArgs,
+		Gateway:     gateway.Name,
+		TokenEnv:    tokenEnv,
+	}, nil
+}
+
 func resolveInlineProvider(ctx context.Context, c client.Client, namespace string, spec *agentv1alpha1.ProviderSpec) (ResolvedProvider, error) {
 	env := make(map[string]string)
 
@@ -117,6 +162,7 @@ func environmentVariables(values map[string]string, authTokenEnv string, authSec
 
 type presetConfig struct {
 	authTokenEnv string
+	baseURLEnv   string
 	cliArgs      []string
 }
 
@@ -137,6 +183,7 @@ func mergePreset(env map[string]string, presetRef, agentType string) (presetConf
 	}
 	return presetConfig{
 		authTokenEnv: preset.CredentialTargetEnv,
+		baseURLEnv:   preset.BaseURLEnv,
 		cliArgs:      append([]string{}, preset.CliArgs...),
 	}, nil
 }
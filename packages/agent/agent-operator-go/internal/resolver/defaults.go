@@ -56,6 +56,9 @@ func ApplyHarnessDefaults(run *agentv1alpha1.AgentRun, harness *agentv1alpha1.Ag
 		if run.Spec.NetworkPolicy == nil && harness.Spec.DefaultNetworkPolicy != nil {
 			run.Spec.NetworkPolicy = harness.Spec.DefaultNetworkPolicy.DeepCopy()
 		}
+		if len(run.Spec.EgressAllow) == 0 && len(harness.Spec.DefaultEgressAllow) > 0 {
+			run.Spec.EgressAllow = append([]string{}, harness.Spec.DefaultEgressAllow...)
+		}
 		if run.Spec.TTLSecondsAfterFinished == nil && harness.Spec.DefaultTTLSecondsAfterFinished != nil {
 			ttl := *harness.Spec.DefaultTTLSecondsAfterFinished
 			run.Spec.TTLSecondsAfterFinished = &ttl
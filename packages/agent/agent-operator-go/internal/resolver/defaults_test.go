@@ -142,3 +142,25 @@ func TestApplyHarnessDefaults_RuntimeClassName_RunTakesPrecedence(t *testing.T)
 		t.Errorf("RuntimeClassName = %q, want gvisor", *run.Spec.RuntimeClassName)
 	}
 }
+
+func TestApplyHarnessDefaults_EgressAllow(t *testing.T) {
+	harness := &agentv1alpha1.AgentHarness{
+		Spec: agentv1alpha1.AgentSpec{
+			DefaultEgressAllow: []string{"api.anthropic.com"},
+		},
+	}
+	run := &agentv1alpha1.AgentRun{}
+	explicit := &agentv1alpha1.AgentRun{
+		Spec: agentv1alpha1.AgentRunSpec{EgressAllow: []string{"registry.npmjs.org"}},
+	}
+
+	ApplyHarnessDefaults(run, harness)
+	ApplyHarnessDefaults(explicit, harness)
+
+	if len(run.Spec.EgressAllow) != 1 || run.Spec.EgressAllow[0] != "api.anthropic.com" {
+		t.Errorf("EgressAllow = %v, want the harness default", run.Spec.EgressAllow)
+	}
+	if len(explicit.Spec.EgressAllow) != 1 || explicit.Spec.EgressAllow[0] != "registry.npmjs.org" {
+		t.Errorf("EgressAllow = %v, want the run's own list", explicit.Spec.EgressAllow)
+	}
+}
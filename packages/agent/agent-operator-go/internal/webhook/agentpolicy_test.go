@@ -272,13 +272,6 @@ func TestEnforcePolicy_RejectsUnrestrictedNetworkConfigurations(t *testing.T) {
 			},
 			phrase: "network=host",
 		},
-		{
-			name: "proxy without backend",
-			mutate: func(run *agentv1alpha1.AgentRun) {
-				run.Spec.Network = agentv1alpha1.NetworkModeProxy
-			},
-			phrase: "network=proxy",
-		},
 		{
 			name: "custom egress",
 			mutate: func(run *agentv1alpha1.AgentRun) {
@@ -303,6 +296,26 @@ func TestEnforcePolicy_RejectsUnrestrictedNetworkConfigurations(t *testing.T) {
 	}
 }
 
+func TestEnforcePolicy_ProxySatisfiesEgressRestricted(t *testing.T) {
+	run := baseRun()
+	run.Spec.Network = agentv1alpha1.NetworkModeProxy
+	run.Spec.EgressAllow = []string{"api.anthropic.com"}
+
+	if err := enforcePolicy(run, basePolicy()); err != nil {
+		t.Fatalf("enforcePolicy() error = %v, want proxy to satisfy restricted egress", err)
+	}
+}
+
+func TestEnforcePolicy_FQDNSatisfiesEgressRestricted(t *testing.T) {
+	run := baseRun()
+	run.Spec.Network = agentv1alpha1.NetworkModeFQDN
+	run.Spec.EgressAllow = []string{"api.anthropic.com"}
+
+	if err := enforcePolicy(run, basePolicy()); err != nil {
+		t.Fatalf("enforcePolicy() error = %v, want fqdn to satisfy restricted egress", err)
+	}
+}
+
 func TestEnforcePolicy_RejectsExceededTimeout(t *testing.T) {
 	run := baseRun()
 	run.Spec.Timeout = &metav1.Duration{Duration: 5 * time.Hour}
@@ -43,6 +43,12 @@ var envKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 var k8sNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9\-]{0,251}[a-z0-9]$|^[a-z0-9]$`)
 
 func (w *AgentProviderWebhook) validate(provider *agentv1alpha1.AgentProvider) (admission.Warnings, error) {
+	if provider.Spec.GatewayRef != "" {
+		return nil, validateProviderGateway(provider)
+	}
+	if provider.Spec.BaseURLEnv != "" {
+		return nil, fmt.Errorf("baseURLEnv requires gatewayRef")
+	}
 	err := validateProviderConfig(
 		provider.Spec.PresetRef,
 		provider.Spec.AgentType,
@@ -54,6 +60,51 @@ func (w *AgentProviderWebhook) validate(provider *agentv1alpha1.AgentProvider) (
 	return nil, err
 }
 
+// validateProviderGateway admits a provider whose credential an AgentGateway
+// holds: the run receives the gateway URL and a per-run token, so it needs
+// somewhere to put both and must not also mount the credential Secret.
+func validateProviderGateway(provider *agentv1alpha1.AgentProvider) error {
+	spec := provider.Spec
+	if !k8sNamePattern.MatchString(spec.GatewayRef) {
+		return fmt.Errorf("gatewayRef %q is not a valid Kubernetes resource name", spec.GatewayRef)
+	}
+	if spec.AuthTokenSecretRef != nil {
+		return fmt.Errorf("gatewayRef and authTokenSecretRef are mutually exclusive")
+	}
+	tokenEnv, baseURLEnv := spec.AuthTokenEnv, spec.BaseURLEnv
+	if spec.PresetRef != "" {
+		at := sharedtypes.AgentType(spec.AgentType)
+		if at == "" {
+			at = sharedtypes.AgentClaude
+		}
+		preset, err := providers.GetPortableProvider(spec.PresetRef, at)
+		if err != nil {
+			return fmt.Errorf("presetRef %q is not a portable provider preset for agent type %q: %w", spec.PresetRef, at, err)
+		}
+		if tokenEnv == "" {
+			tokenEnv = preset.CredentialTargetEnv
+		}
+		if baseURLEnv == "" {
+			baseURLEnv = preset.BaseURLEnv
+		}
+	}
+	for _, destination := range []struct{ field, key string }{{"authTokenEnv", tokenEnv}, {"baseURLEnv", baseURLEnv}} {
+		if destination.key == "" {
+			return fmt.Errorf("%s is required when gatewayRef is configured", destination.field)
+		}
+		if err := validateEnvironmentKey(destination.key); err != nil {
+			return fmt.Errorf("%s: %w", destination.field, err)
+		}
+		if _, exists := spec.Environment[destination.key]; exists {
+			return fmt.Errorf("environment key %q conflicts with gatewayRef", destination.key)
+		}
+	}
+	if tokenEnv == baseURLEnv {
+		return fmt.Errorf("authTokenEnv and baseURLEnv must differ")
+	}
+	return validateProviderConfig(spec.PresetRef, spec.AgentType, nil, "", spec.Environment, spec.CliArgs)
+}
+
 func validateProviderConfig(presetRef, agentType string, secretRef *agentv1alpha1.SecretKeyRef, authTokenEnv string, environment map[string]string, cliArgs []string) error {
 	presetAuthTokenEnv := ""
 	if presetRef != "" {
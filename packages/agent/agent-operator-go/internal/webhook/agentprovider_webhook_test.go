@@ -299,3 +299,42 @@ func TestAgentProviderWebhook_RejectsLocalOnlyPreset(t *testing.T) {
 		t.Errorf("ValidateCreate() warnings = %v, want none", warnings)
 	}
 }
+
+func TestAgentProviderWebhook_AcceptsAGatewayProvider(t *testing.T) {
+	for name, spec := range map[string]agentv1alpha1.AgentProviderSpec{
+		"preset destinations":   {PresetRef: "glm", GatewayRef: "anthropic"},
+		"explicit destinations": {GatewayRef: "anthropic", AuthTokenEnv: "OPENAI_API_KEY", BaseURLEnv: "OPENAI_BASE_URL"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := (&AgentProviderWebhook{}).ValidateCreate(context.Background(), &agentv1alpha1.AgentProvider{Spec: spec}); err != nil {
+				t.Errorf("ValidateCreate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestAgentProviderWebhook_RejectsInvalidGatewayProviders(t *testing.T) {
+	tests := map[string]agentv1alpha1.AgentProviderSpec{
+		"invalid name": {PresetRef: "glm", GatewayRef: "Not_A_Name"},
+		"also a secret": {
+			PresetRef:          "glm",
+			GatewayRef:         "anthropic",
+			AuthTokenSecretRef: &agentv1alpha1.SecretKeyRef{Name: "provider-auth", Key: "token"},
+		},
+		"unknown preset":        {PresetRef: "missing", GatewayRef: "anthropic"},
+		"no token destination":  {GatewayRef: "anthropic", BaseURLEnv: "OPENAI_BASE_URL"},
+		"no base URL":           {GatewayRef: "anthropic", AuthTokenEnv: "OPENAI_API_KEY"},
+		"invalid destination":   {GatewayRef: "anthropic", AuthTokenEnv: "LD_PRELOAD", BaseURLEnv: "OPENAI_BASE_URL"},
+		"same destination":      {GatewayRef: "anthropic", AuthTokenEnv: "OPENAI_API_KEY", BaseURLEnv: "OPENAI_API_KEY"},
+		"literal base URL":      {PresetRef: "glm", GatewayRef: "anthropic", Environment: map[string]string{"ANTHROPIC_BASE_URL": "https://example.com"}},
+		"baseURLEnv without gw": {BaseURLEnv: "ANTHROPIC_BASE_URL"},
+		"invalid cli arg":       {PresetRef: "glm", GatewayRef: "anthropic", CliArgs: []string{"$(id)"}},
+	}
+	for name, spec := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := (&AgentProviderWebhook{}).ValidateCreate(context.Background(), &agentv1alpha1.AgentProvider{Spec: spec}); err == nil {
+				t.Error("ValidateCreate() error = nil, want a validation error")
+			}
+		})
+	}
+}
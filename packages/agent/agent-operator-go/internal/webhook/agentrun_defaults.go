@@ -118,4 +118,7 @@ func applyPolicyDefaults(run *agentv1alpha1.AgentRun, policy *agentv1alpha1.Agen
 		runtimeClassName := *defaults.RuntimeClassName
 		run.Spec.RuntimeClassName = &runtimeClassName
 	}
+	if len(run.Spec.EgressAllow) == 0 && len(defaults.EgressAllow) > 0 {
+		run.Spec.EgressAllow = append([]string{}, defaults.EgressAllow...)
+	}
 }
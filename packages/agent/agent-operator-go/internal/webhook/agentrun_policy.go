@@ -146,8 +146,6 @@ func enforcePolicy(run *agentv1alpha1.AgentRun, policy *agentv1alpha1.AgentPolic
 		switch run.Spec.Network {
 		case agentv1alpha1.NetworkModeHost:
 			return fmt.Errorf("policy requires restricted egress; network=host is unrestricted")
-		case agentv1alpha1.NetworkModeProxy:
-			return fmt.Errorf("policy requires restricted egress; network=proxy has no enforceable backend")
 		}
 		if run.Spec.NetworkPolicy != nil {
 			if run.Spec.NetworkPolicy.Disabled {
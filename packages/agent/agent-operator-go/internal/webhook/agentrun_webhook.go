@@ -11,7 +11,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
 	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/plugins"
+	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/egress"
 	agentvalidation "github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/validation"
 )
 
@@ -142,9 +144,6 @@ func (w *AgentRunWebhook) validate(ctx context.Context, run *agentv1alpha1.Agent
 
 	// Validate NetworkPolicy egress rules
 	var warnings admission.Warnings
-	if run.Spec.Network == agentv1alpha1.NetworkModeProxy {
-		return nil, fmt.Errorf("network=proxy is unavailable until an enforceable FQDN-aware backend is configured")
-	}
 	if run.Spec.NetworkPolicy != nil && !run.Spec.NetworkPolicy.Disabled {
 		for _, rule := range run.Spec.NetworkPolicy.Egress {
 			if len(rule.To) == 0 {
@@ -168,6 +167,9 @@ func (w *AgentRunWebhook) validate(ctx context.Context, run *agentv1alpha1.Agent
 	if err := validateTimeout(effectiveRun.Spec.Timeout); err != nil {
 		return nil, err
 	}
+	if err := w.validateEgressAllowNetwork(effectiveRun); err != nil {
+		return nil, err
+	}
 	if err := validateExecutionBoundary(effectiveRun, policy); err != nil {
 		return nil, err
 	}
@@ -185,3 +187,35 @@ func (w *AgentRunWebhook) validate(ctx context.Context, run *agentv1alpha1.Agent
 
 	return warnings, nil
 }
+
+// validateEgressAllowNetwork checks a network=proxy or network=fqdn run after
+// defaulting: the hostname allowlist must be present and well-formed, and the
+// run's network policy must be the operator's, which is what enforces it.
+// Custom egress rules would be ignored, so they are rejected rather than
+// silently dropped. fqdn additionally needs a policy API the cluster serves, so
+// a run that could never be enforced is refused at admission.
+func (w *AgentRunWebhook) validateEgressAllowNetwork(run *agentv1alpha1.AgentRun) error {
+	network := run.Spec.Network
+	if network != agentv1alpha1.NetworkModeProxy && network != agentv1alpha1.NetworkModeFQDN {
+		return nil
+	}
+	if len(run.Spec.EgressAllow) == 0 {
+		return fmt.Errorf("network=%s requires egressAllow on the run, its harness, or the namespace AgentPolicy defaults", network)
+	}
+	if _, err := egress.NewAllowlist(run.Spec.EgressAllow); err != nil {
+		return fmt.Errorf("invalid egressAllow: %w", err)
+	}
+	if np := run.Spec.NetworkPolicy; np != nil {
+		if np.Disabled {
+			return fmt.Errorf("network=%s requires the operator-managed NetworkPolicy; it cannot be disabled", network)
+		}
+		if len(np.Egress) > 0 {
+			return fmt.Errorf("network=%s cannot be combined with custom NetworkPolicy egress rules", network)
+		}
+	}
+	if network == agentv1alpha1.NetworkModeFQDN &&
+		netshared.ResolveFQDNPolicyRealizer(w.Client.RESTMapper(), plugins.FQDNPolicyRealizers()) == nil {
+		return netshared.ErrFQDNPolicyUnavailable
+	}
+	return nil
+}
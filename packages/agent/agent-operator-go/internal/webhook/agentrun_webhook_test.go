@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -12,6 +13,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
+	"github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/cilium"
+	netshared "github.com/xonovex/platform/packages/agent/agent-operator-go/internal/network/shared"
 	"github.com/xonovex/platform/packages/agent/agent-operator-go/test/testutil"
 )
 
@@ -391,13 +394,121 @@ func TestAgentRunWebhook_Validate_InvalidInlineWorkspaceStorageSize(t *testing.T
 	}
 }
 
-func TestAgentRunWebhook_Validate_ProxyFailsClosed(t *testing.T) {
+func TestAgentRunWebhook_Validate_AllowlistModesRequireAnEnforceableAllowlist(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*agentv1alpha1.AgentRun)
+		phrase string
+	}{
+		{
+			name:   "no allowlist",
+			mutate: func(*agentv1alpha1.AgentRun) {},
+			phrase: "requires egressAllow",
+		},
+		{
+			name: "URL entry",
+			mutate: func(run *agentv1alpha1.AgentRun) {
+				run.Spec.EgressAllow = []string{"https://api.anthropic.com"}
+			},
+			phrase: "invalid egressAllow",
+		},
+		{
+			name: "disabled NetworkPolicy",
+			mutate: func(run *agentv1alpha1.AgentRun) {
+				run.Spec.NetworkPolicy = &agentv1alpha1.AgentNetworkPolicy{Disabled: true}
+			},
+			phrase: "cannot be disabled",
+		},
+		{
+			name: "custom egress",
+			mutate: func(run *agentv1alpha1.AgentRun) {
+				run.Spec.NetworkPolicy = &agentv1alpha1.AgentNetworkPolicy{
+					Egress: []networkingv1.NetworkPolicyEgressRule{{}},
+				}
+			},
+			phrase: "custom NetworkPolicy egress",
+		},
+	}
+	for _, network := range []agentv1alpha1.NetworkMode{agentv1alpha1.NetworkModeProxy, agentv1alpha1.NetworkModeFQDN} {
+		for _, test := range tests {
+			t.Run(string(network)+"/"+test.name, func(t *testing.T) {
+				run := baseRun()
+				run.Spec.Network = network
+				if test.name != "no allowlist" {
+					run.Spec.EgressAllow = []string{"api.anthropic.com"}
+				}
+				test.mutate(run)
+
+				_, err := sandboxedAgentRunWebhook("test-ns").ValidateCreate(context.Background(), run)
+				if err == nil || !strings.Contains(err.Error(), test.phrase) {
+					t.Fatalf("ValidateCreate() error = %v, want phrase %q", err, test.phrase)
+				}
+			})
+		}
+	}
+}
+
+// fqdn is refused where no FQDN-aware policy API is served, rather than
+// admitted and left to fail or, worse, run with DNS-only egress.
+func TestAgentRunWebhook_Validate_FQDNRequiresAServedPolicyAPI(t *testing.T) {
+	run := baseRun()
+	run.Spec.Network = agentv1alpha1.NetworkModeFQDN
+	run.Spec.EgressAllow = []string{"api.anthropic.com"}
+
+	_, err := sandboxedAgentRunWebhook("test-ns").ValidateCreate(context.Background(), run)
+	if !errors.Is(err, netshared.ErrFQDNPolicyUnavailable) {
+		t.Fatalf("ValidateCreate() error = %v, want %v", err, netshared.ErrFQDNPolicyUnavailable)
+	}
+}
+
+func TestAgentRunWebhook_Validate_AdmitsFQDNWhereCiliumIsServed(t *testing.T) {
+	policy := &agentv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "test-ns"},
+		Spec: agentv1alpha1.AgentPolicySpec{
+			Enforced: agentv1alpha1.AgentPolicyEnforced{
+				AllowedRuntimeClassNames: []string{"kata"},
+				RequireEgressRestricted:  true,
+			},
+		},
+	}
+	scheme := testutil.NewScheme()
+	w := &AgentRunWebhook{Client: fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(testutil.NewRESTMapper(scheme, cilium.NetworkPolicyGVK)).
+		WithObjects(policy).
+		Build()}
+	run := baseRun()
+	run.Spec.Network = agentv1alpha1.NetworkModeFQDN
+	run.Spec.EgressAllow = []string{"api.anthropic.com"}
+
+	if _, err := w.ValidateCreate(context.Background(), run); err != nil {
+		t.Fatalf("ValidateCreate() error = %v, want fqdn admitted under restricted egress", err)
+	}
+}
+
+func TestAgentRunWebhook_Validate_AdmitsProxyWithADefaultedAllowlist(t *testing.T) {
+	policy := &agentv1alpha1.AgentPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "test-ns"},
+		Spec: agentv1alpha1.AgentPolicySpec{
+			Enforced: agentv1alpha1.AgentPolicyEnforced{
+				AllowedRuntimeClassNames: []string{"kata"},
+				RequireEgressRestricted:  true,
+			},
+			Defaults: agentv1alpha1.AgentPolicyDefaults{EgressAllow: []string{"api.anthropic.com"}},
+		},
+	}
+	w := &AgentRunWebhook{Client: fake.NewClientBuilder().WithScheme(testutil.NewScheme()).WithObjects(policy).Build()}
 	run := baseRun()
 	run.Spec.Network = agentv1alpha1.NetworkModeProxy
 
-	_, err := (&AgentRunWebhook{}).ValidateCreate(context.Background(), run)
-	if err == nil || !strings.Contains(err.Error(), "network=proxy") {
-		t.Fatalf("ValidateCreate() error = %v, want unavailable proxy error", err)
+	if _, err := w.ValidateCreate(context.Background(), run); err != nil {
+		t.Fatalf("ValidateCreate() error = %v, want proxy admitted under restricted egress", err)
+	}
+	if err := w.Default(context.Background(), run); err != nil {
+		t.Fatalf("Default() error = %v", err)
+	}
+	if len(run.Spec.EgressAllow) != 1 || run.Spec.EgressAllow[0] != "api.anthropic.com" {
+		t.Errorf("EgressAllow = %v, want the policy default snapshotted", run.Spec.EgressAllow)
 	}
 }
 
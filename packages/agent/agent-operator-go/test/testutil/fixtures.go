@@ -109,6 +109,14 @@ func WithWorkspaceRef(ref string) AgentRunOption {
 	}
 }
 
+// WithProxyNetwork sets network=proxy with the given hostname allowlist.
+func WithProxyNetwork(allow ...string) AgentRunOption {
+	return func(r *agentv1alpha1.AgentRun) {
+		r.Spec.Network = agentv1alpha1.NetworkModeProxy
+		r.Spec.EgressAllow = allow
+	}
+}
+
 // NewAgentRun creates an AgentRun with defaults and applies options.
 func NewAgentRun(namespace, name string, opts ...AgentRunOption) *agentv1alpha1.AgentRun {
 	runtimeClassName := "kata"
@@ -158,6 +166,20 @@ func WithEnvironment(env map[string]string) AgentProviderOption {
 	}
 }
 
+// WithGatewayRef routes the provider's credential through an AgentGateway.
+func WithGatewayRef(name string) AgentProviderOption {
+	return func(p *agentv1alpha1.AgentProvider) {
+		p.Spec.GatewayRef = name
+	}
+}
+
+// WithPresetRef sets the provider preset.
+func WithPresetRef(name string) AgentProviderOption {
+	return func(p *agentv1alpha1.AgentProvider) {
+		p.Spec.PresetRef = name
+	}
+}
+
 // NewAgentProvider creates an AgentProvider with defaults and applies options.
 func NewAgentProvider(namespace, name string, opts ...AgentProviderOption) *agentv1alpha1.AgentProvider {
 	provider := &agentv1alpha1.AgentProvider{
@@ -310,6 +332,21 @@ func E2ESecurityOverrides() []AgentRunOption {
 	}
 }
 
+// NewAgentGateway creates an AgentGateway forwarding to upstream with the
+// credential in Secret secretName under key.
+func NewAgentGateway(namespace, name, upstream, secretName, key string) *agentv1alpha1.AgentGateway {
+	return &agentv1alpha1.AgentGateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: agentv1alpha1.AgentGatewaySpec{
+			Upstream:            upstream,
+			CredentialSecretRef: agentv1alpha1.SecretKeyRef{Name: secretName, Key: key},
+		},
+	}
+}
+
 // NewSecret creates a Secret with the given data.
 func NewSecret(namespace, name string, data map[string][]byte) *corev1.Secret {
 	return &corev1.Secret{
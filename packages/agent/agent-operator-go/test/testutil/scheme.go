@@ -3,7 +3,10 @@ package testutil
 import (
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	agentv1alpha1 "github.com/xonovex/platform/packages/agent/agent-operator-go/api/v1alpha1"
@@ -20,3 +23,15 @@ func NewScheme() *runtime.Scheme {
 	}
 	return s
 }
+
+// NewRESTMapper maps every kind in scheme, as the fake client does by default,
+// plus the namespaced extra kinds, standing in for CRDs such as
+// CiliumNetworkPolicy that a cluster serves without the operator registering
+// their Go types.
+func NewRESTMapper(scheme *runtime.Scheme, extra ...schema.GroupVersionKind) meta.RESTMapper {
+	crds := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range extra {
+		crds.Add(gvk, meta.RESTScopeNamespace)
+	}
+	return meta.MultiRESTMapper{testrestmapper.TestOnlyStaticRESTMapper(scheme), crds}
+}
@@ -8,11 +8,11 @@ import (
 	"github.com/xonovex/platform/packages/shared/shared-agent-go/pkg/types"
 )
 
-// BuildAgentCommand builds the command array for executing an agent. When
-// binaryPrefix is non-empty, the agent binary is resolved under that directory
-// (e.g. a provisioned closure's bin). It takes only the fields it needs (data
-// coupling), not a whole config struct.
-func BuildAgentCommand(agent *types.AgentConfig, provider *types.ModelProvider, agentArgs []string, binaryPrefix string) []string {
+// BuildAgentCommand builds the command array for executing an agent; a
+// non-empty prompt runs it headless. When binaryPrefix is non-empty, the agent
+// binary is resolved under that directory (e.g. a provisioned closure's bin).
+// It takes only the fields it needs (data coupling), not a whole config struct.
+func BuildAgentCommand(agent *types.AgentConfig, provider *types.ModelProvider, agentArgs []string, prompt string, binaryPrefix string) []string {
 	var providerCliArgs []string
 	if provider != nil {
 		providerCliArgs = providers.GetProviderCliArgs(provider)
@@ -21,6 +21,7 @@ func BuildAgentCommand(agent *types.AgentConfig, provider *types.ModelProvider,
 	execOpts := types.AgentExecOptions{
 		Sandbox:         true,
 		ProviderCliArgs: providerCliArgs,
+		Prompt:          prompt,
 	}
 
 	var builtArgs []string
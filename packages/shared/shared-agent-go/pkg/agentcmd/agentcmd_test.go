@@ -9,7 +9,7 @@ import (
 func TestBuildAgentCommandBinaryPrefix(t *testing.T) {
 	agent := &types.AgentConfig{Type: types.AgentClaude, Binary: "claude"}
 
-	cmd := BuildAgentCommand(agent, nil, []string{"--foo"}, "/env/bin")
+	cmd := BuildAgentCommand(agent, nil, []string{"--foo"}, "", "/env/bin")
 	if len(cmd) == 0 {
 		t.Fatal("BuildAgentCommand returned empty command")
 	}
@@ -21,12 +21,21 @@ func TestBuildAgentCommandBinaryPrefix(t *testing.T) {
 func TestBuildAgentCommandNoPrefix(t *testing.T) {
 	agent := &types.AgentConfig{Type: types.AgentOpencode, Binary: "opencode"}
 
-	cmd := BuildAgentCommand(agent, nil, nil, "")
+	cmd := BuildAgentCommand(agent, nil, nil, "", "")
 	if len(cmd) == 0 || cmd[0] != "opencode" {
 		t.Errorf("binary = %v, want opencode first", cmd)
 	}
 }
 
+func TestBuildAgentCommandPassesThePrompt(t *testing.T) {
+	agent := &types.AgentConfig{Type: types.AgentClaude, Binary: "claude"}
+
+	cmd := BuildAgentCommand(agent, nil, nil, "Fix the build", "")
+	if got := cmd[len(cmd)-2:]; got[0] != "--print" || got[1] != "Fix the build" {
+		t.Errorf("cmd = %v, want the prompt printed headless", cmd)
+	}
+}
+
 func TestBuildProviderEnvNilProvider(t *testing.T) {
 	agent := &types.AgentConfig{Type: types.AgentClaude, Binary: "claude"}
 
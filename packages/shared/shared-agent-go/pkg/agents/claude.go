@@ -22,6 +22,11 @@ func BuildClaudeArgs(baseArgs []string, options types.AgentExecOptions) []string
 		args = append(args, "--permission-mode", "bypassPermissions")
 	}
 
+	// Headless mode prints the response and exits
+	if options.Prompt != "" {
+		args = append(args, "--print", options.Prompt)
+	}
+
 	// Claude uses environment variables, not CLI args for provider config
 	// So we ignore providerCliArgs
 
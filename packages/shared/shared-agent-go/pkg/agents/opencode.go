@@ -15,9 +15,14 @@ func opencodeAgent() *types.AgentConfig {
 
 // BuildOpencodeArgs builds arguments for OpenCode agent
 func BuildOpencodeArgs(baseArgs []string, options types.AgentExecOptions) []string {
-	args := make([]string, 0, len(baseArgs)+len(options.ProviderCliArgs))
+	args := make([]string, 0, len(baseArgs)+len(options.ProviderCliArgs)+2)
 
-	// Add provider CLI args first
+	// Headless mode is the run subcommand with the prompt as its message
+	if options.Prompt != "" {
+		args = append(args, "run", options.Prompt)
+	}
+
+	// Add provider CLI args
 	args = append(args, options.ProviderCliArgs...)
 
 	// Add base args
@@ -56,6 +56,14 @@ func TestBuildClaudeArgs(t *testing.T) {
 	}
 }
 
+func TestBuildClaudeArgsRunsAPromptHeadless(t *testing.T) {
+	got := BuildClaudeArgs([]string{"--model", "opus"}, types.AgentExecOptions{Sandbox: true, Prompt: "Fix the build"})
+	want := []string{"--permission-mode", "bypassPermissions", "--print", "Fix the build", "--model", "opus"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("BuildClaudeArgs() = %v, want %v", got, want)
+	}
+}
+
 func TestBuildClaudeEnvReturnsCopy(t *testing.T) {
 	providerEnv := map[string]string{"TOKEN": "secret"}
 	env := BuildClaudeEnv(providerEnv)
@@ -73,6 +81,14 @@ func TestBuildOpencodeArgs(t *testing.T) {
 	}
 }
 
+func TestBuildOpencodeArgsRunsAPromptHeadless(t *testing.T) {
+	got := BuildOpencodeArgs(nil, types.AgentExecOptions{ProviderCliArgs: []string{"--model", "gemini"}, Prompt: "Fix the build"})
+	want := []string{"run", "Fix the build", "--model", "gemini"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("BuildOpencodeArgs() = %v, want %v", got, want)
+	}
+}
+
 func TestBuildOpencodeEnvIsEmpty(t *testing.T) {
 	if got := BuildOpencodeEnv(map[string]string{"TOKEN": "secret"}); len(got) != 0 {
 		t.Fatalf("BuildOpencodeEnv() = %v, want empty environment", got)
@@ -0,0 +1,197 @@
+// Package credshield is a credential-shielding reverse proxy for model provider
+// APIs. It runs outside the sandbox holding the real provider credential; the
+// agent is given only a token and the proxy's base URL. Requests carrying an
+// accepted token are forwarded upstream with the token replaced by the
+// credential, everything else is refused, and revoking the token cuts the
+// agent off. New serves one random per-run token; NewAuthorizing lets a shared
+// gateway decide which tokens to accept.
+package credshield
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// tokenBytes is the entropy of a per-run token.
+const tokenBytes = 32
+
+// tokenPrefix marks per-run tokens so one that leaks is recognisable as such.
+const tokenPrefix = "agent-cli-run-"
+
+// ErrCredentialEmpty reports a shield without a credential to inject.
+var ErrCredentialEmpty = errors.New("credential shield requires a non-empty credential")
+
+// Logger receives one line per refused request.
+type Logger func(message string)
+
+// Authorizer decides whether a presented token may use the credential.
+type Authorizer func(token string) bool
+
+// Server is the shielding reverse proxy for one run.
+type Server struct {
+	upstream   *url.URL
+	credential string
+	token      string
+	authorize  Authorizer
+	revoked    atomic.Bool
+	proxy      *httputil.ReverseProxy
+	log        Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	server   *http.Server
+}
+
+// New creates a shield that forwards to upstream, an http(s) base URL, with
+// credential, and mints the run's token.
+func New(upstream, credential string) (*Server, error) {
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("mint credential shield token: %w", err)
+	}
+	token := tokenPrefix + hex.EncodeToString(raw)
+	s, err := NewAuthorizing(upstream, credential, func(presented string) bool {
+		return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return s, nil
+}
+
+// NewAuthorizing creates a shield that forwards to upstream, an http(s) base
+// URL, with credential for every token authorize accepts. It mints no token of
+// its own.
+func NewAuthorizing(upstream, credential string, authorize Authorizer) (*Server, error) {
+	if credential == "" {
+		return nil, ErrCredentialEmpty
+	}
+	target, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("credential shield upstream %q: %w", upstream, err)
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("credential shield upstream %q must be an http or https URL", upstream)
+	}
+	s := &Server{upstream: target, credential: credential, authorize: authorize}
+	s.proxy = &httputil.ReverseProxy{
+		Rewrite: s.rewrite,
+		// Model APIs stream server-sent events; flush every write.
+		FlushInterval: -1,
+	}
+	return s, nil
+}
+
+// WithLogger reports refused requests to log.
+func (s *Server) WithLogger(log Logger) *Server {
+	s.log = log
+	return s
+}
+
+// Token returns the per-run token the agent presents instead of the credential,
+// or "" for a shield created by NewAuthorizing.
+func (s *Server) Token() string { return s.token }
+
+// Revoke invalidates every token; every later request is refused.
+func (s *Server) Revoke() { s.revoked.Store(true) }
+
+// Listen serves the shield on a TCP address in the background.
+func (s *Server) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on credential shield address %q: %w", addr, err)
+	}
+	server := s.attach(listener)
+	go func() { _ = server.Serve(listener) }()
+	return nil
+}
+
+// Serve serves the shield on listener until Close.
+func (s *Server) Serve(listener net.Listener) error {
+	if err := s.attach(listener).Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) attach(listener net.Listener) *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listener = listener
+	s.server = &http.Server{Handler: s}
+	return s.server
+}
+
+// Addr returns the address the shield listens on, or "" before Listen.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close revokes the token and stops the listener.
+func (s *Server) Close() error {
+	s.Revoke()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Close()
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.revoked.Load() || !s.authorized(req) {
+		s.refuse(req)
+		http.Error(w, "invalid or revoked run token", http.StatusUnauthorized)
+		return
+	}
+	s.proxy.ServeHTTP(w, req)
+}
+
+// authorized reports whether req presents the run token in the header the
+// agent's SDK uses: Authorization (bearer) or x-api-key.
+func (s *Server) authorized(req *http.Request) bool {
+	if bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok && s.matches(bearer) {
+		return true
+	}
+	return s.matches(req.Header.Get("X-Api-Key"))
+}
+
+func (s *Server) matches(presented string) bool {
+	return presented != "" && s.authorize(presented)
+}
+
+// rewrite points the request at upstream and swaps the run token for the
+// credential in whichever header carried it.
+func (s *Server) rewrite(r *httputil.ProxyRequest) {
+	r.SetURL(s.upstream)
+	header := r.Out.Header
+	if bearer, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer "); ok && s.matches(bearer) {
+		header.Set("Authorization", "Bearer "+s.credential)
+	}
+	if s.matches(header.Get("X-Api-Key")) {
+		header.Set("X-Api-Key", s.credential)
+	}
+}
+
+func (s *Server) refuse(req *http.Request) {
+	if s.log != nil {
+		s.log(fmt.Sprintf("credential shield: refused %s %s", req.Method, req.URL.Path))
+	}
+}
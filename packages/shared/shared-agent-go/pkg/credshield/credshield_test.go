@@ -0,0 +1,187 @@
+package credshield
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upstreamRecorder stands in for the provider API and records what reached it.
+type upstreamRecorder struct {
+	path          string
+	authorization string
+	apiKey        string
+}
+
+func startUpstream(t *testing.T) (*upstreamRecorder, string) {
+	t.Helper()
+	rec := &upstreamRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rec.path = req.URL.Path
+		rec.authorization = req.Header.Get("Authorization")
+		rec.apiKey = req.Header.Get("X-Api-Key")
+		_, _ = io.WriteString(w, "upstream")
+	}))
+	t.Cleanup(server.Close)
+	return rec, server.URL
+}
+
+func startShield(t *testing.T, upstream string) *Server {
+	t.Helper()
+	shield, err := New(upstream+"/api/anthropic", "real-credential")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := shield.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = shield.Close() })
+	return shield
+}
+
+func send(t *testing.T, shield *Server, header, value string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "http://"+shield.Addr()+"/v1/messages", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request through shield: %v", err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestShield_SwapsBearerTokenForCredential(t *testing.T) {
+	rec, upstream := startUpstream(t)
+	shield := startShield(t, upstream)
+
+	if status := send(t, shield, "Authorization", "Bearer "+shield.Token()); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if rec.authorization != "Bearer real-credential" {
+		t.Errorf("upstream Authorization = %q, want the real credential", rec.authorization)
+	}
+	if rec.path != "/api/anthropic/v1/messages" {
+		t.Errorf("upstream path = %q, want the base path joined", rec.path)
+	}
+}
+
+func TestShield_SwapsAPIKeyForCredential(t *testing.T) {
+	rec, upstream := startUpstream(t)
+	shield := startShield(t, upstream)
+
+	if status := send(t, shield, "X-Api-Key", shield.Token()); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if rec.apiKey != "real-credential" || rec.authorization != "" {
+		t.Errorf("upstream headers = (%q, %q), want only x-api-key with the credential", rec.apiKey, rec.authorization)
+	}
+}
+
+func TestShield_RefusesMissingWrongAndRevokedTokens(t *testing.T) {
+	rec, upstream := startUpstream(t)
+	shield := startShield(t, upstream)
+	var refused []string
+	shield.WithLogger(func(message string) { refused = append(refused, message) })
+
+	if status := send(t, shield, "", ""); status != http.StatusUnauthorized {
+		t.Errorf("no token status = %d, want 401", status)
+	}
+	if status := send(t, shield, "Authorization", "Bearer real-credential"); status != http.StatusUnauthorized {
+		t.Errorf("wrong token status = %d, want 401", status)
+	}
+	shield.Revoke()
+	if status := send(t, shield, "Authorization", "Bearer "+shield.Token()); status != http.StatusUnauthorized {
+		t.Errorf("revoked token status = %d, want 401", status)
+	}
+	if rec.path != "" {
+		t.Errorf("refused request reached upstream at %q", rec.path)
+	}
+	if len(refused) != 3 {
+		t.Errorf("logged %d refusals, want 3", len(refused))
+	}
+}
+
+func TestNew_ValidatesInputs(t *testing.T) {
+	if _, err := New("https://api.example.com", ""); !errors.Is(err, ErrCredentialEmpty) {
+		t.Errorf("New(empty credential) error = %v, want %v", err, ErrCredentialEmpty)
+	}
+	for _, upstream := range []string{"api.example.com", "ftp://api.example.com", "https://", "://bad"} {
+		if _, err := New(upstream, "credential"); err == nil {
+			t.Errorf("New(%q) error = nil, want URL error", upstream)
+		}
+	}
+	a, _ := New("https://api.example.com", "credential")
+	b, _ := New("https://api.example.com", "credential")
+	if a.Token() == b.Token() || !strings.HasPrefix(a.Token(), tokenPrefix) {
+		t.Errorf("tokens = (%q, %q), want distinct prefixed tokens", a.Token(), b.Token())
+	}
+}
+
+func TestServer_AddrAndCloseBeforeListen(t *testing.T) {
+	shield, err := New("https://api.example.com", "credential")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if shield.Addr() != "" {
+		t.Errorf("Addr() before Listen = %q, want empty", shield.Addr())
+	}
+	if err := shield.Close(); err != nil {
+		t.Errorf("Close() before Listen error = %v", err)
+	}
+	if err := shield.Listen("256.0.0.1:0"); err == nil {
+		t.Error("Listen(invalid) error = nil, want error")
+	}
+}
+
+func TestNewAuthorizing_AcceptsTokensTheAuthorizerAccepts(t *testing.T) {
+	rec, upstream := startUpstream(t)
+	shield, err := NewAuthorizing(upstream, "real-credential", func(token string) bool { return token == "run-a" })
+	if err != nil {
+		t.Fatalf("NewAuthorizing() error = %v", err)
+	}
+	if shield.Token() != "" {
+		t.Errorf("Token() = %q, want none for an authorizing shield", shield.Token())
+	}
+	if err := shield.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer shield.Close()
+
+	if status := send(t, shield, "X-Api-Key", "run-a"); status != http.StatusOK || rec.apiKey != "real-credential" {
+		t.Errorf("accepted token = (%d, %q), want (200, real-credential)", status, rec.apiKey)
+	}
+	if status := send(t, shield, "X-Api-Key", "run-b"); status != http.StatusUnauthorized {
+		t.Errorf("rejected token status = %d, want 401", status)
+	}
+}
+
+func TestServe_ReturnsNilAfterClose(t *testing.T) {
+	shield, err := NewAuthorizing("https://api.example.com", "real-credential", func(string) bool { return false })
+	if err != nil {
+		t.Fatalf("NewAuthorizing() error = %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- shield.Serve(listener) }()
+	for shield.Addr() == "" {
+		time.Sleep(time.Millisecond)
+	}
+	_ = shield.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Serve() after Close = %v, want nil", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package egress is the hostname-allowlist egress proxy shared by the agent
+// consumers: an HTTP CONNECT proxy that only tunnels to allowlisted
+// destinations. The CLI serves it on a unix socket bound into the sandbox; the
+// operator runs it as the per-namespace egress proxy Deployment. Consumers own
+// the transport, the proxy's lifetime, and how a request is identified.
+package egress
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// defaultPort is the only port an allowlist entry without an explicit port
+// permits: model APIs and package registries are HTTPS.
+const defaultPort = "443"
+
+// Allowlist is the set of host:port destinations the proxy tunnels to. An entry
+// is a hostname (port 443) or hostname:port; a leading "*." matches any
+// subdomain but not the apex.
+type Allowlist struct {
+	exact    map[string]struct{}
+	suffixes []string
+}
+
+// Destination is one parsed allowlist entry.
+type Destination struct {
+	// Host is the lower-cased hostname or IP literal, without any "*." prefix.
+	Host string
+	// Port is the destination port; 443 when the entry names none.
+	Port string
+	// Wildcard marks a "*." entry, which matches any subdomain of Host but not
+	// Host itself.
+	Wildcard bool
+}
+
+// ParseDestinations validates entries and returns them parsed. Entries are
+// hostnames, not URLs: schemes, paths and empty entries are rejected so a typo
+// cannot silently allow nothing (or everything).
+func ParseDestinations(entries []string) ([]Destination, error) {
+	destinations := make([]Destination, 0, len(entries))
+	for _, entry := range entries {
+		host, port, err := splitEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		wildcard, isWildcard := strings.CutPrefix(host, "*.")
+		if isWildcard {
+			host = wildcard
+		}
+		if err := validateHostname(host); err != nil {
+			return nil, fmt.Errorf("allowed host %q: %w", entry, err)
+		}
+		destinations = append(destinations, Destination{Host: host, Port: port, Wildcard: isWildcard})
+	}
+	return destinations, nil
+}
+
+// NewAllowlist validates entries as ParseDestinations does and returns the
+// allowlist.
+func NewAllowlist(entries []string) (*Allowlist, error) {
+	destinations, err := ParseDestinations(entries)
+	if err != nil {
+		return nil, err
+	}
+	a := &Allowlist{exact: map[string]struct{}{}}
+	for _, destination := range destinations {
+		if destination.Wildcard {
+			a.suffixes = append(a.suffixes, "."+destination.Host+":"+destination.Port)
+			continue
+		}
+		a.exact[destination.Host+":"+destination.Port] = struct{}{}
+	}
+	return a, nil
+}
+
+// Len returns the number of entries.
+func (a *Allowlist) Len() int { return len(a.exact) + len(a.suffixes) }
+
+// Allows reports whether the host:port destination is allowlisted. Hostnames
+// compare case-insensitively with any trailing root dot removed.
+func (a *Allowlist) Allows(hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return false
+	}
+	key := strings.TrimSuffix(strings.ToLower(host), ".") + ":" + port
+	if _, ok := a.exact[key]; ok {
+		return true
+	}
+	for _, suffix := range a.suffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitEntry splits an allowlist entry into a lower-cased host and a port,
+// defaulting the port to 443.
+func splitEntry(entry string) (string, string, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return "", "", fmt.Errorf("allowed host is empty")
+	}
+	if strings.Contains(entry, "/") {
+		return "", "", fmt.Errorf("allowed host %q must be a hostname, not a URL", entry)
+	}
+	host, port := entry, defaultPort
+	if strings.Contains(entry, ":") {
+		var err error
+		host, port, err = net.SplitHostPort(entry)
+		if err != nil {
+			return "", "", fmt.Errorf("allowed host %q: %w", entry, err)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", "", fmt.Errorf("allowed host %q has an invalid port", entry)
+		}
+	}
+	return strings.TrimSuffix(strings.ToLower(host), "."), port, nil
+}
+
+// validateHostname accepts DNS names and IP literals.
+func validateHostname(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if host == "" || len(host) > 253 {
+		return fmt.Errorf("invalid hostname length")
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid hostname label %q", label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return fmt.Errorf("invalid character %q in hostname", r)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package egress
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllowlist_MatchesHostsAndPorts(t *testing.T) {
+	allow, err := NewAllowlist([]string{"api.anthropic.com", "*.githubusercontent.com", "registry.example:8443"})
+	if err != nil {
+		t.Fatalf("NewAllowlist() error = %v", err)
+	}
+	cases := map[string]bool{
+		"api.anthropic.com:443":                      true,
+		"API.Anthropic.com.:443":                     true,
+		"api.anthropic.com:80":                       false,
+		"anthropic.com:443":                          false,
+		"evil-api.anthropic.com:443":                 false,
+		"raw.githubusercontent.com:443":              true,
+		"githubusercontent.com:443":                  false,
+		"registry.example:8443":                      true,
+		"registry.example:443":                       false,
+		"api.anthropic.com.attacker.example:443":     false,
+		"api.anthropic.com":                          false,
+		"objects.raw.githubusercontent.com:443":      true,
+		"raw.githubusercontent.com.attacker.net:443": false,
+	}
+	for hostport, want := range cases {
+		if got := allow.Allows(hostport); got != want {
+			t.Errorf("Allows(%q) = %t, want %t", hostport, got, want)
+		}
+	}
+	if allow.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", allow.Len())
+	}
+}
+
+func TestAllowlist_RejectsMalformedEntries(t *testing.T) {
+	for _, entry := range []string{"", "https://api.anthropic.com", "api.anthropic.com/v1", "host:0", "host:http", "bad host", "-lead.example", "*."} {
+		if _, err := NewAllowlist([]string{entry}); err == nil {
+			t.Errorf("NewAllowlist(%q) error = nil, want error", entry)
+		}
+	}
+}
+
+func TestParseDestinations_SplitsHostPortAndWildcard(t *testing.T) {
+	got, err := ParseDestinations([]string{"API.anthropic.com.", "*.githubusercontent.com", "registry.example:8443"})
+	if err != nil {
+		t.Fatalf("ParseDestinations() error = %v", err)
+	}
+	want := []Destination{
+		{Host: "api.anthropic.com", Port: "443"},
+		{Host: "githubusercontent.com", Port: "443", Wildcard: true},
+		{Host: "registry.example", Port: "8443"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDestinations() = %+v, want %+v", got, want)
+	}
+	if _, err := ParseDestinations([]string{"https://api.anthropic.com"}); err == nil {
+		t.Error("ParseDestinations(URL) error = nil, want error")
+	}
+}
@@ -0,0 +1,180 @@
+package egress
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds the upstream connect so a blackholed destination cannot
+// pin a tunnel open indefinitely.
+const dialTimeout = 30 * time.Second
+
+// Dialer opens the upstream connection for an allowed CONNECT.
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Authorizer decides whether a CONNECT request may tunnel to its target
+// (req.Host). Consumers that scope the allowlist per client resolve the
+// client's allowlist from the request here.
+type Authorizer func(req *http.Request) bool
+
+// Logger receives one line per refused or opened tunnel.
+type Logger func(message string)
+
+// Server is an HTTP CONNECT proxy that tunnels only to authorized
+// destinations. Plain-HTTP forwarding is not offered: every request that is not
+// an authorized CONNECT is refused, so the allowlist is the whole egress
+// surface.
+type Server struct {
+	authorize Authorizer
+	dial      Dialer
+	log       Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+// NewServer creates a proxy that tunnels to the destinations allow permits and
+// dials upstream with the system resolver.
+func NewServer(allow *Allowlist) *Server {
+	return NewAuthorizingServer(func(req *http.Request) bool { return allow.Allows(req.Host) })
+}
+
+// NewAuthorizingServer creates a proxy that tunnels the CONNECT requests
+// authorize accepts and dials upstream with the system resolver.
+func NewAuthorizingServer(authorize Authorizer) *Server {
+	d := &net.Dialer{Timeout: dialTimeout}
+	return &Server{authorize: authorize, dial: d.DialContext}
+}
+
+// WithDialer replaces the upstream dialer (tests point it at a local listener).
+func (s *Server) WithDialer(dial Dialer) *Server {
+	s.dial = dial
+	return s
+}
+
+// WithLogger reports tunnel decisions to log.
+func (s *Server) WithLogger(log Logger) *Server {
+	s.log = log
+	return s
+}
+
+// ListenUnix creates a unix socket at path, readable and writable by the owner
+// only, and serves the proxy on it in the background.
+func (s *Server) ListenUnix(path string) error {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("listen on egress proxy socket %q: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("restrict egress proxy socket %q: %w", path, err)
+	}
+	go func() { _ = s.Serve(listener) }()
+	return nil
+}
+
+// Serve accepts connections on listener until Close.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops accepting connections. Tunnels already open end when either side
+// closes.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) handle(client net.Conn) {
+	defer client.Close()
+	reader := bufio.NewReader(client)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		s.refuse(client, http.StatusMethodNotAllowed, req.Host)
+		return
+	}
+	if !s.authorize(req) {
+		s.refuse(client, http.StatusForbidden, req.Host)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	upstream, err := s.dial(ctx, "tcp", req.Host)
+	cancel()
+	if err != nil {
+		s.logf("egress proxy: dial %s: %v", req.Host, err)
+		s.refuse(client, http.StatusBadGateway, req.Host)
+		return
+	}
+	defer upstream.Close()
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		return
+	}
+	s.logf("egress proxy: tunnel to %s", req.Host)
+
+	// Bytes the client sent after the CONNECT head belong to the tunnel.
+	if buffered := reader.Buffered(); buffered > 0 {
+		head, _ := reader.Peek(buffered)
+		if _, err := upstream.Write(head); err != nil {
+			return
+		}
+	}
+	Pipe(client, upstream)
+}
+
+func (s *Server) refuse(client net.Conn, status int, host string) {
+	s.logf("egress proxy: refused %s (%d)", host, status)
+	_, _ = fmt.Fprintf(client, "HTTP/1.1 %d %s\r\nContent-Length: 0\r\nConnection: close\r\n\r\n", status, http.StatusText(status))
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.log != nil {
+		s.log(fmt.Sprintf(format, args...))
+	}
+}
+
+// Pipe copies both directions until either side finishes, then half-closes the
+// other so the peer sees EOF.
+func Pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	wg.Wait()
+}
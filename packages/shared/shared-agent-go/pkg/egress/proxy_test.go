@@ -0,0 +1,212 @@
+package egress
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// echoUpstream listens on loopback and echoes every connection, standing in for
+// an allowlisted API host.
+func echoUpstream(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen upstream: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// startProxy serves an allowlist proxy on a unix socket whose dialer sends
+// every allowed destination to upstream, and returns the socket path.
+func startProxy(t *testing.T, entries []string, upstream string) string {
+	t.Helper()
+	allow, err := NewAllowlist(entries)
+	if err != nil {
+		t.Fatalf("NewAllowlist() error = %v", err)
+	}
+	var dialer net.Dialer
+	server := NewServer(allow).WithDialer(func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, upstream)
+	})
+	socket := filepath.Join(t.TempDir(), "egress.sock")
+	if err := server.ListenUnix(socket); err != nil {
+		t.Fatalf("ListenUnix() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	return socket
+}
+
+// connect issues a CONNECT through conn and returns the response status.
+func connect(t *testing.T, conn net.Conn, target string) (*bufio.Reader, int) {
+	t.Helper()
+	if _, err := fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target); err != nil {
+		t.Fatalf("write CONNECT: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("read CONNECT response: %v", err)
+	}
+	return reader, resp.StatusCode
+}
+
+func TestServer_TunnelsAllowedHost(t *testing.T) {
+	socket := startProxy(t, []string{"api.anthropic.com"}, echoUpstream(t))
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+	reader, status := connect(t, conn, "api.anthropic.com:443")
+	if status != http.StatusOK {
+		t.Fatalf("CONNECT status = %d, want 200", status)
+	}
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatalf("write through tunnel: %v", err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("tunnel echo = (%q, %v), want ping", line, err)
+	}
+}
+
+func TestServer_AuthorizerDecidesPerRequest(t *testing.T) {
+	upstream := echoUpstream(t)
+	var dialer net.Dialer
+	logged := make(chan string, 16)
+	server := NewAuthorizingServer(func(req *http.Request) bool {
+		return req.Header.Get("Proxy-Authorization") == "Basic dG9rZW4="
+	}).
+		WithDialer(func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, upstream)
+		}).
+		WithLogger(func(message string) {
+			select {
+			case logged <- message:
+			default:
+			}
+		})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	for header, want := range map[string]int{
+		"":                   http.StatusForbidden,
+		"Basic dG9rZW4=":     http.StatusOK,
+		"Basic b3RoZXI6eA==": http.StatusForbidden,
+	} {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("dial proxy: %v", err)
+		}
+		request := "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n"
+		if header != "" {
+			request += "Proxy-Authorization: " + header + "\r\n"
+		}
+		if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+			t.Fatalf("write CONNECT: %v", err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+		if err != nil {
+			t.Fatalf("read CONNECT response: %v", err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("CONNECT with %q status = %d, want %d", header, resp.StatusCode, want)
+		}
+		_ = conn.Close()
+	}
+	select {
+	case <-logged:
+	default:
+		t.Error("logger received no tunnel decisions")
+	}
+}
+
+func TestServer_RefusesUnlistedHostAndPlainHTTP(t *testing.T) {
+	socket := startProxy(t, []string{"api.anthropic.com"}, echoUpstream(t))
+
+	for target, want := range map[string]int{
+		"attacker.example:443":  http.StatusForbidden,
+		"api.anthropic.com:22":  http.StatusForbidden,
+		"api.anthropic.com:443": http.StatusOK,
+	} {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			t.Fatalf("dial proxy: %v", err)
+		}
+		if _, status := connect(t, conn, target); status != want {
+			t.Errorf("CONNECT %s status = %d, want %d", target, status, want)
+		}
+		_ = conn.Close()
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "GET http://api.anthropic.com/ HTTP/1.1\r\nHost: api.anthropic.com\r\n\r\n"); err != nil {
+		t.Fatalf("write GET: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("read GET response: %v", err)
+	}
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("plain GET status = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestServer_SocketIsOwnerOnly(t *testing.T) {
+	socket := startProxy(t, []string{"api.anthropic.com"}, echoUpstream(t))
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket mode = %o, want 600", perm)
+	}
+}
+
+func TestServer_UpstreamFailureIsBadGateway(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := closed.Addr().String()
+	_ = closed.Close()
+	socket := startProxy(t, []string{"api.anthropic.com"}, addr)
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+	defer conn.Close()
+	if _, status := connect(t, conn, "api.anthropic.com:443"); status != http.StatusBadGateway {
+		t.Errorf("CONNECT status = %d, want 502", status)
+	}
+}
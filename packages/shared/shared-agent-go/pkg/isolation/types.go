@@ -19,6 +19,18 @@ const (
 	// attack-surface reduction; a sandboxed runtime (runsc/gVisor) is a kernel
 	// boundary (each isolator declares its own KernelIsolated capability).
 	IsolationDocker IsolationMethod = "docker"
+	// IsolationPodman confines the agent in a rootless podman container. Like
+	// docker, default crun/runc is attack-surface reduction and a sandboxed
+	// runtime (runsc/gVisor, krun) is a kernel boundary.
+	IsolationPodman IsolationMethod = "podman"
+	// IsolationLandlock confines the agent's filesystem (and TCP) access with
+	// Linux Landlock rulesets, without namespaces or a setuid helper.
+	// Attack-surface reduction, not a kernel trust boundary.
+	IsolationLandlock IsolationMethod = "landlock"
+	// IsolationNative confines the agent in namespaces agent-cli creates
+	// itself, with the bwrap mount layout and no bubblewrap binary.
+	// Attack-surface reduction, not a kernel trust boundary.
+	IsolationNative IsolationMethod = "native"
 )
 
 // DefaultContainerImage is the default container image for running agents.
@@ -6,8 +6,9 @@ package network
 //	        not satisfy RequireEgressRestricted.
 //	none  = no network (bwrap --unshare-net / docker --network none); satisfies
 //	        RequireEgressRestricted.
-//	proxy = reserved for an enforceable allowlist transport; it does not satisfy
-//	        RequireEgressRestricted until a realizer provides that transport.
+//	proxy = egress only through a hostname-allowlist proxy, with no direct route
+//	        out (the realizer funnels every connection through the proxy);
+//	        satisfies RequireEgressRestricted.
 type NetworkMethod string
 
 const (
@@ -20,7 +21,8 @@ const (
 // is a closed enum (no per-network plugin object), so the caller computes this
 // boolean and passes it into policy.Capabilities; the policy engine stays
 // method-agnostic. NetworkHost shares the host network unrestricted and does not
-// qualify.
+// qualify; NetworkProxy qualifies because its realizers leave the allowlist proxy
+// as the only reachable route.
 func EgressIsRestricted(n NetworkMethod) bool {
-	return n == NetworkNone
+	return n == NetworkNone || n == NetworkProxy
 }
@@ -3,9 +3,10 @@ package policy
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
-// Policy errors. EnforcePolicy wraps one of these (with context) for the first
+// Policy errors. EnforcePolicy wraps one of these (with context) for every
 // unmet guarantee; callers match with errors.Is to react to a specific failure.
 var (
 	// ErrPinnedProvisionUnmet: RequirePinnedProvision requested but the resolved
@@ -20,6 +21,9 @@ var (
 	// ErrKernelIsolationUnmet: RequireKernelIsolation requested but the resolved
 	// isolator plus runtime gives no kernel boundary.
 	ErrKernelIsolationUnmet = errors.New("kernel isolation unmet")
+	// ErrSyscallFilterUnmet: RequireSyscallFilter requested but the resolved
+	// isolator installs no seccomp profile for the request.
+	ErrSyscallFilterUnmet = errors.New("syscall filter unmet")
 )
 
 // Capabilities are the guarantees a SELECTED sandbox actually provides, computed
@@ -33,12 +37,15 @@ type Capabilities struct {
 	// HostToolsUnreachable: the isolator keeps host tools off PATH and not
 	// bind-reachable for this request (Isolator.HidesHost(passthrough, image)).
 	HostToolsUnreachable bool
-	// EgressRestricted: the network method restricts egress
-	// (network.EgressIsRestricted).
+	// EgressRestricted: the network method restricts egress and the isolator
+	// enforces it for every protocol (Isolator.RestrictsEgress(network)).
 	EgressRestricted bool
 	// KernelIsolated: the isolator plus runtime gives a kernel boundary
 	// (Isolator.KernelIsolated(runtime)).
 	KernelIsolated bool
+	// SyscallFiltered: the isolator installs the requested seccomp deny list
+	// (Isolator.FiltersSyscalls(profile)).
+	SyscallFiltered bool
 }
 
 // SandboxPolicy expresses the isolation guarantees the caller demands of the
@@ -55,30 +62,62 @@ type SandboxPolicy struct {
 	// bind-reachable. Conditioned on closure-only store binds, no host-$HOME bind,
 	// and (docker) a pinned image.
 	RequireHostToolsUnreachable bool
-	// RequireEgressRestricted mandates Network=none; host is unrestricted and
-	// proxy remains unavailable without an enforceable transport.
+	// RequireEgressRestricted mandates Network=none or Network=proxy (an
+	// allowlist proxy as the only route out); host is unrestricted.
 	RequireEgressRestricted bool
-	// RequireKernelIsolation mandates a kernel boundary: docker --runtime
-	// runsc/gVisor, or a pod with a sandboxed runtimeClass (gVisor/Kata/kata-cc).
+	// RequireKernelIsolation mandates a kernel boundary: docker or podman
+	// --runtime runsc/gVisor, podman --runtime krun, or a pod with a sandboxed runtimeClass (gVisor/Kata/kata-cc).
 	// NOT satisfied by bwrap or default runc.
 	RequireKernelIsolation bool
+	// RequireSyscallFilter mandates a hardened syscall filter: a named seccomp
+	// profile installed by bwrap, docker or podman. NOT satisfied by the engine's
+	// default profile, which the caller cannot inspect.
+	RequireSyscallFilter bool
 }
 
+// UnmetError lists every guarantee a sandbox fails to meet, in the order the
+// SandboxPolicy fields are declared. It unwraps to each guarantee's named
+// error, so errors.Is matches any of them.
+type UnmetError struct {
+	Unmet []error
+}
+
+func (e *UnmetError) Error() string {
+	if len(e.Unmet) == 1 {
+		return e.Unmet[0].Error()
+	}
+	messages := make([]string, len(e.Unmet))
+	for i, err := range e.Unmet {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d policy guarantees unmet: %s", len(e.Unmet), strings.Join(messages, "; "))
+}
+
+func (e *UnmetError) Unwrap() []error { return e.Unmet }
+
 // EnforcePolicy validates the provided capabilities against the demanded
-// guarantees, failing CLOSED: it returns a distinct named error (wrapped with
-// context) for the first unmet guarantee and never silently downgrades.
+// guarantees, failing CLOSED: it returns an *UnmetError naming every unmet
+// guarantee, each as a distinct named error wrapped with context, and never
+// silently downgrades.
 func EnforcePolicy(caps Capabilities, pol SandboxPolicy) error {
+	var unmet []error
 	if pol.RequirePinnedProvision && !caps.Pinned {
-		return fmt.Errorf("provisioning is not pinned (require-pinned-provision needs nix or a pinned image): %w", ErrPinnedProvisionUnmet)
+		unmet = append(unmet, fmt.Errorf("provisioning is not pinned (require-pinned-provision needs nix or a pinned image): %w", ErrPinnedProvisionUnmet))
 	}
 	if pol.RequireHostToolsUnreachable && !caps.HostToolsUnreachable {
-		return fmt.Errorf("the selected isolation leaves host tools reachable: %w", ErrHostToolsReachable)
+		unmet = append(unmet, fmt.Errorf("the selected isolation leaves host tools reachable: %w", ErrHostToolsReachable))
 	}
 	if pol.RequireEgressRestricted && !caps.EgressRestricted {
-		return fmt.Errorf("the network method does not restrict egress (require-egress-restricted needs none): %w", ErrEgressUnrestricted)
+		unmet = append(unmet, fmt.Errorf("the network method does not restrict egress (require-egress-restricted needs none or proxy): %w", ErrEgressUnrestricted))
 	}
 	if pol.RequireKernelIsolation && !caps.KernelIsolated {
-		return fmt.Errorf("the selected isolation gives no kernel boundary (require-kernel-isolation needs docker --runtime runsc/gVisor or a sandboxed runtimeClass): %w", ErrKernelIsolationUnmet)
+		unmet = append(unmet, fmt.Errorf("the selected isolation gives no kernel boundary (require-kernel-isolation needs a container --runtime such as runsc/gVisor or krun, or a sandboxed runtimeClass): %w", ErrKernelIsolationUnmet))
+	}
+	if pol.RequireSyscallFilter && !caps.SyscallFiltered {
+		unmet = append(unmet, fmt.Errorf("no hardened syscall filter is installed (require-syscall-filter needs a --seccomp-profile with bwrap, docker or podman): %w", ErrSyscallFilterUnmet))
+	}
+	if len(unmet) == 0 {
+		return nil
 	}
-	return nil
+	return &UnmetError{Unmet: unmet}
 }
@@ -2,6 +2,7 @@ package policy
 
 import (
 	"errors"
+	"strings"
 	"testing"
 )
 
@@ -21,7 +22,8 @@ func TestEnforcePolicy(t *testing.T) {
 		{"egress unmet", Capabilities{EgressRestricted: false}, SandboxPolicy{RequireEgressRestricted: true}, ErrEgressUnrestricted},
 		{"kernel met", Capabilities{KernelIsolated: true}, SandboxPolicy{RequireKernelIsolation: true}, nil},
 		{"kernel unmet", Capabilities{KernelIsolated: false}, SandboxPolicy{RequireKernelIsolation: true}, ErrKernelIsolationUnmet},
-		{"first unmet wins", Capabilities{}, SandboxPolicy{RequirePinnedProvision: true, RequireEgressRestricted: true}, ErrPinnedProvisionUnmet},
+		{"syscall filter met", Capabilities{SyscallFiltered: true}, SandboxPolicy{RequireSyscallFilter: true}, nil},
+		{"syscall filter unmet", Capabilities{SyscallFiltered: false}, SandboxPolicy{RequireSyscallFilter: true}, ErrSyscallFilterUnmet},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -38,3 +40,26 @@ func TestEnforcePolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestEnforcePolicyReportsEveryUnmetGuarantee(t *testing.T) {
+	err := EnforcePolicy(Capabilities{Pinned: true}, SandboxPolicy{
+		RequirePinnedProvision:  true,
+		RequireEgressRestricted: true,
+		RequireSyscallFilter:    true,
+	})
+	var unmet *UnmetError
+	if !errors.As(err, &unmet) || len(unmet.Unmet) != 2 {
+		t.Fatalf("EnforcePolicy() = %v, want an *UnmetError with two guarantees", err)
+	}
+	for _, want := range []error{ErrEgressUnrestricted, ErrSyscallFilterUnmet} {
+		if !errors.Is(err, want) {
+			t.Errorf("EnforcePolicy() = %v, want errors.Is %v", err, want)
+		}
+	}
+	if errors.Is(err, ErrPinnedProvisionUnmet) {
+		t.Errorf("EnforcePolicy() = %v, want the met guarantee left out", err)
+	}
+	if !strings.HasPrefix(err.Error(), "2 policy guarantees unmet: ") {
+		t.Errorf("Error() = %q, want the count and every guarantee", err)
+	}
+}
@@ -11,7 +11,9 @@ func geminiProvider() *types.ModelProvider {
 		AgentType:           types.AgentClaude,
 		CredentialSourceEnv: "CLI_PROXY_API_KEY",
 		CredentialTargetEnv: "ANTHROPIC_AUTH_TOKEN",
+		BaseURLEnv:          "ANTHROPIC_BASE_URL",
 		Portable:            false,
+		LocalEndpoints:      []string{"127.0.0.1:8317"},
 		Environment: map[string]string{
 			"ANTHROPIC_BASE_URL":                       "http://127.0.0.1:8317",
 			"API_TIMEOUT_MS":                           "3000000",
@@ -32,7 +34,9 @@ func geminiClaudeProvider() *types.ModelProvider {
 		AgentType:           types.AgentClaude,
 		CredentialSourceEnv: "CLI_PROXY_API_KEY",
 		CredentialTargetEnv: "ANTHROPIC_AUTH_TOKEN",
+		BaseURLEnv:          "ANTHROPIC_BASE_URL",
 		Portable:            false,
+		LocalEndpoints:      []string{"127.0.0.1:8317"},
 		Environment: map[string]string{
 			"ANTHROPIC_BASE_URL":                       "http://127.0.0.1:8317",
 			"API_TIMEOUT_MS":                           "3000000",
@@ -53,6 +57,7 @@ func glmProvider() *types.ModelProvider {
 		AgentType:           types.AgentClaude,
 		CredentialSourceEnv: "ZAI_AUTH_TOKEN",
 		CredentialTargetEnv: "ANTHROPIC_AUTH_TOKEN",
+		BaseURLEnv:          "ANTHROPIC_BASE_URL",
 		Portable:            true,
 		Environment: map[string]string{
 			"ANTHROPIC_BASE_URL":                       "https://api.z.ai/api/anthropic",
@@ -73,7 +78,9 @@ func gpt5CodexProvider() *types.ModelProvider {
 		AgentType:           types.AgentClaude,
 		CredentialSourceEnv: "CLI_PROXY_API_KEY",
 		CredentialTargetEnv: "ANTHROPIC_AUTH_TOKEN",
+		BaseURLEnv:          "ANTHROPIC_BASE_URL",
 		Portable:            false,
+		LocalEndpoints:      []string{"127.0.0.1:8317"},
 		Environment: map[string]string{
 			"ANTHROPIC_BASE_URL":                       "http://127.0.0.1:8317",
 			"API_TIMEOUT_MS":                           "3000000",
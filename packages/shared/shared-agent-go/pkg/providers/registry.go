@@ -28,6 +28,7 @@ func GetProvider(name string, agentType types.AgentType) (*types.ModelProvider,
 	}
 	provider.Environment = maps.Clone(provider.Environment)
 	provider.CliArgs = slices.Clone(provider.CliArgs)
+	provider.LocalEndpoints = slices.Clone(provider.LocalEndpoints)
 	return provider, nil
 }
 
@@ -76,6 +77,44 @@ func BuildProviderEnv(provider *types.ModelProvider) (map[string]string, error)
 	return env, nil
 }
 
+// CredentialUpstream returns the provider's credential from the host
+// environment and the API base URL it authenticates against, for a credential
+// shield to hold in the agent's place. ok is false when the provider carries no
+// credential, so there is nothing to shield.
+func CredentialUpstream(provider *types.ModelProvider) (upstream, credential string, ok bool, err error) {
+	if provider.CredentialSourceEnv == "" && provider.CredentialTargetEnv == "" {
+		return "", "", false, nil
+	}
+	env, err := BuildProviderEnv(provider)
+	if err != nil {
+		return "", "", false, err
+	}
+	upstream = env[provider.BaseURLEnv]
+	if provider.BaseURLEnv == "" || upstream == "" {
+		return "", "", false, fmt.Errorf("provider %q declares no API base URL, so its credential cannot be shielded", provider.Name)
+	}
+	return upstream, env[provider.CredentialTargetEnv], true, nil
+}
+
+// ShieldProvider returns a copy of provider that reaches its API through a
+// credential shield at baseURL, presenting token instead of the host
+// credential. The copy reads nothing from the host environment, and its local
+// endpoints are dropped because only the shield dials the upstream.
+func ShieldProvider(provider *types.ModelProvider, baseURL, token string) *types.ModelProvider {
+	shielded := *provider
+	shielded.Environment = maps.Clone(provider.Environment)
+	if shielded.Environment == nil {
+		shielded.Environment = make(map[string]string)
+	}
+	shielded.Environment[provider.BaseURLEnv] = baseURL
+	shielded.Environment[provider.CredentialTargetEnv] = token
+	shielded.CredentialSourceEnv = ""
+	shielded.CredentialTargetEnv = ""
+	shielded.CliArgs = slices.Clone(provider.CliArgs)
+	shielded.LocalEndpoints = nil
+	return &shielded
+}
+
 // GetProviderCliArgs gets CLI arguments for a provider
 func GetProviderCliArgs(provider *types.ModelProvider) []string {
 	return slices.Clone(provider.CliArgs)
@@ -39,6 +39,7 @@ func TestGetProviderReturnsIndependentConfigurations(t *testing.T) {
 		t.Fatalf("GetProvider() error = %v", err)
 	}
 	first.Environment["ANTHROPIC_BASE_URL"] = "changed"
+	first.LocalEndpoints[0] = "changed"
 
 	second, err := GetProvider("gemini", types.AgentClaude)
 	if err != nil {
@@ -47,6 +48,30 @@ func TestGetProviderReturnsIndependentConfigurations(t *testing.T) {
 	if second.Environment["ANTHROPIC_BASE_URL"] == "changed" {
 		t.Fatal("GetProvider() returned shared mutable environment")
 	}
+	if second.LocalEndpoints[0] == "changed" {
+		t.Fatal("GetProvider() returned shared mutable local endpoints")
+	}
+}
+
+// Every preset that points at a host loopback URL declares that endpoint, so a
+// network-isolated sandbox can forward it; portable presets declare none.
+func TestLocalOnlyPresetsDeclareTheirLoopbackEndpoint(t *testing.T) {
+	for _, agentType := range []types.AgentType{types.AgentClaude, types.AgentOpencode} {
+		for _, name := range GetProviderNames(agentType) {
+			provider, err := GetProvider(name, agentType)
+			if err != nil {
+				t.Fatalf("GetProvider(%s, %s) error = %v", name, agentType, err)
+			}
+			baseURL := provider.Environment["ANTHROPIC_BASE_URL"]
+			loopback := strings.HasPrefix(baseURL, "http://127.0.0.1:")
+			if loopback && !reflect.DeepEqual(provider.LocalEndpoints, []string{strings.TrimPrefix(baseURL, "http://")}) {
+				t.Errorf("%s/%s LocalEndpoints = %v, want the %s endpoint", agentType, name, provider.LocalEndpoints, baseURL)
+			}
+			if provider.Portable && len(provider.LocalEndpoints) > 0 {
+				t.Errorf("%s/%s is portable but declares local endpoints %v", agentType, name, provider.LocalEndpoints)
+			}
+		}
+	}
 }
 
 func TestGetPortableProviderRejectsLocalOnlyPreset(t *testing.T) {
@@ -167,3 +192,53 @@ func TestGetProviderCliArgs(t *testing.T) {
 		t.Fatal("GetProviderCliArgs() returned shared mutable arguments")
 	}
 }
+
+func TestCredentialUpstreamReturnsHostCredentialAndBaseURL(t *testing.T) {
+	t.Setenv("ZAI_AUTH_TOKEN", "secret")
+
+	upstream, credential, ok, err := CredentialUpstream(glmProvider())
+	if err != nil || !ok {
+		t.Fatalf("CredentialUpstream() = (%t, %v), want (true, nil)", ok, err)
+	}
+	if upstream != "https://api.z.ai/api/anthropic" || credential != "secret" {
+		t.Fatalf("CredentialUpstream() = (%q, %q), want the z.ai base URL and secret", upstream, credential)
+	}
+}
+
+func TestCredentialUpstreamWithoutCredential(t *testing.T) {
+	if _, _, ok, err := CredentialUpstream(geminiOpencodeProvider()); ok || err != nil {
+		t.Fatalf("CredentialUpstream(no credential) = (%t, %v), want (false, nil)", ok, err)
+	}
+	t.Setenv("ZAI_AUTH_TOKEN", "")
+	if _, _, _, err := CredentialUpstream(glmProvider()); err == nil {
+		t.Fatal("CredentialUpstream(missing token) error = nil, want error")
+	}
+	t.Setenv("CUSTOM_SOURCE_TOKEN", "secret")
+	custom := &types.ModelProvider{Name: "custom", CredentialSourceEnv: "CUSTOM_SOURCE_TOKEN", CredentialTargetEnv: "CUSTOM_TARGET_TOKEN"}
+	if _, _, _, err := CredentialUpstream(custom); err == nil {
+		t.Fatal("CredentialUpstream(no base URL) error = nil, want error")
+	}
+}
+
+func TestShieldProviderReplacesCredentialAndBaseURL(t *testing.T) {
+	t.Setenv("CLI_PROXY_API_KEY", "secret")
+	provider := geminiProvider()
+
+	shielded := ShieldProvider(provider, "http://127.0.0.1:4000", "run-token")
+	env, err := BuildProviderEnv(shielded)
+	if err != nil {
+		t.Fatalf("BuildProviderEnv(shielded) error = %v", err)
+	}
+	if env["ANTHROPIC_AUTH_TOKEN"] != "run-token" || env["ANTHROPIC_BASE_URL"] != "http://127.0.0.1:4000" {
+		t.Fatalf("shielded env = %v, want the run token and shield URL", env)
+	}
+	if len(shielded.LocalEndpoints) != 0 {
+		t.Errorf("shielded LocalEndpoints = %v, want none", shielded.LocalEndpoints)
+	}
+	if provider.Environment["ANTHROPIC_BASE_URL"] != "http://127.0.0.1:8317" || provider.CredentialSourceEnv != "CLI_PROXY_API_KEY" {
+		t.Fatal("ShieldProvider() mutated the original provider")
+	}
+	if env := ShieldProvider(&types.ModelProvider{BaseURLEnv: "BASE", CredentialTargetEnv: "TOKEN"}, "u", "t").Environment; env["BASE"] != "u" || env["TOKEN"] != "t" {
+		t.Errorf("ShieldProvider(no preset env) = %v, want BASE and TOKEN", env)
+	}
+}
@@ -20,4 +20,7 @@ type AgentConfig struct {
 type AgentExecOptions struct {
 	Sandbox         bool
 	ProviderCliArgs []string
+	// Prompt, when set, runs the agent headless on this task: it answers and
+	// exits instead of starting an interactive session.
+	Prompt string
 }
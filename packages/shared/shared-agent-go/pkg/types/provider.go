@@ -7,7 +7,9 @@ type ModelProvider struct {
 	AgentType           AgentType
 	CredentialSourceEnv string            // Host environment variable containing the credential
 	CredentialTargetEnv string            // Agent environment variable that receives the credential
+	BaseURLEnv          string            // Agent environment variable holding the API base URL the credential authenticates against
 	Portable            bool              // Whether the preset is safe outside the local host environment
 	Environment         map[string]string // Environment variables to set when using this provider
 	CliArgs             []string          // CLI arguments to add when using this provider
+	LocalEndpoints      []string          // Host loopback host:port endpoints the agent must reach (e.g. a local model proxy)
 }